@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"jsondrop/internal/models"
+)
+
+// client calls a running server's admin API
+type client struct {
+	baseURL  string
+	adminKey string
+	http     *http.Client
+}
+
+// newClient creates a client for a server URL
+func newClient(baseURL string, adminKey string) *client {
+	return &client{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		adminKey: adminKey,
+		http:     &http.Client{},
+	}
+}
+
+// ListDatabases lists every database
+func (c *client) ListDatabases() ([]*models.Database, error) {
+	var databases []*models.Database
+	err := c.doJSON(http.MethodGet, "/databases", nil, &databases)
+	return databases, err
+}
+
+// SetQuotaLimit changes a database's quota limit in bytes
+func (c *client) SetQuotaLimit(dbID string, limit int64) (*models.Database, error) {
+	var db models.Database
+	req := models.SetQuotaLimitRequest{QuotaLimit: limit}
+	if err := c.doJSON(http.MethodPut, "/databases/"+url.PathEscape(dbID)+"/quota", req, &db); err != nil {
+		return nil, err
+	}
+	return &db, nil
+}
+
+// Fsck checks the catalog and database files
+func (c *client) Fsck() (*models.FsckResponse, error) {
+	var result models.FsckResponse
+	if err := c.doJSON(http.MethodPost, "/fsck", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Backup writes a database's archive to w
+func (c *client) Backup(dbID string, w io.Writer) error {
+	resp, err := c.do(http.MethodGet, "/databases/"+url.PathEscape(dbID)+"/export", nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download archive: %w", err)
+	}
+	return nil
+}
+
+// Restore uploads an archive into a database
+// A restore that stops early returns its partial result along with the error
+func (c *client) Restore(dbID string, r io.Reader) (*models.RestoreResponse, error) {
+	req, err := http.NewRequest(http.MethodPost, c.adminURL("/databases/"+url.PathEscape(dbID)+"/import"), r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("Authorization", "Bearer "+c.adminKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// Restores that stop early respond with the partial result; other failures with an error
+	var result models.RestoreResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, responseError(resp.StatusCode, body)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return &result, nil
+	}
+	if result.Error != "" {
+		return &result, fmt.Errorf("restore stopped: %s", result.Error)
+	}
+	return nil, responseError(resp.StatusCode, body)
+}
+
+// Stats returns the server's statistics
+func (c *client) Stats() (*models.ServerStats, error) {
+	var stats models.ServerStats
+	if err := c.doJSON(http.MethodGet, "/stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Close does nothing; the client holds no resources
+func (c *client) Close() error {
+	return nil
+}
+
+// doJSON sends a request with an optional JSON body and decodes the JSON response into out
+func (c *client) doJSON(method string, path string, in interface{}, out interface{}) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	resp, err := c.do(method, path, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from server: %w", err)
+	}
+	return nil
+}
+
+// do sends an authenticated admin request, returning an error for non-2xx responses
+func (c *client) do(method string, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.adminURL(path), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.adminKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, responseError(resp.StatusCode, data)
+	}
+	return resp, nil
+}
+
+// adminURL returns the URL of an admin endpoint
+func (c *client) adminURL(path string) string {
+	return c.baseURL + "/api/admin" + path
+}
+
+// responseError describes an error response, using the server's message when there is one
+func responseError(status int, body []byte) error {
+	var errResp models.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Message != "" {
+		return fmt.Errorf("server responded %d: %s", status, errResp.Message)
+	}
+	return fmt.Errorf("server responded %d", status)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+const usage = `Usage: jsondropctl [flags] <command> [arguments]
+
+Commands:
+  databases                List databases with their quota usage
+  quota <id> <MB>          Set a database's quota limit in megabytes
+  fsck                     Check the catalog and database files for inconsistencies
+  backup <id> <file>       Export a database archive ("-" writes to stdout)
+  restore <id> <file>      Restore an archive into an existing database ("-" reads stdin)
+  stats [-watch interval]  Show server statistics, repeating every interval if given
+
+Flags:
+`
+
+// backend performs operations against a running server or the catalog on disk
+type backend interface {
+	ListDatabases() ([]*models.Database, error)
+	SetQuotaLimit(dbID string, limit int64) (*models.Database, error)
+	Fsck() (*models.FsckResponse, error)
+	Backup(dbID string, w io.Writer) error
+	Restore(dbID string, r io.Reader) (*models.RestoreResponse, error)
+	Stats() (*models.ServerStats, error)
+	Close() error
+}
+
+func main() {
+	server := flag.String("server", getEnv("JSONDROP_URL", "http://localhost:8080"), "Server URL, including BASE_PATH if set (env JSONDROP_URL)")
+	adminKey := flag.String("admin-key", os.Getenv("ADMIN_KEY"), "Server's ADMIN_KEY (env ADMIN_KEY)")
+	offline := flag.Bool("offline", false, "Open the catalog on disk instead of calling the server, using the server's environment variables")
+	jsonOutput := flag.Bool("json", false, "Print responses as JSON")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var b backend
+	if *offline {
+		catalog, err := openOffline()
+		if err != nil {
+			fatalf("%v", err)
+		}
+		b = catalog
+	} else {
+		b = newClient(*server, *adminKey)
+	}
+
+	err := run(b, flag.Arg(0), flag.Args()[1:], *jsonOutput)
+	b.Close()
+	if err != nil {
+		fatalf("%v", err)
+	}
+}
+
+// run executes a command
+func run(b backend, command string, args []string, jsonOutput bool) error {
+	switch command {
+	case "databases":
+		if err := expectArgs(args, 0); err != nil {
+			return err
+		}
+		databases, err := b.ListDatabases()
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(databases)
+		}
+		printDatabases(databases)
+		return nil
+
+	case "quota":
+		if err := expectArgs(args, 2); err != nil {
+			return err
+		}
+		mb, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || mb <= 0 {
+			return fmt.Errorf("quota must be a positive number of megabytes: %s", args[1])
+		}
+		db, err := b.SetQuotaLimit(args[0], mb*1024*1024)
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(db)
+		}
+		printDatabases([]*models.Database{db})
+		return nil
+
+	case "fsck":
+		if err := expectArgs(args, 0); err != nil {
+			return err
+		}
+		result, err := b.Fsck()
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			if err := printJSON(result); err != nil {
+				return err
+			}
+		} else {
+			for _, problem := range result.Problems {
+				fmt.Printf("%s: %s\n", problem.DatabaseID, problem.Problem)
+			}
+			fmt.Printf("Checked %d databases, found %d problems\n", result.Checked, len(result.Problems))
+		}
+		if len(result.Problems) > 0 {
+			return fmt.Errorf("fsck found problems")
+		}
+		return nil
+
+	case "backup":
+		if err := expectArgs(args, 2); err != nil {
+			return err
+		}
+		return backup(b, args[0], args[1])
+
+	case "restore":
+		if err := expectArgs(args, 2); err != nil {
+			return err
+		}
+		result, restoreErr := restore(b, args[0], args[1])
+		if result != nil {
+			if err := printJSON(result); err != nil {
+				return err
+			}
+		}
+		return restoreErr
+
+	case "stats":
+		fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+		watch := fs.Duration("watch", 0, "Repeat every interval until interrupted")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if err := expectArgs(fs.Args(), 0); err != nil {
+			return err
+		}
+		return stats(b, *watch, jsonOutput)
+
+	default:
+		return fmt.Errorf("unknown command: %s (run jsondropctl -h for usage)", command)
+	}
+}
+
+// backup writes a database archive to a file, or stdout for "-"
+// A failed backup removes the partial file
+func backup(b backend, dbID string, path string) error {
+	if path == "-" {
+		return b.Backup(dbID, os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := b.Backup(dbID, f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	return nil
+}
+
+// restore reads a database archive from a file, or stdin for "-"
+func restore(b backend, dbID string, path string) (*models.RestoreResponse, error) {
+	if path == "-" {
+		return b.Restore(dbID, os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return b.Restore(dbID, f)
+}
+
+// stats prints server statistics once, or every interval until interrupted
+func stats(b backend, interval time.Duration, jsonOutput bool) error {
+	for {
+		s, err := b.Stats()
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			if err := printJSON(s); err != nil {
+				return err
+			}
+		} else {
+			printStats(s)
+		}
+
+		if interval <= 0 {
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// printDatabases prints databases as a table
+func printDatabases(databases []*models.Database) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCREATED\tLAST ACCESSED\tUSED\tLIMIT\tUSED %")
+	for _, db := range databases {
+		percent := 0.0
+		if db.QuotaLimit > 0 {
+			percent = float64(db.QuotaUsed) / float64(db.QuotaLimit) * 100
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%.1f\n", db.ID,
+			db.CreatedAt.Format(time.DateTime), db.LastAccessed.Format(time.DateTime),
+			formatBytes(db.QuotaUsed), formatBytes(db.QuotaLimit), percent)
+	}
+	tw.Flush()
+}
+
+// printStats prints server statistics on one line, so repeated output reads as a log
+func printStats(s *models.ServerStats) {
+	line := time.Now().Format(time.TimeOnly)
+	if !s.StartedAt.IsZero() {
+		line += fmt.Sprintf(" uptime=%s goroutines=%d heap=%s",
+			time.Duration(s.UptimeSeconds)*time.Second, s.Goroutines, formatBytes(int64(s.HeapBytes)))
+	}
+	if c := s.Catalog; c != nil {
+		line += fmt.Sprintf(" databases=%d schemas=%d open=%d quota_used=%s disk=%s",
+			c.Databases, c.Schemas, c.OpenDatabases, formatBytes(c.QuotaUsed), formatBytes(c.DiskBytes))
+	}
+	fmt.Println(line)
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// printJSON prints a value as indented JSON
+func printJSON(value interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
+
+// expectArgs checks a command received exactly n arguments
+func expectArgs(args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("expected %d arguments, got %d (run jsondropctl -h for usage)", n, len(args))
+	}
+	return nil
+}
+
+// getEnv gets an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// fatalf prints an error and exits
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "jsondropctl: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"jsondrop/internal/archive"
+	"jsondrop/internal/config"
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+// offlineCatalog works on the SQLite catalog and database files directly, for when the
+// server is stopped. Changes made while the server runs can conflict with its own writes
+type offlineCatalog struct {
+	catalog *database.CatalogDB
+}
+
+// openOffline opens the catalog the server's configuration points to
+func openOffline() (*offlineCatalog, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if cfg.StorageBackend != "sqlite" {
+		return nil, fmt.Errorf("offline mode requires the sqlite storage backend, not %s", cfg.StorageBackend)
+	}
+	if err := database.SelectDriver(cfg.SQLiteDriver); err != nil {
+		return nil, err
+	}
+
+	limits := database.Limits{
+		MaxCollections:   cfg.MaxCollections,
+		MaxSchemaFields:  cfg.MaxSchemaFields,
+		MaxDocumentBytes: cfg.MaxDocumentBytes,
+	}
+	compression := database.Compression{
+		Threshold: cfg.CompressionThreshold,
+		Algorithm: cfg.CompressionAlgorithm,
+	}
+	// No pooling, so each operation opens and closes its database file
+	catalog, err := database.NewCatalogDB(cfg.CatalogDBPath, cfg.DBBaseDir, cfg.DefaultQuotaMB, limits,
+		database.PoolConfig{}, compression, nil, database.RandomKeys{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog: %w", err)
+	}
+	return &offlineCatalog{catalog: catalog}, nil
+}
+
+// ListDatabases lists every database
+func (o *offlineCatalog) ListDatabases() ([]*models.Database, error) {
+	return o.catalog.ListDatabases()
+}
+
+// SetQuotaLimit changes a database's quota limit in bytes
+func (o *offlineCatalog) SetQuotaLimit(dbID string, limit int64) (*models.Database, error) {
+	if err := o.catalog.SetQuotaLimit(dbID, limit); err != nil {
+		return nil, err
+	}
+	return o.catalog.GetDatabaseByID(dbID)
+}
+
+// Fsck checks the catalog and database files
+func (o *offlineCatalog) Fsck() (*models.FsckResponse, error) {
+	return o.catalog.Check()
+}
+
+// Backup writes a database's archive to w
+func (o *offlineCatalog) Backup(dbID string, w io.Writer) error {
+	if err := o.requireDatabase(dbID); err != nil {
+		return err
+	}
+	return archive.Write(w, o.catalog, dbID)
+}
+
+// Restore restores an archive into a database
+func (o *offlineCatalog) Restore(dbID string, r io.Reader) (*models.RestoreResponse, error) {
+	if err := o.requireDatabase(dbID); err != nil {
+		return nil, err
+	}
+	return archive.Restore(o.catalog, dbID, r)
+}
+
+// Stats returns catalog statistics; there is no server to report on
+func (o *offlineCatalog) Stats() (*models.ServerStats, error) {
+	stats, err := o.catalog.Stats()
+	if err != nil {
+		return nil, err
+	}
+	return &models.ServerStats{Catalog: stats}, nil
+}
+
+// Close closes the catalog
+func (o *offlineCatalog) Close() error {
+	return o.catalog.Close()
+}
+
+// requireDatabase fails if a database doesn't exist
+func (o *offlineCatalog) requireDatabase(dbID string) error {
+	db, err := o.catalog.GetDatabaseByID(dbID)
+	if err != nil {
+		return err
+	}
+	if db == nil {
+		return fmt.Errorf("database not found: %s", dbID)
+	}
+	return nil
+}
@@ -2,19 +2,42 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"jsondrop/internal/accesslog"
 	"jsondrop/internal/api"
+	"jsondrop/internal/bolt"
 	"jsondrop/internal/config"
+	"jsondrop/internal/console"
 	"jsondrop/internal/database"
+	"jsondrop/internal/diagnostics"
 	"jsondrop/internal/events"
+	"jsondrop/internal/fixtures"
+	"jsondrop/internal/oidc"
+	"jsondrop/internal/postgres"
+	"jsondrop/internal/sink"
+	"jsondrop/internal/token"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
 )
 
 func main() {
+	// Keep recent error lines from the server and request logs for diagnostics bundles
+	errorLog := diagnostics.NewErrorLog(diagnostics.DefaultErrorLogLines)
+	log.SetOutput(io.MultiWriter(os.Stderr, errorLog))
+	middleware.DefaultLogger = middleware.RequestLogger(&middleware.DefaultLogFormatter{
+		Logger: log.New(io.MultiWriter(os.Stdout, errorLog), "", log.LstdFlags),
+	})
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -23,31 +46,237 @@ func main() {
 
 	log.Printf("Starting JSONDrop server...")
 	log.Printf("Port: %s", cfg.Port)
-	log.Printf("DB Base Directory: %s", cfg.DBBaseDir)
-	log.Printf("Catalog DB Path: %s", cfg.CatalogDBPath)
+	log.Printf("Storage Backend: %s", cfg.StorageBackend)
+	switch cfg.StorageBackend {
+	case "sqlite":
+		if err := database.SelectDriver(cfg.SQLiteDriver); err != nil {
+			log.Fatalf("Failed to select SQLite driver: %v", err)
+		}
+		log.Printf("SQLite Driver: %s", database.CurrentDriver())
+		log.Printf("DB Base Directory: %s", cfg.DBBaseDir)
+		log.Printf("Catalog DB Path: %s", cfg.CatalogDBPath)
+	case "bolt":
+		log.Printf("Bolt Path: %s", cfg.BoltPath)
+	}
 	log.Printf("CORS Origins: %v", cfg.CORSOrigins)
 	log.Printf("Default Quota: %d MB", cfg.DefaultQuotaMB)
-	log.Printf("Expiry Days: %d", cfg.ExpiryDays)
-	log.Printf("Expiry Check Interval: %v", cfg.ExpiryCheckInterval)
+	if cfg.DevMode {
+		log.Printf("WARNING: Dev mode enabled (demo database, stack traces in errors, no expiry)")
+	} else {
+		log.Printf("Expiry Days: %d", cfg.ExpiryDays)
+		log.Printf("Expiry Check Interval: %v", cfg.ExpiryCheckInterval)
+	}
+	log.Printf("Max Collections: %d, Max Schema Fields: %d (0 = unlimited)", cfg.MaxCollections, cfg.MaxSchemaFields)
+	log.Printf("Max Open Databases: %d, Idle Timeout: %v (0 = no pooling / no idle eviction)", cfg.MaxOpenDatabases, cfg.DatabaseIdleTimeout)
+	if cfg.CompressionThreshold > 0 {
+		log.Printf("Compressing documents over %d bytes with %s", cfg.CompressionThreshold, cfg.CompressionAlgorithm)
+	}
+	if cfg.VacuumInterval > 0 {
+		log.Printf("Vacuum Interval: %v (files with %d%% free pages)", cfg.VacuumInterval, cfg.VacuumMinFreePercent)
+	} else {
+		log.Printf("Vacuum disabled (VACUUM_INTERVAL=0)")
+	}
+	if cfg.QuotaRecalcInterval > 0 {
+		log.Printf("Quota Recalculation Interval: %v", cfg.QuotaRecalcInterval)
+	} else {
+		log.Printf("Quota recalculation disabled (QUOTA_RECALC_INTERVAL=0)")
+	}
+	if cfg.RetentionInterval > 0 {
+		log.Printf("Retention Interval: %v (batches of %d documents)", cfg.RetentionInterval, cfg.RetentionBatchSize)
+		if cfg.EventLogRetention > 0 {
+			log.Printf("Event Log Retention: %v", cfg.EventLogRetention)
+		}
+	} else {
+		log.Printf("Retention disabled (RETENTION_INTERVAL=0)")
+	}
+	if cfg.BasePath != "" {
+		log.Printf("Base Path: %s", cfg.BasePath)
+	}
+	if cfg.AccessLog.Path != "" {
+		log.Printf("Access Log: %s (%s, rotate at %d MB, keep %d)", cfg.AccessLog.Path,
+			cfg.AccessLog.Format, cfg.AccessLog.MaxSizeMB, cfg.AccessLog.MaxBackups)
+	}
+	if cfg.FixturesDir != "" {
+		log.Printf("Fixtures Directory: %s", cfg.FixturesDir)
+	}
+	if cfg.AdminKey == "" {
+		log.Printf("Admin endpoints disabled (ADMIN_KEY not set)")
+	}
+	if cfg.Faults.Enabled {
+		log.Printf("WARNING: Fault injection enabled (latency %v @ %.2f, errors @ %.2f, quota @ %.2f, %d route rules)",
+			cfg.Faults.Default.Latency, cfg.Faults.Default.LatencyRate,
+			cfg.Faults.Default.ErrorRate, cfg.Faults.Default.QuotaRate, len(cfg.Faults.Routes))
+	}
 
 	// Initialize event broadcaster
-	broadcaster := events.NewBroadcaster()
+	broadcaster := events.NewBroadcaster(events.RateLimit{
+		EventsPerSecond: cfg.EventRateLimit,
+		Coalesce:        cfg.EventCoalesce,
+
+		ListenerEventsPerSecond: cfg.EventStreamRate,
+		ListenerBurst:           cfg.EventStreamBurst,
+	}, cfg.EventBufferSize)
 	log.Println("Event broadcaster initialized")
 
-	// Initialize catalog database
-	catalog, err := database.NewCatalogDB(cfg.CatalogDBPath, cfg.DBBaseDir, cfg.DefaultQuotaMB, broadcaster)
+	// Publish every change event to a message broker
+	var publisher *sink.Publisher
+	if cfg.EventSink.Kind != "" {
+		publisher, err = sink.New(cfg.EventSink)
+		if err != nil {
+			log.Fatalf("Failed to configure event sink: %v", err)
+		}
+		broadcaster.Observe(publisher.Observe)
+		log.Printf("Publishing change events to %s topic %s", cfg.EventSink.Kind, cfg.EventSink.Topic)
+	}
+
+	// Relay change events between server instances
+	var relay *events.Relay
+	if cfg.EventRelay.Addr != "" {
+		relay, err = events.NewRelay(broadcaster, cfg.EventRelay)
+		if err != nil {
+			log.Fatalf("Failed to configure event relay: %v", err)
+		}
+		log.Printf("Relaying change events through Redis at %s on channel %s", cfg.EventRelay.Addr, cfg.EventRelay.Channel)
+	}
+
+	// Initialize storage
+	limits := database.Limits{
+		MaxCollections:   cfg.MaxCollections,
+		MaxSchemaFields:  cfg.MaxSchemaFields,
+		MaxDocumentBytes: cfg.MaxDocumentBytes,
+		QueryTimeout:     cfg.QueryTimeout,
+		MaxRowsScanned:   cfg.MaxRowsScanned,
+	}
+	// Deployments with their own ID or key schemes pass another database.KeyGenerator here
+	keys := database.RandomKeys{}
+
+	// The catalog stays nil with other backends, which lack the SQLite-only features
+	var store database.Store
+	var catalog *database.CatalogDB
+	switch cfg.StorageBackend {
+	case "postgres":
+		pg, err := postgres.Open(cfg.PostgresURL, cfg.DefaultQuotaMB, limits, broadcaster, keys)
+		if err != nil {
+			log.Fatalf("Failed to initialize postgres store: %v", err)
+		}
+		store = pg
+		log.Println("PostgreSQL store initialized successfully")
+	case "bolt":
+		bs, err := bolt.Open(cfg.BoltPath, cfg.DefaultQuotaMB, limits, broadcaster, keys)
+		if err != nil {
+			log.Fatalf("Failed to initialize bolt store: %v", err)
+		}
+		store = bs
+		log.Println("Bolt store initialized successfully")
+	default:
+		pool := database.PoolConfig{
+			MaxOpen:     cfg.MaxOpenDatabases,
+			IdleTimeout: cfg.DatabaseIdleTimeout,
+		}
+		compression := database.Compression{
+			Threshold: cfg.CompressionThreshold,
+			Algorithm: cfg.CompressionAlgorithm,
+		}
+		catalog, err = database.NewCatalogDB(cfg.CatalogDBPath, cfg.DBBaseDir, cfg.DefaultQuotaMB, limits, pool, compression, broadcaster, keys)
+		if err != nil {
+			log.Fatalf("Failed to initialize catalog database: %v", err)
+		}
+		store = catalog
+
+		log.Println("Catalog database initialized successfully")
+		caps := catalog.Capabilities()
+		log.Printf("SQLite capabilities: fts5=%t json1=%t icu=%t dbstat=%t", caps.FTS5, caps.JSON1, caps.ICU, caps.DBStat)
+		if !caps.FTS5 {
+			log.Println("Full-text search disabled: build with -tags sqlite_fts5 to enable")
+		}
+		if !caps.ICU && database.CurrentDriver() == database.DriverMattn {
+			log.Println("Unicode collation disabled: build with -tags sqlite_icu to enable")
+		} else if !caps.ICU {
+			log.Println("Unicode collation disabled: the modernc driver has no ICU")
+		}
+		if !caps.DBStat {
+			log.Println("Collection stats estimate index sizes: build with CGO_CFLAGS=-DSQLITE_ENABLE_DBSTAT_VTAB for on-disk sizes")
+		}
+	}
+	defer store.Close()
+
+	if catalog != nil {
+		// Load fixtures
+		if cfg.FixturesDir != "" {
+			summary, err := fixtures.LoadDir(catalog, cfg.FixturesDir)
+			if err != nil {
+				log.Fatalf("Failed to load fixtures: %v", err)
+			}
+			log.Printf("Loaded fixtures: %d files, %d databases, %d schemas, %d documents",
+				summary.Files, summary.Databases, summary.Schemas, summary.Documents)
+		}
+
+		// Create the demo database for local development
+		if cfg.DevMode {
+			demo, created, err := fixtures.LoadDemo(catalog, keys)
+			if err != nil {
+				log.Fatalf("Failed to create demo database: %v", err)
+			}
+			if created {
+				log.Printf("Created demo database with example todos and notes collections")
+			}
+			log.Printf("Demo database: %s", demo.ID)
+			log.Printf("Demo write key: %s", demo.WriteKey)
+			log.Printf("Demo read key: %s", demo.ReadKey)
+		}
+
+		// Compact database files in the background
+		if cfg.VacuumInterval > 0 {
+			go vacuumRoutine(catalog, cfg.VacuumInterval, cfg.VacuumMinFreePercent)
+		}
+
+		// Correct quota usage drift in the background
+		if cfg.QuotaRecalcInterval > 0 {
+			go quotaRecalcRoutine(catalog, cfg.QuotaRecalcInterval)
+		}
+
+		// Delete documents past their collection's retention period in the background,
+		// events past EVENT_LOG_RETENTION from each database's event log, and expired keys
+		if cfg.RetentionInterval > 0 {
+			go retentionRoutine(catalog, cfg.RetentionInterval, cfg.RetentionBatchSize, cfg.EventLogRetention)
+		}
+	}
+
+	// Create API handlers
+	tokens, err := token.NewIssuer([]byte(cfg.TokenSecret))
 	if err != nil {
-		log.Fatalf("Failed to initialize catalog database: %v", err)
+		log.Fatalf("Failed to initialize access tokens: %v", err)
 	}
-	defer catalog.Close()
+	if cfg.TokenSecret == "" {
+		log.Println("TOKEN_SECRET not set: access tokens stop working when the server restarts")
+	}
+	handler := api.NewHandler(store, broadcaster, cfg, tokens)
+	admin := api.NewAdminHandler(catalog, broadcaster, cfg, errorLog)
 
-	log.Println("Catalog database initialized successfully")
+	// Serve the web console when an OpenID Connect provider is configured
+	var consoleRoutes http.Handler
+	if cfg.OIDC.Enabled() {
+		if catalog == nil {
+			log.Fatalf("The web console requires the sqlite storage backend")
+		}
+		provider := oidc.NewProvider(cfg.OIDC.Issuer, cfg.OIDC.ClientID, cfg.OIDC.ClientSecret, cfg.OIDC.RedirectURL)
+		consoleRoutes = console.New(catalog, provider, tokens, handler.Lockout(), cfg).Routes()
+		log.Printf("Web console at %s/console, signing in with %s", cfg.BasePath, cfg.OIDC.Issuer)
+	}
 
-	// Create API handler
-	handler := api.NewHandler(catalog, broadcaster)
+	// Open access log
+	var accessLog *accesslog.Logger
+	if cfg.AccessLog.Path != "" {
+		accessLogFile, err := accesslog.OpenRotatingFile(cfg.AccessLog.Path, cfg.AccessLog.MaxSizeMB*1024*1024, cfg.AccessLog.MaxBackups)
+		if err != nil {
+			log.Fatalf("Failed to open access log: %v", err)
+		}
+		defer accessLogFile.Close()
+		accessLog = accesslog.New(accessLogFile, cfg.AccessLog.Format)
+	}
 
 	// Create router
-	router := api.NewRouter(handler, catalog, cfg.CORSOrigins)
+	router := api.NewRouter(handler, admin, consoleRoutes, store, cfg, accessLog)
 
 	// Start HTTP server
 	addr := fmt.Sprintf(":%s", cfg.Port)
@@ -56,6 +285,47 @@ func main() {
 		Handler: router,
 	}
 
+	// Serve HTTPS directly when configured, with an optional plain HTTP port redirecting to it
+	var redirectServer *http.Server
+	if cfg.TLS.Enabled() {
+		tlsConfig, redirect, err := serverTLS(cfg.TLS, cfg.Port)
+		if err != nil {
+			log.Fatalf("Failed to initialize TLS: %v", err)
+		}
+		server.TLSConfig = tlsConfig
+		if len(cfg.TLS.AutocertHosts) > 0 {
+			log.Printf("Obtaining Let's Encrypt certificates for %s", strings.Join(cfg.TLS.AutocertHosts, ", "))
+		}
+		if cfg.TLS.RedirectPort != "" {
+			redirectServer = &http.Server{
+				Addr:    fmt.Sprintf(":%s", cfg.TLS.RedirectPort),
+				Handler: redirect,
+			}
+			go func() {
+				log.Printf("Redirecting HTTP on %s to HTTPS", redirectServer.Addr)
+				if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Fatalf("HTTP redirect server failed: %v", err)
+				}
+			}()
+		}
+	}
+
+	// Start the gRPC server; clients connect with HTTP/2 without TLS
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != "" {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.GRPCPort))
+		if err != nil {
+			log.Fatalf("gRPC server failed: %v", err)
+		}
+		grpcServer = api.NewGRPCServer(handler)
+		go func() {
+			log.Printf("gRPC server listening on %s", listener.Addr())
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("gRPC server failed: %v", err)
+			}
+		}()
+	}
+
 	// Graceful shutdown
 	go func() {
 		sigint := make(chan os.Signal, 1)
@@ -63,15 +333,114 @@ func main() {
 		<-sigint
 
 		log.Println("Shutting down server...")
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+		if redirectServer != nil {
+			redirectServer.Close()
+		}
 		if err := server.Close(); err != nil {
 			log.Printf("Server shutdown error: %v", err)
 		}
 	}()
 
-	log.Printf("Server listening on %s", addr)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if cfg.TLS.Enabled() {
+		log.Printf("Server listening on %s (HTTPS)", addr)
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		log.Printf("Server listening on %s", addr)
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server failed: %v", err)
 	}
 
+	if relay != nil {
+		relay.Close()
+	}
+	if publisher != nil {
+		if left := publisher.Flush(5 * time.Second); left > 0 {
+			log.Printf("Event sink: %d change events left unpublished", left)
+		}
+	}
+
 	log.Println("Server stopped")
 }
+
+// vacuumRoutine periodically compacts database files with enough free pages
+func vacuumRoutine(catalog *database.CatalogDB, interval time.Duration, minFreePercent int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		summary, err := catalog.VacuumDatabases(minFreePercent)
+		if err != nil {
+			log.Printf("Vacuum errors: %v", err)
+		}
+		if summary.Vacuumed > 0 || summary.Failed > 0 {
+			log.Printf("Vacuum: compacted %d of %d database files, reclaimed %d KB, %d failed",
+				summary.Vacuumed, summary.Checked, summary.ReclaimedBytes/1024, summary.Failed)
+		}
+	}
+}
+
+// quotaRecalcRoutine periodically recomputes every database's quota usage from its documents
+func quotaRecalcRoutine(catalog *database.CatalogDB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		summary, err := catalog.RecalculateQuotas()
+		if err != nil {
+			log.Printf("Quota recalculation errors: %v", err)
+		}
+		if summary.Corrected > 0 || summary.Failed > 0 {
+			log.Printf("Quota recalculation: corrected %d of %d databases, %d failed",
+				summary.Corrected, summary.Checked, summary.Failed)
+		}
+	}
+}
+
+// retentionRoutine periodically deletes documents older than their collection's retention period,
+// events older than eventLogRetention unless it is 0, and expired scoped keys
+func retentionRoutine(catalog *database.CatalogDB, interval time.Duration, batchSize int, eventLogRetention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		summary, err := catalog.ApplyRetention(time.Now(), batchSize)
+		if err != nil {
+			log.Printf("Retention errors: %v", err)
+		}
+		if summary.Deleted > 0 || summary.Failed > 0 {
+			log.Printf("Retention: deleted %d documents from %d collections, reclaimed %d KB, %d failed",
+				summary.Deleted, summary.Collections, summary.ReclaimedBytes/1024, summary.Failed)
+		}
+
+		if eventLogRetention > 0 {
+			pruned, err := catalog.PruneEventLogs(time.Now().Add(-eventLogRetention), batchSize)
+			if err != nil {
+				log.Printf("Event log pruning errors: %v", err)
+			}
+			if pruned > 0 {
+				log.Printf("Event log: pruned %d events older than %v", pruned, eventLogRetention)
+			}
+		}
+
+		removed, err := catalog.DeleteExpiredScopedKeys(time.Now())
+		if err != nil {
+			log.Printf("Expired key cleanup error: %v", err)
+		}
+		if removed > 0 {
+			log.Printf("Scoped keys: removed %d expired keys", removed)
+		}
+
+		removed, err = catalog.DeleteExpiredShareLinks(time.Now())
+		if err != nil {
+			log.Printf("Expired share link cleanup error: %v", err)
+		}
+		if removed > 0 {
+			log.Printf("Share links: removed %d expired links", removed)
+		}
+	}
+}
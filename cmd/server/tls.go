@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"jsondrop/internal/config"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certCheckInterval is how often certificate files are checked for renewals
+const certCheckInterval = time.Minute
+
+// serverTLS returns the TLS settings of the HTTPS server, and the handler of the plain
+// HTTP redirect port, which also answers Let's Encrypt's HTTP challenges with autocert
+func serverTLS(cfg config.TLSConfig, httpsPort string) (*tls.Config, http.Handler, error) {
+	redirect := redirectToHTTPS(httpsPort)
+	if len(cfg.AutocertHosts) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertHosts...),
+			Cache:      autocert.DirCache(cfg.AutocertCache),
+			Email:      cfg.AutocertEmail,
+		}
+		tlsConfig := manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return tlsConfig, manager.HTTPHandler(redirect), nil
+	}
+
+	reloader, err := newCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+	return tlsConfig, redirect, nil
+}
+
+// redirectToHTTPS redirects requests to the same URL over HTTPS on httpsPort
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// certReloader serves a certificate from files, loading them again when they change, so
+// renewed certificates are picked up without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time // Newest modification time of the files when loaded
+	checked time.Time
+}
+
+// newCertReloader loads a certificate and its key, failing if they don't load
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads the files; the caller holds the lock, or has the only reference
+func (r *certReloader) load() error {
+	modTime, err := r.newestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	r.cert, r.modTime = &cert, modTime
+	return nil
+}
+
+// newestModTime returns when the certificate or key file last changed
+func (r *certReloader) newestModTime() (time.Time, error) {
+	var newest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to read TLS certificate: %w", err)
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
+
+// getCertificate returns the current certificate, reloading the files at most every
+// certCheckInterval if they changed. A failed reload keeps the previous certificate
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) >= certCheckInterval {
+		r.checked = time.Now()
+		if modTime, err := r.newestModTime(); err == nil && !modTime.Equal(r.modTime) {
+			if err := r.load(); err != nil {
+				log.Printf("Keeping the previous TLS certificate: %v", err)
+			} else {
+				log.Printf("Reloaded TLS certificate from %s", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
@@ -0,0 +1,137 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"jsondrop/internal/config"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Entry is a single access log record
+type Entry struct {
+	Time         time.Time `json:"time"`
+	RemoteAddr   string    `json:"remote_addr"`
+	Method       string    `json:"method"`
+	URI          string    `json:"uri"` // API keys in the query string are redacted
+	Proto        string    `json:"proto"`
+	Status       int       `json:"status"`
+	Bytes        int       `json:"bytes"`         // Response body size
+	RequestBytes int64     `json:"request_bytes"` // Request body size, -1 if unknown
+	DurationMS   float64   `json:"duration_ms"`
+	DatabaseID   string    `json:"database_id,omitempty"`
+	Collection   string    `json:"collection,omitempty"`
+	Referer      string    `json:"referer,omitempty"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+}
+
+// Logger writes one access log line per request
+type Logger struct {
+	out    io.Writer
+	format string
+}
+
+// New creates a Logger writing in the given format (config.AccessLogCombined or config.AccessLogJSON)
+func New(out io.Writer, format string) *Logger {
+	return &Logger{out: out, format: format}
+}
+
+// Middleware records every request passing through it
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		entry := Entry{
+			Time:         start,
+			RemoteAddr:   remoteHost(r.RemoteAddr),
+			Method:       r.Method,
+			URI:          redactURI(r),
+			Proto:        r.Proto,
+			Status:       ww.Status(),
+			Bytes:        ww.BytesWritten(),
+			RequestBytes: r.ContentLength,
+			DurationMS:   float64(time.Since(start).Microseconds()) / 1000,
+			Referer:      r.Referer(),
+			UserAgent:    r.UserAgent(),
+		}
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK // Handler wrote nothing
+		}
+
+		// Routing fills in the URL parameters on the shared route context
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			entry.DatabaseID = rctx.URLParam("id")
+			entry.Collection = rctx.URLParam("collection")
+		}
+
+		if _, err := io.WriteString(l.out, l.Format(entry)); err != nil {
+			// Log but don't fail; the response has already been sent
+		}
+	})
+}
+
+// Format renders an entry as a single line, including the trailing newline
+func (l *Logger) Format(e Entry) string {
+	if l.format == config.AccessLogJSON {
+		data, _ := json.Marshal(e)
+		return string(data) + "\n"
+	}
+	return formatCombined(e)
+}
+
+// formatCombined renders an entry in combined log format
+// The database ID takes the authuser field so per-database usage can be
+// reconstructed with standard tooling
+func formatCombined(e Entry) string {
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %d \"%s\" \"%s\"\n",
+		orDash(e.RemoteAddr),
+		orDash(e.DatabaseID),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, e.URI, e.Proto,
+		e.Status, e.Bytes,
+		orDash(quoteEscape(e.Referer)),
+		orDash(quoteEscape(e.UserAgent)),
+	)
+}
+
+// redactURI returns the request URI with the key query parameter hidden
+func redactURI(r *http.Request) string {
+	query := r.URL.Query()
+	if query.Get("key") == "" {
+		return r.URL.RequestURI()
+	}
+	query.Set("key", "REDACTED")
+	return r.URL.EscapedPath() + "?" + query.Encode()
+}
+
+// remoteHost strips the port from a remote address
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// quoteEscape escapes characters that would break a quoted log field
+func quoteEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// orDash returns "-" for empty fields, as combined log format expects
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,75 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/config"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestFormatCombined(t *testing.T) {
+	entry := Entry{
+		Time:       time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC),
+		RemoteAddr: "203.0.113.7",
+		Method:     "GET",
+		URI:        "/api/databases/db_abc/users/",
+		Proto:      "HTTP/1.1",
+		Status:     200,
+		Bytes:      512,
+		DatabaseID: "db_abc",
+		UserAgent:  `curl/8.0 "test"`,
+	}
+
+	want := `203.0.113.7 - db_abc [15/Jan/2025:10:30:00 +0000] "GET /api/databases/db_abc/users/ HTTP/1.1" 200 512 "-" "curl/8.0 \"test\""` + "\n"
+	if got := New(nil, config.AccessLogCombined).Format(entry); got != want {
+		t.Errorf("Format() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, config.AccessLogJSON)
+
+	r := chi.NewRouter()
+	r.Use(logger.Middleware)
+	r.Get("/api/databases/{id}/{collection}/events", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})
+
+	req := httptest.NewRequest("GET", "/api/databases/db_abc/users/events?key=rk_secret&since=5", nil)
+	req.RemoteAddr = "198.51.100.2:54321"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := out.String()
+	if !strings.HasSuffix(line, "\n") || strings.Count(line, "\n") != 1 {
+		t.Fatalf("expected a single line, got %q", line)
+	}
+	if strings.Contains(line, "rk_secret") {
+		t.Errorf("access log leaked the API key: %s", line)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatalf("invalid JSON line: %v", err)
+	}
+	if entry.Status != http.StatusTeapot || entry.Bytes != len("short and stout") {
+		t.Errorf("Status, Bytes = %d, %d, want %d, %d", entry.Status, entry.Bytes, http.StatusTeapot, len("short and stout"))
+	}
+	if entry.DatabaseID != "db_abc" || entry.Collection != "users" {
+		t.Errorf("DatabaseID, Collection = %q, %q, want db_abc, users", entry.DatabaseID, entry.Collection)
+	}
+	if entry.RemoteAddr != "198.51.100.2" {
+		t.Errorf("RemoteAddr = %q, want 198.51.100.2", entry.RemoteAddr)
+	}
+	if entry.URI != "/api/databases/db_abc/users/events?key=REDACTED&since=5" {
+		t.Errorf("URI = %q", entry.URI)
+	}
+}
@@ -0,0 +1,62 @@
+package accesslog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := OpenRotatingFile(path, 20, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	defer f.Close()
+
+	// Each line is 10 bytes, so every file holds two lines
+	for _, line := range []string{"line 0001\n", "line 0002\n", "line 0003\n", "line 0004\n", "line 0005\n", "line 0006\n", "line 0007\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "line 0007\n",
+		path + ".1": "line 0005\nline 0006\n",
+		path + ".2": "line 0003\nline 0004\n",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", filepath.Base(name), err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(name), data, content)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists, want at most 2 backups", filepath.Base(path))
+	}
+}
+
+func TestRotatingFile_AppendsToExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte("old line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenRotatingFile(path, 0, 0)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	f.Write([]byte("new line\n"))
+	f.Close()
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "old line\n") || !strings.HasSuffix(string(data), "new line\n") {
+		t.Errorf("file = %q, want appended content", data)
+	}
+}
@@ -0,0 +1,151 @@
+package analytics
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"jsondrop/internal/models"
+)
+
+// Analytical queries run in an embedded DuckDB, compiled in only with the duckdb build tag.
+// Each query loads the documents the caller may read into a fresh in-memory table named
+// after the collection and runs with external access disabled, so it can see nothing but
+// that table and nothing it does outlives the request.
+
+const (
+	MaxRows      = 10000            // Rows returned per query; the response is marked truncated beyond this
+	QueryTimeout = 30 * time.Second // Loading the collection and running the query
+	memoryLimit  = "256MB"          // Per query
+)
+
+// Documents streams the documents a query may read to fn
+type Documents func(fn func(*models.Document) error) error
+
+// ValidateQuery checks that a query is a single SELECT statement
+// Semicolons are only allowed at the end, so they can't appear in string literals either
+func ValidateQuery(query string) error {
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\n")
+	if query == "" {
+		return fmt.Errorf("invalid query: query cannot be empty")
+	}
+	if strings.Contains(query, ";") {
+		return fmt.Errorf("invalid query: only a single statement is allowed")
+	}
+
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) > 0 {
+		switch strings.ToUpper(words[0]) {
+		case "SELECT", "WITH", "FROM":
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid query: only SELECT statements are allowed")
+}
+
+// quoteIdentifier quotes a table or column name for DuckDB
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// columnType returns the DuckDB type holding a schema field
+func columnType(fieldType models.FieldType) string {
+	switch fieldType {
+	case models.FieldTypeNumber:
+		return "DOUBLE"
+	case models.FieldTypeBool:
+		return "BOOLEAN"
+	default:
+		return "VARCHAR"
+	}
+}
+
+// sortedFields returns a schema's field names in alphabetical order
+func sortedFields(schema *models.Schema) []string {
+	fields := make([]string, 0, len(schema.Fields))
+	for name := range schema.Fields {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// createTableSQL returns the statement creating a collection's table
+// Metadata columns come first, then schema fields in alphabetical order, as in exports
+func createTableSQL(schema *models.Schema) string {
+	columns := []string{
+		"id VARCHAR NOT NULL",
+		"created_at TIMESTAMP NOT NULL",
+		"updated_at TIMESTAMP NOT NULL",
+		"visibility VARCHAR NOT NULL",
+	}
+	for _, field := range sortedFields(schema) {
+		columns = append(columns, quoteIdentifier(field)+" "+columnType(schema.Fields[field]))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdentifier(schema.Name), strings.Join(columns, ", "))
+}
+
+// documentRow returns a document's values in table column order
+// Fields that are missing or don't hold the schema type are null
+func documentRow(schema *models.Schema, fields []string, doc *models.Document) []driver.Value {
+	row := []driver.Value{doc.ID, doc.CreatedAt.UTC(), doc.UpdatedAt.UTC(), string(doc.Visibility)}
+	for _, field := range fields {
+		var value driver.Value
+		switch v := doc.Data[field].(type) {
+		case string:
+			if schema.Fields[field] == models.FieldTypeString {
+				value = v
+			}
+		case float64:
+			if schema.Fields[field] == models.FieldTypeNumber {
+				value = v
+			}
+		case bool:
+			if schema.Fields[field] == models.FieldTypeBool {
+				value = v
+			}
+		}
+		row = append(row, value)
+	}
+	return row
+}
+
+// jsonValue converts a query result value into one encoding/json can represent
+// NaN and infinities become null, and map keys become strings
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil
+		}
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = jsonValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = jsonValue(item)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return out
+	}
+	return value
+}
@@ -0,0 +1,113 @@
+package analytics
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestValidateQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"select", "SELECT count(*) FROM sales", false},
+		{"lowercase with trailing semicolon", "select 1;", false},
+		{"with", "WITH t AS (SELECT 1) SELECT * FROM t", false},
+		{"from first", "FROM sales", false},
+		{"leading parenthesis", "(SELECT 1)", false},
+		{"empty", "  ; ", true},
+		{"two statements", "SELECT 1; SELECT 2", true},
+		{"semicolon in literal", "SELECT ';'", true},
+		{"drop", "DROP TABLE sales", true},
+		{"set", "SET enable_external_access = true", true},
+		{"attach", "ATTACH 'other.db'", true},
+		{"no words", "1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateQuery(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "invalid query") {
+				t.Errorf("ValidateQuery(%q) error = %q, want it to start with \"invalid query\"", tt.query, err)
+			}
+		})
+	}
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	schema := &models.Schema{
+		Name: "sales",
+		Fields: map[string]models.FieldType{
+			"region":  models.FieldTypeString,
+			"amount":  models.FieldTypeNumber,
+			"paid":    models.FieldTypeBool,
+			`odd"col`: models.FieldTypeString,
+		},
+	}
+
+	want := `CREATE TABLE "sales" (id VARCHAR NOT NULL, created_at TIMESTAMP NOT NULL, ` +
+		`updated_at TIMESTAMP NOT NULL, visibility VARCHAR NOT NULL, "amount" DOUBLE, ` +
+		`"odd""col" VARCHAR, "paid" BOOLEAN, "region" VARCHAR)`
+	if got := createTableSQL(schema); got != want {
+		t.Errorf("createTableSQL() = %s\nwant %s", got, want)
+	}
+}
+
+func TestDocumentRow(t *testing.T) {
+	schema := &models.Schema{
+		Name: "sales",
+		Fields: map[string]models.FieldType{
+			"region": models.FieldTypeString,
+			"amount": models.FieldTypeNumber,
+			"paid":   models.FieldTypeBool,
+		},
+	}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))
+	doc := &models.Document{
+		ID:         "doc_1",
+		CreatedAt:  created,
+		UpdatedAt:  created,
+		Visibility: models.VisibilityPublic,
+		Data:       map[string]interface{}{"region": "north", "amount": "12"},
+	}
+
+	row := documentRow(schema, sortedFields(schema), doc)
+	if len(row) != 7 {
+		t.Fatalf("documentRow() returned %d values, want 7", len(row))
+	}
+	if row[0] != "doc_1" || row[1] != created.UTC() || row[3] != "public" {
+		t.Errorf("documentRow() metadata = %v, want id, UTC timestamps and visibility", row[:4])
+	}
+	// amount holds the wrong type and paid is missing, so both are null
+	if row[4] != nil || row[5] != nil || row[6] != "north" {
+		t.Errorf("documentRow() fields = %v, want [<nil> <nil> north]", row[4:])
+	}
+}
+
+func TestJSONValue(t *testing.T) {
+	in := []interface{}{
+		1.5,
+		math.NaN(),
+		map[interface{}]interface{}{int32(1): math.Inf(1)},
+		map[string]interface{}{"a": []interface{}{"x", math.Inf(-1)}},
+	}
+	want := []interface{}{
+		1.5,
+		nil,
+		map[string]interface{}{"1": nil},
+		map[string]interface{}{"a": []interface{}{"x", nil}},
+	}
+
+	if got := jsonValue(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("jsonValue() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !duckdb
+
+package analytics
+
+import (
+	"context"
+	"fmt"
+
+	"jsondrop/internal/models"
+)
+
+// Enabled reports whether the server was built with DuckDB
+func Enabled() bool {
+	return false
+}
+
+// Query is unavailable without DuckDB
+func Query(ctx context.Context, schema *models.Schema, docs Documents, query string) (*models.AnalyticsResponse, error) {
+	return nil, fmt.Errorf("analytics is not available: server built without DuckDB support")
+}
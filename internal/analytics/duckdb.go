@@ -0,0 +1,137 @@
+//go:build duckdb
+
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"jsondrop/internal/models"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+// Enabled reports whether the server was built with DuckDB
+func Enabled() bool {
+	return true
+}
+
+// Query runs an analytical query over a collection's documents in a fresh in-memory DuckDB
+// Returns errors starting with "invalid query" for queries DuckDB rejects
+func Query(ctx context.Context, schema *models.Schema, docs Documents, query string) (*models.AnalyticsResponse, error) {
+	if err := ValidateQuery(query); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	// Documents are loaded through the appender, which needs no external access
+	connector, err := duckdb.NewConnector(fmt.Sprintf("?enable_external_access=false&memory_limit=%s", memoryLimit), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start analytics engine: %w", err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start analytics engine: %w", err)
+	}
+	defer conn.Close()
+
+	if err := load(ctx, conn, schema, docs); err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "SET lock_configuration = true"); err != nil {
+		return nil, fmt.Errorf("failed to start analytics engine: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	resp := &models.AnalyticsResponse{Columns: columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		if len(resp.Rows) == MaxRows {
+			resp.Truncated = true
+			break
+		}
+
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, queryError(ctx, err)
+		}
+		for i, value := range values {
+			values[i] = resultValue(value)
+		}
+		resp.Rows = append(resp.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return resp, nil
+}
+
+// load creates the collection's table and appends the documents to it
+func load(ctx context.Context, conn *sql.Conn, schema *models.Schema, docs Documents) error {
+	if _, err := conn.ExecContext(ctx, createTableSQL(schema)); err != nil {
+		return fmt.Errorf("failed to create analytics table: %w", err)
+	}
+
+	fields := sortedFields(schema)
+	return conn.Raw(func(driverConn interface{}) error {
+		appender, err := duckdb.NewAppenderFromConn(driverConn.(driver.Conn), "", schema.Name)
+		if err != nil {
+			return fmt.Errorf("failed to load documents: %w", err)
+		}
+
+		err = docs(func(doc *models.Document) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return appender.AppendRow(documentRow(schema, fields, doc)...)
+		})
+		if closeErr := appender.Close(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load documents: %w", err)
+		}
+		return nil
+	})
+}
+
+// queryError reports a failed query, distinguishing timeouts from queries DuckDB rejects
+func queryError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("query timed out after %v", QueryTimeout)
+	}
+	return fmt.Errorf("invalid query: %w", err)
+}
+
+// resultValue converts DuckDB-specific result types before jsonValue
+func resultValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case duckdb.Decimal:
+		return v.Float64()
+	case duckdb.Map:
+		return jsonValue(map[interface{}]interface{}(v))
+	}
+	return jsonValue(value)
+}
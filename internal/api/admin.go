@@ -0,0 +1,223 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"jsondrop/internal/archive"
+	"jsondrop/internal/config"
+	"jsondrop/internal/database"
+	"jsondrop/internal/diagnostics"
+	"jsondrop/internal/events"
+	"jsondrop/internal/fixtures"
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminHandler holds dependencies for operator-only endpoints
+type AdminHandler struct {
+	catalog     *database.CatalogDB
+	broadcaster *events.Broadcaster
+	cfg         *config.Config
+	errorLog    *diagnostics.ErrorLog
+	started     time.Time
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(catalog *database.CatalogDB, broadcaster *events.Broadcaster, cfg *config.Config, errorLog *diagnostics.ErrorLog) *AdminHandler {
+	return &AdminHandler{
+		catalog:     catalog,
+		broadcaster: broadcaster,
+		cfg:         cfg,
+		errorLog:    errorLog,
+		started:     time.Now(),
+	}
+}
+
+// adminMiddleware requires the configured ADMIN_KEY as a bearer token
+// Admin routes are disabled entirely when no key is configured
+func adminMiddleware(adminKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if adminKey == "" {
+				respondError(w, http.StatusNotFound, "Not Found", "Admin endpoints are disabled")
+				return
+			}
+
+			provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(adminKey)) != 1 {
+				respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid admin key")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ReloadFixtures handles POST /api/admin/fixtures/reload
+func (h *AdminHandler) ReloadFixtures(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.FixturesDir == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "FIXTURES_DIR is not configured")
+		return
+	}
+
+	summary, err := fixtures.LoadDir(h.catalog, h.cfg.FixturesDir)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Failed to load fixtures: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, summary)
+}
+
+// Diagnostics handles GET /api/admin/diagnostics
+// Returns a tarball of sanitized diagnostics to attach to bug reports
+func (h *AdminHandler) Diagnostics(w http.ResponseWriter, r *http.Request) {
+	// Build in memory so a failure can still be reported as an error response
+	var buf bytes.Buffer
+	if err := diagnostics.WriteBundle(&buf, h.catalog, h.cfg, h.errorLog); err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to build diagnostics: "+err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("jsondrop-diagnostics-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// ListDatabases handles GET /api/admin/databases
+func (h *AdminHandler) ListDatabases(w http.ResponseWriter, r *http.Request) {
+	databases, err := h.catalog.ListDatabases()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, databases)
+}
+
+// SetQuotaLimit handles PUT /api/admin/databases/:id/quota
+func (h *AdminHandler) SetQuotaLimit(w http.ResponseWriter, r *http.Request) {
+	dbID := chi.URLParam(r, "id")
+
+	var req models.SetQuotaLimitRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.QuotaLimit <= 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "quota_limit must be a positive number of bytes")
+		return
+	}
+
+	if err := h.catalog.SetQuotaLimit(dbID, req.QuotaLimit); err != nil {
+		if strings.Contains(err.Error(), "database not found") {
+			respondError(w, http.StatusNotFound, "Not Found", "Database not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	db, err := h.catalog.GetDatabaseByID(dbID)
+	if err != nil || db == nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to read updated database")
+		return
+	}
+	respondJSON(w, http.StatusOK, db)
+}
+
+// ExportDatabase handles GET /api/admin/databases/:id/export
+// Produces the same archive as the write-key export, for backups of any database
+func (h *AdminHandler) ExportDatabase(w http.ResponseWriter, r *http.Request) {
+	db := h.lookupDatabase(w, r)
+	if db == nil {
+		return
+	}
+
+	filename := fmt.Sprintf("%s.jsondrop.tar.gz", db.ID)
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if err := archive.Write(w, h.catalog, db.ID); err != nil {
+		w.Header().Del("Content-Disposition")
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to export database: "+err.Error())
+		return
+	}
+}
+
+// ImportDatabase handles POST /api/admin/databases/:id/import
+// Restores an archive into an existing database, as the write-key import does
+func (h *AdminHandler) ImportDatabase(w http.ResponseWriter, r *http.Request) {
+	db := h.lookupDatabase(w, r)
+	if db == nil {
+		return
+	}
+
+	result, err := archive.Restore(h.catalog, db.ID, r.Body)
+	if err != nil {
+		respondJSON(w, restoreErrorStatus(err), result)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// lookupDatabase returns the database named in the URL, responding 404 if there is none
+func (h *AdminHandler) lookupDatabase(w http.ResponseWriter, r *http.Request) *models.Database {
+	db, err := h.catalog.GetDatabaseByID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return nil
+	}
+	if db == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Database not found")
+		return nil
+	}
+	return db
+}
+
+// Fsck handles POST /api/admin/fsck
+// Reports inconsistencies between the catalog and database files without repairing them
+func (h *AdminHandler) Fsck(w http.ResponseWriter, r *http.Request) {
+	result, err := h.catalog.Check()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// Stats handles GET /api/admin/stats
+func (h *AdminHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	catalogStats, err := h.catalog.Stats()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	respondJSON(w, http.StatusOK, models.ServerStats{
+		StartedAt:     h.started,
+		UptimeSeconds: int64(time.Since(h.started).Seconds()),
+		Goroutines:    runtime.NumGoroutine(),
+		HeapBytes:     mem.HeapAlloc,
+		Catalog:       catalogStats,
+	})
+}
+
+// Streams handles GET /api/admin/streams
+// Reports open event streams and event traffic, with every storage backend
+func (h *AdminHandler) Streams(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, h.broadcaster.Stats())
+}
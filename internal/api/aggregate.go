@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"jsondrop/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AggregateDocuments handles GET /api/databases/:id/:collection/aggregate
+// Documents are counted per date or numeric bucket and a number field is summarized,
+// selected by the same filters as a query
+func (h *Handler) AggregateDocuments(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	if collection == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Collection name is required")
+		return
+	}
+
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		return
+	}
+	schema = readableSchema(r, schema)
+
+	query := r.URL.Query()
+	bucketing, err := database.ParseBucketing(query.Get("field"), query.Get("interval"), query.Get("bucket_size"), schema.Fields)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	statsField, err := database.ParseStatsField(query.Get("stats"), schema.Fields)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	if bucketing == nil && statsField == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "invalid aggregation: field or stats is required")
+		return
+	}
+
+	dates, err := database.ParseTimeRange(query)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	filters, err := schemaFilters(query, schema, "field", "interval", "bucket_size", "stats")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+
+	scope, err := readScopeFromContext(r, schema)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	result, err := h.catalog.AggregateDocuments(db.ID, collection, bucketing, statsField, filters, dates, scope)
+	if err != nil {
+		if respondQueryLimit(w, err) {
+			return
+		}
+		if strings.HasPrefix(err.Error(), "invalid aggregation") {
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
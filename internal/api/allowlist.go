@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"jsondrop/internal/clientaddr"
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+// GetAllowlist handles GET /api/databases/:id/allowlist
+func (h *Handler) GetAllowlist(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	networks := db.AllowedNetworks
+	if networks == nil {
+		networks = []string{}
+	}
+	respondJSON(w, http.StatusOK, models.Allowlist{DatabaseID: db.ID, Networks: networks})
+}
+
+// SetAllowlist handles PUT /api/databases/:id/allowlist
+// Replaces the networks the database's keys and tokens work from; an empty list allows
+// every address. Lists leaving out the address of the request itself are refused, so the
+// caller can't lock itself out
+func (h *Handler) SetAllowlist(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.SetAllowlistRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	networks, err := database.NormalizeNetworks(req.Networks)
+	if err != nil {
+		if strings.Contains(err.Error(), "limit exceeded") {
+			respondError(w, http.StatusUnprocessableEntity, "Unprocessable Entity", err.Error())
+			return
+		}
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	if len(networks) > 0 {
+		addr, ok := clientaddr.FromRequest(r, h.cfg.TrustedProxies)
+		if !ok || !database.NetworksAllow(networks, addr) {
+			respondError(w, http.StatusUnprocessableEntity, "Unprocessable Entity", "The allowlist must include the address of this request: "+addr.String())
+			return
+		}
+	}
+
+	if err := h.store.SetAllowedNetworks(db.ID, networks); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.Allowlist{DatabaseID: db.ID, Networks: networks})
+}
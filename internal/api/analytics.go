@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"jsondrop/internal/analytics"
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// QueryAnalytics handles POST /api/databases/:id/:collection/analytics
+// Runs a read-only SQL query over the documents the caller may read, in an embedded DuckDB
+func (h *Handler) QueryAnalytics(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	if collection == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Collection name is required")
+		return
+	}
+
+	var req models.AnalyticsRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		return
+	}
+	schema = readableSchema(r, schema)
+
+	scope, err := readScopeFromContext(r, schema)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	docs := func(fn func(*models.Document) error) error {
+		return h.store.EachDocument(db.ID, collection, scope, fn)
+	}
+	result, err := analytics.Query(r.Context(), schema, docs, req.Query)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "analytics is not available"):
+			respondError(w, http.StatusNotImplemented, "Not Implemented", err.Error())
+		case strings.HasPrefix(err.Error(), "invalid query"):
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		case strings.HasPrefix(err.Error(), "query timed out"):
+			respondError(w, http.StatusGatewayTimeout, "Gateway Timeout", err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"jsondrop/internal/archive"
+)
+
+// ExportDatabase handles GET /api/databases/:id/export
+// Streams every schema and document as a single archive that ImportDatabase can restore
+func (h *Handler) ExportDatabase(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	filename := fmt.Sprintf("%s.jsondrop.tar.gz", db.ID)
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// Collections are staged before anything is written, so most failures
+	// happen while an error response can still be sent
+	if err := archive.Write(w, h.catalog, db.ID); err != nil {
+		w.Header().Del("Content-Disposition")
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to export database: "+err.Error())
+		return
+	}
+}
+
+// ImportDatabase handles POST /api/databases/:id/import
+// Restores an archive produced by ExportDatabase into this database
+func (h *Handler) ImportDatabase(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	result, err := archive.Restore(h.catalog, db.ID, r.Body)
+	if err != nil {
+		respondJSON(w, restoreErrorStatus(err), result)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// restoreErrorStatus maps a restore that stopped early to a status code
+func restoreErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "schema conflict"):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "limit exceeded"):
+		return http.StatusUnprocessableEntity
+	case strings.Contains(err.Error(), "invalid archive"), strings.Contains(err.Error(), "invalid schema name"),
+		strings.Contains(err.Error(), "invalid index"):
+		return http.StatusBadRequest
+	default:
+		return importErrorStatus(err)
+	}
+}
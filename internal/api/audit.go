@@ -0,0 +1,155 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"jsondrop/internal/clientaddr"
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unauditedRoutes are the routes whose POST requests only read, by the end of their pattern
+var unauditedRoutes = []string{"/{collection}/analytics", "/events/ack"}
+
+// requestCredential names what authenticated a request, for the audit log
+type requestCredential struct {
+	kind string // models.CredentialWriteKey, CredentialReadKey, CredentialScopedKey or CredentialToken
+	id   string // Scoped key or token ID, or a fingerprint of the database key
+}
+
+// databaseKeyCredential identifies a database key by the start of its SHA-256, which
+// tells rotated keys apart without revealing them
+func databaseKeyCredential(key string, isWrite bool) requestCredential {
+	sum := sha256.Sum256([]byte(key))
+	kind := models.CredentialReadKey
+	if isWrite {
+		kind = models.CredentialWriteKey
+	}
+	return requestCredential{kind: kind, id: hex.EncodeToString(sum[:6])}
+}
+
+// auditRequests records authenticated requests that may change the database in its audit
+// log once they are answered, including those refused
+func (h *Handler) auditRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		db := getDatabaseFromContext(r)
+		if h.catalog == nil || db == nil || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		rctx := chi.RouteContext(r.Context())
+		pattern := rctx.RoutePattern()
+		for _, route := range unauditedRoutes {
+			if strings.HasSuffix(pattern, route) {
+				return
+			}
+		}
+		collection := rctx.URLParam("collection")
+		if collection == "" && strings.Contains(pattern, "/schemas/{name}") {
+			collection = rctx.URLParam("name")
+		}
+		h.recordAudit(r, db.ID, r.Method, collection, rctx.URLParam("docId"), sw.status)
+	})
+}
+
+// grpcAudit records an authenticated gRPC write in the database's audit log
+func (h *Handler) grpcAudit(r *http.Request, collection string, documentID string, err error) {
+	db := getDatabaseFromContext(r)
+	if h.catalog == nil || db == nil {
+		return
+	}
+	h.recordAudit(r, db.ID, "GRPC", collection, documentID, grpcHTTPStatus(err))
+}
+
+// recordAudit appends a request to the audit log; a failure is logged rather than
+// failing a request that has already been answered
+func (h *Handler) recordAudit(r *http.Request, dbID string, method string, collection string, documentID string, status int) {
+	credential, _ := r.Context().Value(contextKeyCredential).(requestCredential)
+	entry := models.AuditEntry{
+		Credential: credential.kind,
+		KeyID:      credential.id,
+		Method:     method,
+		Path:       r.URL.Path,
+		Collection: collection,
+		DocumentID: documentID,
+		Status:     status,
+		Timestamp:  time.Now(),
+	}
+	if addr, ok := clientaddr.FromRequest(r, h.cfg.TrustedProxies); ok {
+		entry.ClientAddr = addr.String()
+	}
+	if err := h.catalog.RecordAudit(dbID, entry); err != nil {
+		log.Printf("Failed to record audit entry of %s: %v", dbID, err)
+	}
+}
+
+// grpcHTTPStatus maps the outcome of a gRPC call to the HTTP status the same failure
+// gets from the HTTP API
+func grpcHTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	s, _ := status.FromError(err)
+	switch s.Code() {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.ResourceExhausted:
+		if strings.Contains(s.Message(), "quota exceeded") {
+			return http.StatusPaymentRequired
+		}
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+// AuditLog handles GET /api/databases/:id/audit
+// ?since= takes a sequence number from an earlier page, or a timestamp; ?limit=,
+// ?collection=, ?document= and ?key_id= narrow the page
+func (h *Handler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	query := r.URL.Query()
+	q := database.AuditQuery{
+		Collection: query.Get("collection"),
+		DocumentID: query.Get("document"),
+		KeyID:      query.Get("key_id"),
+	}
+	var ok bool
+	if q.Since, q.SinceTime, ok = parseLogPosition(w, query.Get("since")); !ok {
+		return
+	}
+	if q.Limit, ok = parseLogLimit(w, query.Get("limit")); !ok {
+		return
+	}
+
+	page, err := h.catalog.AuditLog(db.ID, q)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"jsondrop/internal/i18n"
+	"jsondrop/internal/models"
+)
+
+// maxBatchOperations caps the operations in one batch request
+const maxBatchOperations = 1000
+
+// Failure codes of batch operations, besides the validation codes
+const (
+	batchInvalid          = "invalid"
+	batchNotFound         = "not_found"
+	batchRevisionMismatch = "revision_mismatch"
+	batchRevisionRequired = "revision_required"
+	batchQuotaExceeded    = "quota_exceeded"
+	batchTooLarge         = codeDocumentTooLarge
+	batchReadOnly         = "read_only"
+	batchForbidden        = "forbidden"
+	batchInternal         = "internal"
+)
+
+// Batch handles POST /api/databases/:id/batch
+// Operations are applied in order, each on its own: a failure is reported at its position
+// and doesn't stop or undo the others, so clients can retry just the failures
+func (h *Handler) Batch(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.BatchRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if len(req.Operations) == 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Batch must have at least one operation")
+		return
+	}
+	if len(req.Operations) > maxBatchOperations {
+		respondError(w, http.StatusBadRequest, "Bad Request",
+			fmt.Sprintf("Batch has %d operations, maximum is %d", len(req.Operations), maxBatchOperations))
+		return
+	}
+
+	lang := i18n.Negotiate(r.Header.Get("Accept-Language"))
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+
+	resp := models.BatchResponse{Results: make([]models.BatchResult, len(req.Operations))}
+	schemas := make(map[string]*models.Schema)
+	var warnings []string
+	for i, op := range req.Operations {
+		var result models.BatchResult
+		if keyAllowsCollection(r, op.Collection) {
+			result = h.applyBatchOperation(db.ID, lang, op, schemas)
+		} else {
+			result = batchFailure(batchForbidden, "key may not write collection: "+op.Collection)
+		}
+		if result.Status == models.BatchFailed {
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+			if schema := schemas[op.Collection]; schema != nil {
+				warnings = append(warnings, deprecationWarnings(schema, op.Data)...)
+			}
+		}
+		resp.Results[i] = result
+	}
+
+	// One warning per deprecated field written, however many operations wrote it
+	slices.Sort(warnings)
+	addWarnings(w, slices.Compact(warnings))
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// applyBatchOperation applies one batch operation, looking up schemas once per batch
+// Validation failures are described in lang
+func (h *Handler) applyBatchOperation(dbID string, lang string, op models.BatchOperation, schemas map[string]*models.Schema) models.BatchResult {
+	if op.Collection == "" {
+		return batchFailure(batchInvalid, "collection is required")
+	}
+	switch op.Op {
+	case models.BatchInsert:
+		if op.ID != "" {
+			return batchFailure(batchInvalid, "insert takes no id; the server assigns one")
+		}
+	case models.BatchUpdate, models.BatchDelete:
+		if op.ID == "" {
+			return batchFailure(batchInvalid, op.Op+" requires an id")
+		}
+		if op.Revision < 0 {
+			return batchFailure(batchInvalid, "revision must be positive")
+		}
+		// The revision stands in for If-Match, mandatory unless REQUIRE_IF_MATCH is off
+		if op.Revision == 0 && h.cfg.RequireIfMatch {
+			return batchFailure(batchRevisionRequired, op.Op+" requires a revision")
+		}
+	default:
+		return batchFailure(batchInvalid, fmt.Sprintf("unknown op %q: use insert, update or delete", op.Op))
+	}
+	if op.Op != models.BatchDelete {
+		if len(op.Data) == 0 {
+			return batchFailure(batchInvalid, "document data cannot be empty")
+		}
+		if err := models.CheckDocumentSize(op.Data, h.cfg.MaxDocumentBytes); err != nil {
+			if errors.As(err, new(*models.DocumentTooLargeError)) {
+				return batchFailure(batchTooLarge, err.Error())
+			}
+			return batchFailure(batchInvalid, err.Error())
+		}
+		if op.Visibility != "" && !op.Visibility.IsValid() {
+			return batchFailure(batchInvalid, "invalid visibility: "+string(op.Visibility))
+		}
+	}
+
+	readOnly, err := h.isMirrorTarget(dbID, op.Collection)
+	if err != nil {
+		return batchFailure(batchInternal, err.Error())
+	}
+	if readOnly {
+		return batchFailure(batchReadOnly, "collection is a read-only mirror: "+op.Collection)
+	}
+
+	schema, cached := schemas[op.Collection]
+	if !cached {
+		if schema, err = h.store.GetSchema(dbID, op.Collection); err != nil {
+			return batchFailure(batchInternal, "failed to get schema")
+		}
+		schemas[op.Collection] = schema
+	}
+	if schema == nil {
+		return batchFailure(batchNotFound, "collection does not exist: "+op.Collection)
+	}
+
+	if op.Op != models.BatchDelete {
+		if err := models.ValidateDocument(op.Data, schema); err != nil {
+			var validationErr *models.ValidationError
+			if errors.As(err, &validationErr) {
+				return models.BatchResult{
+					Status: models.BatchFailed,
+					Code:   validationErr.Code,
+					Field:  validationErr.Field,
+					Error:  i18n.ValidationMessage(lang, validationErr),
+				}
+			}
+			return batchFailure(batchInvalid, "validation failed: "+err.Error())
+		}
+	}
+
+	switch op.Op {
+	case models.BatchInsert:
+		doc, err := h.store.InsertDocument(dbID, op.Collection, op.Data, op.Visibility)
+		if err != nil {
+			return batchError(err)
+		}
+		return models.BatchResult{Status: models.BatchCreated, ID: doc.ID, Revision: doc.Revision}
+	case models.BatchUpdate:
+		doc, err := h.store.UpdateDocument(dbID, op.Collection, op.ID, op.Data, op.Visibility, op.Revision)
+		if err != nil {
+			return batchError(err)
+		}
+		return models.BatchResult{Status: models.BatchUpdated, ID: doc.ID, Revision: doc.Revision}
+	default:
+		if err := h.store.DeleteDocument(dbID, op.Collection, op.ID, op.Revision); err != nil {
+			return batchError(err)
+		}
+		return models.BatchResult{Status: models.BatchDeleted, ID: op.ID}
+	}
+}
+
+// batchFailure reports a failed batch operation
+func batchFailure(code string, message string) models.BatchResult {
+	return models.BatchResult{Status: models.BatchFailed, Code: code, Error: message}
+}
+
+// batchError reports a batch operation the store refused
+func batchError(err error) models.BatchResult {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return batchFailure(batchNotFound, err.Error())
+	case strings.Contains(err.Error(), "revision mismatch"):
+		return batchFailure(batchRevisionMismatch, err.Error())
+	case strings.Contains(err.Error(), "quota exceeded"):
+		return batchFailure(batchQuotaExceeded, err.Error())
+	default:
+		return batchFailure(batchInternal, err.Error())
+	}
+}
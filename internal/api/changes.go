@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Changes handles GET /api/databases/:id/:collection/changes
+// Polls for the documents changed since ?since=, a timestamp or the next position of the
+// previous poll, for clients that can't hold an event stream open
+func (h *Handler) Changes(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		return
+	}
+
+	query := r.URL.Query()
+	var q database.ChangesQuery
+	var ok bool
+	if q.Since, q.SinceTime, ok = parseLogPosition(w, query.Get("since")); !ok {
+		return
+	}
+	if q.Limit, ok = parseLogLimit(w, query.Get("limit")); !ok {
+		return
+	}
+
+	scope, err := readScopeFromContext(r, schema)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	changes, err := h.catalog.Changes(db.ID, collection, q, scope)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+	var documents []*models.Document
+	for _, change := range changes.Changes {
+		if change.Document != nil {
+			documents = append(documents, change.Document)
+		}
+	}
+	stripMasked(r, schema, documents)
+
+	respondJSON(w, http.StatusOK, changes)
+}
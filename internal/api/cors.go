@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+// GetCORSOrigins handles GET /api/databases/:id/cors
+func (h *Handler) GetCORSOrigins(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	origins := db.CORSOrigins
+	if origins == nil {
+		origins = []string{}
+	}
+	respondJSON(w, http.StatusOK, models.CORSOrigins{DatabaseID: db.ID, Origins: origins})
+}
+
+// SetCORSOrigins handles PUT /api/databases/:id/cors
+// Replaces the browser origins allowed to call the database's routes besides CORS_ORIGINS;
+// an empty list leaves only CORS_ORIGINS
+func (h *Handler) SetCORSOrigins(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.SetCORSOriginsRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	origins, err := database.NormalizeOrigins(req.Origins)
+	if err != nil {
+		if strings.Contains(err.Error(), "limit exceeded") {
+			respondError(w, http.StatusUnprocessableEntity, "Unprocessable Entity", err.Error())
+			return
+		}
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+
+	if err := h.store.SetCORSOrigins(db.ID, origins); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.CORSOrigins{DatabaseID: db.ID, Origins: origins})
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/models"
+)
+
+// Headers carrying what a client passes to create a database
+const (
+	headerChallenge = "X-JSONDrop-Challenge" // Proof-of-work challenge from a challenge_required error
+	headerSolution  = "X-JSONDrop-Solution"  // Its solution
+	headerCaptcha   = "X-JSONDrop-Captcha"   // Response token of the captcha widget
+)
+
+// Codes of creation refused until the client passes a challenge
+const (
+	codeChallengeRequired = "challenge_required"
+	codeCaptchaRequired   = "captcha_required"
+)
+
+// challengeTTL is how long a proof-of-work challenge can be solved and used
+const challengeTTL = 5 * time.Minute
+
+// captchaClient calls captcha providers, which answer quickly or not at all
+var captchaClient = &http.Client{Timeout: 10 * time.Second}
+
+// spentChallenges remembers solved challenges until they expire, so each solution
+// creates one database
+type spentChallenges struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+	pruned  time.Time // When expired challenges were last dropped
+}
+
+// spend records a solved challenge, reporting false if it was spent already
+func (s *spentChallenges) spend(challenge string, expires time.Time, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.expires == nil {
+		s.expires = make(map[string]time.Time)
+	}
+	if now.Sub(s.pruned) >= time.Minute {
+		for spent, at := range s.expires {
+			if !now.Before(at) {
+				delete(s.expires, spent)
+			}
+		}
+		s.pruned = now
+	}
+	if _, ok := s.expires[challenge]; ok {
+		return false
+	}
+	s.expires[challenge] = expires
+	return true
+}
+
+// passCreationChallenge checks the proof of work or captcha creating a database takes,
+// answering the request if it wasn't passed
+func (h *Handler) passCreationChallenge(w http.ResponseWriter, r *http.Request, addr netip.Addr) bool {
+	switch h.cfg.Creation.Challenge {
+	case config.ChallengePoW:
+		return h.passProofOfWork(w, r)
+	case config.ChallengeCaptcha:
+		return h.passCaptcha(w, r, addr)
+	}
+	return true
+}
+
+// passProofOfWork checks a request's solution to a proof-of-work challenge, answering
+// with a new challenge if it has none or it is wrong
+func (h *Handler) passProofOfWork(w http.ResponseWriter, r *http.Request) bool {
+	now := time.Now()
+	difficulty := h.cfg.Creation.PoWDifficulty
+	message := fmt.Sprintf("Solve the challenge: find a solution whose SHA-256 of challenge:solution starts with %d zero bits, "+
+		"and repeat the request with it in %s and %s within %d minutes", difficulty, headerChallenge, headerSolution, int(challengeTTL.Minutes()))
+	if challenge := r.Header.Get(headerChallenge); challenge != "" {
+		expires, err := h.tokens.VerifyProof(challenge, r.Header.Get(headerSolution), difficulty, now)
+		if err == nil && h.spentChallenges.spend(challenge, expires, now) {
+			return true
+		}
+		if err == nil {
+			err = fmt.Errorf("invalid challenge: already used")
+		}
+		message = fmt.Sprintf("Challenge rejected (%v): solve the new challenge", err)
+	}
+
+	challenge, err := h.tokens.Challenge(now.Add(challengeTTL))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return false
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusForbidden, models.ErrorResponse{
+		Error:      "Forbidden",
+		Message:    message,
+		Code:       codeChallengeRequired,
+		Challenge:  challenge,
+		Difficulty: difficulty,
+	})
+	return false
+}
+
+// passCaptcha verifies a request's captcha response with the provider
+func (h *Handler) passCaptcha(w http.ResponseWriter, r *http.Request, addr netip.Addr) bool {
+	response := r.Header.Get(headerCaptcha)
+	if response == "" {
+		respondJSON(w, http.StatusForbidden, models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: "Solve the captcha and repeat the request with its response in " + headerCaptcha,
+			Code:    codeCaptchaRequired,
+		})
+		return false
+	}
+	ok, err := verifyCaptcha(r.Context(), h.cfg.Creation, response, addr)
+	if err != nil {
+		log.Printf("Captcha verification failed: %v", err)
+		respondError(w, http.StatusBadGateway, "Bad Gateway", "Captcha verification failed, try again later")
+		return false
+	}
+	if !ok {
+		respondJSON(w, http.StatusForbidden, models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: "Captcha rejected: solve a new captcha",
+			Code:    codeCaptchaRequired,
+		})
+		return false
+	}
+	return true
+}
+
+// verifyCaptcha asks the captcha provider whether a response is valid, with the
+// siteverify API hCaptcha, Turnstile and reCAPTCHA share
+func verifyCaptcha(ctx context.Context, cfg config.CreationConfig, response string, addr netip.Addr) (bool, error) {
+	form := url.Values{"secret": {cfg.CaptchaSecret}, "response": {response}}
+	if addr.IsValid() {
+		form.Set("remoteip", addr.String())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.CaptchaVerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := captchaClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("siteverify answered %s", resp.Status)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, 1<<16)).Decode(&result); err != nil {
+		return false, fmt.Errorf("malformed siteverify response: %w", err)
+	}
+	return result.Success, nil
+}
+
+// respondCreationLimited refuses a client that created as many databases as it may for
+// now, telling it when it may create more
+func respondCreationLimited(w http.ResponseWriter, cfg config.CreationConfig, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	respondError(w, http.StatusTooManyRequests, "Too Many Requests",
+		fmt.Sprintf("Database creation limit reached: at most %d databases per %s from one address", cfg.RateLimit, cfg.RateWindow))
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DeprecateField handles PUT /api/databases/:id/schemas/:name/deprecations/:field
+// Writes may omit the field from now on; once the grace period ends they may no longer
+// include it, and the field can be removed from the schema
+func (h *Handler) DeprecateField(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	schemaName := chi.URLParam(r, "name")
+	field := chi.URLParam(r, "field")
+
+	var req models.DeprecateFieldRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.GraceDays < 0 || req.GraceDays > database.MaxGraceDays {
+		respondError(w, http.StatusBadRequest, "Bad Request",
+			fmt.Sprintf("invalid grace_days: must be between 0 and %d", database.MaxGraceDays))
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	deprecation := models.FieldDeprecation{
+		DeprecatedAt: now,
+		Until:        now.AddDate(0, 0, req.GraceDays),
+		Note:         req.Note,
+	}
+	schema, err := h.catalog.DeprecateField(db.ID, schemaName, field, deprecation)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, schema)
+}
+
+// UndeprecateField handles DELETE /api/databases/:id/schemas/:name/deprecations/:field
+// The field is required on writes again
+func (h *Handler) UndeprecateField(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	schema, err := h.catalog.UndeprecateField(db.ID, chi.URLParam(r, "name"), chi.URLParam(r, "field"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, schema)
+}
+
+// deprecationWarnings returns a Warning header value for each deprecated field in data
+func deprecationWarnings(schema *models.Schema, data map[string]interface{}) []string {
+	var warnings []string
+	for field, deprecation := range schema.Deprecated {
+		if _, included := data[field]; !included {
+			continue
+		}
+		message := fmt.Sprintf("field '%s' of %s is deprecated and can't be written after %s",
+			field, schema.Name, deprecation.Until.UTC().Format(time.RFC3339))
+		if deprecation.Note != "" {
+			message += ": " + deprecation.Note
+		}
+		warnings = append(warnings, "299 jsondrop "+strconv.Quote(message))
+	}
+	slices.Sort(warnings)
+	return warnings
+}
+
+// addWarnings adds Warning headers to a response
+func addWarnings(w http.ResponseWriter, warnings []string) {
+	for _, warning := range warnings {
+		w.Header().Add("Warning", warning)
+	}
+}
+
+// omitDeprecated reads ?omit_deprecated, which leaves deprecated fields out of read results
+func omitDeprecated(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	value := r.URL.Query().Get("omit_deprecated")
+	if value == "" {
+		return false, true
+	}
+	omit, err := strconv.ParseBool(value)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid omit_deprecated parameter: "+value)
+		return false, false
+	}
+	return omit, true
+}
+
+// stripDeprecated removes a schema's deprecated fields from documents
+func stripDeprecated(schema *models.Schema, documents []*models.Document) {
+	for _, doc := range documents {
+		for field := range schema.Deprecated {
+			delete(doc.Data, field)
+		}
+	}
+}
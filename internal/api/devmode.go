@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"jsondrop/internal/models"
+)
+
+// devErrors is DEV_MODE middleware adding a stack trace to server error responses
+// It stands in for middleware.Recoverer: a panic is answered with its value and stack
+// instead of an empty 500
+func devErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dw := &devErrorWriter{ResponseWriter: w}
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				stack := debug.Stack()
+				log.Printf("panic: %v\n%s", p, stack)
+				if !dw.wroteHeader {
+					respondJSON(w, http.StatusInternalServerError, models.ErrorResponse{
+						Error:   "Internal Server Error",
+						Message: fmt.Sprintf("panic: %v", p),
+						Stack:   string(stack),
+					})
+				}
+				return
+			}
+			dw.finish()
+		}()
+		next.ServeHTTP(dw, r)
+	})
+}
+
+// devErrorWriter holds back 5xx responses until the handler returns, to add the stack
+// of the handler that wrote them. Other responses pass straight through
+type devErrorWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	status      int    // Held back 5xx status, or 0
+	stack       []byte // Stack when the 5xx status was written
+	body        bytes.Buffer
+}
+
+// WriteHeader holds back 5xx statuses and writes others
+func (w *devErrorWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status >= http.StatusInternalServerError {
+		w.status = status
+		w.stack = debug.Stack()
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write buffers the body of a held back response
+func (w *devErrorWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status != 0 {
+		return w.body.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *devErrorWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish sends a held back response, with the stack added to JSON error bodies
+func (w *devErrorWriter) finish() {
+	if w.status == 0 {
+		return
+	}
+	body := w.body.Bytes()
+	var resp models.ErrorResponse
+	if json.Unmarshal(body, &resp) == nil && resp.Error != "" {
+		resp.Stack = string(w.stack)
+		if verbose, err := json.Marshal(resp); err == nil {
+			body = append(verbose, '\n')
+		}
+	}
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"jsondrop/internal/models"
+)
+
+// documentETag returns a document's entity tag: its revision, quoted
+func documentETag(doc *models.Document) string {
+	return strconv.Quote(strconv.FormatInt(doc.Revision, 10))
+}
+
+// setDocumentETag sends a document's entity tag with a response
+func setDocumentETag(w http.ResponseWriter, doc *models.Document) {
+	w.Header().Set("ETag", documentETag(doc))
+}
+
+// ifMatchRevision returns the document revision a write's If-Match header requires, or 0 for any
+// It responds and returns false when the header is malformed, or missing unless REQUIRE_IF_MATCH is off
+// Only a single strong entity tag or "*" is accepted
+func (h *Handler) ifMatchRevision(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if h.cfg.RequireIfMatch {
+			respondError(w, http.StatusPreconditionRequired, "Precondition Required", "If-Match header with the document's ETag is required")
+			return 0, false
+		}
+		return 0, true
+	}
+	if header == "*" {
+		return 0, true
+	}
+
+	unquoted, err := strconv.Unquote(header)
+	if err == nil {
+		revision, err := strconv.ParseInt(unquoted, 10, 64)
+		if err == nil && revision > 0 {
+			return revision, true
+		}
+	}
+	respondError(w, http.StatusBadRequest, "Bad Request", "Invalid If-Match header: expected a single ETag such as \"3\"")
+	return 0, false
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+	"jsondrop/internal/parquet"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ExportCollection handles GET /api/databases/:id/:collection/export
+// Documents are streamed as NDJSON (default), CSV or Parquet without buffering the collection
+func (h *Handler) ExportCollection(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	if collection == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Collection name is required")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	if format != "ndjson" && format != "csv" && format != "parquet" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid format: "+format+" (expected ndjson, csv or parquet)")
+		return
+	}
+
+	// Verify schema exists for this collection
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		return
+	}
+	schema = readableSchema(r, schema)
+
+	scope, err := readScopeFromContext(r, schema)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", db.ID, collection, format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// Headers are committed once the first row is written, so later errors
+	// can only truncate the stream
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		err = h.exportCSV(w, db.ID, schema, scope)
+	case "parquet":
+		w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+		err = h.exportParquet(w, db.ID, schema, scope)
+	default:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		err = h.exportNDJSON(w, db.ID, collection, scope)
+	}
+	if err != nil {
+		// Log but nothing more can be sent
+	}
+}
+
+// exportNDJSON writes one JSON document per line
+func (h *Handler) exportNDJSON(w http.ResponseWriter, dbID string, collection string, scope *database.ReadScope) error {
+	encoder := json.NewEncoder(w)
+	return h.store.EachDocument(dbID, collection, scope, func(doc *models.Document) error {
+		for _, field := range scope.MaskedFields() {
+			delete(doc.Data, field)
+		}
+		return encoder.Encode(doc)
+	})
+}
+
+// exportCSV writes a header row followed by one row per document
+// Metadata columns come first, then schema fields in alphabetical order
+func (h *Handler) exportCSV(w http.ResponseWriter, dbID string, schema *models.Schema, scope *database.ReadScope) error {
+	fields := sortedSchemaFields(schema)
+
+	writer := csv.NewWriter(w)
+	header := append([]string{"id", "created_at", "updated_at", "visibility"}, fields...)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	err := h.store.EachDocument(dbID, schema.Name, scope, func(doc *models.Document) error {
+		record := []string{
+			doc.ID,
+			doc.CreatedAt.UTC().Format(time.RFC3339),
+			doc.UpdatedAt.UTC().Format(time.RFC3339),
+			string(doc.Visibility),
+		}
+		for _, field := range fields {
+			record = append(record, formatCSVValue(doc.Data[field]))
+		}
+		return writer.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportParquet writes a Parquet file with the same columns as the CSV export
+// Schema fields are typed columns; documents missing a field store null
+func (h *Handler) exportParquet(w http.ResponseWriter, dbID string, schema *models.Schema, scope *database.ReadScope) error {
+	fields := sortedSchemaFields(schema)
+
+	columns := []parquet.Column{
+		{Name: "id", Type: parquet.String},
+		{Name: "created_at", Type: parquet.Timestamp},
+		{Name: "updated_at", Type: parquet.Timestamp},
+		{Name: "visibility", Type: parquet.String},
+	}
+	for _, field := range fields {
+		column := parquet.Column{Name: field, Type: parquet.String, Optional: true}
+		switch schema.Fields[field] {
+		case models.FieldTypeNumber:
+			column.Type = parquet.Double
+		case models.FieldTypeBool:
+			column.Type = parquet.Bool
+		}
+		columns = append(columns, column)
+	}
+
+	writer, err := parquet.NewWriter(w, columns)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.EachDocument(dbID, schema.Name, scope, func(doc *models.Document) error {
+		row := []interface{}{doc.ID, doc.CreatedAt, doc.UpdatedAt, string(doc.Visibility)}
+		for _, field := range fields {
+			row = append(row, doc.Data[field])
+		}
+		return writer.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// sortedSchemaFields returns a schema's field names in alphabetical order
+func sortedSchemaFields(schema *models.Schema) []string {
+	fields := make([]string, 0, len(schema.Fields))
+	for name := range schema.Fields {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// formatCSVValue renders a document field value as a CSV cell
+func formatCSVValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
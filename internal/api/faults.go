@@ -0,0 +1,68 @@
+package api
+
+import (
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"jsondrop/internal/config"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// faultMiddleware injects latency, server errors and quota errors at configured rates
+// so client retry and backoff logic can be exercised against a real server
+// Routes are matched relative to basePath, so rules don't depend on where the API is mounted
+func faultMiddleware(faults config.FaultConfig, basePath string, routes chi.Routes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Leave CORS preflight alone so browsers can still reach the API
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Resolve the route pattern before routing so rules can target routes
+			rctx := chi.NewRouteContext()
+			pattern := ""
+			path, mounted := strings.CutPrefix(r.URL.Path, basePath)
+			if mounted && routes.Match(rctx, r.Method, path) {
+				pattern = rctx.RoutePattern()
+			}
+			rule := faults.RuleFor(r.Method, pattern)
+
+			var injected []string
+
+			if rule.Latency > 0 && rand.Float64() < rule.LatencyRate {
+				injected = append(injected, "latency")
+				select {
+				case <-time.After(rule.Latency):
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+			if rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate {
+				injected = append(injected, "error")
+				w.Header().Set("X-Fault-Injected", strings.Join(injected, ","))
+				respondError(w, http.StatusInternalServerError, "Internal Server Error", "Injected fault")
+				return
+			}
+
+			// Quota errors only make sense for writes
+			isWrite := r.Method == http.MethodPost || r.Method == http.MethodPut
+			if isWrite && rule.QuotaRate > 0 && rand.Float64() < rule.QuotaRate {
+				injected = append(injected, "quota")
+				w.Header().Set("X-Fault-Injected", strings.Join(injected, ","))
+				respondError(w, http.StatusPaymentRequired, "Quota Exceeded", "quota exceeded: injected fault")
+				return
+			}
+
+			if len(injected) > 0 {
+				w.Header().Set("X-Fault-Injected", strings.Join(injected, ","))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
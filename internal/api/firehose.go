@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"jsondrop/internal/events"
+)
+
+// StreamAllEvents handles GET /api/admin/events (SSE)
+// Streams the events of every database, each with its database_id, for operators'
+// monitoring and replication tooling. It takes the parameters of database streams, but
+// not ?ack=, which is acknowledged per database, or ?collections=
+func (h *Handler) StreamAllEvents(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseStreamOptions(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	if opts.ack {
+		respondError(w, http.StatusBadRequest, "Bad Request", "invalid ack parameter: the admin stream isn't acknowledged")
+		return
+	}
+	if r.URL.Query().Get("collections") != "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "invalid collections parameter: the admin stream follows every collection")
+		return
+	}
+
+	// Set SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable buffering in nginx
+
+	// Subscribe to every database's events, with those missed since Last-Event-ID
+	listener, missed, complete := h.broadcaster.SubscribeFirehose(opts.subscribe)
+	defer h.broadcaster.UnsubscribeFirehose(listener)
+	opts.resumed = complete
+
+	sw := events.NewWriter(w, sseWriteTimeout)
+	if err := sw.WriteFrame(connectedFrame("", "", listener, opts)); err != nil {
+		return
+	}
+
+	h.streamEvents(r, sw, listener, opts, missed, r.Context().Done())
+}
@@ -0,0 +1,482 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"jsondrop/internal/clientaddr"
+	"jsondrop/internal/events"
+	"jsondrop/internal/grpc/jsondropv1"
+	"jsondrop/internal/i18n"
+	"jsondrop/internal/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // Accept gzip-compressed messages
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// NewGRPCServer creates the gRPC server for document reads and writes and event
+// streams, sharing the HTTP handler's store, broadcaster and usage counters
+// Calls authenticate with the database's keys in the authorization metadata, as
+// "Bearer <key>"; writes need the write key
+func NewGRPCServer(h *Handler) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			resp, err := handler(ctx, req)
+			return resp, grpcInternal(err)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return grpcInternal(handler(srv, ss))
+		}),
+	)
+	jsondropv1.RegisterDocumentsServer(srv, &grpcServer{h: h})
+	return srv
+}
+
+// grpcInternal ends calls failing with an error that isn't a status as Internal
+func grpcInternal(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// grpcServer serves the Documents service of proto/jsondrop/v1/documents.proto
+type grpcServer struct {
+	jsondropv1.UnimplementedDocumentsServer
+	h *Handler
+}
+
+// grpcRequest returns an HTTP request standing for a call, so the call is
+// authenticated, scoped, counted and audited by the code HTTP requests go through
+// Its headers are the call's metadata and its remote address the client's
+func grpcRequest(ctx context.Context) *http.Request {
+	method, _ := grpc.Method(ctx)
+	r := &http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: method},
+		Proto:  "HTTP/2.0",
+		Header: http.Header{},
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		if strings.HasPrefix(key, ":") {
+			continue
+		}
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r.WithContext(ctx)
+}
+
+// grpcAuthenticate checks the call's key against the database it names and counts the
+// call, and returns the request with the context HTTP requests get from the key
+// middleware
+func (h *Handler) grpcAuthenticate(r *http.Request, dbID string, write bool) (*http.Request, *models.Database, error) {
+	if dbID == "" {
+		return r, nil, status.Errorf(codes.InvalidArgument, "Database ID is required")
+	}
+	apiKey := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if apiKey == "" {
+		return r, nil, status.Errorf(codes.Unauthenticated, "Missing API key")
+	}
+	addr, _ := clientaddr.FromRequest(r, h.cfg.TrustedProxies)
+	if wait := h.lockout.Wait(addr, time.Now()); wait > 0 {
+		return r, nil, status.Errorf(codes.ResourceExhausted, "Too many failed authentication attempts, try again in %d seconds", int(math.Ceil(wait.Seconds())))
+	}
+
+	var db *models.Database
+	var err error
+	isWrite := strings.HasPrefix(apiKey, "wk_")
+	switch {
+	case isWrite:
+		db, err = h.store.GetDatabaseByWriteKey(apiKey)
+	case strings.HasPrefix(apiKey, "rk_"):
+		db, err = h.store.GetDatabaseByReadKey(apiKey)
+	default:
+		h.lockout.Fail(addr, time.Now())
+		return r, nil, status.Errorf(codes.Unauthenticated, "Invalid API key format")
+	}
+	if err != nil {
+		return r, nil, status.Errorf(codes.Internal, "Failed to authenticate")
+	}
+	if db == nil {
+		h.lockout.Fail(addr, time.Now())
+		return r, nil, status.Errorf(codes.Unauthenticated, "Invalid API key")
+	}
+	if dbID != db.ID {
+		return r, nil, status.Errorf(codes.PermissionDenied, "Database ID mismatch")
+	}
+	if !networkAllowed(r, db, h.cfg.TrustedProxies) {
+		return r, nil, status.Errorf(codes.PermissionDenied, "Requests from this address are not allowed for this database")
+	}
+	if write && !isWrite {
+		return r, nil, status.Errorf(codes.PermissionDenied, "Write key required")
+	}
+
+	h.store.UpdateLastAccessed(db.ID)
+
+	ctx := context.WithValue(r.Context(), contextKeyDatabase, db)
+	ctx = context.WithValue(ctx, contextKeyIsWrite, isWrite)
+	ctx = context.WithValue(ctx, contextKeyAuthenticated, true)
+	ctx = context.WithValue(ctx, contextKeyCredential, databaseKeyCredential(apiKey, isWrite))
+	r = r.WithContext(ctx)
+	h.usage.AddRequest(db.ID, keyTypeFromContext(r))
+	return r, db, nil
+}
+
+// grpcSchema returns a collection's schema, or NotFound
+func (h *Handler) grpcSchema(dbID string, collection string) (*models.Schema, error) {
+	schema, err := h.store.GetSchema(dbID, collection)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to get schema")
+	}
+	if schema == nil {
+		return nil, status.Errorf(codes.NotFound, "Schema does not exist for collection: %s", collection)
+	}
+	return schema, nil
+}
+
+// requireCollection fails document requests naming no collection
+func requireCollection(collection string) error {
+	if collection == "" {
+		return status.Errorf(codes.InvalidArgument, "Collection name is required")
+	}
+	return nil
+}
+
+// GetDocument serves GetDocument, with the read scope of the call's key
+func (s *grpcServer) GetDocument(ctx context.Context, req *jsondropv1.GetDocumentRequest) (*jsondropv1.Document, error) {
+	h := s.h
+	if err := requireCollection(req.GetCollection()); err != nil {
+		return nil, err
+	}
+	r, db, err := h.grpcAuthenticate(grpcRequest(ctx), req.GetDatabaseId(), false)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetDocumentId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Document ID is required")
+	}
+	schema, err := h.grpcSchema(db.ID, req.GetCollection())
+	if err != nil {
+		return nil, err
+	}
+	scope, err := readScopeFromContext(r, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	// Documents the caller may not see are reported as missing
+	doc, err := h.store.GetDocument(db.ID, req.GetCollection(), req.GetDocumentId(), scope)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, status.Errorf(codes.NotFound, "document not found")
+	}
+	stripMasked(r, schema, []*models.Document{doc})
+	return encodeDocument(doc), nil
+}
+
+// InsertDocument serves InsertDocument
+func (s *grpcServer) InsertDocument(ctx context.Context, req *jsondropv1.InsertDocumentRequest) (_ *jsondropv1.Document, err error) {
+	h := s.h
+	r := grpcRequest(ctx)
+	defer func() { h.grpcAudit(r, req.GetCollection(), "", err) }()
+
+	r, db, data, err := h.checkDocumentWrite(r, req.GetDatabaseId(), req.GetCollection(), req.GetDataJson(), models.Visibility(req.GetVisibility()))
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := h.store.InsertDocument(db.ID, req.GetCollection(), data, models.Visibility(req.GetVisibility()))
+	if err != nil {
+		return nil, grpcWriteError(err)
+	}
+	h.usage.AddBytesWritten(db.ID, keyTypeFromContext(r), int64(len(req.GetDataJson())))
+	return encodeDocument(doc), nil
+}
+
+// UpdateDocument serves UpdateDocument
+func (s *grpcServer) UpdateDocument(ctx context.Context, req *jsondropv1.UpdateDocumentRequest) (_ *jsondropv1.Document, err error) {
+	h := s.h
+	r := grpcRequest(ctx)
+	defer func() { h.grpcAudit(r, req.GetCollection(), req.GetDocumentId(), err) }()
+
+	r, db, data, err := h.checkDocumentWrite(r, req.GetDatabaseId(), req.GetCollection(), req.GetDataJson(), models.Visibility(req.GetVisibility()))
+	if err != nil {
+		return nil, err
+	}
+	if req.GetDocumentId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Document ID is required")
+	}
+	if err := h.checkRevision(req.GetRevision()); err != nil {
+		return nil, err
+	}
+
+	doc, err := h.store.UpdateDocument(db.ID, req.GetCollection(), req.GetDocumentId(), data, models.Visibility(req.GetVisibility()), req.GetRevision())
+	if err != nil {
+		return nil, grpcWriteError(err)
+	}
+	h.usage.AddBytesWritten(db.ID, keyTypeFromContext(r), int64(len(req.GetDataJson())))
+	return encodeDocument(doc), nil
+}
+
+// DeleteDocument serves DeleteDocument
+func (s *grpcServer) DeleteDocument(ctx context.Context, req *jsondropv1.DeleteDocumentRequest) (_ *jsondropv1.DeleteDocumentResponse, err error) {
+	h := s.h
+	r := grpcRequest(ctx)
+	defer func() { h.grpcAudit(r, req.GetCollection(), req.GetDocumentId(), err) }()
+
+	if err := requireCollection(req.GetCollection()); err != nil {
+		return nil, err
+	}
+	r, db, err := h.grpcAuthenticate(r, req.GetDatabaseId(), true)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetDocumentId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Document ID is required")
+	}
+	if err := h.checkMirror(db.ID, req.GetCollection()); err != nil {
+		return nil, err
+	}
+	if err := h.checkRevision(req.GetRevision()); err != nil {
+		return nil, err
+	}
+
+	if err := h.store.DeleteDocument(db.ID, req.GetCollection(), req.GetDocumentId(), req.GetRevision()); err != nil {
+		return nil, grpcWriteError(err)
+	}
+	return &jsondropv1.DeleteDocumentResponse{}, nil
+}
+
+// checkDocumentWrite checks an insert or update as the HTTP handlers do: the key, the
+// mirror, the document size, the schema and the visibility
+func (h *Handler) checkDocumentWrite(r *http.Request, dbID string, collection string, dataJSON string, visibility models.Visibility) (*http.Request, *models.Database, map[string]interface{}, error) {
+	if err := requireCollection(collection); err != nil {
+		return r, nil, nil, err
+	}
+	r, db, err := h.grpcAuthenticate(r, dbID, true)
+	if err != nil {
+		return r, nil, nil, err
+	}
+	if err := h.checkMirror(db.ID, collection); err != nil {
+		return r, nil, nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
+		return r, nil, nil, status.Errorf(codes.InvalidArgument, "Invalid data_json: expected a JSON object")
+	}
+	if len(data) == 0 {
+		return r, nil, nil, status.Errorf(codes.InvalidArgument, "Document data cannot be empty")
+	}
+	if err := models.CheckDocumentSize(data, h.cfg.MaxDocumentBytes); err != nil {
+		var tooLarge *models.DocumentTooLargeError
+		if errors.As(err, &tooLarge) {
+			return r, nil, nil, status.Errorf(codes.ResourceExhausted, "Document of %d bytes exceeds the limit of %d", tooLarge.Size, tooLarge.Limit)
+		}
+		return r, nil, nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	schema, err := h.grpcSchema(db.ID, collection)
+	if err != nil {
+		return r, nil, nil, err
+	}
+	if err := models.ValidateDocument(data, schema); err != nil {
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			lang := i18n.Negotiate(r.Header.Get("Accept-Language"))
+			return r, nil, nil, status.Errorf(codes.InvalidArgument, "%s", i18n.ValidationMessage(lang, validationErr))
+		}
+		return r, nil, nil, status.Errorf(codes.InvalidArgument, "Validation failed: %v", err)
+	}
+	if visibility != "" && !visibility.IsValid() {
+		return r, nil, nil, status.Errorf(codes.InvalidArgument, "Invalid visibility: %s", visibility)
+	}
+	return r, db, data, nil
+}
+
+// checkMirror fails writes to collections written by a mirror
+func (h *Handler) checkMirror(dbID string, collection string) error {
+	readOnly, err := h.isMirrorTarget(dbID, collection)
+	if err != nil {
+		return err
+	}
+	if readOnly {
+		return status.Errorf(codes.FailedPrecondition, "Collection is a read-only mirror: %s", collection)
+	}
+	return nil
+}
+
+// checkRevision applies REQUIRE_IF_MATCH to a write's revision
+func (h *Handler) checkRevision(revision int64) error {
+	if revision < 0 {
+		return status.Errorf(codes.InvalidArgument, "Invalid revision: %d", revision)
+	}
+	if revision == 0 && h.cfg.RequireIfMatch {
+		return status.Errorf(codes.FailedPrecondition, "The document's revision is required")
+	}
+	return nil
+}
+
+// grpcWriteError maps a store error from a write to a status, as the HTTP handlers do
+func grpcWriteError(err error) error {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return status.Errorf(codes.NotFound, "%v", err)
+	case strings.Contains(err.Error(), "revision mismatch"):
+		return status.Errorf(codes.FailedPrecondition, "%v", err)
+	case strings.Contains(err.Error(), "quota exceeded"):
+		return status.Errorf(codes.ResourceExhausted, "%v", err)
+	}
+	return err
+}
+
+// Watch serves Watch, streaming a database's or collection's events as the SSE and
+// WebSocket streams do. The first message is a connected event, and dropped and
+// resync notices arrive as events of their type, with the stream's JSON in data_json
+func (s *grpcServer) Watch(req *jsondropv1.WatchRequest, stream grpc.ServerStreamingServer[jsondropv1.ChangeEvent]) error {
+	h := s.h
+	var opts streamOptions
+	var err error
+	opts.subscribe.LastID = req.GetLastEventId()
+	if ms := req.GetBatchMs(); ms > 0 {
+		if opts.batch, err = events.ParseBatch(strconv.Itoa(int(ms)) + "ms"); err != nil {
+			return status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	}
+	if opts.subscribe.Types, err = events.ParseTypes(strings.Join(req.GetEventTypes(), ",")); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	r, db, err := h.grpcAuthenticate(grpcRequest(stream.Context()), req.GetDatabaseId(), false)
+	if err != nil {
+		return err
+	}
+	opts.subscribe.Redact = h.eventRedactor(r, db.ID)
+
+	collection := req.GetCollection()
+	var listener *events.Listener
+	var missed []models.ChangeEvent
+	if collection == "" {
+		listener, missed, opts.resumed = h.broadcaster.SubscribeWith(db.ID, opts.subscribe)
+		defer h.broadcaster.Unsubscribe(db.ID, listener)
+	} else {
+		if _, err := h.grpcSchema(db.ID, collection); err != nil {
+			return err
+		}
+		listener, missed, opts.resumed = h.broadcaster.SubscribeCollectionWith(db.ID, collection, opts.subscribe)
+		defer h.broadcaster.UnsubscribeCollection(db.ID, collection, listener)
+	}
+
+	sw := grpcEventWriter{stream: stream}
+	if err := sw.WriteNotice("connected", connectedData(db.ID, collection, listener, opts)); err != nil {
+		return err
+	}
+	h.streamEvents(r, sw, listener, opts, missed, stream.Context().Done())
+	return nil
+}
+
+// grpcEventWriter sends change events to a Watch call
+// Event format versions don't apply: events are always ChangeEvent messages
+type grpcEventWriter struct {
+	stream grpc.ServerStreamingServer[jsondropv1.ChangeEvent]
+}
+
+// WriteEvent sends a change event
+func (sw grpcEventWriter) WriteEvent(event models.ChangeEvent, version int) error {
+	return sw.stream.Send(encodeChangeEvent(event))
+}
+
+// WriteBatch sends events one after another
+func (sw grpcEventWriter) WriteBatch(batch []models.ChangeEvent, version int) error {
+	for _, event := range batch {
+		if err := sw.stream.Send(encodeChangeEvent(event)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteNotice sends an event of another type, with its data as data_json
+func (sw grpcEventWriter) WriteNotice(event string, data []byte) error {
+	return sw.stream.Send(&jsondropv1.ChangeEvent{EventType: event, DataJson: string(data)})
+}
+
+// WritePing does nothing: HTTP/2 keeps the connection alive with its own pings
+func (sw grpcEventWriter) WritePing() error {
+	return nil
+}
+
+// encodeDocument returns a document as a Document message
+func encodeDocument(doc *models.Document) *jsondropv1.Document {
+	return &jsondropv1.Document{
+		Id:         doc.ID,
+		Collection: doc.Collection,
+		DataJson:   marshalData(doc.Data),
+		Visibility: string(doc.Visibility),
+		Revision:   doc.Revision,
+		CreatedAt:  encodeTimestamp(doc.CreatedAt),
+		UpdatedAt:  encodeTimestamp(doc.UpdatedAt),
+	}
+}
+
+// encodeChangeEvent returns an event as a ChangeEvent message
+func encodeChangeEvent(event models.ChangeEvent) *jsondropv1.ChangeEvent {
+	msg := &jsondropv1.ChangeEvent{
+		Id:          event.ID,
+		EventType:   event.EventType,
+		DatabaseId:  event.DatabaseID,
+		Collection:  event.Collection,
+		DocumentId:  event.DocumentID,
+		DataJson:    marshalData(event.Data),
+		OldDataJson: marshalData(event.OldData),
+		Timestamp:   encodeTimestamp(event.Timestamp),
+		Seq:         event.Seq,
+	}
+	if len(event.Counts) > 0 {
+		msg.Counts = make(map[string]int64, len(event.Counts))
+		for collection, count := range event.Counts {
+			msg.Counts[collection] = int64(count)
+		}
+	}
+	return msg
+}
+
+// encodeTimestamp returns a time as a Timestamp message, or nil for the zero time
+func encodeTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// marshalData returns document data as JSON, or "" for none
+func marshalData(data map[string]interface{}) string {
+	if data == nil {
+		return ""
+	}
+	dataJSON, _ := json.Marshal(data)
+	return string(dataJSON)
+}
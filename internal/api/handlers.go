@@ -1,38 +1,131 @@
 package api
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"jsondrop/internal/clientaddr"
+	"jsondrop/internal/config"
 	"jsondrop/internal/database"
 	"jsondrop/internal/events"
+	"jsondrop/internal/i18n"
+	"jsondrop/internal/lockout"
+	"jsondrop/internal/mirror"
 	"jsondrop/internal/models"
+	"jsondrop/internal/policy"
+	"jsondrop/internal/ratelimit"
+	"jsondrop/internal/signing"
+	"jsondrop/internal/token"
+	"jsondrop/internal/usage"
+	"jsondrop/internal/webhook"
 
 	"github.com/go-chi/chi/v5"
 )
 
 // Handler holds dependencies for API handlers
 type Handler struct {
-	catalog     *database.CatalogDB
+	store       database.Store
+	catalog     *database.CatalogDB // nil unless the store is SQLite
 	broadcaster *events.Broadcaster
+	cfg         *config.Config
+	usage       *usage.Tracker
+	mirrors     *mirror.Replicator  // nil unless the store is SQLite
+	webhooks    *webhook.Dispatcher // nil unless the store is SQLite
+	tokens      *token.Issuer
+	signatures  *signing.Verifier
+	lockout     *lockout.Guard // nil when lockout is disabled
+
+	creations       *ratelimit.Limiter // nil when database creation isn't rate limited
+	creating        sync.Mutex         // Held while checking MAX_DATABASES and creating a database
+	spentChallenges spentChallenges
 }
 
 // NewHandler creates a new API handler
-func NewHandler(catalog *database.CatalogDB, broadcaster *events.Broadcaster) *Handler {
-	return &Handler{
+// Endpoints that need SQLite-only features respond 501 with other stores
+func NewHandler(store database.Store, broadcaster *events.Broadcaster, cfg *config.Config, tokens *token.Issuer) *Handler {
+	catalog, _ := store.(*database.CatalogDB)
+	h := &Handler{
+		store:       store,
 		catalog:     catalog,
 		broadcaster: broadcaster,
+		cfg:         cfg,
+		usage:       usage.NewTracker(),
+		tokens:      tokens,
+		signatures:  signing.NewVerifier(),
 	}
+	if cfg.Lockout.Enabled() {
+		h.lockout = lockout.New(cfg.Lockout.Threshold, cfg.Lockout.MaxDelay)
+	}
+	if cfg.Creation.RateLimit > 0 {
+		h.creations = ratelimit.New(cfg.Creation.RateLimit, cfg.Creation.RateWindow)
+	}
+	if catalog != nil {
+		h.mirrors = mirror.NewReplicator(catalog)
+		broadcaster.Observe(h.mirrors.Observe)
+		h.webhooks = webhook.NewDispatcher(catalog)
+		broadcaster.Observe(h.webhooks.Observe)
+	}
+	return h
+}
+
+// Lockout returns the guard counting failed authentications, for the web console to
+// share; nil when lockout is disabled
+func (h *Handler) Lockout() *lockout.Guard {
+	return h.lockout
+}
+
+// requireCatalog responds 501 and returns false when a feature needs the SQLite store
+func (h *Handler) requireCatalog(w http.ResponseWriter, feature string) bool {
+	if h.catalog == nil {
+		respondError(w, http.StatusNotImplemented, "Not Implemented", feature+" requires the sqlite storage backend")
+		return false
+	}
+	return true
+}
+
+// sqliteOnly is middleware for endpoints that need the SQLite store
+func (h *Handler) sqliteOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.requireCatalog(w, "This endpoint") {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 // CreateDatabase handles POST /api/databases
 func (h *Handler) CreateDatabase(w http.ResponseWriter, r *http.Request) {
-	resp, err := h.catalog.CreateDatabase()
+	// Creation needs no key, so each address may only create so many databases, after
+	// passing the challenge when one is configured
+	// The creation is counted up front, so concurrent requests can't all get past the
+	// limit, and given back when no database is created
+	addr, _ := clientaddr.FromRequest(r, h.cfg.TrustedProxies)
+	reserved := time.Now()
+	if wait := h.creations.Reserve(addr, reserved); wait > 0 {
+		respondCreationLimited(w, h.cfg.Creation, wait)
+		return
+	}
+	if !h.passCreationChallenge(w, r, addr) {
+		h.creations.Release(addr, reserved)
+		return
+	}
+
+	resp, err := h.createDatabase()
 	if err != nil {
+		h.creations.Release(addr, reserved)
+		if strings.Contains(err.Error(), "limit exceeded") {
+			respondError(w, http.StatusServiceUnavailable, "Service Unavailable", err.Error())
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Failed to create database", err.Error())
 		return
 	}
@@ -40,6 +133,24 @@ func (h *Handler) CreateDatabase(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusCreated, resp)
 }
 
+// createDatabase creates a database unless the server holds MAX_DATABASES already
+func (h *Handler) createDatabase() (*models.CreateDatabaseResponse, error) {
+	if h.cfg.Creation.MaxDatabases == 0 {
+		return h.store.CreateDatabase()
+	}
+	h.creating.Lock()
+	defer h.creating.Unlock()
+
+	count, err := h.store.CountDatabases()
+	if err != nil {
+		return nil, err
+	}
+	if count >= h.cfg.Creation.MaxDatabases {
+		return nil, fmt.Errorf("database limit exceeded: the server holds at most %d databases", h.cfg.Creation.MaxDatabases)
+	}
+	return h.store.CreateDatabase()
+}
+
 // CreateSchema handles POST /api/databases/:id/schemas/:name
 func (h *Handler) CreateSchema(w http.ResponseWriter, r *http.Request) {
 	db := getDatabaseFromContext(r)
@@ -56,8 +167,7 @@ func (h *Handler) CreateSchema(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req models.CreateSchemaRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid JSON body")
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
 
@@ -78,8 +188,25 @@ func (h *Handler) CreateSchema(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if req.ReadFilter != "" {
+		if _, err := policy.Parse(req.ReadFilter); err != nil {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid read filter: "+err.Error())
+			return
+		}
+	}
+
+	// Write-only fields are masked, which only the catalog records
+	writeOnly, err := database.WriteOnlyFields(req.FieldVisibility)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	if len(writeOnly) > 0 && !h.requireCatalog(w, "Write-only fields") {
+		return
+	}
+
 	// Check if schema already exists
-	existingSchema, err := h.catalog.GetSchema(db.ID, schemaName)
+	existingSchema, err := h.store.GetSchema(db.ID, schemaName)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to check existing schema")
 		return
@@ -90,15 +217,150 @@ func (h *Handler) CreateSchema(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create schema
-	schema, err := h.catalog.CreateSchema(db.ID, schemaName, req.Fields)
+	schema, err := h.store.CreateSchema(db.ID, schemaName, req.Fields, writeOnly)
 	if err != nil {
+		if strings.Contains(err.Error(), "limit exceeded") {
+			respondError(w, http.StatusUnprocessableEntity, "Limit Exceeded", err.Error())
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
 		return
 	}
 
+	if req.ReadFilter != "" {
+		schema, err = h.store.SetReadFilter(db.ID, schemaName, req.ReadFilter)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+			return
+		}
+	}
+
 	respondJSON(w, http.StatusCreated, schema)
 }
 
+// SetReadFilter handles PUT /api/databases/:id/schemas/:name/policy
+func (h *Handler) SetReadFilter(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	schemaName := chi.URLParam(r, "name")
+	if schemaName == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Schema name is required")
+		return
+	}
+
+	// Parse request body
+	var req models.SetReadFilterRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	// Validate the expression before storing it
+	if req.ReadFilter != "" {
+		if _, err := policy.Parse(req.ReadFilter); err != nil {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid read filter: "+err.Error())
+			return
+		}
+	}
+
+	schema, err := h.store.SetReadFilter(db.ID, schemaName, req.ReadFilter)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, schema)
+}
+
+// UpdateSchema handles PUT /api/databases/:id/schemas/:name
+func (h *Handler) UpdateSchema(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	schemaName := chi.URLParam(r, "name")
+	if schemaName == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Schema name is required")
+		return
+	}
+
+	// Parse request body
+	var req models.UpdateSchemaRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	schema, err := h.catalog.UpdateSchema(db.ID, schemaName, &req)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "schema not found"):
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+		case strings.Contains(err.Error(), "limit exceeded"):
+			respondError(w, http.StatusUnprocessableEntity, "Limit Exceeded", err.Error())
+		case strings.Contains(err.Error(), "quota exceeded"):
+			respondError(w, http.StatusPaymentRequired, "Quota Exceeded", err.Error())
+		case strings.HasPrefix(err.Error(), "invalid") || strings.Contains(err.Error(), "at least one field"):
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, schema)
+}
+
+// RenameSchema handles POST /api/databases/:id/schemas/:name/rename
+func (h *Handler) RenameSchema(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	schemaName := chi.URLParam(r, "name")
+	if schemaName == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Schema name is required")
+		return
+	}
+
+	// Parse request body
+	var req models.RenameSchemaRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.NewName == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "New name is required")
+		return
+	}
+
+	schema, err := h.catalog.RenameSchema(db.ID, schemaName, req.NewName)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "schema not found"):
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+		case strings.Contains(err.Error(), "already exists"):
+			respondError(w, http.StatusConflict, "Conflict", err.Error())
+		case strings.HasPrefix(err.Error(), "invalid schema name"):
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, schema)
+}
+
 // InsertDocument handles POST /api/databases/:id/:collection
 func (h *Handler) InsertDocument(w http.ResponseWriter, r *http.Request) {
 	db := getDatabaseFromContext(r)
@@ -115,8 +377,7 @@ func (h *Handler) InsertDocument(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req models.InsertDocumentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid JSON body")
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
 
@@ -124,9 +385,12 @@ func (h *Handler) InsertDocument(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "Bad Request", "Document data cannot be empty")
 		return
 	}
+	if !h.checkDocumentSize(w, req.Data) {
+		return
+	}
 
 	// Get schema for validation
-	schema, err := h.catalog.GetSchema(db.ID, collection)
+	schema, err := h.store.GetSchema(db.ID, collection)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to get schema")
 		return
@@ -138,12 +402,18 @@ func (h *Handler) InsertDocument(w http.ResponseWriter, r *http.Request) {
 
 	// Validate document against schema
 	if err := models.ValidateDocument(req.Data, schema); err != nil {
-		respondError(w, http.StatusBadRequest, "Bad Request", "Validation failed: "+err.Error())
+		respondValidationError(w, r, err)
+		return
+	}
+	warnings := deprecationWarnings(schema, req.Data)
+
+	if req.Visibility != "" && !req.Visibility.IsValid() {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid visibility: "+string(req.Visibility))
 		return
 	}
 
 	// Insert document
-	doc, err := h.catalog.InsertDocument(db.ID, collection, req.Data)
+	doc, err := h.store.InsertDocument(db.ID, collection, req.Data, req.Visibility)
 	if err != nil {
 		// Check if it's a quota error
 		if strings.Contains(err.Error(), "quota exceeded") {
@@ -154,10 +424,14 @@ func (h *Handler) InsertDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	addWarnings(w, warnings)
+	setDocumentETag(w, doc)
 	respondJSON(w, http.StatusCreated, doc)
 }
 
 // StreamDatabaseEvents handles GET /api/databases/:id/events (SSE)
+// ?v= selects the event format version; ?ack=true makes the client acknowledge it is alive;
+// ?collections= follows only some collections
 func (h *Handler) StreamDatabaseEvents(w http.ResponseWriter, r *http.Request) {
 	db := getDatabaseFromContext(r)
 	if db == nil {
@@ -165,57 +439,42 @@ func (h *Handler) StreamDatabaseEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	opts, err := parseStreamOptions(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	if !h.streamCollections(w, r, db.ID, &opts) {
+		return
+	}
+	opts.subscribe.Redact = h.eventRedactor(r, db.ID)
+
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no") // Disable buffering in nginx
 
-	// Subscribe to events
-	listener := h.broadcaster.Subscribe(db.ID)
+	// Subscribe to events, with those missed since Last-Event-ID
+	listener, missed, complete := h.broadcaster.SubscribeWith(db.ID, opts.subscribe)
 	defer h.broadcaster.Unsubscribe(db.ID, listener)
+	opts.resumed = complete
+	if opts.ack {
+		h.broadcaster.RequireAck(listener)
+	}
 
 	// Send initial connection message
-	fmt.Fprintf(w, "event: connected\ndata: {\"database_id\":\"%s\",\"timestamp\":\"%s\"}\n\n",
-		db.ID, time.Now().Format(time.RFC3339))
-	if f, ok := w.(http.Flusher); ok {
-		f.Flush()
+	sw := events.NewWriter(w, sseWriteTimeout)
+	if err := sw.WriteFrame(connectedFrame(db.ID, "", listener, opts)); err != nil {
+		return
 	}
 
-	// Heartbeat ticker
-	ticker := time.NewTicker(15 * time.Second)
-	defer ticker.Stop()
-
-	// Stream events
-	for {
-		select {
-		case event := <-listener.Events:
-			// Send event to client
-			fmt.Fprint(w, events.FormatSSE(event))
-			if f, ok := w.(http.Flusher); ok {
-				f.Flush()
-			}
-
-		case <-ticker.C:
-			// Send heartbeat/ping
-			fmt.Fprint(w, events.FormatPing())
-			if f, ok := w.(http.Flusher); ok {
-				f.Flush()
-			}
-			h.broadcaster.UpdatePing(listener)
-
-		case <-listener.Done:
-			// Listener was closed by broadcaster
-			return
-
-		case <-r.Context().Done():
-			// Client disconnected
-			return
-		}
-	}
+	h.streamEvents(r, sw, listener, opts, missed, r.Context().Done())
 }
 
 // StreamCollectionEvents handles GET /api/databases/:id/:collection/events (SSE)
+// ?v= selects the event format version; ?ack=true makes the client acknowledge it is alive.
+// Field filters, as when querying documents, narrow the document events sent
 func (h *Handler) StreamCollectionEvents(w http.ResponseWriter, r *http.Request) {
 	db := getDatabaseFromContext(r)
 	if db == nil {
@@ -230,7 +489,7 @@ func (h *Handler) StreamCollectionEvents(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Verify schema exists for this collection
-	schema, err := h.catalog.GetSchema(db.ID, collection)
+	schema, err := h.store.GetSchema(db.ID, collection)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
 		return
@@ -240,42 +499,353 @@ func (h *Handler) StreamCollectionEvents(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	opts, err := parseStreamOptions(r)
+	if err == nil {
+		err = opts.filterDocuments(r, schema)
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	opts.subscribe.Redact = h.eventRedactor(r, db.ID)
+
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no") // Disable buffering in nginx
 
-	// Subscribe to collection-specific events
-	listener := h.broadcaster.SubscribeCollection(db.ID, collection)
+	// Subscribe to collection-specific events, with those missed since Last-Event-ID
+	listener, missed, complete := h.broadcaster.SubscribeCollectionWith(db.ID, collection, opts.subscribe)
 	defer h.broadcaster.UnsubscribeCollection(db.ID, collection, listener)
+	opts.resumed = complete
+	if opts.ack {
+		h.broadcaster.RequireAck(listener)
+	}
 
 	// Send initial connection message
-	fmt.Fprintf(w, "event: connected\ndata: {\"database_id\":\"%s\",\"collection\":\"%s\",\"timestamp\":\"%s\"}\n\n",
-		db.ID, collection, time.Now().Format(time.RFC3339))
-	if f, ok := w.(http.Flusher); ok {
-		f.Flush()
+	sw := events.NewWriter(w, sseWriteTimeout)
+	if err := sw.WriteFrame(connectedFrame(db.ID, collection, listener, opts)); err != nil {
+		return
+	}
+
+	h.streamEvents(r, sw, listener, opts, missed, r.Context().Done())
+}
+
+// sseWriteTimeout bounds how long a single SSE frame may take to reach the client
+const sseWriteTimeout = 10 * time.Second
+
+// streamOptions are the subscription parameters of an event stream
+type streamOptions struct {
+	version   int                     // Event format version (?v=)
+	ack       bool                    // The client acknowledges it is alive (?ack=true)
+	subscribe events.SubscribeOptions // Event types (?events=) and the event to resume after (Last-Event-ID or ?last_event_id=)
+	resumed   bool                    // Every event since the last event ID was replayed
+	filters   []database.Filter       // Field filters documents must match (collection streams only)
+	batch     time.Duration           // Window events are held for and sent together (?batch=); 0 sends each at once
+	heartbeat time.Duration           // Interval between heartbeats (?heartbeat=); 0 selects the default
+	patch     events.PatchMode        // Whether update events carry a JSON Patch (?patch=)
+}
+
+// streamParams are the query parameters of event streams, besides field filters
+var streamParams = []string{"v", "ack", "events", "last_event_id", "batch", "heartbeat", "collections", "patch", "key"}
+
+// filterDocuments parses a collection stream's field filters, so document events are
+// only sent for documents matching them before or after the change
+// Fields the request may not read can't be filtered on
+func (opts *streamOptions) filterDocuments(r *http.Request, schema *models.Schema) error {
+	filters, err := schemaFilters(r.URL.Query(), readableSchema(r, schema), streamParams...)
+	if err != nil || len(filters) == 0 {
+		return err
+	}
+	opts.filters = filters
+	opts.subscribe.Match = func(data map[string]interface{}) bool {
+		return database.MatchFilters(data, filters)
+	}
+	return nil
+}
+
+// MaxStreamCollections bounds the collections one database stream can follow with ?collections=
+const MaxStreamCollections = 50
+
+// streamCollections narrows a database stream to the comma-separated collections of
+// ?collections=, so one connection can follow several; each must exist. Keys restricted
+// to some collections follow only those, all of them by default
+// Responds with an error and returns false when they don't
+func (h *Handler) streamCollections(w http.ResponseWriter, r *http.Request, dbID string, opts *streamOptions) bool {
+	value := r.URL.Query().Get("collections")
+	if value == "" {
+		opts.subscribe.Collections = keyCollectionsFromContext(r)
+		return true
+	}
+	var collections []string
+	for _, collection := range strings.Split(value, ",") {
+		if collection = strings.TrimSpace(collection); collection != "" && !slices.Contains(collections, collection) {
+			collections = append(collections, collection)
+		}
+	}
+	if len(collections) == 0 || len(collections) > MaxStreamCollections {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("invalid collections: expected 1 to %d comma-separated collection names", MaxStreamCollections))
+		return false
+	}
+
+	for _, collection := range collections {
+		if !keyAllowsCollection(r, collection) {
+			respondError(w, http.StatusForbidden, "Forbidden", "Key may not follow collection: "+collection)
+			return false
+		}
+		schema, err := h.store.GetSchema(dbID, collection)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+			return false
+		}
+		if schema == nil {
+			respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+			return false
+		}
+	}
+	opts.subscribe.Collections = collections
+	return true
+}
+
+// parseStreamOptions reads the subscription parameters of an event stream
+func parseStreamOptions(r *http.Request) (streamOptions, error) {
+	var opts streamOptions
+
+	version, err := events.ParseVersion(r.URL.Query().Get("v"))
+	if err != nil {
+		return opts, err
+	}
+	opts.version = version
+
+	if ackStr := r.URL.Query().Get("ack"); ackStr != "" {
+		ack, err := strconv.ParseBool(ackStr)
+		if err != nil {
+			return opts, fmt.Errorf("invalid ack parameter: %s", ackStr)
+		}
+		opts.ack = ack
+	}
+
+	// EventSource sends Last-Event-ID when reconnecting; the parameter serves WebSockets
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	if lastID != "" {
+		id, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid last event ID: %s", lastID)
+		}
+		opts.subscribe.LastID = id
+	}
+
+	types, err := events.ParseTypes(r.URL.Query().Get("events"))
+	if err != nil {
+		return opts, err
+	}
+	opts.subscribe.Types = types
+
+	batch, err := events.ParseBatch(r.URL.Query().Get("batch"))
+	if err != nil {
+		return opts, err
+	}
+	opts.batch = batch
+
+	heartbeat, err := events.ParseHeartbeat(r.URL.Query().Get("heartbeat"))
+	if err != nil {
+		return opts, err
+	}
+	opts.heartbeat = heartbeat
+
+	patch, err := events.ParsePatch(r.URL.Query().Get("patch"))
+	if err != nil {
+		return opts, err
+	}
+	opts.patch = patch
+
+	return opts, nil
+}
+
+// connectedFrame formats the event that opens an SSE stream
+func connectedFrame(dbID string, collection string, listener *events.Listener, opts streamOptions) string {
+	return fmt.Sprintf("event: connected\ndata: %s\n\n", connectedData(dbID, collection, listener, opts))
+}
+
+// connectedData is the data of the event that opens a stream
+// It tells the client its listener ID and, if it acknowledges, how often it must
+func connectedData(dbID string, collection string, listener *events.Listener, opts streamOptions) []byte {
+	connected := struct {
+		DatabaseID  string   `json:"database_id,omitempty"` // Empty on the admin stream
+		Collection  string   `json:"collection,omitempty"`
+		ListenerID  string   `json:"listener_id"`
+		V           int      `json:"v"`
+		AckTimeout  int      `json:"ack_timeout,omitempty"`  // Seconds
+		Resumed     *bool    `json:"resumed,omitempty"`      // With a last event ID: false if events were lost
+		Events      []string `json:"events,omitempty"`       // Event types delivered, if not all
+		Collections []string `json:"collections,omitempty"`  // Collections a database stream follows, if not all
+		Filters     []string `json:"filters,omitempty"`      // Fields document events are filtered on
+		BatchMS     int64    `json:"batch_ms,omitempty"`     // Batch window, if batching
+		HeartbeatMS int64    `json:"heartbeat_ms,omitempty"` // Interval between heartbeats
+		Patch       string   `json:"patch,omitempty"`        // Whether update events carry a JSON Patch
+		Timestamp   string   `json:"timestamp"`
+	}{
+		DatabaseID:  dbID,
+		Collection:  collection,
+		ListenerID:  listener.ID,
+		V:           opts.version,
+		Events:      opts.subscribe.Types,
+		Collections: opts.subscribe.Collections,
+		BatchMS:     opts.batch.Milliseconds(),
+		HeartbeatMS: opts.heartbeat.Milliseconds(),
+		Patch:       string(opts.patch),
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+	if opts.ack {
+		connected.AckTimeout = int(events.AckTimeout / time.Second)
+	}
+	if opts.subscribe.LastID != 0 {
+		connected.Resumed = &opts.resumed
+	}
+	for _, filter := range opts.filters {
+		if !slices.Contains(connected.Filters, filter.Field) {
+			connected.Filters = append(connected.Filters, filter.Field)
+		}
+	}
+
+	data, _ := json.Marshal(connected)
+	return data
+}
+
+// AckEvents handles POST /api/databases/:id/events/ack
+// Clients that subscribed with ?ack=true call it to show they are alive; a 404 means the
+// listener was evicted or the stream closed, and the client should reconnect
+func (h *Handler) AckEvents(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.AckEventsRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.ListenerID == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "listener_id is required")
+		return
+	}
+
+	if !h.broadcaster.Ack(db.ID, req.ListenerID) {
+		respondError(w, http.StatusNotFound, "Not Found", "Listener not found: "+req.ListenerID)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// eventWriter sends change events, notices and heartbeats to a stream's client
+type eventWriter interface {
+	WriteEvent(event models.ChangeEvent, version int) error
+	WriteBatch(batch []models.ChangeEvent, version int) error
+	WriteNotice(event string, data []byte) error
+	WritePing() error
+}
+
+// streamEvents sends missed events, then events as they come in an event format version
+// and heartbeats to a client until done is closed, the listener is closed, or a write fails.
+// With a batch window, events are held from the first for the window and sent together
+func (h *Handler) streamEvents(r *http.Request, sw eventWriter, listener *events.Listener, opts streamOptions, missed []models.ChangeEvent, done <-chan struct{}) {
+	// Admin firehose streams follow no one database, and aren't counted as a database's usage
+	var dbID string
+	if db := getDatabaseFromContext(r); db != nil {
+		dbID = db.ID
+	}
+	label := keyTypeFromContext(r)
+
+	// send writes events to the client, after telling it about any it missed
+	send := func(batch []models.ChangeEvent) error {
+		if err := reportMissed(sw, listener); err != nil {
+			return err
+		}
+		if opts.patch != events.PatchNone {
+			patched := make([]models.ChangeEvent, len(batch))
+			for i, event := range batch {
+				patched[i] = opts.patch.Apply(event)
+			}
+			batch = patched
+		}
+		if opts.batch > 0 {
+			if err := sw.WriteBatch(batch, opts.version); err != nil {
+				return err
+			}
+		} else {
+			for _, event := range batch {
+				if err := sw.WriteEvent(event, opts.version); err != nil {
+					return err
+				}
+			}
+		}
+		if dbID != "" {
+			for range batch {
+				h.usage.AddEvent(dbID, label)
+			}
+		}
+		return nil
+	}
+
+	for chunk := range slices.Chunk(missed, events.MaxBatchEvents) {
+		if err := send(chunk); err != nil {
+			return
+		}
 	}
 
 	// Heartbeat ticker
-	ticker := time.NewTicker(15 * time.Second)
+	heartbeat := opts.heartbeat
+	if heartbeat == 0 {
+		heartbeat = events.DefaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
 	defer ticker.Stop()
 
+	// Events held for the batch window, and when it ends; nil while none are held
+	var pending []models.ChangeEvent
+	var flush <-chan time.Time
+
 	// Stream events
 	for {
 		select {
 		case event := <-listener.Events:
-			// Send event to client
-			fmt.Fprint(w, events.FormatSSE(event))
-			if f, ok := w.(http.Flusher); ok {
-				f.Flush()
+			if opts.batch == 0 {
+				if err := send([]models.ChangeEvent{event}); err != nil {
+					return // Client is gone or too slow
+				}
+				continue
+			}
+			pending = append(pending, event)
+			if len(pending) == 1 {
+				flush = time.After(opts.batch)
+			}
+			if len(pending) < events.MaxBatchEvents {
+				continue
 			}
+			if err := send(pending); err != nil {
+				return
+			}
+			pending, flush = nil, nil
+
+		case <-flush:
+			if err := send(pending); err != nil {
+				return
+			}
+			pending, flush = nil, nil
 
 		case <-ticker.C:
 			// Send heartbeat/ping
-			fmt.Fprint(w, events.FormatPing())
-			if f, ok := w.(http.Flusher); ok {
-				f.Flush()
+			if err := reportMissed(sw, listener); err != nil {
+				return
+			}
+			if err := sw.WritePing(); err != nil {
+				return
 			}
 			h.broadcaster.UpdatePing(listener)
 
@@ -283,13 +853,63 @@ func (h *Handler) StreamCollectionEvents(w http.ResponseWriter, r *http.Request)
 			// Listener was closed by broadcaster
 			return
 
-		case <-r.Context().Done():
+		case <-done:
 			// Client disconnected
 			return
 		}
 	}
 }
 
+// reportMissed tells the client about events it didn't get since the last notices
+func reportMissed(sw eventWriter, listener *events.Listener) error {
+	if err := reportDropped(sw, listener); err != nil {
+		return err
+	}
+	return reportThrottled(sw, listener)
+}
+
+// reportDropped sends a dropped notice when events were dropped because the client fell
+// behind, so it can refetch instead of silently diverging
+func reportDropped(sw eventWriter, listener *events.Listener) error {
+	dropped := listener.TakeDropped()
+	if dropped == 0 {
+		return nil
+	}
+	notice := struct {
+		Dropped      uint64 `json:"dropped"`       // Since the last notice
+		TotalDropped uint64 `json:"total_dropped"` // Since the stream opened
+		Timestamp    string `json:"timestamp"`
+	}{
+		Dropped:      dropped,
+		TotalDropped: listener.Dropped(),
+		Timestamp:    time.Now().Format(time.RFC3339),
+	}
+	data, _ := json.Marshal(notice)
+	return sw.WriteNotice("dropped", data)
+}
+
+// reportThrottled sends a resync notice when events were skipped because the stream was
+// over its rate, recommending the client refetch what it shows
+func reportThrottled(sw eventWriter, listener *events.Listener) error {
+	throttled := listener.TakeThrottled()
+	if throttled == 0 {
+		return nil
+	}
+	notice := struct {
+		Reason         string `json:"reason"`
+		Throttled      uint64 `json:"throttled"`       // Since the last notice
+		TotalThrottled uint64 `json:"total_throttled"` // Since the stream opened
+		Timestamp      string `json:"timestamp"`
+	}{
+		Reason:         "rate_limited",
+		Throttled:      throttled,
+		TotalThrottled: listener.Throttled(),
+		Timestamp:      time.Now().Format(time.RFC3339),
+	}
+	data, _ := json.Marshal(notice)
+	return sw.WriteNotice("resync", data)
+}
+
 // QueryDocuments handles GET /api/databases/:id/:collection
 func (h *Handler) QueryDocuments(w http.ResponseWriter, r *http.Request) {
 	db := getDatabaseFromContext(r)
@@ -305,7 +925,7 @@ func (h *Handler) QueryDocuments(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify schema exists for this collection
-	schema, err := h.catalog.GetSchema(db.ID, collection)
+	schema, err := h.store.GetSchema(db.ID, collection)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
 		return
@@ -314,6 +934,7 @@ func (h *Handler) QueryDocuments(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
 		return
 	}
+	schema = readableSchema(r, schema)
 
 	// Parse pagination parameters
 	limit := 100 // Default limit
@@ -334,35 +955,256 @@ func (h *Handler) QueryDocuments(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Parse filters from query parameters
-	// Multiple values for same parameter are treated as OR (IN list)
-	filters := make(map[string][]string)
-	for key, values := range r.URL.Query() {
-		// Skip pagination parameters
-		if key == "limit" || key == "offset" {
-			continue
+	// Skip pagination, search, ordering, projection and join parameters
+	filters, err := schemaFilters(r.URL.Query(), schema, "limit", "offset", "search", "sort", "collate", "fields", "join", "deleted", "omit_deprecated")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+
+	// Timestamp filters apply to every collection, whatever its schema
+	dates, err := database.ParseTimeRange(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+
+	// Metadata columns can be sorted and projected alongside schema fields
+	order, err := database.ParseSort(r.URL.Query().Get("sort"), schema.Fields)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	collation := r.URL.Query().Get("collate")
+	if order, err = database.ApplyCollation(order, schema.Fields, collation); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	if collation == database.CollationUnicode {
+		if !h.requireCatalog(w, "Unicode collation") {
+			return
 		}
-		// Only include fields that exist in the schema
-		if _, exists := schema.Fields[key]; exists {
-			filters[key] = values
+		if !h.catalog.UnicodeCollationEnabled() {
+			respondError(w, http.StatusNotImplemented, "Not Implemented", "Unicode collation is not available: SQLite was built without ICU")
+			return
 		}
 	}
+	projection, err := database.ParseProjection(r.URL.Query().Get("fields"), schema.Fields)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	omit, ok := omitDeprecated(w, r)
+	if !ok {
+		return
+	}
 
-	// Query documents
-	documents, err := h.catalog.QueryDocuments(db.ID, collection, limit, offset, filters)
+	scope, err := readScopeFromContext(r, schema)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
 		return
 	}
 
+	// Soft-deleted documents are listed instead of live ones on request, with the write key
+	if deletedStr := r.URL.Query().Get("deleted"); deletedStr != "" {
+		deleted, err := strconv.ParseBool(deletedStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid deleted parameter: "+deletedStr)
+			return
+		}
+		if deleted && !isWriteKeyFromContext(r) {
+			respondError(w, http.StatusForbidden, "Forbidden", "Listing deleted documents requires the write key")
+			return
+		}
+		if deleted && !h.requireCatalog(w, "Soft delete") {
+			return
+		}
+		scope.Deleted = deleted
+	}
+
+	// Joined documents are read under the joined collection's own scope
+	join, err := database.ParseJoin(r.URL.Query().Get("join"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	var joinSchema *models.Schema
+	var joinScope *database.ReadScope
+	if join != nil {
+		if !h.requireCatalog(w, "Joins") {
+			return
+		}
+		if !keyAllowsCollection(r, join.Collection) {
+			respondError(w, http.StatusForbidden, "Forbidden", "Key may not read collection: "+join.Collection)
+			return
+		}
+		joinSchema, err = h.store.GetSchema(db.ID, join.Collection)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+			return
+		}
+		if joinSchema == nil {
+			respondError(w, http.StatusBadRequest, "Bad Request", "invalid join: collection does not exist: "+join.Collection)
+			return
+		}
+		joinSchema = readableSchema(r, joinSchema)
+		if err := join.Validate(schema, joinSchema); err != nil {
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+			return
+		}
+		if joinScope, err = readScopeFromContext(r, joinSchema); err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+			return
+		}
+	}
+
+	// Query documents, ranked by relevance when a full-text search is requested
+	var documents []*models.Document
+	if search := r.URL.Query().Get("search"); search != "" {
+		if !h.requireCatalog(w, "Full-text search") {
+			return
+		}
+		documents, err = h.catalog.SearchDocuments(db.ID, collection, search, limit, offset, filters, dates, order, scope)
+	} else {
+		documents, err = h.store.QueryDocuments(db.ID, collection, limit, offset, filters, dates, order, scope)
+	}
+	if err != nil {
+		if respondQueryLimit(w, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "search is not available") {
+			respondError(w, http.StatusNotImplemented, "Not Implemented", err.Error())
+			return
+		}
+		if strings.Contains(err.Error(), "search query cannot be empty") || strings.Contains(err.Error(), "no searchable string fields") {
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
 	// Return empty array if no documents found
 	if documents == nil {
 		documents = []*models.Document{}
 	}
+	if omit {
+		stripDeprecated(schema, documents)
+	}
+	stripMasked(r, schema, documents)
+
+	if join != nil {
+		if err := h.catalog.JoinDocuments(db.ID, collection, documents, join, joinScope); err != nil {
+			if respondQueryLimit(w, err) {
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+			return
+		}
+		for _, doc := range documents {
+			stripMasked(r, joinSchema, doc.Joined[join.Collection])
+		}
+	}
+
+	if projection != nil {
+		projected := make([]map[string]interface{}, len(documents))
+		for i, doc := range documents {
+			projected[i] = projection.Apply(doc)
+		}
+		respondJSON(w, http.StatusOK, projected)
+		return
+	}
 
 	respondJSON(w, http.StatusOK, documents)
 }
 
+// schemaFilters builds field filters from query parameters
+// Multiple values for same parameter are treated as OR (IN list). Only schema fields
+// are included, skipping the given endpoint parameters and the timestamp filters
+// String fields also take operators, as field.op parameters
+func schemaFilters(query url.Values, schema *models.Schema, reserved ...string) ([]database.Filter, error) {
+	var filters []database.Filter
+	for key, values := range query {
+		if slices.Contains(reserved, key) || database.IsTimeRangeParam(key) {
+			continue
+		}
+		// Only include fields that exist in the schema
+		filter, ok, err := database.ParseFilterParam(key, values, schema.Fields)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			filters = append(filters, filter)
+		}
+	}
+	// Keep the generated SQL stable across requests
+	slices.SortFunc(filters, func(a, b database.Filter) int {
+		return cmp.Or(strings.Compare(a.Field, b.Field), strings.Compare(string(a.Op), string(b.Op)))
+	})
+	return filters, nil
+}
+
+// GetDocument handles GET /api/databases/:id/:collection/:docId
+func (h *Handler) GetDocument(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	if collection == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Collection name is required")
+		return
+	}
+
+	docID := chi.URLParam(r, "docId")
+	if docID == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Document ID is required")
+		return
+	}
+
+	// Verify schema exists for this collection
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		return
+	}
+
+	scope, err := readScopeFromContext(r, schema)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	omit, ok := omitDeprecated(w, r)
+	if !ok {
+		return
+	}
+
+	// Documents the caller may not see are reported as missing
+	doc, err := h.store.GetDocument(db.ID, collection, docID, scope)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+	if doc == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "document not found")
+		return
+	}
+	if omit {
+		stripDeprecated(schema, []*models.Document{doc})
+	}
+	stripMasked(r, schema, []*models.Document{doc})
+
+	setDocumentETag(w, doc)
+	respondJSON(w, http.StatusOK, doc)
+}
+
 // DeleteDocument handles DELETE /api/databases/:id/:collection/:docId
 func (h *Handler) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 	db := getDatabaseFromContext(r)
@@ -383,13 +1225,41 @@ func (h *Handler) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Soft deletes can be undone until the collection is purged
+	soft := false
+	if softStr := r.URL.Query().Get("soft"); softStr != "" {
+		parsed, err := strconv.ParseBool(softStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Invalid soft parameter: "+softStr)
+			return
+		}
+		soft = parsed
+	}
+	if soft && !h.requireCatalog(w, "Soft delete") {
+		return
+	}
+
+	revision, ok := h.ifMatchRevision(w, r)
+	if !ok {
+		return
+	}
+
 	// Delete document
-	err := h.catalog.DeleteDocument(db.ID, collection, docID)
+	var err error
+	if soft {
+		err = h.catalog.SoftDeleteDocument(db.ID, collection, docID, revision)
+	} else {
+		err = h.store.DeleteDocument(db.ID, collection, docID, revision)
+	}
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			respondError(w, http.StatusNotFound, "Not Found", err.Error())
 			return
 		}
+		if strings.Contains(err.Error(), "revision mismatch") {
+			respondError(w, http.StatusPreconditionFailed, "Precondition Failed", err.Error())
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
 		return
 	}
@@ -419,8 +1289,7 @@ func (h *Handler) UpdateDocument(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req models.UpdateDocumentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid JSON body")
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
 
@@ -428,9 +1297,12 @@ func (h *Handler) UpdateDocument(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "Bad Request", "Document data cannot be empty")
 		return
 	}
+	if !h.checkDocumentSize(w, req.Data) {
+		return
+	}
 
 	// Get schema for validation
-	schema, err := h.catalog.GetSchema(db.ID, collection)
+	schema, err := h.store.GetSchema(db.ID, collection)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to get schema")
 		return
@@ -442,17 +1314,32 @@ func (h *Handler) UpdateDocument(w http.ResponseWriter, r *http.Request) {
 
 	// Validate document against schema
 	if err := models.ValidateDocument(req.Data, schema); err != nil {
-		respondError(w, http.StatusBadRequest, "Bad Request", "Validation failed: "+err.Error())
+		respondValidationError(w, r, err)
+		return
+	}
+	warnings := deprecationWarnings(schema, req.Data)
+
+	if req.Visibility != "" && !req.Visibility.IsValid() {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid visibility: "+string(req.Visibility))
+		return
+	}
+
+	revision, ok := h.ifMatchRevision(w, r)
+	if !ok {
 		return
 	}
 
 	// Update document
-	doc, err := h.catalog.UpdateDocument(db.ID, collection, docID, req.Data)
+	doc, err := h.store.UpdateDocument(db.ID, collection, docID, req.Data, req.Visibility, revision)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			respondError(w, http.StatusNotFound, "Not Found", err.Error())
 			return
 		}
+		if strings.Contains(err.Error(), "revision mismatch") {
+			respondError(w, http.StatusPreconditionFailed, "Precondition Failed", err.Error())
+			return
+		}
 		if strings.Contains(err.Error(), "quota exceeded") {
 			respondError(w, http.StatusPaymentRequired, "Quota Exceeded", err.Error())
 			return
@@ -461,6 +1348,8 @@ func (h *Handler) UpdateDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	addWarnings(w, warnings)
+	setDocumentETag(w, doc)
 	respondJSON(w, http.StatusOK, doc)
 }
 
@@ -477,9 +1366,17 @@ func (h *Handler) DeleteSchema(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "Bad Request", "Schema name is required")
 		return
 	}
+	if !h.confirmDeletion(w, r, db, "delete_schema:"+schemaName, "schema "+schemaName) {
+		return
+	}
 
+	h.deleteSchema(w, db, schemaName)
+}
+
+// deleteSchema deletes a schema and its documents and responds 204
+func (h *Handler) deleteSchema(w http.ResponseWriter, db *models.Database, schemaName string) {
 	// Delete schema
-	err := h.catalog.DeleteSchema(db.ID, schemaName)
+	err := h.store.DeleteSchema(db.ID, schemaName)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			respondError(w, http.StatusNotFound, "Not Found", err.Error())
@@ -499,13 +1396,22 @@ func (h *Handler) DeleteDatabase(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
 		return
 	}
+	if !h.confirmDeletion(w, r, db, "delete_database", "the database") {
+		return
+	}
 
+	h.deleteDatabase(w, db)
+}
+
+// deleteDatabase deletes a database with everything in it and responds 204
+func (h *Handler) deleteDatabase(w http.ResponseWriter, db *models.Database) {
 	// Delete database
-	err := h.catalog.DeleteDatabase(db.ID)
+	err := h.store.DeleteDatabase(db.ID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
 		return
 	}
+	h.usage.Forget(db.ID)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -525,3 +1431,74 @@ func respondError(w http.ResponseWriter, status int, error string, message strin
 	}
 	respondJSON(w, status, resp)
 }
+
+// respondValidationError sends a document validation error with its code, in the language
+// the request prefers
+func respondValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *models.ValidationError
+	if !errors.As(err, &validationErr) {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Validation failed: "+err.Error())
+		return
+	}
+
+	lang := i18n.Negotiate(r.Header.Get("Accept-Language"))
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	respondJSON(w, http.StatusBadRequest, models.ErrorResponse{
+		Error:   "Bad Request",
+		Message: i18n.ValidationMessage(lang, validationErr),
+		Code:    validationErr.Code,
+		Field:   validationErr.Field,
+	})
+}
+
+// ListCollections handles GET /api/databases/:id/collections
+// Counts only include documents visible to the presented key, and keys restricted to some
+// collections only see those
+func (h *Handler) ListCollections(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collections, err := h.catalog.ListCollections(db.ID, visibleLevelsFromContext(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+	collections = slices.DeleteFunc(collections, func(info *models.CollectionInfo) bool {
+		return !keyAllowsCollection(r, info.Name)
+	})
+
+	respondJSON(w, http.StatusOK, collections)
+}
+
+// GetCollectionStats handles GET /api/databases/:id/:collection/stats
+// Sizes cover every document, whatever its visibility, so the write key is required
+func (h *Handler) GetCollectionStats(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		return
+	}
+
+	stats, err := h.catalog.CollectionStats(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/models"
+)
+
+func TestCreateSchema_WriteOnlyFields(t *testing.T) {
+	a := newTestAPI(t, nil)
+	a.mustDo(http.MethodPost, "/schemas/people", testWriteKey, `{"fields": {"name": "string", "ssn": {"type": "string", "visibility": "write_only"}, "pin": {"type": "number", "visibility": "write_only"}}}`, http.StatusCreated)
+
+	schema, err := a.catalog.GetSchema(testDatabaseID, "people")
+	if err != nil || !slices.Equal(schema.Masked, []string{"pin", "ssn"}) {
+		t.Fatalf("GetSchema() masked = %v, %v, want [pin ssn]", schema, err)
+	}
+
+	var created, read models.Document
+	decodeBody(t, a.mustDo(http.MethodPost, "/people", testWriteKey, `{"data": {"name": "ann", "ssn": "123-45-6789", "pin": 1234}}`, http.StatusCreated).Body.Bytes(), &created)
+	decodeBody(t, a.mustDo(http.MethodGet, "/people/"+created.ID, testReadKey, "", http.StatusOK).Body.Bytes(), &read)
+	if _, ok := read.Data["ssn"]; ok || read.Data["name"] != "ann" {
+		t.Errorf("document read with the read key = %v, want name without ssn", read.Data)
+	}
+}
+
+// createNote creates a notes document through the API, returning its ID
+func createNote(t *testing.T, a *testAPI) string {
+	t.Helper()
+	var doc models.Document
+	decodeBody(t, a.mustDo(http.MethodPost, "/notes", testWriteKey, `{"data": {"text": "draft"}}`, http.StatusCreated).Body.Bytes(), &doc)
+	return doc.ID
+}
+
+func TestIfMatch_RequiredByDefault(t *testing.T) {
+	a := newTestAPI(t, nil)
+	a.mustDo(http.MethodPost, "/schemas/notes", testWriteKey, `{"fields": {"text": "string"}}`, http.StatusCreated)
+	id := createNote(t, a)
+
+	a.mustDo(http.MethodPut, "/notes/"+id, testWriteKey, `{"data": {"text": "final"}}`, http.StatusPreconditionRequired)
+	a.mustDo(http.MethodDelete, "/notes/"+id, testWriteKey, "", http.StatusPreconditionRequired)
+
+	var batch models.BatchResponse
+	decodeBody(t, a.mustDo(http.MethodPost, "/batch", testWriteKey, `{"operations": [{"op": "delete", "collection": "notes", "id": "`+id+`"}]}`, http.StatusOK).Body.Bytes(), &batch)
+	if len(batch.Results) != 1 || batch.Results[0].Code != "revision_required" {
+		t.Errorf("batch delete without a revision = %+v, want revision_required", batch.Results)
+	}
+
+	// A stale revision fails, the current one or "*" goes through
+	put := func(ifMatch string, want int) {
+		t.Helper()
+		r := a.newRequest(http.MethodPut, "/notes/"+id, testWriteKey, `{"data": {"text": "final"}}`)
+		r.Header.Set("If-Match", ifMatch)
+		if w := a.serve(r); w.Code != want {
+			t.Errorf("PUT with If-Match %s = %d %s, want %d", ifMatch, w.Code, w.Body.String(), want)
+		}
+	}
+	put(`"2"`, http.StatusPreconditionFailed)
+	put(`"1"`, http.StatusOK)
+	put("*", http.StatusOK)
+	put("3", http.StatusBadRequest)
+}
+
+func TestIfMatch_OptOut(t *testing.T) {
+	a := newTestAPI(t, func(cfg *config.Config) { cfg.RequireIfMatch = false })
+	a.mustDo(http.MethodPost, "/schemas/notes", testWriteKey, `{"fields": {"text": "string"}}`, http.StatusCreated)
+	id := createNote(t, a)
+
+	a.mustDo(http.MethodPut, "/notes/"+id, testWriteKey, `{"data": {"text": "final"}}`, http.StatusOK)
+	a.mustDo(http.MethodDelete, "/notes/"+id, testWriteKey, "", http.StatusNoContent)
+}
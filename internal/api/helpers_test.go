@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/database"
+	"jsondrop/internal/events"
+	"jsondrop/internal/token"
+)
+
+// Credentials of the database every test API starts with
+const (
+	testDatabaseID = "db_apitest"
+	testWriteKey   = "wk_apitestwritekey"
+	testReadKey    = "rk_apitestreadkey"
+)
+
+// testAPI serves the HTTP API over a fresh SQLite catalog holding one database
+type testAPI struct {
+	t       *testing.T
+	router  http.Handler
+	handler *Handler
+	catalog *database.CatalogDB
+}
+
+// newTestAPI starts the API with the default configuration, changed by configure
+// when it isn't nil
+func newTestAPI(t *testing.T, configure func(cfg *config.Config)) *testAPI {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() error = %v", err)
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	dir := t.TempDir()
+	broadcaster := events.NewBroadcaster(events.RateLimit{}, 10)
+	catalog, err := database.NewCatalogDB(filepath.Join(dir, "catalog.db"), dir, 1, database.Limits{}, database.PoolConfig{}, database.Compression{}, broadcaster, nil)
+	if err != nil {
+		t.Fatalf("NewCatalogDB() error = %v", err)
+	}
+	t.Cleanup(func() { catalog.Close() })
+	if _, err := catalog.CreateDatabaseWithKeys(testDatabaseID, testWriteKey, testReadKey, 1<<20); err != nil {
+		t.Fatalf("CreateDatabaseWithKeys() error = %v", err)
+	}
+
+	tokens, err := token.NewIssuer(nil)
+	if err != nil {
+		t.Fatalf("NewIssuer() error = %v", err)
+	}
+	handler := NewHandler(catalog, broadcaster, cfg, tokens)
+	admin := NewAdminHandler(catalog, broadcaster, cfg, nil)
+	return &testAPI{t: t, router: NewRouter(handler, admin, nil, catalog, cfg, nil), handler: handler, catalog: catalog}
+}
+
+// newRequest builds a request for a path under the test database, such as "/notes",
+// authenticated with key unless it is empty
+func (a *testAPI) newRequest(method, path, key, body string) *http.Request {
+	r := httptest.NewRequest(method, "/api/databases/"+testDatabaseID+path, strings.NewReader(body))
+	if key != "" {
+		r.Header.Set("Authorization", "Bearer "+key)
+	}
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+// serve handles a request
+func (a *testAPI) serve(r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	return w
+}
+
+// do handles a request for a path under the test database
+func (a *testAPI) do(method, path, key, body string) *httptest.ResponseRecorder {
+	return a.serve(a.newRequest(method, path, key, body))
+}
+
+// mustDo handles a request that must get the status want, failing the test otherwise
+func (a *testAPI) mustDo(method, path, key, body string, want int) *httptest.ResponseRecorder {
+	a.t.Helper()
+	w := a.do(method, path, key, body)
+	if w.Code != want {
+		a.t.Fatalf("%s %s = %d %s, want %d", method, path, w.Code, w.Body.String(), want)
+	}
+	return w
+}
+
+// decodeBody decodes a JSON response body into v
+func decodeBody(t *testing.T, body []byte, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("failed to decode %s: %v", body, err)
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/events"
+)
+
+// EventHistory handles GET /api/databases/:id/events/history
+// ?since= takes a sequence number from an earlier page or event, or a timestamp; ?limit=,
+// ?collection= and ?events= narrow the page
+func (h *Handler) EventHistory(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	query := r.URL.Query()
+	var q database.EventHistoryQuery
+	var ok bool
+	if q.Since, q.SinceTime, ok = parseLogPosition(w, query.Get("since")); !ok {
+		return
+	}
+	if q.Limit, ok = parseLogLimit(w, query.Get("limit")); !ok {
+		return
+	}
+
+	types, err := events.ParseTypes(query.Get("events"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	q.Types = types
+	q.Collection = query.Get("collection")
+
+	history, err := h.catalog.EventHistory(db.ID, q)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+	if redact := h.eventRedactor(r, db.ID); redact != nil {
+		readable := history.Events[:0]
+		for _, event := range history.Events {
+			if event, ok := redact(event); ok {
+				readable = append(readable, event)
+			}
+		}
+		history.Events = readable
+	}
+
+	respondJSON(w, http.StatusOK, history)
+}
+
+// parseLogPosition reads a ?since= position in the event log: a sequence number, or a
+// timestamp that must be a date or RFC 3339 since whole numbers are sequence numbers
+// Empty starts from the beginning of the log
+func parseLogPosition(w http.ResponseWriter, value string) (uint64, time.Time, bool) {
+	if value == "" {
+		return 0, time.Time{}, true
+	}
+	if seq, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return seq, time.Time{}, true
+	}
+	since, err := database.ParseTimestamp(value)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "invalid since: expected a sequence number, an RFC 3339 timestamp or a YYYY-MM-DD date")
+		return 0, time.Time{}, false
+	}
+	return 0, since, true
+}
+
+// parseLogLimit reads a ?limit= page size for the event log; empty selects the default
+func parseLogLimit(w http.ResponseWriter, value string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > database.MaxHistoryLimit {
+		respondError(w, http.StatusBadRequest, "Bad Request", "invalid limit: must be between 1 and "+strconv.Itoa(database.MaxHistoryLimit))
+		return 0, false
+	}
+	return limit, true
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ImportDocuments handles POST /api/databases/:id/:collection/import
+// The body is NDJSON with one document per line, in the format produced by export
+func (h *Handler) ImportDocuments(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	if collection == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Collection name is required")
+		return
+	}
+
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to get schema")
+		return
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Schema does not exist for collection: "+collection)
+		return
+	}
+
+	result, err := h.catalog.ImportNDJSON(db.ID, schema, r.Body)
+	if err != nil {
+		respondJSON(w, importErrorStatus(err), result)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// importErrorStatus maps an import that stopped early to a status code
+func importErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "quota exceeded"):
+		return http.StatusPaymentRequired
+	case strings.Contains(err.Error(), "failed to read"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateIndex handles POST /api/databases/:id/:collection/indexes
+func (h *Handler) CreateIndex(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	if collection == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Collection name is required")
+		return
+	}
+
+	var req models.CreateIndexRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Name == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Index name is required")
+		return
+	}
+
+	index, err := h.catalog.CreateIndex(db.ID, collection, req.Name, req.Fields)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "schema not found"):
+			respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		case strings.Contains(err.Error(), "already exists"):
+			respondError(w, http.StatusConflict, "Conflict", err.Error())
+		case strings.Contains(err.Error(), "limit exceeded"):
+			respondError(w, http.StatusUnprocessableEntity, "Unprocessable Entity", err.Error())
+		case strings.HasPrefix(err.Error(), "invalid index"):
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, index)
+}
+
+// ListIndexes handles GET /api/databases/:id/:collection/indexes
+func (h *Handler) ListIndexes(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		return
+	}
+
+	indexes, err := h.catalog.ListIndexes(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, indexes)
+}
+
+// DeleteIndex handles DELETE /api/databases/:id/:collection/indexes/:name
+func (h *Handler) DeleteIndex(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	name := chi.URLParam(r, "name")
+	if err := h.catalog.DeleteIndex(db.ID, collection, name); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
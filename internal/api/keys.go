@@ -0,0 +1,149 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxKeyGrace bounds how long rotated keys keep working
+const maxKeyGrace = 7 * 24 * time.Hour
+
+// RotateKeys handles POST /api/databases/:id/keys/rotate
+// Replaces the write key, the read key or both, returning the new keys only this once.
+// The old keys stop working at once, closing the database's event streams on this server,
+// or keep working for grace_seconds so clients can be moved over
+func (h *Handler) RotateKeys(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.RotateKeysRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if !req.Write && !req.Read {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Choose the keys to rotate with write, read or both")
+		return
+	}
+	grace := time.Duration(req.GraceSeconds) * time.Second
+	if grace < 0 || grace > maxKeyGrace {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("grace_seconds must be between 0 and %d", int(maxKeyGrace.Seconds())))
+		return
+	}
+
+	resp, err := h.store.RotateKeys(db.ID, req.Write, req.Read, grace)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	// Streams opened with a revoked key would otherwise keep receiving events
+	if grace == 0 {
+		resp.StreamsClosed = h.broadcaster.Disconnect(db.ID)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// CreateScopedKey handles POST /api/databases/:id/keys
+// Creates a write or read key restricted to some collections, optionally working only
+// until expires_at; the response carries the key, which isn't shown again
+func (h *Handler) CreateScopedKey(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.CreateScopedKeyRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Access != models.KeyAccessWrite && req.Access != models.KeyAccessRead {
+		respondError(w, http.StatusBadRequest, "Bad Request", "access must be write or read")
+		return
+	}
+	if len(req.Collections) == 0 {
+		respondError(w, http.StatusBadRequest, "Bad Request", "At least one collection is required")
+		return
+	}
+
+	key := &models.ScopedKey{
+		DatabaseID:  db.ID,
+		Name:        req.Name,
+		Access:      req.Access,
+		Collections: req.Collections,
+		ExpiresAt:   req.ExpiresAt,
+	}
+	if err := h.catalog.CreateScopedKey(key); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "schema not found"):
+			respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+strings.TrimPrefix(err.Error(), "schema not found: "))
+		case strings.Contains(err.Error(), "limit exceeded"):
+			respondError(w, http.StatusUnprocessableEntity, "Unprocessable Entity", err.Error())
+		case strings.HasPrefix(err.Error(), "invalid"):
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusCreated, key)
+}
+
+// ListScopedKeys handles GET /api/databases/:id/keys
+// The keys themselves are left out
+func (h *Handler) ListScopedKeys(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	keys, err := h.catalog.ListScopedKeys(db.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+	for _, key := range keys {
+		key.Key = ""
+	}
+
+	respondJSON(w, http.StatusOK, keys)
+}
+
+// DeleteScopedKey handles DELETE /api/databases/:id/keys/:keyId
+// Requests with the key fail from then on; its open event streams aren't closed
+func (h *Handler) DeleteScopedKey(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	if err := h.catalog.DeleteScopedKey(db.ID, chi.URLParam(r, "keyId")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
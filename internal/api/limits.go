@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+// Error codes of requests over a size limit
+const (
+	codeBodyTooLarge     = "body_too_large"
+	codeDocumentTooLarge = "document_too_large"
+)
+
+// Error codes of queries stopped by QUERY_TIMEOUT or MAX_ROWS_SCANNED
+const (
+	codeQueryTimeout      = "query_timeout"
+	codeScanLimitExceeded = "scan_limit_exceeded"
+)
+
+// limitBody is middleware that caps request bodies at maxBytes; 0 disables it
+// Bodies declaring a larger Content-Length are refused up front; others fail as they are
+// read. Imports stream their bodies, and are bounded by the quota and line length instead
+func limitBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxBytes <= 0 || (r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/import")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength > maxBytes {
+				respondTooLarge(w, codeBodyTooLarge, fmt.Sprintf("Request body of %d bytes exceeds the limit of %d", r.ContentLength, maxBytes), maxBytes)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// decodeJSONBody decodes a request's JSON body into v, responding with an error if it can't
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondTooLarge(w, codeBodyTooLarge, fmt.Sprintf("Request body exceeds the limit of %d bytes", tooLarge.Limit), tooLarge.Limit)
+		return false
+	}
+	respondError(w, http.StatusBadRequest, "Bad Request", "Invalid JSON body")
+	return false
+}
+
+// checkDocumentSize responds with an error if document data is over MAX_DOCUMENT_BYTES
+func (h *Handler) checkDocumentSize(w http.ResponseWriter, data map[string]interface{}) bool {
+	err := models.CheckDocumentSize(data, h.cfg.MaxDocumentBytes)
+	if err == nil {
+		return true
+	}
+	var tooLarge *models.DocumentTooLargeError
+	if errors.As(err, &tooLarge) {
+		respondTooLarge(w, codeDocumentTooLarge, fmt.Sprintf("Document of %d bytes exceeds the limit of %d", tooLarge.Size, tooLarge.Limit), tooLarge.Limit)
+		return false
+	}
+	respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+	return false
+}
+
+// respondTooLarge sends a 413 response naming the limit that was exceeded
+func respondTooLarge(w http.ResponseWriter, code string, message string, limit int64) {
+	respondJSON(w, http.StatusRequestEntityTooLarge, models.ErrorResponse{
+		Error:   "Payload Too Large",
+		Message: message,
+		Code:    code,
+		Limit:   limit,
+	})
+}
+
+// respondQueryLimit sends an error response if err is a query stopped by a query limit,
+// and reports whether it did: 408 for running out of time, 422 for reading too many rows
+func respondQueryLimit(w http.ResponseWriter, err error) bool {
+	var limitErr *database.QueryLimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+	if limitErr.Timeout > 0 {
+		respondJSON(w, http.StatusRequestTimeout, models.ErrorResponse{
+			Error:   "Request Timeout",
+			Message: fmt.Sprintf("Query ran longer than %s; narrow it with filters or a date range", limitErr.Timeout),
+			Code:    codeQueryTimeout,
+			Limit:   limitErr.Timeout.Milliseconds(),
+		})
+		return true
+	}
+	respondJSON(w, http.StatusUnprocessableEntity, models.ErrorResponse{
+		Error:   "Unprocessable Entity",
+		Message: fmt.Sprintf("Query read more than %d rows; narrow it with filters or a date range", limitErr.Rows),
+		Code:    codeScanLimitExceeded,
+		Limit:   int64(limitErr.Rows),
+	})
+	return true
+}
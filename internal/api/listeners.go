@@ -0,0 +1,16 @@
+package api
+
+import "net/http"
+
+// ListListeners handles GET /api/databases/:id/listeners
+// Lists the database's open event streams on this server, with what each subscribed to
+// and when it last pinged, for debugging clients that don't receive events
+func (h *Handler) ListListeners(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, h.broadcaster.Listeners(db.ID))
+}
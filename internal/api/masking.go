@@ -0,0 +1,161 @@
+package api
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/events"
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MaskField handles PUT /api/databases/:id/schemas/:name/masked/:field
+// Only the write key reads the field from then on: it is stripped from the documents
+// and events read keys and anonymous readers receive
+func (h *Handler) MaskField(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	schema, err := h.catalog.MaskField(db.ID, chi.URLParam(r, "name"), chi.URLParam(r, "field"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, schema)
+}
+
+// UnmaskField handles DELETE /api/databases/:id/schemas/:name/masked/:field
+// Every key reads the field again
+func (h *Handler) UnmaskField(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	schema, err := h.catalog.UnmaskField(db.ID, chi.URLParam(r, "name"), chi.URLParam(r, "field"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, schema)
+}
+
+// maskedFields returns the fields of a schema the request may not read
+func maskedFields(r *http.Request, schema *models.Schema) []string {
+	if isWriteKeyFromContext(r) {
+		return nil
+	}
+	return schema.Masked
+}
+
+// readableSchema returns a schema as the request sees it, without the fields it may not
+// read. Filters, sorts, projections, joins and aggregations then treat those as unknown,
+// so their values can't be probed for
+func readableSchema(r *http.Request, schema *models.Schema) *models.Schema {
+	masked := maskedFields(r, schema)
+	if len(masked) == 0 {
+		return schema
+	}
+	readable := *schema
+	readable.Fields = maps.Clone(schema.Fields)
+	for _, field := range masked {
+		delete(readable.Fields, field)
+	}
+	return &readable
+}
+
+// stripMasked removes the fields of a schema the request may not read from documents
+func stripMasked(r *http.Request, schema *models.Schema, documents []*models.Document) {
+	masked := maskedFields(r, schema)
+	if len(masked) == 0 {
+		return
+	}
+	for _, doc := range documents {
+		for _, field := range masked {
+			delete(doc.Data, field)
+		}
+	}
+}
+
+// eventRedactor returns the redaction of the events a request receives from streams,
+// replays and the event log, or nil when it may read them all. Document events are
+// read with the scope queries of the document's collection have: they keep only the
+// documents of visibility levels the request may read that pass the collection's read
+// filter, before or after an update, and are dropped when it may read neither. Fields
+// it may not read are stripped. Schemas are looked up as events arrive, so masking a
+// field or changing the read filter applies to open streams too
+func (h *Handler) eventRedactor(r *http.Request, dbID string) func(models.ChangeEvent) (models.ChangeEvent, bool) {
+	if isWriteKeyFromContext(r) {
+		return nil
+	}
+	return func(event models.ChangeEvent) (models.ChangeEvent, bool) {
+		switch event.EventType {
+		case "insert", "update", "delete", "restore":
+		default:
+			return event, true
+		}
+		scope, err := h.eventScope(r, dbID, event.Collection)
+		if err != nil {
+			return event, false
+		}
+		allows := func(data map[string]interface{}) bool {
+			return data == nil || !scope.HasPolicy() || scope.Allow(&models.Document{Data: data})
+		}
+
+		// Events logged before visibility was recorded have none, and are read by no one
+		readable := slices.Contains(scope.VisibleLevels(), event.Visibility)
+		oldReadable := readable
+		if event.OldVisibility != "" {
+			oldReadable = slices.Contains(scope.VisibleLevels(), event.OldVisibility)
+		}
+		// A delete's data only marks how it was deleted; the document is its old data
+		readable = readable && (event.EventType == "delete" || allows(event.Data))
+		oldReadable = oldReadable && allows(event.OldData)
+		if event.EventType != "update" {
+			readable = readable && oldReadable
+			oldReadable = readable
+		}
+		if !readable && !oldReadable {
+			return event, false
+		}
+		if !readable {
+			event.Data = nil
+		}
+		if !oldReadable {
+			event.OldData = nil
+		}
+		masked := scope.MaskedFields()
+		return events.Mask(event, func(string) ([]string, bool) { return masked, true }), true
+	}
+}
+
+// eventScope builds the read scope of a request for the events of a collection, which
+// may have been deleted since
+func (h *Handler) eventScope(r *http.Request, dbID string, collection string) (*database.ReadScope, error) {
+	schema, err := h.store.GetSchema(dbID, collection)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return &database.ReadScope{Visible: visibleLevelsFromContext(r)}, nil
+	}
+	return readScopeFromContext(r, schema)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"jsondrop/internal/analytics"
+	"jsondrop/internal/database"
+	"jsondrop/internal/events"
+	"jsondrop/internal/i18n"
+	"jsondrop/internal/models"
+)
+
+// GetMeta handles GET /api/meta
+// Reports server limits and capabilities; no authentication required
+func (h *Handler) GetMeta(w http.ResponseWriter, r *http.Request) {
+	limits := h.store.Limits()
+
+	resp := models.MetaResponse{
+		Limits: models.MetaLimits{
+			MaxCollections:     limits.MaxCollections,
+			MaxSchemaFields:    limits.MaxSchemaFields,
+			DefaultQuotaMB:     h.cfg.DefaultQuotaMB,
+			ExpiryDays:         h.cfg.ExpiryDays,
+			MaxBatchOperations: maxBatchOperations,
+			MaxDocumentBytes:   h.cfg.MaxDocumentBytes,
+			MaxBodyBytes:       h.cfg.MaxBodyBytes,
+			QueryTimeoutMS:     limits.QueryTimeout.Milliseconds(),
+			MaxRowsScanned:     limits.MaxRowsScanned,
+			MaxDatabases:       h.cfg.Creation.MaxDatabases,
+			CreateRateLimit:    h.cfg.Creation.RateLimit,
+			CreateRateWindow:   int64(h.cfg.Creation.RateWindow.Seconds()),
+		},
+		Features: models.MetaFeatures{
+			FieldTypes:      []models.FieldType{models.FieldTypeString, models.FieldTypeNumber, models.FieldTypeBool},
+			Visibilities:    []models.Visibility{models.VisibilityPublic, models.VisibilityReadKey, models.VisibilityWriteKeyOnly},
+			ExportFormats:   []string{"ndjson", "csv", "parquet"},
+			FullTextSearch:  h.catalog != nil && h.catalog.SearchEnabled(),
+			Analytics:       analytics.Enabled(),
+			EventVersions:   events.Versions(),
+			EventTypes:      events.EventTypes,
+			StorageBackend:  h.cfg.StorageBackend,
+			Languages:       i18n.Languages,
+			DevMode:         h.cfg.DevMode,
+			CreateChallenge: h.cfg.Creation.Challenge,
+		},
+	}
+
+	if h.catalog != nil {
+		caps := h.catalog.Capabilities()
+		resp.Features.SQLiteDriver = database.CurrentDriver()
+		resp.Features.SQLite = &caps
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -1,12 +1,26 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"jsondrop/internal/clientaddr"
 	"jsondrop/internal/database"
+	"jsondrop/internal/lockout"
 	"jsondrop/internal/models"
+	"jsondrop/internal/policy"
+	"jsondrop/internal/signing"
+	"jsondrop/internal/token"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -15,12 +29,92 @@ import (
 type contextKey string
 
 const (
-	contextKeyDatabase contextKey = "database"
-	contextKeyIsWrite  contextKey = "is_write"
+	contextKeyDatabase      contextKey = "database"
+	contextKeyIsWrite       contextKey = "is_write"
+	contextKeyAuthenticated contextKey = "authenticated"
+	contextKeyCollections   contextKey = "collections"
+	contextKeyToken         contextKey = "token"
+	contextKeyClaims        contextKey = "claims"
+	contextKeyCredential    contextKey = "credential"
 )
 
-// authMiddleware validates the API key and loads the database
-func authMiddleware(catalog *database.CatalogDB) func(http.Handler) http.Handler {
+// scopedKeyRoutes are the database-level routes, below /api/databases/{id}, that keys
+// restricted to some collections may use. Their handlers narrow them to the key's
+// collections; every other route must name one of those collections
+var scopedKeyRoutes = map[string]bool{
+	"/events":      true,
+	"/events/ack":  true,
+	"/ws":          true,
+	"/batch":       true,
+	"/collections": true,
+}
+
+// authConfig holds what key authentication needs besides the store
+type authConfig struct {
+	tokens     *token.Issuer
+	signatures *signing.Verifier
+	proxies    []netip.Prefix // Reverse proxies trusted to name the client in X-Forwarded-For
+	maxBody    int64          // Largest signed body read, imports included; 0 means unlimited
+	lockout    *lockout.Guard // Locks out clients presenting too many invalid keys; nil when disabled
+	origins    []string       // CORS_ORIGINS, which every database admits
+}
+
+// authConfig returns the handler's key authentication settings
+func (h *Handler) authConfig() authConfig {
+	return authConfig{tokens: h.tokens, signatures: h.signatures, proxies: h.cfg.TrustedProxies, maxBody: h.cfg.MaxBodyBytes, lockout: h.lockout, origins: h.cfg.CORSOrigins}
+}
+
+// databaseGate applies the client policies of the database named in the URL before
+// any key is looked at, so keyed and anonymous requests are held to the same ones:
+// locked out clients, addresses outside the database's allowed networks and browsers
+// on origins it doesn't allow are turned away. Browsers don't apply CORS to
+// WebSockets, so the origin check is what keeps other sites from opening them
+func databaseGate(store database.Store, auth authConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr, _ := clientaddr.FromRequest(r, auth.proxies)
+			if wait := auth.lockout.Wait(addr, time.Now()); wait > 0 {
+				respondLockedOut(w, wait)
+				return
+			}
+
+			db, err := store.GetDatabaseByID(chi.URLParam(r, "id"))
+			if err != nil {
+				respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to load database")
+				return
+			}
+			if db == nil {
+				// Unknown databases are left to authentication, which fails them like wrong keys
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !networkAllowed(r, db, auth.proxies) {
+				respondError(w, http.StatusForbidden, "Forbidden", "Requests from this address are not allowed for this database")
+				return
+			}
+			if origin := r.Header.Get("Origin"); origin != "" && !originAllowed(auth.origins, origin) && !originAllowed(db.CORSOrigins, origin) {
+				respondError(w, http.StatusForbidden, "Forbidden", "Origin not allowed: "+origin)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authMiddleware validates the API key, access token or request signature and loads the database
+func authMiddleware(store database.Store, auth authConfig) func(http.Handler) http.Handler {
+	return keyAuth(store, auth, false)
+}
+
+// publicAuthMiddleware behaves like authMiddleware but lets requests without
+// an API key through as anonymous readers of public documents
+func publicAuthMiddleware(store database.Store, auth authConfig) func(http.Handler) http.Handler {
+	return keyAuth(store, auth, true)
+}
+
+// keyAuth builds the key authentication middleware, which runs behind databaseGate
+// Clients presenting too many invalid keys are locked out for a while
+func keyAuth(store database.Store, auth authConfig, allowAnonymous bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract API key from Authorization header or query parameter
@@ -33,34 +127,109 @@ func authMiddleware(catalog *database.CatalogDB) func(http.Handler) http.Handler
 				apiKey = r.URL.Query().Get("key")
 			}
 
-			if apiKey == "" {
+			signature := r.Header.Get(signing.HeaderSignature)
+			signedURL := apiKey == "" && signature == "" && r.URL.Query().Has(signing.ParamSignature)
+
+			if apiKey == "" && signature == "" && !signedURL && allowAnonymous {
+				serveAnonymous(store, next, w, r)
+				return
+			}
+
+			if apiKey == "" && signature == "" && !signedURL {
 				respondError(w, http.StatusUnauthorized, "Unauthorized", "Missing API key")
 				return
 			}
 
+			addr, _ := clientaddr.FromRequest(r, auth.proxies)
+			reject := func(message string) {
+				auth.lockout.Fail(addr, time.Now())
+				respondError(w, http.StatusUnauthorized, "Unauthorized", message)
+			}
+
 			// Try to authenticate with write key first
 			var db *models.Database
-			var isWrite bool
+			var isWrite, isToken bool
+			var collections []string
+			var credential requestCredential
+			var tokenClaims *token.Claims
 			var err error
 
-			if strings.HasPrefix(apiKey, "wk_") {
-				db, err = catalog.GetDatabaseByWriteKey(apiKey)
+			if signature != "" {
+				db, isWrite, err = signedDatabase(store, auth, r)
+				if db != nil && err == nil {
+					key := db.ReadKey
+					if isWrite {
+						key = db.WriteKey
+					}
+					credential = databaseKeyCredential(key, isWrite)
+				}
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					respondTooLarge(w, codeBodyTooLarge, fmt.Sprintf("Request body exceeds the limit of %d bytes", tooLarge.Limit), tooLarge.Limit)
+					return
+				}
+				if err != nil && strings.HasPrefix(err.Error(), "invalid") {
+					reject("Request signature rejected: " + err.Error())
+					return
+				}
+			} else if signedURL {
+				db, isWrite, err = signedURLDatabase(store, r)
+				if err != nil && strings.HasPrefix(err.Error(), "invalid") {
+					reject("Signed URL rejected: " + err.Error())
+					return
+				}
+				// Signed URLs stand in for keys like access tokens do
+				isToken = true
+			} else if strings.HasPrefix(apiKey, "wk_") {
+				db, err = store.GetDatabaseByWriteKey(apiKey)
 				isWrite = true
+				credential = databaseKeyCredential(apiKey, true)
 			} else if strings.HasPrefix(apiKey, "rk_") {
-				db, err = catalog.GetDatabaseByReadKey(apiKey)
+				db, err = store.GetDatabaseByReadKey(apiKey)
 				isWrite = false
+				credential = databaseKeyCredential(apiKey, false)
+			} else if auth.tokens != nil && token.Looks(apiKey) {
+				claims, verr := auth.tokens.Verify(apiKey, time.Now())
+				if verr != nil {
+					reject("Invalid or expired access token")
+					return
+				}
+				db, err = store.GetDatabaseByID(claims.DatabaseID)
+				if db != nil && db.KeyGeneration != claims.Generation {
+					respondError(w, http.StatusUnauthorized, "Unauthorized", "Access token revoked by a key rotation")
+					return
+				}
+				isWrite = claims.Access == models.KeyAccessWrite
+				isToken = true
+				collections = claims.Collections
+				credential = requestCredential{kind: models.CredentialToken, id: claims.ID}
+				tokenClaims = claims
 			} else {
-				respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid API key format")
+				reject("Invalid API key format")
 				return
 			}
 
+			// Keys restricted to some collections are only issued with the SQLite backend
+			var scoped *models.ScopedKey
+			if catalog, ok := store.(*database.CatalogDB); ok && db == nil && err == nil {
+				if scoped, err = catalog.GetScopedKey(apiKey); scoped != nil {
+					db, err = store.GetDatabaseByID(scoped.DatabaseID)
+					collections = scoped.Collections
+					credential = requestCredential{kind: models.CredentialScopedKey, id: scoped.ID}
+				}
+			}
+
 			if err != nil {
 				respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to authenticate")
 				return
 			}
 
 			if db == nil {
-				respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid API key")
+				reject("Invalid API key")
+				return
+			}
+			if scoped != nil && scoped.Expired(time.Now()) {
+				respondError(w, http.StatusUnauthorized, "Unauthorized", "API key expired")
 				return
 			}
 
@@ -70,9 +239,13 @@ func authMiddleware(catalog *database.CatalogDB) func(http.Handler) http.Handler
 				respondError(w, http.StatusForbidden, "Forbidden", "Database ID mismatch")
 				return
 			}
+			if collections != nil && !scopeAllowsRoute(r, collections) {
+				respondError(w, http.StatusForbidden, "Forbidden", "Key is restricted to collections: "+strings.Join(collections, ", "))
+				return
+			}
 
 			// Update last accessed timestamp
-			if err := catalog.UpdateLastAccessed(db.ID); err != nil {
+			if err := store.UpdateLastAccessed(db.ID); err != nil {
 				// Log error but don't fail the request
 				// TODO: Add proper logging
 			}
@@ -80,12 +253,118 @@ func authMiddleware(catalog *database.CatalogDB) func(http.Handler) http.Handler
 			// Store database and write permission in context
 			ctx := context.WithValue(r.Context(), contextKeyDatabase, db)
 			ctx = context.WithValue(ctx, contextKeyIsWrite, isWrite)
+			ctx = context.WithValue(ctx, contextKeyAuthenticated, true)
+			ctx = context.WithValue(ctx, contextKeyToken, isToken)
+			ctx = context.WithValue(ctx, contextKeyCredential, credential)
+			if collections != nil {
+				ctx = context.WithValue(ctx, contextKeyCollections, collections)
+			}
+			if tokenClaims != nil {
+				ctx = context.WithValue(ctx, contextKeyClaims, tokenClaims)
+			}
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// respondLockedOut refuses a client locked out for presenting invalid keys, telling it
+// when it may try again
+func respondLockedOut(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	respondError(w, http.StatusTooManyRequests, "Too Many Requests", "Too many failed authentication attempts, try again later")
+}
+
+// signedDatabase authenticates a request signed with a database key instead of carrying
+// it, returning the database and whether the key was its write key. The body is read to
+// check the signature and put back for the handler. Errors for requests failing
+// verification start with "invalid"
+func signedDatabase(store database.Store, auth authConfig, r *http.Request) (*models.Database, bool, error) {
+	credential := r.Header.Get(signing.HeaderCredential)
+	dbID, isWrite, err := signing.ParseCredential(credential)
+	if err != nil {
+		return nil, false, err
+	}
+	db, err := store.GetDatabaseByID(dbID)
+	if err != nil {
+		return nil, false, err
+	}
+	if db == nil {
+		// Unknown databases fail like wrong keys, so IDs can't be probed
+		return nil, false, fmt.Errorf("invalid signature")
+	}
+
+	if auth.maxBody > 0 {
+		r.Body = http.MaxBytesReader(nil, r.Body, auth.maxBody)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	key := db.ReadKey
+	if isWrite {
+		key = db.WriteKey
+	}
+	err = auth.signatures.Verify(key, credential, r.Method, r.URL.RequestURI(),
+		r.Header.Get(signing.HeaderTimestamp), r.Header.Get(signing.HeaderNonce), body,
+		r.Header.Get(signing.HeaderSignature), time.Now())
+	if err != nil {
+		return nil, false, err
+	}
+	return db, isWrite, nil
+}
+
+// signedURLDatabase authenticates a GET request for a URL signed with a database's
+// write key, returning the database and whether the URL reads as the write key. Errors
+// for requests failing verification start with "invalid"
+func signedURLDatabase(store database.Store, r *http.Request) (*models.Database, bool, error) {
+	if r.Method != http.MethodGet || !slices.ContainsFunc(signedURLRoutes, func(route string) bool {
+		return strings.HasSuffix(chi.RouteContext(r.Context()).RoutePattern(), route)
+	}) {
+		return nil, false, fmt.Errorf("invalid signed URL: only documents and exports can be read with one")
+	}
+	db, err := store.GetDatabaseByID(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, false, err
+	}
+	if db == nil {
+		// Unknown databases fail like wrong signatures, so IDs can't be probed
+		return nil, false, fmt.Errorf("invalid signed URL: signature mismatch")
+	}
+	access, err := signing.VerifyURL(db.WriteKey, r.URL.Path, r.URL.Query(), time.Now())
+	if err != nil {
+		return nil, false, err
+	}
+	return db, access == models.KeyAccessWrite, nil
+}
+
+// serveAnonymous loads the database named in the URL for a request without an API key
+func serveAnonymous(store database.Store, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	dbID := chi.URLParam(r, "id")
+	db, err := store.GetDatabaseByID(dbID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to load database")
+		return
+	}
+	if db == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Database not found")
+		return
+	}
+
+	// Anonymous reads still count as activity
+	if err := store.UpdateLastAccessed(db.ID); err != nil {
+		// Log error but don't fail the request
+	}
+
+	ctx := context.WithValue(r.Context(), contextKeyDatabase, db)
+	ctx = context.WithValue(ctx, contextKeyIsWrite, false)
+	ctx = context.WithValue(ctx, contextKeyAuthenticated, false)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // requireWriteKey middleware ensures the request uses a write key
 func requireWriteKey(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -98,6 +377,51 @@ func requireWriteKey(next http.Handler) http.Handler {
 	})
 }
 
+// requireAPIKey middleware keeps access tokens from managing keys or minting tokens,
+// which would outlive them
+func requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isToken, _ := r.Context().Value(contextKeyToken).(bool); isToken {
+			respondError(w, http.StatusForbidden, "Forbidden", "API key required: access tokens can't be used here")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// networkAllowed reports whether a request comes from one of the database's allowed
+// networks; requests from unknown addresses are refused when it has any
+func networkAllowed(r *http.Request, db *models.Database, proxies []netip.Prefix) bool {
+	if len(db.AllowedNetworks) == 0 {
+		return true
+	}
+	addr, ok := clientaddr.FromRequest(r, proxies)
+	return ok && database.NetworksAllow(db.AllowedNetworks, addr)
+}
+
+// scopeAllowsRoute reports whether a key restricted to collections may use the request's
+// route: one naming a collection among them, or one of scopedKeyRoutes
+func scopeAllowsRoute(r *http.Request, collections []string) bool {
+	if collection := chi.URLParam(r, "collection"); collection != "" {
+		return slices.Contains(collections, collection)
+	}
+	_, route, _ := strings.Cut(chi.RouteContext(r.Context()).RoutePattern(), "/databases/{id}")
+	return scopedKeyRoutes[route]
+}
+
+// keyCollectionsFromContext returns the collections the request's key or token is restricted to,
+// or nil when it may use every collection
+func keyCollectionsFromContext(r *http.Request) []string {
+	collections, _ := r.Context().Value(contextKeyCollections).([]string)
+	return collections
+}
+
+// keyAllowsCollection reports whether the request's key may use a collection
+func keyAllowsCollection(r *http.Request, collection string) bool {
+	collections := keyCollectionsFromContext(r)
+	return collections == nil || slices.Contains(collections, collection)
+}
+
 // getDatabaseFromContext retrieves the database from request context
 func getDatabaseFromContext(r *http.Request) *models.Database {
 	db, _ := r.Context().Value(contextKeyDatabase).(*models.Database)
@@ -109,3 +433,50 @@ func isWriteKeyFromContext(r *http.Request) bool {
 	isWrite, _ := r.Context().Value(contextKeyIsWrite).(bool)
 	return isWrite
 }
+
+// isAuthenticatedFromContext checks if the request presented a valid API key
+func isAuthenticatedFromContext(r *http.Request) bool {
+	authenticated, _ := r.Context().Value(contextKeyAuthenticated).(bool)
+	return authenticated
+}
+
+// keyTypeFromContext returns the policy key type for the request
+func keyTypeFromContext(r *http.Request) string {
+	switch {
+	case isWriteKeyFromContext(r):
+		return policy.KeyTypeWrite
+	case isAuthenticatedFromContext(r):
+		return policy.KeyTypeRead
+	default:
+		return policy.KeyTypePublic
+	}
+}
+
+// readScopeFromContext builds the document read scope for a request against a collection
+// Write key holders bypass the collection's row-level read filter
+func readScopeFromContext(r *http.Request, schema *models.Schema) (*database.ReadScope, error) {
+	scope := &database.ReadScope{Visible: visibleLevelsFromContext(r), Masked: maskedFields(r, schema)}
+	if schema.ReadFilter == "" || isWriteKeyFromContext(r) {
+		return scope, nil
+	}
+
+	expr, err := policy.Parse(schema.ReadFilter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid read filter: %w", err)
+	}
+
+	keyType := keyTypeFromContext(r)
+	var claims map[string]interface{}
+	if tokenClaims, ok := r.Context().Value(contextKeyClaims).(*token.Claims); ok {
+		claims = tokenClaims.PolicyClaims()
+	}
+	scope.Allow = func(doc *models.Document) bool {
+		return expr.Eval(policy.Env{Data: doc.Data, Token: claims, KeyType: keyType})
+	}
+	return scope, nil
+}
+
+// visibleLevelsFromContext returns the document visibility levels the request may read
+func visibleLevelsFromContext(r *http.Request) []models.Visibility {
+	return models.VisibleLevels(isAuthenticatedFromContext(r), isWriteKeyFromContext(r))
+}
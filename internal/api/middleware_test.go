@@ -0,0 +1,239 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/models"
+)
+
+// mintToken mints an access token for the test database through the API
+func mintToken(t *testing.T, a *testAPI, request string) string {
+	t.Helper()
+	var minted models.TokenResponse
+	decodeBody(t, a.mustDo(http.MethodPost, "/tokens", testWriteKey, request, http.StatusCreated).Body.Bytes(), &minted)
+	return minted.Token
+}
+
+// owners returns the owner_id of each document a key can read from notes
+func owners(t *testing.T, a *testAPI, key string) []string {
+	t.Helper()
+	var docs []models.Document
+	decodeBody(t, a.mustDo(http.MethodGet, "/notes", key, "", http.StatusOK).Body.Bytes(), &docs)
+	var ids []string
+	for _, doc := range docs {
+		owner, _ := doc.Data["owner_id"].(string)
+		ids = append(ids, owner)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestReadFilter_TokenClaims(t *testing.T) {
+	a := newTestAPI(t, nil)
+	a.mustDo(http.MethodPost, "/schemas/notes", testWriteKey, `{"fields": {"owner_id": "string"}, "read_filter": "data.owner_id == token.sub"}`, http.StatusCreated)
+	for _, owner := range []string{"alice", "bob", "alice"} {
+		a.mustDo(http.MethodPost, "/notes", testWriteKey, `{"data": {"owner_id": "`+owner+`"}}`, http.StatusCreated)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want []string
+	}{
+		{"token for alice", mintToken(t, a, `{"caller": "alice"}`), []string{"alice", "alice"}},
+		{"token for bob", mintToken(t, a, `{"caller": "bob"}`), []string{"bob"}},
+		{"token without a caller", mintToken(t, a, `{}`), nil},
+		{"read key", testReadKey, nil},
+		{"write key", testWriteKey, []string{"alice", "alice", "bob"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := owners(t, a, tt.key); !slices.Equal(got, tt.want) {
+				t.Errorf("readable owners = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The filter also applies to single-document reads
+	var all []models.Document
+	decodeBody(t, a.mustDo(http.MethodGet, "/notes", testWriteKey, "", http.StatusOK).Body.Bytes(), &all)
+	bobToken := mintToken(t, a, `{"caller": "bob"}`)
+	for _, doc := range all {
+		want := http.StatusNotFound
+		if doc.Data["owner_id"] == "bob" {
+			want = http.StatusOK
+		}
+		a.mustDo(http.MethodGet, "/notes/"+doc.ID, bobToken, "", want)
+	}
+}
+
+func TestAllowlist_AnonymousReads(t *testing.T) {
+	a := newTestAPI(t, nil)
+	a.mustDo(http.MethodPost, "/schemas/notes", testWriteKey, `{"fields": {"title": "string"}}`, http.StatusCreated)
+	a.mustDo(http.MethodPost, "/notes", testWriteKey, `{"data": {"title": "hello"}, "visibility": "public"}`, http.StatusCreated)
+	a.mustDo(http.MethodGet, "/notes", "", "", http.StatusOK)
+
+	// The API refuses lists leaving out the requester, so the test address is kept out directly
+	if err := a.catalog.SetAllowedNetworks(testDatabaseID, []string{"198.51.100.0/24"}); err != nil {
+		t.Fatalf("SetAllowedNetworks: %v", err)
+	}
+	a.mustDo(http.MethodGet, "/notes", "", "", http.StatusForbidden)
+	a.mustDo(http.MethodGet, "/notes", testReadKey, "", http.StatusForbidden)
+
+	r := a.newRequest(http.MethodGet, "/notes", "", "")
+	r.RemoteAddr = "198.51.100.7:1234"
+	if w := a.serve(r); w.Code != http.StatusOK {
+		t.Errorf("anonymous read from an allowed network: status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestDatabaseGate(t *testing.T) {
+	a := newTestAPI(t, func(cfg *config.Config) {
+		cfg.CORSOrigins = []string{"https://app.example.com"}
+		cfg.Lockout = config.LockoutConfig{Threshold: 1, MaxDelay: time.Minute}
+	})
+	a.mustDo(http.MethodPost, "/schemas/notes", testWriteKey, `{"fields": {"title": "string"}}`, http.StatusCreated)
+	a.mustDo(http.MethodPost, "/notes", testWriteKey, `{"data": {"title": "hello"}, "visibility": "public"}`, http.StatusCreated)
+
+	// Origins apply to both route groups, with and without a key
+	fromOrigin := func(key, origin string) int {
+		r := a.newRequest(http.MethodGet, "/notes", key, "")
+		r.Header.Set("Origin", origin)
+		return a.serve(r).Code
+	}
+	for _, key := range []string{"", testReadKey} {
+		if got := fromOrigin(key, "https://app.example.com"); got != http.StatusOK {
+			t.Errorf("key %q from CORS_ORIGINS: status %d, want %d", key, got, http.StatusOK)
+		}
+		if got := fromOrigin(key, "https://other.example.com"); got != http.StatusForbidden {
+			t.Errorf("key %q from another origin: status %d, want %d", key, got, http.StatusForbidden)
+		}
+	}
+	a.mustDo(http.MethodPut, "/cors", testWriteKey, `{"origins": ["https://other.example.com"]}`, http.StatusOK)
+	if got := fromOrigin("", "https://other.example.com"); got != http.StatusOK {
+		t.Errorf("anonymous read from the database's origin: status %d, want %d", got, http.StatusOK)
+	}
+
+	// A locked out client can't read anonymously either
+	for range 2 {
+		a.mustDo(http.MethodGet, "/notes", "rk_wrong", "", http.StatusUnauthorized)
+	}
+	a.mustDo(http.MethodGet, "/notes", "", "", http.StatusTooManyRequests)
+	a.mustDo(http.MethodGet, "/schemas/notes", testWriteKey, "", http.StatusTooManyRequests)
+}
+
+func TestScopedKey_Restrictions(t *testing.T) {
+	a := newTestAPI(t, nil)
+	for _, collection := range []string{"notes", "tasks"} {
+		a.mustDo(http.MethodPost, "/schemas/"+collection, testWriteKey, `{"fields": {"text": "string"}}`, http.StatusCreated)
+	}
+	scopedKey := func(request string) string {
+		var key models.ScopedKey
+		decodeBody(t, a.mustDo(http.MethodPost, "/keys", testWriteKey, request, http.StatusCreated).Body.Bytes(), &key)
+		return key.Key
+	}
+	writer := scopedKey(`{"access": "write", "collections": ["notes"]}`)
+	reader := scopedKey(`{"access": "read", "collections": ["notes"]}`)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		body   string
+		want   int
+	}{
+		{"write in its collection", http.MethodPost, "/notes", writer, `{"data": {"text": "hi"}}`, http.StatusCreated},
+		{"read in its collection", http.MethodGet, "/notes", reader, "", http.StatusOK},
+		{"write in another collection", http.MethodPost, "/tasks", writer, `{"data": {"text": "hi"}}`, http.StatusForbidden},
+		{"read in another collection", http.MethodGet, "/tasks", reader, "", http.StatusForbidden},
+		{"write with a read key", http.MethodPost, "/notes", reader, `{"data": {"text": "hi"}}`, http.StatusForbidden},
+		{"database-wide route", http.MethodGet, "/keys", writer, "", http.StatusForbidden},
+		{"schema of another collection", http.MethodGet, "/schemas/tasks", writer, "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := a.do(tt.method, tt.path, tt.key, tt.body); w.Code != tt.want {
+				t.Errorf("%s %s = %d %s, want %d", tt.method, tt.path, w.Code, w.Body, tt.want)
+			}
+		})
+	}
+
+	// Tokens restricted to collections are held to them the same way
+	token := mintToken(t, a, `{"access": "write", "collections": ["notes"]}`)
+	a.mustDo(http.MethodGet, "/notes", token, "", http.StatusOK)
+	a.mustDo(http.MethodGet, "/tasks", token, "", http.StatusForbidden)
+}
+
+func TestToken_RevokedByRotation(t *testing.T) {
+	a := newTestAPI(t, nil)
+	a.mustDo(http.MethodPost, "/schemas/notes", testWriteKey, `{"fields": {"text": "string"}}`, http.StatusCreated)
+	before := mintToken(t, a, `{}`)
+	a.mustDo(http.MethodGet, "/notes", before, "", http.StatusOK)
+
+	var rotated models.RotateKeysResponse
+	decodeBody(t, a.mustDo(http.MethodPost, "/keys/rotate", testWriteKey, `{"write": true, "read": true}`, http.StatusOK).Body.Bytes(), &rotated)
+	a.mustDo(http.MethodGet, "/notes", before, "", http.StatusUnauthorized)
+	a.mustDo(http.MethodGet, "/notes", testReadKey, "", http.StatusUnauthorized)
+
+	var minted models.TokenResponse
+	decodeBody(t, a.mustDo(http.MethodPost, "/tokens", rotated.WriteKey, `{}`, http.StatusCreated).Body.Bytes(), &minted)
+	a.mustDo(http.MethodGet, "/notes", minted.Token, "", http.StatusOK)
+}
+
+func TestSignedURL_BoundToMethodAndPath(t *testing.T) {
+	a := newTestAPI(t, nil)
+	a.mustDo(http.MethodPost, "/schemas/notes", testWriteKey, `{"fields": {"text": "string"}}`, http.StatusCreated)
+	id := createNote(t, a)
+	other := createNote(t, a)
+
+	var signed models.SignedURL
+	decodeBody(t, a.mustDo(http.MethodPost, "/signed-urls", testWriteKey, `{"collection": "notes", "document_id": "`+id+`"}`, http.StatusCreated).Body.Bytes(), &signed)
+	open := func(method, target string) int {
+		return a.serve(httptest.NewRequest(method, target, nil)).Code
+	}
+
+	if got := open(http.MethodGet, signed.URL); got != http.StatusOK {
+		t.Fatalf("GET signed URL: status %d, want %d", got, http.StatusOK)
+	}
+	for _, method := range []string{http.MethodDelete, http.MethodPut} {
+		if got := open(method, signed.URL); got != http.StatusUnauthorized {
+			t.Errorf("%s signed URL: status %d, want %d", method, got, http.StatusUnauthorized)
+		}
+	}
+	if got := open(http.MethodGet, strings.Replace(signed.URL, id, other, 1)); got != http.StatusUnauthorized {
+		t.Errorf("signature on another document: status %d, want %d", got, http.StatusUnauthorized)
+	}
+	if got := open(http.MethodGet, strings.Replace(signed.URL, "/notes/"+id, "/notes", 1)); got != http.StatusUnauthorized {
+		t.Errorf("signature on the collection: status %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestLockout_AfterFailures(t *testing.T) {
+	a := newTestAPI(t, func(cfg *config.Config) {
+		cfg.Lockout = config.LockoutConfig{Threshold: 3, MaxDelay: time.Minute}
+	})
+	a.mustDo(http.MethodPost, "/schemas/notes", testWriteKey, `{"fields": {"text": "string"}}`, http.StatusCreated)
+
+	// The threshold's failures go unpunished, the next one locks the client out
+	for range 4 {
+		a.mustDo(http.MethodGet, "/notes", "rk_wrongkey", "", http.StatusUnauthorized)
+	}
+	w := a.mustDo(http.MethodGet, "/notes", testReadKey, "", http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("lockout response has no Retry-After header")
+	}
+
+	// Other clients aren't affected
+	r := a.newRequest(http.MethodGet, "/notes", testReadKey, "")
+	r.RemoteAddr = "198.51.100.7:1234"
+	if w := a.serve(r); w.Code != http.StatusOK {
+		t.Errorf("read from another client: status %d, want %d", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"jsondrop/internal/clientaddr"
+	"jsondrop/internal/mirror"
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateMirror handles POST /api/databases/:id/:collection/mirrors
+// The target collection is created with the mirrored fields and filled before responding
+func (h *Handler) CreateMirror(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	if collection == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Collection name is required")
+		return
+	}
+
+	var req models.CreateMirrorRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.TargetWriteKey == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Target write key is required")
+		return
+	}
+	if req.TargetCollection == "" {
+		req.TargetCollection = collection
+	}
+	if req.Visibility == "" {
+		req.Visibility = models.VisibilityPublic
+	}
+	if !req.Visibility.IsValid() {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Invalid visibility: "+string(req.Visibility))
+		return
+	}
+	if _, err := mirror.ParseFilter(req.Filter); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+
+	// The target key is guessed like any other, so failures count toward the lockout,
+	// and it only works from the target database's allowed networks
+	addr, _ := clientaddr.FromRequest(r, h.cfg.TrustedProxies)
+	if wait := h.lockout.Wait(addr, time.Now()); wait > 0 {
+		respondLockedOut(w, wait)
+		return
+	}
+	target, err := h.catalog.GetDatabaseByWriteKey(req.TargetWriteKey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify target database")
+		return
+	}
+	if target == nil {
+		h.lockout.Fail(addr, time.Now())
+		respondError(w, http.StatusForbidden, "Forbidden", "Invalid target write key")
+		return
+	}
+	if !networkAllowed(r, target, h.cfg.TrustedProxies) {
+		respondError(w, http.StatusForbidden, "Forbidden", "Requests from this address are not allowed for the target database")
+		return
+	}
+
+	m := &models.Mirror{
+		DatabaseID:       db.ID,
+		Collection:       collection,
+		TargetDatabaseID: target.ID,
+		TargetCollection: req.TargetCollection,
+		Fields:           req.Fields,
+		Filter:           req.Filter,
+		Visibility:       req.Visibility,
+	}
+	if err := h.catalog.CreateMirror(m); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "schema not found"):
+			respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		case strings.Contains(err.Error(), "already exists"):
+			respondError(w, http.StatusConflict, "Conflict", err.Error())
+		case strings.Contains(err.Error(), "limit exceeded"):
+			respondError(w, http.StatusUnprocessableEntity, "Unprocessable Entity", err.Error())
+		case strings.HasPrefix(err.Error(), "invalid"):
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		}
+		return
+	}
+
+	copied, err := h.mirrors.Sync(m)
+	if err != nil {
+		// Rollback: drop the target collection CreateMirror made, and the mirror with it
+		if err := h.catalog.RollBackMirror(m); err != nil {
+			log.Printf("mirror %s: failed to roll back: %v", m.ID, err)
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to copy documents: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, models.CreateMirrorResponse{Mirror: *m, Copied: copied})
+}
+
+// ListMirrors handles GET /api/databases/:id/:collection/mirrors
+func (h *Handler) ListMirrors(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	mirrors, err := h.catalog.ListMirrors(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mirrors)
+}
+
+// DeleteMirror handles DELETE /api/databases/:id/:collection/mirrors/:mirrorId
+// The target collection keeps its documents and becomes writable
+func (h *Handler) DeleteMirror(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	mirrorID := chi.URLParam(r, "mirrorId")
+	if err := h.catalog.DeleteMirror(db.ID, collection, mirrorID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mirrorReadOnly is middleware for writes to a collection, which mirror targets refuse
+// The collection is the {collection} URL parameter, or {name} on schema routes
+// Deleting a mirror target's schema stays allowed, and stops the mirror
+func (h *Handler) mirrorReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		db := getDatabaseFromContext(r)
+		if db == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		collection := chi.URLParam(r, "collection")
+		if collection == "" {
+			collection = chi.URLParam(r, "name")
+		}
+		readOnly, err := h.isMirrorTarget(db.ID, collection)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+			return
+		}
+		if readOnly {
+			respondError(w, http.StatusConflict, "Conflict", "Collection is a read-only mirror: "+collection)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isMirrorTarget reports whether a collection is written by a mirror, and so read-only
+func (h *Handler) isMirrorTarget(dbID string, collection string) (bool, error) {
+	if h.catalog == nil {
+		return false, nil
+	}
+	m, err := h.catalog.GetMirrorInto(dbID, collection)
+	return m != nil, err
+}
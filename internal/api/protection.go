@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// confirmationTTL is how long the token confirming a protected deletion works
+const confirmationTTL = 5 * time.Minute
+
+// codeConfirmationRequired marks deletions refused until repeated with a confirmation token
+const codeConfirmationRequired = "confirmation_required"
+
+// GetProtection handles GET /api/databases/:id/protection
+func (h *Handler) GetProtection(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.Protection{DatabaseID: db.ID, Protected: db.Protected})
+}
+
+// SetProtection handles PUT /api/databases/:id/protection
+// A protected database, and its schemas, are only deleted by requests repeated with the
+// confirmation token their first attempt was answered with, or by the admin endpoints
+func (h *Handler) SetProtection(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.SetProtectionRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Protected == nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "protected is required")
+		return
+	}
+
+	if err := h.store.SetProtected(db.ID, *req.Protected); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.Protection{DatabaseID: db.ID, Protected: *req.Protected})
+}
+
+// confirmDeletion reports whether a deletion may go ahead: always for unprotected
+// databases, and for protected ones when ?confirm= carries a token issued for the same
+// deletion. Otherwise it responds 409 with a token to repeat the request with
+func (h *Handler) confirmDeletion(w http.ResponseWriter, r *http.Request, db *models.Database, action string, what string) bool {
+	if !db.Protected {
+		return true
+	}
+	now := time.Now()
+	minutes := int(confirmationTTL.Minutes())
+	message := fmt.Sprintf("Database is protected: repeat the request with ?confirm= set to the confirmation within %d minutes to delete %s", minutes, what)
+	if confirm := r.URL.Query().Get("confirm"); confirm != "" {
+		err := h.tokens.VerifyConfirmation(confirm, db.ID, action, now)
+		if err == nil {
+			return true
+		}
+		message = fmt.Sprintf("Confirmation rejected (%v): repeat the request with the new confirmation within %d minutes to delete %s", err, minutes, what)
+	}
+	respondJSON(w, http.StatusConflict, models.ErrorResponse{
+		Error:        "Conflict",
+		Message:      message,
+		Code:         codeConfirmationRequired,
+		Confirmation: h.tokens.Confirmation(db.ID, action, now.Add(confirmationTTL)),
+	})
+	return false
+}
+
+// ForceDeleteDatabase handles DELETE /api/admin/databases/:id
+// Deletes a database whether or not it is protected
+func (h *Handler) ForceDeleteDatabase(w http.ResponseWriter, r *http.Request) {
+	db := h.lookupDatabase(w, r)
+	if db == nil {
+		return
+	}
+	h.deleteDatabase(w, db)
+}
+
+// ForceDeleteSchema handles DELETE /api/admin/databases/:id/schemas/:name
+// Deletes a schema whether or not its database is protected
+func (h *Handler) ForceDeleteSchema(w http.ResponseWriter, r *http.Request) {
+	db := h.lookupDatabase(w, r)
+	if db == nil {
+		return
+	}
+	h.deleteSchema(w, db, chi.URLParam(r, "name"))
+}
+
+// lookupDatabase loads the database named in an admin route, or responds 404
+func (h *Handler) lookupDatabase(w http.ResponseWriter, r *http.Request) *models.Database {
+	db, err := h.store.GetDatabaseByID(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return nil
+	}
+	if db == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Database not found")
+		return nil
+	}
+	return db
+}
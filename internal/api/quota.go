@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"jsondrop/internal/database"
+)
+
+// Quota headers reported on successful writes, in bytes
+const (
+	headerQuotaUsed      = "X-Quota-Used"
+	headerQuotaRemaining = "X-Quota-Remaining"
+)
+
+// quotaHeaders reports the database's storage usage on every successful write,
+// so clients can show remaining space without polling the info endpoint
+// Usage is read just before the status is sent, after the handler has applied the write
+func quotaHeaders(store database.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			db := getDatabaseFromContext(r)
+			if db == nil || r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(&quotaWriter{ResponseWriter: w, store: store, dbID: db.ID}, r)
+		})
+	}
+}
+
+// quotaWriter adds quota headers to a successful response before its status is written
+type quotaWriter struct {
+	http.ResponseWriter
+	store       database.Store
+	dbID        string
+	wroteHeader bool
+}
+
+// WriteHeader sets the quota headers on 2xx responses, then writes the status
+func (w *quotaWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status >= 200 && status < 300 {
+			w.setQuotaHeaders()
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write sends an implicit 200 status through WriteHeader so the headers are added
+func (w *quotaWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *quotaWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// setQuotaHeaders looks up current usage; a deleted database gets no headers
+func (w *quotaWriter) setQuotaHeaders() {
+	db, err := w.store.GetDatabaseByID(w.dbID)
+	if err != nil || db == nil {
+		return
+	}
+	remaining := db.QuotaLimit - db.QuotaUsed
+	if remaining < 0 {
+		remaining = 0
+	}
+	w.Header().Set(headerQuotaUsed, strconv.FormatInt(db.QuotaUsed, 10))
+	w.Header().Set(headerQuotaRemaining, strconv.FormatInt(remaining, 10))
+}
+
+// RecalculateQuota handles POST /api/databases/:id/recalculate-quota
+// Recomputes the database's quota usage from its stored documents, correcting any drift
+func (h *Handler) RecalculateQuota(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	result, err := h.store.RecalculateQuota(db.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "database not found") {
+			respondError(w, http.StatusNotFound, "Not Found", "Database not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SetRetention handles PUT /api/databases/:id/schemas/:name/retention
+// Documents older than the period are deleted by the retention job, not right away
+func (h *Handler) SetRetention(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	schemaName := chi.URLParam(r, "name")
+	if schemaName == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Schema name is required")
+		return
+	}
+
+	var req models.SetRetentionRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	schema, err := h.catalog.SetRetention(db.ID, schemaName, req.RetentionDays)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+		case strings.HasPrefix(err.Error(), "invalid"):
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, schema)
+}
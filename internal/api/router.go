@@ -2,53 +2,245 @@ package api
 
 import (
 	"net/http"
+	"strings"
 
+	"jsondrop/internal/accesslog"
+	"jsondrop/internal/config"
 	"jsondrop/internal/database"
+	"jsondrop/internal/signing"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
 // NewRouter creates and configures the HTTP router
-func NewRouter(handler *Handler, catalog *database.CatalogDB, corsOrigins []string) *chi.Mux {
+// console and accessLog may be nil when the web console or the access log are disabled
+func NewRouter(handler *Handler, admin *AdminHandler, console http.Handler, store database.Store, cfg *config.Config, accessLog *accesslog.Logger) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Middleware
+	if accessLog != nil {
+		r.Use(accessLog.Middleware)
+	}
 	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(corsMiddleware(corsOrigins))
+	if cfg.DevMode {
+		r.Use(devErrors)
+	} else {
+		r.Use(middleware.Recoverer)
+	}
+	r.Use(corsMiddleware(cfg.CORSOrigins, databaseCORSOrigins(store, cfg.BasePath)))
+	r.Use(limitBody(cfg.MaxBodyBytes))
+
+	// Routes are mounted under BASE_PATH when sharing a host behind a reverse proxy
+	routes := r
+	if cfg.BasePath != "" {
+		routes = chi.NewRouter()
+	}
+
+	// Fault injection for testing client retry logic (never enable in production)
+	if cfg.Faults.Enabled {
+		r.Use(faultMiddleware(cfg.Faults, cfg.BasePath, routes))
+	}
 
 	// Routes
-	r.Route("/api", func(r chi.Router) {
+	if cfg.BasePath != "" {
+		r.Mount(cfg.BasePath, routes)
+	}
+	if console != nil {
+		routes.Mount("/console", console)
+	}
+	routes.Route("/api", func(r chi.Router) {
+		// Server limits and capabilities (no auth required)
+		r.Get("/meta", handler.GetMeta)
+
 		// Database creation (no auth required)
 		r.Post("/databases", handler.CreateDatabase)
 
+		// Shared documents and queries (the share link's token is the credential)
+		r.With(handler.sqliteOnly).Get("/shared/{token}", handler.OpenShareLink)
+
+		// Operator endpoints (ADMIN_KEY required)
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(adminMiddleware(cfg.AdminKey))
+
+			// Event streams are served by every storage backend
+			r.Get("/streams", admin.Streams)
+			r.Get("/events", handler.StreamAllEvents)
+
+			// Deletions that don't need confirming, even for protected databases
+			r.Delete("/databases/{id}", handler.ForceDeleteDatabase)
+			r.Delete("/databases/{id}/schemas/{name}", handler.ForceDeleteSchema)
+
+			r.Group(func(r chi.Router) {
+				r.Use(handler.sqliteOnly)
+
+				r.Post("/fixtures/reload", admin.ReloadFixtures)
+				r.Get("/diagnostics", admin.Diagnostics)
+				r.Get("/stats", admin.Stats)
+				r.Post("/fsck", admin.Fsck)
+
+				r.Get("/databases", admin.ListDatabases)
+				r.Put("/databases/{id}/quota", admin.SetQuotaLimit)
+				r.Get("/databases/{id}/export", admin.ExportDatabase)
+				r.Post("/databases/{id}/import", admin.ImportDatabase)
+			})
+		})
+
 		// Authenticated routes
+		// Routes wrapped in sqliteOnly respond 501 Not Implemented with other storage backends
 		r.Route("/databases/{id}", func(r chi.Router) {
-			r.Use(authMiddleware(catalog))
+			// Lockout, allowed networks and origins apply to keyed and anonymous requests alike
+			r.Use(databaseGate(store, handler.authConfig()))
+
+			// Public document reads (no key required, only public documents are visible)
+			r.Group(func(r chi.Router) {
+				r.Use(publicAuthMiddleware(store, handler.authConfig()))
+				r.Use(handler.trackUsage)
+
+				r.Get("/{collection}", handler.QueryDocuments)
+				r.Get("/{collection}/", handler.QueryDocuments)
+				r.Get("/{collection}/{docId}", handler.GetDocument)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware(store, handler.authConfig()))
+				r.Use(quotaHeaders(store))
+				r.Use(handler.trackUsage)
+				r.Use(handler.auditRequests)
+
+				// Database deletion (write key required)
+				r.With(requireWriteKey).Delete("/", handler.DeleteDatabase)
+
+				// Confirmation required to delete the database or its schemas (write key required)
+				r.With(requireWriteKey).Get("/protection", handler.GetProtection)
+				r.With(requireWriteKey, requireAPIKey).Put("/protection", handler.SetProtection)
+
+				// Quota usage recomputed from stored documents (write key required)
+				r.With(requireWriteKey).Post("/recalculate-quota", handler.RecalculateQuota)
+
+				// Replacing leaked or retired keys (write key required)
+				r.With(requireWriteKey, requireAPIKey).Post("/keys/rotate", handler.RotateKeys)
+
+				// Keys restricted to some collections (write key required)
+				r.With(handler.sqliteOnly, requireWriteKey, requireAPIKey).Get("/keys", handler.ListScopedKeys)
+				r.With(handler.sqliteOnly, requireWriteKey, requireAPIKey).Post("/keys", handler.CreateScopedKey)
+				r.With(handler.sqliteOnly, requireWriteKey, requireAPIKey).Delete("/keys/{keyId}", handler.DeleteScopedKey)
 
-			// Database deletion (write key required)
-			r.With(requireWriteKey).Delete("/", handler.DeleteDatabase)
+				// Links sharing a document or query without a key (write key required)
+				r.With(handler.sqliteOnly, requireWriteKey).Get("/shares", handler.ListShareLinks)
+				r.With(handler.sqliteOnly, requireWriteKey).Delete("/shares/{shareId}", handler.DeleteShareLink)
 
-			// SSE endpoint for database events (read or write key)
-			r.Get("/events", handler.StreamDatabaseEvents)
+				// Short-lived access tokens standing in for the keys (write key required)
+				r.With(requireWriteKey, requireAPIKey).Post("/tokens", handler.CreateToken)
 
-			// Schema operations
-			r.With(requireWriteKey).Post("/schemas/{name}", handler.CreateSchema)
-			r.With(requireWriteKey).Delete("/schemas/{name}", handler.DeleteSchema)
+				// URLs reading a document or export without a key until they expire (write key required)
+				r.With(requireWriteKey, requireAPIKey).Post("/signed-urls", handler.CreateSignedURL)
+
+				// Browser origins allowed besides CORS_ORIGINS (write key required)
+				r.With(requireWriteKey).Get("/cors", handler.GetCORSOrigins)
+				r.With(requireWriteKey, requireAPIKey).Put("/cors", handler.SetCORSOrigins)
+
+				// Networks the keys and tokens work from (write key required)
+				r.With(requireWriteKey).Get("/allowlist", handler.GetAllowlist)
+				r.With(requireWriteKey, requireAPIKey).Put("/allowlist", handler.SetAllowlist)
+
+				// Authenticated requests that changed the database, or tried to (write key required)
+				r.With(handler.sqliteOnly, requireWriteKey).Get("/audit", handler.AuditLog)
+
+				// Requests, bytes written and events consumed by key type (write key required)
+				r.With(requireWriteKey).Get("/usage", handler.GetUsage)
+
+				// Whole-database archive (write key required)
+				r.With(handler.sqliteOnly, requireWriteKey).Get("/export", handler.ExportDatabase)
+				r.With(handler.sqliteOnly, requireWriteKey).Post("/import", handler.ImportDatabase)
+
+				// Data subject export and erasure across collections
+				r.With(handler.sqliteOnly, requireWriteKey).Get("/subjects/export", handler.ExportSubject)
+				r.With(handler.sqliteOnly, requireWriteKey).Post("/subjects/erase", handler.EraseSubject)
+				r.With(handler.sqliteOnly, requireWriteKey).Get("/subjects/erasures", handler.ListErasures)
+
+				// SSE endpoint for database events and acknowledgments from its clients (read or write key)
+				r.Get("/events", handler.StreamDatabaseEvents)
+				r.Post("/events/ack", handler.AckEvents)
+
+				// Open event streams and what they subscribed to (write key required)
+				r.With(requireWriteKey).Get("/listeners", handler.ListListeners)
+
+				// Event log, paged from a sequence number or time (read or write key)
+				r.With(handler.sqliteOnly).Get("/events/history", handler.EventHistory)
+
+				// WebSocket endpoint for database events (read or write key)
+				r.Get("/ws", handler.DatabaseEventsSocket)
+
+				// Webhooks delivering events to other servers (write key required)
+				r.With(handler.sqliteOnly, requireWriteKey).Get("/webhooks", handler.ListWebhooks)
+				r.With(handler.sqliteOnly, requireWriteKey).Post("/webhooks", handler.CreateWebhook)
+				r.With(handler.sqliteOnly, requireWriteKey).Delete("/webhooks/{webhookId}", handler.DeleteWebhook)
+				r.With(handler.sqliteOnly, requireWriteKey).Get("/webhooks/{webhookId}/dead-letters", handler.ListWebhookDeadLetters)
+				r.With(handler.sqliteOnly, requireWriteKey).Post("/webhooks/{webhookId}/dead-letters/{deliveryId}/replay", handler.ReplayWebhookDeadLetter)
+				r.With(handler.sqliteOnly, requireWriteKey).Delete("/webhooks/{webhookId}/dead-letters/{deliveryId}", handler.DeleteWebhookDeadLetter)
+
+				// Collection listing with stats (read or write key)
+				r.With(handler.sqliteOnly).Get("/collections", handler.ListCollections)
+
+				// Best-effort batch of document writes, reported per operation (write key required)
+				r.With(requireWriteKey).Post("/batch", handler.Batch)
+
+				// Schema operations
+				r.With(requireWriteKey).Post("/schemas/{name}", handler.CreateSchema)
+				r.With(handler.sqliteOnly, requireWriteKey, handler.mirrorReadOnly).Put("/schemas/{name}", handler.UpdateSchema)
+				r.With(requireWriteKey).Delete("/schemas/{name}", handler.DeleteSchema)
+				r.With(requireWriteKey).Put("/schemas/{name}/policy", handler.SetReadFilter)
+				r.With(handler.sqliteOnly, requireWriteKey, handler.mirrorReadOnly).Put("/schemas/{name}/retention", handler.SetRetention)
+				r.With(handler.sqliteOnly, requireWriteKey, handler.mirrorReadOnly).Put("/schemas/{name}/deprecations/{field}", handler.DeprecateField)
+				r.With(handler.sqliteOnly, requireWriteKey, handler.mirrorReadOnly).Delete("/schemas/{name}/deprecations/{field}", handler.UndeprecateField)
+				r.With(handler.sqliteOnly, requireWriteKey, handler.mirrorReadOnly).Put("/schemas/{name}/masked/{field}", handler.MaskField)
+				r.With(handler.sqliteOnly, requireWriteKey, handler.mirrorReadOnly).Delete("/schemas/{name}/masked/{field}", handler.UnmaskField)
+				r.With(handler.sqliteOnly, requireWriteKey, handler.mirrorReadOnly).Post("/schemas/{name}/rename", handler.RenameSchema)
 
-			// Collection-specific routes
-			r.Route("/{collection}", func(r chi.Router) {
 				// SSE endpoint for collection-specific events (read or write key)
-				r.Get("/events", handler.StreamCollectionEvents)
+				r.Get("/{collection}/events", handler.StreamCollectionEvents)
 
-				// Query documents (read or write key)
-				r.Get("/", handler.QueryDocuments)
+				// WebSocket endpoint for collection-specific events (read or write key)
+				r.Get("/{collection}/ws", handler.CollectionEventsSocket)
 
-				// Document operations (write key required)
-				r.With(requireWriteKey).Post("/", handler.InsertDocument)
-				r.With(requireWriteKey).Put("/{docId}", handler.UpdateDocument)
-				r.With(requireWriteKey).Delete("/{docId}", handler.DeleteDocument)
+				// Polling change feed, for clients behind proxies that break event streams
+				r.With(handler.sqliteOnly).Get("/{collection}/changes", handler.Changes)
+
+				// Bulk export (read or write key)
+				r.Get("/{collection}/export", handler.ExportCollection)
+
+				// Document and index sizes (write key required)
+				r.With(handler.sqliteOnly, requireWriteKey).Get("/{collection}/stats", handler.GetCollectionStats)
+
+				// Histogram aggregation (read or write key)
+				r.With(handler.sqliteOnly).Get("/{collection}/aggregate", handler.AggregateDocuments)
+
+				// Analytical SQL queries (read or write key)
+				r.Post("/{collection}/analytics", handler.QueryAnalytics)
+
+				// User-declared indexes (listing with read or write key, changes with write key)
+				r.With(handler.sqliteOnly).Get("/{collection}/indexes", handler.ListIndexes)
+				r.With(handler.sqliteOnly, requireWriteKey).Post("/{collection}/indexes", handler.CreateIndex)
+				r.With(handler.sqliteOnly, requireWriteKey).Delete("/{collection}/indexes/{name}", handler.DeleteIndex)
+
+				// Read-only mirrors into other databases (write key required)
+				r.With(handler.sqliteOnly, requireWriteKey).Get("/{collection}/mirrors", handler.ListMirrors)
+				r.With(handler.sqliteOnly, requireWriteKey).Post("/{collection}/mirrors", handler.CreateMirror)
+				r.With(handler.sqliteOnly, requireWriteKey).Delete("/{collection}/mirrors/{mirrorId}", handler.DeleteMirror)
+
+				// Share links to a query's results or a document (write key required)
+				r.With(handler.sqliteOnly, requireWriteKey, requireAPIKey).Post("/{collection}/share", handler.CreateShareLink)
+				r.With(handler.sqliteOnly, requireWriteKey, requireAPIKey).Post("/{collection}/{docId}/share", handler.CreateShareLink)
+
+				// Document operations (write key required; mirror targets are read-only)
+				r.With(requireWriteKey, handler.mirrorReadOnly).Post("/{collection}", handler.InsertDocument)
+				r.With(requireWriteKey, handler.mirrorReadOnly).Post("/{collection}/", handler.InsertDocument)
+				r.With(handler.sqliteOnly, requireWriteKey, handler.mirrorReadOnly).Post("/{collection}/import", handler.ImportDocuments)
+				r.With(handler.sqliteOnly, requireWriteKey, handler.mirrorReadOnly).Post("/{collection}/purge", handler.PurgeDocuments)
+				r.With(requireWriteKey, handler.mirrorReadOnly).Put("/{collection}/{docId}", handler.UpdateDocument)
+				r.With(handler.sqliteOnly, requireWriteKey, handler.mirrorReadOnly).Post("/{collection}/{docId}/restore", handler.RestoreDocument)
+				r.With(requireWriteKey, handler.mirrorReadOnly).Delete("/{collection}/{docId}", handler.DeleteDocument)
 			})
 		})
 	})
@@ -57,7 +249,8 @@ func NewRouter(handler *Handler, catalog *database.CatalogDB, corsOrigins []stri
 }
 
 // corsMiddleware adds CORS headers to responses
-func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
+// Origins outside allowedOrigins may still be allowed by the database the request is for
+func corsMiddleware(allowedOrigins []string, databaseOrigins func(*http.Request) []string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			origin := r.Header.Get("Origin")
@@ -76,11 +269,19 @@ func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 					}
 				}
 			}
+			if !allowed && origin != "" && originAllowed(databaseOrigins(r), origin) {
+				allowed = true
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
 
 			if allowed {
 				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, Last-Event-ID, "+
+					signing.HeaderCredential+", "+signing.HeaderTimestamp+", "+signing.HeaderNonce+", "+signing.HeaderSignature+", "+
+					headerChallenge+", "+headerSolution+", "+headerCaptcha)
 				w.Header().Set("Access-Control-Max-Age", "3600")
+				w.Header().Set("Access-Control-Expose-Headers", headerQuotaUsed+", "+headerQuotaRemaining+", ETag, Warning")
 			}
 
 			// Handle preflight requests
@@ -93,3 +294,23 @@ func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// databaseCORSOrigins returns a lookup of the CORS origins of the database a request's
+// path names, for corsMiddleware, which runs before routing and so before authentication
+func databaseCORSOrigins(store database.Store, basePath string) func(*http.Request) []string {
+	return func(r *http.Request) []string {
+		rest, ok := strings.CutPrefix(r.URL.Path, basePath+"/api/databases/")
+		if !ok {
+			return nil
+		}
+		dbID, _, _ := strings.Cut(rest, "/")
+		if database.ValidateDatabaseID(dbID) != nil {
+			return nil
+		}
+		db, err := store.GetDatabaseByID(dbID)
+		if err != nil || db == nil {
+			return nil
+		}
+		return db.CORSOrigins
+	}
+}
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"jsondrop/internal/clientaddr"
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Share links bounds: how long links last by default and at most, and how long a shared
+// query string may be
+const (
+	defaultShareTTL = 24 * time.Hour
+	maxShareTTL     = 30 * 24 * time.Hour
+	maxShareQuery   = 2048
+)
+
+// unsharedQueryParams are the query parameters share links can't carry: the key, and
+// reads beyond the shared collection's live documents
+var unsharedQueryParams = []string{"key", "deleted", "join"}
+
+// CreateShareLink handles POST /api/databases/:id/:collection/share and
+// POST /api/databases/:id/:collection/:docId/share
+// Shares a document, or the results of the query in the body, with whoever holds the
+// returned token; the token isn't shown again
+func (h *Handler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.CreateShareLinkRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl == 0 {
+		ttl = defaultShareTTL
+	}
+	if ttl < 0 || ttl > maxShareTTL {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("ttl_seconds must be between 1 and %d", int(maxShareTTL.Seconds())))
+		return
+	}
+
+	link := &models.ShareLink{
+		DatabaseID: db.ID,
+		Collection: chi.URLParam(r, "collection"),
+		DocumentID: chi.URLParam(r, "docId"),
+		Query:      strings.TrimPrefix(req.Query, "?"),
+		SingleUse:  req.SingleUse,
+		ExpiresAt:  time.Now().Add(ttl),
+	}
+	if link.DocumentID != "" {
+		if link.Query != "" {
+			respondError(w, http.StatusBadRequest, "Bad Request", "query can only be given when sharing a collection query")
+			return
+		}
+		if !h.shareableDocument(w, r, db, link) {
+			return
+		}
+	} else if !validShareQuery(w, link.Query) {
+		return
+	}
+
+	if err := h.catalog.CreateShareLink(link); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "schema not found"):
+			respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+link.Collection)
+		case strings.Contains(err.Error(), "limit exceeded"):
+			respondError(w, http.StatusUnprocessableEntity, "Unprocessable Entity", err.Error())
+		case strings.HasPrefix(err.Error(), "invalid"):
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		}
+		return
+	}
+	link.URL = h.cfg.BasePath + "/api/shared/" + link.Token
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusCreated, link)
+}
+
+// shareableDocument checks that a shared document exists, answering the request if not
+func (h *Handler) shareableDocument(w http.ResponseWriter, r *http.Request, db *models.Database, link *models.ShareLink) bool {
+	schema, err := h.store.GetSchema(db.ID, link.Collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return false
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+link.Collection)
+		return false
+	}
+	scope, err := readScopeFromContext(r, schema)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return false
+	}
+	doc, err := h.store.GetDocument(db.ID, link.Collection, link.DocumentID, scope)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return false
+	}
+	if doc == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "document not found")
+		return false
+	}
+	return true
+}
+
+// validShareQuery checks a query string to share, answering the request if it can't be
+// Its filters are checked against the schema when the link is opened
+func validShareQuery(w http.ResponseWriter, query string) bool {
+	if len(query) > maxShareQuery {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("query must be at most %d bytes", maxShareQuery))
+		return false
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "invalid query: "+err.Error())
+		return false
+	}
+	for _, param := range unsharedQueryParams {
+		if params.Has(param) {
+			respondError(w, http.StatusBadRequest, "Bad Request", "invalid query: "+param+" can't be shared")
+			return false
+		}
+	}
+	return true
+}
+
+// ListShareLinks handles GET /api/databases/:id/shares
+// The tokens themselves are left out
+func (h *Handler) ListShareLinks(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	links, err := h.catalog.ListShareLinks(db.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, links)
+}
+
+// DeleteShareLink handles DELETE /api/databases/:id/shares/:shareId
+func (h *Handler) DeleteShareLink(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	if err := h.catalog.DeleteShareLink(db.ID, chi.URLParam(r, "shareId")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// OpenShareLink handles GET /api/shared/:token
+// Serves the shared document or query results as a read key would see them: without
+// masked fields, write-key-only documents or those the read filter hides. A single-use
+// link is used up as it is opened, even if the document has since been deleted
+func (h *Handler) OpenShareLink(w http.ResponseWriter, r *http.Request) {
+	// Tokens are guessed like keys, so failures count toward the same lockout
+	addr, _ := clientaddr.FromRequest(r, h.cfg.TrustedProxies)
+	if wait := h.lockout.Wait(addr, time.Now()); wait > 0 {
+		respondLockedOut(w, wait)
+		return
+	}
+
+	token := chi.URLParam(r, "token")
+	var link *models.ShareLink
+	var err error
+	if strings.HasPrefix(token, "sh_") {
+		link, err = h.catalog.GetShareLink(token)
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to load share link")
+		return
+	}
+	if link == nil {
+		h.lockout.Fail(addr, time.Now())
+		respondError(w, http.StatusNotFound, "Not Found", "Share link not found")
+		return
+	}
+	if link.Expired(time.Now()) {
+		respondError(w, http.StatusGone, "Gone", "Share link expired")
+		return
+	}
+
+	db, err := h.store.GetDatabaseByID(link.DatabaseID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to load database")
+		return
+	}
+	if db == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Share link not found")
+		return
+	}
+	if !networkAllowed(r, db, h.cfg.TrustedProxies) {
+		respondError(w, http.StatusForbidden, "Forbidden", "Requests from this address are not allowed for this database")
+		return
+	}
+	if link.SingleUse {
+		if err := h.catalog.UseShareLink(link.ID, time.Now()); err != nil {
+			if strings.Contains(err.Error(), "already used") {
+				respondError(w, http.StatusGone, "Gone", "Share link already used")
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+			return
+		}
+	}
+	if err := h.store.UpdateLastAccessed(db.ID); err != nil {
+		// Log error but don't fail the request
+	}
+
+	// The shared read is served by the document handlers, as if made with a read key
+	// restricted to the link's collection
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", db.ID)
+	rctx.URLParams.Add("collection", link.Collection)
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+	ctx = context.WithValue(ctx, contextKeyDatabase, db)
+	ctx = context.WithValue(ctx, contextKeyIsWrite, false)
+	ctx = context.WithValue(ctx, contextKeyAuthenticated, true)
+	ctx = context.WithValue(ctx, contextKeyCollections, []string{link.Collection})
+	shared := r.WithContext(ctx)
+	shared.URL = &url.URL{Path: r.URL.Path, RawQuery: link.Query}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	if link.DocumentID != "" {
+		rctx.URLParams.Add("docId", link.DocumentID)
+		h.GetDocument(w, shared)
+		return
+	}
+	h.QueryDocuments(w, shared)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestShareLink_ReadOnly(t *testing.T) {
+	a := newTestAPI(t, nil)
+	a.mustDo(http.MethodPost, "/schemas/notes", testWriteKey, `{"fields": {"text": "string"}}`, http.StatusCreated)
+	id := createNote(t, a)
+
+	var link models.ShareLink
+	decodeBody(t, a.mustDo(http.MethodPost, "/notes/"+id+"/share", testWriteKey, `{}`, http.StatusCreated).Body.Bytes(), &link)
+	open := func(method string) int {
+		return a.serve(httptest.NewRequest(method, link.URL, nil)).Code
+	}
+
+	if got := open(http.MethodGet); got != http.StatusOK {
+		t.Fatalf("GET share link: status %d, want %d", got, http.StatusOK)
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		if got := open(method); got != http.StatusMethodNotAllowed {
+			t.Errorf("%s share link: status %d, want %d", method, got, http.StatusMethodNotAllowed)
+		}
+	}
+
+	// The link's token isn't a key either
+	a.mustDo(http.MethodGet, "/notes/"+id, link.Token, "", http.StatusUnauthorized)
+	a.mustDo(http.MethodPut, "/notes/"+id, link.Token, `{"data": {"text": "changed"}}`, http.StatusUnauthorized)
+	a.mustDo(http.MethodDelete, "/notes/"+id, link.Token, "", http.StatusUnauthorized)
+
+	var doc models.Document
+	decodeBody(t, a.mustDo(http.MethodGet, "/notes/"+id, testReadKey, "", http.StatusOK).Body.Bytes(), &doc)
+	if doc.Data["text"] != "draft" {
+		t.Errorf("document text = %v after share link writes, want draft", doc.Data["text"])
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+	"jsondrop/internal/signing"
+)
+
+// Signed URL bounds: how long URLs last by default and at most, and how long the query
+// of an export may be
+const (
+	defaultSignedURLTTL = time.Hour
+	maxSignedURLTTL     = 7 * 24 * time.Hour
+	maxSignedURLQuery   = 2048
+)
+
+// signedURLRoutes are the routes signed URLs open, by the end of their pattern; a URL
+// signed for a document whose ID matches another route still opens nothing else
+var signedURLRoutes = []string{"/databases/{id}/{collection}/{docId}", "/databases/{id}/{collection}/export", "/databases/{id}/export"}
+
+// CreateSignedURL handles POST /api/databases/:id/signed-urls
+// Signs a URL reading a document, or exporting a collection or the database, without a
+// key until it expires, for downloads embedded in emails or static sites. Signed URLs
+// can't be revoked one by one: rotating the keys revokes them all
+func (h *Handler) CreateSignedURL(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.CreateSignedURLRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Access == "" {
+		req.Access = models.KeyAccessRead
+	}
+	if req.Access != models.KeyAccessWrite && req.Access != models.KeyAccessRead {
+		respondError(w, http.StatusBadRequest, "Bad Request", "access must be write or read")
+		return
+	}
+	ttl := defaultSignedURLTTL
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl <= 0 || ttl > maxSignedURLTTL {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("ttl_seconds must be between 1 and %d", int(maxSignedURLTTL.Seconds())))
+		return
+	}
+	query, ok := signableQuery(w, req.Query)
+	if !ok {
+		return
+	}
+
+	path := h.cfg.BasePath + "/api/databases/" + db.ID
+	switch {
+	case req.Collection == "":
+		if req.DocumentID != "" {
+			respondError(w, http.StatusBadRequest, "Bad Request", "document_id can only be given with its collection")
+			return
+		}
+		if req.Access != models.KeyAccessWrite {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Exporting the database needs write access")
+			return
+		}
+		if !h.requireCatalog(w, "Database export") {
+			return
+		}
+		path += "/export"
+	case req.DocumentID != "":
+		if len(query) > 0 {
+			respondError(w, http.StatusBadRequest, "Bad Request", "query can only be given when signing an export")
+			return
+		}
+		if !h.signableDocument(w, r, db, req.Collection, req.DocumentID) {
+			return
+		}
+		path += "/" + req.Collection + "/" + req.DocumentID
+	default:
+		schema, err := h.store.GetSchema(db.ID, req.Collection)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+			return
+		}
+		if schema == nil {
+			respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+req.Collection)
+			return
+		}
+		path += "/" + req.Collection + "/export"
+	}
+
+	expires := time.Unix(time.Now().Add(ttl).Unix(), 0).UTC()
+	signed := signing.SignURL(db.WriteKey, path, query, req.Access, expires)
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusCreated, models.SignedURL{
+		URL:       (&url.URL{Path: path, RawQuery: signed.Encode()}).String(),
+		Method:    http.MethodGet,
+		Access:    req.Access,
+		ExpiresAt: expires,
+	})
+}
+
+// signableDocument checks that a document to sign a URL for exists, answering the
+// request if not
+func (h *Handler) signableDocument(w http.ResponseWriter, r *http.Request, db *models.Database, collection string, docID string) bool {
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return false
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		return false
+	}
+	doc, err := h.store.GetDocument(db.ID, collection, docID, nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return false
+	}
+	if doc == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "document not found")
+		return false
+	}
+	return true
+}
+
+// signableQuery parses the query of an export to sign, answering the request if it
+// can't be signed
+func signableQuery(w http.ResponseWriter, raw string) (url.Values, bool) {
+	raw = strings.TrimPrefix(raw, "?")
+	if len(raw) > maxSignedURLQuery {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("query must be at most %d bytes", maxSignedURLQuery))
+		return nil, false
+	}
+	query, err := url.ParseQuery(raw)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", "invalid query: "+err.Error())
+		return nil, false
+	}
+	for _, param := range []string{"key", signing.ParamAccess, signing.ParamExpires, signing.ParamSignature} {
+		if query.Has(param) {
+			respondError(w, http.StatusBadRequest, "Bad Request", "invalid query: "+param+" can't be signed")
+			return nil, false
+		}
+	}
+	return query, true
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RestoreDocument handles POST /api/databases/:id/:collection/:docId/restore
+func (h *Handler) RestoreDocument(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	docID := chi.URLParam(r, "docId")
+	if collection == "" || docID == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Collection name and document ID are required")
+		return
+	}
+
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		return
+	}
+
+	doc, err := h.catalog.RestoreDocument(db.ID, collection, docID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, doc)
+}
+
+// PurgeDocuments handles POST /api/databases/:id/:collection/purge
+// Soft-deleted documents are removed permanently; ?before= limits the purge to older deletions
+func (h *Handler) PurgeDocuments(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	if collection == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Collection name is required")
+		return
+	}
+
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		return
+	}
+
+	var before time.Time
+	if beforeStr := r.URL.Query().Get("before"); beforeStr != "" {
+		before, err = database.ParseTimestamp(beforeStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Bad Request", "invalid before: "+err.Error())
+			return
+		}
+	}
+
+	purged, err := h.catalog.PurgeDocuments(db.ID, collection, before)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.PurgeResponse{Purged: purged})
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"jsondrop/internal/archive"
+	"jsondrop/internal/models"
+)
+
+// ExportSubject handles GET /api/databases/:id/subjects/export?field=&value=
+// Streams the documents whose field equals value, across every collection with the field,
+// as an archive in the database export format
+func (h *Handler) ExportSubject(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	field := r.URL.Query().Get("field")
+	value := r.URL.Query().Get("value")
+	if field == "" || !r.URL.Query().Has("value") {
+		respondError(w, http.StatusBadRequest, "Bad Request", "field and value parameters are required")
+		return
+	}
+
+	filename := fmt.Sprintf("%s-subject.jsondrop.tar.gz", db.ID)
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if err := archive.WriteSubject(w, h.catalog, db.ID, field, value); err != nil {
+		w.Header().Del("Content-Disposition")
+		if strings.HasPrefix(err.Error(), "invalid subject") {
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to export subject: "+err.Error())
+		return
+	}
+}
+
+// EraseSubject handles POST /api/databases/:id/subjects/erase
+// Permanently deletes the documents whose field equals value, across every collection
+// with the field, and responds with the recorded receipt
+func (h *Handler) EraseSubject(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.EraseSubjectRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Field == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "field is required")
+		return
+	}
+	value, ok := subjectValue(req.Value)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Bad Request", "value must be a string, number or bool")
+		return
+	}
+
+	receipt, err := h.catalog.EraseSubject(db.ID, req.Field, value)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid subject") {
+			respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+			return
+		}
+		if receipt != nil {
+			// Some collections were erased; the receipt records them
+			respondJSON(w, http.StatusInternalServerError, receipt)
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, receipt)
+}
+
+// ListErasures handles GET /api/databases/:id/subjects/erasures
+func (h *Handler) ListErasures(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	receipts, err := h.catalog.ListErasureReceipts(db.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, receipts)
+}
+
+// subjectValue formats a JSON subject value as a filter value
+func subjectValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
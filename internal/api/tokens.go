@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"jsondrop/internal/models"
+	"jsondrop/internal/token"
+)
+
+// defaultTokenTTL is how long access tokens last unless asked otherwise
+const defaultTokenTTL = 15 * time.Minute
+
+// maxTokenCaller is the longest caller a token may be minted for
+const maxTokenCaller = 256
+
+// CreateToken handles POST /api/databases/:id/tokens
+// Mints a short-lived JWT acting as a read or write key, optionally restricted to some
+// collections, so browsers never hold the write key. Tokens can't be revoked one by one
+// before they expire; rotating the keys or deleting the database revokes them all
+func (h *Handler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.CreateTokenRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Access == "" {
+		req.Access = models.KeyAccessRead
+	}
+	if req.Access != models.KeyAccessWrite && req.Access != models.KeyAccessRead {
+		respondError(w, http.StatusBadRequest, "Bad Request", "access must be write or read")
+		return
+	}
+	ttl := min(defaultTokenTTL, h.cfg.TokenMaxTTL)
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl <= 0 || ttl > h.cfg.TokenMaxTTL {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("ttl_seconds must be between 1 and %d", int(h.cfg.TokenMaxTTL.Seconds())))
+		return
+	}
+
+	if len(req.Caller) > maxTokenCaller {
+		respondError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("caller must be at most %d characters", maxTokenCaller))
+		return
+	}
+
+	var collections []string
+	if len(req.Collections) > 0 {
+		collections = slices.Clone(req.Collections)
+		slices.Sort(collections)
+		collections = slices.Compact(collections)
+	}
+	for _, collection := range collections {
+		schema, err := h.store.GetSchema(db.ID, collection)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+			return
+		}
+		if schema == nil {
+			respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+			return
+		}
+	}
+
+	now := time.Now()
+	claims := token.Claims{DatabaseID: db.ID, Access: req.Access, Collections: collections, Generation: db.KeyGeneration, Caller: req.Caller}
+	signed, err := h.tokens.Mint(claims, ttl, now)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusCreated, models.TokenResponse{
+		Token:       signed,
+		TokenType:   "Bearer",
+		DatabaseID:  db.ID,
+		Access:      req.Access,
+		Collections: collections,
+		Caller:      req.Caller,
+		ExpiresAt:   time.Unix(now.Add(ttl).Unix(), 0).UTC(),
+	})
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"io"
+	"net/http"
+)
+
+// trackUsage counts each authenticated request, and the request body bytes of successful
+// writes, against the database and the type of key that made it
+func (h *Handler) trackUsage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		db := getDatabaseFromContext(r)
+		if db == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		label := keyTypeFromContext(r)
+		h.usage.AddRequest(db.ID, label)
+
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		if sw.status >= 200 && sw.status < 300 {
+			h.usage.AddBytesWritten(db.ID, label, body.n)
+		}
+	})
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read reads from the body and adds to the count
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// statusWriter records the status of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the first status written
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// GetUsage handles GET /api/databases/:id/usage
+// Reports requests, bytes written and events consumed by key type since the server started
+func (h *Handler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, h.usage.Usage(db.ID))
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/events"
+	"jsondrop/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateWebhook handles POST /api/databases/:id/webhooks
+// The response carries the signing secret, which isn't shown again
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.URL == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Webhook URL is required")
+		return
+	}
+	if err := database.ValidateWebhookURL(req.URL); err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	for _, eventType := range req.Events {
+		// Webhooks see every change, so there are no bulk_change events to deliver
+		if eventType == events.EventTypeBulkChange || !slices.Contains(events.EventTypes, eventType) {
+			respondError(w, http.StatusBadRequest, "Bad Request", "Unknown event type: "+eventType)
+			return
+		}
+	}
+
+	hook := &models.Webhook{
+		DatabaseID: db.ID,
+		URL:        req.URL,
+		Collection: req.Collection,
+		Events:     req.Events,
+	}
+	if err := h.catalog.CreateWebhook(hook); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "schema not found"):
+			respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+req.Collection)
+		case strings.Contains(err.Error(), "limit exceeded"):
+			respondError(w, http.StatusUnprocessableEntity, "Unprocessable Entity", err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, hook)
+}
+
+// ListWebhooks handles GET /api/databases/:id/webhooks
+// Secrets are left out
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	hooks, err := h.catalog.ListWebhooks(db.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+
+	respondJSON(w, http.StatusOK, hooks)
+}
+
+// DeleteWebhook handles DELETE /api/databases/:id/webhooks/:webhookId
+// Deliveries waiting for a retry are dropped
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	if err := h.catalog.DeleteWebhook(db.ID, chi.URLParam(r, "webhookId")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeadLetters handles GET /api/databases/:id/webhooks/:webhookId/dead-letters
+// Lists the deliveries the webhook's receiver refused on every attempt, oldest first
+func (h *Handler) ListWebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	hook, ok := h.findWebhook(w, db.ID, chi.URLParam(r, "webhookId"))
+	if !ok {
+		return
+	}
+	deadLetters, err := h.catalog.ListWebhookDeadLetters(db.ID, hook.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, deadLetters)
+}
+
+// ReplayWebhookDeadLetter handles POST /api/databases/:id/webhooks/:webhookId/dead-letters/:deliveryId/replay
+// Queues the delivery again with a fresh set of attempts; it comes back as a dead letter
+// if they all fail
+func (h *Handler) ReplayWebhookDeadLetter(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	hook, ok := h.findWebhook(w, db.ID, chi.URLParam(r, "webhookId"))
+	if !ok {
+		return
+	}
+	dead, ok := h.takeDeadLetter(w, db.ID, hook.ID, chi.URLParam(r, "deliveryId"))
+	if !ok {
+		return
+	}
+	if err := h.webhooks.Replay(hook, dead); err != nil {
+		if strings.Contains(err.Error(), "queue full") {
+			respondError(w, http.StatusServiceUnavailable, "Service Unavailable", "Webhook deliveries are backed up, try again later")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// DeleteWebhookDeadLetter handles DELETE /api/databases/:id/webhooks/:webhookId/dead-letters/:deliveryId
+func (h *Handler) DeleteWebhookDeadLetter(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	if _, ok := h.takeDeadLetter(w, db.ID, chi.URLParam(r, "webhookId"), chi.URLParam(r, "deliveryId")); !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findWebhook looks up a webhook of a database, answering the request if it can't
+func (h *Handler) findWebhook(w http.ResponseWriter, dbID string, id string) (*models.Webhook, bool) {
+	hooks, err := h.catalog.ListWebhooks(dbID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return nil, false
+	}
+	for _, hook := range hooks {
+		if hook.ID == id {
+			return hook, true
+		}
+	}
+	respondError(w, http.StatusNotFound, "Not Found", "webhook not found")
+	return nil, false
+}
+
+// takeDeadLetter removes a dead letter of a webhook, answering the request if it can't
+func (h *Handler) takeDeadLetter(w http.ResponseWriter, dbID string, webhookID string, id string) (*models.WebhookDeadLetter, bool) {
+	dead, err := h.catalog.TakeWebhookDeadLetter(dbID, webhookID, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			respondError(w, http.StatusNotFound, "Not Found", err.Error())
+			return nil, false
+		}
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+		return nil, false
+	}
+	return dead, true
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"jsondrop/internal/events"
+	"jsondrop/internal/models"
+	"jsondrop/internal/websocket"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DatabaseEventsSocket handles GET /api/databases/:id/ws (WebSocket)
+// Sends the same events as the SSE stream, for clients that handle WebSockets better
+func (h *Handler) DatabaseEventsSocket(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	h.serveEventSocket(w, r, db.ID, nil)
+}
+
+// CollectionEventsSocket handles GET /api/databases/:id/:collection/ws (WebSocket)
+func (h *Handler) CollectionEventsSocket(w http.ResponseWriter, r *http.Request) {
+	db := getDatabaseFromContext(r)
+	if db == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized", "Invalid authentication")
+		return
+	}
+
+	collection := chi.URLParam(r, "collection")
+	if collection == "" {
+		respondError(w, http.StatusBadRequest, "Bad Request", "Collection name is required")
+		return
+	}
+
+	// Verify schema exists for this collection
+	schema, err := h.store.GetSchema(db.ID, collection)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Internal Server Error", "Failed to verify collection")
+		return
+	}
+	if schema == nil {
+		respondError(w, http.StatusNotFound, "Not Found", "Collection does not exist: "+collection)
+		return
+	}
+
+	h.serveEventSocket(w, r, db.ID, schema)
+}
+
+// serveEventSocket upgrades to WebSocket and streams a database's events, or only a
+// collection's when schema isn't nil, narrowed by its field filters
+// Clients always acknowledge: browsers answer the heartbeat pings on their own, and each
+// pong or message from the client counts as an acknowledgment
+func (h *Handler) serveEventSocket(w http.ResponseWriter, r *http.Request, dbID string, schema *models.Schema) {
+	opts, err := parseStreamOptions(r)
+	collection := ""
+	if schema != nil {
+		collection = schema.Name
+		if err == nil {
+			err = opts.filterDocuments(r, schema)
+		}
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	if schema == nil && !h.streamCollections(w, r, dbID, &opts) {
+		return
+	}
+	opts.subscribe.Redact = h.eventRedactor(r, dbID)
+	opts.ack = true
+
+	// Origins are checked by databaseGate, as browsers don't apply CORS to WebSockets
+	if !websocket.IsUpgrade(r) {
+		w.Header().Set("Upgrade", "websocket")
+		respondError(w, http.StatusUpgradeRequired, "Upgrade Required", "This endpoint speaks WebSocket; use /events for SSE")
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		if errors.Is(err, http.ErrNotSupported) {
+			respondError(w, http.StatusInternalServerError, "Internal Server Error", err.Error())
+			return
+		}
+		respondError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	defer conn.Close(websocket.CloseGoingAway, "stream closed")
+
+	var listener *events.Listener
+	var missed []models.ChangeEvent
+	if collection == "" {
+		listener, missed, opts.resumed = h.broadcaster.SubscribeWith(dbID, opts.subscribe)
+		defer h.broadcaster.Unsubscribe(dbID, listener)
+	} else {
+		listener, missed, opts.resumed = h.broadcaster.SubscribeCollectionWith(dbID, collection, opts.subscribe)
+		defer h.broadcaster.UnsubscribeCollection(dbID, collection, listener)
+	}
+	h.broadcaster.RequireAck(listener)
+
+	// Send initial connection message
+	connected, _ := json.Marshal(events.Message{Event: "connected", Data: connectedData(dbID, collection, listener, opts)})
+	if err := conn.WriteText(connected, sseWriteTimeout); err != nil {
+		return
+	}
+
+	// The request context isn't canceled when a taken-over connection drops, so the
+	// reader reports it instead
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			h.broadcaster.Ack(dbID, listener.ID)
+		}
+	}()
+
+	h.streamEvents(r, socketWriter{conn: conn}, listener, opts, missed, done)
+}
+
+// socketWriter sends change events and heartbeat pings to a WebSocket client
+type socketWriter struct {
+	conn *websocket.Conn
+}
+
+// WriteEvent sends a change event message in an event format version
+func (sw socketWriter) WriteEvent(event models.ChangeEvent, version int) error {
+	return sw.conn.WriteText(events.FormatMessage(event, version), sseWriteTimeout)
+}
+
+// WriteBatch sends events as one batch message in an event format version
+func (sw socketWriter) WriteBatch(batch []models.ChangeEvent, version int) error {
+	return sw.conn.WriteText(events.FormatMessageBatch(batch, version), sseWriteTimeout)
+}
+
+// WriteNotice sends a message of a named event other than a change
+func (sw socketWriter) WriteNotice(event string, data []byte) error {
+	message, _ := json.Marshal(events.Message{Event: event, Data: data})
+	return sw.conn.WriteText(message, sseWriteTimeout)
+}
+
+// WritePing sends a ping, which the client answers with a pong
+func (sw socketWriter) WritePing() error {
+	return sw.conn.Ping(sseWriteTimeout)
+}
+
+// originAllowed reports whether CORS_ORIGINS, or a database's origins, admit an origin
+func originAllowed(allowedOrigins []string, origin string) bool {
+	return slices.Contains(allowedOrigins, "*") || slices.Contains(allowedOrigins, origin)
+}
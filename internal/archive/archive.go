@@ -0,0 +1,664 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+	"jsondrop/internal/policy"
+)
+
+// Archive layout (gzipped tar):
+//
+//	manifest.json              format, version, per-collection document counts and checksums
+//	schemas.json               schema definitions
+//	collections/<name>.ndjson  documents, one per line, as produced by collection export
+//	collections/<name>.sha256  the SHA-256 of each document line, one per line
+//
+// Version 1 archives have no checksums; they are still restored, unverified
+const (
+	Format  = "jsondrop-archive"
+	Version = 2
+
+	manifestFile     = "manifest.json"
+	schemasFile      = "schemas.json"
+	collectionsDir   = "collections/"
+	collectionSuffix = ".ndjson"
+	checksumSuffix   = ".sha256"
+
+	maxProblems = 100 // Problems listed when an archive fails verification
+)
+
+// Manifest describes an archive's contents
+type Manifest struct {
+	Format        string               `json:"format"`
+	Version       int                  `json:"version"`
+	DatabaseID    string               `json:"database_id"`
+	ExportedAt    time.Time            `json:"exported_at"`
+	Subject       *Subject             `json:"subject,omitempty"` // Set for a data subject's export
+	SchemasSHA256 string               `json:"schemas_sha256,omitempty"`
+	Checksum      string               `json:"checksum,omitempty"` // Covers every file's checksum, see exportChecksum
+	Collections   []CollectionManifest `json:"collections"`
+}
+
+// Subject identifies the data subject whose documents an archive holds
+type Subject struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// CollectionManifest records a collection included in an archive
+type CollectionManifest struct {
+	Name            string `json:"name"`
+	Documents       int    `json:"documents"`
+	Bytes           int64  `json:"bytes,omitempty"`            // Size of the NDJSON file
+	SHA256          string `json:"sha256,omitempty"`           // Of the NDJSON file
+	ChecksumsSHA256 string `json:"checksums_sha256,omitempty"` // Of the per-document checksum file
+}
+
+// Schema is a schema definition as stored in an archive
+type Schema struct {
+	Name          string                              `json:"name"`
+	Fields        map[string]models.FieldType         `json:"fields"`
+	ReadFilter    string                              `json:"read_filter,omitempty"`
+	RetentionDays int                                 `json:"retention_days,omitempty"`
+	Deprecated    map[string]*models.FieldDeprecation `json:"deprecated_fields,omitempty"`
+	Indexes       []Index                             `json:"indexes,omitempty"`
+}
+
+// Index is a user-declared index as stored in an archive
+type Index struct {
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+}
+
+// Write writes every schema and document of a database to w as an archive
+func Write(w io.Writer, catalog *database.CatalogDB, dbID string) error {
+	schemas, err := catalog.ListSchemas(dbID)
+	if err != nil {
+		return err
+	}
+
+	return write(w, catalog, dbID, schemas, nil, func(schema *models.Schema, fn func(*models.Document) error) error {
+		return catalog.EachDocument(dbID, schema.Name, nil, fn)
+	})
+}
+
+// WriteSubject writes a data subject's documents, those whose field equals value, to w as
+// an archive, with the schemas of the collections that have the field
+// Soft-deleted documents are included
+func WriteSubject(w io.Writer, catalog *database.CatalogDB, dbID string, field string, value string) error {
+	schemas, err := catalog.SubjectCollections(dbID, field)
+	if err != nil {
+		return err
+	}
+	if len(schemas) == 0 {
+		return fmt.Errorf("invalid subject: no collection has field %s", field)
+	}
+
+	subject := &Subject{Field: field, Value: value}
+	return write(w, catalog, dbID, schemas, subject, func(schema *models.Schema, fn func(*models.Document) error) error {
+		return catalog.EachSubjectDocument(dbID, schema, field, value, fn)
+	})
+}
+
+// write writes an archive of the given schemas, with the documents each yields
+// Collections are staged in temporary files, since tar entries need their size up front
+// and the manifest, which leads the archive, records their checksums
+func write(w io.Writer, catalog *database.CatalogDB, dbID string, schemas []*models.Schema, subject *Subject,
+	each func(*models.Schema, func(*models.Document) error) error) error {
+	manifest := Manifest{
+		Format:      Format,
+		Version:     Version,
+		DatabaseID:  dbID,
+		ExportedAt:  time.Now().UTC(),
+		Subject:     subject,
+		Collections: make([]CollectionManifest, 0, len(schemas)),
+	}
+	archived := make([]Schema, 0, len(schemas))
+	var staged []*os.File
+	defer func() {
+		for _, f := range staged {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	stage := func(name string) (*os.File, error) {
+		f, err := os.CreateTemp("", "jsondrop-export-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to stage collection %s: %w", name, err)
+		}
+		staged = append(staged, f)
+		return f, nil
+	}
+
+	for _, schema := range schemas {
+		indexes, err := catalog.ListIndexes(dbID, schema.Name)
+		if err != nil {
+			return fmt.Errorf("failed to export collection %s: %w", schema.Name, err)
+		}
+		def := Schema{Name: schema.Name, Fields: schema.Fields, ReadFilter: schema.ReadFilter, RetentionDays: schema.RetentionDays, Deprecated: schema.Deprecated}
+		for _, index := range indexes {
+			def.Indexes = append(def.Indexes, Index{Name: index.Name, Fields: index.Fields})
+		}
+		archived = append(archived, def)
+
+		docs, err := stage(schema.Name)
+		if err != nil {
+			return err
+		}
+		sums, err := stage(schema.Name)
+		if err != nil {
+			return err
+		}
+
+		collection := CollectionManifest{Name: schema.Name}
+		docsHash, sumsHash := sha256.New(), sha256.New()
+		docsOut := &countingWriter{w: io.MultiWriter(docs, docsHash)}
+		sumsOut := io.MultiWriter(sums, sumsHash)
+		var line bytes.Buffer
+		encoder := json.NewEncoder(&line)
+		err = each(schema, func(doc *models.Document) error {
+			line.Reset()
+			if err := encoder.Encode(doc); err != nil {
+				return err
+			}
+			if _, err := docsOut.Write(line.Bytes()); err != nil {
+				return err
+			}
+			_, err := io.WriteString(sumsOut, lineChecksum(line.Bytes())+"\n")
+			collection.Documents++
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export collection %s: %w", schema.Name, err)
+		}
+		collection.Bytes = docsOut.n
+		collection.SHA256 = hex.EncodeToString(docsHash.Sum(nil))
+		collection.ChecksumsSHA256 = hex.EncodeToString(sumsHash.Sum(nil))
+		manifest.Collections = append(manifest.Collections, collection)
+	}
+
+	schemasData, err := encodeJSON(schemasFile, archived)
+	if err != nil {
+		return err
+	}
+	manifest.SchemasSHA256 = checksum(schemasData)
+	manifest.Checksum = exportChecksum(&manifest)
+	manifestData, err := encodeJSON(manifestFile, manifest)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := writeFile(tw, manifestFile, bytes.NewReader(manifestData), int64(len(manifestData)), manifest.ExportedAt); err != nil {
+		return err
+	}
+	if err := writeFile(tw, schemasFile, bytes.NewReader(schemasData), int64(len(schemasData)), manifest.ExportedAt); err != nil {
+		return err
+	}
+
+	// Each collection has two staged files: its documents, then their checksums
+	for i, f := range staged {
+		name := collectionsDir + schemas[i/2].Name + collectionSuffix
+		if i%2 == 1 {
+			name = collectionsDir + schemas[i/2].Name + checksumSuffix
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stage collection %s: %w", schemas[i/2].Name, err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to stage collection %s: %w", schemas[i/2].Name, err)
+		}
+		if err := writeFile(tw, name, f, info.Size(), manifest.ExportedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return nil
+}
+
+// encodeJSON encodes a JSON file of the archive
+func encodeJSON(name string, value interface{}) ([]byte, error) {
+	// Read filters use < and >, which the default encoder escapes
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFile adds a file of the given size to the archive
+func writeFile(tw *tar.Writer, name string, r io.Reader, size int64, modTime time.Time) error {
+	header := &tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modTime}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// checksum returns the hex SHA-256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// lineChecksum returns the checksum of a document line, without its newline
+func lineChecksum(line []byte) string {
+	return checksum(bytes.TrimSuffix(line, []byte("\n")))
+}
+
+// exportChecksum returns the checksum of a whole archive: the SHA-256 of a listing of
+// every file's checksum, in sha256sum format and archive order
+func exportChecksum(m *Manifest) string {
+	var listing strings.Builder
+	fmt.Fprintf(&listing, "%s  %s\n", m.SchemasSHA256, schemasFile)
+	for _, c := range m.Collections {
+		fmt.Fprintf(&listing, "%s  %s\n", c.SHA256, collectionsDir+c.Name+collectionSuffix)
+		fmt.Fprintf(&listing, "%s  %s\n", c.ChecksumsSHA256, collectionsDir+c.Name+checksumSuffix)
+	}
+	return checksum([]byte(listing.String()))
+}
+
+// Restore reads an archive from r into an existing database
+// The whole archive is staged and its checksums verified first, so a corrupt or truncated
+// archive is rejected, listing its problems, before anything is written. Missing schemas
+// are created; schemas that already exist must have the same fields, and are checked
+// before anything is written. Documents are imported as by the collection import
+// endpoint, so existing document IDs are reported as failed lines rather than
+// overwritten. A non-nil error means the restore stopped early; the response then
+// records what was restored before it
+func Restore(catalog *database.CatalogDB, dbID string, r io.Reader) (*models.RestoreResponse, error) {
+	result := &models.RestoreResponse{Collections: make(map[string]*models.ImportResponse)}
+	fail := func(err error) (*models.RestoreResponse, error) {
+		result.Error = err.Error()
+		return result, err
+	}
+
+	staged, err := stage(r)
+	defer staged.close()
+	if err != nil {
+		return fail(err)
+	}
+	if problems := staged.verify(); len(problems) > 0 {
+		result.Problems = problems
+		return fail(fmt.Errorf("invalid archive: failed verification, nothing was restored: %s", problems[0]))
+	}
+	result.Verified = staged.manifest.Version >= 2
+
+	schemas, err := restoreSchemas(catalog, dbID, staged.schemas, result)
+	if err != nil {
+		return fail(err)
+	}
+
+	for _, collection := range staged.collections {
+		schema, exists := schemas[collection.name]
+		if !exists {
+			return fail(fmt.Errorf("invalid archive: %s has no schema", collectionsDir+collection.name+collectionSuffix))
+		}
+		if _, err := collection.file.Seek(0, io.SeekStart); err != nil {
+			return fail(fmt.Errorf("collection %s: %w", collection.name, err))
+		}
+
+		imported, err := catalog.ImportNDJSON(dbID, schema, collection.file)
+		result.Collections[collection.name] = imported
+		if err != nil {
+			return fail(fmt.Errorf("collection %s: %w", collection.name, err))
+		}
+	}
+
+	return result, nil
+}
+
+// stagedArchive is an archive read into temporary files, to be verified before restoring
+type stagedArchive struct {
+	manifest    Manifest
+	schemas     []Schema
+	schemasSum  string
+	collections []*stagedCollection // In archive order
+}
+
+// stagedCollection is a collection's documents, with the checksums read and computed for them
+type stagedCollection struct {
+	name         string
+	file         *os.File
+	bytes        int64
+	sha256       string
+	lines        []stagedLine
+	checksums    []string // From the collection's checksum file; nil if it has none
+	checksumsSum string
+	checksumsErr string // First malformed line of the checksum file
+}
+
+// stagedLine is the computed checksum of a document line
+type stagedLine struct {
+	number int
+	sum    string
+}
+
+// stage reads a whole archive into temporary files, computing checksums as it goes
+// The returned archive is never nil, and must be closed to remove the files
+func stage(r io.Reader) (*stagedArchive, error) {
+	staged := &stagedArchive{}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return staged, fmt.Errorf("invalid archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	// The manifest and schemas lead the archive
+	if _, err := readJSON(tr, manifestFile, &staged.manifest); err != nil {
+		return staged, err
+	}
+	if staged.manifest.Format != Format {
+		return staged, fmt.Errorf("invalid archive: unknown format %q", staged.manifest.Format)
+	}
+	if staged.manifest.Version < 1 || staged.manifest.Version > Version {
+		return staged, fmt.Errorf("invalid archive: unsupported version %d", staged.manifest.Version)
+	}
+	data, err := readJSON(tr, schemasFile, &staged.schemas)
+	if err != nil {
+		return staged, err
+	}
+	staged.schemasSum = checksum(data)
+
+	collections := make(map[string]*stagedCollection)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return staged, fmt.Errorf("invalid archive: truncated or unreadable: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		dir, file := path.Split(header.Name)
+		suffix := path.Ext(file)
+		name := strings.TrimSuffix(file, suffix)
+		if dir != collectionsDir || (suffix != collectionSuffix && suffix != checksumSuffix) {
+			return staged, fmt.Errorf("invalid archive: unexpected file %s", header.Name)
+		}
+		collection := collections[name]
+		if collection == nil {
+			collection = &stagedCollection{name: name}
+			collections[name] = collection
+			staged.collections = append(staged.collections, collection)
+		}
+
+		if suffix == collectionSuffix {
+			if collection.file != nil {
+				return staged, fmt.Errorf("invalid archive: duplicate file %s", header.Name)
+			}
+			collection.file, err = os.CreateTemp("", "jsondrop-restore-*.ndjson")
+			if err != nil {
+				return staged, fmt.Errorf("failed to stage collection %s: %w", name, err)
+			}
+			err = collection.readDocuments(tr)
+		} else {
+			if collection.checksums != nil {
+				return staged, fmt.Errorf("invalid archive: duplicate file %s", header.Name)
+			}
+			err = collection.readChecksums(tr)
+		}
+		if err != nil {
+			return staged, fmt.Errorf("invalid archive: truncated or unreadable: %s: %w", header.Name, err)
+		}
+	}
+
+	return staged, nil
+}
+
+// readDocuments copies a collection's documents to its staged file, computing the
+// checksum of the file and of each document line
+func (c *stagedCollection) readDocuments(r io.Reader) error {
+	fileHash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(c.file, fileHash)}
+	reader := bufio.NewReader(io.TeeReader(r, counter))
+
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		// Blank lines are skipped, as by import
+		if len(bytes.TrimSpace(line)) > 0 {
+			c.lines = append(c.lines, stagedLine{number: number, sum: lineChecksum(line)})
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	c.bytes = counter.n
+	c.sha256 = hex.EncodeToString(fileHash.Sum(nil))
+	return nil
+}
+
+// readChecksums reads a collection's checksum file
+func (c *stagedCollection) readChecksums(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	c.checksumsSum = checksum(data)
+	c.checksums = []string{}
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil
+	}
+	for i, line := range strings.Split(text, "\n") {
+		if _, err := hex.DecodeString(line); (err != nil || len(line) != sha256.Size*2) && c.checksumsErr == "" {
+			c.checksumsErr = fmt.Sprintf("%s line %d: malformed checksum", collectionsDir+c.name+checksumSuffix, i+1)
+		}
+		c.checksums = append(c.checksums, line)
+	}
+	return nil
+}
+
+// verify checks a staged archive against its manifest, returning the problems found
+// Version 1 archives have no checksums, so only their collections and counts are checked
+func (s *stagedArchive) verify() []string {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		if len(problems) < maxProblems {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	checksummed := s.manifest.Version >= 2
+
+	if checksummed {
+		if s.manifest.Checksum != exportChecksum(&s.manifest) {
+			report("%s: checksum does not match the file checksums it lists", manifestFile)
+		}
+		if s.schemasSum != s.manifest.SchemasSHA256 {
+			report("%s: checksum mismatch", schemasFile)
+		}
+	}
+
+	staged := make(map[string]*stagedCollection, len(s.collections))
+	for _, c := range s.collections {
+		staged[c.name] = c
+	}
+	listed := make(map[string]bool, len(s.manifest.Collections))
+	for _, want := range s.manifest.Collections {
+		listed[want.Name] = true
+		docsFile := collectionsDir + want.Name + collectionSuffix
+		sumsFile := collectionsDir + want.Name + checksumSuffix
+
+		c := staged[want.Name]
+		if c == nil || c.file == nil {
+			report("%s: missing, the archive may be truncated", docsFile)
+			continue
+		}
+		if len(c.lines) != want.Documents {
+			report("%s: has %d documents, manifest lists %d", docsFile, len(c.lines), want.Documents)
+		}
+		if !checksummed {
+			continue
+		}
+		// Documents are checked line by line first, to point at the corrupted ones; the
+		// file checksum then catches anything else, such as inserted blank lines
+		damaged := false
+		switch {
+		case c.checksums == nil:
+			report("%s: missing", sumsFile)
+		case c.checksumsSum != want.ChecksumsSHA256:
+			report("%s: checksum mismatch", sumsFile)
+		case c.checksumsErr != "":
+			report("%s", c.checksumsErr)
+		case len(c.checksums) != len(c.lines):
+			report("%s: has %d checksums for %d documents", sumsFile, len(c.checksums), len(c.lines))
+		default:
+			for i, line := range c.lines {
+				if line.sum != c.checksums[i] {
+					report("%s line %d: document checksum mismatch", docsFile, line.number)
+					damaged = true
+				}
+			}
+		}
+		if !damaged && (c.bytes != want.Bytes || c.sha256 != want.SHA256) {
+			report("%s: checksum mismatch (%d bytes, manifest lists %d)", docsFile, c.bytes, want.Bytes)
+		}
+	}
+
+	for _, c := range s.collections {
+		if !listed[c.name] {
+			report("%s: not listed in the manifest", collectionsDir+c.name+collectionSuffix)
+		}
+	}
+
+	return problems
+}
+
+// close removes the staged files
+func (s *stagedArchive) close() {
+	for _, c := range s.collections {
+		if c.file != nil {
+			c.file.Close()
+			os.Remove(c.file.Name())
+		}
+	}
+}
+
+// readJSON reads the next archive entry, which must be the named JSON file, and returns its content
+func readJSON(tr *tar.Reader, name string, value interface{}) ([]byte, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: missing %s: %w", name, err)
+	}
+	if header.Name != name {
+		return nil, fmt.Errorf("invalid archive: expected %s, found %s", name, header.Name)
+	}
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: truncated or unreadable: %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return nil, fmt.Errorf("invalid archive: failed to parse %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// restoreSchemas checks archived schemas against the database, then creates the missing ones
+func restoreSchemas(catalog *database.CatalogDB, dbID string, archived []Schema, result *models.RestoreResponse) (map[string]*models.Schema, error) {
+	schemas := make(map[string]*models.Schema)
+	var missing []Schema
+
+	for _, def := range archived {
+		existing, err := catalog.GetSchema(dbID, def.Name)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			if def.ReadFilter != "" {
+				if _, err := policy.Parse(def.ReadFilter); err != nil {
+					return nil, fmt.Errorf("invalid archive: schema %s has an invalid read filter: %w", def.Name, err)
+				}
+			}
+			missing = append(missing, def)
+			continue
+		}
+		if !reflect.DeepEqual(existing.Fields, def.Fields) {
+			return nil, fmt.Errorf("schema conflict: %s already exists with different fields", def.Name)
+		}
+		schemas[def.Name] = existing
+		result.SchemasExisting++
+	}
+
+	for _, def := range missing {
+		schema, err := catalog.CreateSchema(dbID, def.Name, def.Fields, nil)
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: %w", def.Name, err)
+		}
+		if def.ReadFilter != "" {
+			if schema, err = catalog.SetReadFilter(dbID, def.Name, def.ReadFilter); err != nil {
+				return nil, fmt.Errorf("schema %s: %w", def.Name, err)
+			}
+		}
+		if def.RetentionDays != 0 {
+			if schema, err = catalog.SetRetention(dbID, def.Name, def.RetentionDays); err != nil {
+				return nil, fmt.Errorf("schema %s: %w", def.Name, err)
+			}
+		}
+		for field, deprecation := range def.Deprecated {
+			if schema, err = catalog.DeprecateField(dbID, def.Name, field, *deprecation); err != nil {
+				return nil, fmt.Errorf("schema %s: %w", def.Name, err)
+			}
+		}
+		// Indexes are declared on the empty collection, before its documents are restored
+		for _, index := range def.Indexes {
+			if _, err := catalog.CreateIndex(dbID, def.Name, index.Name, index.Fields); err != nil {
+				return nil, fmt.Errorf("schema %s: %w", def.Name, err)
+			}
+		}
+		schemas[def.Name] = schema
+		result.SchemasCreated++
+	}
+
+	return schemas, nil
+}
@@ -0,0 +1,146 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+// rewrite returns a copy of an archive with each file passed through edit
+// A nil result from edit drops the file
+func rewrite(t *testing.T, archive []byte, edit func(name string, data []byte) []byte) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var out bytes.Buffer
+	gzOut := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gzOut)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar.Next() error = %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("ReadAll() error = %v", err)
+		}
+		if data = edit(header.Name, data); data == nil {
+			continue
+		}
+		header.Size = int64(len(data))
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("WriteHeader() error = %v", err)
+		}
+		tw.Write(data)
+	}
+	tw.Close()
+	gzOut.Close()
+	return out.Bytes()
+}
+
+func TestRestore_Checksums(t *testing.T) {
+	c := newTestCatalog(t)
+
+	newTestDatabase(t, c, "db_source")
+	for _, name := range []string{"orders", "users"} {
+		if _, err := c.CreateSchema("db_source", name, map[string]models.FieldType{"n": models.FieldTypeNumber}, nil); err != nil {
+			t.Fatalf("CreateSchema(%s) error = %v", name, err)
+		}
+		for i := 0; i < 3; i++ {
+			if _, err := c.InsertDocument("db_source", name, map[string]interface{}{"n": i}, ""); err != nil {
+				t.Fatalf("InsertDocument() error = %v", err)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, c, "db_source"); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	archive := buf.Bytes()
+
+	tests := []struct {
+		name        string
+		archive     []byte
+		wantProblem string // Empty for a successful restore
+	}{
+		{"intact", archive, ""},
+		{
+			"corrupted document",
+			rewrite(t, archive, func(name string, data []byte) []byte {
+				if name == "collections/orders.ndjson" {
+					return bytes.Replace(data, []byte(`"n":1`), []byte(`"n":7`), 1)
+				}
+				return data
+			}),
+			"document checksum mismatch",
+		},
+		{
+			"missing collection",
+			rewrite(t, archive, func(name string, data []byte) []byte {
+				if strings.HasPrefix(name, "collections/users.") {
+					return nil
+				}
+				return data
+			}),
+			"collections/users.ndjson: missing",
+		},
+		{
+			"dropped document",
+			rewrite(t, archive, func(name string, data []byte) []byte {
+				if name == "collections/users.ndjson" {
+					return data[:bytes.IndexByte(data, '\n')+1]
+				}
+				return data
+			}),
+			"collections/users.ndjson: has 1 documents, manifest lists 3",
+		},
+		{"truncated", archive[:len(archive)*2/3], "truncated or unreadable"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbID := "db_target" + string(rune('a'+i))
+			newTestDatabase(t, c, dbID)
+
+			result, err := Restore(c, dbID, bytes.NewReader(tt.archive))
+			if tt.wantProblem == "" {
+				if err != nil || !result.Verified || result.Collections["orders"].Imported != 3 {
+					t.Fatalf("Restore() = %+v, %v, want 3 verified orders", result, err)
+				}
+				return
+			}
+
+			if err == nil || !strings.HasPrefix(err.Error(), "invalid archive") {
+				t.Fatalf("Restore() error = %v, want invalid archive", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantProblem) && !containsProblem(result.Problems, tt.wantProblem) {
+				t.Errorf("Restore() error = %v, problems = %v, want %q", err, result.Problems, tt.wantProblem)
+			}
+			if schemas, _ := c.ListSchemas(dbID); len(schemas) != 0 {
+				t.Errorf("Restore() created %d schemas from a bad archive, want none", len(schemas))
+			}
+		})
+	}
+}
+
+// containsProblem reports whether a problem is listed
+func containsProblem(problems []string, want string) bool {
+	for _, problem := range problems {
+		if strings.Contains(problem, want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,377 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Documents are charged for the size of their JSON as written. bbolt has no secondary
+// indexes, so queries read the whole collection and filter, sort and paginate in memory.
+// Soft deletes aren't supported, so reads of soft-deleted documents find none.
+
+// documentRecord is a document as stored in its collection's bucket
+type documentRecord struct {
+	Seq        uint64            `json:"seq"` // Insertion order, breaking timestamp ties
+	Data       json.RawMessage   `json:"data"`
+	Visibility models.Visibility `json:"visibility"`
+	CreatedAt  int64             `json:"created_at"`
+	UpdatedAt  int64             `json:"updated_at"`
+	Revision   int64             `json:"revision"`
+}
+
+// size returns the quota charged for the document
+func (r *documentRecord) size() int64 {
+	return int64(len(r.Data))
+}
+
+// toModel converts a stored record to the API model
+func (r *documentRecord) toModel(collection string, docID string) (*models.Document, error) {
+	doc := &models.Document{
+		ID:         docID,
+		Collection: collection,
+		Visibility: r.Visibility,
+		CreatedAt:  time.Unix(r.CreatedAt, 0),
+		UpdatedAt:  time.Unix(r.UpdatedAt, 0),
+		Revision:   r.Revision,
+	}
+	if err := json.Unmarshal(r.Data, &doc.Data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal document data: %w", err)
+	}
+	return doc, nil
+}
+
+// collectionBucket returns a collection's document bucket, or nil if it doesn't exist
+func collectionBucket(tx *bbolt.Tx, dbID string, collection string) *bbolt.Bucket {
+	dbBucket := tx.Bucket([]byte(dbID))
+	if dbBucket == nil {
+		return nil
+	}
+	return dbBucket.Bucket(collectionsBucket).Bucket([]byte(collection))
+}
+
+// getDocument reads a document's record, or nil if it doesn't exist
+func getDocument(documents *bbolt.Bucket, docID string) (*documentRecord, error) {
+	value := documents.Get([]byte(docID))
+	if value == nil {
+		return nil, nil
+	}
+	var record documentRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal document: %w", err)
+	}
+	return &record, nil
+}
+
+// putDocument writes a document's record
+func putDocument(documents *bbolt.Bucket, docID string, record *documentRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %w", err)
+	}
+	return documents.Put([]byte(docID), value)
+}
+
+// InsertDocument inserts a new document into a collection
+func (s *Store) InsertDocument(dbID string, collection string, data map[string]interface{}, visibility models.Visibility) (*models.Document, error) {
+	if visibility == "" {
+		visibility = models.DefaultVisibility
+	}
+	if !visibility.IsValid() {
+		return nil, fmt.Errorf("invalid visibility: %s", visibility)
+	}
+
+	docID, err := s.keys.DocumentID()
+	if err != nil {
+		return nil, err
+	}
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document data: %w", err)
+	}
+	now := time.Now().Unix()
+
+	record := &documentRecord{
+		Data:       dataJSON,
+		Visibility: visibility,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Revision:   1,
+	}
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		documents := collectionBucket(tx, dbID, collection)
+		if documents == nil {
+			return fmt.Errorf("collection does not exist: %s", collection)
+		}
+		if err := adjustQuota(tx, dbID, record.size()); err != nil {
+			return err
+		}
+		if record.Seq, err = documents.NextSequence(); err != nil {
+			return err
+		}
+		return putDocument(documents, docID, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.broadcaster != nil {
+		s.broadcaster.Broadcast(dbID, models.ChangeEvent{
+			EventType:  "insert",
+			DatabaseID: dbID,
+			Collection: collection,
+			DocumentID: docID,
+			Data:       data,
+			Visibility: visibility,
+			Timestamp:  time.Unix(now, 0),
+		})
+	}
+
+	return &models.Document{
+		ID:         docID,
+		Collection: collection,
+		Data:       data,
+		Visibility: visibility,
+		CreatedAt:  time.Unix(now, 0),
+		UpdatedAt:  time.Unix(now, 0),
+		Revision:   1,
+	}, nil
+}
+
+// GetDocument retrieves a single document by ID
+// Returns nil if the document doesn't exist or the scope doesn't allow reading it
+func (s *Store) GetDocument(dbID string, collection string, docID string, scope *database.ReadScope) (*models.Document, error) {
+	if scope.ReadsDeleted() {
+		return nil, nil
+	}
+
+	var doc *models.Document
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		documents := collectionBucket(tx, dbID, collection)
+		if documents == nil {
+			return nil
+		}
+		record, err := getDocument(documents, docID)
+		if err != nil || record == nil || !visible(record, scope.VisibleLevels()) {
+			return err
+		}
+		doc, err = record.toModel(collection, docID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	if doc == nil || !scope.Allows(doc) {
+		return nil, nil
+	}
+
+	return doc, nil
+}
+
+// QueryDocuments retrieves documents from a collection with pagination and filtering
+// Results are newest first unless sort keys are given
+// Nil dates or scope mean no timestamp or visibility restriction
+func (s *Store) QueryDocuments(dbID string, collection string, limit int, offset int, filters []database.Filter, dates *database.TimeRange, order []database.SortKey, scope *database.ReadScope) ([]*models.Document, error) {
+	if scope.ReadsDeleted() {
+		return nil, nil
+	}
+
+	budget := s.limits.NewQueryBudget()
+	defer budget.Done()
+
+	matched, err := s.matchDocuments(dbID, collection, budget, func(record *documentRecord, doc *models.Document) bool {
+		return visible(record, scope.VisibleLevels()) && inTimeRange(record, dates) &&
+			database.MatchFilters(doc.Data, filters) && scope.Allows(doc)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortDocuments(matched, order)
+	return paginate(matched, limit, offset), nil
+}
+
+// EachDocument streams every document in a collection to fn, oldest first
+func (s *Store) EachDocument(dbID string, collection string, scope *database.ReadScope, fn func(*models.Document) error) error {
+	if scope.ReadsDeleted() {
+		return nil
+	}
+
+	matched, err := s.matchDocuments(dbID, collection, nil, func(record *documentRecord, doc *models.Document) bool {
+		return visible(record, scope.VisibleLevels()) && scope.Allows(doc)
+	})
+	if err != nil {
+		return err
+	}
+
+	sortDocuments(matched, []database.SortKey{{Field: "created_at"}, {Field: "id"}})
+	for _, m := range matched {
+		if err := fn(m.doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchDocuments reads the documents in a collection that pass keep
+// Every document read counts against the budget, if there is one
+func (s *Store) matchDocuments(dbID string, collection string, budget *database.QueryBudget, keep func(*documentRecord, *models.Document) bool) ([]match, error) {
+	var matched []match
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		documents := collectionBucket(tx, dbID, collection)
+		if documents == nil {
+			return nil
+		}
+		return documents.ForEach(func(key, value []byte) error {
+			if budget != nil {
+				if err := budget.Scan(); err != nil {
+					return err
+				}
+			}
+			var record documentRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("failed to unmarshal document: %w", err)
+			}
+			doc, err := record.toModel(collection, string(key))
+			if err != nil {
+				return err
+			}
+			if keep(&record, doc) {
+				matched = append(matched, match{doc: doc, seq: record.Seq})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	return matched, nil
+}
+
+// UpdateDocument updates an existing document by ID
+// An empty visibility keeps the document's current visibility, and a nonzero revision
+// must match the document's current revision
+func (s *Store) UpdateDocument(dbID string, collection string, docID string, data map[string]interface{}, visibility models.Visibility, revision int64) (*models.Document, error) {
+	if visibility != "" && !visibility.IsValid() {
+		return nil, fmt.Errorf("invalid visibility: %s", visibility)
+	}
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document data: %w", err)
+	}
+	now := time.Now().Unix()
+
+	var record *documentRecord
+	var oldJSON json.RawMessage
+	var oldVisibility models.Visibility
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		documents := collectionBucket(tx, dbID, collection)
+		if documents == nil {
+			return fmt.Errorf("document not found")
+		}
+		var err error
+		record, err = getDocument(documents, docID)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return fmt.Errorf("document not found")
+		}
+		if err := database.CheckRevision(revision, record.Revision); err != nil {
+			return err
+		}
+
+		if err := adjustQuota(tx, dbID, int64(len(dataJSON))-record.size()); err != nil {
+			return err
+		}
+		oldJSON, oldVisibility = record.Data, record.Visibility
+		record.Data = dataJSON
+		record.UpdatedAt = now
+		record.Revision++
+		if visibility != "" {
+			record.Visibility = visibility
+		}
+		return putDocument(documents, docID, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.broadcaster != nil {
+		event := models.ChangeEvent{
+			EventType:     "update",
+			DatabaseID:    dbID,
+			Collection:    collection,
+			DocumentID:    docID,
+			Data:          data,
+			Visibility:    record.Visibility,
+			OldVisibility: oldVisibility,
+			Timestamp:     time.Unix(now, 0),
+		}
+		json.Unmarshal(oldJSON, &event.OldData)
+		s.broadcaster.Broadcast(dbID, event)
+	}
+
+	return &models.Document{
+		ID:         docID,
+		Collection: collection,
+		Data:       data,
+		Visibility: record.Visibility,
+		CreatedAt:  time.Unix(record.CreatedAt, 0),
+		UpdatedAt:  time.Unix(now, 0),
+		Revision:   record.Revision,
+	}, nil
+}
+
+// DeleteDocument deletes a single document by ID
+// A nonzero revision must match the document's current revision
+func (s *Store) DeleteDocument(dbID string, collection string, docID string, revision int64) error {
+	var oldJSON json.RawMessage
+	var visibility models.Visibility
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		documents := collectionBucket(tx, dbID, collection)
+		if documents == nil {
+			return fmt.Errorf("document not found")
+		}
+		record, err := getDocument(documents, docID)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return fmt.Errorf("document not found")
+		}
+		if err := database.CheckRevision(revision, record.Revision); err != nil {
+			return err
+		}
+
+		if err := documents.Delete([]byte(docID)); err != nil {
+			return err
+		}
+		oldJSON, visibility = record.Data, record.Visibility
+		return adjustQuota(tx, dbID, -record.size())
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.broadcaster != nil {
+		event := models.ChangeEvent{
+			EventType:  "delete",
+			DatabaseID: dbID,
+			Collection: collection,
+			DocumentID: docID,
+			Visibility: visibility,
+			Timestamp:  time.Now(),
+		}
+		json.Unmarshal(oldJSON, &event.OldData)
+		s.broadcaster.Broadcast(dbID, event)
+	}
+
+	return nil
+}
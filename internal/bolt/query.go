@@ -0,0 +1,139 @@
+package bolt
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+// match is a document selected by a query, with its insertion order
+type match struct {
+	doc *models.Document
+	seq uint64
+}
+
+// visible reports whether a document has one of the readable visibility levels
+// Nil levels mean no restriction
+func visible(record *documentRecord, levels []models.Visibility) bool {
+	return levels == nil || slices.Contains(levels, record.Visibility)
+}
+
+// inTimeRange reports whether a document's timestamps fall within a time range
+// Timestamps are Unix seconds, so bounds are compared at second granularity as with SQLite
+func inTimeRange(record *documentRecord, tr *database.TimeRange) bool {
+	if tr == nil {
+		return true
+	}
+	if !tr.CreatedAfter.IsZero() && record.CreatedAt <= tr.CreatedAfter.Unix() {
+		return false
+	}
+	if !tr.CreatedBefore.IsZero() && record.CreatedAt >= tr.CreatedBefore.Unix() {
+		return false
+	}
+	if !tr.UpdatedAfter.IsZero() && record.UpdatedAt <= tr.UpdatedAfter.Unix() {
+		return false
+	}
+	if !tr.UpdatedBefore.IsZero() && record.UpdatedAt >= tr.UpdatedBefore.Unix() {
+		return false
+	}
+	return true
+}
+
+// sortDocuments orders matches by sort keys, newest first when there are none
+// Ties keep insertion order, or its reverse when newest first
+func sortDocuments(matched []match, order []database.SortKey) {
+	if len(order) == 0 {
+		slices.SortFunc(matched, func(a, b match) int {
+			return cmp.Or(
+				-compareValues(sortValue(a.doc, "created_at"), sortValue(b.doc, "created_at")),
+				-cmp.Compare(a.seq, b.seq),
+			)
+		})
+		return
+	}
+	slices.SortFunc(matched, func(a, b match) int {
+		for _, key := range order {
+			c := compareValues(sortValue(a.doc, key.Field), sortValue(b.doc, key.Field))
+			if key.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.seq, b.seq)
+	})
+}
+
+// sortValue returns a metadata column or data field of a document for sorting
+func sortValue(doc *models.Document, field string) interface{} {
+	switch field {
+	case "id":
+		return doc.ID
+	case "created_at":
+		return float64(doc.CreatedAt.Unix())
+	case "updated_at":
+		return float64(doc.UpdatedAt.Unix())
+	default:
+		return doc.Data[field]
+	}
+}
+
+// compareValues orders JSON values as SQLite orders the values json_extract yields:
+// missing values first, then booleans and numbers (booleans as 0 and 1), then strings
+// Other values, such as objects, sort last and equal to each other
+func compareValues(a, b interface{}) int {
+	rankA, numA, strA := sortRank(a)
+	rankB, numB, strB := sortRank(b)
+	if rankA != rankB {
+		return cmp.Compare(rankA, rankB)
+	}
+	switch rankA {
+	case 1:
+		return cmp.Compare(numA, numB)
+	case 2:
+		return strings.Compare(strA, strB)
+	default:
+		return 0
+	}
+}
+
+// sortRank classifies a value for compareValues, returning its numeric or string form
+func sortRank(v interface{}) (int, float64, string) {
+	switch v := v.(type) {
+	case nil:
+		return 0, 0, ""
+	case bool:
+		if v {
+			return 1, 1, ""
+		}
+		return 1, 0, ""
+	case float64:
+		return 1, v, ""
+	case string:
+		return 2, 0, v
+	default:
+		return 3, 0, ""
+	}
+}
+
+// paginate returns the page of matches after offset, at most limit long
+// A limit of zero or less means no limit
+func paginate(matched []match, limit int, offset int) []*models.Document {
+	if offset >= len(matched) {
+		return nil
+	}
+	matched = matched[max(offset, 0):]
+	if limit > 0 && limit < len(matched) {
+		matched = matched[:limit]
+	}
+
+	documents := make([]*models.Document, len(matched))
+	for i, m := range matched {
+		documents[i] = m.doc
+	}
+	return documents
+}
@@ -0,0 +1,74 @@
+package bolt
+
+import (
+	"testing"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+func TestCompareValues(t *testing.T) {
+	// Ascending order: missing, booleans and numbers, strings, other values
+	ordered := []interface{}{nil, false, 0.5, true, 2.0, "10", "9", map[string]interface{}{}}
+	for i := 0; i < len(ordered)-1; i++ {
+		if c := compareValues(ordered[i], ordered[i+1]); c >= 0 {
+			t.Errorf("compareValues(%v, %v) = %d, want < 0", ordered[i], ordered[i+1], c)
+		}
+		if c := compareValues(ordered[i+1], ordered[i]); c <= 0 {
+			t.Errorf("compareValues(%v, %v) = %d, want > 0", ordered[i+1], ordered[i], c)
+		}
+	}
+	if c := compareValues(1.0, true); c != 0 {
+		t.Errorf("compareValues(1, true) = %d, want 0", c)
+	}
+}
+
+func TestInTimeRange(t *testing.T) {
+	record := &documentRecord{CreatedAt: 1000, UpdatedAt: 2000}
+	tests := []struct {
+		name string
+		tr   *database.TimeRange
+		want bool
+	}{
+		{name: "nil", want: true},
+		{name: "created after", tr: &database.TimeRange{CreatedAfter: time.Unix(999, 0)}, want: true},
+		{name: "bounds are exclusive", tr: &database.TimeRange{CreatedAfter: time.Unix(1000, 0)}, want: false},
+		{name: "updated before", tr: &database.TimeRange{UpdatedBefore: time.Unix(2000, 0)}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inTimeRange(record, tt.tr); got != tt.want {
+				t.Errorf("inTimeRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	matched := make([]match, 5)
+	for i := range matched {
+		matched[i] = match{doc: &models.Document{ID: string(rune('a' + i))}, seq: uint64(i)}
+	}
+
+	tests := []struct {
+		limit, offset int
+		want          string
+	}{
+		{0, 0, "abcde"},
+		{2, 0, "ab"},
+		{2, 4, "e"},
+		{0, 3, "de"},
+		{1, 5, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		for _, doc := range paginate(matched, tt.limit, tt.offset) {
+			got += doc.ID
+		}
+		if got != tt.want {
+			t.Errorf("paginate(limit %d, offset %d) = %q, want %q", tt.limit, tt.offset, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// schemaRecord is a schema as stored in a database's schemas bucket
+type schemaRecord struct {
+	Fields     map[string]models.FieldType `json:"fields"`
+	ReadFilter string                      `json:"read_filter,omitempty"`
+	CreatedAt  int64                       `json:"created_at"`
+}
+
+// CreateSchema creates a new schema for a collection; masking fields needs the catalog
+func (s *Store) CreateSchema(dbID string, name string, fields map[string]models.FieldType, masked []string) (*models.Schema, error) {
+	if err := database.ValidateSchema(name, fields); err != nil {
+		return nil, err
+	}
+	if len(masked) > 0 {
+		return nil, fmt.Errorf("masked fields require the sqlite storage backend")
+	}
+	if err := s.limits.CheckFields(len(fields)); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		dbBucket := tx.Bucket([]byte(dbID))
+		if dbBucket == nil {
+			return fmt.Errorf("database not found")
+		}
+		schemas := dbBucket.Bucket(schemasBucket)
+		if schemas.Get([]byte(name)) != nil {
+			return fmt.Errorf("schema already exists: %s", name)
+		}
+		count := 0
+		schemas.ForEach(func(_, _ []byte) error {
+			count++
+			return nil
+		})
+		if err := s.limits.CheckCollections(count); err != nil {
+			return err
+		}
+
+		if err := putSchema(schemas, name, &schemaRecord{Fields: fields, CreatedAt: now}); err != nil {
+			return err
+		}
+		_, err := dbBucket.Bucket(collectionsBucket).CreateBucket([]byte(name))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	if s.broadcaster != nil {
+		s.broadcaster.Broadcast(dbID, models.ChangeEvent{
+			EventType:  "schema_created",
+			DatabaseID: dbID,
+			Collection: name,
+			Data: map[string]interface{}{
+				"schema_name": name,
+				"fields":      fields,
+			},
+			Timestamp: time.Unix(now, 0),
+		})
+	}
+
+	return &models.Schema{
+		DatabaseID: dbID,
+		Name:       name,
+		Fields:     fields,
+		CreatedAt:  time.Unix(now, 0),
+	}, nil
+}
+
+// GetSchema retrieves a schema by database ID and name
+func (s *Store) GetSchema(dbID string, name string) (*models.Schema, error) {
+	var schema *models.Schema
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		dbBucket := tx.Bucket([]byte(dbID))
+		if dbBucket == nil {
+			return nil
+		}
+		record, err := getSchema(dbBucket.Bucket(schemasBucket), name)
+		if record != nil {
+			schema = &models.Schema{
+				DatabaseID: dbID,
+				Name:       name,
+				Fields:     record.Fields,
+				ReadFilter: record.ReadFilter,
+				CreatedAt:  time.Unix(record.CreatedAt, 0),
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema: %w", err)
+	}
+	return schema, nil
+}
+
+// SetReadFilter sets the row-level read filter expression for a collection
+// An empty expression removes the filter
+func (s *Store) SetReadFilter(dbID string, name string, expression string) (*models.Schema, error) {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		dbBucket := tx.Bucket([]byte(dbID))
+		if dbBucket == nil {
+			return fmt.Errorf("schema not found")
+		}
+		schemas := dbBucket.Bucket(schemasBucket)
+		record, err := getSchema(schemas, name)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return fmt.Errorf("schema not found")
+		}
+		record.ReadFilter = expression
+		return putSchema(schemas, name, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetSchema(dbID, name)
+}
+
+// DeleteSchema deletes a schema and its documents, giving back their quota
+func (s *Store) DeleteSchema(dbID string, name string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		dbBucket := tx.Bucket([]byte(dbID))
+		if dbBucket == nil || dbBucket.Bucket(schemasBucket).Get([]byte(name)) == nil {
+			return fmt.Errorf("schema not found")
+		}
+
+		var size int64
+		collections := dbBucket.Bucket(collectionsBucket)
+		err := collections.Bucket([]byte(name)).ForEach(func(_, value []byte) error {
+			var doc documentRecord
+			if err := json.Unmarshal(value, &doc); err != nil {
+				return fmt.Errorf("failed to unmarshal document: %w", err)
+			}
+			size += doc.size()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := dbBucket.Bucket(schemasBucket).Delete([]byte(name)); err != nil {
+			return err
+		}
+		if err := collections.DeleteBucket([]byte(name)); err != nil {
+			return err
+		}
+		return adjustQuota(tx, dbID, -size)
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.broadcaster != nil {
+		s.broadcaster.Broadcast(dbID, models.ChangeEvent{
+			EventType:  "schema_deleted",
+			DatabaseID: dbID,
+			Collection: name,
+			Data: map[string]interface{}{
+				"schema_name": name,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+
+	return nil
+}
+
+// getSchema reads a schema's record, or nil if it doesn't exist
+func getSchema(schemas *bbolt.Bucket, name string) (*schemaRecord, error) {
+	value := schemas.Get([]byte(name))
+	if value == nil {
+		return nil, nil
+	}
+	var record schemaRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schema: %w", err)
+	}
+	return &record, nil
+}
+
+// putSchema writes a schema's record
+func putSchema(schemas *bbolt.Bucket, name string, record *schemaRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	return schemas.Put([]byte(name), value)
+}
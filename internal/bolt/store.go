@@ -0,0 +1,523 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// All databases share one bbolt file. The databases bucket holds each database's record and
+// the keys bucket maps write and read keys to database IDs. Each database has a bucket of its
+// own, named by its ID, holding a schemas bucket and a collections bucket with a nested bucket
+// of documents per collection. Keys replaced with a grace period stay in the keys bucket until
+// the next rotation, and the record says until when they work. bbolt runs one write transaction at a time, so quota checks
+// and the writes they guard are atomic.
+
+var (
+	databasesBucket   = []byte("databases")
+	keysBucket        = []byte("keys")
+	schemasBucket     = []byte("schemas")
+	collectionsBucket = []byte("collections")
+)
+
+// databaseRecord is a database as stored in the databases bucket
+type databaseRecord struct {
+	WriteKey     string `json:"write_key"`
+	ReadKey      string `json:"read_key"`
+	CreatedAt    int64  `json:"created_at"`
+	LastAccessed int64  `json:"last_accessed"`
+	QuotaUsed    int64  `json:"quota_used"`
+	QuotaLimit   int64  `json:"quota_limit"`
+
+	// Keys replaced by the last rotation, and the Unix time they stop working
+	PreviousWriteKey        string `json:"previous_write_key,omitempty"`
+	PreviousWriteKeyExpires int64  `json:"previous_write_key_expires,omitempty"`
+	PreviousReadKey         string `json:"previous_read_key,omitempty"`
+	PreviousReadKeyExpires  int64  `json:"previous_read_key_expires,omitempty"`
+
+	AllowedNetworks []string `json:"allowed_networks,omitempty"` // CIDR ranges the keys work from
+	CORSOrigins     []string `json:"cors_origins,omitempty"`     // Browser origins allowed besides CORS_ORIGINS
+	Protected       bool     `json:"protected,omitempty"`        // Deletions need confirmation
+	KeyGeneration   int64    `json:"key_generation,omitempty"`   // Counts key rotations
+}
+
+// Store keeps databases in an embedded bbolt file, without cgo
+type Store struct {
+	db           *bbolt.DB
+	defaultQuota int64 // bytes
+	limits       database.Limits
+	broadcaster  database.EventBroadcaster
+	keys         database.KeyGenerator
+}
+
+var _ database.Store = (*Store)(nil)
+
+// Open opens or creates the bbolt file at path
+func Open(path string, defaultQuotaMB int64, limits database.Limits, broadcaster database.EventBroadcaster, keys database.KeyGenerator) (*Store, error) {
+	if keys == nil {
+		keys = database.RandomKeys{}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create bolt directory: %w", err)
+	}
+
+	// A second server on the same file waits for the lock instead of failing at once
+	db, err := bbolt.Open(path, 0644, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %w", err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{databasesBucket, keysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize bolt database: %w", err)
+	}
+
+	return &Store{
+		db:           db,
+		defaultQuota: defaultQuotaMB * 1024 * 1024,
+		limits:       limits,
+		broadcaster:  broadcaster,
+		keys:         keys,
+	}, nil
+}
+
+// Close closes the bbolt file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Limits returns the configured schema limits
+func (s *Store) Limits() database.Limits {
+	return s.limits
+}
+
+// CreateDatabase creates a database with generated identifiers
+func (s *Store) CreateDatabase() (*models.CreateDatabaseResponse, error) {
+	dbID, err := s.keys.DatabaseID()
+	if err != nil {
+		return nil, err
+	}
+	writeKey, err := s.keys.WriteKey()
+	if err != nil {
+		return nil, err
+	}
+	readKey, err := s.keys.ReadKey()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(dbID, "db_") || !strings.HasPrefix(writeKey, "wk_") || !strings.HasPrefix(readKey, "rk_") {
+		return nil, fmt.Errorf("invalid database identifiers: expected db_, wk_ and rk_ prefixes")
+	}
+
+	now := time.Now().Unix()
+	record := &databaseRecord{
+		WriteKey:     writeKey,
+		ReadKey:      readKey,
+		CreatedAt:    now,
+		LastAccessed: now,
+		QuotaLimit:   s.defaultQuota,
+	}
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		databases := tx.Bucket(databasesBucket)
+		keys := tx.Bucket(keysBucket)
+		if databases.Get([]byte(dbID)) != nil || keys.Get([]byte(writeKey)) != nil || keys.Get([]byte(readKey)) != nil {
+			return fmt.Errorf("database identifiers already in use")
+		}
+
+		if err := putDatabase(tx, dbID, record); err != nil {
+			return err
+		}
+		if err := keys.Put([]byte(writeKey), []byte(dbID)); err != nil {
+			return err
+		}
+		if err := keys.Put([]byte(readKey), []byte(dbID)); err != nil {
+			return err
+		}
+
+		dbBucket, err := tx.CreateBucket([]byte(dbID))
+		if err != nil {
+			return err
+		}
+		if _, err := dbBucket.CreateBucket(schemasBucket); err != nil {
+			return err
+		}
+		_, err = dbBucket.CreateBucket(collectionsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database entry: %w", err)
+	}
+
+	return &models.CreateDatabaseResponse{
+		DatabaseID: dbID,
+		WriteKey:   writeKey,
+		ReadKey:    readKey,
+	}, nil
+}
+
+// GetDatabaseByID retrieves a database by its ID
+func (s *Store) GetDatabaseByID(dbID string) (*models.Database, error) {
+	var db *models.Database
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		record, err := getDatabase(tx, dbID)
+		if record != nil {
+			db = record.toModel(dbID)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database: %w", err)
+	}
+	return db, nil
+}
+
+// GetDatabaseByWriteKey retrieves a database by its write key
+func (s *Store) GetDatabaseByWriteKey(writeKey string) (*models.Database, error) {
+	return s.getDatabaseByKey(writeKey, func(record *databaseRecord) (string, string, int64) {
+		return record.WriteKey, record.PreviousWriteKey, record.PreviousWriteKeyExpires
+	})
+}
+
+// GetDatabaseByReadKey retrieves a database by its read key
+func (s *Store) GetDatabaseByReadKey(readKey string) (*models.Database, error) {
+	return s.getDatabaseByKey(readKey, func(record *databaseRecord) (string, string, int64) {
+		return record.ReadKey, record.PreviousReadKey, record.PreviousReadKeyExpires
+	})
+}
+
+// getDatabaseByKey retrieves a database by an access key
+// Write and read keys share the keys bucket, so the record's own key must match: its
+// current one, or the one it replaced until that expires
+func (s *Store) getDatabaseByKey(key string, recordKeys func(*databaseRecord) (string, string, int64)) (*models.Database, error) {
+	var db *models.Database
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		dbID := tx.Bucket(keysBucket).Get([]byte(key))
+		if dbID == nil {
+			return nil
+		}
+		record, err := getDatabase(tx, string(dbID))
+		if record == nil {
+			return err
+		}
+		current, previous, expires := recordKeys(record)
+		if current == key || (previous == key && expires > time.Now().Unix()) {
+			db = record.toModel(string(dbID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database: %w", err)
+	}
+	return db, nil
+}
+
+// RotateKeys replaces a database's chosen keys with new ones
+// With a grace period the old keys keep working until it ends; keys replaced by an
+// earlier rotation stop working at once
+func (s *Store) RotateKeys(dbID string, write bool, read bool, grace time.Duration) (*models.RotateKeysResponse, error) {
+	writeKey, readKey, err := database.RotatedKeys(s.keys, write, read)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.RotateKeysResponse{DatabaseID: dbID, WriteKey: writeKey, ReadKey: readKey}
+	var expires int64
+	if grace > 0 {
+		expiresAt := time.Now().Add(grace)
+		resp.OldKeysExpire = &expiresAt
+		expires = expiresAt.Unix()
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		record, err := getDatabase(tx, dbID)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return fmt.Errorf("database not found")
+		}
+
+		keys := tx.Bucket(keysBucket)
+		if writeKey != "" {
+			if err := rotateKey(keys, dbID, writeKey, expires, &record.WriteKey, &record.PreviousWriteKey, &record.PreviousWriteKeyExpires); err != nil {
+				return err
+			}
+		}
+		if readKey != "" {
+			if err := rotateKey(keys, dbID, readKey, expires, &record.ReadKey, &record.PreviousReadKey, &record.PreviousReadKeyExpires); err != nil {
+				return err
+			}
+		}
+		// Access tokens minted before the rotation stop working at once
+		record.KeyGeneration++
+		return putDatabase(tx, dbID, record)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate keys: %w", err)
+	}
+	return resp, nil
+}
+
+// rotateKey replaces a record's current key with key in the keys bucket, keeping the old
+// one until expires, or dropping it when expires is 0. The key it replaced before is dropped
+func rotateKey(keys *bbolt.Bucket, dbID string, key string, expires int64, current *string, previous *string, previousExpires *int64) error {
+	if keys.Get([]byte(key)) != nil {
+		return fmt.Errorf("database identifiers already in use")
+	}
+	if *previous != "" {
+		if err := keys.Delete([]byte(*previous)); err != nil {
+			return err
+		}
+	}
+	if expires > 0 {
+		*previous, *previousExpires = *current, expires
+	} else {
+		if err := keys.Delete([]byte(*current)); err != nil {
+			return err
+		}
+		*previous, *previousExpires = "", 0
+	}
+	*current = key
+	return keys.Put([]byte(key), []byte(dbID))
+}
+
+// SetAllowedNetworks replaces the IP allowlist of a database; an empty list allows every address
+func (s *Store) SetAllowedNetworks(dbID string, networks []string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		record, err := getDatabase(tx, dbID)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return fmt.Errorf("database not found")
+		}
+		record.AllowedNetworks = networks
+		return putDatabase(tx, dbID, record)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set allowed networks: %w", err)
+	}
+	return nil
+}
+
+// SetCORSOrigins replaces the browser origins allowed besides CORS_ORIGINS
+func (s *Store) SetCORSOrigins(dbID string, origins []string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		record, err := getDatabase(tx, dbID)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return fmt.Errorf("database not found")
+		}
+		record.CORSOrigins = origins
+		return putDatabase(tx, dbID, record)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set CORS origins: %w", err)
+	}
+	return nil
+}
+
+// SetProtected sets whether deleting a database or its schemas needs confirmation
+func (s *Store) SetProtected(dbID string, protected bool) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		record, err := getDatabase(tx, dbID)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return fmt.Errorf("database not found")
+		}
+		record.Protected = protected
+		return putDatabase(tx, dbID, record)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set protection: %w", err)
+	}
+	return nil
+}
+
+// CountDatabases returns how many databases the store holds
+func (s *Store) CountDatabases() (int, error) {
+	var count int
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		count = tx.Bucket(databasesBucket).Stats().KeyN
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count databases: %w", err)
+	}
+	return count, nil
+}
+
+// UpdateLastAccessed updates the last_accessed timestamp for a database
+func (s *Store) UpdateLastAccessed(dbID string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		record, err := getDatabase(tx, dbID)
+		if err != nil || record == nil {
+			return err
+		}
+		record.LastAccessed = time.Now().Unix()
+		return putDatabase(tx, dbID, record)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update last_accessed: %w", err)
+	}
+	return nil
+}
+
+// DeleteDatabase removes a database with its schemas and documents
+func (s *Store) DeleteDatabase(dbID string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		record, err := getDatabase(tx, dbID)
+		if err != nil || record == nil {
+			return err
+		}
+
+		keys := tx.Bucket(keysBucket)
+		if err := keys.Delete([]byte(record.WriteKey)); err != nil {
+			return err
+		}
+		if err := keys.Delete([]byte(record.ReadKey)); err != nil {
+			return err
+		}
+		for _, previous := range []string{record.PreviousWriteKey, record.PreviousReadKey} {
+			if previous == "" {
+				continue
+			}
+			if err := keys.Delete([]byte(previous)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(databasesBucket).Delete([]byte(dbID)); err != nil {
+			return err
+		}
+		return tx.DeleteBucket([]byte(dbID))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete database: %w", err)
+	}
+	return nil
+}
+
+// RecalculateQuota recomputes a database's quota usage from its stored documents
+// Usage is kept in step with writes, so this only corrects changes made outside the server
+func (s *Store) RecalculateQuota(dbID string) (*models.QuotaRecalculationResponse, error) {
+	var resp *models.QuotaRecalculationResponse
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		record, err := getDatabase(tx, dbID)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return fmt.Errorf("database not found")
+		}
+
+		var used int64
+		collections := tx.Bucket([]byte(dbID)).Bucket(collectionsBucket)
+		err = collections.ForEachBucket(func(name []byte) error {
+			return collections.Bucket(name).ForEach(func(_, value []byte) error {
+				var doc documentRecord
+				if err := json.Unmarshal(value, &doc); err != nil {
+					return fmt.Errorf("failed to unmarshal document: %w", err)
+				}
+				used += doc.size()
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		resp = &models.QuotaRecalculationResponse{
+			DatabaseID:        dbID,
+			PreviousQuotaUsed: record.QuotaUsed,
+			QuotaUsed:         used,
+			QuotaLimit:        record.QuotaLimit,
+		}
+		record.QuotaUsed = used
+		return putDatabase(tx, dbID, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// getDatabase reads a database's record, or nil if it doesn't exist
+func getDatabase(tx *bbolt.Tx, dbID string) (*databaseRecord, error) {
+	value := tx.Bucket(databasesBucket).Get([]byte(dbID))
+	if value == nil {
+		return nil, nil
+	}
+	var record databaseRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal database: %w", err)
+	}
+	return &record, nil
+}
+
+// putDatabase writes a database's record
+func putDatabase(tx *bbolt.Tx, dbID string, record *databaseRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal database: %w", err)
+	}
+	return tx.Bucket(databasesBucket).Put([]byte(dbID), value)
+}
+
+// toModel converts a stored record to the API model
+func (r *databaseRecord) toModel(dbID string) *models.Database {
+	return &models.Database{
+		ID:              dbID,
+		WriteKey:        r.WriteKey,
+		ReadKey:         r.ReadKey,
+		CreatedAt:       time.Unix(r.CreatedAt, 0),
+		LastAccessed:    time.Unix(r.LastAccessed, 0),
+		QuotaUsed:       r.QuotaUsed,
+		QuotaLimit:      r.QuotaLimit,
+		AllowedNetworks: r.AllowedNetworks,
+		CORSOrigins:     r.CORSOrigins,
+		Protected:       r.Protected,
+		KeyGeneration:   r.KeyGeneration,
+	}
+}
+
+// adjustQuota changes a database's quota usage by delta bytes within a write transaction
+// Growth beyond the limit fails; usage never drops below zero
+func adjustQuota(tx *bbolt.Tx, dbID string, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	record, err := getDatabase(tx, dbID)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return fmt.Errorf("database not found")
+	}
+
+	if delta > 0 && record.QuotaUsed+delta > record.QuotaLimit {
+		return fmt.Errorf("quota exceeded: current %d bytes, limit %d bytes, attempted to add %d bytes",
+			record.QuotaUsed, record.QuotaLimit, delta)
+	}
+	record.QuotaUsed = max(record.QuotaUsed+delta, 0)
+	return putDatabase(tx, dbID, record)
+}
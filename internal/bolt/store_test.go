@@ -0,0 +1,289 @@
+package bolt
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "jsondrop.bolt"), 1, database.Limits{MaxCollections: 2}, nil, nil)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStore_Documents(t *testing.T) {
+	s := openTestStore(t)
+
+	created, err := s.CreateDatabase()
+	if err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	dbID := created.DatabaseID
+	if db, _ := s.GetDatabaseByReadKey(created.ReadKey); db == nil || db.ID != dbID {
+		t.Fatalf("GetDatabaseByReadKey() = %v, want %s", db, dbID)
+	}
+	if db, _ := s.GetDatabaseByWriteKey(created.ReadKey); db != nil {
+		t.Errorf("GetDatabaseByWriteKey(read key) = %v, want nil", db)
+	}
+
+	fields := map[string]models.FieldType{"title": models.FieldTypeString, "n": models.FieldTypeNumber}
+	if _, err := s.CreateSchema(dbID, "posts", fields, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	if _, err := s.CreateSchema(dbID, "posts", fields, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("CreateSchema(duplicate) error = %v, want already exists", err)
+	}
+
+	for i, title := range []string{"a", "b", "c"} {
+		if _, err := s.InsertDocument(dbID, "posts", map[string]interface{}{"title": title, "n": float64(i)}, ""); err != nil {
+			t.Fatalf("InsertDocument() error = %v", err)
+		}
+	}
+	hidden, err := s.InsertDocument(dbID, "posts", map[string]interface{}{"title": "d", "n": float64(3)}, models.VisibilityWriteKeyOnly)
+	if err != nil {
+		t.Fatalf("InsertDocument() error = %v", err)
+	}
+
+	// Newest first, with visibility and filters applied before pagination
+	scope := &database.ReadScope{Visible: models.VisibleLevels(true, false)}
+	docs, err := s.QueryDocuments(dbID, "posts", 0, 0, nil, nil, nil, scope)
+	if err != nil {
+		t.Fatalf("QueryDocuments() error = %v", err)
+	}
+	if got := titles(docs); got != "c,b,a" {
+		t.Errorf("QueryDocuments() = %s, want c,b,a", got)
+	}
+	filters := []database.Filter{{Field: "n", Type: models.FieldTypeNumber, Values: []string{"0", "2", "3"}}}
+	docs, _ = s.QueryDocuments(dbID, "posts", 1, 1, filters, nil, []database.SortKey{{Field: "title"}}, scope)
+	if got := titles(docs); got != "c" {
+		t.Errorf("QueryDocuments(filtered page) = %s, want c", got)
+	}
+	if doc, _ := s.GetDocument(dbID, "posts", hidden.ID, scope); doc != nil {
+		t.Errorf("GetDocument(write_key_only) = %v, want nil for a read key", doc)
+	}
+
+	updated, err := s.UpdateDocument(dbID, "posts", hidden.ID, map[string]interface{}{"title": "e"}, "", 1)
+	if err != nil {
+		t.Fatalf("UpdateDocument() error = %v", err)
+	}
+	if updated.Visibility != models.VisibilityWriteKeyOnly {
+		t.Errorf("UpdateDocument() visibility = %s, want it kept", updated.Visibility)
+	}
+	if updated.Revision != 2 {
+		t.Errorf("UpdateDocument() revision = %d, want 2", updated.Revision)
+	}
+	if _, err := s.UpdateDocument(dbID, "posts", hidden.ID, map[string]interface{}{"title": "f"}, "", 1); err == nil || !strings.Contains(err.Error(), "revision mismatch") {
+		t.Errorf("UpdateDocument(stale revision) error = %v, want revision mismatch", err)
+	}
+
+	if err := s.DeleteDocument(dbID, "posts", hidden.ID, 1); err == nil || !strings.Contains(err.Error(), "revision mismatch") {
+		t.Errorf("DeleteDocument(stale revision) error = %v, want revision mismatch", err)
+	}
+	if err := s.DeleteDocument(dbID, "posts", hidden.ID, 2); err != nil {
+		t.Fatalf("DeleteDocument() error = %v", err)
+	}
+	if err := s.DeleteDocument(dbID, "posts", hidden.ID, 0); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("DeleteDocument(again) error = %v, want not found", err)
+	}
+
+	// Tracked usage matches the stored documents after every write
+	db, _ := s.GetDatabaseByID(dbID)
+	recalc, err := s.RecalculateQuota(dbID)
+	if err != nil {
+		t.Fatalf("RecalculateQuota() error = %v", err)
+	}
+	if recalc.QuotaUsed != db.QuotaUsed || db.QuotaUsed == 0 {
+		t.Errorf("QuotaUsed = %d, recalculated %d", db.QuotaUsed, recalc.QuotaUsed)
+	}
+
+	if err := s.DeleteSchema(dbID, "posts"); err != nil {
+		t.Fatalf("DeleteSchema() error = %v", err)
+	}
+	if db, _ := s.GetDatabaseByID(dbID); db.QuotaUsed != 0 {
+		t.Errorf("QuotaUsed after DeleteSchema = %d, want 0", db.QuotaUsed)
+	}
+
+	if err := s.DeleteDatabase(dbID); err != nil {
+		t.Fatalf("DeleteDatabase() error = %v", err)
+	}
+	if db, _ := s.GetDatabaseByWriteKey(created.WriteKey); db != nil {
+		t.Errorf("GetDatabaseByWriteKey() after delete = %v, want nil", db)
+	}
+}
+
+func TestStore_Limits(t *testing.T) {
+	s := openTestStore(t)
+
+	created, err := s.CreateDatabase()
+	if err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	dbID := created.DatabaseID
+	fields := map[string]models.FieldType{"body": models.FieldTypeString}
+	for _, name := range []string{"a", "b"} {
+		if _, err := s.CreateSchema(dbID, name, fields, nil); err != nil {
+			t.Fatalf("CreateSchema(%s) error = %v", name, err)
+		}
+	}
+	if _, err := s.CreateSchema(dbID, "c", fields, nil); err == nil || !strings.Contains(err.Error(), "limit exceeded") {
+		t.Errorf("CreateSchema(over limit) error = %v, want limit exceeded", err)
+	}
+
+	// The quota is 1 MB; a document over it is rejected without being stored
+	big := map[string]interface{}{"body": strings.Repeat("x", 1024*1024)}
+	if _, err := s.InsertDocument(dbID, "a", big, ""); err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("InsertDocument(over quota) error = %v, want quota exceeded", err)
+	}
+	if docs, _ := s.QueryDocuments(dbID, "a", 0, 0, nil, nil, nil, nil); len(docs) != 0 {
+		t.Errorf("QueryDocuments() = %d documents, want 0", len(docs))
+	}
+}
+
+func titles(docs []*models.Document) string {
+	var names []string
+	for _, doc := range docs {
+		names = append(names, doc.Data["title"].(string))
+	}
+	return strings.Join(names, ",")
+}
+
+func TestStore_RotateKeys(t *testing.T) {
+	s := openTestStore(t)
+
+	created, err := s.CreateDatabase()
+	if err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	dbID := created.DatabaseID
+	found := func(db *models.Database, err error) bool {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("get database error = %v", err)
+		}
+		return db != nil && db.ID == dbID
+	}
+
+	rotated, err := s.RotateKeys(dbID, false, true, time.Hour)
+	if err != nil {
+		t.Fatalf("RotateKeys(read, 1h) error = %v", err)
+	}
+	if !found(s.GetDatabaseByReadKey(created.ReadKey)) || !found(s.GetDatabaseByReadKey(rotated.ReadKey)) {
+		t.Error("old and new read keys don't both work during the grace period")
+	}
+	if found(s.GetDatabaseByWriteKey(created.ReadKey)) {
+		t.Error("old read key works as a write key")
+	}
+
+	// Rotating again drops the key replaced before, and without a grace period the current one
+	again, err := s.RotateKeys(dbID, true, true, 0)
+	if err != nil {
+		t.Fatalf("RotateKeys(both, 0) error = %v", err)
+	}
+	for _, key := range []string{created.ReadKey, rotated.ReadKey} {
+		if found(s.GetDatabaseByReadKey(key)) {
+			t.Errorf("revoked read key %s still works", key)
+		}
+	}
+	if found(s.GetDatabaseByWriteKey(created.WriteKey)) {
+		t.Error("revoked write key still works")
+	}
+	if !found(s.GetDatabaseByWriteKey(again.WriteKey)) || !found(s.GetDatabaseByReadKey(again.ReadKey)) {
+		t.Error("new keys don't work")
+	}
+	if db, err := s.GetDatabaseByID(dbID); err != nil || db.KeyGeneration != 2 {
+		t.Errorf("GetDatabaseByID() after two rotations = %+v, %v, want key generation 2", db, err)
+	}
+
+	// Deleting the database frees every key
+	if _, err := s.RotateKeys(dbID, true, false, time.Hour); err != nil {
+		t.Fatalf("RotateKeys(write, 1h) error = %v", err)
+	}
+	if err := s.DeleteDatabase(dbID); err != nil {
+		t.Fatalf("DeleteDatabase() error = %v", err)
+	}
+	s.db.View(func(tx *bbolt.Tx) error {
+		if n := tx.Bucket(keysBucket).Stats().KeyN; n != 0 {
+			t.Errorf("keys bucket holds %d keys after DeleteDatabase, want 0", n)
+		}
+		return nil
+	})
+	if _, err := s.RotateKeys(dbID, true, false, 0); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("RotateKeys(deleted database) error = %v, want not found", err)
+	}
+}
+
+func TestStore_SetAllowedNetworks(t *testing.T) {
+	s := openTestStore(t)
+
+	created, err := s.CreateDatabase()
+	if err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	networks := []string{"10.0.0.0/8", "2001:db8::/32"}
+	if err := s.SetAllowedNetworks(created.DatabaseID, networks); err != nil {
+		t.Fatalf("SetAllowedNetworks() error = %v", err)
+	}
+	db, err := s.GetDatabaseByReadKey(created.ReadKey)
+	if err != nil || db == nil {
+		t.Fatalf("GetDatabaseByReadKey() = %v, %v", db, err)
+	}
+	if strings.Join(db.AllowedNetworks, " ") != "10.0.0.0/8 2001:db8::/32" {
+		t.Errorf("AllowedNetworks = %v, want %v", db.AllowedNetworks, networks)
+	}
+	if err := s.SetAllowedNetworks("db_missing", networks); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("SetAllowedNetworks(missing database) error = %v, want not found", err)
+	}
+}
+
+func TestStore_SetCORSOrigins(t *testing.T) {
+	s := openTestStore(t)
+
+	created, err := s.CreateDatabase()
+	if err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	origins := []string{"http://localhost:3000", "https://app.example.com"}
+	if err := s.SetCORSOrigins(created.DatabaseID, origins); err != nil {
+		t.Fatalf("SetCORSOrigins() error = %v", err)
+	}
+	db, err := s.GetDatabaseByID(created.DatabaseID)
+	if err != nil || db == nil {
+		t.Fatalf("GetDatabaseByID() = %v, %v", db, err)
+	}
+	if strings.Join(db.CORSOrigins, " ") != "http://localhost:3000 https://app.example.com" {
+		t.Errorf("CORSOrigins = %v, want %v", db.CORSOrigins, origins)
+	}
+	if err := s.SetCORSOrigins("db_missing", origins); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("SetCORSOrigins(missing database) error = %v, want not found", err)
+	}
+}
+
+func TestStore_CountDatabases(t *testing.T) {
+	s := openTestStore(t)
+
+	var ids []string
+	for range 3 {
+		created, err := s.CreateDatabase()
+		if err != nil {
+			t.Fatalf("CreateDatabase() error = %v", err)
+		}
+		ids = append(ids, created.DatabaseID)
+	}
+	if err := s.DeleteDatabase(ids[0]); err != nil {
+		t.Fatalf("DeleteDatabase() error = %v", err)
+	}
+	if count, err := s.CountDatabases(); err != nil || count != 2 {
+		t.Errorf("CountDatabases() = %d, %v; want 2, nil", count, err)
+	}
+}
@@ -0,0 +1,49 @@
+// Package clientaddr finds the address a request came from, for allowlists, lockouts
+// and rate limits
+package clientaddr
+
+import (
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// FromRequest returns the address a request came from: its peer's, or when the peer is
+// a trusted proxy, the last address in X-Forwarded-For not belonging to one
+func FromRequest(r *http.Request, proxies []netip.Prefix) (netip.Addr, bool) {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	trusted := func(addr netip.Addr) bool {
+		return slices.ContainsFunc(proxies, func(proxy netip.Prefix) bool { return proxy.Contains(addr) })
+	}
+
+	addr := peer.Addr().Unmap()
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0 && trusted(addr); i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			break
+		}
+		next, err := netip.ParseAddr(hop)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		addr = next.Unmap()
+	}
+	return addr, true
+}
+
+// Key groups IPv6 clients by their /64, which a single host usually controls whole, for
+// limits counted per client
+func Key(addr netip.Addr) netip.Prefix {
+	addr = addr.Unmap()
+	bits := addr.BitLen()
+	if addr.Is6() {
+		bits = 64
+	}
+	prefix, _ := addr.Prefix(bits)
+	return prefix
+}
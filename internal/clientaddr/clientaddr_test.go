@@ -0,0 +1,58 @@
+package clientaddr
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestFromRequest(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded string
+		want      string
+	}{
+		{"direct", "203.0.113.7:1234", "", "203.0.113.7"},
+		{"untrusted peer's header ignored", "203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"through a trusted proxy", "10.0.0.2:1234", "198.51.100.1", "198.51.100.1"},
+		{"through several proxies", "10.0.0.2:1234", "198.51.100.1, 10.0.0.3", "198.51.100.1"},
+		{"spoofed hop before the client", "10.0.0.2:1234", "10.9.9.9, 198.51.100.1", "198.51.100.1"},
+		{"mapped IPv4", "[::ffff:203.0.113.7]:1234", "", "203.0.113.7"},
+		{"invalid hop", "10.0.0.2:1234", "nonsense", ""},
+		{"no peer", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.peer
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			got := ""
+			if addr, ok := FromRequest(r, proxies); ok {
+				got = addr.String()
+			}
+			if got != tt.want {
+				t.Errorf("FromRequest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"203.0.113.7", "203.0.113.7/32"},
+		{"::ffff:203.0.113.7", "203.0.113.7/32"},
+		{"2001:db8:1:2:ffff::9", "2001:db8:1:2::/64"},
+	}
+	for _, tt := range tests {
+		if got := Key(netip.MustParseAddr(tt.addr)); got.String() != tt.want {
+			t.Errorf("Key(%s) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Access log formats
+const (
+	AccessLogCombined = "combined" // Apache/NCSA combined log format
+	AccessLogJSON     = "json"     // One JSON object per line
+)
+
+// AccessLogConfig controls the API access log, written separately from application logs
+type AccessLogConfig struct {
+	Path       string // Empty disables the access log
+	Format     string
+	MaxSizeMB  int64 // Rotate when the file would exceed this size; 0 disables rotation
+	MaxBackups int   // Rotated files to keep
+}
+
+// loadAccessLogConfig reads the ACCESS_LOG_* environment variables
+func loadAccessLogConfig() (AccessLogConfig, error) {
+	cfg := AccessLogConfig{
+		Path:   getEnv("ACCESS_LOG_FILE", ""),
+		Format: getEnv("ACCESS_LOG_FORMAT", AccessLogCombined),
+	}
+
+	if cfg.Format != AccessLogCombined && cfg.Format != AccessLogJSON {
+		return cfg, fmt.Errorf("invalid ACCESS_LOG_FORMAT: %q (expected %s or %s)", cfg.Format, AccessLogCombined, AccessLogJSON)
+	}
+
+	maxSize, err := strconv.ParseInt(getEnv("ACCESS_LOG_MAX_SIZE_MB", "100"), 10, 64)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid ACCESS_LOG_MAX_SIZE_MB: %w", err)
+	}
+	if maxSize < 0 {
+		return cfg, fmt.Errorf("ACCESS_LOG_MAX_SIZE_MB must not be negative, got %d", maxSize)
+	}
+	cfg.MaxSizeMB = maxSize
+
+	maxBackups, err := strconv.Atoi(getEnv("ACCESS_LOG_MAX_BACKUPS", "5"))
+	if err != nil {
+		return cfg, fmt.Errorf("invalid ACCESS_LOG_MAX_BACKUPS: %w", err)
+	}
+	if maxBackups < 0 {
+		return cfg, fmt.Errorf("ACCESS_LOG_MAX_BACKUPS must not be negative, got %d", maxBackups)
+	}
+	cfg.MaxBackups = maxBackups
+
+	return cfg, nil
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLoad_AccessLogDefaults(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	if cfg.AccessLog.Path != "" {
+		t.Errorf("AccessLog.Path = %s, want empty", cfg.AccessLog.Path)
+	}
+	if cfg.AccessLog.Format != AccessLogCombined {
+		t.Errorf("AccessLog.Format = %s, want %s", cfg.AccessLog.Format, AccessLogCombined)
+	}
+	if cfg.AccessLog.MaxSizeMB != 100 {
+		t.Errorf("AccessLog.MaxSizeMB = %d, want 100", cfg.AccessLog.MaxSizeMB)
+	}
+	if cfg.AccessLog.MaxBackups != 5 {
+		t.Errorf("AccessLog.MaxBackups = %d, want 5", cfg.AccessLog.MaxBackups)
+	}
+}
+
+func TestLoad_AccessLogCustom(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("ACCESS_LOG_FILE", "/var/log/jsondrop/access.log")
+	os.Setenv("ACCESS_LOG_FORMAT", "json")
+	os.Setenv("ACCESS_LOG_MAX_SIZE_MB", "0")
+	os.Setenv("ACCESS_LOG_MAX_BACKUPS", "10")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	if cfg.AccessLog.Path != "/var/log/jsondrop/access.log" {
+		t.Errorf("AccessLog.Path = %s, want /var/log/jsondrop/access.log", cfg.AccessLog.Path)
+	}
+	if cfg.AccessLog.Format != AccessLogJSON {
+		t.Errorf("AccessLog.Format = %s, want %s", cfg.AccessLog.Format, AccessLogJSON)
+	}
+	if cfg.AccessLog.MaxSizeMB != 0 {
+		t.Errorf("AccessLog.MaxSizeMB = %d, want 0", cfg.AccessLog.MaxSizeMB)
+	}
+	if cfg.AccessLog.MaxBackups != 10 {
+		t.Errorf("AccessLog.MaxBackups = %d, want 10", cfg.AccessLog.MaxBackups)
+	}
+}
+
+func TestLoad_AccessLogInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{name: "unknown format", key: "ACCESS_LOG_FORMAT", value: "xml"},
+		{name: "invalid size", key: "ACCESS_LOG_MAX_SIZE_MB", value: "big"},
+		{name: "negative size", key: "ACCESS_LOG_MAX_SIZE_MB", value: "-1"},
+		{name: "negative backups", key: "ACCESS_LOG_MAX_BACKUPS", value: "-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv()
+			defer clearEnv()
+
+			os.Setenv(tt.key, tt.value)
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() error = nil, want error for %s=%s", tt.key, tt.value)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
@@ -11,12 +12,54 @@ import (
 // Config holds all server configuration
 type Config struct {
 	Port                 string
+	GRPCPort             string // Port of the gRPC API, over unencrypted HTTP/2; empty disables it
 	DBBaseDir            string
 	CatalogDBPath        string
 	CORSOrigins          []string
+	TrustedProxies       []netip.Prefix // Reverse proxies whose X-Forwarded-For names the client
 	DefaultQuotaMB       int64
 	ExpiryDays           int
 	ExpiryCheckInterval  time.Duration
+	MaxCollections       int // Per database; 0 means unlimited
+	MaxSchemaFields      int // Per schema; 0 means unlimited
+	MaxDocumentBytes     int64 // Largest document JSON accepted; 0 means unlimited
+	MaxBodyBytes         int64 // Largest request body accepted, except streamed imports; 0 means unlimited
+	QueryTimeout         time.Duration // Longest a document query may run; 0 means unlimited
+	MaxRowsScanned       int           // Rows a document query may read; 0 means unlimited
+	MaxOpenDatabases     int           // Database files kept open; 0 opens one per request
+	DatabaseIdleTimeout  time.Duration // Close database files unused this long; 0 never does
+	CompressionThreshold int           // Compress documents larger than this many bytes; 0 disables
+	CompressionAlgorithm string        // "gzip" or "zstd"
+	VacuumInterval       time.Duration // How often database files are compacted; 0 disables
+	VacuumMinFreePercent int           // Compact files with at least this share of free pages
+	QuotaRecalcInterval  time.Duration // How often quota usage is recomputed from stored documents; 0 disables
+	RetentionInterval    time.Duration // How often collection retention periods are enforced; 0 disables
+	RetentionBatchSize   int           // Documents deleted at a time when enforcing retention
+	EventLogRetention    time.Duration // How long each database's event log keeps events; 0 keeps them forever
+	EventRateLimit       int           // Events delivered per database each second; 0 disables limiting
+	EventCoalesce        bool          // Summarize events over the limit instead of dropping them
+	EventBufferSize      int           // Events queued per stream before a slow client misses some
+	EventStreamRate      int           // Events delivered per stream each second; 0 disables pacing
+	EventStreamBurst     int           // Events a stream may take at once; 0 uses EventStreamRate
+	RequireIfMatch       bool          // Reject document updates and deletes without an If-Match header
+	DevMode              bool          // Demo database, stack traces in server errors and no expiry, for local development
+	Faults               FaultConfig
+	AccessLog            AccessLogConfig
+	EventSink            EventSinkConfig
+	EventRelay           EventRelayConfig
+	TLS                  TLSConfig
+	Lockout              LockoutConfig
+	OIDC                 OIDCConfig
+	Creation             CreationConfig
+	FixturesDir          string
+	AdminKey             string
+	TokenSecret          string        // Signs access tokens; empty signs with a random secret each start
+	TokenMaxTTL          time.Duration // Longest an access token may last
+	BasePath             string // Route prefix such as "/jsondrop"; empty serves at the root
+	StorageBackend       string // "sqlite", "postgres" or "bolt"
+	PostgresURL          string // Connection URL when StorageBackend is "postgres"
+	BoltPath             string // Database file when StorageBackend is "bolt"
+	SQLiteDriver         string // "mattn", "modernc" or empty for the preferred driver built in
 }
 
 // Load reads configuration from environment variables with sensible defaults
@@ -26,6 +69,16 @@ func Load() (*Config, error) {
 		DBBaseDir:     getEnv("DB_BASE_DIR", "./data"),
 		CatalogDBPath: getEnv("CATALOG_DB_PATH", "./data/catalog.db"),
 		CORSOrigins:   parseCORSOrigins(getEnv("CORS_ORIGINS", "*")),
+		FixturesDir:   getEnv("FIXTURES_DIR", ""),
+		AdminKey:      getEnv("ADMIN_KEY", ""),
+		PostgresURL:   getEnv("POSTGRES_URL", ""),
+		BoltPath:      getEnv("BOLT_PATH", "./data/jsondrop.bolt"),
+	}
+
+	// Parse GRPC_PORT; it can't share the HTTP port
+	cfg.GRPCPort = getEnv("GRPC_PORT", "")
+	if cfg.GRPCPort != "" && cfg.GRPCPort == cfg.Port {
+		return nil, fmt.Errorf("GRPC_PORT must differ from PORT, both are %s", cfg.Port)
 	}
 
 	// Parse DEFAULT_QUOTA_MB
@@ -59,6 +112,346 @@ func Load() (*Config, error) {
 	}
 	cfg.ExpiryCheckInterval = interval
 
+	// Parse MAX_COLLECTIONS
+	maxCollections, err := strconv.Atoi(getEnv("MAX_COLLECTIONS", "100"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_COLLECTIONS: %w", err)
+	}
+	if maxCollections < 0 {
+		return nil, fmt.Errorf("MAX_COLLECTIONS must not be negative, got %d", maxCollections)
+	}
+	cfg.MaxCollections = maxCollections
+
+	// Parse MAX_SCHEMA_FIELDS
+	maxFields, err := strconv.Atoi(getEnv("MAX_SCHEMA_FIELDS", "100"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_SCHEMA_FIELDS: %w", err)
+	}
+	if maxFields < 0 {
+		return nil, fmt.Errorf("MAX_SCHEMA_FIELDS must not be negative, got %d", maxFields)
+	}
+	cfg.MaxSchemaFields = maxFields
+
+	// Parse MAX_DOCUMENT_BYTES
+	maxDocument, err := strconv.ParseInt(getEnv("MAX_DOCUMENT_BYTES", "1048576"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_DOCUMENT_BYTES: %w", err)
+	}
+	if maxDocument < 0 {
+		return nil, fmt.Errorf("MAX_DOCUMENT_BYTES must not be negative, got %d", maxDocument)
+	}
+	cfg.MaxDocumentBytes = maxDocument
+
+	// Parse MAX_BODY_BYTES; a body must be able to carry the largest document
+	maxBody, err := strconv.ParseInt(getEnv("MAX_BODY_BYTES", "10485760"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_BODY_BYTES: %w", err)
+	}
+	if maxBody < 0 {
+		return nil, fmt.Errorf("MAX_BODY_BYTES must not be negative, got %d", maxBody)
+	}
+	if maxBody > 0 && maxBody < maxDocument {
+		return nil, fmt.Errorf("MAX_BODY_BYTES must be at least MAX_DOCUMENT_BYTES, got %d", maxBody)
+	}
+	cfg.MaxBodyBytes = maxBody
+
+	// Parse QUERY_TIMEOUT
+	queryTimeoutStr := getEnv("QUERY_TIMEOUT", "5s")
+	queryTimeout, err := time.ParseDuration(queryTimeoutStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid QUERY_TIMEOUT: %w", err)
+	}
+	if queryTimeout < 0 {
+		return nil, fmt.Errorf("QUERY_TIMEOUT must not be negative, got %s", queryTimeoutStr)
+	}
+	cfg.QueryTimeout = queryTimeout
+
+	// Parse MAX_ROWS_SCANNED
+	maxRows, err := strconv.Atoi(getEnv("MAX_ROWS_SCANNED", "100000"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_ROWS_SCANNED: %w", err)
+	}
+	if maxRows < 0 {
+		return nil, fmt.Errorf("MAX_ROWS_SCANNED must not be negative, got %d", maxRows)
+	}
+	cfg.MaxRowsScanned = maxRows
+
+	// Parse MAX_OPEN_DATABASES
+	maxOpen, err := strconv.Atoi(getEnv("MAX_OPEN_DATABASES", "64"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_OPEN_DATABASES: %w", err)
+	}
+	if maxOpen < 0 {
+		return nil, fmt.Errorf("MAX_OPEN_DATABASES must not be negative, got %d", maxOpen)
+	}
+	cfg.MaxOpenDatabases = maxOpen
+
+	// Parse DATABASE_IDLE_TIMEOUT
+	idleStr := getEnv("DATABASE_IDLE_TIMEOUT", "5m")
+	idleTimeout, err := time.ParseDuration(idleStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DATABASE_IDLE_TIMEOUT: %w", err)
+	}
+	if idleTimeout < 0 {
+		return nil, fmt.Errorf("DATABASE_IDLE_TIMEOUT must not be negative, got %s", idleStr)
+	}
+	cfg.DatabaseIdleTimeout = idleTimeout
+
+	// Parse COMPRESSION_THRESHOLD_BYTES
+	threshold, err := strconv.Atoi(getEnv("COMPRESSION_THRESHOLD_BYTES", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid COMPRESSION_THRESHOLD_BYTES: %w", err)
+	}
+	if threshold < 0 {
+		return nil, fmt.Errorf("COMPRESSION_THRESHOLD_BYTES must not be negative, got %d", threshold)
+	}
+	cfg.CompressionThreshold = threshold
+
+	// Parse COMPRESSION_ALGORITHM
+	cfg.CompressionAlgorithm = getEnv("COMPRESSION_ALGORITHM", "gzip")
+	if cfg.CompressionAlgorithm != "gzip" && cfg.CompressionAlgorithm != "zstd" {
+		return nil, fmt.Errorf("COMPRESSION_ALGORITHM must be gzip or zstd, got %s", cfg.CompressionAlgorithm)
+	}
+
+	// Parse VACUUM_INTERVAL
+	vacuumStr := getEnv("VACUUM_INTERVAL", "24h")
+	vacuumInterval, err := time.ParseDuration(vacuumStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VACUUM_INTERVAL: %w", err)
+	}
+	if vacuumInterval < 0 {
+		return nil, fmt.Errorf("VACUUM_INTERVAL must not be negative, got %s", vacuumStr)
+	}
+	cfg.VacuumInterval = vacuumInterval
+
+	// Parse VACUUM_MIN_FREE_PERCENT
+	minFree, err := strconv.Atoi(getEnv("VACUUM_MIN_FREE_PERCENT", "20"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid VACUUM_MIN_FREE_PERCENT: %w", err)
+	}
+	if minFree < 0 || minFree > 100 {
+		return nil, fmt.Errorf("VACUUM_MIN_FREE_PERCENT must be between 0 and 100, got %d", minFree)
+	}
+	cfg.VacuumMinFreePercent = minFree
+
+	// Parse QUOTA_RECALC_INTERVAL
+	recalcStr := getEnv("QUOTA_RECALC_INTERVAL", "24h")
+	recalcInterval, err := time.ParseDuration(recalcStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid QUOTA_RECALC_INTERVAL: %w", err)
+	}
+	if recalcInterval < 0 {
+		return nil, fmt.Errorf("QUOTA_RECALC_INTERVAL must not be negative, got %s", recalcStr)
+	}
+	cfg.QuotaRecalcInterval = recalcInterval
+
+	// Parse RETENTION_INTERVAL
+	retentionStr := getEnv("RETENTION_INTERVAL", "1h")
+	retentionInterval, err := time.ParseDuration(retentionStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RETENTION_INTERVAL: %w", err)
+	}
+	if retentionInterval < 0 {
+		return nil, fmt.Errorf("RETENTION_INTERVAL must not be negative, got %s", retentionStr)
+	}
+	cfg.RetentionInterval = retentionInterval
+
+	// Parse RETENTION_BATCH_SIZE
+	retentionBatch, err := strconv.Atoi(getEnv("RETENTION_BATCH_SIZE", "500"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RETENTION_BATCH_SIZE: %w", err)
+	}
+	if retentionBatch < 1 {
+		return nil, fmt.Errorf("RETENTION_BATCH_SIZE must be at least 1, got %d", retentionBatch)
+	}
+	cfg.RetentionBatchSize = retentionBatch
+
+	// Parse EVENT_LOG_RETENTION
+	eventLogStr := getEnv("EVENT_LOG_RETENTION", "168h")
+	eventLogRetention, err := time.ParseDuration(eventLogStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_LOG_RETENTION: %w", err)
+	}
+	if eventLogRetention < 0 {
+		return nil, fmt.Errorf("EVENT_LOG_RETENTION must not be negative, got %s", eventLogStr)
+	}
+	cfg.EventLogRetention = eventLogRetention
+
+	// Parse EVENT_RATE_LIMIT
+	eventRate, err := strconv.Atoi(getEnv("EVENT_RATE_LIMIT", "100"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_RATE_LIMIT: %w", err)
+	}
+	if eventRate < 0 {
+		return nil, fmt.Errorf("EVENT_RATE_LIMIT must not be negative, got %d", eventRate)
+	}
+	cfg.EventRateLimit = eventRate
+
+	coalesce, err := strconv.ParseBool(getEnv("EVENT_COALESCE", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_COALESCE: %w", err)
+	}
+	cfg.EventCoalesce = coalesce
+
+	// Parse EVENT_BUFFER_SIZE
+	eventBuffer, err := strconv.Atoi(getEnv("EVENT_BUFFER_SIZE", "10"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_BUFFER_SIZE: %w", err)
+	}
+	if eventBuffer < 1 {
+		return nil, fmt.Errorf("EVENT_BUFFER_SIZE must be at least 1, got %d", eventBuffer)
+	}
+	cfg.EventBufferSize = eventBuffer
+
+	// Parse EVENT_STREAM_RATE_LIMIT
+	streamRate, err := strconv.Atoi(getEnv("EVENT_STREAM_RATE_LIMIT", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_STREAM_RATE_LIMIT: %w", err)
+	}
+	if streamRate < 0 {
+		return nil, fmt.Errorf("EVENT_STREAM_RATE_LIMIT must not be negative, got %d", streamRate)
+	}
+	cfg.EventStreamRate = streamRate
+
+	streamBurst, err := strconv.Atoi(getEnv("EVENT_STREAM_BURST", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_STREAM_BURST: %w", err)
+	}
+	if streamBurst < 0 {
+		return nil, fmt.Errorf("EVENT_STREAM_BURST must not be negative, got %d", streamBurst)
+	}
+	cfg.EventStreamBurst = streamBurst
+
+	// Parse TRUSTED_PROXIES
+	proxies, err := parseTrustedProxies(getEnv("TRUSTED_PROXIES", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.TrustedProxies = proxies
+
+	// Parse TOKEN_SECRET and TOKEN_MAX_TTL
+	cfg.TokenSecret = getEnv("TOKEN_SECRET", "")
+	if cfg.TokenSecret != "" && len(cfg.TokenSecret) < 32 {
+		return nil, fmt.Errorf("TOKEN_SECRET must be at least 32 bytes, got %d", len(cfg.TokenSecret))
+	}
+	tokenTTLStr := getEnv("TOKEN_MAX_TTL", "1h")
+	tokenTTL, err := time.ParseDuration(tokenTTLStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOKEN_MAX_TTL: %w", err)
+	}
+	if tokenTTL < time.Second {
+		return nil, fmt.Errorf("TOKEN_MAX_TTL must be at least 1s, got %s", tokenTTLStr)
+	}
+	cfg.TokenMaxTTL = tokenTTL
+
+	// Writes without a revision overwrite concurrent changes, so they are refused unless opted out
+	requireIfMatch, err := strconv.ParseBool(getEnv("REQUIRE_IF_MATCH", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REQUIRE_IF_MATCH: %w", err)
+	}
+	cfg.RequireIfMatch = requireIfMatch
+
+	// Parse DEV_MODE; databases never expire in dev mode
+	devMode, err := strconv.ParseBool(getEnv("DEV_MODE", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DEV_MODE: %w", err)
+	}
+	cfg.DevMode = devMode
+	if devMode {
+		cfg.ExpiryDays = 0
+	}
+
+	// Parse BASE_PATH
+	basePath, err := parseBasePath(getEnv("BASE_PATH", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.BasePath = basePath
+
+	// Parse STORAGE_BACKEND
+	backend := strings.ToLower(getEnv("STORAGE_BACKEND", "sqlite"))
+	switch backend {
+	case "sqlite", "bolt":
+	case "postgres":
+		if cfg.PostgresURL == "" {
+			return nil, fmt.Errorf("POSTGRES_URL is required when STORAGE_BACKEND is postgres")
+		}
+	default:
+		return nil, fmt.Errorf("invalid STORAGE_BACKEND: %q must be sqlite, postgres or bolt", backend)
+	}
+	if backend != "sqlite" && cfg.FixturesDir != "" {
+		return nil, fmt.Errorf("FIXTURES_DIR is only supported with the sqlite storage backend")
+	}
+	if backend != "sqlite" && cfg.DevMode {
+		return nil, fmt.Errorf("DEV_MODE is only supported with the sqlite storage backend")
+	}
+	cfg.StorageBackend = backend
+
+	// Parse SQLITE_DRIVER; whether the binary has the driver is checked when the catalog opens
+	sqliteDriver := strings.ToLower(getEnv("SQLITE_DRIVER", ""))
+	switch sqliteDriver {
+	case "", "mattn", "modernc":
+	default:
+		return nil, fmt.Errorf("invalid SQLITE_DRIVER: %q must be mattn or modernc", sqliteDriver)
+	}
+	cfg.SQLiteDriver = sqliteDriver
+
+	// Parse FAULT_* settings
+	faults, err := loadFaultConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Faults = faults
+
+	// Parse ACCESS_LOG_* settings
+	accessLog, err := loadAccessLogConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.AccessLog = accessLog
+
+	// Parse EVENT_SINK* settings
+	eventSink, err := loadEventSinkConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.EventSink = eventSink
+
+	// Parse EVENT_RELAY* settings
+	eventRelay, err := loadEventRelayConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.EventRelay = eventRelay
+
+	// Parse TLS_* settings
+	tlsConfig, err := loadTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.TLS = tlsConfig
+
+	// Parse AUTH_LOCKOUT_* settings
+	lockout, err := loadLockoutConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Lockout = lockout
+
+	// Parse OIDC_* settings
+	oidc, err := loadOIDCConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.OIDC = oidc
+
+	// Parse CREATE_* and MAX_DATABASES settings
+	creation, err := loadCreationConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Creation = creation
+
 	return cfg, nil
 }
 
@@ -90,3 +483,41 @@ func parseCORSOrigins(origins string) []string {
 
 	return result
 }
+
+// parseTrustedProxies parses a comma-separated list of CIDR ranges and IP addresses
+func parseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %q is not a CIDR range or IP address", entry)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// parseBasePath normalizes a route prefix to a leading slash and no trailing slash
+// "/" and "" both mean routes are served at the root
+func parseBasePath(path string) (string, error) {
+	if strings.ContainsAny(path, " \t\n{}*?#") {
+		return "", fmt.Errorf("invalid BASE_PATH: %q must be a plain URL path", path)
+	}
+
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "", nil
+	}
+	if strings.Contains(path, "//") {
+		return "", fmt.Errorf("invalid BASE_PATH: %q has an empty segment", path)
+	}
+	return "/" + path, nil
+}
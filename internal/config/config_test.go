@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -37,6 +38,93 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.ExpiryCheckInterval != 24*time.Hour {
 		t.Errorf("ExpiryCheckInterval = %v, want 24h", cfg.ExpiryCheckInterval)
 	}
+	if cfg.MaxCollections != 100 {
+		t.Errorf("MaxCollections = %d, want 100", cfg.MaxCollections)
+	}
+	if cfg.MaxSchemaFields != 100 {
+		t.Errorf("MaxSchemaFields = %d, want 100", cfg.MaxSchemaFields)
+	}
+	if cfg.MaxDocumentBytes != 1<<20 {
+		t.Errorf("MaxDocumentBytes = %d, want 1048576", cfg.MaxDocumentBytes)
+	}
+	if cfg.MaxBodyBytes != 10<<20 {
+		t.Errorf("MaxBodyBytes = %d, want 10485760", cfg.MaxBodyBytes)
+	}
+	if cfg.QueryTimeout != 5*time.Second {
+		t.Errorf("QueryTimeout = %v, want 5s", cfg.QueryTimeout)
+	}
+	if cfg.MaxRowsScanned != 100000 {
+		t.Errorf("MaxRowsScanned = %d, want 100000", cfg.MaxRowsScanned)
+	}
+	if cfg.MaxOpenDatabases != 64 {
+		t.Errorf("MaxOpenDatabases = %d, want 64", cfg.MaxOpenDatabases)
+	}
+	if cfg.DatabaseIdleTimeout != 5*time.Minute {
+		t.Errorf("DatabaseIdleTimeout = %v, want 5m", cfg.DatabaseIdleTimeout)
+	}
+	if cfg.CompressionThreshold != 0 {
+		t.Errorf("CompressionThreshold = %d, want 0", cfg.CompressionThreshold)
+	}
+	if cfg.CompressionAlgorithm != "gzip" {
+		t.Errorf("CompressionAlgorithm = %s, want gzip", cfg.CompressionAlgorithm)
+	}
+	if cfg.VacuumInterval != 24*time.Hour {
+		t.Errorf("VacuumInterval = %v, want 24h", cfg.VacuumInterval)
+	}
+	if cfg.VacuumMinFreePercent != 20 {
+		t.Errorf("VacuumMinFreePercent = %d, want 20", cfg.VacuumMinFreePercent)
+	}
+	if cfg.QuotaRecalcInterval != 24*time.Hour {
+		t.Errorf("QuotaRecalcInterval = %v, want 24h", cfg.QuotaRecalcInterval)
+	}
+	if cfg.RetentionInterval != time.Hour {
+		t.Errorf("RetentionInterval = %v, want 1h", cfg.RetentionInterval)
+	}
+	if cfg.RetentionBatchSize != 500 {
+		t.Errorf("RetentionBatchSize = %d, want 500", cfg.RetentionBatchSize)
+	}
+	if cfg.EventLogRetention != 168*time.Hour {
+		t.Errorf("EventLogRetention = %v, want 168h", cfg.EventLogRetention)
+	}
+	if cfg.EventRateLimit != 100 {
+		t.Errorf("EventRateLimit = %d, want 100", cfg.EventRateLimit)
+	}
+	if !cfg.EventCoalesce {
+		t.Error("EventCoalesce = false, want true")
+	}
+	if cfg.EventBufferSize != 10 {
+		t.Errorf("EventBufferSize = %d, want 10", cfg.EventBufferSize)
+	}
+	if cfg.EventStreamRate != 0 || cfg.EventStreamBurst != 0 {
+		t.Errorf("EventStreamRate, EventStreamBurst = %d, %d, want 0, 0", cfg.EventStreamRate, cfg.EventStreamBurst)
+	}
+	if cfg.TrustedProxies != nil {
+		t.Errorf("TrustedProxies = %v, want none", cfg.TrustedProxies)
+	}
+	if cfg.TokenSecret != "" || cfg.TokenMaxTTL != time.Hour {
+		t.Errorf("TokenSecret, TokenMaxTTL = %q, %v, want empty, 1h", cfg.TokenSecret, cfg.TokenMaxTTL)
+	}
+	if !cfg.RequireIfMatch {
+		t.Error("RequireIfMatch = false, want true")
+	}
+	if cfg.DevMode {
+		t.Error("DevMode = true, want false")
+	}
+	if cfg.FixturesDir != "" {
+		t.Errorf("FixturesDir = %s, want empty", cfg.FixturesDir)
+	}
+	if cfg.AdminKey != "" {
+		t.Errorf("AdminKey = %s, want empty", cfg.AdminKey)
+	}
+	if cfg.StorageBackend != "sqlite" {
+		t.Errorf("StorageBackend = %s, want sqlite", cfg.StorageBackend)
+	}
+	if cfg.BoltPath != "./data/jsondrop.bolt" {
+		t.Errorf("BoltPath = %s, want ./data/jsondrop.bolt", cfg.BoltPath)
+	}
+	if cfg.SQLiteDriver != "" {
+		t.Errorf("SQLiteDriver = %s, want empty", cfg.SQLiteDriver)
+	}
 }
 
 func TestLoad_CustomValues(t *testing.T) {
@@ -50,6 +138,35 @@ func TestLoad_CustomValues(t *testing.T) {
 	os.Setenv("DEFAULT_QUOTA_MB", "250")
 	os.Setenv("EXPIRY_DAYS", "60")
 	os.Setenv("EXPIRY_CHECK_INTERVAL", "12h")
+	os.Setenv("MAX_COLLECTIONS", "0")
+	os.Setenv("MAX_SCHEMA_FIELDS", "20")
+	os.Setenv("MAX_DOCUMENT_BYTES", "65536")
+	os.Setenv("MAX_BODY_BYTES", "0")
+	os.Setenv("QUERY_TIMEOUT", "0")
+	os.Setenv("MAX_ROWS_SCANNED", "500")
+	os.Setenv("MAX_OPEN_DATABASES", "0")
+	os.Setenv("DATABASE_IDLE_TIMEOUT", "30s")
+	os.Setenv("COMPRESSION_THRESHOLD_BYTES", "4096")
+	os.Setenv("COMPRESSION_ALGORITHM", "zstd")
+	os.Setenv("VACUUM_INTERVAL", "0")
+	os.Setenv("VACUUM_MIN_FREE_PERCENT", "50")
+	os.Setenv("QUOTA_RECALC_INTERVAL", "1h")
+	os.Setenv("RETENTION_INTERVAL", "10m")
+	os.Setenv("RETENTION_BATCH_SIZE", "100")
+	os.Setenv("EVENT_LOG_RETENTION", "0")
+	os.Setenv("EVENT_RATE_LIMIT", "0")
+	os.Setenv("EVENT_COALESCE", "false")
+	os.Setenv("EVENT_BUFFER_SIZE", "256")
+	os.Setenv("EVENT_STREAM_RATE_LIMIT", "20")
+	os.Setenv("EVENT_STREAM_BURST", "50")
+	os.Setenv("TOKEN_SECRET", "0123456789abcdef0123456789abcdef")
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.7,fd00::/8")
+	os.Setenv("TOKEN_MAX_TTL", "15m")
+	os.Setenv("REQUIRE_IF_MATCH", "false")
+	os.Setenv("STORAGE_BACKEND", "postgres")
+	os.Setenv("POSTGRES_URL", "postgres://jsondrop@localhost/jsondrop")
+	os.Setenv("BOLT_PATH", "/var/lib/jsondrop/jsondrop.bolt")
+	os.Setenv("SQLITE_DRIVER", "modernc")
 
 	cfg, err := Load()
 	if err != nil {
@@ -83,6 +200,87 @@ func TestLoad_CustomValues(t *testing.T) {
 	if cfg.ExpiryCheckInterval != 12*time.Hour {
 		t.Errorf("ExpiryCheckInterval = %v, want 12h", cfg.ExpiryCheckInterval)
 	}
+	if cfg.MaxCollections != 0 {
+		t.Errorf("MaxCollections = %d, want 0", cfg.MaxCollections)
+	}
+	if cfg.MaxSchemaFields != 20 {
+		t.Errorf("MaxSchemaFields = %d, want 20", cfg.MaxSchemaFields)
+	}
+	if cfg.MaxDocumentBytes != 65536 {
+		t.Errorf("MaxDocumentBytes = %d, want 65536", cfg.MaxDocumentBytes)
+	}
+	if cfg.MaxBodyBytes != 0 {
+		t.Errorf("MaxBodyBytes = %d, want 0", cfg.MaxBodyBytes)
+	}
+	if cfg.QueryTimeout != 0 {
+		t.Errorf("QueryTimeout = %v, want 0", cfg.QueryTimeout)
+	}
+	if cfg.MaxRowsScanned != 500 {
+		t.Errorf("MaxRowsScanned = %d, want 500", cfg.MaxRowsScanned)
+	}
+	if cfg.MaxOpenDatabases != 0 {
+		t.Errorf("MaxOpenDatabases = %d, want 0", cfg.MaxOpenDatabases)
+	}
+	if cfg.DatabaseIdleTimeout != 30*time.Second {
+		t.Errorf("DatabaseIdleTimeout = %v, want 30s", cfg.DatabaseIdleTimeout)
+	}
+	if cfg.CompressionThreshold != 4096 {
+		t.Errorf("CompressionThreshold = %d, want 4096", cfg.CompressionThreshold)
+	}
+	if cfg.CompressionAlgorithm != "zstd" {
+		t.Errorf("CompressionAlgorithm = %s, want zstd", cfg.CompressionAlgorithm)
+	}
+	if cfg.VacuumInterval != 0 {
+		t.Errorf("VacuumInterval = %v, want 0", cfg.VacuumInterval)
+	}
+	if cfg.VacuumMinFreePercent != 50 {
+		t.Errorf("VacuumMinFreePercent = %d, want 50", cfg.VacuumMinFreePercent)
+	}
+	if cfg.QuotaRecalcInterval != time.Hour {
+		t.Errorf("QuotaRecalcInterval = %v, want 1h", cfg.QuotaRecalcInterval)
+	}
+	if cfg.RetentionInterval != 10*time.Minute {
+		t.Errorf("RetentionInterval = %v, want 10m", cfg.RetentionInterval)
+	}
+	if cfg.RetentionBatchSize != 100 {
+		t.Errorf("RetentionBatchSize = %d, want 100", cfg.RetentionBatchSize)
+	}
+	if cfg.EventLogRetention != 0 {
+		t.Errorf("EventLogRetention = %v, want 0", cfg.EventLogRetention)
+	}
+	if cfg.EventRateLimit != 0 {
+		t.Errorf("EventRateLimit = %d, want 0", cfg.EventRateLimit)
+	}
+	if cfg.EventCoalesce {
+		t.Error("EventCoalesce = true, want false")
+	}
+	if cfg.EventBufferSize != 256 {
+		t.Errorf("EventBufferSize = %d, want 256", cfg.EventBufferSize)
+	}
+	if cfg.EventStreamRate != 20 || cfg.EventStreamBurst != 50 {
+		t.Errorf("EventStreamRate, EventStreamBurst = %d, %d, want 20, 50", cfg.EventStreamRate, cfg.EventStreamBurst)
+	}
+	if got := fmt.Sprint(cfg.TrustedProxies); got != "[10.0.0.0/8 192.168.1.7/32 fd00::/8]" {
+		t.Errorf("TrustedProxies = %s, want [10.0.0.0/8 192.168.1.7/32 fd00::/8]", got)
+	}
+	if cfg.TokenSecret != "0123456789abcdef0123456789abcdef" || cfg.TokenMaxTTL != 15*time.Minute {
+		t.Errorf("TokenSecret, TokenMaxTTL = %q, %v, want the secret, 15m", cfg.TokenSecret, cfg.TokenMaxTTL)
+	}
+	if cfg.RequireIfMatch {
+		t.Error("RequireIfMatch = true, want false")
+	}
+	if cfg.StorageBackend != "postgres" {
+		t.Errorf("StorageBackend = %s, want postgres", cfg.StorageBackend)
+	}
+	if cfg.PostgresURL != "postgres://jsondrop@localhost/jsondrop" {
+		t.Errorf("PostgresURL = %s, want postgres://jsondrop@localhost/jsondrop", cfg.PostgresURL)
+	}
+	if cfg.BoltPath != "/var/lib/jsondrop/jsondrop.bolt" {
+		t.Errorf("BoltPath = %s, want /var/lib/jsondrop/jsondrop.bolt", cfg.BoltPath)
+	}
+	if cfg.SQLiteDriver != "modernc" {
+		t.Errorf("SQLiteDriver = %s, want modernc", cfg.SQLiteDriver)
+	}
 }
 
 func TestLoad_InvalidQuota(t *testing.T) {
@@ -169,6 +367,428 @@ func TestLoad_NegativeInterval(t *testing.T) {
 	}
 }
 
+func TestLoad_NegativeMaxCollections(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("MAX_COLLECTIONS", "-1")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for negative MAX_COLLECTIONS")
+	}
+}
+
+func TestLoad_InvalidMaxOpenDatabases(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("MAX_OPEN_DATABASES", "-1")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for negative MAX_OPEN_DATABASES")
+	}
+}
+
+func TestLoad_InvalidDatabaseIdleTimeout(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("DATABASE_IDLE_TIMEOUT", "-1m")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for negative DATABASE_IDLE_TIMEOUT")
+	}
+}
+
+func TestLoad_InvalidCompressionThreshold(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("COMPRESSION_THRESHOLD_BYTES", "-1")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for negative COMPRESSION_THRESHOLD_BYTES")
+	}
+}
+
+func TestLoad_InvalidCompressionAlgorithm(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("COMPRESSION_ALGORITHM", "lz4")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for unknown COMPRESSION_ALGORITHM")
+	}
+}
+
+func TestLoad_InvalidVacuumInterval(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("VACUUM_INTERVAL", "-1h")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for negative VACUUM_INTERVAL")
+	}
+}
+
+func TestLoad_InvalidVacuumMinFreePercent(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("VACUUM_MIN_FREE_PERCENT", "101")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for VACUUM_MIN_FREE_PERCENT over 100")
+	}
+}
+
+func TestLoad_InvalidQuotaRecalcInterval(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("QUOTA_RECALC_INTERVAL", "soon")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for invalid QUOTA_RECALC_INTERVAL")
+	}
+}
+
+func TestLoad_InvalidSizeLimits(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("MAX_DOCUMENT_BYTES", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, want error for negative MAX_DOCUMENT_BYTES")
+	}
+
+	os.Setenv("MAX_DOCUMENT_BYTES", "2048")
+	os.Setenv("MAX_BODY_BYTES", "1024")
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, want error for MAX_BODY_BYTES below MAX_DOCUMENT_BYTES")
+	}
+}
+
+func TestLoad_InvalidQueryLimits(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("QUERY_TIMEOUT", "-1s")
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, want error for negative QUERY_TIMEOUT")
+	}
+
+	os.Unsetenv("QUERY_TIMEOUT")
+	os.Setenv("MAX_ROWS_SCANNED", "many")
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, want error for invalid MAX_ROWS_SCANNED")
+	}
+}
+
+func TestLoad_GRPCPort(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.GRPCPort != "" {
+		t.Errorf("GRPCPort = %q, want empty by default", cfg.GRPCPort)
+	}
+
+	os.Setenv("GRPC_PORT", "9090")
+	if cfg, err := Load(); err != nil || cfg.GRPCPort != "9090" {
+		t.Errorf("Load() = %+v, %v, want GRPCPort 9090", cfg, err)
+	}
+
+	os.Setenv("GRPC_PORT", "8080")
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, want error for GRPC_PORT equal to PORT")
+	}
+}
+
+func TestLoad_InvalidRetention(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("RETENTION_INTERVAL", "-1h")
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, want error for negative RETENTION_INTERVAL")
+	}
+
+	os.Unsetenv("RETENTION_INTERVAL")
+	os.Setenv("RETENTION_BATCH_SIZE", "0")
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, want error for RETENTION_BATCH_SIZE 0")
+	}
+
+	os.Unsetenv("RETENTION_BATCH_SIZE")
+	os.Setenv("EVENT_LOG_RETENTION", "-1h")
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, want error for negative EVENT_LOG_RETENTION")
+	}
+}
+
+func TestLoad_InvalidEventRateLimit(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("EVENT_RATE_LIMIT", "-1")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for negative EVENT_RATE_LIMIT")
+	}
+}
+
+func TestLoad_InvalidEventCoalesce(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("EVENT_COALESCE", "sometimes")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for invalid EVENT_COALESCE")
+	}
+}
+
+func TestLoad_InvalidEventBufferSize(t *testing.T) {
+	for _, value := range []string{"0", "many"} {
+		clearEnv()
+		os.Setenv("EVENT_BUFFER_SIZE", value)
+
+		if _, err := Load(); err == nil {
+			t.Errorf("Load() error = nil, want error for EVENT_BUFFER_SIZE=%s", value)
+		}
+	}
+	clearEnv()
+}
+
+func TestLoad_InvalidEventStreamRate(t *testing.T) {
+	for _, env := range []string{"EVENT_STREAM_RATE_LIMIT", "EVENT_STREAM_BURST"} {
+		for _, value := range []string{"-1", "fast"} {
+			clearEnv()
+			os.Setenv(env, value)
+
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() error = nil, want error for %s=%s", env, value)
+			}
+		}
+	}
+	clearEnv()
+}
+
+func TestLoad_InvalidTrustedProxies(t *testing.T) {
+	for _, value := range []string{"proxy.internal", "10.0.0.0/33", "10.0.0.1,nope"} {
+		clearEnv()
+		os.Setenv("TRUSTED_PROXIES", value)
+
+		if _, err := Load(); err == nil {
+			t.Errorf("Load() error = nil, want error for TRUSTED_PROXIES=%s", value)
+		}
+	}
+	clearEnv()
+}
+
+func TestLoad_InvalidToken(t *testing.T) {
+	for env, values := range map[string][]string{
+		"TOKEN_SECRET":  {"short"},
+		"TOKEN_MAX_TTL": {"0", "500ms", "-1h", "soon"},
+	} {
+		for _, value := range values {
+			clearEnv()
+			os.Setenv(env, value)
+
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() error = nil, want error for %s=%s", env, value)
+			}
+		}
+	}
+	clearEnv()
+}
+
+func TestLoad_InvalidRequireIfMatch(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("REQUIRE_IF_MATCH", "always")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for invalid REQUIRE_IF_MATCH")
+	}
+}
+
+func TestLoad_DevMode(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("DEV_MODE", "true")
+	os.Setenv("EXPIRY_DAYS", "7")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.DevMode {
+		t.Error("DevMode = false, want true")
+	}
+	if cfg.ExpiryDays != 0 {
+		t.Errorf("ExpiryDays = %d, want 0 in dev mode", cfg.ExpiryDays)
+	}
+}
+
+func TestLoad_InvalidDevMode(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("DEV_MODE", "yes please")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for invalid DEV_MODE")
+	}
+}
+
+func TestLoad_InvalidStorageBackend(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("STORAGE_BACKEND", "mysql")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for invalid STORAGE_BACKEND")
+	}
+}
+
+func TestLoad_InvalidSQLiteDriver(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("SQLITE_DRIVER", "sqlite3")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for invalid SQLITE_DRIVER")
+	}
+}
+
+func TestLoad_PostgresRequiresURL(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("STORAGE_BACKEND", "postgres")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for missing POSTGRES_URL")
+	}
+}
+
+func TestLoad_PostgresRejectsFixtures(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("STORAGE_BACKEND", "postgres")
+	os.Setenv("POSTGRES_URL", "postgres://localhost/jsondrop")
+	os.Setenv("FIXTURES_DIR", "./fixtures")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for FIXTURES_DIR with postgres")
+	}
+}
+
+func TestLoad_BoltRejectsFixtures(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("STORAGE_BACKEND", "bolt")
+	os.Setenv("FIXTURES_DIR", "./fixtures")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for FIXTURES_DIR with bolt")
+	}
+}
+
+func TestLoad_BoltRejectsDevMode(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("STORAGE_BACKEND", "bolt")
+	os.Setenv("DEV_MODE", "true")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for DEV_MODE with bolt")
+	}
+}
+
+func TestLoad_InvalidMaxSchemaFields(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("MAX_SCHEMA_FIELDS", "many")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for invalid MAX_SCHEMA_FIELDS")
+	}
+}
+
+func TestLoad_InvalidBasePath(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("BASE_PATH", "/{tenant}/api")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for invalid BASE_PATH")
+	}
+}
+
+func TestParseBasePath(t *testing.T) {
+	tests := map[string]string{
+		"":              "",
+		"/":             "",
+		"jsondrop":      "/jsondrop",
+		"/jsondrop/":    "/jsondrop",
+		"/tenants/acme": "/tenants/acme",
+		"//jsondrop//":  "/jsondrop",
+	}
+	for input, want := range tests {
+		got, err := parseBasePath(input)
+		if err != nil {
+			t.Errorf("parseBasePath(%q) error = %v, want nil", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("parseBasePath(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"/a b", "/a//b", "/api/*", "/a?b"} {
+		if _, err := parseBasePath(input); err == nil {
+			t.Errorf("parseBasePath(%q) error = nil, want error", input)
+		}
+	}
+}
+
 func TestParseCORSOrigins_Wildcard(t *testing.T) {
 	origins := parseCORSOrigins("*")
 	if len(origins) != 1 || origins[0] != "*" {
@@ -221,4 +841,81 @@ func clearEnv() {
 	os.Unsetenv("DEFAULT_QUOTA_MB")
 	os.Unsetenv("EXPIRY_DAYS")
 	os.Unsetenv("EXPIRY_CHECK_INTERVAL")
+	os.Unsetenv("MAX_COLLECTIONS")
+	os.Unsetenv("MAX_SCHEMA_FIELDS")
+	os.Unsetenv("MAX_DOCUMENT_BYTES")
+	os.Unsetenv("MAX_BODY_BYTES")
+	os.Unsetenv("QUERY_TIMEOUT")
+	os.Unsetenv("MAX_ROWS_SCANNED")
+	os.Unsetenv("MAX_OPEN_DATABASES")
+	os.Unsetenv("DATABASE_IDLE_TIMEOUT")
+	os.Unsetenv("COMPRESSION_THRESHOLD_BYTES")
+	os.Unsetenv("COMPRESSION_ALGORITHM")
+	os.Unsetenv("VACUUM_INTERVAL")
+	os.Unsetenv("VACUUM_MIN_FREE_PERCENT")
+	os.Unsetenv("QUOTA_RECALC_INTERVAL")
+	os.Unsetenv("RETENTION_INTERVAL")
+	os.Unsetenv("RETENTION_BATCH_SIZE")
+	os.Unsetenv("EVENT_LOG_RETENTION")
+	os.Unsetenv("GRPC_PORT")
+	os.Unsetenv("EVENT_RATE_LIMIT")
+	os.Unsetenv("EVENT_COALESCE")
+	os.Unsetenv("EVENT_BUFFER_SIZE")
+	os.Unsetenv("EVENT_STREAM_RATE_LIMIT")
+	os.Unsetenv("EVENT_STREAM_BURST")
+	os.Unsetenv("REQUIRE_IF_MATCH")
+	os.Unsetenv("DEV_MODE")
+	os.Unsetenv("FAULT_INJECTION")
+	os.Unsetenv("FAULT_LATENCY")
+	os.Unsetenv("FAULT_LATENCY_RATE")
+	os.Unsetenv("FAULT_ERROR_RATE")
+	os.Unsetenv("FAULT_QUOTA_RATE")
+	os.Unsetenv("FAULT_ROUTES")
+	os.Unsetenv("ACCESS_LOG_FILE")
+	os.Unsetenv("ACCESS_LOG_FORMAT")
+	os.Unsetenv("ACCESS_LOG_MAX_SIZE_MB")
+	os.Unsetenv("ACCESS_LOG_MAX_BACKUPS")
+	os.Unsetenv("EVENT_SINK")
+	os.Unsetenv("EVENT_SINK_SERVERS")
+	os.Unsetenv("EVENT_SINK_TOPIC")
+	os.Unsetenv("EVENT_SINK_TLS")
+	os.Unsetenv("EVENT_SINK_TLS_CA_FILE")
+	os.Unsetenv("EVENT_SINK_USERNAME")
+	os.Unsetenv("EVENT_SINK_PASSWORD")
+	os.Unsetenv("EVENT_SINK_SASL_MECHANISM")
+	os.Unsetenv("EVENT_SINK_NATS_CREDS")
+	os.Unsetenv("EVENT_RELAY_URL")
+	os.Unsetenv("EVENT_RELAY_CHANNEL")
+	os.Unsetenv("EVENT_RELAY_TLS_CA_FILE")
+	os.Unsetenv("TLS_CERT_FILE")
+	os.Unsetenv("TLS_KEY_FILE")
+	os.Unsetenv("TLS_AUTOCERT_HOSTS")
+	os.Unsetenv("TLS_AUTOCERT_EMAIL")
+	os.Unsetenv("TLS_AUTOCERT_CACHE_DIR")
+	os.Unsetenv("TLS_REDIRECT_PORT")
+	os.Unsetenv("AUTH_LOCKOUT_THRESHOLD")
+	os.Unsetenv("AUTH_LOCKOUT_MAX")
+	os.Unsetenv("OIDC_ISSUER")
+	os.Unsetenv("OIDC_CLIENT_ID")
+	os.Unsetenv("OIDC_CLIENT_SECRET")
+	os.Unsetenv("OIDC_REDIRECT_URL")
+	os.Unsetenv("OIDC_ADMINS")
+	os.Unsetenv("OIDC_SESSION_TTL")
+	os.Unsetenv("CREATE_RATE_LIMIT")
+	os.Unsetenv("CREATE_RATE_WINDOW")
+	os.Unsetenv("MAX_DATABASES")
+	os.Unsetenv("CREATE_CHALLENGE")
+	os.Unsetenv("CREATE_POW_DIFFICULTY")
+	os.Unsetenv("CREATE_CAPTCHA_VERIFY_URL")
+	os.Unsetenv("CREATE_CAPTCHA_SECRET")
+	os.Unsetenv("FIXTURES_DIR")
+	os.Unsetenv("ADMIN_KEY")
+	os.Unsetenv("TOKEN_SECRET")
+	os.Unsetenv("TRUSTED_PROXIES")
+	os.Unsetenv("TOKEN_MAX_TTL")
+	os.Unsetenv("BASE_PATH")
+	os.Unsetenv("STORAGE_BACKEND")
+	os.Unsetenv("POSTGRES_URL")
+	os.Unsetenv("BOLT_PATH")
+	os.Unsetenv("SQLITE_DRIVER")
 }
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Challenges a client can be made to pass before creating a database
+const (
+	ChallengeNone    = "none"
+	ChallengePoW     = "pow"     // Proof of work: a hash with leading zero bits
+	ChallengeCaptcha = "captcha" // A captcha verified with its provider's siteverify API
+)
+
+// CreationConfig controls creating databases, which needs no key
+type CreationConfig struct {
+	RateLimit        int           // Databases one client may create per RateWindow; 0 disables the limit
+	RateWindow       time.Duration // Period RateLimit applies to
+	MaxDatabases     int           // Databases the server holds at most; 0 is unlimited
+	Challenge        string        // ChallengeNone, ChallengePoW or ChallengeCaptcha
+	PoWDifficulty    int           // Leading zero bits a proof of work's hash needs
+	CaptchaVerifyURL string        // Provider's siteverify endpoint, such as hCaptcha's or Turnstile's
+	CaptchaSecret    string        // Secret key registered with the captcha provider
+}
+
+// loadCreationConfig reads the CREATE_* and MAX_DATABASES environment variables
+func loadCreationConfig() (CreationConfig, error) {
+	cfg := CreationConfig{
+		Challenge:        getEnv("CREATE_CHALLENGE", ChallengeNone),
+		CaptchaVerifyURL: getEnv("CREATE_CAPTCHA_VERIFY_URL", ""),
+		CaptchaSecret:    getEnv("CREATE_CAPTCHA_SECRET", ""),
+	}
+
+	rateLimitStr := getEnv("CREATE_RATE_LIMIT", "20")
+	rateLimit, err := strconv.Atoi(rateLimitStr)
+	if err != nil || rateLimit < 0 {
+		return cfg, fmt.Errorf("invalid CREATE_RATE_LIMIT: %q must be a number of databases, or 0 to disable", rateLimitStr)
+	}
+	cfg.RateLimit = rateLimit
+
+	rateWindowStr := getEnv("CREATE_RATE_WINDOW", "1h")
+	rateWindow, err := time.ParseDuration(rateWindowStr)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid CREATE_RATE_WINDOW: %w", err)
+	}
+	if rateWindow < time.Second {
+		return cfg, fmt.Errorf("CREATE_RATE_WINDOW must be at least 1s, got %s", rateWindowStr)
+	}
+	cfg.RateWindow = rateWindow
+
+	maxDatabasesStr := getEnv("MAX_DATABASES", "0")
+	maxDatabases, err := strconv.Atoi(maxDatabasesStr)
+	if err != nil || maxDatabases < 0 {
+		return cfg, fmt.Errorf("invalid MAX_DATABASES: %q must be a number of databases, or 0 for no limit", maxDatabasesStr)
+	}
+	cfg.MaxDatabases = maxDatabases
+
+	difficultyStr := getEnv("CREATE_POW_DIFFICULTY", "20")
+	difficulty, err := strconv.Atoi(difficultyStr)
+	if err != nil || difficulty < 1 || difficulty > 32 {
+		return cfg, fmt.Errorf("invalid CREATE_POW_DIFFICULTY: %q must be between 1 and 32 bits", difficultyStr)
+	}
+	cfg.PoWDifficulty = difficulty
+
+	switch cfg.Challenge {
+	case ChallengeNone, ChallengePoW:
+		if cfg.CaptchaVerifyURL != "" || cfg.CaptchaSecret != "" {
+			return cfg, fmt.Errorf("CREATE_CAPTCHA_VERIFY_URL and CREATE_CAPTCHA_SECRET require CREATE_CHALLENGE=captcha")
+		}
+	case ChallengeCaptcha:
+		if cfg.CaptchaVerifyURL == "" || cfg.CaptchaSecret == "" {
+			return cfg, fmt.Errorf("CREATE_CHALLENGE=captcha requires CREATE_CAPTCHA_VERIFY_URL and CREATE_CAPTCHA_SECRET")
+		}
+		if u, err := url.Parse(cfg.CaptchaVerifyURL); err != nil || u.Host == "" || (u.Scheme != "https" && !isLoopback(u.Hostname())) {
+			return cfg, fmt.Errorf("invalid CREATE_CAPTCHA_VERIFY_URL: %q must be an https URL", cfg.CaptchaVerifyURL)
+		}
+	default:
+		return cfg, fmt.Errorf("invalid CREATE_CHALLENGE: %q (use none, pow or captcha)", cfg.Challenge)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLoad_CreationDefaults(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+	want := CreationConfig{RateLimit: 20, RateWindow: time.Hour, Challenge: ChallengeNone, PoWDifficulty: 20}
+	if cfg.Creation != want {
+		t.Errorf("Creation = %+v, want %+v", cfg.Creation, want)
+	}
+}
+
+func TestLoad_CreationCustom(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("CREATE_RATE_LIMIT", "0")
+	os.Setenv("CREATE_RATE_WINDOW", "24h")
+	os.Setenv("MAX_DATABASES", "50000")
+	os.Setenv("CREATE_CHALLENGE", "captcha")
+	os.Setenv("CREATE_CAPTCHA_VERIFY_URL", "https://challenges.cloudflare.com/turnstile/v0/siteverify")
+	os.Setenv("CREATE_CAPTCHA_SECRET", "0x4AAAAAAA")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+	c := cfg.Creation
+	if c.RateLimit != 0 || c.RateWindow != 24*time.Hour || c.MaxDatabases != 50000 || c.Challenge != ChallengeCaptcha ||
+		c.CaptchaVerifyURL != "https://challenges.cloudflare.com/turnstile/v0/siteverify" || c.CaptchaSecret != "0x4AAAAAAA" {
+		t.Errorf("Creation = %+v, want no rate limit, 50000 databases and a Turnstile captcha", c)
+	}
+}
+
+func TestLoad_CreationInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{name: "negative rate limit", env: map[string]string{"CREATE_RATE_LIMIT": "-1"}},
+		{name: "window too short", env: map[string]string{"CREATE_RATE_WINDOW": "10ms"}},
+		{name: "max databases not a number", env: map[string]string{"MAX_DATABASES": "lots"}},
+		{name: "unknown challenge", env: map[string]string{"CREATE_CHALLENGE": "riddle"}},
+		{name: "difficulty too high", env: map[string]string{"CREATE_CHALLENGE": "pow", "CREATE_POW_DIFFICULTY": "40"}},
+		{name: "captcha without secret", env: map[string]string{"CREATE_CHALLENGE": "captcha", "CREATE_CAPTCHA_VERIFY_URL": "https://hcaptcha.com/siteverify"}},
+		{name: "captcha over http", env: map[string]string{"CREATE_CHALLENGE": "captcha", "CREATE_CAPTCHA_VERIFY_URL": "http://hcaptcha.com/siteverify", "CREATE_CAPTCHA_SECRET": "s"}},
+		{name: "captcha settings without captcha", env: map[string]string{"CREATE_CAPTCHA_SECRET": "s"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv()
+			defer clearEnv()
+
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() error = nil, want error for %v", tt.env)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// redisPort is the Redis port, used for relay URLs given without one
+const redisPort = "6379"
+
+// EventRelayConfig controls relaying change events between server instances over Redis
+// pub/sub, so listeners on every instance see changes made on any of them
+type EventRelayConfig struct {
+	Addr     string // Redis host:port; empty disables the relay
+	Username string // ACL user; empty authenticates as the default user
+	Password string // Empty skips authentication
+	Channel  string // Pub/sub channel shared by the instances
+	TLS      bool   // Connect with TLS, for rediss:// URLs
+	CAFile   string // PEM certificates to verify Redis with instead of the system's
+}
+
+// loadEventRelayConfig reads the EVENT_RELAY* environment variables
+func loadEventRelayConfig() (EventRelayConfig, error) {
+	cfg := EventRelayConfig{Channel: getEnv("EVENT_RELAY_CHANNEL", "jsondrop-events")}
+	if strings.ContainsAny(cfg.Channel, " \r\n*?[") {
+		return cfg, fmt.Errorf("invalid EVENT_RELAY_CHANNEL: %q must not contain spaces, line breaks or glob characters", cfg.Channel)
+	}
+
+	raw := getEnv("EVENT_RELAY_URL", "")
+	if raw == "" {
+		return cfg, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Hostname() == "" {
+		return cfg, fmt.Errorf("invalid EVENT_RELAY_URL: %q (expected redis[s]://[[user]:password@]host[:port])", raw)
+	}
+	cfg.Addr = u.Host
+	if u.Port() == "" {
+		cfg.Addr = net.JoinHostPort(u.Hostname(), redisPort)
+	}
+	if u.User != nil {
+		// redis://:password@host authenticates as the default user
+		cfg.Username = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+		if cfg.Password == "" {
+			return cfg, fmt.Errorf("invalid EVENT_RELAY_URL: a user needs a password")
+		}
+	}
+	cfg.CAFile = getEnv("EVENT_RELAY_TLS_CA_FILE", "")
+	cfg.TLS = u.Scheme == "rediss" || cfg.CAFile != ""
+	return cfg, nil
+}
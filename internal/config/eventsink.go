@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Event sink kinds
+const (
+	EventSinkNATS  = "nats"
+	EventSinkKafka = "kafka"
+)
+
+// Kafka SASL mechanisms
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// topicPattern matches names valid both as Kafka topics and as NATS subject prefixes
+var topicPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// EventSinkConfig controls publishing every change event to a message broker
+type EventSinkConfig struct {
+	Kind          string   // EventSinkNATS, EventSinkKafka or empty to disable
+	Servers       []string // Broker addresses, tried in turn
+	Topic         string   // Kafka topic, or the first token of NATS subjects
+	TLS           bool     // Connect with TLS
+	CAFile        string   // PEM certificates to verify brokers with instead of the system's
+	Username      string   // NATS user or Kafka SASL user
+	Password      string   // NATS password or Kafka SASL password
+	SASLMechanism string   // Kafka SASL mechanism, SASLPlain by default when Username is set
+	NATSCreds     string   // NATS credentials file, with a user JWT and nkey seed
+}
+
+// loadEventSinkConfig reads the EVENT_SINK* environment variables
+func loadEventSinkConfig() (EventSinkConfig, error) {
+	cfg := EventSinkConfig{
+		Kind:  strings.ToLower(getEnv("EVENT_SINK", "")),
+		Topic: getEnv("EVENT_SINK_TOPIC", "jsondrop"),
+	}
+
+	switch cfg.Kind {
+	case "":
+		return cfg, nil
+	case EventSinkNATS, EventSinkKafka:
+	default:
+		return cfg, fmt.Errorf("invalid EVENT_SINK: %q (expected %s or %s)", cfg.Kind, EventSinkNATS, EventSinkKafka)
+	}
+
+	for _, server := range strings.Split(getEnv("EVENT_SINK_SERVERS", ""), ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			cfg.Servers = append(cfg.Servers, server)
+		}
+	}
+	if len(cfg.Servers) == 0 {
+		return cfg, fmt.Errorf("EVENT_SINK_SERVERS is required when EVENT_SINK is %s", cfg.Kind)
+	}
+
+	if len(cfg.Topic) > 200 || !topicPattern.MatchString(cfg.Topic) {
+		return cfg, fmt.Errorf("invalid EVENT_SINK_TOPIC: %q must be up to 200 letters, digits, '_' or '-', in tokens separated by '.'", cfg.Topic)
+	}
+
+	useTLS, err := strconv.ParseBool(getEnv("EVENT_SINK_TLS", "false"))
+	if err != nil {
+		return cfg, fmt.Errorf("invalid EVENT_SINK_TLS: %w", err)
+	}
+	cfg.CAFile = getEnv("EVENT_SINK_TLS_CA_FILE", "")
+	cfg.TLS = useTLS || cfg.CAFile != ""
+
+	cfg.Username = getEnv("EVENT_SINK_USERNAME", "")
+	cfg.Password = getEnv("EVENT_SINK_PASSWORD", "")
+	if cfg.Password != "" && cfg.Username == "" {
+		return cfg, fmt.Errorf("EVENT_SINK_PASSWORD needs EVENT_SINK_USERNAME")
+	}
+
+	cfg.SASLMechanism = strings.ToUpper(getEnv("EVENT_SINK_SASL_MECHANISM", ""))
+	cfg.NATSCreds = getEnv("EVENT_SINK_NATS_CREDS", "")
+	switch cfg.Kind {
+	case EventSinkKafka:
+		if cfg.NATSCreds != "" {
+			return cfg, fmt.Errorf("EVENT_SINK_NATS_CREDS only applies when EVENT_SINK is %s", EventSinkNATS)
+		}
+		if cfg.SASLMechanism == "" && cfg.Username != "" {
+			cfg.SASLMechanism = SASLPlain
+		}
+		switch cfg.SASLMechanism {
+		case "", SASLPlain, SASLScramSHA256, SASLScramSHA512:
+		default:
+			return cfg, fmt.Errorf("invalid EVENT_SINK_SASL_MECHANISM: %q (expected %s, %s or %s)", cfg.SASLMechanism, SASLPlain, SASLScramSHA256, SASLScramSHA512)
+		}
+		if cfg.SASLMechanism != "" && cfg.Username == "" {
+			return cfg, fmt.Errorf("EVENT_SINK_SASL_MECHANISM needs EVENT_SINK_USERNAME")
+		}
+	case EventSinkNATS:
+		if cfg.SASLMechanism != "" {
+			return cfg, fmt.Errorf("EVENT_SINK_SASL_MECHANISM only applies when EVENT_SINK is %s", EventSinkKafka)
+		}
+		if cfg.NATSCreds != "" && cfg.Username != "" {
+			return cfg, fmt.Errorf("EVENT_SINK_NATS_CREDS and EVENT_SINK_USERNAME can't both be set")
+		}
+	}
+
+	return cfg, nil
+}
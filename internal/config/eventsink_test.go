@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLoad_EventSinkDefaults(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	if cfg.EventSink.Kind != "" {
+		t.Errorf("EventSink.Kind = %s, want empty", cfg.EventSink.Kind)
+	}
+	if cfg.EventSink.Topic != "jsondrop" {
+		t.Errorf("EventSink.Topic = %s, want jsondrop", cfg.EventSink.Topic)
+	}
+}
+
+func TestLoad_EventSinkCustom(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("EVENT_SINK", "Kafka")
+	os.Setenv("EVENT_SINK_SERVERS", "kafka-1:9092, kafka-2:9092,")
+	os.Setenv("EVENT_SINK_TOPIC", "prod.jsondrop-changes")
+	os.Setenv("EVENT_SINK_TLS_CA_FILE", "/etc/jsondrop/kafka-ca.pem")
+	os.Setenv("EVENT_SINK_USERNAME", "jsondrop")
+	os.Setenv("EVENT_SINK_PASSWORD", "s3cret")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	if cfg.EventSink.Kind != EventSinkKafka {
+		t.Errorf("EventSink.Kind = %s, want %s", cfg.EventSink.Kind, EventSinkKafka)
+	}
+	if len(cfg.EventSink.Servers) != 2 || cfg.EventSink.Servers[0] != "kafka-1:9092" || cfg.EventSink.Servers[1] != "kafka-2:9092" {
+		t.Errorf("EventSink.Servers = %v, want [kafka-1:9092 kafka-2:9092]", cfg.EventSink.Servers)
+	}
+	if cfg.EventSink.Topic != "prod.jsondrop-changes" {
+		t.Errorf("EventSink.Topic = %s, want prod.jsondrop-changes", cfg.EventSink.Topic)
+	}
+	// A CA file turns TLS on, and a user SASL PLAIN
+	if !cfg.EventSink.TLS || cfg.EventSink.CAFile != "/etc/jsondrop/kafka-ca.pem" {
+		t.Errorf("EventSink TLS = %v with CA file %q, want TLS with the CA file", cfg.EventSink.TLS, cfg.EventSink.CAFile)
+	}
+	if cfg.EventSink.Username != "jsondrop" || cfg.EventSink.Password != "s3cret" || cfg.EventSink.SASLMechanism != SASLPlain {
+		t.Errorf("EventSink credentials = %s/%s with %s, want jsondrop/s3cret with %s", cfg.EventSink.Username, cfg.EventSink.Password, cfg.EventSink.SASLMechanism, SASLPlain)
+	}
+}
+
+func TestLoad_EventSinkInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{name: "unknown kind", env: map[string]string{"EVENT_SINK": "rabbitmq", "EVENT_SINK_SERVERS": "localhost:5672"}},
+		{name: "no servers", env: map[string]string{"EVENT_SINK": "nats"}},
+		{name: "wildcard topic", env: map[string]string{"EVENT_SINK": "nats", "EVENT_SINK_SERVERS": "localhost:4222", "EVENT_SINK_TOPIC": "jsondrop.>"}},
+		{name: "empty token", env: map[string]string{"EVENT_SINK": "kafka", "EVENT_SINK_SERVERS": "localhost:9092", "EVENT_SINK_TOPIC": "jsondrop..changes"}},
+		{name: "bad TLS", env: map[string]string{"EVENT_SINK": "kafka", "EVENT_SINK_SERVERS": "localhost:9092", "EVENT_SINK_TLS": "maybe"}},
+		{name: "password without user", env: map[string]string{"EVENT_SINK": "nats", "EVENT_SINK_SERVERS": "localhost:4222", "EVENT_SINK_PASSWORD": "s3cret"}},
+		{name: "unknown mechanism", env: map[string]string{"EVENT_SINK": "kafka", "EVENT_SINK_SERVERS": "localhost:9092", "EVENT_SINK_USERNAME": "app", "EVENT_SINK_SASL_MECHANISM": "GSSAPI"}},
+		{name: "mechanism without user", env: map[string]string{"EVENT_SINK": "kafka", "EVENT_SINK_SERVERS": "localhost:9092", "EVENT_SINK_SASL_MECHANISM": "SCRAM-SHA-512"}},
+		{name: "SASL with NATS", env: map[string]string{"EVENT_SINK": "nats", "EVENT_SINK_SERVERS": "localhost:4222", "EVENT_SINK_USERNAME": "app", "EVENT_SINK_SASL_MECHANISM": "PLAIN"}},
+		{name: "creds with Kafka", env: map[string]string{"EVENT_SINK": "kafka", "EVENT_SINK_SERVERS": "localhost:9092", "EVENT_SINK_NATS_CREDS": "app.creds"}},
+		{name: "creds and user", env: map[string]string{"EVENT_SINK": "nats", "EVENT_SINK_SERVERS": "localhost:4222", "EVENT_SINK_USERNAME": "app", "EVENT_SINK_NATS_CREDS": "app.creds"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv()
+			defer clearEnv()
+
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() error = nil, want error for %v", tt.env)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FaultConfig controls artificial latency and error injection for testing clients
+type FaultConfig struct {
+	Enabled bool
+	Default FaultRule   // Applies to routes without a specific rule
+	Routes  []FaultRule // Per-route overrides, matched in order
+}
+
+// FaultRule describes the faults injected on matching requests
+type FaultRule struct {
+	Method      string        // HTTP method, or "*" for any
+	Pattern     string        // Route pattern (e.g. /api/databases/{id}/{collection}/), or "*" for any
+	Latency     time.Duration // Delay added before handling
+	LatencyRate float64       // Probability of adding the delay
+	ErrorRate   float64       // Probability of responding 500
+	QuotaRate   float64       // Probability of responding 402 on writes
+}
+
+// Matches reports whether the rule applies to a method and route pattern
+// Trailing slashes are ignored, as chi drops them from resolved patterns
+func (r FaultRule) Matches(method, pattern string) bool {
+	return (r.Method == "*" || strings.EqualFold(r.Method, method)) &&
+		(r.Pattern == "*" || strings.TrimSuffix(r.Pattern, "/") == strings.TrimSuffix(pattern, "/"))
+}
+
+// RuleFor returns the rule for a method and route pattern, falling back to the default
+func (c *FaultConfig) RuleFor(method, pattern string) FaultRule {
+	for _, rule := range c.Routes {
+		if rule.Matches(method, pattern) {
+			return rule
+		}
+	}
+	return c.Default
+}
+
+// loadFaultConfig reads the FAULT_* environment variables
+func loadFaultConfig() (FaultConfig, error) {
+	var cfg FaultConfig
+
+	enabled, err := strconv.ParseBool(getEnv("FAULT_INJECTION", "false"))
+	if err != nil {
+		return cfg, fmt.Errorf("invalid FAULT_INJECTION: %w", err)
+	}
+	cfg.Enabled = enabled
+
+	cfg.Default = FaultRule{Method: "*", Pattern: "*", LatencyRate: 1}
+	settings := map[string]string{
+		"latency":      getEnv("FAULT_LATENCY", "0s"),
+		"latency_rate": getEnv("FAULT_LATENCY_RATE", "1"),
+		"error_rate":   getEnv("FAULT_ERROR_RATE", "0"),
+		"quota_rate":   getEnv("FAULT_QUOTA_RATE", "0"),
+	}
+	for _, key := range []string{"latency", "latency_rate", "error_rate", "quota_rate"} {
+		if err := applyFaultSetting(&cfg.Default, key, settings[key]); err != nil {
+			return cfg, fmt.Errorf("invalid FAULT_%s: %w", strings.ToUpper(key), err)
+		}
+	}
+
+	routes, err := parseFaultRoutes(getEnv("FAULT_ROUTES", ""), cfg.Default)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid FAULT_ROUTES: %w", err)
+	}
+	cfg.Routes = routes
+
+	return cfg, nil
+}
+
+// parseFaultRoutes parses per-route fault rules
+// Format: "METHOD PATTERN:key=value,key=value;METHOD PATTERN:..." where METHOD
+// may be omitted or "*"; settings not given inherit from base
+func parseFaultRoutes(spec string, base FaultRule) ([]FaultRule, error) {
+	var rules []FaultRule
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, settings, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("rule %q missing ':' before settings", entry)
+		}
+
+		rule := base
+		parts := strings.Fields(route)
+		switch len(parts) {
+		case 1:
+			rule.Method, rule.Pattern = "*", parts[0]
+		case 2:
+			rule.Method, rule.Pattern = strings.ToUpper(parts[0]), parts[1]
+		default:
+			return nil, fmt.Errorf("rule %q must be \"[METHOD] PATTERN\"", route)
+		}
+
+		for _, setting := range strings.Split(settings, ",") {
+			setting = strings.TrimSpace(setting)
+			if setting == "" {
+				continue
+			}
+			key, value, found := strings.Cut(setting, "=")
+			if !found {
+				return nil, fmt.Errorf("setting %q must be key=value", setting)
+			}
+			if err := applyFaultSetting(&rule, strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("rule %q: %w", route, err)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// applyFaultSetting sets a single fault setting on a rule
+func applyFaultSetting(rule *FaultRule, key, value string) error {
+	if key == "latency" {
+		latency, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid latency: %w", err)
+		}
+		if latency < 0 {
+			return fmt.Errorf("latency must not be negative, got %s", value)
+		}
+		rule.Latency = latency
+		return nil
+	}
+
+	var target *float64
+	switch key {
+	case "latency_rate":
+		target = &rule.LatencyRate
+	case "error_rate":
+		target = &rule.ErrorRate
+	case "quota_rate":
+		target = &rule.QuotaRate
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("%s must be between 0 and 1, got %s", key, value)
+	}
+	*target = rate
+	return nil
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLoad_FaultsDisabledByDefault(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	if cfg.Faults.Enabled {
+		t.Error("Faults.Enabled = true, want false")
+	}
+	if len(cfg.Faults.Routes) != 0 {
+		t.Errorf("len(Faults.Routes) = %d, want 0", len(cfg.Faults.Routes))
+	}
+}
+
+func TestLoad_FaultDefaults(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("FAULT_INJECTION", "true")
+	os.Setenv("FAULT_LATENCY", "250ms")
+	os.Setenv("FAULT_LATENCY_RATE", "0.5")
+	os.Setenv("FAULT_ERROR_RATE", "0.1")
+	os.Setenv("FAULT_QUOTA_RATE", "0.05")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	rule := cfg.Faults.Default
+	if !cfg.Faults.Enabled {
+		t.Error("Faults.Enabled = false, want true")
+	}
+	if rule.Latency != 250*time.Millisecond {
+		t.Errorf("Latency = %v, want 250ms", rule.Latency)
+	}
+	if rule.LatencyRate != 0.5 {
+		t.Errorf("LatencyRate = %v, want 0.5", rule.LatencyRate)
+	}
+	if rule.ErrorRate != 0.1 {
+		t.Errorf("ErrorRate = %v, want 0.1", rule.ErrorRate)
+	}
+	if rule.QuotaRate != 0.05 {
+		t.Errorf("QuotaRate = %v, want 0.05", rule.QuotaRate)
+	}
+}
+
+func TestLoad_InvalidFaultRate(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("FAULT_ERROR_RATE", "1.5")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Load() error = nil, want error for FAULT_ERROR_RATE above 1")
+	}
+}
+
+func TestParseFaultRoutes(t *testing.T) {
+	base := FaultRule{Method: "*", Pattern: "*", Latency: time.Second, LatencyRate: 1, ErrorRate: 0.2}
+
+	rules, err := parseFaultRoutes("GET /api/databases/{id}/{collection}/:latency=50ms,error_rate=0; /api/databases:quota_rate=1", base)
+	if err != nil {
+		t.Fatalf("parseFaultRoutes() error = %v, want nil", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("len(rules) = %d, want 2", len(rules))
+	}
+
+	first := rules[0]
+	if first.Method != "GET" || first.Pattern != "/api/databases/{id}/{collection}/" {
+		t.Errorf("rules[0] route = %s %s, want GET /api/databases/{id}/{collection}/", first.Method, first.Pattern)
+	}
+	if first.Latency != 50*time.Millisecond || first.ErrorRate != 0 || first.LatencyRate != 1 {
+		t.Errorf("rules[0] = %+v, want latency 50ms, error_rate 0, inherited latency_rate 1", first)
+	}
+
+	second := rules[1]
+	if second.Method != "*" || second.QuotaRate != 1 || second.ErrorRate != 0.2 {
+		t.Errorf("rules[1] = %+v, want any method, quota_rate 1, inherited error_rate 0.2", second)
+	}
+}
+
+func TestParseFaultRoutes_Invalid(t *testing.T) {
+	specs := []string{
+		"GET /x",
+		"GET /x:bogus=1",
+		"GET /x:error_rate=2",
+		"GET /x:latency=fast",
+		"GET /x extra:error_rate=0.1",
+		"GET /x:error_rate",
+	}
+
+	for _, spec := range specs {
+		if _, err := parseFaultRoutes(spec, FaultRule{}); err == nil {
+			t.Errorf("parseFaultRoutes(%q) error = nil, want error", spec)
+		}
+	}
+}
+
+func TestFaultConfig_RuleFor(t *testing.T) {
+	cfg := FaultConfig{
+		Default: FaultRule{Method: "*", Pattern: "*", ErrorRate: 0.1},
+		Routes: []FaultRule{
+			{Method: "POST", Pattern: "/api/databases", ErrorRate: 1},
+			{Method: "*", Pattern: "/api/databases", ErrorRate: 0.5},
+		},
+	}
+
+	if got := cfg.RuleFor("POST", "/api/databases").ErrorRate; got != 1 {
+		t.Errorf("RuleFor(POST) ErrorRate = %v, want 1", got)
+	}
+	if got := cfg.RuleFor("GET", "/api/databases").ErrorRate; got != 0.5 {
+		t.Errorf("RuleFor(GET) ErrorRate = %v, want 0.5", got)
+	}
+	if got := cfg.RuleFor("GET", "/api/databases/").ErrorRate; got != 0.5 {
+		t.Errorf("RuleFor(GET with trailing slash) ErrorRate = %v, want 0.5", got)
+	}
+	if got := cfg.RuleFor("GET", "/other").ErrorRate; got != 0.1 {
+		t.Errorf("RuleFor(GET /other) ErrorRate = %v, want 0.1", got)
+	}
+}
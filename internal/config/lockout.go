@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// LockoutConfig controls throttling clients that keep presenting invalid keys
+type LockoutConfig struct {
+	Threshold int           // Failed attempts allowed before a client is locked out; 0 disables throttling
+	MaxDelay  time.Duration // Longest lockout, reached as failures continue; also how long failures are remembered
+}
+
+// Enabled reports whether failed attempts are throttled
+func (c LockoutConfig) Enabled() bool {
+	return c.Threshold > 0
+}
+
+// loadLockoutConfig reads the AUTH_LOCKOUT_* environment variables
+func loadLockoutConfig() (LockoutConfig, error) {
+	var cfg LockoutConfig
+
+	thresholdStr := getEnv("AUTH_LOCKOUT_THRESHOLD", "10")
+	threshold, err := strconv.Atoi(thresholdStr)
+	if err != nil || threshold < 0 {
+		return cfg, fmt.Errorf("invalid AUTH_LOCKOUT_THRESHOLD: %q must be a number of attempts, or 0 to disable", thresholdStr)
+	}
+	cfg.Threshold = threshold
+
+	maxDelayStr := getEnv("AUTH_LOCKOUT_MAX", "15m")
+	maxDelay, err := time.ParseDuration(maxDelayStr)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid AUTH_LOCKOUT_MAX: %w", err)
+	}
+	if maxDelay < time.Second {
+		return cfg, fmt.Errorf("AUTH_LOCKOUT_MAX must be at least 1s, got %s", maxDelayStr)
+	}
+	cfg.MaxDelay = maxDelay
+	return cfg, nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLoad_LockoutDefaults(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+	if !cfg.Lockout.Enabled() || cfg.Lockout.Threshold != 10 || cfg.Lockout.MaxDelay != 15*time.Minute {
+		t.Errorf("Lockout = %+v, want 10 attempts and at most 15m", cfg.Lockout)
+	}
+}
+
+func TestLoad_LockoutCustom(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("AUTH_LOCKOUT_THRESHOLD", "3")
+	os.Setenv("AUTH_LOCKOUT_MAX", "1h")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+	if cfg.Lockout.Threshold != 3 || cfg.Lockout.MaxDelay != time.Hour {
+		t.Errorf("Lockout = %+v, want 3 attempts and at most 1h", cfg.Lockout)
+	}
+
+	os.Setenv("AUTH_LOCKOUT_THRESHOLD", "0")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+	if cfg.Lockout.Enabled() {
+		t.Errorf("Lockout = %+v, want disabled", cfg.Lockout)
+	}
+}
+
+func TestLoad_LockoutInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{name: "negative threshold", env: map[string]string{"AUTH_LOCKOUT_THRESHOLD": "-1"}},
+		{name: "threshold not a number", env: map[string]string{"AUTH_LOCKOUT_THRESHOLD": "many"}},
+		{name: "max not a duration", env: map[string]string{"AUTH_LOCKOUT_MAX": "forever"}},
+		{name: "max too short", env: map[string]string{"AUTH_LOCKOUT_MAX": "500ms"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv()
+			defer clearEnv()
+
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() error = nil, want error for %v", tt.env)
+			}
+		})
+	}
+}
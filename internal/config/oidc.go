@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OIDCConfig controls signing in to the web console with an OpenID Connect provider
+type OIDCConfig struct {
+	Issuer       string        // Provider's issuer URL; empty disables the console
+	ClientID     string        // Client registered with the provider
+	ClientSecret string        // Client secret; empty for public clients, which rely on PKCE alone
+	RedirectURL  string        // Console callback registered with the provider, ending in /console/callback
+	Admins       []string      // Emails or subjects that see every database in the console
+	SessionTTL   time.Duration // How long a console login lasts
+}
+
+// Enabled reports whether the web console is served
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// loadOIDCConfig reads the OIDC_* environment variables
+func loadOIDCConfig() (OIDCConfig, error) {
+	cfg := OIDCConfig{
+		Issuer:       strings.TrimSuffix(getEnv("OIDC_ISSUER", ""), "/"),
+		ClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		ClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:  getEnv("OIDC_REDIRECT_URL", ""),
+	}
+
+	sessionTTLStr := getEnv("OIDC_SESSION_TTL", "12h")
+	sessionTTL, err := time.ParseDuration(sessionTTLStr)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid OIDC_SESSION_TTL: %w", err)
+	}
+	if sessionTTL < time.Minute {
+		return cfg, fmt.Errorf("OIDC_SESSION_TTL must be at least 1m, got %s", sessionTTLStr)
+	}
+	cfg.SessionTTL = sessionTTL
+
+	for _, admin := range strings.Split(getEnv("OIDC_ADMINS", ""), ",") {
+		if admin = strings.TrimSpace(admin); admin != "" {
+			cfg.Admins = append(cfg.Admins, admin)
+		}
+	}
+
+	if !cfg.Enabled() {
+		if cfg.ClientID != "" || cfg.RedirectURL != "" || len(cfg.Admins) > 0 {
+			return cfg, fmt.Errorf("OIDC_CLIENT_ID, OIDC_REDIRECT_URL and OIDC_ADMINS require OIDC_ISSUER")
+		}
+		return cfg, nil
+	}
+	if u, err := url.Parse(cfg.Issuer); err != nil || u.Host == "" || (u.Scheme != "https" && !isLoopback(u.Hostname())) {
+		return cfg, fmt.Errorf("invalid OIDC_ISSUER: %q must be an https URL", cfg.Issuer)
+	}
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return cfg, fmt.Errorf("OIDC_ISSUER requires OIDC_CLIENT_ID and OIDC_REDIRECT_URL")
+	}
+	if u, err := url.Parse(cfg.RedirectURL); err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") ||
+		!strings.HasSuffix(u.Path, "/console/callback") {
+		return cfg, fmt.Errorf("invalid OIDC_REDIRECT_URL: %q must be the console's callback URL, such as https://api.example.com/console/callback", cfg.RedirectURL)
+	}
+	return cfg, nil
+}
+
+// isLoopback reports whether a hostname is this machine, where plain HTTP providers are
+// allowed for development
+func isLoopback(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoad_OIDCDefaults(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+	if cfg.OIDC.Enabled() {
+		t.Errorf("OIDC = %+v, want disabled", cfg.OIDC)
+	}
+	if cfg.OIDC.SessionTTL != 12*time.Hour {
+		t.Errorf("OIDC.SessionTTL = %v, want 12h", cfg.OIDC.SessionTTL)
+	}
+}
+
+func TestLoad_OIDCCustom(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("OIDC_ISSUER", "https://login.example.com/")
+	os.Setenv("OIDC_CLIENT_ID", "jsondrop")
+	os.Setenv("OIDC_CLIENT_SECRET", "s3cret")
+	os.Setenv("OIDC_REDIRECT_URL", "https://api.example.com/jsondrop/console/callback")
+	os.Setenv("OIDC_ADMINS", "ops@example.com, 248289761001")
+	os.Setenv("OIDC_SESSION_TTL", "30m")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+	if !cfg.OIDC.Enabled() || cfg.OIDC.Issuer != "https://login.example.com" || cfg.OIDC.ClientID != "jsondrop" ||
+		cfg.OIDC.ClientSecret != "s3cret" || cfg.OIDC.RedirectURL != "https://api.example.com/jsondrop/console/callback" ||
+		!slices.Equal(cfg.OIDC.Admins, []string{"ops@example.com", "248289761001"}) || cfg.OIDC.SessionTTL != 30*time.Minute {
+		t.Errorf("OIDC = %+v, want the configured provider", cfg.OIDC)
+	}
+}
+
+func TestLoad_OIDCInvalid(t *testing.T) {
+	valid := map[string]string{
+		"OIDC_ISSUER":       "https://login.example.com",
+		"OIDC_CLIENT_ID":    "jsondrop",
+		"OIDC_REDIRECT_URL": "https://api.example.com/console/callback",
+	}
+	tests := []struct {
+		name     string
+		override map[string]string
+	}{
+		{name: "client without issuer", override: map[string]string{"OIDC_ISSUER": ""}},
+		{name: "plain http issuer", override: map[string]string{"OIDC_ISSUER": "http://login.example.com"}},
+		{name: "missing client", override: map[string]string{"OIDC_CLIENT_ID": ""}},
+		{name: "missing redirect", override: map[string]string{"OIDC_REDIRECT_URL": ""}},
+		{name: "redirect elsewhere", override: map[string]string{"OIDC_REDIRECT_URL": "https://api.example.com/callback"}},
+		{name: "short session", override: map[string]string{"OIDC_SESSION_TTL": "30s"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv()
+			defer clearEnv()
+
+			for key, value := range valid {
+				os.Setenv(key, value)
+			}
+			for key, value := range tt.override {
+				os.Setenv(key, value)
+			}
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() error = nil, want error for %v", tt.override)
+			}
+		})
+	}
+}
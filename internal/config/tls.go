@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TLSConfig controls serving HTTPS directly, without a reverse proxy terminating TLS
+type TLSConfig struct {
+	CertFile      string   // PEM certificate chain; with KeyFile serves HTTPS
+	KeyFile       string   // PEM private key of CertFile
+	AutocertHosts []string // Hostnames to obtain Let's Encrypt certificates for; serves HTTPS
+	AutocertEmail string   // Contact address of the ACME account; optional
+	AutocertCache string   // Directory keeping obtained certificates across restarts
+	RedirectPort  string   // Plain HTTP port redirecting to HTTPS and answering ACME challenges; empty disables
+}
+
+// Enabled reports whether the server serves HTTPS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || len(c.AutocertHosts) > 0
+}
+
+// loadTLSConfig reads the TLS_* environment variables
+func loadTLSConfig() (TLSConfig, error) {
+	cfg := TLSConfig{
+		CertFile:      getEnv("TLS_CERT_FILE", ""),
+		KeyFile:       getEnv("TLS_KEY_FILE", ""),
+		AutocertEmail: getEnv("TLS_AUTOCERT_EMAIL", ""),
+		AutocertCache: getEnv("TLS_AUTOCERT_CACHE_DIR", "./data/autocert"),
+		RedirectPort:  getEnv("TLS_REDIRECT_PORT", ""),
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return cfg, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	for _, host := range strings.Split(getEnv("TLS_AUTOCERT_HOSTS", ""), ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" {
+			continue
+		}
+		if strings.ContainsAny(host, "/:*@ ") || !strings.Contains(host, ".") {
+			return cfg, fmt.Errorf("invalid TLS_AUTOCERT_HOSTS: %q must be a plain hostname such as api.example.com", host)
+		}
+		cfg.AutocertHosts = append(cfg.AutocertHosts, host)
+	}
+	if cfg.CertFile != "" && len(cfg.AutocertHosts) > 0 {
+		return cfg, fmt.Errorf("TLS_CERT_FILE and TLS_AUTOCERT_HOSTS can't be used together")
+	}
+	if len(cfg.AutocertHosts) == 0 && cfg.AutocertEmail != "" {
+		return cfg, fmt.Errorf("TLS_AUTOCERT_EMAIL requires TLS_AUTOCERT_HOSTS")
+	}
+
+	if cfg.RedirectPort != "" {
+		if !cfg.Enabled() {
+			return cfg, fmt.Errorf("TLS_REDIRECT_PORT requires TLS_CERT_FILE or TLS_AUTOCERT_HOSTS")
+		}
+		if port, err := strconv.Atoi(cfg.RedirectPort); err != nil || port < 1 || port > 65535 {
+			return cfg, fmt.Errorf("invalid TLS_REDIRECT_PORT: %q is not a port number", cfg.RedirectPort)
+		}
+	}
+	return cfg, nil
+}
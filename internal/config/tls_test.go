@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestLoad_TLSDefaults(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	if cfg.TLS.Enabled() {
+		t.Errorf("TLS = %+v, want disabled", cfg.TLS)
+	}
+	if cfg.TLS.AutocertCache != "./data/autocert" {
+		t.Errorf("TLS.AutocertCache = %s, want ./data/autocert", cfg.TLS.AutocertCache)
+	}
+}
+
+func TestLoad_TLSCustom(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	os.Setenv("TLS_CERT_FILE", "/etc/jsondrop/cert.pem")
+	os.Setenv("TLS_KEY_FILE", "/etc/jsondrop/key.pem")
+	os.Setenv("TLS_REDIRECT_PORT", "80")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+	if !cfg.TLS.Enabled() || cfg.TLS.CertFile != "/etc/jsondrop/cert.pem" || cfg.TLS.KeyFile != "/etc/jsondrop/key.pem" || cfg.TLS.RedirectPort != "80" {
+		t.Errorf("TLS = %+v, want the certificate files and redirect port", cfg.TLS)
+	}
+
+	clearEnv()
+	os.Setenv("TLS_AUTOCERT_HOSTS", "API.example.com, data.example.com")
+	os.Setenv("TLS_AUTOCERT_EMAIL", "ops@example.com")
+	os.Setenv("TLS_AUTOCERT_CACHE_DIR", "/var/lib/jsondrop/autocert")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+	if !cfg.TLS.Enabled() || !slices.Equal(cfg.TLS.AutocertHosts, []string{"api.example.com", "data.example.com"}) ||
+		cfg.TLS.AutocertEmail != "ops@example.com" || cfg.TLS.AutocertCache != "/var/lib/jsondrop/autocert" {
+		t.Errorf("TLS = %+v, want autocert for both hosts", cfg.TLS)
+	}
+}
+
+func TestLoad_TLSInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{name: "cert without key", env: map[string]string{"TLS_CERT_FILE": "cert.pem"}},
+		{name: "key without cert", env: map[string]string{"TLS_KEY_FILE": "key.pem"}},
+		{name: "files and autocert", env: map[string]string{"TLS_CERT_FILE": "cert.pem", "TLS_KEY_FILE": "key.pem", "TLS_AUTOCERT_HOSTS": "api.example.com"}},
+		{name: "host with scheme", env: map[string]string{"TLS_AUTOCERT_HOSTS": "https://api.example.com"}},
+		{name: "host with port", env: map[string]string{"TLS_AUTOCERT_HOSTS": "api.example.com:443"}},
+		{name: "wildcard host", env: map[string]string{"TLS_AUTOCERT_HOSTS": "*.example.com"}},
+		{name: "email without hosts", env: map[string]string{"TLS_AUTOCERT_EMAIL": "ops@example.com"}},
+		{name: "redirect without TLS", env: map[string]string{"TLS_REDIRECT_PORT": "80"}},
+		{name: "bad redirect port", env: map[string]string{"TLS_AUTOCERT_HOSTS": "api.example.com", "TLS_REDIRECT_PORT": "http"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv()
+			defer clearEnv()
+
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() error = nil, want error for %v", tt.env)
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+// Package console serves the web console, where people signed in with the configured
+// OpenID Connect provider manage the databases they own without handling write keys.
+// A database is owned by whoever claimed it once with its write key; OIDC_ADMINS see
+// every database
+package console
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"jsondrop/internal/clientaddr"
+	"jsondrop/internal/config"
+	"jsondrop/internal/database"
+	"jsondrop/internal/lockout"
+	"jsondrop/internal/models"
+	"jsondrop/internal/oidc"
+	"jsondrop/internal/token"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	sessionCookie = "jsondrop_session"
+	loginCookie   = "jsondrop_login" // State, nonce and PKCE verifier of a sign-in in progress
+	loginTTL      = 10 * time.Minute
+)
+
+// contextKey is a type for context keys
+type contextKey string
+
+// contextKeySession holds the signed-in session of a request
+const contextKeySession contextKey = "session"
+
+// Console serves the web console
+type Console struct {
+	catalog  *database.CatalogDB
+	provider *oidc.Provider
+	tokens   *token.Issuer
+	lockout  *lockout.Guard // The API's, so write keys can't be guessed here instead; nil when lockout is disabled
+	cfg      *config.Config
+	path     string // Where the console is served, BASE_PATH included
+	secure   bool   // Whether cookies are only sent over HTTPS
+}
+
+// New creates the web console, signing people in with provider; sessions are signed by
+// tokens, so they last across restarts when TOKEN_SECRET is set. Failed claims count
+// toward guard's lockout
+func New(catalog *database.CatalogDB, provider *oidc.Provider, tokens *token.Issuer, guard *lockout.Guard, cfg *config.Config) *Console {
+	redirect, _ := url.Parse(cfg.OIDC.RedirectURL)
+	return &Console{
+		catalog:  catalog,
+		provider: provider,
+		tokens:   tokens,
+		lockout:  guard,
+		cfg:      cfg,
+		path:     cfg.BasePath + "/console",
+		secure:   redirect != nil && redirect.Scheme == "https",
+	}
+}
+
+// Routes returns the console's routes, to be mounted at /console
+func (c *Console) Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(securityHeaders)
+
+	r.Get("/login", c.login)
+	r.Get("/callback", c.callback)
+	r.Post("/logout", c.logout)
+
+	r.Group(func(r chi.Router) {
+		r.Use(c.requireSession)
+
+		r.Get("/", c.index)
+		r.Post("/claim", c.claim)
+		r.Get("/databases/{id}", c.database)
+		r.Post("/databases/{id}/release", c.release)
+		r.Post("/databases/{id}/tokens", c.mintToken)
+	})
+	return r
+}
+
+// securityHeaders keeps console pages out of caches and frames, and stops them loading
+// anything from elsewhere
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireSession sends people who aren't signed in to sign in, and checks that forms
+// come from console pages
+func (c *Console) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var session *token.Session
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			session, _ = c.tokens.VerifySession(cookie.Value, time.Now())
+		}
+		if session == nil {
+			if r.Method != http.MethodGet {
+				c.fail(w, http.StatusUnauthorized, "Your session has expired. Sign in again.")
+				return
+			}
+			http.Redirect(w, r, c.path+"/login", http.StatusFound)
+			return
+		}
+		if r.Method == http.MethodPost && subtle.ConstantTimeCompare([]byte(r.PostFormValue("csrf")), []byte(session.CSRF)) != 1 {
+			c.fail(w, http.StatusForbidden, "This form has expired. Go back, reload the page and try again.")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeySession, session)))
+	})
+}
+
+// login sends the browser to the provider to sign in
+func (c *Console) login(w http.ResponseWriter, r *http.Request) {
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	authURL, err := c.provider.AuthURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("Console sign-in failed: %v", err)
+		c.fail(w, http.StatusBadGateway, "The identity provider can't be reached. Try again later.")
+		return
+	}
+	c.setCookie(w, loginCookie, state+"."+nonce+"."+verifier, loginTTL)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// callback completes a sign-in when the provider sends the browser back
+func (c *Console) callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	cookie, err := r.Cookie(loginCookie)
+	var parts []string
+	if err == nil {
+		parts = strings.Split(cookie.Value, ".")
+	}
+	if len(parts) != 3 {
+		c.fail(w, http.StatusBadRequest, "Signing in took too long. Sign in again.")
+		return
+	}
+	c.setCookie(w, loginCookie, "", -1)
+	state, nonce, verifier := parts[0], parts[1], parts[2]
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		c.fail(w, http.StatusBadRequest, "This sign-in wasn't started here. Sign in again.")
+		return
+	}
+	if reason := query.Get("error"); reason != "" {
+		c.fail(w, http.StatusUnauthorized, "The identity provider refused the sign-in: "+strings.TrimSpace(reason+" "+query.Get("error_description")))
+		return
+	}
+
+	identity, err := c.provider.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		log.Printf("Console sign-in failed: %v", err)
+		c.fail(w, http.StatusUnauthorized, "Signing in failed. Sign in again.")
+		return
+	}
+	session := token.Session{
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		Name:      identity.Name,
+		CSRF:      randomString(),
+		ExpiresAt: time.Now().Add(c.cfg.OIDC.SessionTTL).Unix(),
+	}
+	value, err := c.tokens.SignSession(session)
+	if err != nil {
+		c.fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.setCookie(w, sessionCookie, value, c.cfg.OIDC.SessionTTL)
+	http.Redirect(w, r, c.path+"/", http.StatusFound)
+}
+
+// logout forgets the session; the provider's own session is left alone
+func (c *Console) logout(w http.ResponseWriter, r *http.Request) {
+	c.setCookie(w, sessionCookie, "", -1)
+	c.render(w, http.StatusOK, "signedout", page{Base: c.path})
+}
+
+// index lists the databases the signed-in person owns, or every database for admins
+func (c *Console) index(w http.ResponseWriter, r *http.Request) {
+	c.renderIndex(w, r, http.StatusOK, "")
+}
+
+// renderIndex renders the database list, with an error from claiming a database
+func (c *Console) renderIndex(w http.ResponseWriter, r *http.Request, status int, message string) {
+	session := sessionFromContext(r)
+	var databases []*models.Database
+	var err error
+	if c.isAdmin(session) {
+		databases, err = c.catalog.ListDatabases()
+	} else {
+		databases, err = c.catalog.OwnedDatabases(session.Subject)
+	}
+	if err != nil {
+		c.fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.render(w, status, "index", page{Base: c.path, Session: session, Admin: c.isAdmin(session), Databases: databases, Error: message})
+}
+
+// claim makes the signed-in person an owner of the database a write key belongs to
+// Only the current write key claims a database, not one replaced by a rotation during
+// its grace period, and wrong keys count toward the lockout as they do on the API
+func (c *Console) claim(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r)
+	writeKey := strings.TrimSpace(r.PostFormValue("write_key"))
+	if !strings.HasPrefix(writeKey, "wk_") {
+		c.renderIndex(w, r, http.StatusBadRequest, "Enter the database's write key, starting with wk_.")
+		return
+	}
+	addr, _ := clientaddr.FromRequest(r, c.cfg.TrustedProxies)
+	if wait := c.lockout.Wait(addr, time.Now()); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.renderIndex(w, r, http.StatusTooManyRequests, "Too many wrong write keys. Try again later.")
+		return
+	}
+	db, err := c.catalog.GetDatabaseByWriteKey(writeKey)
+	if err != nil {
+		c.fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if db == nil || subtle.ConstantTimeCompare([]byte(db.WriteKey), []byte(writeKey)) != 1 {
+		c.lockout.Fail(addr, time.Now())
+		c.renderIndex(w, r, http.StatusUnprocessableEntity, "No database has that write key.")
+		return
+	}
+	if err := c.catalog.AddOwner(db.ID, session.Subject); err != nil {
+		if strings.Contains(err.Error(), "limit exceeded") {
+			c.renderIndex(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		c.fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	http.Redirect(w, r, c.path+"/databases/"+db.ID, http.StatusSeeOther)
+}
+
+// database shows a database the signed-in person may manage, with its collections
+func (c *Console) database(w http.ResponseWriter, r *http.Request) {
+	db := c.ownedDatabase(w, r)
+	if db == nil {
+		return
+	}
+	c.renderDatabase(w, r, db, nil)
+}
+
+// renderDatabase renders a database's page, with a token just minted
+func (c *Console) renderDatabase(w http.ResponseWriter, r *http.Request, db *models.Database, minted *mintedToken) {
+	collections, err := c.catalog.ListCollections(db.ID, nil)
+	if err != nil {
+		c.fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	session := sessionFromContext(r)
+	c.render(w, http.StatusOK, "database", page{
+		Base:        c.path,
+		Session:     session,
+		Admin:       c.isAdmin(session),
+		Database:    db,
+		Collections: collections,
+		Token:       minted,
+		TokenTTL:    c.cfg.TokenMaxTTL,
+	})
+}
+
+// release forgets the signed-in person's ownership of a database
+func (c *Console) release(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r)
+	if err := c.catalog.RemoveOwner(chi.URLParam(r, "id"), session.Subject); err != nil && !strings.Contains(err.Error(), "not found") {
+		c.fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	http.Redirect(w, r, c.path+"/", http.StatusSeeOther)
+}
+
+// mintedToken is an access token minted in the console, shown once
+type mintedToken struct {
+	Token     string
+	Access    string
+	ExpiresAt time.Time
+}
+
+// mintToken mints an access token lasting TOKEN_MAX_TTL, for dashboards and scripts
+// that would otherwise be given the database's keys
+func (c *Console) mintToken(w http.ResponseWriter, r *http.Request) {
+	db := c.ownedDatabase(w, r)
+	if db == nil {
+		return
+	}
+	access := r.PostFormValue("access")
+	if access != models.KeyAccessWrite && access != models.KeyAccessRead {
+		c.fail(w, http.StatusBadRequest, "Choose read or write access.")
+		return
+	}
+	// The token acts for the signed-in person, whom read filters see as token.sub
+	now := time.Now()
+	claims := token.Claims{DatabaseID: db.ID, Access: access, Generation: db.KeyGeneration, Caller: sessionFromContext(r).Subject}
+	signed, err := c.tokens.Mint(claims, c.cfg.TokenMaxTTL, now)
+	if err != nil {
+		c.fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.renderDatabase(w, r, db, &mintedToken{Token: signed, Access: access, ExpiresAt: now.Add(c.cfg.TokenMaxTTL)})
+}
+
+// ownedDatabase loads the database in the URL if the signed-in person owns it or is an
+// admin; otherwise it responds 404, so others can't tell which databases exist
+func (c *Console) ownedDatabase(w http.ResponseWriter, r *http.Request) *models.Database {
+	session := sessionFromContext(r)
+	dbID := chi.URLParam(r, "id")
+	if !c.isAdmin(session) {
+		owner, err := c.catalog.IsOwner(dbID, session.Subject)
+		if err != nil {
+			c.fail(w, http.StatusInternalServerError, err.Error())
+			return nil
+		}
+		if !owner {
+			c.fail(w, http.StatusNotFound, "You don't own a database with that ID.")
+			return nil
+		}
+	}
+	db, err := c.catalog.GetDatabaseByID(dbID)
+	if err != nil {
+		c.fail(w, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+	if db == nil {
+		c.fail(w, http.StatusNotFound, "You don't own a database with that ID.")
+		return nil
+	}
+	return db
+}
+
+// isAdmin reports whether a person sees every database: their subject, or their
+// verified email, is in OIDC_ADMINS
+func (c *Console) isAdmin(session *token.Session) bool {
+	return slices.ContainsFunc(c.cfg.OIDC.Admins, func(admin string) bool {
+		return admin == session.Subject || (session.Email != "" && strings.EqualFold(admin, session.Email))
+	})
+}
+
+// setCookie sets a console cookie lasting ttl; a negative ttl deletes it
+func (c *Console) setCookie(w http.ResponseWriter, name string, value string, ttl time.Duration) {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     c.path,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// fail renders an error page
+func (c *Console) fail(w http.ResponseWriter, status int, message string) {
+	c.render(w, status, "error", page{Base: c.path, Error: message, Status: status})
+}
+
+// render writes a console page
+func (c *Console) render(w http.ResponseWriter, status int, name string, data page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := pages.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Failed to render console page %s: %v", name, err)
+	}
+}
+
+// sessionFromContext returns the session requireSession found
+func sessionFromContext(r *http.Request) *token.Session {
+	session, _ := r.Context().Value(contextKeySession).(*token.Session)
+	return session
+}
+
+// randomString returns 32 random bytes, base64url-encoded
+func randomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,203 @@
+package console
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/lockout"
+	"jsondrop/internal/oidc"
+	"jsondrop/internal/token"
+)
+
+// newTestProvider starts an OpenID provider that signs in whoever subject says, with the
+// nonce of the last authorization request
+func newTestProvider(t *testing.T, subject *string) *httptest.Server {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	var idp *httptest.Server
+	var nonce string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		nonce = r.URL.Query().Get("nonce")
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "k1", "n": encode(key.N.Bytes()), "e": encode(big.NewInt(int64(key.E)).Bytes())},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1"})
+		claims, _ := json.Marshal(map[string]any{
+			"iss": idp.URL, "sub": *subject, "aud": "console", "exp": time.Now().Add(time.Hour).Unix(), "nonce": nonce,
+			"email": *subject + "@example.com", "email_verified": true,
+		})
+		signed := encode(header) + "." + encode(claims)
+		digest := sha256.Sum256([]byte(signed))
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed + "." + encode(signature)})
+	})
+	idp = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// signIn goes through the sign-in flow and returns the session cookie
+func signIn(t *testing.T, routes http.Handler) *http.Cookie {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("GET /login = %d, want 302", rec.Code)
+	}
+	authURL, _ := url.Parse(rec.Header().Get("Location"))
+	if resp, err := http.Get(authURL.String()); err == nil {
+		resp.Body.Close()
+	}
+	login := rec.Result().Cookies()[0]
+
+	req := httptest.NewRequest(http.MethodGet, "/callback?code=c0de&state="+authURL.Query().Get("state"), nil)
+	req.AddCookie(login)
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("GET /callback = %d, want 302: %s", rec.Code, rec.Body)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == sessionCookie && cookie.Value != "" {
+			return cookie
+		}
+	}
+	t.Fatal("GET /callback set no session cookie")
+	return nil
+}
+
+func TestConsole(t *testing.T) {
+	subject := "ada"
+	idp := newTestProvider(t, &subject)
+
+	catalog := newTestCatalog(t)
+	newTestDatabase(t, catalog, "db_console")
+
+	cfg := &config.Config{
+		TokenMaxTTL: time.Hour,
+		OIDC: config.OIDCConfig{Issuer: idp.URL, ClientID: "console", RedirectURL: "https://api.example.com/console/callback",
+			Admins: []string{"grace@example.com"}, SessionTTL: time.Hour},
+	}
+	tokens, _ := token.NewIssuer(nil)
+	routes := New(catalog, oidc.NewProvider(idp.URL, "console", "", cfg.OIDC.RedirectURL), tokens, lockout.New(1, time.Minute), cfg).Routes()
+
+	do := func(method string, target string, session *http.Cookie, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if session != nil {
+			req.AddCookie(session)
+		}
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/", nil, nil); rec.Code != http.StatusFound || rec.Header().Get("Location") != "/console/login" {
+		t.Errorf("GET / signed out = %d to %q, want a redirect to sign in", rec.Code, rec.Header().Get("Location"))
+	}
+
+	ada := signIn(t, routes)
+	session, err := tokens.VerifySession(ada.Value, time.Now())
+	if err != nil || session.Subject != "ada" || session.Email != "ada@example.com" {
+		t.Fatalf("session = %+v, %v, want ada", session, err)
+	}
+	if rec := do(http.MethodGet, "/", ada, nil); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "db_console") {
+		t.Errorf("GET / = %d, want ada's empty list", rec.Code)
+	}
+
+	// Claiming needs the form's CSRF value and the write key
+	claim := url.Values{"write_key": {"wk_console"}}
+	if rec := do(http.MethodPost, "/claim", ada, claim); rec.Code != http.StatusForbidden {
+		t.Errorf("POST /claim without csrf = %d, want 403", rec.Code)
+	}
+	claim.Set("csrf", session.CSRF)
+	claim.Set("write_key", "rk_console")
+	if rec := do(http.MethodPost, "/claim", ada, claim); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /claim with the read key = %d, want 400", rec.Code)
+	}
+	claim.Set("write_key", "wk_console")
+	if rec := do(http.MethodPost, "/claim", ada, claim); rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/console/databases/db_console" {
+		t.Fatalf("POST /claim = %d to %q, want a redirect to the database", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := do(http.MethodGet, "/", ada, nil); !strings.Contains(rec.Body.String(), "db_console") {
+		t.Errorf("GET / after claiming doesn't list db_console")
+	}
+	if rec := do(http.MethodGet, "/databases/db_console", ada, nil); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "wk_console") {
+		t.Errorf("GET /databases/db_console = %d, want the database without its keys", rec.Code)
+	}
+
+	rec := do(http.MethodPost, "/databases/db_console/tokens", ada, url.Values{"csrf": {session.CSRF}, "access": {"read"}})
+	minted := regexp.MustCompile(`<code>(eyJ[^<]+)</code>`).FindStringSubmatch(rec.Body.String())
+	if rec.Code != http.StatusOK || minted == nil {
+		t.Fatalf("POST /databases/db_console/tokens = %d, want a token", rec.Code)
+	}
+	if claims, err := tokens.Verify(minted[1], time.Now()); err != nil || claims.DatabaseID != "db_console" || claims.Access != "read" || claims.Generation != 0 {
+		t.Errorf("minted token claims = %+v, %v, want a read token of db_console's first key generation", claims, err)
+	}
+
+	// Others can't see it, except admins
+	subject = "bob"
+	bob := signIn(t, routes)
+	if rec := do(http.MethodGet, "/databases/db_console", bob, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /databases/db_console as bob = %d, want 404", rec.Code)
+	}
+	subject = "grace"
+	grace := signIn(t, routes)
+	if rec := do(http.MethodGet, "/databases/db_console", grace, nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /databases/db_console as an admin = %d, want 200", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/databases/db_console/release", ada, url.Values{"csrf": {session.CSRF}}); rec.Code != http.StatusSeeOther {
+		t.Errorf("POST /databases/db_console/release = %d, want 303", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/databases/db_console", ada, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /databases/db_console after release = %d, want 404", rec.Code)
+	}
+
+	// Only the current write key claims a database, and wrong keys lock the client out
+	rotated, err := catalog.RotateKeys("db_console", true, false, time.Hour)
+	if err != nil {
+		t.Fatalf("RotateKeys() error = %v", err)
+	}
+	for _, key := range []string{"wk_console", "wk_wrong"} {
+		claim.Set("write_key", key)
+		if rec := do(http.MethodPost, "/claim", ada, claim); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("POST /claim with %s = %d, want 422", key, rec.Code)
+		}
+	}
+	claim.Set("write_key", rotated.WriteKey)
+	if rec := do(http.MethodPost, "/claim", ada, claim); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("POST /claim when locked out = %d, want 429 with Retry-After", rec.Code)
+	}
+}
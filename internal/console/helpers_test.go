@@ -0,0 +1,32 @@
+package console
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"jsondrop/internal/database"
+)
+
+// newTestCatalog opens an empty catalog in a temporary directory, closed when the
+// test ends
+func newTestCatalog(t *testing.T) *database.CatalogDB {
+	t.Helper()
+	dir := t.TempDir()
+	c, err := database.NewCatalogDB(filepath.Join(dir, "catalog.db"), dir, 1, database.Limits{}, database.PoolConfig{}, database.Compression{}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewCatalogDB() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// newTestDatabase creates a database with a 1 MB quota, whose keys follow its ID:
+// db_notes gets wk_notes and rk_notes
+func newTestDatabase(t *testing.T, c *database.CatalogDB, dbID string) {
+	t.Helper()
+	name := strings.TrimPrefix(dbID, "db_")
+	if _, err := c.CreateDatabaseWithKeys(dbID, "wk_"+name, "rk_"+name, 1<<20); err != nil {
+		t.Fatalf("CreateDatabaseWithKeys(%s) error = %v", dbID, err)
+	}
+}
@@ -0,0 +1,140 @@
+package console
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+
+	"jsondrop/internal/models"
+	"jsondrop/internal/token"
+)
+
+// page is what console templates render
+type page struct {
+	Base        string // Console path, for links
+	Session     *token.Session
+	Admin       bool
+	Databases   []*models.Database
+	Database    *models.Database
+	Collections []*models.CollectionInfo
+	Token       *mintedToken
+	TokenTTL    time.Duration
+	Error       string
+	Status      int
+}
+
+// pages are the console's templates
+var pages = template.Must(template.New("console").Funcs(template.FuncMap{
+	"bytes": formatBytes,
+	"time":  func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 UTC") },
+}).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>JSONDrop console</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+header { display: flex; justify-content: space-between; align-items: center; border-bottom: 1px solid #ddd; margin-bottom: 1.5rem; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5rem; }
+th, td { text-align: left; padding: .4rem .6rem; border-bottom: 1px solid #eee; }
+code, input[type=text] { font-family: ui-monospace, monospace; }
+input[type=text] { width: 24rem; padding: .3rem; }
+.error { background: #fdecea; border: 1px solid #f5c2c0; padding: .6rem; }
+.token { background: #eef6ee; border: 1px solid #b9dbb9; padding: .6rem; word-break: break-all; }
+form.inline { display: inline; }
+</style>
+</head>
+<body>
+<header>
+<h1><a href="{{.Base}}/">JSONDrop console</a></h1>
+{{if .Session}}<form class="inline" method="post" action="{{.Base}}/logout">
+{{with .Session.Email}}{{.}}{{else}}{{with $.Session.Name}}{{.}}{{else}}{{$.Session.Subject}}{{end}}{{end}}{{if .Admin}} (admin){{end}}
+<button>Sign out</button>
+</form>{{end}}
+</header>
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "index"}}{{template "header" .}}
+<h2>{{if .Admin}}All databases{{else}}Your databases{{end}}</h2>
+{{if .Databases}}<table>
+<tr><th>Database</th><th>Created</th><th>Last accessed</th><th>Storage</th><th></th></tr>
+{{range .Databases}}<tr>
+<td><a href="{{$.Base}}/databases/{{.ID}}"><code>{{.ID}}</code></a></td>
+<td>{{time .CreatedAt}}</td>
+<td>{{time .LastAccessed}}</td>
+<td>{{bytes .QuotaUsed}} of {{bytes .QuotaLimit}}</td>
+<td>{{if .Protected}}Protected{{end}}</td>
+</tr>{{end}}
+</table>{{else}}<p>You don't own any databases yet.</p>{{end}}
+<h2>Add a database</h2>
+<p>Enter a database's write key once to manage it here from then on. The key isn't stored with your account.</p>
+<form method="post" action="{{.Base}}/claim">
+<input type="hidden" name="csrf" value="{{.Session.CSRF}}">
+<input type="text" name="write_key" placeholder="wk_..." autocomplete="off" required>
+<button>Add</button>
+</form>
+{{template "footer" .}}{{end}}
+
+{{define "database"}}{{template "header" .}}
+<h2><code>{{.Database.ID}}</code></h2>
+<table>
+<tr><th>Created</th><td>{{time .Database.CreatedAt}}</td></tr>
+<tr><th>Last accessed</th><td>{{time .Database.LastAccessed}}</td></tr>
+<tr><th>Storage</th><td>{{bytes .Database.QuotaUsed}} of {{bytes .Database.QuotaLimit}}</td></tr>
+<tr><th>Deletion</th><td>{{if .Database.Protected}}Protected, deletions need confirming{{else}}Not protected{{end}}</td></tr>
+<tr><th>Networks</th><td>{{range .Database.AllowedNetworks}}<code>{{.}}</code> {{else}}Any address{{end}}</td></tr>
+</table>
+<h3>Collections</h3>
+{{if .Collections}}<table>
+<tr><th>Collection</th><th>Documents</th><th>Size</th><th>Created</th></tr>
+{{range .Collections}}<tr><td><code>{{.Name}}</code></td><td>{{.DocumentCount}}</td><td>{{bytes .SizeBytes}}</td><td>{{time .CreatedAt}}</td></tr>{{end}}
+</table>{{else}}<p>No collections yet.</p>{{end}}
+<h3>Access tokens</h3>
+<p>Give dashboards and scripts an access token instead of the database's keys. Tokens last {{.TokenTTL}} and can't be revoked before they expire.</p>
+{{with .Token}}<p class="token">{{.Access}} token, expiring {{time .ExpiresAt}}. Copy it now, it isn't shown again:<br><code>{{.Token}}</code></p>{{end}}
+<form class="inline" method="post" action="{{.Base}}/databases/{{.Database.ID}}/tokens">
+<input type="hidden" name="csrf" value="{{.Session.CSRF}}">
+<input type="hidden" name="access" value="read">
+<button>Mint read token</button>
+</form>
+<form class="inline" method="post" action="{{.Base}}/databases/{{.Database.ID}}/tokens">
+<input type="hidden" name="csrf" value="{{.Session.CSRF}}">
+<input type="hidden" name="access" value="write">
+<button>Mint write token</button>
+</form>
+<h3>Ownership</h3>
+<form method="post" action="{{.Base}}/databases/{{.Database.ID}}/release">
+<input type="hidden" name="csrf" value="{{.Session.CSRF}}">
+<p>Removing the database from your account leaves the database itself alone. <button>Remove from my databases</button></p>
+</form>
+{{template "footer" .}}{{end}}
+
+{{define "signedout"}}{{template "header" .}}
+<p>You have signed out. <a href="{{.Base}}/login">Sign in again</a></p>
+{{template "footer" .}}{{end}}
+
+{{define "error"}}{{template "header" .}}
+<p><a href="{{.Base}}/">Back to your databases</a></p>
+{{template "footer" .}}{{end}}
+`))
+
+// formatBytes formats a byte count for people
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,344 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// maxBuckets caps the number of buckets in a histogram, including empty ones between the first and last
+const maxBuckets = 1000
+
+// Date bucket intervals
+const (
+	IntervalHour  = "hour"
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// intervalSeconds gives the length of the fixed-length intervals
+// Months vary in length and are bucketed by calendar month instead
+var intervalSeconds = map[string]int64{
+	IntervalHour: 3600,
+	IntervalDay:  86400,
+	IntervalWeek: 7 * 86400,
+}
+
+// weekOffset aligns week buckets to Mondays; the Unix epoch fell on a Thursday
+const weekOffset = 4 * 86400
+
+// Bucketing describes how to group documents into a histogram
+// Date buckets apply to created_at and updated_at, numeric buckets to number fields
+type Bucketing struct {
+	Field      string
+	Interval   string  // Date buckets: hour, day, week or month (UTC)
+	BucketSize float64 // Numeric buckets: width of each bucket
+}
+
+// ParseBucketing validates histogram parameters against a collection's schema
+// Returns nil when no field is given, meaning documents aren't bucketed
+func ParseBucketing(field string, interval string, bucketSize string, fields map[string]models.FieldType) (*Bucketing, error) {
+	if field == "" {
+		if interval != "" || bucketSize != "" {
+			return nil, fmt.Errorf("invalid aggregation: interval and bucket_size require a field")
+		}
+		return nil, nil
+	}
+
+	if field == "created_at" || field == "updated_at" {
+		if bucketSize != "" {
+			return nil, fmt.Errorf("invalid aggregation: %s uses interval, not bucket_size", field)
+		}
+		if _, fixed := intervalSeconds[interval]; !fixed && interval != IntervalMonth {
+			return nil, fmt.Errorf("invalid aggregation: interval must be hour, day, week or month")
+		}
+		return &Bucketing{Field: field, Interval: interval}, nil
+	}
+
+	if fields[field] != models.FieldTypeNumber {
+		return nil, fmt.Errorf("invalid aggregation: %s is not created_at, updated_at or a number field", field)
+	}
+	if interval != "" {
+		return nil, fmt.Errorf("invalid aggregation: %s uses bucket_size, not interval", field)
+	}
+	size, err := strconv.ParseFloat(bucketSize, 64)
+	if err != nil || size <= 0 || math.IsInf(size, 0) {
+		return nil, fmt.Errorf("invalid aggregation: bucket_size must be a positive number")
+	}
+	return &Bucketing{Field: field, BucketSize: size}, nil
+}
+
+// ParseStatsField validates the number field summarized by an aggregation
+func ParseStatsField(field string, fields map[string]models.FieldType) (string, error) {
+	if field != "" && fields[field] != models.FieldTypeNumber {
+		return "", fmt.Errorf("invalid aggregation: stats field %s is not a number field", field)
+	}
+	return field, nil
+}
+
+// histogram counts documents per bucket index, and collects a number field's values for statistics
+// A nil bucketing collects statistics only
+type histogram struct {
+	bucketing  *Bucketing
+	statsField string
+	counts     map[int64]int
+	values     map[int64][]float64 // Stats values per bucket
+	all        []float64           // Stats values of every document
+	missing    int
+}
+
+// newHistogram creates an empty histogram
+func newHistogram(bucketing *Bucketing, statsField string) *histogram {
+	return &histogram{
+		bucketing:  bucketing,
+		statsField: statsField,
+		counts:     make(map[int64]int),
+		values:     make(map[int64][]float64),
+	}
+}
+
+// add counts a document in its bucket
+func (h *histogram) add(doc *models.Document) {
+	value, hasValue := doc.Data[h.statsField].(float64)
+	hasValue = hasValue && h.statsField != ""
+	if hasValue {
+		h.all = append(h.all, value)
+	}
+
+	if h.bucketing == nil {
+		return
+	}
+	index, ok := h.index(doc)
+	if !ok {
+		h.missing++
+		return
+	}
+	h.counts[index]++
+	if hasValue {
+		h.values[index] = append(h.values[index], value)
+	}
+}
+
+// index returns the bucket index of a document, or false if it has no value for the field
+func (h *histogram) index(doc *models.Document) (int64, bool) {
+	switch h.bucketing.Field {
+	case "created_at":
+		return h.timeIndex(doc.CreatedAt), true
+	case "updated_at":
+		return h.timeIndex(doc.UpdatedAt), true
+	}
+
+	value, ok := doc.Data[h.bucketing.Field].(float64)
+	if !ok {
+		return 0, false
+	}
+	// Values too far out to index exactly are counted as missing
+	index := math.Floor(value / h.bucketing.BucketSize)
+	if math.Abs(index) > 1<<53 {
+		return 0, false
+	}
+	return int64(index), true
+}
+
+// timeIndex returns the date bucket index of a timestamp
+func (h *histogram) timeIndex(t time.Time) int64 {
+	t = t.UTC()
+	if h.bucketing.Interval == IntervalMonth {
+		return int64(t.Year())*12 + int64(t.Month()) - 1
+	}
+
+	seconds := intervalSeconds[h.bucketing.Interval]
+	offset := int64(0)
+	if h.bucketing.Interval == IntervalWeek {
+		offset = weekOffset
+	}
+	return floorDiv(t.Unix()-offset, seconds)
+}
+
+// bounds returns the start and end of a bucket
+func (h *histogram) bounds(index int64) (interface{}, interface{}) {
+	if h.bucketing.Field != "created_at" && h.bucketing.Field != "updated_at" {
+		size := h.bucketing.BucketSize
+		return float64(index) * size, float64(index+1) * size
+	}
+
+	if h.bucketing.Interval == IntervalMonth {
+		start := time.Date(int(index/12), time.Month(index%12+1), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+
+	seconds := intervalSeconds[h.bucketing.Interval]
+	offset := int64(0)
+	if h.bucketing.Interval == IntervalWeek {
+		offset = weekOffset
+	}
+	start := index*seconds + offset
+	return time.Unix(start, 0).UTC(), time.Unix(start+seconds, 0).UTC()
+}
+
+// buckets returns every bucket from the first to the last non-empty one, in order
+// Empty buckets in between are included so series can be plotted directly
+func (h *histogram) buckets() ([]models.Bucket, error) {
+	buckets := []models.Bucket{}
+	if h.bucketing == nil || len(h.counts) == 0 {
+		return buckets, nil
+	}
+
+	indexes := make([]int64, 0, len(h.counts))
+	for index := range h.counts {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	first, last := indexes[0], indexes[len(indexes)-1]
+	if last-first >= maxBuckets {
+		return nil, fmt.Errorf("invalid aggregation: more than %d buckets, use a larger interval or bucket_size", maxBuckets)
+	}
+
+	for index := first; index <= last; index++ {
+		from, to := h.bounds(index)
+		bucket := models.Bucket{From: from, To: to, Count: h.counts[index]}
+		if h.statsField != "" {
+			bucket.Stats = numberStats(h.values[index])
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
+
+// numberStats summarizes values, or returns nil if there are none
+func numberStats(values []float64) *models.NumberStats {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	mean := sum / float64(len(sorted))
+
+	variance := 0.0
+	for _, v := range sorted {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(sorted))
+
+	return &models.NumberStats{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		Mean:   mean,
+		StdDev: math.Sqrt(variance),
+		P50:    percentile(sorted, 50),
+		P90:    percentile(sorted, 90),
+		P99:    percentile(sorted, 99),
+	}
+}
+
+// percentile returns the p-th percentile of sorted values, interpolating between the nearest ranks
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// AggregateDocuments counts a collection's documents per bucket and summarizes a number field
+// Either bucketing or statsField may be omitted. Filters, dates and scope select documents
+// as in QueryDocuments
+func (c *CatalogDB) AggregateDocuments(dbID string, collection string, bucketing *Bucketing, statsField string, filters []Filter, dates *TimeRange, scope *ReadScope) (*models.AggregateResponse, error) {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	visibilityClause, visibilityArgs := visibilityFilter("visibility", scope.VisibleLevels())
+	dateClause, dateArgs := timeRangeFilter("", dates)
+	fieldClause, fieldArgs := filterClause("", filters)
+	query := fmt.Sprintf(`
+		SELECT id, created_at, updated_at, data, visibility
+		FROM %s
+		WHERE 1 = 1%s%s%s%s
+	`, QuoteIdentifier(collection), visibilityClause, dateClause, fieldClause, scope.deletedFilter(""))
+
+	args := append(visibilityArgs, dateArgs...)
+	args = append(args, fieldArgs...)
+	budget := c.limits.NewQueryBudget()
+	defer budget.Done()
+
+	rows, err := db.QueryContext(budget.Context(), query, args...)
+	if err != nil {
+		return nil, budget.Err(fmt.Errorf("failed to query documents: %w", err))
+	}
+	defer rows.Close()
+
+	hist := newHistogram(bucketing, statsField)
+	for rows.Next() {
+		if err := budget.Scan(); err != nil {
+			return nil, err
+		}
+
+		var doc models.Document
+		var createdAt, updatedAt int64
+		var stored []byte
+
+		if err := rows.Scan(&doc.ID, &createdAt, &updatedAt, &stored, &doc.Visibility); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+
+		if err := decodeData(stored, &doc.Data); err != nil {
+			return nil, err
+		}
+
+		doc.Collection = collection
+		doc.CreatedAt = time.Unix(createdAt, 0)
+		doc.UpdatedAt = time.Unix(updatedAt, 0)
+
+		if !scope.Allows(&doc) {
+			continue
+		}
+		hist.add(&doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, budget.Err(fmt.Errorf("failed to read documents: %w", err))
+	}
+
+	buckets, err := hist.buckets()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.AggregateResponse{
+		Buckets:    buckets,
+		Missing:    hist.missing,
+		StatsField: statsField,
+	}
+	if bucketing != nil {
+		result.Field = bucketing.Field
+		result.Interval = bucketing.Interval
+		result.BucketSize = bucketing.BucketSize
+	}
+	if statsField != "" {
+		result.Stats = numberStats(hist.all)
+	}
+	return result, nil
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestParseBucketing(t *testing.T) {
+	fields := map[string]models.FieldType{"price": models.FieldTypeNumber, "name": models.FieldTypeString}
+
+	tests := []struct {
+		name       string
+		field      string
+		interval   string
+		bucketSize string
+		want       *Bucketing
+		wantErr    bool
+	}{
+		{name: "date buckets", field: "created_at", interval: "day", want: &Bucketing{Field: "created_at", Interval: "day"}},
+		{name: "numeric buckets", field: "price", bucketSize: "2.5", want: &Bucketing{Field: "price", BucketSize: 2.5}},
+		{name: "no bucketing", want: nil},
+		{name: "interval without field", interval: "day", wantErr: true},
+		{name: "unknown interval", field: "updated_at", interval: "year", wantErr: true},
+		{name: "date with bucket size", field: "created_at", interval: "day", bucketSize: "1", wantErr: true},
+		{name: "string field", field: "name", bucketSize: "1", wantErr: true},
+		{name: "zero bucket size", field: "price", bucketSize: "0", wantErr: true},
+		{name: "numeric with interval", field: "price", interval: "day", bucketSize: "1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBucketing(tt.field, tt.interval, tt.bucketSize, fields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBucketing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseBucketing() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogramNumeric(t *testing.T) {
+	hist := newHistogram(&Bucketing{Field: "price", BucketSize: 10}, "")
+	for _, data := range []map[string]interface{}{
+		{"price": float64(3)},
+		{"price": float64(9.99)},
+		{"price": float64(-1)},
+		{"price": float64(30)},
+		{"price": "n/a"},
+		{},
+	} {
+		hist.add(&models.Document{Data: data})
+	}
+
+	buckets, err := hist.buckets()
+	if err != nil {
+		t.Fatalf("buckets() error = %v", err)
+	}
+	want := []models.Bucket{
+		{From: float64(-10), To: float64(0), Count: 1},
+		{From: float64(0), To: float64(10), Count: 2},
+		{From: float64(10), To: float64(20), Count: 0},
+		{From: float64(20), To: float64(30), Count: 0},
+		{From: float64(30), To: float64(40), Count: 1},
+	}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Errorf("buckets() = %v, want %v", buckets, want)
+	}
+	if hist.missing != 2 {
+		t.Errorf("missing = %d, want 2", hist.missing)
+	}
+}
+
+func TestHistogramDates(t *testing.T) {
+	// 2024-03-06 is a Wednesday
+	created := time.Date(2024, 3, 6, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		interval string
+		from     time.Time
+		to       time.Time
+	}{
+		{IntervalHour, time.Date(2024, 3, 6, 15, 0, 0, 0, time.UTC), time.Date(2024, 3, 6, 16, 0, 0, 0, time.UTC)},
+		{IntervalDay, time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{IntervalWeek, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{IntervalMonth, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.interval, func(t *testing.T) {
+			hist := newHistogram(&Bucketing{Field: "created_at", Interval: tt.interval}, "")
+			hist.add(&models.Document{CreatedAt: created})
+
+			buckets, err := hist.buckets()
+			if err != nil {
+				t.Fatalf("buckets() error = %v", err)
+			}
+			want := []models.Bucket{{From: tt.from, To: tt.to, Count: 1}}
+			if !reflect.DeepEqual(buckets, want) {
+				t.Errorf("buckets() = %v, want %v", buckets, want)
+			}
+		})
+	}
+}
+
+func TestHistogramTooManyBuckets(t *testing.T) {
+	hist := newHistogram(&Bucketing{Field: "price", BucketSize: 1}, "")
+	hist.add(&models.Document{Data: map[string]interface{}{"price": float64(0)}})
+	hist.add(&models.Document{Data: map[string]interface{}{"price": float64(maxBuckets)}})
+
+	if _, err := hist.buckets(); err == nil {
+		t.Error("buckets() should fail when the range exceeds the bucket limit")
+	}
+}
+
+func TestNumberStats(t *testing.T) {
+	if got := numberStats(nil); got != nil {
+		t.Errorf("numberStats(nil) = %+v, want nil", got)
+	}
+
+	values := make([]float64, 0, 101)
+	for v := 100; v >= 0; v-- {
+		values = append(values, float64(v))
+	}
+	got := numberStats(values)
+	want := &models.NumberStats{Count: 101, Min: 0, Max: 100, Mean: 50, P50: 50, P90: 90, P99: 99}
+	want.StdDev = got.StdDev // Checked separately below
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("numberStats() = %+v, want %+v", got, want)
+	}
+	if got.StdDev < 29.15 || got.StdDev > 29.16 {
+		t.Errorf("StdDev = %v, want about 29.155", got.StdDev)
+	}
+	if values[0] != 100 {
+		t.Error("numberStats should not reorder its input")
+	}
+
+	// Percentiles interpolate between neighbouring values
+	if got := numberStats([]float64{10, 20}); got.P50 != 15 || got.P90 != 19 || got.StdDev != 5 {
+		t.Errorf("numberStats([10 20]) = %+v, want p50 15, p90 19, stddev 5", got)
+	}
+}
+
+func TestHistogramStats(t *testing.T) {
+	hist := newHistogram(&Bucketing{Field: "size", BucketSize: 100}, "latency")
+	for _, data := range []map[string]interface{}{
+		{"size": float64(10), "latency": float64(5)},
+		{"size": float64(20), "latency": float64(15)},
+		{"size": float64(150)},
+		{"latency": float64(40)},
+	} {
+		hist.add(&models.Document{Data: data})
+	}
+
+	buckets, err := hist.buckets()
+	if err != nil {
+		t.Fatalf("buckets() error = %v", err)
+	}
+	if len(buckets) != 2 || buckets[0].Stats == nil || buckets[0].Stats.Mean != 10 || buckets[1].Stats != nil {
+		t.Errorf("buckets() = %+v, want stats for the first bucket only", buckets)
+	}
+	if overall := numberStats(hist.all); overall.Count != 3 || overall.Max != 40 {
+		t.Errorf("overall stats = %+v, want 3 values up to 40", overall)
+	}
+}
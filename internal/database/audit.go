@@ -0,0 +1,169 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// Authenticated requests that change a database are recorded in the _audit table of its
+// database file, with the credential that made them and their outcome. Entries are kept
+// as long as the database is, and pages of the log are as large as event log pages.
+
+// auditLogSchema creates a database's audit log; created_at is in Unix milliseconds
+const auditLogSchema = `
+CREATE TABLE IF NOT EXISTS _audit (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	credential TEXT NOT NULL,
+	key_id TEXT NOT NULL,
+	client_addr TEXT NOT NULL,
+	method TEXT NOT NULL,
+	path TEXT NOT NULL,
+	collection TEXT NOT NULL,
+	document_id TEXT NOT NULL,
+	status INTEGER NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_document ON _audit(collection, document_id);
+`
+
+// AuditQuery selects a page of a database's audit log
+type AuditQuery struct {
+	Since      uint64    // Entries recorded after this sequence number
+	SinceTime  time.Time // Or, when set, entries recorded at or after this time
+	Collection string    // Only requests to this collection, when set
+	DocumentID string    // Only requests to this document, when set
+	KeyID      string    // Only requests made with this key or token, when set
+	Limit      int       // Entries per page; 0 selects DefaultHistoryLimit
+}
+
+// RecordAudit appends an entry to its database's audit log
+// Nothing is recorded once the database is deleted
+func (c *CatalogDB) RecordAudit(dbID string, entry models.AuditEntry) error {
+	dbPath, err := c.getDatabasePath(dbID)
+	if err != nil {
+		return err
+	}
+	// Opening a deleted database's file would create it again
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil
+	}
+	db, release, err := c.pool.acquire(dbPath)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	insert := func() error {
+		_, err := db.Exec(`
+			INSERT INTO _audit (credential, key_id, client_addr, method, path, collection, document_id, status, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, entry.Credential, entry.KeyID, entry.ClientAddr, entry.Method, entry.Path,
+			entry.Collection, entry.DocumentID, entry.Status, entry.Timestamp.UnixMilli())
+		return err
+	}
+	err = insert()
+	if err != nil && strings.Contains(err.Error(), "no such table") {
+		// Databases created before the audit log get it with their first entry
+		if _, err := db.Exec(auditLogSchema); err != nil {
+			return fmt.Errorf("failed to create audit log: %w", err)
+		}
+		err = insert()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// AuditLog returns a page of a database's audit log, oldest first
+func (c *CatalogDB) AuditLog(dbID string, q AuditQuery) (*models.AuditLogResponse, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultHistoryLimit
+	}
+	if q.Limit > MaxHistoryLimit {
+		return nil, fmt.Errorf("invalid limit: must be between 1 and %d", MaxHistoryLimit)
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	response := &models.AuditLogResponse{Entries: []models.AuditEntry{}, Next: q.Since}
+
+	// The page ends at the last entry recorded when the read began, so entries recorded
+	// while paging are picked up by the next read
+	var last sql.NullInt64
+	if err := db.QueryRow(`SELECT seq FROM sqlite_sequence WHERE name = '_audit'`).Scan(&last); err != nil {
+		if err == sql.ErrNoRows || strings.Contains(err.Error(), "no such table") {
+			return response, nil // Nothing has been recorded yet
+		}
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if uint64(last.Int64) <= q.Since {
+		return response, nil
+	}
+
+	query := `
+		SELECT seq, credential, key_id, client_addr, method, path, collection, document_id, status, created_at
+		FROM _audit
+		WHERE seq > ? AND seq <= ?`
+	args := []interface{}{q.Since, last.Int64}
+	if !q.SinceTime.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, q.SinceTime.UnixMilli())
+	}
+	if q.Collection != "" {
+		query += ` AND collection = ?`
+		args = append(args, q.Collection)
+	}
+	if q.DocumentID != "" {
+		query += ` AND document_id = ?`
+		args = append(args, q.DocumentID)
+	}
+	if q.KeyID != "" {
+		query += ` AND key_id = ?`
+		args = append(args, q.KeyID)
+	}
+	query += ` ORDER BY seq LIMIT ?`
+	args = append(args, q.Limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditEntry
+		var createdAt int64
+		if err := rows.Scan(&entry.Seq, &entry.Credential, &entry.KeyID, &entry.ClientAddr, &entry.Method, &entry.Path,
+			&entry.Collection, &entry.DocumentID, &entry.Status, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		if len(response.Entries) == q.Limit {
+			response.HasMore = true
+			break
+		}
+		entry.Timestamp = time.UnixMilli(createdAt)
+		response.Entries = append(response.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	// A full page continues from its last entry; otherwise everything up to the last
+	// entry recorded has been seen
+	if response.HasMore {
+		response.Next = response.Entries[len(response.Entries)-1].Seq
+	} else {
+		response.Next = uint64(last.Int64)
+	}
+	return response, nil
+}
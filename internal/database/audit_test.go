@@ -0,0 +1,99 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestAuditLog(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_audit"
+	newTestDatabase(t, c, dbID)
+
+	empty, err := c.AuditLog(dbID, AuditQuery{})
+	if err != nil {
+		t.Fatalf("AuditLog() on an empty log error = %v", err)
+	}
+	if len(empty.Entries) != 0 || empty.Next != 0 || empty.HasMore {
+		t.Errorf("AuditLog() on an empty log = %+v, want no entries from 0", empty)
+	}
+
+	start := time.UnixMilli(time.Now().UnixMilli())
+	entries := []models.AuditEntry{
+		{Credential: models.CredentialWriteKey, KeyID: "3f2a9c", Method: "POST", Path: "/api/databases/db_audit/tasks", Collection: "tasks", Status: 201},
+		{Credential: models.CredentialScopedKey, KeyID: "key_ops", Method: "DELETE", Path: "/api/databases/db_audit/tasks/doc_1", Collection: "tasks", DocumentID: "doc_1", Status: 204},
+		{Credential: models.CredentialReadKey, KeyID: "9b01de", Method: "DELETE", Path: "/api/databases/db_audit/tasks/doc_2", Collection: "tasks", DocumentID: "doc_2", Status: 403},
+		{Credential: models.CredentialToken, KeyID: "tok_1", Method: "PUT", Path: "/api/databases/db_audit/notes/doc_3", Collection: "notes", DocumentID: "doc_3", Status: 200},
+	}
+	for i, entry := range entries {
+		entry.Timestamp = start.Add(time.Duration(i) * time.Second)
+		if err := c.RecordAudit(dbID, entry); err != nil {
+			t.Fatalf("RecordAudit(%d) error = %v", i, err)
+		}
+	}
+
+	first, err := c.AuditLog(dbID, AuditQuery{Limit: 3})
+	if err != nil {
+		t.Fatalf("AuditLog() error = %v", err)
+	}
+	if len(first.Entries) != 3 || !first.HasMore || first.Next != 3 {
+		t.Fatalf("AuditLog(limit 3) = %d entries, has_more %v, next %d; want 3, true, 3", len(first.Entries), first.HasMore, first.Next)
+	}
+	if got := first.Entries[1]; got.Seq != 2 || got.KeyID != "key_ops" || got.DocumentID != "doc_1" || got.Status != 204 || !got.Timestamp.Equal(start.Add(time.Second)) {
+		t.Errorf("AuditLog() entry 2 = %+v, want the scoped key's delete of doc_1", got)
+	}
+	rest, err := c.AuditLog(dbID, AuditQuery{Since: first.Next})
+	if err != nil {
+		t.Fatalf("AuditLog(since) error = %v", err)
+	}
+	if len(rest.Entries) != 1 || rest.HasMore || rest.Next != 4 || rest.Entries[0].Credential != models.CredentialToken {
+		t.Errorf("AuditLog(since %d) = %+v, want the token's update", first.Next, rest)
+	}
+
+	tests := []struct {
+		name string
+		q    AuditQuery
+		want []uint64
+	}{
+		{"collection", AuditQuery{Collection: "tasks"}, []uint64{1, 2, 3}},
+		{"document", AuditQuery{Collection: "tasks", DocumentID: "doc_2"}, []uint64{3}},
+		{"key", AuditQuery{KeyID: "key_ops"}, []uint64{2}},
+		{"since time", AuditQuery{SinceTime: start.Add(2 * time.Second)}, []uint64{3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := c.AuditLog(dbID, tt.q)
+			if err != nil {
+				t.Fatalf("AuditLog() error = %v", err)
+			}
+			var got []uint64
+			for _, entry := range page.Entries {
+				got = append(got, entry.Seq)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("AuditLog(%+v) seqs = %v, want %v", tt.q, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("AuditLog(%+v) seqs = %v, want %v", tt.q, got, tt.want)
+					break
+				}
+			}
+		})
+	}
+
+	if _, err := c.AuditLog(dbID, AuditQuery{Limit: MaxHistoryLimit + 1}); err == nil {
+		t.Error("AuditLog(limit too large) error = nil, want an error")
+	}
+
+	// Requests racing the database's deletion leave nothing behind
+	if err := c.DeleteDatabase(dbID); err != nil {
+		t.Fatalf("DeleteDatabase() error = %v", err)
+	}
+	if err := c.RecordAudit(dbID, entries[0]); err != nil {
+		t.Errorf("RecordAudit() after deletion error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"jsondrop/internal/models"
+)
+
+// loadUnicodeCollation makes new connections load CollationUnicode; set once ICU is detected
+var loadUnicodeCollation bool
+
+// capabilityProbes are the statements that succeed only when a SQLite feature is compiled in
+// FTS5 backs full-text search, JSON1 the generated field columns, ICU the unicode collation,
+// and dbstat the index sizes of collection stats
+var capabilityProbes = []struct {
+	name  string
+	query string
+	set   func(*models.SQLiteCapabilities)
+}{
+	{"fts5", `CREATE VIRTUAL TABLE fts5_probe USING fts5(content)`, func(c *models.SQLiteCapabilities) { c.FTS5 = true }},
+	{"json1", `SELECT json_extract('{"a":1}', '$.a')`, func(c *models.SQLiteCapabilities) { c.JSON1 = true }},
+	{"icu", `SELECT icu_load_collation('root', 'icu_probe')`, func(c *models.SQLiteCapabilities) { c.ICU = true }},
+	{"dbstat", `SELECT COUNT(*) FROM dbstat`, func(c *models.SQLiteCapabilities) { c.DBStat = true }},
+}
+
+// detectCapabilities probes the SQLite library of the driver in use
+// modernc.org/sqlite includes FTS5, JSON1 and dbstat but not ICU; mattn/go-sqlite3 includes
+// JSON1, FTS5 and ICU with the sqlite_fts5 and sqlite_icu tags, and dbstat when built
+// with CGO_CFLAGS=-DSQLITE_ENABLE_DBSTAT_VTAB
+func detectCapabilities() (models.SQLiteCapabilities, error) {
+	var caps models.SQLiteCapabilities
+
+	db, err := sql.Open(sqlDriverName(), ":memory:")
+	if err != nil {
+		return caps, fmt.Errorf("failed to open sqlite: %w", err)
+	}
+	defer db.Close()
+
+	for _, probe := range capabilityProbes {
+		if _, err := db.Exec(probe.query); err == nil {
+			probe.set(&caps)
+		}
+	}
+	return caps, nil
+}
+
+// Capabilities reports the SQLite features found at startup
+func (c *CatalogDB) Capabilities() models.SQLiteCapabilities {
+	return c.capabilities
+}
+
+// SearchEnabled reports whether full-text search is available
+func (c *CatalogDB) SearchEnabled() bool {
+	return c.capabilities.FTS5
+}
+
+// UnicodeCollationEnabled reports whether sorts can use the unicode collation
+func (c *CatalogDB) UnicodeCollationEnabled() bool {
+	return c.capabilities.ICU
+}
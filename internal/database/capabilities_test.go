@@ -0,0 +1,17 @@
+package database
+
+import "testing"
+
+func TestDetectCapabilities(t *testing.T) {
+	caps, err := detectCapabilities()
+	if err != nil {
+		t.Fatalf("detectCapabilities() error = %v", err)
+	}
+	// Every supported driver includes JSON1; FTS5, ICU and dbstat depend on the build
+	if !caps.JSON1 {
+		t.Errorf("detectCapabilities() = %+v, want JSON1", caps)
+	}
+	if CurrentDriver() == DriverModernc && (!caps.FTS5 || !caps.DBStat || caps.ICU) {
+		t.Errorf("detectCapabilities() = %+v, want FTS5 and dbstat without ICU for modernc", caps)
+	}
+}
@@ -6,11 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"jsondrop/internal/models"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // EventBroadcaster is an interface for broadcasting events
@@ -18,16 +18,39 @@ type EventBroadcaster interface {
 	Broadcast(dbID string, event models.ChangeEvent)
 }
 
+// Limits bounds how far each database can grow its schema, and how much work one query
+// may do; zero means unlimited
+type Limits struct {
+	MaxCollections   int           // Schemas per database
+	MaxSchemaFields  int           // Fields per schema
+	MaxDocumentBytes int64         // Document JSON size, checked on import
+	QueryTimeout     time.Duration // Run time of a document query
+	MaxRowsScanned   int           // Rows a document query reads
+}
+
 // CatalogDB manages the catalog database
 type CatalogDB struct {
 	db           *sql.DB
 	dbBaseDir    string
 	defaultQuota int64
+	limits       Limits
 	broadcaster  EventBroadcaster
+	keys         KeyGenerator
+	pool         *dbPool
+	schemas      *schemaCache
+	compression  Compression               // How large documents are stored
+	capabilities models.SQLiteCapabilities // SQLite features of the driver, found at startup
 }
 
 // NewCatalogDB creates a new catalog database connection
-func NewCatalogDB(catalogPath string, dbBaseDir string, defaultQuotaMB int64, broadcaster EventBroadcaster) (*CatalogDB, error) {
+// Database files are kept open between operations within the pool's bounds
+// Documents larger than the compression threshold are stored compressed
+// A nil keys generator defaults to RandomKeys
+func NewCatalogDB(catalogPath string, dbBaseDir string, defaultQuotaMB int64, limits Limits, pool PoolConfig, compression Compression, broadcaster EventBroadcaster, keys KeyGenerator) (*CatalogDB, error) {
+	if keys == nil {
+		keys = RandomKeys{}
+	}
+
 	// Ensure the directory exists
 	dir := filepath.Dir(catalogPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -39,7 +62,17 @@ func NewCatalogDB(catalogPath string, dbBaseDir string, defaultQuotaMB int64, br
 		return nil, fmt.Errorf("failed to create database base directory: %w", err)
 	}
 
-	db, err := sql.Open("sqlite3", catalogPath)
+	// Collection tables can't be created without JSON1; the other features are optional
+	caps, err := detectCapabilities()
+	if err != nil {
+		return nil, err
+	}
+	if !caps.JSON1 {
+		return nil, fmt.Errorf("sqlite driver %s lacks the JSON1 extension, which collection tables require", CurrentDriver())
+	}
+	loadUnicodeCollation = caps.ICU
+
+	db, err := sql.Open(sqlDriverName(), withDriverParams(catalogPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open catalog database: %w", err)
 	}
@@ -48,11 +81,23 @@ func NewCatalogDB(catalogPath string, dbBaseDir string, defaultQuotaMB int64, br
 		db:           db,
 		dbBaseDir:    dbBaseDir,
 		defaultQuota: defaultQuotaMB * 1024 * 1024, // Convert MB to bytes
-		broadcaster:  broadcaster,
+		limits:       limits,
+		keys:         keys,
+		pool:         newDBPool(pool),
+		schemas:      newSchemaCache(),
+		compression:  compression,
+		capabilities: caps,
 	}
+	// Events are written to their database's log before reaching the broadcaster
+	catalog.broadcaster = &eventLog{catalog: catalog, next: broadcaster}
 
 	if err := catalog.initSchema(); err != nil {
-		db.Close()
+		catalog.Close()
+		return nil, err
+	}
+
+	if err := catalog.migrateCollectionTables(); err != nil {
+		catalog.Close()
 		return nil, err
 	}
 
@@ -84,6 +129,113 @@ func (c *CatalogDB) initSchema() error {
 		PRIMARY KEY (database_id, name),
 		FOREIGN KEY (database_id) REFERENCES databases(id) ON DELETE CASCADE
 	);
+
+	CREATE TABLE IF NOT EXISTS collection_indexes (
+		database_id TEXT NOT NULL,
+		collection TEXT NOT NULL,
+		name TEXT NOT NULL,
+		fields TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (database_id, collection, name),
+		FOREIGN KEY (database_id) REFERENCES databases(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS collection_mirrors (
+		id TEXT PRIMARY KEY,
+		database_id TEXT NOT NULL,
+		collection TEXT NOT NULL,
+		target_database_id TEXT NOT NULL,
+		target_collection TEXT NOT NULL,
+		fields TEXT NOT NULL,
+		filter TEXT NOT NULL DEFAULT '',
+		visibility TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		UNIQUE (target_database_id, target_collection)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_mirror_source ON collection_mirrors(database_id, collection);
+
+	CREATE TABLE IF NOT EXISTS erasure_receipts (
+		id TEXT PRIMARY KEY,
+		database_id TEXT NOT NULL,
+		field TEXT NOT NULL,
+		value_sha256 TEXT NOT NULL,
+		collections TEXT NOT NULL,
+		documents INTEGER NOT NULL,
+		bytes INTEGER NOT NULL,
+		erased_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_erasure_database ON erasure_receipts(database_id);
+
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id TEXT PRIMARY KEY,
+		database_id TEXT NOT NULL,
+		url TEXT NOT NULL,
+		collection TEXT NOT NULL DEFAULT '',
+		events TEXT NOT NULL DEFAULT '[]',
+		secret TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webhook_database ON webhooks(database_id);
+
+	CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+		id TEXT NOT NULL,
+		database_id TEXT NOT NULL,
+		webhook_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		event TEXT NOT NULL,
+		attempts INTEGER NOT NULL,
+		last_error TEXT NOT NULL,
+		failed_at INTEGER NOT NULL,
+		PRIMARY KEY (webhook_id, id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webhook_dead_letter_database ON webhook_dead_letters(database_id);
+
+	CREATE TABLE IF NOT EXISTS scoped_keys (
+		id TEXT PRIMARY KEY,
+		database_id TEXT NOT NULL,
+		key TEXT UNIQUE NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_scoped_key_database ON scoped_keys(database_id);
+
+	CREATE TABLE IF NOT EXISTS scoped_key_collections (
+		key_id TEXT NOT NULL,
+		database_id TEXT NOT NULL,
+		collection TEXT NOT NULL,
+		PRIMARY KEY (key_id, collection)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_scoped_key_collection ON scoped_key_collections(database_id, collection);
+
+	CREATE TABLE IF NOT EXISTS database_owners (
+		database_id TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (database_id, subject)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_database_owner_subject ON database_owners(subject);
+
+	CREATE TABLE IF NOT EXISTS share_links (
+		id TEXT PRIMARY KEY,
+		database_id TEXT NOT NULL,
+		token_hash TEXT UNIQUE NOT NULL,
+		collection TEXT NOT NULL,
+		document_id TEXT NOT NULL,
+		query TEXT NOT NULL,
+		single_use INTEGER NOT NULL,
+		created_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL,
+		used_at INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_share_link_database ON share_links(database_id);
 	`
 
 	_, err := c.db.Exec(schema)
@@ -91,26 +243,193 @@ func (c *CatalogDB) initSchema() error {
 		return fmt.Errorf("failed to initialize catalog schema: %w", err)
 	}
 
+	// Columns added after the initial release
+	if err := ensureColumn(c.db, "schemas", "read_filter", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("failed to migrate catalog schema: %w", err)
+	}
+	if err := ensureColumn(c.db, "schemas", "retention_days", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("failed to migrate catalog schema: %w", err)
+	}
+	if err := ensureColumn(c.db, "schemas", "deprecated_fields", "TEXT NOT NULL DEFAULT '{}'"); err != nil {
+		return fmt.Errorf("failed to migrate catalog schema: %w", err)
+	}
+	if err := ensureColumn(c.db, "schemas", "masked_fields", "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+		return fmt.Errorf("failed to migrate catalog schema: %w", err)
+	}
+	for _, column := range []string{"write_key", "read_key"} {
+		if err := ensureColumn(c.db, "databases", "previous_"+column, "TEXT"); err != nil {
+			return fmt.Errorf("failed to migrate catalog schema: %w", err)
+		}
+		if err := ensureColumn(c.db, "databases", "previous_"+column+"_expires", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return fmt.Errorf("failed to migrate catalog schema: %w", err)
+		}
+	}
+	for _, column := range []string{"allowed_networks", "cors_origins"} {
+		if err := ensureColumn(c.db, "databases", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return fmt.Errorf("failed to migrate catalog schema: %w", err)
+		}
+	}
+	if err := ensureColumn(c.db, "databases", "protected", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("failed to migrate catalog schema: %w", err)
+	}
+	if err := ensureColumn(c.db, "databases", "key_generation", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("failed to migrate catalog schema: %w", err)
+	}
+	if err := ensureColumn(c.db, "scoped_keys", "expires_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("failed to migrate catalog schema: %w", err)
+	}
+	if _, err := c.db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_previous_write_key ON databases(previous_write_key);
+		CREATE INDEX IF NOT EXISTS idx_previous_read_key ON databases(previous_read_key);
+	`); err != nil {
+		return fmt.Errorf("failed to migrate catalog schema: %w", err)
+	}
+
+	return nil
+}
+
+// migrateCollectionTables brings collection tables created by older versions up to date
+func (c *CatalogDB) migrateCollectionTables() error {
+	schemas, err := c.ListAllSchemas()
+	if err != nil {
+		return fmt.Errorf("failed to list schemas for migration: %w", err)
+	}
+
+	for _, schema := range schemas {
+		dbPath, err := c.getDatabasePath(schema.DatabaseID)
+		if err != nil {
+			continue // Not a usable database ID; there is no file to migrate
+		}
+		if _, err := os.Stat(dbPath); err != nil {
+			continue // Database file is gone; expiry will clean up the catalog entry
+		}
+		if err := c.migrateCollectionTable(dbPath, schema); err != nil {
+			return fmt.Errorf("failed to migrate collection %s in %s: %w", schema.Name, schema.DatabaseID, err)
+		}
+	}
+
+	return nil
+}
+
+// migrateCollectionTable adds columns and tables introduced after a collection table was created
+func (c *CatalogDB) migrateCollectionTable(dbPath string, schema *models.Schema) error {
+	db, release, err := c.pool.acquire(dbPath)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := ensureColumn(db, schema.Name, "visibility", "TEXT NOT NULL DEFAULT 'read_key'"); err != nil {
+		return err
+	}
+
+	if err := ensureColumn(db, schema.Name, "deleted_at", "INTEGER"); err != nil {
+		return err
+	}
+
+	if err := ensureColumn(db, schema.Name, "revision", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+
+	if err := ensureTimestampIndexes(db, schema.Name); err != nil {
+		return err
+	}
+
+	indexes, err := c.ListIndexes(schema.DatabaseID, schema.Name)
+	if err != nil {
+		return err
+	}
+	if err := upgradeFieldColumns(db, schema.Name, schema.Fields, indexes); err != nil {
+		return err
+	}
+
+	if err := ensureFieldIndexes(db, schema.Name, schema.Fields); err != nil {
+		return err
+	}
+
+	if c.capabilities.FTS5 {
+		if err := ensureSearchIndex(db, schema.Name, schema.Fields); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queryExecer is satisfied by both *sql.DB and *sql.Tx
+type queryExecer interface {
+	execer
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ensureColumn adds a column to a table if it does not already exist
+func ensureColumn(db queryExecer, table string, column string, definition string) error {
+	quotedTable := QuoteIdentifier(table)
+	// table_xinfo also lists generated columns, which table_info hides
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_xinfo(%s)", quotedTable))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk, hidden int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk, &hidden); err != nil {
+			return fmt.Errorf("failed to inspect table: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect table: %w", err)
+	}
+	rows.Close()
+
+	alterSQL := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quotedTable, QuoteIdentifier(column), definition)
+	if _, err := db.Exec(alterSQL); err != nil {
+		return fmt.Errorf("failed to add column %s: %w", column, err)
+	}
+
 	return nil
 }
 
 // CreateDatabase creates a new database entry in the catalog
 func (c *CatalogDB) CreateDatabase() (*models.CreateDatabaseResponse, error) {
 	// Generate unique identifiers
-	dbID, err := GenerateDatabaseID()
+	dbID, err := c.keys.DatabaseID()
+	if err != nil {
+		return nil, err
+	}
+
+	writeKey, err := c.keys.WriteKey()
 	if err != nil {
 		return nil, err
 	}
 
-	writeKey, err := GenerateWriteKey()
+	readKey, err := c.keys.ReadKey()
 	if err != nil {
 		return nil, err
 	}
 
-	readKey, err := GenerateReadKey()
+	return c.CreateDatabaseWithKeys(dbID, writeKey, readKey, c.defaultQuota)
+}
+
+// CreateDatabaseWithKeys creates a database with caller-chosen identifiers
+// Used for fixtures and restores where IDs must be reproducible
+func (c *CatalogDB) CreateDatabaseWithKeys(dbID string, writeKey string, readKey string, quotaLimit int64) (*models.CreateDatabaseResponse, error) {
+	if !strings.HasPrefix(dbID, "db_") || !strings.HasPrefix(writeKey, "wk_") || !strings.HasPrefix(readKey, "rk_") {
+		return nil, fmt.Errorf("invalid database identifiers: expected db_, wk_ and rk_ prefixes")
+	}
+	dbPath, err := c.getDatabasePath(dbID)
 	if err != nil {
 		return nil, err
 	}
+	if quotaLimit <= 0 {
+		quotaLimit = c.defaultQuota
+	}
 
 	now := time.Now().Unix()
 
@@ -120,13 +439,12 @@ func (c *CatalogDB) CreateDatabase() (*models.CreateDatabaseResponse, error) {
 		VALUES (?, ?, ?, ?, ?, 0, ?)
 	`
 
-	_, err = c.db.Exec(query, dbID, writeKey, readKey, now, now, c.defaultQuota)
+	_, err = c.db.Exec(query, dbID, writeKey, readKey, now, now, quotaLimit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create database entry: %w", err)
 	}
 
 	// Create the SQLite database file
-	dbPath := c.getDatabasePath(dbID)
 	if err := c.initDatabaseFile(dbPath); err != nil {
 		// Rollback: delete from catalog
 		c.db.Exec("DELETE FROM databases WHERE id = ?", dbID)
@@ -142,11 +460,11 @@ func (c *CatalogDB) CreateDatabase() (*models.CreateDatabaseResponse, error) {
 
 // initDatabaseFile creates a new SQLite database file for a user database
 func (c *CatalogDB) initDatabaseFile(dbPath string) error {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, release, err := c.pool.acquire(dbPath)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer release()
 
 	// Create collections table to track all collections in this database
 	schema := `
@@ -154,7 +472,7 @@ func (c *CatalogDB) initDatabaseFile(dbPath string) error {
 		name TEXT PRIMARY KEY,
 		created_at INTEGER NOT NULL
 	);
-	`
+	` + eventLogSchema + auditLogSchema
 
 	_, err = db.Exec(schema)
 	if err != nil {
@@ -165,8 +483,28 @@ func (c *CatalogDB) initDatabaseFile(dbPath string) error {
 }
 
 // getDatabasePath returns the file path for a database
-func (c *CatalogDB) getDatabasePath(dbID string) string {
-	return filepath.Join(c.dbBaseDir, dbID+".db")
+// The ID is validated and the result checked to lie directly inside the data directory,
+// so a malformed ID can never address another file
+func (c *CatalogDB) getDatabasePath(dbID string) (string, error) {
+	if err := ValidateDatabaseID(dbID); err != nil {
+		return "", err
+	}
+
+	base := filepath.Clean(c.dbBaseDir)
+	dbPath := filepath.Clean(filepath.Join(base, dbID+".db"))
+	if filepath.Dir(dbPath) != base {
+		return "", fmt.Errorf("invalid database ID: %s is outside the data directory", dbID)
+	}
+	return dbPath, nil
+}
+
+// openDatabase returns the pooled handle for a database file and a function to release it
+func (c *CatalogDB) openDatabase(dbID string) (*sql.DB, func(), error) {
+	dbPath, err := c.getDatabasePath(dbID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.pool.acquire(dbPath)
 }
 
 // GetDatabaseByWriteKey retrieves a database by its write key
@@ -179,18 +517,31 @@ func (c *CatalogDB) GetDatabaseByReadKey(readKey string) (*models.Database, erro
 	return c.getDatabaseByKey("read_key", readKey)
 }
 
+// GetDatabaseByID retrieves a database by its ID
+func (c *CatalogDB) GetDatabaseByID(dbID string) (*models.Database, error) {
+	return c.getDatabaseByKey("id", dbID)
+}
+
 // getDatabaseByKey is a helper to retrieve database by any key field
+// Replaced write and read keys match until their grace period ends
 func (c *CatalogDB) getDatabaseByKey(keyField, keyValue string) (*models.Database, error) {
+	where := keyField + " = ?"
+	args := []interface{}{keyValue}
+	if keyField != "id" {
+		where = fmt.Sprintf("%s OR (previous_%s = ? AND previous_%s_expires > ?)", where, keyField, keyField)
+		args = append(args, keyValue, time.Now().Unix())
+	}
 	query := fmt.Sprintf(`
-		SELECT id, write_key, read_key, created_at, last_accessed, quota_used, quota_limit
+		SELECT id, write_key, read_key, created_at, last_accessed, quota_used, quota_limit, allowed_networks, cors_origins, protected, key_generation
 		FROM databases
-		WHERE %s = ?
-	`, keyField)
+		WHERE %s
+	`, where)
 
 	var db models.Database
 	var createdAt, lastAccessed int64
+	var networks, origins string
 
-	err := c.db.QueryRow(query, keyValue).Scan(
+	err := c.db.QueryRow(query, args...).Scan(
 		&db.ID,
 		&db.WriteKey,
 		&db.ReadKey,
@@ -198,6 +549,10 @@ func (c *CatalogDB) getDatabaseByKey(keyField, keyValue string) (*models.Databas
 		&lastAccessed,
 		&db.QuotaUsed,
 		&db.QuotaLimit,
+		&networks,
+		&origins,
+		&db.Protected,
+		&db.KeyGeneration,
 	)
 
 	if err == sql.ErrNoRows {
@@ -209,6 +564,8 @@ func (c *CatalogDB) getDatabaseByKey(keyField, keyValue string) (*models.Databas
 
 	db.CreatedAt = time.Unix(createdAt, 0)
 	db.LastAccessed = time.Unix(lastAccessed, 0)
+	db.AllowedNetworks = splitList(networks)
+	db.CORSOrigins = splitList(origins)
 
 	return &db, nil
 }
@@ -233,6 +590,163 @@ func (c *CatalogDB) UpdateQuotaUsed(dbID string, quotaUsed int64) error {
 	return nil
 }
 
+// RotateKeys replaces a database's chosen keys with new ones
+// With a grace period the old keys keep working until it ends; keys replaced by an
+// earlier rotation stop working at once. A new write key also drops the database's
+// console owners, who claimed it with the old one
+func (c *CatalogDB) RotateKeys(dbID string, write bool, read bool, grace time.Duration) (*models.RotateKeysResponse, error) {
+	writeKey, readKey, err := RotatedKeys(c.keys, write, read)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.RotateKeysResponse{DatabaseID: dbID, WriteKey: writeKey, ReadKey: readKey}
+	var expires int64
+	if grace > 0 {
+		expiresAt := time.Now().Add(grace)
+		resp.OldKeysExpire = &expiresAt
+		expires = expiresAt.Unix()
+	}
+
+	var sets []string
+	var args []interface{}
+	for column, key := range map[string]string{"write_key": writeKey, "read_key": readKey} {
+		if key == "" {
+			continue
+		}
+		if grace > 0 {
+			sets = append(sets, fmt.Sprintf("previous_%s = %s, previous_%s_expires = ?", column, column, column))
+			args = append(args, expires)
+		} else {
+			sets = append(sets, fmt.Sprintf("previous_%s = NULL, previous_%s_expires = 0", column, column))
+		}
+		sets = append(sets, column+" = ?")
+		args = append(args, key)
+	}
+	// Access tokens minted before the rotation stop working at once
+	sets = append(sets, "key_generation = key_generation + 1")
+	query := fmt.Sprintf(`UPDATE databases SET %s WHERE id = ?`, strings.Join(sets, ", "))
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate keys: %w", err)
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(query, append(args, dbID)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate keys: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("database not found")
+	}
+	if writeKey != "" {
+		if _, err := tx.Exec(`DELETE FROM database_owners WHERE database_id = ?`, dbID); err != nil {
+			return nil, fmt.Errorf("failed to rotate keys: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to rotate keys: %w", err)
+	}
+	return resp, nil
+}
+
+// SetAllowedNetworks replaces the IP allowlist of a database, normalized by
+// NormalizeNetworks; an empty list allows every address
+func (c *CatalogDB) SetAllowedNetworks(dbID string, networks []string) error {
+	result, err := c.db.Exec(`UPDATE databases SET allowed_networks = ? WHERE id = ?`, joinList(networks), dbID)
+	if err != nil {
+		return fmt.Errorf("failed to set allowed networks: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("database not found")
+	}
+	return nil
+}
+
+// SetProtected sets whether deleting a database or its schemas needs confirmation
+func (c *CatalogDB) SetProtected(dbID string, protected bool) error {
+	result, err := c.db.Exec(`UPDATE databases SET protected = ? WHERE id = ?`, protected, dbID)
+	if err != nil {
+		return fmt.Errorf("failed to set protection: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("database not found")
+	}
+	return nil
+}
+
+// SetCORSOrigins replaces the browser origins allowed to call a database's routes,
+// normalized by NormalizeOrigins; an empty list leaves only CORS_ORIGINS
+func (c *CatalogDB) SetCORSOrigins(dbID string, origins []string) error {
+	result, err := c.db.Exec(`UPDATE databases SET cors_origins = ? WHERE id = ?`, joinList(origins), dbID)
+	if err != nil {
+		return fmt.Errorf("failed to set CORS origins: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("database not found")
+	}
+	return nil
+}
+
+// listDatabaseIDs returns the IDs of every database in the catalog
+func (c *CatalogDB) listDatabaseIDs() ([]string, error) {
+	rows, err := c.db.Query(`SELECT id FROM databases ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// CountDatabases returns how many databases the catalog holds
+func (c *CatalogDB) CountDatabases() (int, error) {
+	var count int
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM databases`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count databases: %w", err)
+	}
+	return count, nil
+}
+
+// ListDatabases returns every database in the catalog, ordered by ID
+func (c *CatalogDB) ListDatabases() ([]*models.Database, error) {
+	rows, err := c.db.Query(`
+		SELECT id, write_key, read_key, created_at, last_accessed, quota_used, quota_limit, allowed_networks, cors_origins, protected, key_generation
+		FROM databases
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	defer rows.Close()
+
+	databases := []*models.Database{}
+	for rows.Next() {
+		var db models.Database
+		var createdAt, lastAccessed int64
+		var networks, origins string
+		if err := rows.Scan(&db.ID, &db.WriteKey, &db.ReadKey, &createdAt, &lastAccessed, &db.QuotaUsed, &db.QuotaLimit, &networks, &origins, &db.Protected, &db.KeyGeneration); err != nil {
+			return nil, fmt.Errorf("failed to scan database: %w", err)
+		}
+		db.CreatedAt = time.Unix(createdAt, 0)
+		db.LastAccessed = time.Unix(lastAccessed, 0)
+		db.AllowedNetworks = splitList(networks)
+		db.CORSOrigins = splitList(origins)
+		databases = append(databases, &db)
+	}
+
+	return databases, rows.Err()
+}
+
 // GetExpiredDatabases returns databases that haven't been accessed in the specified number of days
 func (c *CatalogDB) GetExpiredDatabases(expiryDays int) ([]string, error) {
 	cutoff := time.Now().AddDate(0, 0, -expiryDays).Unix()
@@ -258,13 +772,51 @@ func (c *CatalogDB) GetExpiredDatabases(expiryDays int) ([]string, error) {
 
 // DeleteDatabase removes a database from the catalog and deletes its file
 func (c *CatalogDB) DeleteDatabase(dbID string) error {
-	// Delete the database file
-	dbPath := c.getDatabasePath(dbID)
-	if err := os.Remove(dbPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to delete database file: %w", err)
+	defer c.schemas.invalidateDatabase(dbID)
+
+	// Delete the database file; an invalid ID never had one, so only the catalog entry is removed
+	if dbPath, err := c.getDatabasePath(dbID); err == nil {
+		c.pool.remove(dbPath)
+		if err := os.Remove(dbPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete database file: %w", err)
+		}
 	}
 
-	// Delete from catalog (cascade will delete schemas)
+	// Delete schemas explicitly; SQLite doesn't enforce the cascade unless
+	// foreign keys are enabled on the connection
+	if _, err := c.db.Exec(`DELETE FROM schemas WHERE database_id = ?`, dbID); err != nil {
+		return fmt.Errorf("failed to delete schemas from catalog: %w", err)
+	}
+	if _, err := c.db.Exec(`DELETE FROM collection_indexes WHERE database_id = ?`, dbID); err != nil {
+		return fmt.Errorf("failed to delete indexes from catalog: %w", err)
+	}
+	mirrorQuery := `DELETE FROM collection_mirrors WHERE database_id = ? OR target_database_id = ?`
+	if _, err := c.db.Exec(mirrorQuery, dbID, dbID); err != nil {
+		return fmt.Errorf("failed to delete mirrors from catalog: %w", err)
+	}
+	if _, err := c.db.Exec(`DELETE FROM erasure_receipts WHERE database_id = ?`, dbID); err != nil {
+		return fmt.Errorf("failed to delete erasure receipts from catalog: %w", err)
+	}
+	if _, err := c.db.Exec(`DELETE FROM webhooks WHERE database_id = ?`, dbID); err != nil {
+		return fmt.Errorf("failed to delete webhooks from catalog: %w", err)
+	}
+	if _, err := c.db.Exec(`DELETE FROM webhook_dead_letters WHERE database_id = ?`, dbID); err != nil {
+		return fmt.Errorf("failed to delete webhook dead letters from catalog: %w", err)
+	}
+	if _, err := c.db.Exec(`DELETE FROM scoped_key_collections WHERE database_id = ?`, dbID); err != nil {
+		return fmt.Errorf("failed to delete scoped keys from catalog: %w", err)
+	}
+	if _, err := c.db.Exec(`DELETE FROM scoped_keys WHERE database_id = ?`, dbID); err != nil {
+		return fmt.Errorf("failed to delete scoped keys from catalog: %w", err)
+	}
+	if _, err := c.db.Exec(`DELETE FROM database_owners WHERE database_id = ?`, dbID); err != nil {
+		return fmt.Errorf("failed to delete owners from catalog: %w", err)
+	}
+	if _, err := c.db.Exec(`DELETE FROM share_links WHERE database_id = ?`, dbID); err != nil {
+		return fmt.Errorf("failed to delete share links from catalog: %w", err)
+	}
+
+	// Delete from catalog
 	query := `DELETE FROM databases WHERE id = ?`
 	_, err := c.db.Exec(query, dbID)
 	if err != nil {
@@ -274,29 +826,25 @@ func (c *CatalogDB) DeleteDatabase(dbID string) error {
 	return nil
 }
 
-// CreateSchema creates a new schema for a collection
-func (c *CatalogDB) CreateSchema(dbID string, name string, fields map[string]models.FieldType) (*models.Schema, error) {
-	// Validate collection name to prevent SQL injection
-	if err := ValidateIdentifier(name); err != nil {
-		return nil, fmt.Errorf("invalid schema name: %w", err)
+// CreateSchema creates a new schema for a collection, with masked fields hidden from
+// read keys from the start
+func (c *CatalogDB) CreateSchema(dbID string, name string, fields map[string]models.FieldType, masked []string) (*models.Schema, error) {
+	if err := ValidateSchema(name, fields); err != nil {
+		return nil, err
 	}
-
-	// Validate fields
-	for fieldName, fieldType := range fields {
-		if fieldName == "" {
-			return nil, fmt.Errorf("field name cannot be empty")
-		}
-		// Validate field names to prevent SQL injection
-		if err := ValidateIdentifier(fieldName); err != nil {
-			return nil, fmt.Errorf("invalid field name %s: %w", fieldName, err)
-		}
-		if !fieldType.IsValid() {
-			return nil, fmt.Errorf("invalid field type for %s: %s", fieldName, fieldType)
+	for _, field := range masked {
+		if _, exists := fields[field]; !exists {
+			return nil, fmt.Errorf("field not found: %s", field)
 		}
 	}
+	if len(masked) > 0 {
+		masked = slices.Clone(masked)
+		slices.Sort(masked)
+		masked = slices.Compact(masked)
+	}
 
-	if len(fields) == 0 {
-		return nil, fmt.Errorf("schema must have at least one field")
+	if err := c.checkSchemaLimits(dbID, len(fields)); err != nil {
+		return nil, err
 	}
 
 	// Marshal fields to JSON
@@ -309,17 +857,21 @@ func (c *CatalogDB) CreateSchema(dbID string, name string, fields map[string]mod
 
 	// Insert into catalog
 	query := `
-		INSERT INTO schemas (database_id, name, fields, created_at)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO schemas (database_id, name, fields, masked_fields, created_at)
+		VALUES (?, ?, ?, ?, ?)
 	`
 
-	_, err = c.db.Exec(query, dbID, name, string(fieldsJSON), now)
+	_, err = c.db.Exec(query, dbID, name, string(fieldsJSON), marshalMasked(masked), now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create schema: %w", err)
 	}
+	defer c.schemas.invalidate(dbID, name)
 
 	// Create the table in the database file
-	dbPath := c.getDatabasePath(dbID)
+	dbPath, err := c.getDatabasePath(dbID)
+	if err != nil {
+		return nil, err
+	}
 	if err := c.createCollectionTable(dbPath, name, fields); err != nil {
 		// Rollback: delete from catalog
 		c.db.Exec("DELETE FROM schemas WHERE database_id = ? AND name = ?", dbID, name)
@@ -330,6 +882,7 @@ func (c *CatalogDB) CreateSchema(dbID string, name string, fields map[string]mod
 		DatabaseID: dbID,
 		Name:       name,
 		Fields:     fields,
+		Masked:     masked,
 		CreatedAt:  time.Unix(now, 0),
 	}
 
@@ -352,13 +905,98 @@ func (c *CatalogDB) CreateSchema(dbID string, name string, fields map[string]mod
 	return schema, nil
 }
 
+// ValidateSchema checks the name and fields of a new schema
+func ValidateSchema(name string, fields map[string]models.FieldType) error {
+	if err := ValidateSchemaName(name); err != nil {
+		return err
+	}
+
+	// Validate fields
+	for fieldName, fieldType := range fields {
+		if fieldName == "" {
+			return fmt.Errorf("field name cannot be empty")
+		}
+		// Validate field names to prevent SQL injection
+		if err := ValidateIdentifier(fieldName); err != nil {
+			return fmt.Errorf("invalid field name %s: %w", fieldName, err)
+		}
+		if !fieldType.IsValid() {
+			return fmt.Errorf("invalid field type for %s: %s", fieldName, fieldType)
+		}
+	}
+
+	if len(fields) == 0 {
+		return fmt.Errorf("schema must have at least one field")
+	}
+
+	return nil
+}
+
+// ValidateSchemaName checks that a name can be used for a collection table
+func ValidateSchemaName(name string) error {
+	// Validate collection name to prevent SQL injection
+	if err := ValidateIdentifier(name); err != nil {
+		return fmt.Errorf("invalid schema name: %w", err)
+	}
+
+	// Names starting with an underscore are reserved for internal tables
+	if strings.HasPrefix(name, "_") {
+		return fmt.Errorf("invalid schema name: names starting with underscore are reserved")
+	}
+
+	return nil
+}
+
+// Limits returns the configured schema limits
+func (c *CatalogDB) Limits() Limits {
+	return c.limits
+}
+
+// checkSchemaLimits verifies a new schema stays within the field and collection limits
+// These are soft limits: concurrent schema creation can briefly exceed the collection count
+func (c *CatalogDB) checkSchemaLimits(dbID string, fieldCount int) error {
+	if err := c.limits.CheckFields(fieldCount); err != nil {
+		return err
+	}
+
+	if c.limits.MaxCollections > 0 {
+		var count int
+		if err := c.db.QueryRow(`SELECT COUNT(*) FROM schemas WHERE database_id = ?`, dbID).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count collections: %w", err)
+		}
+		if err := c.limits.CheckCollections(count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckFields verifies a schema with fieldCount fields stays within the field limit
+func (l Limits) CheckFields(fieldCount int) error {
+	if l.MaxSchemaFields > 0 && fieldCount > l.MaxSchemaFields {
+		return fmt.Errorf("field limit exceeded: schema has %d fields, maximum is %d",
+			fieldCount, l.MaxSchemaFields)
+	}
+	return nil
+}
+
+// CheckCollections verifies a database with count collections has room for another
+func (l Limits) CheckCollections(count int) error {
+	if l.MaxCollections > 0 && count >= l.MaxCollections {
+		return fmt.Errorf("collection limit exceeded: database has %d collections, maximum is %d",
+			count, l.MaxCollections)
+	}
+	return nil
+}
+
 // createCollectionTable creates a table in a user's database file
 func (c *CatalogDB) createCollectionTable(dbPath string, collectionName string, fields map[string]models.FieldType) error {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, release, err := c.pool.acquire(dbPath)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer release()
 
 	// Quote the table name to prevent SQL injection
 	quotedName := QuoteIdentifier(collectionName)
@@ -368,13 +1006,34 @@ func (c *CatalogDB) createCollectionTable(dbPath string, collectionName string,
 	createSQL += "id TEXT PRIMARY KEY, "
 	createSQL += "created_at INTEGER NOT NULL, "
 	createSQL += "updated_at INTEGER NOT NULL, "
-	createSQL += "data TEXT NOT NULL" // Store entire JSON document
+	createSQL += "data TEXT NOT NULL, " // Store entire JSON document
+	createSQL += "visibility TEXT NOT NULL DEFAULT 'read_key', "
+	createSQL += "revision INTEGER NOT NULL DEFAULT 1, " // Incremented by every update
+	createSQL += "deleted_at INTEGER"                    // Set while soft-deleted
+	for _, field := range sortedFields(fields) {
+		// Mirror each field into an indexed column so filters don't parse every document
+		createSQL += fmt.Sprintf(", %s %s", QuoteIdentifier(fieldColumnName(field)), fieldColumnDefinition(field, storedColumn))
+	}
 	createSQL += ")"
 
 	if _, err := db.Exec(createSQL); err != nil {
 		return fmt.Errorf("failed to create table: %w", err)
 	}
 
+	if err := ensureTimestampIndexes(db, collectionName); err != nil {
+		return err
+	}
+
+	if err := ensureFieldIndexes(db, collectionName, fields); err != nil {
+		return err
+	}
+
+	if c.capabilities.FTS5 {
+		if err := ensureSearchIndex(db, collectionName, fields); err != nil {
+			return err
+		}
+	}
+
 	// Register collection (using parameterized query - safe)
 	_, err = db.Exec(
 		"INSERT OR IGNORE INTO _collections (name, created_at) VALUES (?, ?)",
@@ -389,21 +1048,31 @@ func (c *CatalogDB) createCollectionTable(dbPath string, collectionName string,
 }
 
 // GetSchema retrieves a schema by database ID and name
+// Schemas are served from memory after the first read
 func (c *CatalogDB) GetSchema(dbID string, name string) (*models.Schema, error) {
+	cached, generation := c.schemas.get(dbID, name)
+	if cached != nil {
+		return cached, nil
+	}
+
 	query := `
-		SELECT database_id, name, fields, created_at
+		SELECT database_id, name, fields, read_filter, retention_days, deprecated_fields, masked_fields, created_at
 		FROM schemas
 		WHERE database_id = ? AND name = ?
 	`
 
 	var schema models.Schema
-	var fieldsJSON string
+	var fieldsJSON, deprecatedJSON, maskedJSON string
 	var createdAt int64
 
 	err := c.db.QueryRow(query, dbID, name).Scan(
 		&schema.DatabaseID,
 		&schema.Name,
 		&fieldsJSON,
+		&schema.ReadFilter,
+		&schema.RetentionDays,
+		&deprecatedJSON,
+		&maskedJSON,
 		&createdAt,
 	)
 
@@ -418,12 +1087,89 @@ func (c *CatalogDB) GetSchema(dbID string, name string) (*models.Schema, error)
 	if err := json.Unmarshal([]byte(fieldsJSON), &schema.Fields); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal fields: %w", err)
 	}
+	if err := unmarshalDeprecated(deprecatedJSON, &schema); err != nil {
+		return nil, err
+	}
+	if err := unmarshalMasked(maskedJSON, &schema); err != nil {
+		return nil, err
+	}
 
 	schema.CreatedAt = time.Unix(createdAt, 0)
 
+	c.schemas.put(&schema, generation)
 	return &schema, nil
 }
 
+// ListSchemas returns the schemas of a database, ordered by name
+func (c *CatalogDB) ListSchemas(dbID string) ([]*models.Schema, error) {
+	return c.querySchemas(`WHERE database_id = ?`, dbID)
+}
+
+// ListAllSchemas returns the schemas of every database, ordered by database ID and name
+func (c *CatalogDB) ListAllSchemas() ([]*models.Schema, error) {
+	return c.querySchemas("")
+}
+
+// querySchemas loads the schemas matching a WHERE clause
+func (c *CatalogDB) querySchemas(where string, args ...interface{}) ([]*models.Schema, error) {
+	query := fmt.Sprintf(`
+		SELECT database_id, name, fields, read_filter, retention_days, deprecated_fields, masked_fields, created_at
+		FROM schemas
+		%s
+		ORDER BY database_id, name
+	`, where)
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schemas: %w", err)
+	}
+	defer rows.Close()
+
+	var schemas []*models.Schema
+	for rows.Next() {
+		var schema models.Schema
+		var fieldsJSON, deprecatedJSON, maskedJSON string
+		var createdAt int64
+		if err := rows.Scan(&schema.DatabaseID, &schema.Name, &fieldsJSON, &schema.ReadFilter, &schema.RetentionDays, &deprecatedJSON, &maskedJSON, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema: %w", err)
+		}
+		if err := json.Unmarshal([]byte(fieldsJSON), &schema.Fields); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal fields: %w", err)
+		}
+		if err := unmarshalDeprecated(deprecatedJSON, &schema); err != nil {
+			return nil, err
+		}
+		if err := unmarshalMasked(maskedJSON, &schema); err != nil {
+			return nil, err
+		}
+		schema.CreatedAt = time.Unix(createdAt, 0)
+		schemas = append(schemas, &schema)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list schemas: %w", err)
+	}
+
+	return schemas, nil
+}
+
+// SetReadFilter sets the row-level read filter expression for a collection
+// An empty expression removes the filter
+func (c *CatalogDB) SetReadFilter(dbID string, name string, expression string) (*models.Schema, error) {
+	query := `UPDATE schemas SET read_filter = ? WHERE database_id = ? AND name = ?`
+	result, err := c.db.Exec(query, expression, dbID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update read filter: %w", err)
+	}
+	c.schemas.invalidate(dbID, name)
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("schema not found")
+	}
+
+	return c.GetSchema(dbID, name)
+}
+
 // DeleteSchema deletes a schema and drops the collection table
 func (c *CatalogDB) DeleteSchema(dbID string, name string) error {
 	// Verify schema exists
@@ -435,20 +1181,27 @@ func (c *CatalogDB) DeleteSchema(dbID string, name string) error {
 		return fmt.Errorf("schema not found")
 	}
 
-	// Delete from catalog
+	// Delete from catalog; the table's indexes are dropped with it
 	query := `DELETE FROM schemas WHERE database_id = ? AND name = ?`
 	_, err = c.db.Exec(query, dbID, name)
 	if err != nil {
 		return fmt.Errorf("failed to delete schema from catalog: %w", err)
 	}
+	c.schemas.invalidate(dbID, name)
+	_, err = c.db.Exec(`DELETE FROM collection_indexes WHERE database_id = ? AND collection = ?`, dbID, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete indexes from catalog: %w", err)
+	}
+	if err := c.deleteCollectionMirrors(dbID, name); err != nil {
+		return err
+	}
 
 	// Drop the table from the database file
-	dbPath := c.getDatabasePath(dbID)
-	db, err := sql.Open("sqlite3", dbPath)
+	db, release, err := c.openDatabase(dbID)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
-	defer db.Close()
+	defer release()
 
 	// Drop the collection table with quoted identifier
 	quotedName := QuoteIdentifier(name)
@@ -458,6 +1211,12 @@ func (c *CatalogDB) DeleteSchema(dbID string, name string) error {
 		return fmt.Errorf("failed to drop table: %w", err)
 	}
 
+	// Drop the full-text index, if one was created
+	_, err = db.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %s`, QuoteIdentifier(searchTableName(name))))
+	if err != nil {
+		return fmt.Errorf("failed to drop search index: %w", err)
+	}
+
 	// Remove from collections registry
 	_, err = db.Exec(`DELETE FROM _collections WHERE name = ?`, name)
 	if err != nil {
@@ -482,7 +1241,8 @@ func (c *CatalogDB) DeleteSchema(dbID string, name string) error {
 	return nil
 }
 
-// Close closes the catalog database connection
+// Close closes the open database files and the catalog database connection
 func (c *CatalogDB) Close() error {
+	c.pool.Close()
 	return c.db.Close()
 }
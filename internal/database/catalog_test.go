@@ -0,0 +1,127 @@
+package database
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestGetDatabasePath(t *testing.T) {
+	base := filepath.Join("var", "lib", "jsondrop")
+	c := &CatalogDB{dbBaseDir: base + string(filepath.Separator)}
+
+	got, err := c.getDatabasePath("db_abc123")
+	if err != nil {
+		t.Fatalf("getDatabasePath() error = %v, want nil", err)
+	}
+	if want := filepath.Join(base, "db_abc123.db"); got != want {
+		t.Errorf("getDatabasePath() = %q, want %q", got, want)
+	}
+}
+
+func TestGetDatabasePath_Traversal(t *testing.T) {
+	c := &CatalogDB{dbBaseDir: filepath.Join("data", "dbs")}
+
+	attempts := []string{
+		"db_../../catalog",
+		"db_/../../etc/passwd",
+		`db_..\..\catalog`,
+		"db_C:catalog",
+		"../db_abc",
+		"db_abc/../../x",
+		"db_abc\x00",
+	}
+	for _, id := range attempts {
+		if path, err := c.getDatabasePath(id); err == nil {
+			t.Errorf("getDatabasePath(%q) = %q, want error", id, path)
+		}
+	}
+}
+
+func TestRotateKeys(t *testing.T) {
+	c := newTestCatalog(t)
+
+	created, err := c.CreateDatabase()
+	if err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	dbID := created.DatabaseID
+	works := func(name string, get func(string) (*models.Database, error), key string, want bool) {
+		t.Helper()
+		db, err := get(key)
+		if err != nil {
+			t.Fatalf("%s error = %v", name, err)
+		}
+		if got := db != nil && db.ID == dbID; got != want {
+			t.Errorf("%s found the database = %v, want %v", name, got, want)
+		}
+	}
+
+	owned := func(want bool) {
+		t.Helper()
+		if got, err := c.IsOwner(dbID, "ada"); err != nil || got != want {
+			t.Errorf("IsOwner() = %v, %v, want %v", got, err, want)
+		}
+	}
+	if err := c.AddOwner(dbID, "ada"); err != nil {
+		t.Fatalf("AddOwner() error = %v", err)
+	}
+
+	// Replaced keys keep working through the grace period
+	rotated, err := c.RotateKeys(dbID, true, false, time.Hour)
+	if err != nil {
+		t.Fatalf("RotateKeys(write, 1h) error = %v", err)
+	}
+	if !strings.HasPrefix(rotated.WriteKey, "wk_") || rotated.ReadKey != "" || rotated.OldKeysExpire == nil {
+		t.Fatalf("RotateKeys(write, 1h) = %+v, want a new write key and an expiry", rotated)
+	}
+	works("old write key", c.GetDatabaseByWriteKey, created.WriteKey, true)
+	works("new write key", c.GetDatabaseByWriteKey, rotated.WriteKey, true)
+	works("read key", c.GetDatabaseByReadKey, created.ReadKey, true)
+	// Console owners claimed the database with the old write key
+	owned(false)
+
+	// Until it expires
+	if _, err := c.db.Exec(`UPDATE databases SET previous_write_key_expires = 1`); err != nil {
+		t.Fatalf("expiring the old write key: %v", err)
+	}
+	works("expired write key", c.GetDatabaseByWriteKey, created.WriteKey, false)
+
+	// Without a grace period, the old keys stop working at once
+	again, err := c.RotateKeys(dbID, true, true, 0)
+	if err != nil {
+		t.Fatalf("RotateKeys(both, 0) error = %v", err)
+	}
+	if again.OldKeysExpire != nil {
+		t.Errorf("RotateKeys(both, 0) expiry = %v, want none", again.OldKeysExpire)
+	}
+	works("revoked write key", c.GetDatabaseByWriteKey, rotated.WriteKey, false)
+	works("revoked read key", c.GetDatabaseByReadKey, created.ReadKey, false)
+	works("new write key", c.GetDatabaseByWriteKey, again.WriteKey, true)
+	works("new read key", c.GetDatabaseByReadKey, again.ReadKey, true)
+	works("new read key as a write key", c.GetDatabaseByWriteKey, again.ReadKey, false)
+
+	// A new read key alone leaves the owners
+	if err := c.AddOwner(dbID, "ada"); err != nil {
+		t.Fatalf("AddOwner() error = %v", err)
+	}
+	if _, err := c.RotateKeys(dbID, false, true, 0); err != nil {
+		t.Fatalf("RotateKeys(read, 0) error = %v", err)
+	}
+	owned(true)
+
+	// Each rotation moves to a new key generation, revoking older access tokens
+	if db, err := c.GetDatabaseByID(dbID); err != nil || db.KeyGeneration != 3 {
+		t.Errorf("GetDatabaseByID() after three rotations = %+v, %v, want key generation 3", db, err)
+	}
+
+	if _, err := c.RotateKeys(dbID, false, false, 0); err == nil {
+		t.Error("RotateKeys(neither) error = nil, want error")
+	}
+	if _, err := c.RotateKeys("db_missing", true, false, 0); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("RotateKeys(missing database) error = %v, want not found", err)
+	}
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// ChangesQuery selects a page of a collection's change feed
+type ChangesQuery struct {
+	Since     uint64    // Documents changed after this sequence number
+	SinceTime time.Time // Or, when set, documents changed at or after this time
+	Limit     int       // Documents per page; 0 selects DefaultHistoryLimit
+}
+
+// Changes returns the documents of a collection changed since a point in its database's
+// event log, each once and in the state it has now, ordered by their last change
+// Documents deleted since then, or that the scope doesn't let the caller read, are
+// reported as deleted
+func (c *CatalogDB) Changes(dbID string, collection string, q ChangesQuery, scope *ReadScope) (*models.ChangesResponse, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultHistoryLimit
+	}
+	if q.Limit > MaxHistoryLimit {
+		return nil, fmt.Errorf("invalid limit: must be between 1 and %d", MaxHistoryLimit)
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	window, err := readLogWindow(db, q.Since, q.SinceTime)
+	if err != nil {
+		return nil, err
+	}
+	response := &models.ChangesResponse{Changes: []models.DocumentChange{}, Next: uint64(window.since), Complete: window.complete}
+	if window.since >= window.last {
+		return response, nil
+	}
+
+	// A document changed several times is listed once, at its last change; one whose last
+	// change is past the page is left for the next page, which starts after it
+	rows, err := db.Query(`
+		SELECT document_id, MAX(seq), MAX(created_at)
+		FROM _events
+		WHERE seq > ? AND seq <= ? AND collection = ? AND document_id != ''
+		GROUP BY document_id
+		ORDER BY MAX(seq)
+		LIMIT ?
+	`, window.since, window.last, collection, q.Limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+	defer rows.Close()
+
+	changes := map[string]int{} // Document ID -> index in response.Changes
+	for rows.Next() {
+		var change models.DocumentChange
+		var changedAt int64
+		if err := rows.Scan(&change.ID, &change.Seq, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to read event log: %w", err)
+		}
+		if len(response.Changes) == q.Limit {
+			response.HasMore = true
+			break
+		}
+		change.ChangedAt = time.UnixMilli(changedAt)
+		change.Deleted = true // Until the document is found
+		changes[change.ID] = len(response.Changes)
+		response.Changes = append(response.Changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+	rows.Close()
+
+	if response.HasMore {
+		response.Next = response.Changes[len(response.Changes)-1].Seq
+	} else {
+		response.Next = uint64(window.last)
+	}
+	if len(changes) == 0 {
+		return response, nil
+	}
+
+	// Fill in the documents that still exist
+	placeholders := make([]string, 0, len(changes))
+	args := make([]interface{}, 0, len(changes))
+	for id := range changes {
+		placeholders = append(placeholders, "?")
+		args = append(args, id)
+	}
+	visibilityClause, visibilityArgs := visibilityFilter("visibility", scope.VisibleLevels())
+	query := fmt.Sprintf(`
+		SELECT id, created_at, updated_at, data, visibility, revision
+		FROM %s
+		WHERE id IN (%s)%s%s
+	`, QuoteIdentifier(collection), strings.Join(placeholders, ", "), visibilityClause, scope.deletedFilter(""))
+	args = append(args, visibilityArgs...)
+
+	docs, err := db.Query(query, args...)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return response, nil // The collection is gone, and its documents with it
+		}
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer docs.Close()
+
+	for docs.Next() {
+		var doc models.Document
+		var createdAt, updatedAt int64
+		var stored []byte
+
+		if err := docs.Scan(&doc.ID, &createdAt, &updatedAt, &stored, &doc.Visibility, &doc.Revision); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+
+		if err := decodeData(stored, &doc.Data); err != nil {
+			return nil, err
+		}
+
+		doc.Collection = collection
+		doc.CreatedAt = time.Unix(createdAt, 0)
+		doc.UpdatedAt = time.Unix(updatedAt, 0)
+
+		if !scope.Allows(&doc) {
+			continue
+		}
+		change := &response.Changes[changes[doc.ID]]
+		change.Deleted = false
+		change.Document = &doc
+	}
+
+	return response, docs.Err()
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestChanges(t *testing.T) {
+	c := newTestCatalogWith(t, Limits{}, &eventRecorder{})
+
+	const dbID = "db_changes"
+	newTestDatabase(t, c, dbID)
+	for _, name := range []string{"tasks", "notes"} {
+		if _, err := c.CreateSchema(dbID, name, map[string]models.FieldType{"title": models.FieldTypeString}, nil); err != nil {
+			t.Fatalf("CreateSchema(%s) error = %v", name, err)
+		}
+	}
+
+	insert := func(collection string, title string, visibility models.Visibility) string {
+		t.Helper()
+		doc, err := c.InsertDocument(dbID, collection, map[string]interface{}{"title": title}, visibility)
+		if err != nil {
+			t.Fatalf("InsertDocument(%s) error = %v", title, err)
+		}
+		return doc.ID
+	}
+	kept := insert("tasks", "draft", "")
+	deleted := insert("tasks", "scratch", "")
+	hidden := insert("tasks", "secret", models.VisibilityWriteKeyOnly)
+	insert("notes", "aside", "")
+	if _, err := c.UpdateDocument(dbID, "tasks", kept, map[string]interface{}{"title": "final"}, "", 0); err != nil {
+		t.Fatalf("UpdateDocument() error = %v", err)
+	}
+	if err := c.DeleteDocument(dbID, "tasks", deleted, 0); err != nil {
+		t.Fatalf("DeleteDocument() error = %v", err)
+	}
+
+	// Each document appears once, at its last change, and other collections are left out
+	readScope := &ReadScope{Visible: models.VisibleLevels(true, false)}
+	first, err := c.Changes(dbID, "tasks", ChangesQuery{Limit: 2}, readScope)
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+	if len(first.Changes) != 2 || !first.HasMore || !first.Complete {
+		t.Fatalf("Changes() = %+v, want 2 changes with more to come", first)
+	}
+	if change := first.Changes[0]; change.ID != hidden || !change.Deleted || change.Document != nil {
+		t.Errorf("first change = %+v, want the write-key-only document reported deleted to a read key", change)
+	}
+	if change := first.Changes[1]; change.ID != kept || change.Deleted || change.Document == nil || change.Document.Data["title"] != "final" {
+		t.Errorf("second change = %+v, want the updated document", change)
+	}
+	if first.Next != first.Changes[1].Seq {
+		t.Errorf("Changes() next = %d, want the last change's seq %d", first.Next, first.Changes[1].Seq)
+	}
+
+	rest, err := c.Changes(dbID, "tasks", ChangesQuery{Since: first.Next}, readScope)
+	if err != nil {
+		t.Fatalf("Changes(since %d) error = %v", first.Next, err)
+	}
+	if len(rest.Changes) != 1 || rest.Changes[0].ID != deleted || !rest.Changes[0].Deleted || rest.HasMore {
+		t.Errorf("Changes(since %d) = %+v, want the deleted document alone", first.Next, rest)
+	}
+
+	// Polling again from the end finds nothing new
+	idle, err := c.Changes(dbID, "tasks", ChangesQuery{Since: rest.Next}, readScope)
+	if err != nil {
+		t.Fatalf("Changes(since %d) error = %v", rest.Next, err)
+	}
+	if len(idle.Changes) != 0 || idle.Next != rest.Next {
+		t.Errorf("Changes(since %d) = %+v, want no changes", rest.Next, idle)
+	}
+
+	// The write key sees the hidden document, and a time in the future finds nothing
+	all, err := c.Changes(dbID, "tasks", ChangesQuery{}, nil)
+	if err != nil {
+		t.Fatalf("Changes() with no scope error = %v", err)
+	}
+	if len(all.Changes) != 3 || all.Changes[0].Deleted || all.Changes[0].Document.Visibility != models.VisibilityWriteKeyOnly {
+		t.Errorf("Changes() with no scope = %+v, want 3 changes with the hidden document", all)
+	}
+	later, err := c.Changes(dbID, "tasks", ChangesQuery{SinceTime: time.Now().Add(time.Hour)}, nil)
+	if err != nil {
+		t.Fatalf("Changes(since an hour ahead) error = %v", err)
+	}
+	if len(later.Changes) != 0 || !later.Complete {
+		t.Errorf("Changes(since an hour ahead) = %+v, want no changes", later)
+	}
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// ListCollections returns every collection in a database with its document count and size
+// Only live documents at the given visibility levels are counted; nil visibility counts all levels
+func (c *CatalogDB) ListCollections(dbID string, visible []models.Visibility) ([]*models.CollectionInfo, error) {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	rows, err := db.Query(`SELECT name, created_at FROM _collections ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	collections := []*models.CollectionInfo{}
+	for rows.Next() {
+		var info models.CollectionInfo
+		var createdAt int64
+		if err := rows.Scan(&info.Name, &createdAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan collection: %w", err)
+		}
+		info.CreatedAt = time.Unix(createdAt, 0)
+		collections = append(collections, &info)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	visibilityClause, visibilityArgs := visibilityFilter("visibility", visible)
+	for _, info := range collections {
+		query := fmt.Sprintf(`
+			SELECT COUNT(*), COALESCE(SUM(LENGTH(data)), 0)
+			FROM %s
+			WHERE deleted_at IS NULL%s
+		`, QuoteIdentifier(info.Name), visibilityClause)
+
+		if err := db.QueryRow(query, visibilityArgs...).Scan(&info.DocumentCount, &info.SizeBytes); err != nil {
+			return nil, fmt.Errorf("failed to count collection %s: %w", info.Name, err)
+		}
+	}
+
+	return collections, nil
+}
+
+// CollectionStats returns the size of a collection's documents and indexes
+// Sizes cover soft-deleted documents too, since they count against the quota. Index sizes
+// are read from the dbstat table when SQLite has it, and estimated from key lengths otherwise
+func (c *CatalogDB) CollectionStats(dbID string, collection string) (*models.CollectionStats, error) {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	table := QuoteIdentifier(collection)
+	stats := &models.CollectionStats{Name: collection, Indexes: []models.IndexStats{}, IndexSizesEstimated: !c.capabilities.DBStat}
+	err = db.QueryRow(fmt.Sprintf(`
+		SELECT
+			COUNT(*) FILTER (WHERE deleted_at IS NULL),
+			COUNT(*) FILTER (WHERE deleted_at IS NOT NULL),
+			COALESCE(SUM(LENGTH(CAST(data AS BLOB))), 0),
+			COALESCE(AVG(LENGTH(CAST(data AS BLOB))), 0)
+		FROM %s
+	`, table)).Scan(&stats.DocumentCount, &stats.DeletedCount, &stats.TotalBytes, &stats.AverageBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure collection %s: %w", collection, err)
+	}
+
+	var largest models.LargestDocument
+	err = db.QueryRow(fmt.Sprintf(`
+		SELECT id, LENGTH(CAST(data AS BLOB)) AS size
+		FROM %s
+		ORDER BY size DESC, id ASC
+		LIMIT 1
+	`, table)).Scan(&largest.ID, &largest.Bytes)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to find largest document: %w", err)
+	}
+	if err == nil {
+		stats.LargestDocument = &largest
+	}
+
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ? ORDER BY name`, collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan index: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+
+	for _, name := range names {
+		var size int64
+		if c.capabilities.DBStat {
+			err = db.QueryRow(`SELECT COALESCE(SUM(pgsize), 0) FROM dbstat WHERE name = ?`, name).Scan(&size)
+		} else {
+			size, err = estimateIndexSize(db, table, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure index %s: %w", name, err)
+		}
+		stats.Indexes = append(stats.Indexes, models.IndexStats{Name: name, Bytes: size})
+		stats.IndexBytes += size
+	}
+
+	return stats, nil
+}
+
+// estimateIndexSize estimates an index's size as the length of its keys plus a rowid
+// per entry; expression columns, which index_info doesn't name, are left out
+func estimateIndexSize(db *sql.DB, table string, index string) (int64, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_index_info(?)`, index)
+	if err != nil {
+		return 0, err
+	}
+	sum := "8 * COUNT(*)"
+	for rows.Next() {
+		var column sql.NullString
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if column.Valid {
+			sum += fmt.Sprintf(" + COALESCE(SUM(LENGTH(CAST(%s AS BLOB))), 0)", QuoteIdentifier(column.String))
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var size int64
+	err = db.QueryRow(fmt.Sprintf(`SELECT %s FROM %s`, sum, table)).Scan(&size)
+	return size, err
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestCollectionStats(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_stats"
+	newTestDatabase(t, c, dbID)
+	if _, err := c.CreateSchema(dbID, "notes", map[string]models.FieldType{"text": models.FieldTypeString}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+
+	stats, err := c.CollectionStats(dbID, "notes")
+	if err != nil {
+		t.Fatalf("CollectionStats() on an empty collection error = %v", err)
+	}
+	if stats.DocumentCount != 0 || stats.LargestDocument != nil {
+		t.Errorf("CollectionStats() = %+v, want no documents", stats)
+	}
+
+	var largest *models.Document
+	for _, text := range []string{"a", "bb", strings.Repeat("c", 100)} {
+		doc, err := c.InsertDocument(dbID, "notes", map[string]interface{}{"text": text}, "")
+		if err != nil {
+			t.Fatalf("InsertDocument() error = %v", err)
+		}
+		largest = doc
+	}
+	first, _ := c.QueryDocuments(dbID, "notes", 1, 0, []Filter{{Field: "text", Type: models.FieldTypeString, Values: []string{"a"}}}, nil, nil, nil)
+	if err := c.SoftDeleteDocument(dbID, "notes", first[0].ID, 0); err != nil {
+		t.Fatalf("SoftDeleteDocument() error = %v", err)
+	}
+
+	info, _ := c.GetDatabaseByID(dbID)
+	for _, dbstat := range []bool{false, true} {
+		if dbstat && !c.capabilities.DBStat {
+			continue
+		}
+		c.capabilities.DBStat = dbstat
+
+		stats, err := c.CollectionStats(dbID, "notes")
+		if err != nil {
+			t.Fatalf("CollectionStats(dbstat=%v) error = %v", dbstat, err)
+		}
+		if stats.DocumentCount != 2 || stats.DeletedCount != 1 {
+			t.Errorf("counts = %d live, %d deleted, want 2 and 1", stats.DocumentCount, stats.DeletedCount)
+		}
+		// Soft-deleted documents still count against the quota, and so in the total
+		if stats.TotalBytes != info.QuotaUsed {
+			t.Errorf("total_bytes = %d, want the quota used, %d", stats.TotalBytes, info.QuotaUsed)
+		}
+		if stats.AverageBytes != float64(stats.TotalBytes)/3 {
+			t.Errorf("average_bytes = %v, want %v", stats.AverageBytes, float64(stats.TotalBytes)/3)
+		}
+		if stats.LargestDocument == nil || stats.LargestDocument.ID != largest.ID {
+			t.Errorf("largest_document = %+v, want %s", stats.LargestDocument, largest.ID)
+		}
+		if stats.IndexSizesEstimated == dbstat || len(stats.Indexes) == 0 || stats.IndexBytes <= 0 {
+			t.Errorf("dbstat=%v: indexes = %+v, %d bytes, estimated %v", dbstat, stats.Indexes, stats.IndexBytes, stats.IndexSizesEstimated)
+		}
+	}
+}
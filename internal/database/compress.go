@@ -0,0 +1,158 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Documents larger than the compression threshold are stored gzip- or zstd-compressed as a
+// BLOB, while smaller ones stay JSON text, so a collection can hold both, and documents
+// written under either algorithm. SQL that looks inside
+// documents reads the column through jsondrop_data(), and SQL that rewrites documents
+// stores the result through jsondrop_pack(); every SQLite driver registers both for its
+// connections.
+const (
+	dataFunction = "jsondrop_data"
+	packFunction = "jsondrop_pack"
+)
+
+// Compression algorithms for stored documents
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Compression configures how large documents are stored
+type Compression struct {
+	Threshold int    // Documents larger than this many bytes are compressed; 0 disables
+	Algorithm string // CompressionGzip or CompressionZstd; empty means gzip
+}
+
+// Magic bytes start every gzip and zstd stream; JSON text never starts with either
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// The zstd encoder and decoder are safe for concurrent EncodeAll and DecodeAll calls
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// ValidCompression reports whether an algorithm name is supported
+func ValidCompression(algorithm string) bool {
+	return algorithm == CompressionGzip || algorithm == CompressionZstd
+}
+
+// dataExpr returns SQL reading a data column as JSON text, whether or not it is compressed
+func dataExpr(column string) string {
+	return fmt.Sprintf("%s(%s)", dataFunction, column)
+}
+
+// isCompressed reports whether stored document data is compressed
+func isCompressed(stored []byte) bool {
+	return bytes.HasPrefix(stored, gzipMagic) || bytes.HasPrefix(stored, zstdMagic)
+}
+
+// packData returns the value to store for document JSON and its stored size
+// JSON larger than the threshold is compressed if that makes it smaller
+func packData(dataJSON []byte, compression Compression) (interface{}, int64, error) {
+	if compression.Threshold <= 0 || len(dataJSON) <= compression.Threshold {
+		return string(dataJSON), int64(len(dataJSON)), nil
+	}
+
+	var packed []byte
+	switch compression.Algorithm {
+	case "", CompressionGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(dataJSON); err != nil {
+			return nil, 0, fmt.Errorf("failed to compress document data: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, 0, fmt.Errorf("failed to compress document data: %w", err)
+		}
+		packed = buf.Bytes()
+	case CompressionZstd:
+		packed = zstdEncoder.EncodeAll(dataJSON, nil)
+	default:
+		return nil, 0, fmt.Errorf("unknown compression algorithm: %s", compression.Algorithm)
+	}
+
+	if len(packed) >= len(dataJSON) {
+		return string(dataJSON), int64(len(dataJSON)), nil
+	}
+	return packed, int64(len(packed)), nil
+}
+
+// unpackData returns the JSON of stored document data
+func unpackData(stored []byte) ([]byte, error) {
+	if !isCompressed(stored) {
+		return stored, nil
+	}
+	if bytes.HasPrefix(stored, zstdMagic) {
+		dataJSON, err := zstdDecoder.DecodeAll(stored, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress document data: %w", err)
+		}
+		return dataJSON, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(stored))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress document data: %w", err)
+	}
+	defer zr.Close()
+
+	dataJSON, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress document data: %w", err)
+	}
+	return dataJSON, nil
+}
+
+// storedValue returns scanned document data as it was stored, a BLOB if compressed and TEXT
+// otherwise, so it can be written back unchanged
+func storedValue(stored []byte) interface{} {
+	if isCompressed(stored) {
+		return stored
+	}
+	return string(stored)
+}
+
+// sqlUnpackData implements jsondrop_data(data)
+func sqlUnpackData(stored interface{}) (interface{}, error) {
+	blob, ok := stored.([]byte)
+	if !ok || !isCompressed(blob) {
+		return stored, nil
+	}
+	dataJSON, err := unpackData(blob)
+	if err != nil {
+		return nil, err
+	}
+	return string(dataJSON), nil
+}
+
+// sqlPackData implements jsondrop_pack(json, threshold, algorithm)
+func sqlPackData(dataJSON string, threshold int64, algorithm string) (interface{}, error) {
+	value, _, err := packData([]byte(dataJSON), Compression{Threshold: int(threshold), Algorithm: algorithm})
+	return value, err
+}
+
+// decodeData unmarshals stored document data
+func decodeData(stored []byte, data *map[string]interface{}) error {
+	dataJSON, err := unpackData(stored)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(dataJSON, data); err != nil {
+		return fmt.Errorf("failed to unmarshal document data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPackData(t *testing.T) {
+	small := []byte(`{"title":"short"}`)
+	large := []byte(fmt.Sprintf(`{"body":%q}`, strings.Repeat("lorem ipsum ", 200)))
+
+	tests := []struct {
+		name       string
+		data       []byte
+		threshold  int
+		algorithm  string
+		compressed bool
+	}{
+		{"disabled", large, 0, CompressionGzip, false},
+		{"below threshold", small, 1024, CompressionGzip, false},
+		{"above threshold", large, 1024, CompressionGzip, true},
+		{"default algorithm", large, 1024, "", true},
+		{"zstd", large, 1024, CompressionZstd, true},
+		{"no saving", []byte(`{"a":1}`), 1, CompressionZstd, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, size, err := packData(tt.data, Compression{Threshold: tt.threshold, Algorithm: tt.algorithm})
+			if err != nil {
+				t.Fatalf("packData() error = %v, want nil", err)
+			}
+
+			blob, isBlob := value.([]byte)
+			if isBlob != tt.compressed {
+				t.Fatalf("packData() returned %T, want compressed = %v", value, tt.compressed)
+			}
+			if !tt.compressed {
+				if value != string(tt.data) || size != int64(len(tt.data)) {
+					t.Errorf("packData() = %v, %d, want the JSON text unchanged", value, size)
+				}
+				return
+			}
+
+			if size != int64(len(blob)) || size >= int64(len(tt.data)) {
+				t.Errorf("packData() size = %d, want the compressed length below %d", size, len(tt.data))
+			}
+			got, err := unpackData(blob)
+			if err != nil {
+				t.Fatalf("unpackData() error = %v, want nil", err)
+			}
+			if string(got) != string(tt.data) {
+				t.Errorf("unpackData() did not round-trip the document")
+			}
+		})
+	}
+}
+
+func TestUnpackData_PlainJSON(t *testing.T) {
+	data := []byte(`{"title":"plain"}`)
+	got, err := unpackData(data)
+	if err != nil {
+		t.Fatalf("unpackData() error = %v, want nil", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("unpackData() = %s, want %s", got, data)
+	}
+}
+
+func TestPackData_UnknownAlgorithm(t *testing.T) {
+	large := []byte(fmt.Sprintf(`{"body":%q}`, strings.Repeat("lorem ipsum ", 200)))
+	if _, _, err := packData(large, Compression{Threshold: 1, Algorithm: "lz4"}); err == nil {
+		t.Error("packData() error = nil, want an error for an unknown algorithm")
+	}
+}
+
+func TestStoredValue(t *testing.T) {
+	if _, ok := storedValue([]byte(`{"a":1}`)).(string); !ok {
+		t.Error("storedValue() of JSON should be TEXT")
+	}
+
+	blob, _, err := packData([]byte(strings.Repeat(`{"a":"aaaaaaaa"}`, 10)), Compression{Threshold: 1})
+	if err != nil {
+		t.Fatalf("packData() error = %v, want nil", err)
+	}
+	if _, ok := storedValue(blob.([]byte)).([]byte); !ok {
+		t.Error("storedValue() of compressed data should be a BLOB")
+	}
+}
+
+func TestFieldColumns_CompressedDocuments(t *testing.T) {
+	db, err := sql.Open(sqlDriverName(), ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	createSQL := fmt.Sprintf("CREATE TABLE docs (id TEXT PRIMARY KEY, data TEXT NOT NULL, %s TEXT %s)",
+		fieldColumnName("title"), fieldColumnDefinition("title", storedColumn))
+	if _, err := db.Exec(createSQL); err != nil {
+		t.Fatalf("create table error = %v", err)
+	}
+
+	body := strings.Repeat("text ", 100)
+	documents := map[string]Compression{
+		"plain":  {},
+		"packed": {Threshold: 64},
+		"zstd":   {Threshold: 64, Algorithm: CompressionZstd},
+	}
+	for id, compression := range documents {
+		value, _, err := packData([]byte(fmt.Sprintf(`{"title":%q,"body":%q}`, id, body)), compression)
+		if err != nil {
+			t.Fatalf("packData() error = %v", err)
+		}
+		if _, err := db.Exec(`INSERT INTO docs (id, data) VALUES (?, ?)`, id, value); err != nil {
+			t.Fatalf("insert %s error = %v", id, err)
+		}
+	}
+
+	for _, id := range []string{"packed", "zstd"} {
+		var kind string
+		if err := db.QueryRow(`SELECT typeof(data) FROM docs WHERE id = ?`, id).Scan(&kind); err != nil || kind != "blob" {
+			t.Fatalf("%s document stored as %q (%v), want blob", id, kind, err)
+		}
+	}
+
+	// Schema changes rewrite documents through the SQL functions
+	var title string
+	rewrite := fmt.Sprintf(`SELECT json_extract(%s(%s(%s, 64, ?)), '$.title') FROM docs WHERE id = 'plain'`,
+		dataFunction, packFunction, dataExpr("data"))
+	if err := db.QueryRow(rewrite, CompressionZstd).Scan(&title); err != nil || title != "plain" {
+		t.Errorf("round trip through %s = %q (%v), want plain", packFunction, title, err)
+	}
+
+	for _, id := range []string{"plain", "packed", "zstd"} {
+		var title string
+		query := fmt.Sprintf("SELECT %s FROM docs WHERE id = ?", fieldColumnName("title"))
+		if err := db.QueryRow(query, id).Scan(&title); err != nil {
+			t.Fatalf("select %s error = %v", id, err)
+		}
+		if title != id {
+			t.Errorf("generated column for %s = %q, want %q", id, title, id)
+		}
+	}
+}
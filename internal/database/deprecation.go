@@ -0,0 +1,108 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// MaxGraceDays bounds a deprecated field's grace period, about ten years
+const MaxGraceDays = 3650
+
+// DeprecateField marks a schema field as deprecated until the end of its grace period
+// Deprecating a field again replaces its grace period and note
+func (c *CatalogDB) DeprecateField(dbID string, name string, field string, deprecation models.FieldDeprecation) (*models.Schema, error) {
+	schema, err := c.GetSchema(dbID, name)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("schema not found")
+	}
+	if _, exists := schema.Fields[field]; !exists {
+		return nil, fmt.Errorf("field not found: %s", field)
+	}
+
+	deprecated := maps.Clone(schema.Deprecated)
+	if deprecated == nil {
+		deprecated = make(map[string]*models.FieldDeprecation)
+	}
+	deprecated[field] = &deprecation
+	if err := c.setDeprecated(dbID, name, deprecated); err != nil {
+		return nil, err
+	}
+	return c.GetSchema(dbID, name)
+}
+
+// UndeprecateField makes a deprecated field required on writes again
+func (c *CatalogDB) UndeprecateField(dbID string, name string, field string) (*models.Schema, error) {
+	schema, err := c.GetSchema(dbID, name)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("schema not found")
+	}
+	if schema.Deprecated[field] == nil {
+		return nil, fmt.Errorf("deprecation not found: %s is not deprecated", field)
+	}
+
+	deprecated := maps.Clone(schema.Deprecated)
+	delete(deprecated, field)
+	if err := c.setDeprecated(dbID, name, deprecated); err != nil {
+		return nil, err
+	}
+	return c.GetSchema(dbID, name)
+}
+
+// setDeprecated stores a schema's deprecated fields
+func (c *CatalogDB) setDeprecated(dbID string, name string, deprecated map[string]*models.FieldDeprecation) error {
+	deprecatedJSON, err := marshalDeprecated(deprecated)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE schemas SET deprecated_fields = ? WHERE database_id = ? AND name = ?`
+	if _, err := c.db.Exec(query, deprecatedJSON, dbID, name); err != nil {
+		return fmt.Errorf("failed to update deprecated fields: %w", err)
+	}
+	c.schemas.invalidate(dbID, name)
+	return nil
+}
+
+// checkRemovable verifies no removed field is deprecated with its grace period still running
+// Clients were promised they could keep writing such fields until the period ends
+func checkRemovable(schema *models.Schema, remove []string, now time.Time) error {
+	for _, field := range remove {
+		if deprecation := schema.Deprecated[field]; deprecation != nil && !deprecation.Retired(now) {
+			return fmt.Errorf("invalid schema update: field %s is deprecated until %s; remove it after its grace period",
+				field, deprecation.Until.UTC().Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// marshalDeprecated encodes deprecated fields for the catalog
+func marshalDeprecated(deprecated map[string]*models.FieldDeprecation) (string, error) {
+	if len(deprecated) == 0 {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(deprecated)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal deprecated fields: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// unmarshalDeprecated decodes a schema's deprecated fields from the catalog
+func unmarshalDeprecated(deprecatedJSON string, schema *models.Schema) error {
+	if deprecatedJSON == "" || deprecatedJSON == "{}" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(deprecatedJSON), &schema.Deprecated); err != nil {
+		return fmt.Errorf("failed to unmarshal deprecated fields: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestDeprecateField(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_deprecate"
+	newTestDatabase(t, c, dbID)
+	fields := map[string]models.FieldType{"name": models.FieldTypeString, "nick": models.FieldTypeString}
+	if _, err := c.CreateSchema(dbID, "people", fields, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	deprecation := models.FieldDeprecation{DeprecatedAt: now, Until: now.AddDate(0, 0, 30), Note: "use name"}
+	if _, err := c.DeprecateField(dbID, "people", "email", deprecation); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("DeprecateField(unknown field) error = %v, want not found", err)
+	}
+	schema, err := c.DeprecateField(dbID, "people", "nick", deprecation)
+	if err != nil {
+		t.Fatalf("DeprecateField() error = %v", err)
+	}
+	if got := schema.Deprecated["nick"]; got == nil || !got.Until.Equal(deprecation.Until) || got.Note != "use name" {
+		t.Fatalf("Deprecated[nick] = %+v, want %+v", got, deprecation)
+	}
+
+	// During the grace period the field may be omitted or written, but not removed
+	if err := models.ValidateDocument(map[string]interface{}{"name": "ann"}, schema); err != nil {
+		t.Errorf("ValidateDocument(without nick) error = %v, want nil", err)
+	}
+	if err := models.ValidateDocument(map[string]interface{}{"name": "ann", "nick": "a"}, schema); err != nil {
+		t.Errorf("ValidateDocument(with nick) error = %v, want nil", err)
+	}
+	remove := &models.UpdateSchemaRequest{RemoveFields: []string{"nick"}}
+	if _, err := c.UpdateSchema(dbID, "people", remove); err == nil || !strings.HasPrefix(err.Error(), "invalid schema update") {
+		t.Errorf("UpdateSchema(remove nick) error = %v, want the grace period refused", err)
+	}
+
+	// Once it's over, writes including the field fail and it can be removed
+	deprecation.Until = now.Add(-time.Second)
+	if schema, err = c.DeprecateField(dbID, "people", "nick", deprecation); err != nil {
+		t.Fatalf("DeprecateField() error = %v", err)
+	}
+	var validationErr *models.ValidationError
+	err = models.ValidateDocument(map[string]interface{}{"name": "ann", "nick": "a"}, schema)
+	if !errors.As(err, &validationErr) || validationErr.Code != models.ValidationRetiredField {
+		t.Errorf("ValidateDocument(with retired nick) error = %v, want %s", err, models.ValidationRetiredField)
+	}
+	if schema, err = c.UpdateSchema(dbID, "people", remove); err != nil {
+		t.Fatalf("UpdateSchema(remove nick) error = %v", err)
+	}
+	if schema, _ = c.GetSchema(dbID, "people"); len(schema.Deprecated) != 0 {
+		t.Errorf("Deprecated = %v after removing the field, want none", schema.Deprecated)
+	}
+
+	if _, err := c.UndeprecateField(dbID, "people", "name"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("UndeprecateField(name) error = %v, want not found", err)
+	}
+	if _, err := c.DeprecateField(dbID, "people", "name", deprecation); err != nil {
+		t.Fatalf("DeprecateField(name) error = %v", err)
+	}
+	if schema, err = c.UndeprecateField(dbID, "people", "name"); err != nil || schema.Deprecated["name"] != nil {
+		t.Errorf("UndeprecateField(name) = %v, %v, want the deprecation gone", schema.Deprecated, err)
+	}
+}
@@ -4,62 +4,85 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"strings"
 	"time"
 
 	"jsondrop/internal/models"
 )
 
 // InsertDocument inserts a new document into a collection
-func (c *CatalogDB) InsertDocument(dbID string, collection string, data map[string]interface{}) (*models.Document, error) {
+func (c *CatalogDB) InsertDocument(dbID string, collection string, data map[string]interface{}, visibility models.Visibility) (*models.Document, error) {
 	// Generate document ID
-	docID, err := GenerateDocumentID()
+	docID, err := c.keys.DocumentID()
 	if err != nil {
 		return nil, err
 	}
 
+	return c.InsertDocumentWithID(dbID, collection, docID, data, visibility)
+}
+
+// InsertDocumentWithID inserts a document under a caller-chosen ID
+// Used for fixtures and restores where IDs must be reproducible
+func (c *CatalogDB) InsertDocumentWithID(dbID string, collection string, docID string, data map[string]interface{}, visibility models.Visibility) (*models.Document, error) {
+	if visibility == "" {
+		visibility = models.DefaultVisibility
+	}
+	if !visibility.IsValid() {
+		return nil, fmt.Errorf("invalid visibility: %s", visibility)
+	}
+	if docID == "" {
+		return nil, fmt.Errorf("document ID cannot be empty")
+	}
+
 	// Marshal data to JSON
 	dataJSON, err := json.Marshal(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal document data: %w", err)
 	}
+	storedData, documentSize, err := packData(dataJSON, c.compression)
+	if err != nil {
+		return nil, err
+	}
 
 	now := time.Now().Unix()
 
 	// Open the database file
-	dbPath := c.getDatabasePath(dbID)
-	db, err := sql.Open("sqlite3", dbPath)
+	db, release, err := c.openDatabase(dbID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
-	defer db.Close()
+	defer release()
 
 	// Insert document with quoted identifier
 	quotedCollection := QuoteIdentifier(collection)
 	query := fmt.Sprintf(`
-		INSERT INTO %s (id, created_at, updated_at, data)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO %s (id, created_at, updated_at, data, visibility)
+		VALUES (?, ?, ?, ?, ?)
 	`, quotedCollection)
 
-	_, err = db.Exec(query, docID, now, now, string(dataJSON))
+	// Reserve quota for the stored size first, so concurrent inserts can't exceed the limit
+	if err := c.reserveQuota(dbID, documentSize); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(query, docID, now, now, storedData, string(visibility))
 	if err != nil {
+		c.releaseQuota(dbID, documentSize)
 		return nil, fmt.Errorf("failed to insert document: %w", err)
 	}
 
-	// Calculate size and update quota
-	documentSize := int64(len(dataJSON))
-	if err := c.updateQuotaAfterInsert(dbID, documentSize); err != nil {
-		// Try to rollback the insert
-		db.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", quotedCollection), docID)
-		return nil, err
+	if err := c.syncSearchIndex(db, collection, docID, data); err != nil {
+		// Log but don't fail; the document is stored and can be reindexed
 	}
 
 	doc := &models.Document{
 		ID:         docID,
 		Collection: collection,
 		Data:       data,
+		Visibility: visibility,
 		CreatedAt:  time.Unix(now, 0),
 		UpdatedAt:  time.Unix(now, 0),
+		Revision:   1,
 	}
 
 	// Broadcast insert event
@@ -70,6 +93,7 @@ func (c *CatalogDB) InsertDocument(dbID string, collection string, data map[stri
 			Collection: collection,
 			DocumentID: docID,
 			Data:       data,
+			Visibility: visibility,
 			Timestamp:  time.Unix(now, 0),
 		}
 		c.broadcaster.Broadcast(dbID, event)
@@ -78,28 +102,6 @@ func (c *CatalogDB) InsertDocument(dbID string, collection string, data map[stri
 	return doc, nil
 }
 
-// updateQuotaAfterInsert updates quota and checks if limit is exceeded
-func (c *CatalogDB) updateQuotaAfterInsert(dbID string, additionalSize int64) error {
-	// Get current quota usage
-	var quotaUsed, quotaLimit int64
-	query := `SELECT quota_used, quota_limit FROM databases WHERE id = ?`
-	err := c.db.QueryRow(query, dbID).Scan(&quotaUsed, &quotaLimit)
-	if err != nil {
-		return fmt.Errorf("failed to get quota: %w", err)
-	}
-
-	newQuotaUsed := quotaUsed + additionalSize
-
-	// Check if quota would be exceeded
-	if newQuotaUsed > quotaLimit {
-		return fmt.Errorf("quota exceeded: current %d bytes, limit %d bytes, attempted to add %d bytes",
-			quotaUsed, quotaLimit, additionalSize)
-	}
-
-	// Update quota
-	return c.UpdateQuotaUsed(dbID, newQuotaUsed)
-}
-
 // GenerateDocumentID generates a unique document ID
 func GenerateDocumentID() (string, error) {
 	id, err := generateRandomString(16)
@@ -110,30 +112,34 @@ func GenerateDocumentID() (string, error) {
 }
 
 // GetDocument retrieves a single document by ID
-func (c *CatalogDB) GetDocument(dbID string, collection string, docID string) (*models.Document, error) {
-	dbPath := c.getDatabasePath(dbID)
-	db, err := sql.Open("sqlite3", dbPath)
+// Documents outside the scope are reported as not found; a nil scope means no restriction
+func (c *CatalogDB) GetDocument(dbID string, collection string, docID string, scope *ReadScope) (*models.Document, error) {
+	db, release, err := c.openDatabase(dbID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
-	defer db.Close()
+	defer release()
 
 	quotedCollection := QuoteIdentifier(collection)
+	visibilityClause, visibilityArgs := visibilityFilter("visibility", scope.VisibleLevels())
 	query := fmt.Sprintf(`
-		SELECT id, created_at, updated_at, data
+		SELECT id, created_at, updated_at, data, visibility, revision
 		FROM %s
-		WHERE id = ?
-	`, quotedCollection)
+		WHERE id = ?%s%s
+	`, quotedCollection, visibilityClause, scope.deletedFilter(""))
 
 	var doc models.Document
 	var createdAt, updatedAt int64
-	var dataJSON string
+	var stored []byte
 
-	err = db.QueryRow(query, docID).Scan(
+	args := append([]interface{}{docID}, visibilityArgs...)
+	err = db.QueryRow(query, args...).Scan(
 		&doc.ID,
 		&createdAt,
 		&updatedAt,
-		&dataJSON,
+		&stored,
+		&doc.Visibility,
+		&doc.Revision,
 	)
 
 	if err == sql.ErrNoRows {
@@ -144,190 +150,230 @@ func (c *CatalogDB) GetDocument(dbID string, collection string, docID string) (*
 	}
 
 	// Unmarshal data
-	if err := json.Unmarshal([]byte(dataJSON), &doc.Data); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal document data: %w", err)
+	if err := decodeData(stored, &doc.Data); err != nil {
+		return nil, err
 	}
 
 	doc.Collection = collection
 	doc.CreatedAt = time.Unix(createdAt, 0)
 	doc.UpdatedAt = time.Unix(updatedAt, 0)
 
+	if !scope.Allows(&doc) {
+		return nil, nil
+	}
+
 	return &doc, nil
 }
 
-// QueryDocuments retrieves documents from a collection with pagination and filtering
-func (c *CatalogDB) QueryDocuments(dbID string, collection string, limit int, offset int, filters map[string][]string) ([]*models.Document, error) {
-	dbPath := c.getDatabasePath(dbID)
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+// documentsQuery builds the unpaginated SELECT for a document query
+// All conditions compare stored or generated columns directly, so SQLite can serve a filter
+// on several fields, and its sort, from one index declared over those fields
+func documentsQuery(collection string, filters []Filter, dates *TimeRange, order []SortKey, scope *ReadScope) (string, []interface{}) {
+	visibilityClause, visibilityArgs := visibilityFilter("visibility", scope.VisibleLevels())
+	dateClause, dateArgs := timeRangeFilter("", dates)
+	fieldClause, fieldArgs := filterClause("", filters)
+	orderBy := "created_at DESC"
+	if len(order) > 0 {
+		orderBy = orderByClause("", order)
 	}
-	defer db.Close()
-
-	// Build query with quoted identifier
-	quotedCollection := QuoteIdentifier(collection)
 	query := fmt.Sprintf(`
-		SELECT id, created_at, updated_at, data
+		SELECT id, created_at, updated_at, data, visibility, revision
 		FROM %s
-		ORDER BY created_at DESC
-	`, quotedCollection)
+		WHERE 1 = 1%s%s%s%s
+		ORDER BY %s
+	`, QuoteIdentifier(collection), visibilityClause, dateClause, fieldClause, scope.deletedFilter(""), orderBy)
 
-	// Add limit and offset
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
+	args := append(visibilityArgs, dateArgs...)
+	args = append(args, fieldArgs...)
+	return query, args
+}
+
+// QueryDocuments retrieves documents from a collection with pagination and filtering
+// Results are newest first unless sort keys are given
+// Nil dates or scope mean no timestamp or visibility restriction
+func (c *CatalogDB) QueryDocuments(dbID string, collection string, limit int, offset int, filters []Filter, dates *TimeRange, order []SortKey, scope *ReadScope) ([]*models.Document, error) {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
 	}
-	if offset > 0 {
-		query += fmt.Sprintf(" OFFSET %d", offset)
+	defer release()
+
+	query, args := documentsQuery(collection, filters, dates, order, scope)
+
+	// Paginate in SQL unless rows are filtered by a read policy afterwards
+	inMemory := scope.HasPolicy()
+	if !inMemory {
+		if limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", limit)
+		}
+		if offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", offset)
+		}
 	}
 
-	rows, err := db.Query(query)
+	budget := c.limits.NewQueryBudget()
+	defer budget.Done()
+
+	rows, err := db.QueryContext(budget.Context(), query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query documents: %w", err)
+		return nil, budget.Err(fmt.Errorf("failed to query documents: %w", err))
 	}
 	defer rows.Close()
 
 	var documents []*models.Document
+	skipped := 0
 	for rows.Next() {
+		if err := budget.Scan(); err != nil {
+			return nil, err
+		}
+
 		var doc models.Document
 		var createdAt, updatedAt int64
-		var dataJSON string
+		var stored []byte
 
 		err := rows.Scan(
 			&doc.ID,
 			&createdAt,
 			&updatedAt,
-			&dataJSON,
+			&stored,
+			&doc.Visibility,
+			&doc.Revision,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan document: %w", err)
 		}
 
 		// Unmarshal data
-		if err := json.Unmarshal([]byte(dataJSON), &doc.Data); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal document data: %w", err)
+		if err := decodeData(stored, &doc.Data); err != nil {
+			return nil, err
 		}
 
 		doc.Collection = collection
 		doc.CreatedAt = time.Unix(createdAt, 0)
 		doc.UpdatedAt = time.Unix(updatedAt, 0)
 
-		// Apply in-memory filtering
-		if matchesFilters(&doc, filters) {
+		if !inMemory {
 			documents = append(documents, &doc)
+			continue
+		}
+
+		// Apply the read policy, then paginate
+		if !scope.Allows(&doc) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		documents = append(documents, &doc)
+		if limit > 0 && len(documents) >= limit {
+			break
 		}
 	}
 
-	return documents, rows.Err()
+	return documents, budget.Err(rows.Err())
 }
 
-// matchesFilters checks if a document matches the provided filters
-// Multiple values for the same field are treated as OR (IN list)
-func matchesFilters(doc *models.Document, filters map[string][]string) bool {
-	if len(filters) == 0 {
-		return true
+// EachDocument streams every document in a collection to fn, oldest first
+// Rows are read one at a time so large collections aren't buffered in memory
+func (c *CatalogDB) EachDocument(dbID string, collection string, scope *ReadScope, fn func(*models.Document) error) error {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return err
 	}
+	defer release()
 
-	for fieldName, filterValues := range filters {
-		if len(filterValues) == 0 {
-			continue
-		}
+	quotedCollection := QuoteIdentifier(collection)
+	visibilityClause, visibilityArgs := visibilityFilter("visibility", scope.VisibleLevels())
+	query := fmt.Sprintf(`
+		SELECT id, created_at, updated_at, data, visibility, revision
+		FROM %s
+		WHERE 1 = 1%s%s
+		ORDER BY created_at ASC, id ASC
+	`, quotedCollection, visibilityClause, scope.deletedFilter(""))
+
+	rows, err := db.Query(query, visibilityArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var doc models.Document
+		var createdAt, updatedAt int64
+		var stored []byte
 
-		// Get the field value from the document
-		fieldValue, exists := doc.Data[fieldName]
-		if !exists {
-			return false // Field doesn't exist in document
+		if err := rows.Scan(&doc.ID, &createdAt, &updatedAt, &stored, &doc.Visibility, &doc.Revision); err != nil {
+			return fmt.Errorf("failed to scan document: %w", err)
 		}
 
-		// Check if field value matches any of the filter values (OR logic)
-		matched := false
-		for _, filterValue := range filterValues {
-			if matchesValue(fieldValue, filterValue) {
-				matched = true
-				break
-			}
+		if err := decodeData(stored, &doc.Data); err != nil {
+			return err
 		}
 
-		if !matched {
-			return false // AND logic between different fields
+		doc.Collection = collection
+		doc.CreatedAt = time.Unix(createdAt, 0)
+		doc.UpdatedAt = time.Unix(updatedAt, 0)
+
+		if !scope.Allows(&doc) {
+			continue
+		}
+		if err := fn(&doc); err != nil {
+			return err
 		}
 	}
 
-	return true
+	return rows.Err()
 }
 
-// matchesValue checks if a field value matches a filter value
-func matchesValue(fieldValue interface{}, filterValue string) bool {
-	switch v := fieldValue.(type) {
-	case string:
-		return v == filterValue
-	case float64:
-		// Try to parse filter as number
-		if filterNum, err := strconv.ParseFloat(filterValue, 64); err == nil {
-			return v == filterNum
-		}
-		return false
-	case bool:
-		// Try to parse filter as boolean
-		if filterBool, err := strconv.ParseBool(filterValue); err == nil {
-			return v == filterBool
-		}
-		return false
-	default:
-		// Convert to string and compare
-		return fmt.Sprintf("%v", fieldValue) == filterValue
+// visibilityFilter builds a SQL clause restricting column to the given visibility levels
+func visibilityFilter(column string, visible []models.Visibility) (string, []interface{}) {
+	if visible == nil {
+		return "", nil
 	}
+	if len(visible) == 0 {
+		return " AND 0", nil
+	}
+
+	placeholders := make([]string, len(visible))
+	args := make([]interface{}, len(visible))
+	for i, v := range visible {
+		placeholders[i] = "?"
+		args[i] = string(v)
+	}
+	return fmt.Sprintf(" AND %s IN (%s)", column, strings.Join(placeholders, ", ")), args
 }
 
 // DeleteDocument deletes a single document by ID
-func (c *CatalogDB) DeleteDocument(dbID string, collection string, docID string) error {
-	dbPath := c.getDatabasePath(dbID)
-	db, err := sql.Open("sqlite3", dbPath)
+// A nonzero revision must match the document's current revision
+func (c *CatalogDB) DeleteDocument(dbID string, collection string, docID string, revision int64) error {
+	db, release, err := c.openDatabase(dbID)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
-	defer db.Close()
+	defer release()
 
 	quotedCollection := QuoteIdentifier(collection)
 
-	// Get document size before deletion for quota update
-	var dataJSON string
-	query := fmt.Sprintf(`SELECT data FROM %s WHERE id = ?`, quotedCollection)
-	err = db.QueryRow(query, docID).Scan(&dataJSON)
+	// Delete the document, returning its stored size for the quota update and its data
+	// for the event
+	var documentSize int64
+	var stored []byte
+	var visibility models.Visibility
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE id = ? AND (? = 0 OR revision = ?) RETURNING LENGTH(CAST(data AS BLOB)), data, visibility`, quotedCollection)
+	err = db.QueryRow(deleteQuery, docID, revision, revision).Scan(&documentSize, &stored, &visibility)
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("document not found")
-	}
-	if err != nil {
-		return fmt.Errorf("failed to get document: %w", err)
+		return revisionMismatchOrNotFound(db, collection, docID, "")
 	}
-
-	documentSize := int64(len(dataJSON))
-
-	// Delete the document
-	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, quotedCollection)
-	result, err := db.Exec(deleteQuery, docID)
 	if err != nil {
 		return fmt.Errorf("failed to delete document: %w", err)
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("document not found")
+	if err := c.syncSearchIndex(db, collection, docID, nil); err != nil {
+		// Log but don't fail the delete
 	}
 
-	// Update quota
-	var quotaUsed int64
-	quotaQuery := `SELECT quota_used FROM databases WHERE id = ?`
-	err = c.db.QueryRow(quotaQuery, dbID).Scan(&quotaUsed)
-	if err != nil {
-		// Log error but don't fail the delete
-		return nil
-	}
-
-	newQuotaUsed := quotaUsed - documentSize
-	if newQuotaUsed < 0 {
-		newQuotaUsed = 0
-	}
-	c.UpdateQuotaUsed(dbID, newQuotaUsed)
+	c.releaseQuota(dbID, documentSize)
 
 	// Broadcast delete event
 	if c.broadcaster != nil {
@@ -336,9 +382,13 @@ func (c *CatalogDB) DeleteDocument(dbID string, collection string, docID string)
 			DatabaseID: dbID,
 			Collection: collection,
 			DocumentID: docID,
-			Data:       nil, // No data for delete events
+			Data:       nil, // The deleted document is in OldData
+			Visibility: visibility,
 			Timestamp:  time.Now(),
 		}
+		if err := decodeData(stored, &event.OldData); err != nil {
+			// Still broadcast the delete, without the previous data
+		}
 		c.broadcaster.Broadcast(dbID, event)
 	}
 
@@ -346,28 +396,22 @@ func (c *CatalogDB) DeleteDocument(dbID string, collection string, docID string)
 }
 
 // UpdateDocument updates an existing document by ID
-func (c *CatalogDB) UpdateDocument(dbID string, collection string, docID string, data map[string]interface{}) (*models.Document, error) {
-	dbPath := c.getDatabasePath(dbID)
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+// An empty visibility keeps the document's current visibility, and a nonzero revision
+// must match the document's current revision. The read, quota change, write and search
+// index entry happen in one transaction, which takes the database's write lock as it
+// begins (_txlock=immediate), so concurrent updates to a document apply one at a time
+func (c *CatalogDB) UpdateDocument(dbID string, collection string, docID string, data map[string]interface{}, visibility models.Visibility, revision int64) (*models.Document, error) {
+	if visibility != "" && !visibility.IsValid() {
+		return nil, fmt.Errorf("invalid visibility: %s", visibility)
 	}
-	defer db.Close()
-
-	quotedCollection := QuoteIdentifier(collection)
 
-	// Get old document size for quota update
-	var oldDataJSON string
-	query := fmt.Sprintf(`SELECT data FROM %s WHERE id = ?`, quotedCollection)
-	err = db.QueryRow(query, docID).Scan(&oldDataJSON)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("document not found")
-	}
+	db, release, err := c.openDatabase(dbID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get document: %w", err)
+		return nil, err
 	}
+	defer release()
 
-	oldSize := int64(len(oldDataJSON))
+	quotedCollection := QuoteIdentifier(collection)
 
 	// Marshal new data to JSON
 	newDataJSON, err := json.Marshal(data)
@@ -375,77 +419,135 @@ func (c *CatalogDB) UpdateDocument(dbID string, collection string, docID string,
 		return nil, fmt.Errorf("failed to marshal document data: %w", err)
 	}
 
-	newSize := int64(len(newDataJSON))
+	newStored, newSize, err := packData(newDataJSON, c.compression)
+	if err != nil {
+		return nil, err
+	}
 	now := time.Now().Unix()
 
-	// Update document
-	updateQuery := fmt.Sprintf(`
-		UPDATE %s
-		SET data = ?, updated_at = ?
-		WHERE id = ?
-	`, quotedCollection)
-
-	result, err := db.Exec(updateQuery, string(newDataJSON), now, docID)
+	// Reading the old size and writing the new data in one transaction keeps concurrent
+	// updates to the document from charging the same size change twice
+	tx, err := db.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("failed to update document: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	var oldSize, oldRevision, createdAt int64
+	var oldVisibility models.Visibility
+	var oldStored []byte
+	query := fmt.Sprintf(`SELECT data, LENGTH(CAST(data AS BLOB)), visibility, revision, created_at FROM %s WHERE id = ? AND deleted_at IS NULL`, quotedCollection)
+	err = tx.QueryRow(query, docID).Scan(&oldStored, &oldSize, &oldVisibility, &oldRevision, &createdAt)
+	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("document not found")
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	if err := CheckRevision(revision, oldRevision); err != nil {
+		return nil, err
+	}
+
+	if visibility == "" {
+		visibility = oldVisibility
+	}
 
-	// Update quota if size changed
+	// Reserve quota for growth before writing; shrinkage is released once committed
 	sizeDelta := newSize - oldSize
-	if sizeDelta != 0 {
-		var quotaUsed, quotaLimit int64
-		quotaQuery := `SELECT quota_used, quota_limit FROM databases WHERE id = ?`
-		err = c.db.QueryRow(quotaQuery, dbID).Scan(&quotaUsed, &quotaLimit)
-		if err == nil {
-			newQuotaUsed := quotaUsed + sizeDelta
-
-			// Check if quota would be exceeded
-			if sizeDelta > 0 && newQuotaUsed > quotaLimit {
-				// Rollback: restore old data
-				db.Exec(fmt.Sprintf("UPDATE %s SET data = ?, updated_at = (SELECT updated_at FROM %s WHERE id = ?) WHERE id = ?", quotedCollection, quotedCollection), oldDataJSON, docID, docID)
-				return nil, fmt.Errorf("quota exceeded: current %d bytes, limit %d bytes, attempted to add %d bytes",
-					quotaUsed, quotaLimit, sizeDelta)
-			}
-
-			if newQuotaUsed < 0 {
-				newQuotaUsed = 0
-			}
-			c.UpdateQuotaUsed(dbID, newQuotaUsed)
+	if sizeDelta > 0 {
+		if err := c.reserveQuota(dbID, sizeDelta); err != nil {
+			return nil, err
 		}
 	}
 
-	// Get created_at for response
-	var createdAt int64
-	err = db.QueryRow(fmt.Sprintf("SELECT created_at FROM %s WHERE id = ?", quotedCollection), docID).Scan(&createdAt)
+	// The write is conditional on the revision read above, so it can never apply on top of
+	// a version whose size wasn't the one charged for
+	updateQuery := fmt.Sprintf(`
+		UPDATE %s
+		SET data = ?, visibility = ?, updated_at = ?, revision = revision + 1
+		WHERE id = ? AND revision = ?
+	`, quotedCollection)
+
+	result, err := tx.Exec(updateQuery, newStored, string(visibility), now, docID, oldRevision)
+	if err == nil {
+		var updated int64
+		if updated, err = result.RowsAffected(); err == nil && updated != 1 {
+			err = fmt.Errorf("revision mismatch: document changed during the update")
+		}
+	}
 	if err != nil {
-		createdAt = now
+		c.releaseQuota(dbID, sizeDelta)
+		if strings.Contains(err.Error(), "revision mismatch") {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update document: %w", err)
 	}
 
+	if err := c.syncSearchIndex(tx, collection, docID, data); err != nil {
+		// Log but don't fail the update; a failed statement doesn't abort the transaction
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.releaseQuota(dbID, sizeDelta)
+		return nil, fmt.Errorf("failed to update document: %w", err)
+	}
+	c.releaseQuota(dbID, -sizeDelta)
+
 	doc := &models.Document{
 		ID:         docID,
 		Collection: collection,
 		Data:       data,
+		Visibility: visibility,
 		CreatedAt:  time.Unix(createdAt, 0),
 		UpdatedAt:  time.Unix(now, 0),
+		Revision:   oldRevision + 1,
 	}
 
-	// Broadcast update event
+	// Broadcast update event, with the data it replaced
 	if c.broadcaster != nil {
 		event := models.ChangeEvent{
-			EventType:  "update",
-			DatabaseID: dbID,
-			Collection: collection,
-			DocumentID: docID,
-			Data:       data,
-			Timestamp:  time.Unix(now, 0),
+			EventType:     "update",
+			DatabaseID:    dbID,
+			Collection:    collection,
+			DocumentID:    docID,
+			Data:          data,
+			Visibility:    visibility,
+			OldVisibility: oldVisibility,
+			Timestamp:     time.Unix(now, 0),
+		}
+		if err := decodeData(oldStored, &event.OldData); err != nil {
+			// Still broadcast the update, without the previous data
 		}
 		c.broadcaster.Broadcast(dbID, event)
 	}
 
 	return doc, nil
 }
+
+// CheckRevision fails when an expected revision is given and isn't the current one
+func CheckRevision(expected int64, current int64) error {
+	if expected != 0 && expected != current {
+		return revisionMismatch(current)
+	}
+	return nil
+}
+
+// revisionMismatch reports the current revision of a document a write expected to be at another
+func revisionMismatch(current int64) error {
+	return fmt.Errorf("revision mismatch: document is at revision %d", current)
+}
+
+// revisionMismatchOrNotFound explains why a write conditioned on a revision changed no rows
+// extraWhere further restricts which rows count as existing
+func revisionMismatchOrNotFound(db *sql.DB, collection string, docID string, extraWhere string) error {
+	var current int64
+	query := fmt.Sprintf(`SELECT revision FROM %s WHERE id = ?%s`, QuoteIdentifier(collection), extraWhere)
+	err := db.QueryRow(query, docID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("document not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get document: %w", err)
+	}
+	return revisionMismatch(current)
+}
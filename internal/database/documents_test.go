@@ -0,0 +1,64 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestDocumentEvents(t *testing.T) {
+	recorder := &eventRecorder{}
+	c := newTestCatalogWith(t, Limits{}, recorder)
+
+	const dbID = "db_docevents"
+	newTestDatabase(t, c, dbID)
+	if _, err := c.CreateSchema(dbID, "tasks", map[string]models.FieldType{"title": models.FieldTypeString}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	recorder.events = nil
+
+	doc, err := c.InsertDocument(dbID, "tasks", map[string]interface{}{"title": "draft"}, "")
+	if err != nil {
+		t.Fatalf("InsertDocument() error = %v", err)
+	}
+	if _, err := c.UpdateDocument(dbID, "tasks", doc.ID, map[string]interface{}{"title": "final"}, "", 0); err != nil {
+		t.Fatalf("UpdateDocument() error = %v", err)
+	}
+	if err := c.DeleteDocument(dbID, "tasks", doc.ID, 0); err != nil {
+		t.Fatalf("DeleteDocument() error = %v", err)
+	}
+
+	want := []string{"insert", "update", "delete"}
+	if len(recorder.events) != len(want) {
+		t.Fatalf("broadcast %+v, want %v", recorder.events, want)
+	}
+	for i, event := range recorder.events {
+		if event.EventType != want[i] || event.DatabaseID != dbID || event.Collection != "tasks" || event.DocumentID != doc.ID {
+			t.Errorf("event %d = %+v, want %s of %s", i, event, want[i], doc.ID)
+		}
+	}
+	// Updates carry the new data, so live views can show the edit, and updates and
+	// deletes the data they replaced
+	if update := recorder.events[1]; update.Data["title"] != "final" || update.OldData["title"] != "draft" {
+		t.Errorf("update event data = %v, old data = %v, want the new and previous titles", update.Data, update.OldData)
+	}
+	if deleted := recorder.events[2]; deleted.OldData["title"] != "final" {
+		t.Errorf("delete event old data = %v, want the deleted document", deleted.OldData)
+	}
+
+	recorder.events = nil
+	doc, err = c.InsertDocument(dbID, "tasks", map[string]interface{}{"title": "trash me"}, "")
+	if err != nil {
+		t.Fatalf("InsertDocument() error = %v", err)
+	}
+	if err := c.SoftDeleteDocument(dbID, "tasks", doc.ID, 0); err != nil {
+		t.Fatalf("SoftDeleteDocument() error = %v", err)
+	}
+	if soft := recorder.events[1]; soft.EventType != "delete" || soft.OldData["title"] != "trash me" {
+		t.Errorf("soft delete event = %+v, want a delete with the old data", soft)
+	}
+	if err := c.SoftDeleteDocument(dbID, "tasks", doc.ID, 0); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("SoftDeleteDocument() again error = %v, want not found", err)
+	}
+}
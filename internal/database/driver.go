@@ -0,0 +1,69 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQLite drivers jsondrop can be built with. mattn is built in whenever cgo is enabled;
+// modernc is built in without cgo, or alongside mattn with the sqlite_modernc build tag
+const (
+	DriverMattn   = "mattn"   // github.com/mattn/go-sqlite3, binding the C library through cgo
+	DriverModernc = "modernc" // modernc.org/sqlite, the C library translated to Go
+)
+
+// sqliteDriver is a SQLite driver registered with database/sql, along with jsondrop's
+// SQL functions
+type sqliteDriver struct {
+	sqlName string   // Name registered with database/sql
+	params  []string // DSN parameters every file is opened with
+}
+
+// sqlDrivers holds the drivers built in, by name
+var sqlDrivers = map[string]sqliteDriver{}
+
+// selectedDriver is the driver chosen by SelectDriver; empty for the preferred one
+var selectedDriver string
+
+// Drivers returns the SQLite drivers built into this binary, preferred first
+func Drivers() []string {
+	var names []string
+	for _, name := range []string{DriverMattn, DriverModernc} {
+		if _, ok := sqlDrivers[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// SelectDriver chooses the SQLite driver for databases opened from then on
+// An empty name selects the preferred driver built in
+func SelectDriver(name string) error {
+	if _, ok := sqlDrivers[name]; name != "" && !ok {
+		return fmt.Errorf("sqlite driver %s is not built in; this binary has %s", name, strings.Join(Drivers(), ", "))
+	}
+	selectedDriver = name
+	return nil
+}
+
+// CurrentDriver returns the SQLite driver in use
+func CurrentDriver() string {
+	if selectedDriver == "" {
+		return Drivers()[0]
+	}
+	return selectedDriver
+}
+
+// sqlDriverName returns the database/sql driver name to open SQLite files with
+func sqlDriverName() string {
+	return sqlDrivers[CurrentDriver()].sqlName
+}
+
+// withDriverParams appends the DSN parameters of the driver in use, and any others, to a path
+func withDriverParams(path string, params ...string) string {
+	params = append(params, sqlDrivers[CurrentDriver()].params...)
+	if len(params) == 0 {
+		return path
+	}
+	return path + "?" + strings.Join(params, "&")
+}
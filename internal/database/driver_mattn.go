@@ -0,0 +1,30 @@
+//go:build cgo
+
+package database
+
+import (
+	"database/sql"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	const name = "sqlite3_jsondrop"
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc(dataFunction, sqlUnpackData, true); err != nil {
+				return err
+			}
+			if err := conn.RegisterFunc(packFunction, sqlPackData, true); err != nil {
+				return err
+			}
+			if loadUnicodeCollation {
+				// Collations are per connection; the sqlite_icu tag provides icu_load_collation
+				_, err := conn.Exec(`SELECT icu_load_collation('root', '`+CollationUnicode+`')`, nil)
+				return err
+			}
+			return nil
+		},
+	})
+	sqlDrivers[DriverMattn] = sqliteDriver{sqlName: name}
+}
@@ -0,0 +1,44 @@
+//go:build !cgo || sqlite_modernc
+
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"modernc.org/sqlite"
+)
+
+func init() {
+	// modernc.org/sqlite registers functions for every connection of its "sqlite" driver
+	err := sqlite.RegisterDeterministicScalarFunction(dataFunction, 1,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			return sqlUnpackData(args[0])
+		})
+	if err == nil {
+		err = sqlite.RegisterDeterministicScalarFunction(packFunction, 3,
+			func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+				threshold, ok := args[1].(int64)
+				if !ok {
+					return nil, fmt.Errorf("%s: threshold must be an integer", packFunction)
+				}
+				algorithm, ok := args[2].(string)
+				if !ok {
+					return nil, fmt.Errorf("%s: algorithm must be text", packFunction)
+				}
+				switch dataJSON := args[0].(type) {
+				case string:
+					return sqlPackData(dataJSON, threshold, algorithm)
+				case []byte:
+					return sqlPackData(string(dataJSON), threshold, algorithm)
+				default:
+					return nil, fmt.Errorf("%s: data must be text", packFunction)
+				}
+			})
+	}
+	if err != nil {
+		panic(fmt.Sprintf("failed to register SQL functions: %v", err))
+	}
+	// mattn/go-sqlite3 waits up to 5s for locks by default; modernc.org/sqlite doesn't wait
+	sqlDrivers[DriverModernc] = sqliteDriver{sqlName: "sqlite", params: []string{"_pragma=busy_timeout(5000)"}}
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectDriver(t *testing.T) {
+	defer SelectDriver("")
+
+	drivers := Drivers()
+	if len(drivers) == 0 {
+		t.Fatal("Drivers() is empty, want at least one built-in driver")
+	}
+	for _, name := range drivers {
+		if err := SelectDriver(name); err != nil {
+			t.Errorf("SelectDriver(%q) error = %v", name, err)
+		}
+		if got := CurrentDriver(); got != name {
+			t.Errorf("CurrentDriver() = %s after selecting %s", got, name)
+		}
+	}
+
+	if err := SelectDriver(""); err != nil || CurrentDriver() != drivers[0] {
+		t.Errorf("SelectDriver(\"\") = %v, CurrentDriver() = %s, want the preferred driver %s", err, CurrentDriver(), drivers[0])
+	}
+	if err := SelectDriver("sqlite3"); err == nil {
+		t.Error("SelectDriver(\"sqlite3\") error = nil, want an error")
+	}
+}
+
+func TestDataSourceName(t *testing.T) {
+	dsn := dataSourceName("/data/db_a.db")
+	if !strings.HasPrefix(dsn, "/data/db_a.db?_txlock=immediate") {
+		t.Errorf("dataSourceName() = %s, want the path with _txlock=immediate", dsn)
+	}
+}
@@ -0,0 +1,315 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// Every change event is also written to the _events table of its database, so clients
+// can page through the changes made while they weren't listening. Sequence numbers come
+// from AUTOINCREMENT and so are never reused, even once the oldest events are pruned.
+
+// Pages of the event log hold DefaultHistoryLimit events unless asked for up to MaxHistoryLimit
+const (
+	DefaultHistoryLimit = 100
+	MaxHistoryLimit     = 1000
+)
+
+// eventLogSchema creates a database's event log; created_at is in Unix milliseconds
+const eventLogSchema = `
+CREATE TABLE IF NOT EXISTS _events (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_type TEXT NOT NULL,
+	collection TEXT NOT NULL,
+	document_id TEXT NOT NULL,
+	event TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_events_created_at ON _events(created_at);
+CREATE INDEX IF NOT EXISTS idx_events_document ON _events(collection, document_id);
+`
+
+// EventHistoryQuery selects a page of a database's event log
+type EventHistoryQuery struct {
+	Since      uint64    // Events logged after this sequence number
+	SinceTime  time.Time // Or, when set, events that happened at or after this time
+	Collection string    // Only this collection's events, when set
+	Types      []string  // Only events of these types, when set
+	Limit      int       // Events per page; 0 selects DefaultHistoryLimit
+}
+
+// eventLog is the broadcaster the catalog sends events to: it writes each event to its
+// database's log, then passes it on with its sequence number
+type eventLog struct {
+	catalog *CatalogDB
+	next    EventBroadcaster // May be nil
+}
+
+// Broadcast logs an event and passes it on; a failure to log it doesn't hold it back
+func (l *eventLog) Broadcast(dbID string, event models.ChangeEvent) {
+	seq, err := l.catalog.logEvent(dbID, event)
+	if err != nil {
+		log.Printf("Failed to log %s event of %s: %v", event.EventType, dbID, err)
+	}
+	event.Seq = seq
+	if l.next != nil {
+		l.next.Broadcast(dbID, event)
+	}
+}
+
+// logEvent appends an event to its database's log and returns its sequence number
+func (c *CatalogDB) logEvent(dbID string, event models.ChangeEvent) (uint64, error) {
+	dbPath, err := c.getDatabasePath(dbID)
+	if err != nil {
+		return 0, err
+	}
+	// Opening a deleted database's file would create it again
+	if _, err := os.Stat(dbPath); err != nil {
+		return 0, fmt.Errorf("database file not found")
+	}
+	db, release, err := c.pool.acquire(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	insert := func() (sql.Result, error) {
+		return db.Exec(`INSERT INTO _events (event_type, collection, document_id, event, created_at) VALUES (?, ?, ?, ?, ?)`,
+			event.EventType, event.Collection, event.DocumentID, string(eventJSON), event.Timestamp.UnixMilli())
+	}
+	result, err := insert()
+	if err != nil && strings.Contains(err.Error(), "no such table") {
+		// Databases created before the event log get it with their first event
+		if _, err := db.Exec(eventLogSchema); err != nil {
+			return 0, fmt.Errorf("failed to create event log: %w", err)
+		}
+		result, err = insert()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to log event: %w", err)
+	}
+	seq, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to log event: %w", err)
+	}
+	return uint64(seq), nil
+}
+
+// EventHistory returns a page of a database's event log, oldest first
+func (c *CatalogDB) EventHistory(dbID string, q EventHistoryQuery) (*models.EventHistoryResponse, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultHistoryLimit
+	}
+	if q.Limit > MaxHistoryLimit {
+		return nil, fmt.Errorf("invalid limit: must be between 1 and %d", MaxHistoryLimit)
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	window, err := readLogWindow(db, q.Since, q.SinceTime)
+	if err != nil {
+		return nil, err
+	}
+	response := &models.EventHistoryResponse{Events: []models.ChangeEvent{}, Next: uint64(window.since), Complete: window.complete}
+	if window.since >= window.last {
+		return response, nil
+	}
+
+	since, last := window.since, window.last
+	query := `SELECT seq, event FROM _events WHERE seq > ? AND seq <= ?`
+	args := []interface{}{since, last}
+	if q.Collection != "" {
+		query += ` AND collection = ?`
+		args = append(args, q.Collection)
+	}
+	if len(q.Types) > 0 {
+		placeholders := make([]string, len(q.Types))
+		for i, eventType := range q.Types {
+			placeholders[i] = "?"
+			args = append(args, eventType)
+		}
+		query += fmt.Sprintf(` AND event_type IN (%s)`, strings.Join(placeholders, ", "))
+	}
+	query += ` ORDER BY seq LIMIT ?`
+	args = append(args, q.Limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seq int64
+		var eventJSON string
+		if err := rows.Scan(&seq, &eventJSON); err != nil {
+			return nil, fmt.Errorf("failed to read event log: %w", err)
+		}
+		if len(response.Events) == q.Limit {
+			response.HasMore = true
+			break
+		}
+		var event models.ChangeEvent
+		if err := json.Unmarshal([]byte(eventJSON), &event); err != nil {
+			return nil, fmt.Errorf("failed to read event %d: %w", seq, err)
+		}
+		event.Seq = uint64(seq)
+		response.Events = append(response.Events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+
+	// A full page continues from its last event; otherwise everything up to the last
+	// event logged has been seen
+	if response.HasMore {
+		response.Next = response.Events[len(response.Events)-1].Seq
+	} else {
+		response.Next = uint64(last)
+	}
+	return response, nil
+}
+
+// logWindow is the part of an event log a read covers: the events after since, up to
+// the last one logged when the read began
+type logWindow struct {
+	since    int64
+	last     int64
+	complete bool // False when events after since were already pruned
+}
+
+// readLogWindow finds the events after a sequence number, or at or after a time when
+// sinceTime is set; a database that hasn't logged anything yet has an empty window
+// The window ends at the last event logged, so events logged while paging are picked up
+// by the next read rather than skipped
+func readLogWindow(db *sql.DB, since uint64, sinceTime time.Time) (logWindow, error) {
+	window := logWindow{since: int64(since), last: int64(since), complete: true}
+
+	// sqlite_sequence keeps the last sequence number even after every event is pruned
+	var last, first sql.NullInt64
+	err := db.QueryRow(`SELECT (SELECT seq FROM sqlite_sequence WHERE name = '_events'), (SELECT MIN(seq) FROM _events)`).Scan(&last, &first)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return window, nil // Nothing has been logged yet
+		}
+		return window, fmt.Errorf("failed to read event log: %w", err)
+	}
+	if !first.Valid {
+		first.Int64 = last.Int64 + 1
+	}
+	pruned := first.Int64 > 1
+	window.last = last.Int64
+
+	if !sinceTime.IsZero() {
+		// Events are found by when they happened; the first one at or after the time sets
+		// where the window starts, and the time may reach back past the pruned events
+		var match sql.NullInt64
+		err := db.QueryRow(`SELECT MIN(seq) FROM _events WHERE created_at >= ?`, sinceTime.UnixMilli()).Scan(&match)
+		if err != nil {
+			return window, fmt.Errorf("failed to read event log: %w", err)
+		}
+		if !match.Valid {
+			match.Int64 = last.Int64 + 1
+		}
+		window.since = match.Int64 - 1
+		window.complete = !pruned || match.Int64 > first.Int64
+	} else {
+		window.complete = window.since+1 >= first.Int64
+	}
+	return window, nil
+}
+
+// redactEventLog removes the document data from the logged events of erased documents
+// Delete events keep the data marking them as erasures
+func redactEventLog(db *sql.DB, collection string, documentIDs []string) error {
+	if len(documentIDs) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(documentIDs))
+	args := []interface{}{collection}
+	for i, id := range documentIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	query := fmt.Sprintf(`
+		UPDATE _events SET event = CASE
+			WHEN json_extract(event, '$.data.erasure') THEN json_remove(event, '$.old_data')
+			ELSE json_remove(event, '$.data', '$.old_data')
+		END
+		WHERE collection = ? AND document_id IN (%s)
+	`, strings.Join(placeholders, ", "))
+	if _, err := db.Exec(query, args...); err != nil && !strings.Contains(err.Error(), "no such table") {
+		return fmt.Errorf("failed to redact event log: %w", err)
+	}
+	return nil
+}
+
+// PruneEventLogs deletes events that happened before cutoff from every database's log,
+// batchSize at a time so writers aren't held up
+// Failures don't stop the run; they are returned together with the events deleted
+func (c *CatalogDB) PruneEventLogs(cutoff time.Time, batchSize int) (int, error) {
+	ids, err := c.listDatabaseIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	var pruned int
+	var errs []error
+	for _, dbID := range ids {
+		n, err := c.pruneEventLog(dbID, cutoff, batchSize)
+		pruned += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", dbID, err))
+		}
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// pruneEventLog deletes a database's events that happened before cutoff, in batches
+func (c *CatalogDB) pruneEventLog(dbID string, cutoff time.Time, batchSize int) (int, error) {
+	dbPath, err := c.getDatabasePath(dbID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return 0, nil // Database file is gone; expiry will clean up the catalog entry
+	}
+	db, release, err := c.pool.acquire(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	var pruned int
+	for {
+		result, err := db.Exec(`DELETE FROM _events WHERE seq IN (SELECT seq FROM _events WHERE created_at < ? ORDER BY seq LIMIT ?)`,
+			cutoff.UnixMilli(), batchSize)
+		if err != nil {
+			if strings.Contains(err.Error(), "no such table") {
+				return pruned, nil
+			}
+			return pruned, fmt.Errorf("failed to prune event log: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		pruned += int(n)
+		if n < int64(batchSize) {
+			return pruned, nil
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestEventHistory(t *testing.T) {
+	recorder := &eventRecorder{}
+	c := newTestCatalogWith(t, Limits{}, recorder)
+
+	const dbID = "db_eventlog"
+	newTestDatabase(t, c, dbID)
+
+	empty, err := c.EventHistory(dbID, EventHistoryQuery{})
+	if err != nil {
+		t.Fatalf("EventHistory() on an empty log error = %v", err)
+	}
+	if len(empty.Events) != 0 || empty.Next != 0 || empty.HasMore || !empty.Complete {
+		t.Errorf("EventHistory() on an empty log = %+v, want no events from 0", empty)
+	}
+
+	for _, name := range []string{"tasks", "notes"} {
+		if _, err := c.CreateSchema(dbID, name, map[string]models.FieldType{"title": models.FieldTypeString}, nil); err != nil {
+			t.Fatalf("CreateSchema(%s) error = %v", name, err)
+		}
+	}
+	doc, err := c.InsertDocument(dbID, "tasks", map[string]interface{}{"title": "draft"}, "")
+	if err != nil {
+		t.Fatalf("InsertDocument() error = %v", err)
+	}
+	if _, err := c.UpdateDocument(dbID, "tasks", doc.ID, map[string]interface{}{"title": "final"}, "", 0); err != nil {
+		t.Fatalf("UpdateDocument() error = %v", err)
+	}
+	if _, err := c.InsertDocument(dbID, "notes", map[string]interface{}{"title": "aside"}, ""); err != nil {
+		t.Fatalf("InsertDocument() error = %v", err)
+	}
+
+	// Broadcast events carry their place in the log
+	for i, event := range recorder.events {
+		if event.Seq != uint64(i+1) {
+			t.Errorf("event %d (%s) seq = %d, want %d", i, event.EventType, event.Seq, i+1)
+		}
+	}
+
+	first, err := c.EventHistory(dbID, EventHistoryQuery{Limit: 3})
+	if err != nil {
+		t.Fatalf("EventHistory() error = %v", err)
+	}
+	if len(first.Events) != 3 || !first.HasMore || first.Next != 3 || !first.Complete {
+		t.Fatalf("EventHistory(limit 3) = %+v, want 3 events continuing from 3", first)
+	}
+	if first.Events[0].EventType != "schema_created" || first.Events[2].EventType != "insert" || first.Events[2].Data["title"] != "draft" {
+		t.Errorf("EventHistory(limit 3) events = %+v, want the schemas then the insert", first.Events)
+	}
+	rest, err := c.EventHistory(dbID, EventHistoryQuery{Since: first.Next, Limit: 3})
+	if err != nil {
+		t.Fatalf("EventHistory() error = %v", err)
+	}
+	if len(rest.Events) != 2 || rest.HasMore || rest.Next != 5 {
+		t.Fatalf("EventHistory(since 3) = %+v, want the last 2 events", rest)
+	}
+	if update := rest.Events[0]; update.EventType != "update" || update.Seq != 4 || update.OldData["title"] != "draft" {
+		t.Errorf("EventHistory(since 3) first event = %+v, want the update with its old data", update)
+	}
+	caughtUp, err := c.EventHistory(dbID, EventHistoryQuery{Since: rest.Next})
+	if err != nil || len(caughtUp.Events) != 0 || caughtUp.Next != 5 {
+		t.Errorf("EventHistory(since 5) = %+v, %v, want no events from 5", caughtUp, err)
+	}
+
+	filtered, err := c.EventHistory(dbID, EventHistoryQuery{Collection: "tasks", Types: []string{"insert", "update"}})
+	if err != nil {
+		t.Fatalf("EventHistory(filtered) error = %v", err)
+	}
+	if len(filtered.Events) != 2 || filtered.Next != 5 {
+		t.Errorf("EventHistory(tasks inserts and updates) = %+v, want 2 events", filtered)
+	}
+
+	if _, err := c.EventHistory(dbID, EventHistoryQuery{Limit: MaxHistoryLimit + 1}); err == nil || !strings.Contains(err.Error(), "invalid limit") {
+		t.Errorf("EventHistory(limit %d) error = %v, want invalid limit", MaxHistoryLimit+1, err)
+	}
+
+	// Pruning keeps later events and the sequence, and tells clients that asked for
+	// pruned events they missed some
+	pruned, err := c.PruneEventLogs(time.Now().Add(time.Hour), 2)
+	if err != nil || pruned != 5 {
+		t.Fatalf("PruneEventLogs() = %d, %v, want 5 events pruned", pruned, err)
+	}
+	stale, err := c.EventHistory(dbID, EventHistoryQuery{Since: 2})
+	if err != nil || len(stale.Events) != 0 || stale.Complete || stale.Next != 5 {
+		t.Errorf("EventHistory(since 2) after pruning = %+v, %v, want an incomplete empty page from 5", stale, err)
+	}
+	if _, err := c.InsertDocument(dbID, "tasks", map[string]interface{}{"title": "later"}, ""); err != nil {
+		t.Fatalf("InsertDocument() error = %v", err)
+	}
+	current, err := c.EventHistory(dbID, EventHistoryQuery{Since: 5})
+	if err != nil || len(current.Events) != 1 || current.Events[0].Seq != 6 || !current.Complete {
+		t.Errorf("EventHistory(since 5) after pruning = %+v, %v, want the new event as 6", current, err)
+	}
+	byTime, err := c.EventHistory(dbID, EventHistoryQuery{SinceTime: time.Now().Add(-time.Hour)})
+	if err != nil || len(byTime.Events) != 1 || byTime.Complete {
+		t.Errorf("EventHistory(an hour ago) after pruning = %+v, %v, want the new event, incomplete", byTime, err)
+	}
+	future, err := c.EventHistory(dbID, EventHistoryQuery{SinceTime: time.Now().Add(time.Hour)})
+	if err != nil || len(future.Events) != 0 || future.Next != 6 || !future.Complete {
+		t.Errorf("EventHistory(in an hour) = %+v, %v, want no events from 6", future, err)
+	}
+}
+
+func TestEventHistory_Erasure(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_eventlogerase"
+	newTestDatabase(t, c, dbID)
+	if _, err := c.CreateSchema(dbID, "users", map[string]models.FieldType{"email": models.FieldTypeString}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	for _, email := range []string{"ana@example.com", "bo@example.com"} {
+		if _, err := c.InsertDocument(dbID, "users", map[string]interface{}{"email": email}, ""); err != nil {
+			t.Fatalf("InsertDocument() error = %v", err)
+		}
+	}
+	if _, err := c.EraseSubject(dbID, "email", "ana@example.com"); err != nil {
+		t.Fatalf("EraseSubject() error = %v", err)
+	}
+
+	history, err := c.EventHistory(dbID, EventHistoryQuery{Collection: "users"})
+	if err != nil {
+		t.Fatalf("EventHistory() error = %v", err)
+	}
+	if len(history.Events) != 4 {
+		t.Fatalf("EventHistory() = %d events, want schema, 2 inserts and the erasure", len(history.Events))
+	}
+	if ana := history.Events[1]; ana.Data != nil {
+		t.Errorf("erased document's insert event data = %v, want it removed", ana.Data)
+	}
+	if bo := history.Events[2]; bo.Data["email"] != "bo@example.com" {
+		t.Errorf("other document's insert event data = %v, want it kept", bo.Data)
+	}
+	if erasure := history.Events[3]; erasure.EventType != "delete" || erasure.Data["erasure"] != true || erasure.OldData != nil {
+		t.Errorf("erasure event = %+v, want a delete marked as an erasure without the old data", erasure)
+	}
+}
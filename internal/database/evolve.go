@@ -0,0 +1,293 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// UpdateSchema adds and removes fields of an existing schema
+// Removed fields are stripped from stored documents. Added fields are written into
+// existing documents only when a backfill value is given; documents without them stay
+// readable, but must supply them on their next update like any other field
+func (c *CatalogDB) UpdateSchema(dbID string, name string, req *models.UpdateSchemaRequest) (*models.Schema, error) {
+	schema, err := c.GetSchema(dbID, name)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("schema not found")
+	}
+
+	fields, err := evolveFields(schema.Fields, req)
+	if err != nil {
+		return nil, err
+	}
+	writeOnly, err := WriteOnlyFields(req.FieldVisibility)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRemovable(schema, req.RemoveFields, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := c.limits.CheckFields(len(fields)); err != nil {
+		return nil, err
+	}
+
+	// SQLite can't drop a column an index covers, so indexes must be deleted first
+	indexes, err := c.ListIndexes(dbID, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range req.RemoveFields {
+		if index := indexUsingField(indexes, field); index != "" {
+			return nil, fmt.Errorf("invalid schema update: field %s is used by index %s", field, index)
+		}
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, field := range req.RemoveFields {
+		if err := dropFieldColumn(tx, name, field); err != nil {
+			return nil, err
+		}
+	}
+
+	sizeDelta, err := rewriteDocuments(tx, name, req.RemoveFields, req.Backfill, c.compression)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index added fields after backfilling so each index is built once
+	if err := ensureFieldIndexes(tx, name, req.AddFields); err != nil {
+		return nil, err
+	}
+	if sizeDelta > 0 {
+		if err := c.reserveQuota(dbID, sizeDelta); err != nil {
+			return nil, err
+		}
+	}
+
+	fieldsJSON, err := json.Marshal(fields)
+	if err != nil {
+		c.releaseQuota(dbID, sizeDelta)
+		return nil, fmt.Errorf("failed to marshal fields: %w", err)
+	}
+	oldFieldsJSON, _ := json.Marshal(schema.Fields)
+
+	// Removed fields are no longer deprecated or masked; added write_only fields are
+	// masked before documents can be read with them
+	deprecated := maps.Clone(schema.Deprecated)
+	for _, field := range req.RemoveFields {
+		delete(deprecated, field)
+	}
+	deprecatedJSON, err := marshalDeprecated(deprecated)
+	if err != nil {
+		c.releaseQuota(dbID, sizeDelta)
+		return nil, err
+	}
+	oldDeprecatedJSON, _ := marshalDeprecated(schema.Deprecated)
+	masked := slices.DeleteFunc(slices.Clone(schema.Masked), func(field string) bool {
+		return slices.Contains(req.RemoveFields, field)
+	})
+	masked = slices.Compact(slices.Sorted(slices.Values(append(masked, writeOnly...))))
+
+	query := `UPDATE schemas SET fields = ?, deprecated_fields = ?, masked_fields = ? WHERE database_id = ? AND name = ?`
+	if _, err := c.db.Exec(query, string(fieldsJSON), deprecatedJSON, marshalMasked(masked), dbID, name); err != nil {
+		c.releaseQuota(dbID, sizeDelta)
+		return nil, fmt.Errorf("failed to update schema: %w", err)
+	}
+	defer c.schemas.invalidate(dbID, name)
+
+	if err := tx.Commit(); err != nil {
+		// Rollback: restore the catalog entry and give back the quota
+		c.db.Exec(query, string(oldFieldsJSON), oldDeprecatedJSON, marshalMasked(schema.Masked), dbID, name)
+		c.releaseQuota(dbID, sizeDelta)
+		return nil, fmt.Errorf("failed to commit schema update: %w", err)
+	}
+	if sizeDelta < 0 {
+		c.releaseQuota(dbID, -sizeDelta)
+	}
+
+	if c.capabilities.FTS5 && !reflect.DeepEqual(stringFields(schema.Fields), stringFields(fields)) {
+		if err := rebuildSearchIndex(db, name, fields); err != nil {
+			// Log but don't fail; startup migration recreates a missing index
+		}
+	}
+
+	oldFields := schema.Fields
+	schema.Fields = fields
+	schema.Deprecated = deprecated
+	schema.Masked = masked
+
+	// Broadcast schema update event
+	if c.broadcaster != nil {
+		event := models.ChangeEvent{
+			EventType:  "schema_updated",
+			DatabaseID: dbID,
+			Collection: name,
+			DocumentID: "",
+			Data: map[string]interface{}{
+				"schema_name":    name,
+				"fields":         fields,
+				"added_fields":   addedFields(oldFields, fields),
+				"removed_fields": removedFields(req.RemoveFields),
+			},
+			Timestamp: time.Now(),
+		}
+		c.broadcaster.Broadcast(dbID, event)
+	}
+
+	return schema, nil
+}
+
+// evolveFields validates a schema update and returns the resulting fields
+func evolveFields(current map[string]models.FieldType, req *models.UpdateSchemaRequest) (map[string]models.FieldType, error) {
+	if len(req.AddFields) == 0 && len(req.RemoveFields) == 0 {
+		return nil, fmt.Errorf("invalid schema update: no fields to add or remove")
+	}
+
+	fields := make(map[string]models.FieldType, len(current))
+	for fieldName, fieldType := range current {
+		fields[fieldName] = fieldType
+	}
+
+	for _, fieldName := range req.RemoveFields {
+		if _, exists := fields[fieldName]; !exists {
+			return nil, fmt.Errorf("invalid schema update: field %s does not exist", fieldName)
+		}
+		delete(fields, fieldName)
+	}
+
+	for fieldName, fieldType := range req.AddFields {
+		// Validate field names to prevent SQL injection; they are also used in JSON paths
+		if err := ValidateIdentifier(fieldName); err != nil {
+			return nil, fmt.Errorf("invalid field name %s: %w", fieldName, err)
+		}
+		if !fieldType.IsValid() {
+			return nil, fmt.Errorf("invalid field type for %s: %s", fieldName, fieldType)
+		}
+		if _, exists := current[fieldName]; exists {
+			return nil, fmt.Errorf("invalid schema update: field %s already exists", fieldName)
+		}
+		fields[fieldName] = fieldType
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("schema must have at least one field")
+	}
+
+	// Backfill values are type-checked like document data
+	backfillSchema := &models.Schema{Fields: make(map[string]models.FieldType, len(req.Backfill))}
+	for fieldName := range req.Backfill {
+		fieldType, added := req.AddFields[fieldName]
+		if !added {
+			return nil, fmt.Errorf("invalid schema update: backfill field %s is not being added", fieldName)
+		}
+		backfillSchema.Fields[fieldName] = fieldType
+	}
+	if err := models.ValidateDocument(req.Backfill, backfillSchema); err != nil {
+		return nil, fmt.Errorf("invalid backfill: %w", err)
+	}
+
+	return fields, nil
+}
+
+// rewriteDocuments strips removed fields from and backfills added fields into every document
+// Rewritten documents are compressed again under the same settings as new writes
+// Returns the change in stored data size
+func rewriteDocuments(tx *sql.Tx, collection string, remove []string, backfill map[string]interface{}, compression Compression) (int64, error) {
+	quotedCollection := QuoteIdentifier(collection)
+
+	var before int64
+	sizeQuery := fmt.Sprintf(`SELECT COALESCE(SUM(LENGTH(data)), 0) FROM %s`, quotedCollection)
+	if err := tx.QueryRow(sizeQuery).Scan(&before); err != nil {
+		return 0, fmt.Errorf("failed to measure collection: %w", err)
+	}
+
+	if len(remove) > 0 {
+		paths := make([]string, len(remove))
+		args := make([]interface{}, len(remove))
+		for i, fieldName := range remove {
+			paths[i] = "?"
+			args[i] = "$." + fieldName
+		}
+		removeSQL := fmt.Sprintf(`UPDATE %s SET data = %s(json_remove(%s, %s), ?, ?), revision = revision + 1`,
+			quotedCollection, packFunction, dataExpr("data"), strings.Join(paths, ", "))
+		args = append(args, compression.Threshold, compression.Algorithm)
+		if _, err := tx.Exec(removeSQL, args...); err != nil {
+			return 0, fmt.Errorf("failed to remove fields from documents: %w", err)
+		}
+	}
+
+	if len(backfill) > 0 {
+		pairs := make([]string, 0, len(backfill))
+		args := make([]interface{}, 0, 2*len(backfill))
+		for fieldName, value := range backfill {
+			valueJSON, err := json.Marshal(value)
+			if err != nil {
+				return 0, fmt.Errorf("failed to marshal backfill value for %s: %w", fieldName, err)
+			}
+			pairs = append(pairs, "?, json(?)")
+			args = append(args, "$."+fieldName, string(valueJSON))
+		}
+		backfillSQL := fmt.Sprintf(`UPDATE %s SET data = %s(json_set(%s, %s), ?, ?), revision = revision + 1`,
+			quotedCollection, packFunction, dataExpr("data"), strings.Join(pairs, ", "))
+		args = append(args, compression.Threshold, compression.Algorithm)
+		if _, err := tx.Exec(backfillSQL, args...); err != nil {
+			return 0, fmt.Errorf("failed to backfill documents: %w", err)
+		}
+	}
+
+	var after int64
+	if err := tx.QueryRow(sizeQuery).Scan(&after); err != nil {
+		return 0, fmt.Errorf("failed to measure collection: %w", err)
+	}
+	return after - before, nil
+}
+
+// rebuildSearchIndex recreates a collection's full-text index for a new set of fields
+func rebuildSearchIndex(db *sql.DB, collection string, fields map[string]models.FieldType) error {
+	_, err := db.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %s`, QuoteIdentifier(searchTableName(collection))))
+	if err != nil {
+		return fmt.Errorf("failed to drop search index: %w", err)
+	}
+	return ensureSearchIndex(db, collection, fields)
+}
+
+// removedFields returns the sorted names of removed fields
+func removedFields(names []string) []string {
+	removed := append([]string{}, names...)
+	sort.Strings(removed)
+	return removed
+}
+
+// addedFields returns the sorted names of fields present in after but not before
+func addedFields(before, after map[string]models.FieldType) []string {
+	added := []string{}
+	for fieldName := range after {
+		if _, exists := before[fieldName]; !exists {
+			added = append(added, fieldName)
+		}
+	}
+	sort.Strings(added)
+	return added
+}
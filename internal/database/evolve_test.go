@@ -0,0 +1,92 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestEvolveFields(t *testing.T) {
+	current := map[string]models.FieldType{
+		"name": models.FieldTypeString,
+		"age":  models.FieldTypeNumber,
+	}
+
+	tests := []struct {
+		name    string
+		req     models.UpdateSchemaRequest
+		want    map[string]models.FieldType
+		wantErr string
+	}{
+		{
+			name: "add and remove",
+			req: models.UpdateSchemaRequest{
+				AddFields:    map[string]models.FieldType{"email": models.FieldTypeString},
+				RemoveFields: []string{"age"},
+				Backfill:     map[string]interface{}{"email": ""},
+			},
+			want: map[string]models.FieldType{"name": models.FieldTypeString, "email": models.FieldTypeString},
+		},
+		{name: "empty update", req: models.UpdateSchemaRequest{}, wantErr: "no fields to add or remove"},
+		{
+			name:    "remove unknown field",
+			req:     models.UpdateSchemaRequest{RemoveFields: []string{"email"}},
+			wantErr: "does not exist",
+		},
+		{
+			name:    "add existing field",
+			req:     models.UpdateSchemaRequest{AddFields: map[string]models.FieldType{"age": models.FieldTypeString}},
+			wantErr: "already exists",
+		},
+		{
+			name:    "remove every field",
+			req:     models.UpdateSchemaRequest{RemoveFields: []string{"name", "age"}},
+			wantErr: "at least one field",
+		},
+		{
+			name: "backfill for a field not being added",
+			req: models.UpdateSchemaRequest{
+				AddFields: map[string]models.FieldType{"email": models.FieldTypeString},
+				Backfill:  map[string]interface{}{"name": "x"},
+			},
+			wantErr: "not being added",
+		},
+		{
+			name: "backfill of the wrong type",
+			req: models.UpdateSchemaRequest{
+				AddFields: map[string]models.FieldType{"active": models.FieldTypeBool},
+				Backfill:  map[string]interface{}{"active": "yes"},
+			},
+			wantErr: "invalid backfill",
+		},
+		{
+			name:    "unsafe field name",
+			req:     models.UpdateSchemaRequest{AddFields: map[string]models.FieldType{"a.b": models.FieldTypeString}},
+			wantErr: "invalid field name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evolveFields(current, &tt.req)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("evolveFields() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("evolveFields() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("evolveFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if len(current) != 2 {
+		t.Errorf("evolveFields modified the current fields: %v", current)
+	}
+}
@@ -0,0 +1,352 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"jsondrop/internal/models"
+)
+
+// Schema fields are mirrored into generated columns holding json_extract(data, '$.field'),
+// each with an index, so field filters, sorts and joins don't parse every document.
+// Columns created with the table are STORED; SQLite can only add VIRTUAL generated
+// columns to an existing table, so fields added later and migrated tables use those.
+// Both kinds are indexed the same way.
+const (
+	storedColumn  = "STORED"
+	virtualColumn = "VIRTUAL"
+)
+
+// fieldColumnPrefix keeps generated column names apart from the built-in columns
+const fieldColumnPrefix = "field_"
+
+// fieldColumnName returns the name of the generated column mirroring a schema field
+func fieldColumnName(field string) string {
+	return fieldColumnPrefix + field
+}
+
+// fieldIndexName returns the name of the index on a field's generated column
+func fieldIndexName(collection string, field string) string {
+	return fmt.Sprintf("idx_%s_%s", collection, fieldColumnName(field))
+}
+
+// fieldColumnDefinition returns the type and expression of a field's generated column
+// Field names come from schemas, where they are validated identifiers
+func fieldColumnDefinition(field string, storage string) string {
+	return fmt.Sprintf("GENERATED ALWAYS AS (json_extract(%s, '$.%s')) %s", dataExpr("data"), field, storage)
+}
+
+// legacyFieldExpression is how generated columns read fields before documents could be
+// compressed; such columns can't read compressed documents and are rebuilt on startup
+func legacyFieldExpression(field string) string {
+	return fmt.Sprintf("json_extract(data, '$.%s')", field)
+}
+
+// sortedFields returns field names in a stable order so DDL is deterministic
+func sortedFields(fields map[string]models.FieldType) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ensureFieldIndexes adds any missing generated columns and indexes for schema fields
+func ensureFieldIndexes(db queryExecer, collection string, fields map[string]models.FieldType) error {
+	for _, field := range sortedFields(fields) {
+		if err := ensureColumn(db, collection, fieldColumnName(field), fieldColumnDefinition(field, virtualColumn)); err != nil {
+			return err
+		}
+		if err := createFieldIndex(db, collection, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upgradeFieldColumns rebuilds generated columns that still read fields with legacyFieldExpression
+// SQLite can't drop a column an index uses, so the collection's user-declared indexes are
+// dropped first and recreated afterwards
+func upgradeFieldColumns(db *sql.DB, collection string, fields map[string]models.FieldType, indexes []*models.Index) error {
+	var tableSQL string
+	if err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?`, collection).Scan(&tableSQL); err != nil {
+		return fmt.Errorf("failed to inspect table: %w", err)
+	}
+
+	var legacy []string
+	for _, field := range sortedFields(fields) {
+		if strings.Contains(tableSQL, legacyFieldExpression(field)) {
+			legacy = append(legacy, field)
+		}
+	}
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, index := range indexes {
+		if err := dropUserIndex(tx, collection, index.Name); err != nil {
+			return err
+		}
+	}
+	for _, field := range legacy {
+		if err := dropFieldColumn(tx, collection, field); err != nil {
+			return err
+		}
+	}
+	if err := ensureFieldIndexes(tx, collection, fields); err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if err := createUserIndex(tx, collection, index.Name, indexKeys(index)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// createFieldIndex indexes a field's generated column
+func createFieldIndex(db execer, collection string, field string) error {
+	indexSQL := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)",
+		QuoteIdentifier(fieldIndexName(collection, field)), QuoteIdentifier(collection), QuoteIdentifier(fieldColumnName(field)))
+	if _, err := db.Exec(indexSQL); err != nil {
+		return fmt.Errorf("failed to create index on %s: %w", field, err)
+	}
+	return nil
+}
+
+// dropFieldIndex drops a field's index; SQLite can't drop an indexed column
+func dropFieldIndex(db execer, collection string, field string) error {
+	dropSQL := fmt.Sprintf("DROP INDEX IF EXISTS %s", QuoteIdentifier(fieldIndexName(collection, field)))
+	if _, err := db.Exec(dropSQL); err != nil {
+		return fmt.Errorf("failed to drop index on %s: %w", field, err)
+	}
+	return nil
+}
+
+// dropFieldColumn drops a removed field's index and generated column
+func dropFieldColumn(db execer, collection string, field string) error {
+	if err := dropFieldIndex(db, collection, field); err != nil {
+		return err
+	}
+	dropSQL := fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", QuoteIdentifier(collection), QuoteIdentifier(fieldColumnName(field)))
+	if _, err := db.Exec(dropSQL); err != nil {
+		return fmt.Errorf("failed to drop column for %s: %w", field, err)
+	}
+	return nil
+}
+
+// Filter matches documents whose field equals any of the given values, or matches any of
+// them under Op. Values are compared as the field's schema type; values that don't parse
+// as it never match
+type Filter struct {
+	Field     string
+	Type      models.FieldType
+	Op        FilterOp
+	Values    []string
+	MatchNull bool // Equality also matches documents where the field is missing or null
+}
+
+// FilterOp is how a filter compares fields with its values
+type FilterOp string
+
+// Filter operators, given as field.op=value query parameters
+// The string operators ignore case, only of ASCII letters on SQLite
+const (
+	FilterEquals   FilterOp = ""         // field=value, or field=null for a missing or null field
+	FilterExists   FilterOp = "exists"   // field.exists=true for present fields, even null; false for missing ones
+	FilterLike     FilterOp = "like"     // LIKE pattern: % matches any run of characters, _ any one, \ escapes
+	FilterContains FilterOp = "contains" // The value appears anywhere in the field
+	FilterPrefix   FilterOp = "prefix"   // The field starts with the value
+	FilterIEquals  FilterOp = "ieq"      // The field equals the value
+)
+
+// NullValue is the equality filter value matching missing and null fields
+const NullValue = "null"
+
+// ParseFilterParam reads a filter query parameter, field or field.op, and its values
+// against a schema. Returns false for parameters naming no schema field, which aren't filters
+func ParseFilterParam(key string, values []string, fields map[string]models.FieldType) (Filter, bool, error) {
+	field, op, _ := strings.Cut(key, ".")
+	fieldType, exists := fields[field]
+	if !exists {
+		return Filter{}, false, nil
+	}
+	filter := Filter{Field: field, Type: fieldType, Op: FilterOp(op), Values: values}
+	switch filter.Op {
+	case FilterEquals:
+		if strings.HasSuffix(key, ".") {
+			return Filter{}, false, fmt.Errorf("invalid filter %s: operator is missing", key)
+		}
+		filter.Values = nil
+		for _, value := range values {
+			if value == NullValue {
+				filter.MatchNull = true
+			} else {
+				filter.Values = append(filter.Values, value)
+			}
+		}
+		return filter, true, nil
+	case FilterExists:
+		filter.Values = make([]string, len(values))
+		for i, value := range values {
+			exists, err := strconv.ParseBool(value)
+			if err != nil {
+				return Filter{}, false, fmt.Errorf("invalid filter %s: %q must be true or false", key, value)
+			}
+			filter.Values[i] = strconv.FormatBool(exists)
+		}
+		return filter, true, nil
+	case FilterLike, FilterContains, FilterPrefix, FilterIEquals:
+		if fieldType != models.FieldTypeString {
+			return Filter{}, false, fmt.Errorf("invalid filter %s: %s applies to string fields, and %s is a %s", key, op, field, fieldType)
+		}
+		for _, value := range values {
+			if filter.Op == FilterLike && strings.HasSuffix(strings.ReplaceAll(value, `\\`, ""), `\`) {
+				return Filter{}, false, fmt.Errorf("invalid filter %s: pattern ends with an escape", key)
+			}
+		}
+		return filter, true, nil
+	default:
+		return Filter{}, false, fmt.Errorf("invalid filter %s: unknown operator %q, use exists, like, contains, prefix or ieq", key, op)
+	}
+}
+
+// Existence reports whether an exists filter matches present fields and missing ones
+func (f Filter) Existence() (present bool, missing bool) {
+	return slices.Contains(f.Values, "true"), slices.Contains(f.Values, "false")
+}
+
+// LikePattern returns the LIKE pattern, escaped with backslashes, that a string filter
+// value matches under op; like values are patterns already
+func LikePattern(op FilterOp, value string) string {
+	switch op {
+	case FilterContains:
+		return "%" + likeEscaper.Replace(value) + "%"
+	case FilterPrefix:
+		return likeEscaper.Replace(value) + "%"
+	default:
+		return value
+	}
+}
+
+// likeEscaper escapes LIKE wildcards so they match themselves
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// filterClause builds SQL conditions for field filters against their generated columns
+// Filters on different fields are combined with AND
+func filterClause(prefix string, filters []Filter) (string, []interface{}) {
+	var clause strings.Builder
+	var args []interface{}
+	for _, filter := range filters {
+		column := prefix + fieldColumnName(filter.Field)
+		switch filter.Op {
+		case FilterEquals:
+		case FilterExists:
+			clause.WriteString(" AND " + existsCondition(prefix, filter))
+			continue
+		default:
+			condition, values := stringFilterCondition(column, filter)
+			clause.WriteString(" AND " + condition)
+			args = append(args, values...)
+			continue
+		}
+
+		// The generated column is NULL both for missing fields and JSON null
+		var conditions []string
+		values := filterArgs(filter)
+		if len(values) > 0 {
+			placeholders := make([]string, len(values))
+			for i := range values {
+				placeholders[i] = "?"
+			}
+			conditions = append(conditions, fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", ")))
+			args = append(args, values...)
+		}
+		if filter.MatchNull {
+			conditions = append(conditions, column+" IS NULL")
+		}
+		switch len(conditions) {
+		case 0:
+			clause.WriteString(" AND 0")
+		case 1:
+			clause.WriteString(" AND " + conditions[0])
+		default:
+			clause.WriteString(" AND (" + strings.Join(conditions, " OR ") + ")")
+		}
+	}
+	return clause.String(), args
+}
+
+// existsCondition builds the SQL condition for an exists filter
+// json_type tells missing fields, which it returns NULL for, from JSON null
+func existsCondition(prefix string, filter Filter) string {
+	present, missing := filter.Existence()
+	typeExpr := fmt.Sprintf("json_type(%s, '$.%s')", dataExpr(prefix+"data"), filter.Field)
+	switch {
+	case present && missing:
+		return "1"
+	case present:
+		return typeExpr + " IS NOT NULL"
+	case missing:
+		return typeExpr + " IS NULL"
+	default:
+		return "0"
+	}
+}
+
+// stringFilterCondition builds the SQL condition for a string filter operator on column
+// SQLite's LIKE and lower() ignore the case of ASCII letters only
+func stringFilterCondition(column string, filter Filter) (string, []interface{}) {
+	if len(filter.Values) == 0 {
+		return "0", nil
+	}
+	conditions := make([]string, len(filter.Values))
+	args := make([]interface{}, len(filter.Values))
+	for i, value := range filter.Values {
+		if filter.Op == FilterIEquals {
+			conditions[i] = fmt.Sprintf("lower(%s) = lower(?)", column)
+			args[i] = value
+			continue
+		}
+		conditions[i] = fmt.Sprintf(`%s LIKE ? ESCAPE '\'`, column)
+		args[i] = LikePattern(filter.Op, value)
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// filterArgs converts filter values to the SQL values json_extract yields for the field's type
+// Booleans are stored as 1 and 0
+func filterArgs(filter Filter) []interface{} {
+	var args []interface{}
+	for _, value := range filter.Values {
+		switch filter.Type {
+		case models.FieldTypeNumber:
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				args = append(args, n)
+			}
+		case models.FieldTypeBool:
+			if b, err := strconv.ParseBool(value); err == nil {
+				if b {
+					args = append(args, 1)
+				} else {
+					args = append(args, 0)
+				}
+			}
+		default:
+			args = append(args, value)
+		}
+	}
+	return args
+}
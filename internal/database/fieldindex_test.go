@@ -0,0 +1,196 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestFilterClause(t *testing.T) {
+	tests := []struct {
+		name       string
+		filters    []Filter
+		wantClause string
+		wantArgs   []interface{}
+	}{
+		{name: "no filters", filters: nil, wantClause: "", wantArgs: nil},
+		{
+			name:       "string values",
+			filters:    []Filter{{Field: "status", Type: models.FieldTypeString, Values: []string{"open", "closed"}}},
+			wantClause: " AND d.field_status IN (?, ?)",
+			wantArgs:   []interface{}{"open", "closed"},
+		},
+		{
+			name: "number and bool",
+			filters: []Filter{
+				{Field: "count", Type: models.FieldTypeNumber, Values: []string{"3", "x"}},
+				{Field: "done", Type: models.FieldTypeBool, Values: []string{"true", "0"}},
+			},
+			wantClause: " AND d.field_count IN (?) AND d.field_done IN (?, ?)",
+			wantArgs:   []interface{}{3.0, 1, 0},
+		},
+		{
+			name: "string operators",
+			filters: []Filter{
+				{Field: "title", Type: models.FieldTypeString, Op: FilterContains, Values: []string{"50%", "a_b"}},
+				{Field: "email", Type: models.FieldTypeString, Op: FilterIEquals, Values: []string{"Bob@Example.com"}},
+			},
+			wantClause: ` AND (d.field_title LIKE ? ESCAPE '\' OR d.field_title LIKE ? ESCAPE '\') AND (lower(d.field_email) = lower(?))`,
+			wantArgs:   []interface{}{`%50\%%`, `%a\_b%`, "Bob@Example.com"},
+		},
+		{
+			name:       "no parseable values",
+			filters:    []Filter{{Field: "count", Type: models.FieldTypeNumber, Values: []string{"many"}}},
+			wantClause: " AND 0",
+			wantArgs:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clause, args := filterClause("d.", tt.filters)
+			if clause != tt.wantClause {
+				t.Errorf("filterClause() clause = %q, want %q", clause, tt.wantClause)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("filterClause() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestParseFilterParam(t *testing.T) {
+	fields := map[string]models.FieldType{"name": models.FieldTypeString, "age": models.FieldTypeNumber}
+	tests := []struct {
+		key     string
+		values  []string
+		want    Filter
+		wantOK  bool
+		wantErr bool
+	}{
+		{key: "name", values: []string{"ann"}, want: Filter{Field: "name", Type: models.FieldTypeString, Values: []string{"ann"}}, wantOK: true},
+		{key: "name.prefix", values: []string{"an"}, want: Filter{Field: "name", Type: models.FieldTypeString, Op: FilterPrefix, Values: []string{"an"}}, wantOK: true},
+		{key: "name.like", values: []string{`50\%`}, want: Filter{Field: "name", Type: models.FieldTypeString, Op: FilterLike, Values: []string{`50\%`}}, wantOK: true},
+		{key: "limit", values: []string{"10"}},
+		{key: "name.like", values: []string{`ends\`}, wantErr: true},
+		{key: "name.regex", values: []string{"a"}, wantErr: true},
+		{key: "name", values: []string{"null", "ann"}, want: Filter{Field: "name", Type: models.FieldTypeString, Values: []string{"ann"}, MatchNull: true}, wantOK: true},
+		{key: "age.exists", values: []string{"1"}, want: Filter{Field: "age", Type: models.FieldTypeNumber, Op: FilterExists, Values: []string{"true"}}, wantOK: true},
+		{key: "age.exists", values: []string{"yes"}, wantErr: true},
+		{key: "name.", values: []string{"a"}, wantErr: true},
+		{key: "age.contains", values: []string{"3"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, ok, err := ParseFilterParam(tt.key, tt.values, fields)
+		if (err != nil) != tt.wantErr || ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFilterParam(%q, %q) = %+v, %v, %v", tt.key, tt.values, got, ok, err)
+		}
+	}
+}
+
+func TestStringFilters(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_filters"
+	newTestDatabase(t, c, dbID)
+	if _, err := c.CreateSchema(dbID, "people", map[string]models.FieldType{"email": models.FieldTypeString}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	for _, email := range []string{"Bob@Example.com", "ann@example.org", "100%_off@shop.com"} {
+		if _, err := c.InsertDocument(dbID, "people", map[string]interface{}{"email": email}, ""); err != nil {
+			t.Fatalf("InsertDocument() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		op    FilterOp
+		value string
+		want  int
+	}{
+		{FilterIEquals, "bob@example.com", 1},
+		{FilterContains, "EXAMPLE", 2},
+		{FilterContains, "%_", 1},
+		{FilterPrefix, "ann@", 1},
+		{FilterLike, "%.com", 2},
+		{FilterLike, `100\%%`, 1},
+	}
+	for _, tt := range tests {
+		filter := Filter{Field: "email", Type: models.FieldTypeString, Op: tt.op, Values: []string{tt.value}}
+		docs, err := c.QueryDocuments(dbID, "people", 0, 0, []Filter{filter}, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("QueryDocuments(%s %q) error = %v", tt.op, tt.value, err)
+		}
+		if len(docs) != tt.want {
+			t.Errorf("QueryDocuments(%s %q) = %d documents, want %d", tt.op, tt.value, len(docs), tt.want)
+		}
+	}
+}
+
+func TestNullAndExistsFilters(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_nulls"
+	newTestDatabase(t, c, dbID)
+	if _, err := c.CreateSchema(dbID, "people", map[string]models.FieldType{"name": models.FieldTypeString}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	// ann predates the nick field, so it's missing; bob's nick is set to null directly
+	if _, err := c.InsertDocument(dbID, "people", map[string]interface{}{"name": "ann"}, ""); err != nil {
+		t.Fatalf("InsertDocument() error = %v", err)
+	}
+	bob, err := c.InsertDocument(dbID, "people", map[string]interface{}{"name": "bob"}, "")
+	if err != nil {
+		t.Fatalf("InsertDocument() error = %v", err)
+	}
+	if _, err := c.UpdateSchema(dbID, "people", &models.UpdateSchemaRequest{AddFields: map[string]models.FieldType{"nick": models.FieldTypeString}}); err != nil {
+		t.Fatalf("UpdateSchema() error = %v", err)
+	}
+	if _, err := c.InsertDocument(dbID, "people", map[string]interface{}{"name": "cy", "nick": "null"}, ""); err != nil {
+		t.Fatalf("InsertDocument() error = %v", err)
+	}
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	_, err = db.Exec(`UPDATE people SET data = json_set(data, '$.nick', json('null')) WHERE id = ?`, bob.ID)
+	release()
+	if err != nil {
+		t.Fatalf("setting null error = %v", err)
+	}
+
+	fields := map[string]models.FieldType{"name": models.FieldTypeString, "nick": models.FieldTypeString}
+	tests := []struct {
+		key    string
+		values []string
+		want   []string
+	}{
+		{"nick", []string{"null"}, []string{"ann", "bob"}},
+		{"nick.exists", []string{"true"}, []string{"bob", "cy"}},
+		{"nick.exists", []string{"false"}, []string{"ann"}},
+		{"nick.ieq", []string{"null"}, []string{"cy"}},
+	}
+	for _, tt := range tests {
+		filter, _, err := ParseFilterParam(tt.key, tt.values, fields)
+		if err != nil {
+			t.Fatalf("ParseFilterParam(%s) error = %v", tt.key, err)
+		}
+		docs, err := c.QueryDocuments(dbID, "people", 0, 0, []Filter{filter}, nil, []SortKey{{Field: "name"}}, nil)
+		if err != nil {
+			t.Fatalf("QueryDocuments(%s=%v) error = %v", tt.key, tt.values, err)
+		}
+		var names []string
+		for _, doc := range docs {
+			names = append(names, doc.Data["name"].(string))
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("QueryDocuments(%s=%v) = %v, want %v", tt.key, tt.values, names, tt.want)
+		}
+	}
+}
+
+func TestFieldIndexName(t *testing.T) {
+	if got, want := fieldIndexName("tasks", "status"), "idx_tasks_field_status"; got != want {
+		t.Errorf("fieldIndexName() = %q, want %q", got, want)
+	}
+}
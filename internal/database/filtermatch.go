@@ -0,0 +1,99 @@
+package database
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"jsondrop/internal/models"
+)
+
+// MatchFilters reports whether document data matches every field filter, as a query
+// would select it. Values that don't parse as the field's type never match
+func MatchFilters(data map[string]interface{}, filters []Filter) bool {
+	for _, filter := range filters {
+		field, exists := data[filter.Field]
+		if filter.Op == FilterExists {
+			present, missing := filter.Existence()
+			if (exists && !present) || (!exists && !missing) {
+				return false
+			}
+			continue
+		}
+		if filter.MatchNull && field == nil {
+			continue
+		}
+		if !slices.ContainsFunc(filter.Values, func(value string) bool {
+			if filter.Op != FilterEquals {
+				text, ok := field.(string)
+				return ok && stringFilterMatches(filter.Op, text, value)
+			}
+			return filterMatches(filter.Type, field, value)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterMatches compares a document field with one filter value of the field's type
+func filterMatches(fieldType models.FieldType, field interface{}, value string) bool {
+	switch fieldType {
+	case models.FieldTypeNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		f, ok := field.(float64)
+		return err == nil && ok && f == n
+	case models.FieldTypeBool:
+		b, err := strconv.ParseBool(value)
+		f, ok := field.(bool)
+		return err == nil && ok && f == b
+	default:
+		f, ok := field.(string)
+		return ok && f == value
+	}
+}
+
+// stringFilterMatches compares a string field with one value under a filter operator
+// Case is ignored, of all letters as with PostgreSQL
+func stringFilterMatches(op FilterOp, field string, value string) bool {
+	if op == FilterIEquals {
+		return strings.EqualFold(field, value)
+	}
+	return likeMatches([]rune(strings.ToLower(LikePattern(op, value))), []rune(strings.ToLower(field)))
+}
+
+// likeMatches reports whether s matches a LIKE pattern, where % matches any run of
+// characters, _ any one character and a backslash escapes the next
+// After a %, only the latest one is retried, which keeps matching linear in practice
+func likeMatches(pattern []rune, s []rune) bool {
+	p, i := 0, 0
+	star, starI := -1, 0
+	for i < len(s) {
+		if p < len(pattern) && pattern[p] == '%' {
+			star, starI = p, i
+			p++
+			continue
+		}
+		if p < len(pattern) {
+			literal, width := pattern[p], 1
+			if literal == '\\' && p+1 < len(pattern) {
+				literal, width = pattern[p+1], 2
+			}
+			if (pattern[p] == '_' && width == 1) || literal == s[i] {
+				p += width
+				i++
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		// Let the last % absorb one more character
+		starI++
+		p, i = star+1, starI
+	}
+	for p < len(pattern) && pattern[p] == '%' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestMatchFilters(t *testing.T) {
+	data := map[string]interface{}{"name": "ann", "age": 30.0, "active": true}
+	tests := []struct {
+		name    string
+		filters []Filter
+		want    bool
+	}{
+		{name: "none", want: true},
+		{name: "string", filters: []Filter{{Field: "name", Type: models.FieldTypeString, Values: []string{"bob", "ann"}}}, want: true},
+		{name: "number", filters: []Filter{{Field: "age", Type: models.FieldTypeNumber, Values: []string{"30.0"}}}, want: true},
+		{name: "bool", filters: []Filter{{Field: "active", Type: models.FieldTypeBool, Values: []string{"1"}}}, want: true},
+		{name: "unparseable", filters: []Filter{{Field: "age", Type: models.FieldTypeNumber, Values: []string{"old"}}}, want: false},
+		{name: "missing field", filters: []Filter{{Field: "city", Type: models.FieldTypeString, Values: []string{""}}}, want: false},
+		{name: "null matches missing", filters: []Filter{{Field: "city", Type: models.FieldTypeString, MatchNull: true}}, want: true},
+		{name: "null", filters: []Filter{{Field: "name", Type: models.FieldTypeString, MatchNull: true}}, want: false},
+		{name: "exists", filters: []Filter{{Field: "name", Type: models.FieldTypeString, Op: FilterExists, Values: []string{"true"}}}, want: true},
+		{name: "missing", filters: []Filter{{Field: "city", Type: models.FieldTypeString, Op: FilterExists, Values: []string{"false"}}}, want: true},
+		{
+			name: "all must match",
+			filters: []Filter{
+				{Field: "name", Type: models.FieldTypeString, Values: []string{"ann"}},
+				{Field: "active", Type: models.FieldTypeBool, Values: []string{"false"}},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchFilters(data, tt.filters); got != tt.want {
+				t.Errorf("MatchFilters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringFilterMatches(t *testing.T) {
+	tests := []struct {
+		op    FilterOp
+		field string
+		value string
+		want  bool
+	}{
+		{FilterLike, "Report 2024", "report%", true},
+		{FilterLike, "Report 2024", "%20_4", true},
+		{FilterLike, "Report 2024", "%20_", false},
+		{FilterLike, "100%", `100\%`, true},
+		{FilterLike, "1000", `100\%`, false},
+		{FilterLike, "aaab", "%a%a%b", true},
+		{FilterContains, "Hello World", "o w", true},
+		{FilterContains, "Hello World", "%", false},
+		{FilterPrefix, "Hello World", "HELLO", true},
+		{FilterPrefix, "Hello World", "World", false},
+		{FilterIEquals, "Bob@Example.com", "bob@example.COM", true},
+		{FilterIEquals, "Bob@Example.com", "bob", false},
+	}
+	for _, tt := range tests {
+		if got := stringFilterMatches(tt.op, tt.field, tt.value); got != tt.want {
+			t.Errorf("stringFilterMatches(%s, %q, %q) = %v, want %v", tt.op, tt.field, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"jsondrop/internal/models"
+)
+
+// Check looks for inconsistencies between the catalog and the database files without
+// changing anything: missing or corrupt files, collections known to only one side, quota
+// usage that differs from the stored documents, and files no database owns
+// Quota drift is corrected by recalculation, the rest needs an operator
+func (c *CatalogDB) Check() (*models.FsckResponse, error) {
+	databases, err := c.ListDatabases()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.FsckResponse{Problems: []models.FsckProblem{}}
+	known := make(map[string]bool, len(databases))
+	for _, info := range databases {
+		known[info.ID] = true
+		resp.Checked++
+		for _, problem := range c.checkDatabase(info) {
+			resp.Problems = append(resp.Problems, models.FsckProblem{DatabaseID: info.ID, Problem: problem})
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(c.dbBaseDir, "*.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list database files: %w", err)
+	}
+	for _, path := range paths {
+		// Other files, such as the catalog itself, can share the directory
+		dbID := strings.TrimSuffix(filepath.Base(path), ".db")
+		if ValidateDatabaseID(dbID) == nil && !known[dbID] {
+			resp.Problems = append(resp.Problems, models.FsckProblem{DatabaseID: dbID, Problem: "database file has no catalog entry"})
+		}
+	}
+
+	return resp, nil
+}
+
+// checkDatabase returns the problems found with one database
+func (c *CatalogDB) checkDatabase(info *models.Database) []string {
+	dbPath, err := c.getDatabasePath(info.ID)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	// Opening a missing file would create it
+	if _, err := os.Stat(dbPath); err != nil {
+		if os.IsNotExist(err) {
+			return []string{"database file is missing"}
+		}
+		return []string{fmt.Sprintf("failed to stat database file: %v", err)}
+	}
+
+	db, release, err := c.openDatabase(info.ID)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer release()
+
+	var integrity string
+	if err := db.QueryRow(`PRAGMA quick_check`).Scan(&integrity); err != nil {
+		return []string{fmt.Sprintf("failed to check integrity: %v", err)}
+	}
+	if integrity != "ok" {
+		return []string{"integrity check failed: " + integrity}
+	}
+
+	var problems []string
+	schemas, err := c.ListSchemas(info.ID)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	var catalogNames []string
+	for _, schema := range schemas {
+		catalogNames = append(catalogNames, schema.Name)
+	}
+	fileNames, err := collectionNames(db)
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		problems = append(problems, compareCollections(catalogNames, fileNames)...)
+	}
+
+	used, err := storedSize(db)
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if used != info.QuotaUsed {
+		problems = append(problems, fmt.Sprintf("quota used is %d bytes but documents take %d bytes", info.QuotaUsed, used))
+	}
+
+	return problems
+}
+
+// collectionNames returns the collections recorded in a database file
+func collectionNames(db queryExecer) ([]string, error) {
+	rows, err := db.Query(`SELECT name FROM _collections ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan collection: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// compareCollections reports collections the catalog and a database file disagree on
+func compareCollections(catalogNames []string, fileNames []string) []string {
+	var problems []string
+	for _, name := range catalogNames {
+		if !slices.Contains(fileNames, name) {
+			problems = append(problems, fmt.Sprintf("collection %s has a schema but no table", name))
+		}
+	}
+	for _, name := range fileNames {
+		if !slices.Contains(catalogNames, name) {
+			problems = append(problems, fmt.Sprintf("collection %s has a table but no schema", name))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,21 @@
+package database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareCollections(t *testing.T) {
+	got := compareCollections([]string{"posts", "users"}, []string{"posts", "tags"})
+	want := []string{
+		"collection users has a schema but no table",
+		"collection tags has a table but no schema",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("compareCollections() = %q, want %q", got, want)
+	}
+
+	if got := compareCollections([]string{"posts"}, []string{"posts"}); len(got) != 0 {
+		t.Errorf("compareCollections() with matching names = %q, want none", got)
+	}
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestCatalog opens an empty catalog in a temporary directory, closed when the
+// test ends
+func newTestCatalog(t *testing.T) *CatalogDB {
+	t.Helper()
+	return newTestCatalogWith(t, Limits{}, nil)
+}
+
+// newTestCatalogWith is newTestCatalog with query limits and a broadcaster for the
+// catalog's change events
+func newTestCatalogWith(t *testing.T, limits Limits, broadcaster EventBroadcaster) *CatalogDB {
+	t.Helper()
+	dir := t.TempDir()
+	c, err := NewCatalogDB(filepath.Join(dir, "catalog.db"), dir, 1, limits, PoolConfig{}, Compression{}, broadcaster, nil)
+	if err != nil {
+		t.Fatalf("NewCatalogDB() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// newTestDatabase creates a database with a 1 MB quota, whose keys follow its ID:
+// db_notes gets wk_notes and rk_notes
+func newTestDatabase(t *testing.T, c *CatalogDB, dbID string) {
+	t.Helper()
+	name := strings.TrimPrefix(dbID, "db_")
+	if _, err := c.CreateDatabaseWithKeys(dbID, "wk_"+name, "rk_"+name, 1<<20); err != nil {
+		t.Fatalf("CreateDatabaseWithKeys(%s) error = %v", dbID, err)
+	}
+}
@@ -0,0 +1,248 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+const (
+	importBatchSize     = 500     // Documents per import transaction
+	importMaxLineBytes  = 1 << 20 // Longest accepted NDJSON line
+	importMaxLineErrors = 100     // Line errors included in an import result
+)
+
+// pendingImport is a validated document waiting for its batch to be written
+type pendingImport struct {
+	line int
+	doc  *models.Document
+}
+
+// ImportNDJSON streams NDJSON documents, one per line in the format produced by
+// export, into a collection. Each line is validated against the schema and valid
+// documents are inserted in batched transactions; invalid lines are reported in the
+// result without stopping the import. A non-nil error means the import stopped
+// early (unreadable input or a failed batch, e.g. quota exceeded); the result then
+// records what was imported before it and the line where it stopped
+func (c *CatalogDB) ImportNDJSON(dbID string, schema *models.Schema, r io.Reader) (*models.ImportResponse, error) {
+	result := &models.ImportResponse{}
+	addLineError := func(line int, message string) {
+		result.Failed++
+		if len(result.Errors) < importMaxLineErrors {
+			result.Errors = append(result.Errors, models.ImportLineError{Line: line, Error: message})
+		}
+	}
+
+	var batch []pendingImport
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		docs := make([]*models.Document, len(batch))
+		for i, pending := range batch {
+			docs[i] = pending.doc
+		}
+
+		errs, err := c.ImportDocuments(dbID, schema.Name, docs)
+		if err != nil {
+			result.StoppedAt = batch[0].line
+			result.Error = err.Error()
+			return err
+		}
+		for i, err := range errs {
+			if err != nil {
+				addLineError(batch[i].line, err.Error())
+			} else {
+				result.Imported++
+			}
+		}
+
+		batch = batch[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), importMaxLineBytes)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		var doc models.Document
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			addLineError(line, "Invalid JSON: "+err.Error())
+			continue
+		}
+		if len(doc.Data) == 0 {
+			addLineError(line, "Document data cannot be empty")
+			continue
+		}
+		if err := models.CheckDocumentSize(doc.Data, c.limits.MaxDocumentBytes); err != nil {
+			addLineError(line, err.Error())
+			continue
+		}
+		if err := models.ValidateDocument(doc.Data, schema); err != nil {
+			addLineError(line, "Validation failed: "+err.Error())
+			continue
+		}
+
+		batch = append(batch, pendingImport{line: line, doc: &doc})
+		if len(batch) >= importBatchSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// Batches before an unreadable line are kept; the rest is dropped
+	if err := flush(); err != nil {
+		return result, err
+	}
+	if err := scanner.Err(); err != nil {
+		result.StoppedAt = line + 1
+		result.Error = "failed to read input: " + err.Error()
+		return result, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	return result, nil
+}
+
+// ImportDocuments inserts a batch of documents in a single transaction
+// Documents without an ID get a generated one, and zero timestamps are set to now,
+// so exported documents can be imported unchanged. The returned slice holds a
+// per-document error (nil on success); a non-nil error means the whole batch was
+// rolled back, e.g. because it would exceed the quota
+func (c *CatalogDB) ImportDocuments(dbID string, collection string, docs []*models.Document) ([]error, error) {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		INSERT INTO %s (id, created_at, updated_at, data, visibility)
+		VALUES (?, ?, ?, ?, ?)
+	`, QuoteIdentifier(collection)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().Unix()
+	errs := make([]error, len(docs))
+	var inserted []*models.Document
+	var totalSize int64
+
+	for i, doc := range docs {
+		if err := c.prepareImportDocument(doc, collection, now); err != nil {
+			errs[i] = err
+			continue
+		}
+
+		dataJSON, err := json.Marshal(doc.Data)
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to marshal document data: %w", err)
+			continue
+		}
+
+		storedData, documentSize, err := packData(dataJSON, c.compression)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		// A failed statement doesn't abort the transaction, so the rest of the batch continues
+		_, err = stmt.Exec(doc.ID, doc.CreatedAt.Unix(), doc.UpdatedAt.Unix(), storedData, string(doc.Visibility))
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				errs[i] = fmt.Errorf("document already exists: %s", doc.ID)
+			} else {
+				errs[i] = fmt.Errorf("failed to insert document: %w", err)
+			}
+			continue
+		}
+
+		totalSize += documentSize
+		inserted = append(inserted, doc)
+	}
+
+	if len(inserted) == 0 {
+		return errs, nil
+	}
+
+	if err := c.reserveQuota(dbID, totalSize); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		// Give back the quota reserved for the batch
+		c.releaseQuota(dbID, totalSize)
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+
+	for _, doc := range inserted {
+		if err := c.syncSearchIndex(db, collection, doc.ID, doc.Data); err != nil {
+			// Log but don't fail; the document is stored and can be reindexed
+		}
+
+		if c.broadcaster != nil {
+			event := models.ChangeEvent{
+				EventType:  "insert",
+				DatabaseID: dbID,
+				Collection: collection,
+				DocumentID: doc.ID,
+				Data:       doc.Data,
+				Visibility: doc.Visibility,
+				Timestamp:  time.Unix(now, 0),
+			}
+			c.broadcaster.Broadcast(dbID, event)
+		}
+	}
+
+	return errs, nil
+}
+
+// prepareImportDocument fills in defaults for an imported document
+func (c *CatalogDB) prepareImportDocument(doc *models.Document, collection string, now int64) error {
+	if doc.Visibility == "" {
+		doc.Visibility = models.DefaultVisibility
+	}
+	if !doc.Visibility.IsValid() {
+		return fmt.Errorf("invalid visibility: %s", doc.Visibility)
+	}
+
+	if doc.ID == "" {
+		id, err := c.keys.DocumentID()
+		if err != nil {
+			return err
+		}
+		doc.ID = id
+	}
+
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Unix(now, 0)
+	}
+	if doc.UpdatedAt.IsZero() {
+		doc.UpdatedAt = doc.CreatedAt
+	}
+
+	doc.Collection = collection
+	return nil
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// maxIndexes caps the user-declared indexes per collection
+const maxIndexes = 16
+
+// userIndexName returns the SQLite name of a user-declared index
+func userIndexName(collection string, name string) string {
+	return fmt.Sprintf("idx_%s_user_%s", collection, name)
+}
+
+// ParseIndexFields validates the fields of a user-declared index against a collection's schema
+// Every field and the id, created_at and updated_at columns already have an index of their own,
+// so an index must cover at least two fields
+func ParseIndexFields(fields []string, schemaFields map[string]models.FieldType) ([]SortKey, error) {
+	keys, err := parseKeys("index", fields, schemaFields)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) < 2 {
+		return nil, fmt.Errorf("invalid index: single fields are already indexed, list at least two fields")
+	}
+	return keys, nil
+}
+
+// createUserIndex creates the SQLite index for a user-declared index
+func createUserIndex(db execer, collection string, name string, keys []SortKey) error {
+	indexSQL := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)",
+		QuoteIdentifier(userIndexName(collection, name)), QuoteIdentifier(collection), orderByClause("", keys))
+	if _, err := db.Exec(indexSQL); err != nil {
+		return fmt.Errorf("failed to create index %s: %w", name, err)
+	}
+	return nil
+}
+
+// dropUserIndex drops the SQLite index for a user-declared index
+func dropUserIndex(db execer, collection string, name string) error {
+	dropSQL := fmt.Sprintf("DROP INDEX IF EXISTS %s", QuoteIdentifier(userIndexName(collection, name)))
+	if _, err := db.Exec(dropSQL); err != nil {
+		return fmt.Errorf("failed to drop index %s: %w", name, err)
+	}
+	return nil
+}
+
+// indexKeys parses the stored fields of an index
+func indexKeys(index *models.Index) []SortKey {
+	keys := make([]SortKey, len(index.Fields))
+	for i, field := range index.Fields {
+		keys[i] = SortKey{Field: strings.TrimPrefix(field, "-"), Descending: strings.HasPrefix(field, "-")}
+	}
+	return keys
+}
+
+// CreateIndex declares an index over several fields of a collection
+// The SQLite index is built first and dropped again if the catalog entry can't be written
+func (c *CatalogDB) CreateIndex(dbID string, collection string, name string, fields []string) (*models.Index, error) {
+	if err := ValidateIdentifier(name); err != nil {
+		return nil, fmt.Errorf("invalid index name: %w", err)
+	}
+
+	schema, err := c.GetSchema(dbID, collection)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("schema not found")
+	}
+
+	keys, err := ParseIndexFields(fields, schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := c.ListIndexes(dbID, collection)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range existing {
+		if index.Name == name {
+			return nil, fmt.Errorf("index already exists: %s", name)
+		}
+	}
+	if len(existing) >= maxIndexes {
+		return nil, fmt.Errorf("index limit exceeded: collections can have at most %d indexes", maxIndexes)
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := createUserIndex(db, collection, name, keys); err != nil {
+		return nil, err
+	}
+
+	index := &models.Index{
+		Name:       name,
+		Collection: collection,
+		Fields:     make([]string, len(keys)),
+		CreatedAt:  time.Unix(time.Now().Unix(), 0),
+	}
+	for i, key := range keys {
+		index.Fields[i] = key.Field
+		if key.Descending {
+			index.Fields[i] = "-" + key.Field
+		}
+	}
+
+	fieldsJSON, err := json.Marshal(index.Fields)
+	if err != nil {
+		dropUserIndex(db, collection, name)
+		return nil, fmt.Errorf("failed to marshal index fields: %w", err)
+	}
+
+	query := `
+		INSERT INTO collection_indexes (database_id, collection, name, fields, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	if _, err := c.db.Exec(query, dbID, collection, name, string(fieldsJSON), index.CreatedAt.Unix()); err != nil {
+		// Rollback: drop the SQLite index
+		dropUserIndex(db, collection, name)
+		return nil, fmt.Errorf("failed to create index entry: %w", err)
+	}
+
+	return index, nil
+}
+
+// ListIndexes returns the user-declared indexes of a collection, by name
+func (c *CatalogDB) ListIndexes(dbID string, collection string) ([]*models.Index, error) {
+	query := `
+		SELECT name, fields, created_at
+		FROM collection_indexes
+		WHERE database_id = ? AND collection = ?
+		ORDER BY name
+	`
+	rows, err := c.db.Query(query, dbID, collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	defer rows.Close()
+
+	indexes := []*models.Index{}
+	for rows.Next() {
+		index := &models.Index{Collection: collection}
+		var fieldsJSON string
+		var createdAt int64
+		if err := rows.Scan(&index.Name, &fieldsJSON, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan index: %w", err)
+		}
+		if err := json.Unmarshal([]byte(fieldsJSON), &index.Fields); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal index fields: %w", err)
+		}
+		index.CreatedAt = time.Unix(createdAt, 0)
+		indexes = append(indexes, index)
+	}
+
+	return indexes, rows.Err()
+}
+
+// DeleteIndex drops a user-declared index
+func (c *CatalogDB) DeleteIndex(dbID string, collection string, name string) error {
+	result, err := c.db.Exec(`DELETE FROM collection_indexes WHERE database_id = ? AND collection = ? AND name = ?`,
+		dbID, collection, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete index entry: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("index not found")
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return dropUserIndex(db, collection, name)
+}
+
+// indexUsingField returns the name of a user-declared index covering a field, if any
+func indexUsingField(indexes []*models.Index, field string) string {
+	for _, index := range indexes {
+		for _, key := range indexKeys(index) {
+			if key.Field == field {
+				return index.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestParseIndexFields(t *testing.T) {
+	fields := map[string]models.FieldType{"status": models.FieldTypeString, "prio": models.FieldTypeNumber}
+
+	got, err := ParseIndexFields([]string{"status", "-prio", "created_at"}, fields)
+	if err != nil {
+		t.Fatalf("ParseIndexFields() error = %v, want nil", err)
+	}
+	want := []SortKey{{Field: "status"}, {Field: "prio", Descending: true}, {Field: "created_at"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseIndexFields() = %+v, want %+v", got, want)
+	}
+
+	invalid := [][]string{
+		nil,
+		{"status"},
+		{"status", "missing"},
+		{"status", "-status"},
+		{"status", ""},
+	}
+	for _, names := range invalid {
+		if _, err := ParseIndexFields(names, fields); err == nil {
+			t.Errorf("ParseIndexFields(%q) error = nil, want error", names)
+		}
+	}
+}
+
+func TestIndexKeys(t *testing.T) {
+	index := &models.Index{Name: "by_status", Fields: []string{"status", "-prio"}}
+	want := []SortKey{{Field: "status"}, {Field: "prio", Descending: true}}
+	if got := indexKeys(index); !reflect.DeepEqual(got, want) {
+		t.Errorf("indexKeys() = %+v, want %+v", got, want)
+	}
+
+	if got := indexUsingField([]*models.Index{index}, "prio"); got != "by_status" {
+		t.Errorf("indexUsingField(prio) = %q, want by_status", got)
+	}
+	if got := indexUsingField([]*models.Index{index}, "title"); got != "" {
+		t.Errorf("indexUsingField(title) = %q, want empty", got)
+	}
+}
+
+func TestCompositeIndexServesFilters(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_indexes"
+	newTestDatabase(t, c, dbID)
+	fields := map[string]models.FieldType{"status": models.FieldTypeString, "prio": models.FieldTypeNumber}
+	if _, err := c.CreateSchema(dbID, "orders", fields, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	if _, err := c.CreateIndex(dbID, "orders", "by_status", []string{"status", "created_at"}); err != nil {
+		t.Fatalf("CreateIndex() error = %v", err)
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	defer release()
+
+	// An equality filter on the leading field and a range on the next use the index for both
+	filters := []Filter{{Field: "status", Type: models.FieldTypeString, Values: []string{"open"}}}
+	dates := &TimeRange{CreatedAfter: time.Now().Add(-time.Hour)}
+	query, args := documentsQuery("orders", filters, dates, nil, nil)
+	rows, err := db.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		t.Fatalf("EXPLAIN QUERY PLAN error = %v", err)
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatalf("scan plan error = %v", err)
+		}
+		plan = append(plan, detail)
+	}
+	want := "USING INDEX " + userIndexName("orders", "by_status") + " (field_status=? AND created_at>?)"
+	if len(plan) != 1 || !strings.Contains(plan[0], want) {
+		t.Errorf("query plan = %q, want one search %s", plan, want)
+	}
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// joinMaxMatches caps how many joined documents are attached to each result
+const joinMaxMatches = 100
+
+// Join attaches documents from another collection whose field equals a field of each result
+type Join struct {
+	LocalField   string
+	Collection   string
+	ForeignField string
+}
+
+// ParseJoin parses a join parameter of the form "local_field:collection.foreign_field"
+// Returns nil when the parameter is empty
+func ParseJoin(value string) (*Join, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	local, foreign, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid join: expected local_field:collection.foreign_field")
+	}
+	collection, foreignField, ok := strings.Cut(foreign, ".")
+	if !ok || local == "" || collection == "" || foreignField == "" {
+		return nil, fmt.Errorf("invalid join: expected local_field:collection.foreign_field")
+	}
+
+	return &Join{LocalField: local, Collection: collection, ForeignField: foreignField}, nil
+}
+
+// Validate checks that the join fields exist in the local and joined schemas
+// Either side may also use the built-in id, created_at and updated_at columns
+func (j *Join) Validate(local *models.Schema, foreign *models.Schema) error {
+	if _, isField := local.Fields[j.LocalField]; !isField && !metadataColumns[j.LocalField] {
+		return fmt.Errorf("invalid join: unknown field %s in %s", j.LocalField, local.Name)
+	}
+	if _, isField := foreign.Fields[j.ForeignField]; !isField && !metadataColumns[j.ForeignField] {
+		return fmt.Errorf("invalid join: unknown field %s in %s", j.ForeignField, foreign.Name)
+	}
+	return nil
+}
+
+// JoinDocuments attaches matching documents from the joined collection to each document
+// Matches are found with a single SQL join over the given documents, oldest first, and
+// filtered by the joined collection's read scope
+func (c *CatalogDB) JoinDocuments(dbID string, collection string, documents []*models.Document, join *Join, scope *ReadScope) error {
+	if len(documents) == 0 {
+		return nil
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	byID := make(map[string]*models.Document, len(documents))
+	placeholders := make([]string, len(documents))
+	args := make([]interface{}, len(documents))
+	for i, doc := range documents {
+		doc.Joined = map[string][]*models.Document{join.Collection: {}}
+		byID[doc.ID] = doc
+		placeholders[i] = "?"
+		args[i] = doc.ID
+	}
+
+	visibilityClause, visibilityArgs := visibilityFilter("f.visibility", scope.VisibleLevels())
+	query := fmt.Sprintf(`
+		SELECT l.id, f.id, f.created_at, f.updated_at, f.data, f.visibility, f.revision
+		FROM %s AS l
+		JOIN %s AS f ON %s = %s
+		WHERE l.id IN (%s)%s%s
+		ORDER BY f.created_at ASC, f.id ASC
+	`, QuoteIdentifier(collection), QuoteIdentifier(join.Collection),
+		fieldExpr("f.", join.ForeignField), fieldExpr("l.", join.LocalField),
+		strings.Join(placeholders, ", "), visibilityClause, scope.deletedFilter("f."))
+
+	budget := c.limits.NewQueryBudget()
+	defer budget.Done()
+
+	rows, err := db.QueryContext(budget.Context(), query, append(args, visibilityArgs...)...)
+	if err != nil {
+		return budget.Err(fmt.Errorf("failed to join documents: %w", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := budget.Scan(); err != nil {
+			return err
+		}
+
+		var localID string
+		var doc models.Document
+		var createdAt, updatedAt int64
+		var stored []byte
+
+		if err := rows.Scan(&localID, &doc.ID, &createdAt, &updatedAt, &stored, &doc.Visibility, &doc.Revision); err != nil {
+			return fmt.Errorf("failed to scan joined document: %w", err)
+		}
+
+		if err := decodeData(stored, &doc.Data); err != nil {
+			return err
+		}
+
+		doc.Collection = join.Collection
+		doc.CreatedAt = time.Unix(createdAt, 0)
+		doc.UpdatedAt = time.Unix(updatedAt, 0)
+
+		if !scope.Allows(&doc) {
+			continue
+		}
+		local := byID[localID]
+		if len(local.Joined[join.Collection]) < joinMaxMatches {
+			local.Joined[join.Collection] = append(local.Joined[join.Collection], &doc)
+		}
+	}
+
+	return budget.Err(rows.Err())
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestParseJoin(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    *Join
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: nil},
+		{
+			name:  "data field to id",
+			value: "author_id:authors.id",
+			want:  &Join{LocalField: "author_id", Collection: "authors", ForeignField: "id"},
+		},
+		{name: "missing collection", value: "author_id:id", wantErr: true},
+		{name: "missing local field", value: ":authors.id", wantErr: true},
+		{name: "missing separator", value: "authors.id", wantErr: true},
+		{name: "missing foreign field", value: "author_id:authors.", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseJoin(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseJoin(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseJoin(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinValidate(t *testing.T) {
+	posts := &models.Schema{Name: "posts", Fields: map[string]models.FieldType{"author_id": models.FieldTypeString}}
+	authors := &models.Schema{Name: "authors", Fields: map[string]models.FieldType{"handle": models.FieldTypeString}}
+
+	valid := []*Join{
+		{LocalField: "author_id", Collection: "authors", ForeignField: "id"},
+		{LocalField: "author_id", Collection: "authors", ForeignField: "handle"},
+	}
+	for _, join := range valid {
+		if err := join.Validate(posts, authors); err != nil {
+			t.Errorf("Validate(%+v) error = %v", join, err)
+		}
+	}
+
+	invalid := []*Join{
+		{LocalField: "author", Collection: "authors", ForeignField: "id"},
+		{LocalField: "author_id", Collection: "authors", ForeignField: "name"},
+	}
+	for _, join := range invalid {
+		if err := join.Validate(posts, authors); err == nil {
+			t.Errorf("Validate(%+v) should fail", join)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"regexp"
+	"strings"
 )
 
 const (
@@ -12,6 +14,32 @@ const (
 	readKeyLength    = 32
 )
 
+// KeyGenerator creates the identifiers and access keys handed out by the server
+// Implementations let deployments use their own schemes, such as org-prefixed IDs or
+// keys derived through a KMS. Database IDs must start with db_ and pass ValidateDatabaseID,
+// and write and read keys must start with wk_ and rk_, which identify their access level
+type KeyGenerator interface {
+	DatabaseID() (string, error)
+	WriteKey() (string, error)
+	ReadKey() (string, error)
+	DocumentID() (string, error)
+}
+
+// RandomKeys is the default KeyGenerator, drawing every ID and key from crypto/rand
+type RandomKeys struct{}
+
+// DatabaseID generates a random database ID
+func (RandomKeys) DatabaseID() (string, error) { return GenerateDatabaseID() }
+
+// WriteKey generates a random write key
+func (RandomKeys) WriteKey() (string, error) { return GenerateWriteKey() }
+
+// ReadKey generates a random read key
+func (RandomKeys) ReadKey() (string, error) { return GenerateReadKey() }
+
+// DocumentID generates a random document ID
+func (RandomKeys) DocumentID() (string, error) { return GenerateDocumentID() }
+
 // GenerateDatabaseID generates a unique database ID with "db_" prefix
 func GenerateDatabaseID() (string, error) {
 	id, err := generateRandomString(databaseIDLength)
@@ -21,6 +49,18 @@ func GenerateDatabaseID() (string, error) {
 	return "db_" + id, nil
 }
 
+// databaseIDPattern matches the db_ prefix followed by URL-safe base64 characters
+// IDs name files in the data directory, so separators, dots and drive letters are excluded
+var databaseIDPattern = regexp.MustCompile(`^db_[A-Za-z0-9_-]{1,64}$`)
+
+// ValidateDatabaseID checks that a database ID is safe to use as a file name
+func ValidateDatabaseID(dbID string) error {
+	if !databaseIDPattern.MatchString(dbID) {
+		return fmt.Errorf("invalid database ID: expected db_ followed by up to 64 letters, digits, '-' or '_'")
+	}
+	return nil
+}
+
 // GenerateWriteKey generates a write key with "wk_" prefix
 func GenerateWriteKey() (string, error) {
 	key, err := generateRandomString(writeKeyLength)
@@ -39,6 +79,33 @@ func GenerateReadKey() (string, error) {
 	return "rk_" + key, nil
 }
 
+// RotatedKeys generates replacements for the chosen keys with a KeyGenerator, leaving the
+// others empty
+func RotatedKeys(keys KeyGenerator, write bool, read bool) (string, string, error) {
+	if !write && !read {
+		return "", "", fmt.Errorf("no keys to rotate: choose the write key, the read key or both")
+	}
+	var writeKey, readKey string
+	var err error
+	if write {
+		if writeKey, err = keys.WriteKey(); err != nil {
+			return "", "", err
+		}
+		if !strings.HasPrefix(writeKey, "wk_") {
+			return "", "", fmt.Errorf("invalid write key: expected wk_ prefix")
+		}
+	}
+	if read {
+		if readKey, err = keys.ReadKey(); err != nil {
+			return "", "", err
+		}
+		if !strings.HasPrefix(readKey, "rk_") {
+			return "", "", fmt.Errorf("invalid read key: expected rk_ prefix")
+		}
+	}
+	return writeKey, readKey, nil
+}
+
 // generateRandomString creates a cryptographically secure random string
 func generateRandomString(length int) (string, error) {
 	// Calculate bytes needed for base64 encoding
@@ -136,3 +136,54 @@ func isBase64URLChar(c rune) bool {
 		(c >= '0' && c <= '9') ||
 		c == '-' || c == '_'
 }
+
+func TestValidateDatabaseID(t *testing.T) {
+	generated, err := GenerateDatabaseID()
+	if err != nil {
+		t.Fatalf("GenerateDatabaseID() error = %v, want nil", err)
+	}
+
+	valid := []string{generated, "db_demo", "db_a-b_c", "db_" + strings.Repeat("x", 64)}
+	for _, id := range valid {
+		if err := ValidateDatabaseID(id); err != nil {
+			t.Errorf("ValidateDatabaseID(%q) error = %v, want nil", id, err)
+		}
+	}
+
+	invalid := []string{
+		"", "db_", "demo", "db_" + strings.Repeat("x", 65),
+		"db_../catalog", "db_a/b", `db_a\b`, "db_a.b", "db_C:x", "db_a b", "db_a\x00",
+	}
+	for _, id := range invalid {
+		if err := ValidateDatabaseID(id); err == nil {
+			t.Errorf("ValidateDatabaseID(%q) error = nil, want error", id)
+		}
+	}
+}
+
+func TestRandomKeys(t *testing.T) {
+	var keys KeyGenerator = RandomKeys{}
+
+	dbID, err := keys.DatabaseID()
+	if err != nil {
+		t.Fatalf("DatabaseID() error = %v, want nil", err)
+	}
+	if err := ValidateDatabaseID(dbID); err != nil {
+		t.Errorf("DatabaseID() = %s, not a valid database ID: %v", dbID, err)
+	}
+
+	prefixes := map[string]func() (string, error){
+		"wk_":  keys.WriteKey,
+		"rk_":  keys.ReadKey,
+		"doc_": keys.DocumentID,
+	}
+	for prefix, generate := range prefixes {
+		value, err := generate()
+		if err != nil {
+			t.Fatalf("generate %s error = %v, want nil", prefix, err)
+		}
+		if !strings.HasPrefix(value, prefix) {
+			t.Errorf("generated %s, want prefix %s", value, prefix)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"jsondrop/internal/models"
+)
+
+// MaskField makes a schema field readable with the write key only
+// Masking a field again leaves it masked
+func (c *CatalogDB) MaskField(dbID string, name string, field string) (*models.Schema, error) {
+	return c.MaskFields(dbID, name, []string{field})
+}
+
+// MaskFields makes schema fields readable with the write key only
+func (c *CatalogDB) MaskFields(dbID string, name string, fields []string) (*models.Schema, error) {
+	schema, err := c.GetSchema(dbID, name)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("schema not found")
+	}
+	for _, field := range fields {
+		if _, exists := schema.Fields[field]; !exists {
+			return nil, fmt.Errorf("field not found: %s", field)
+		}
+	}
+
+	masked := slices.Concat(schema.Masked, fields)
+	slices.Sort(masked)
+	masked = slices.Compact(masked)
+	if slices.Equal(masked, schema.Masked) {
+		return schema, nil
+	}
+	if err := c.setMasked(dbID, name, masked); err != nil {
+		return nil, err
+	}
+	return c.GetSchema(dbID, name)
+}
+
+// WriteOnlyFields returns the fields a schema request gives the write_only visibility,
+// sorted, checking the visibilities it gives
+func WriteOnlyFields(visibility map[string]models.FieldVisibility) ([]string, error) {
+	var fields []string
+	for field, v := range visibility {
+		if !v.IsValid() {
+			return nil, fmt.Errorf("invalid visibility %q of field %s: use read or write_only", v, field)
+		}
+		if v == models.FieldVisibilityWriteOnly {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+	return fields, nil
+}
+
+// UnmaskField makes a masked field readable by every key again
+func (c *CatalogDB) UnmaskField(dbID string, name string, field string) (*models.Schema, error) {
+	schema, err := c.GetSchema(dbID, name)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("schema not found")
+	}
+	if !slices.Contains(schema.Masked, field) {
+		return nil, fmt.Errorf("mask not found: %s is not masked", field)
+	}
+
+	masked := slices.DeleteFunc(slices.Clone(schema.Masked), func(f string) bool { return f == field })
+	if err := c.setMasked(dbID, name, masked); err != nil {
+		return nil, err
+	}
+	return c.GetSchema(dbID, name)
+}
+
+// setMasked stores a schema's masked fields
+func (c *CatalogDB) setMasked(dbID string, name string, masked []string) error {
+	query := `UPDATE schemas SET masked_fields = ? WHERE database_id = ? AND name = ?`
+	if _, err := c.db.Exec(query, marshalMasked(masked), dbID, name); err != nil {
+		return fmt.Errorf("failed to update masked fields: %w", err)
+	}
+	c.schemas.invalidate(dbID, name)
+	return nil
+}
+
+// marshalMasked encodes masked fields for the catalog
+func marshalMasked(masked []string) string {
+	if len(masked) == 0 {
+		return "[]"
+	}
+	encoded, _ := json.Marshal(masked)
+	return string(encoded)
+}
+
+// unmarshalMasked decodes a schema's masked fields from the catalog
+func unmarshalMasked(maskedJSON string, schema *models.Schema) error {
+	if maskedJSON == "" || maskedJSON == "[]" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(maskedJSON), &schema.Masked); err != nil {
+		return fmt.Errorf("failed to unmarshal masked fields: %w", err)
+	}
+	return nil
+}
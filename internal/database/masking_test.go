@@ -0,0 +1,127 @@
+package database
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestMaskField(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_mask"
+	newTestDatabase(t, c, dbID)
+	fields := map[string]models.FieldType{"name": models.FieldTypeString, "ssn": models.FieldTypeString, "salary": models.FieldTypeNumber}
+	if _, err := c.CreateSchema(dbID, "people", fields, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+
+	if _, err := c.MaskField(dbID, "people", "email"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("MaskField(unknown field) error = %v, want not found", err)
+	}
+	for _, field := range []string{"ssn", "salary", "ssn"} {
+		if _, err := c.MaskField(dbID, "people", field); err != nil {
+			t.Fatalf("MaskField(%s) error = %v", field, err)
+		}
+	}
+	schema, err := c.GetSchema(dbID, "people")
+	if err != nil {
+		t.Fatalf("GetSchema() error = %v", err)
+	}
+	if !slices.Equal(schema.Masked, []string{"salary", "ssn"}) {
+		t.Errorf("Masked = %v, want [salary ssn]", schema.Masked)
+	}
+	schemas, err := c.ListSchemas(dbID)
+	if err != nil || len(schemas) != 1 || !slices.Equal(schemas[0].Masked, schema.Masked) {
+		t.Errorf("ListSchemas() = %v, %v, want the masked fields listed", schemas, err)
+	}
+
+	// Removed fields are no longer masked
+	if _, err := c.UpdateSchema(dbID, "people", &models.UpdateSchemaRequest{RemoveFields: []string{"salary"}}); err != nil {
+		t.Fatalf("UpdateSchema(remove salary) error = %v", err)
+	}
+	if schema, _ = c.GetSchema(dbID, "people"); !slices.Equal(schema.Masked, []string{"ssn"}) {
+		t.Errorf("Masked = %v after removing salary, want [ssn]", schema.Masked)
+	}
+
+	// Full-text search skips masked fields for callers who can't read them
+	if c.capabilities.FTS5 {
+		if _, err := c.InsertDocument(dbID, "people", map[string]interface{}{"name": "ann", "ssn": "secret"}, ""); err != nil {
+			t.Fatalf("InsertDocument() error = %v", err)
+		}
+		scope := &ReadScope{Masked: schema.Masked}
+		if docs, err := c.SearchDocuments(dbID, "people", "secret", 10, 0, nil, nil, nil, scope); err != nil || len(docs) != 0 {
+			t.Errorf("SearchDocuments(masked value) = %d documents, %v, want none", len(docs), err)
+		}
+		if docs, err := c.SearchDocuments(dbID, "people", "ann", 10, 0, nil, nil, nil, scope); err != nil || len(docs) != 1 {
+			t.Errorf("SearchDocuments(readable value) = %d documents, %v, want 1", len(docs), err)
+		}
+		if docs, err := c.SearchDocuments(dbID, "people", "secret", 10, 0, nil, nil, nil, nil); err != nil || len(docs) != 1 {
+			t.Errorf("SearchDocuments(masked value) without a scope = %d documents, %v, want 1", len(docs), err)
+		}
+	}
+
+	if _, err := c.UnmaskField(dbID, "people", "name"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("UnmaskField(name) error = %v, want not found", err)
+	}
+	if schema, err = c.UnmaskField(dbID, "people", "ssn"); err != nil || len(schema.Masked) != 0 {
+		t.Errorf("UnmaskField(ssn) = %v, %v, want no masked fields", schema.Masked, err)
+	}
+}
+
+func TestWriteOnlyFields(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_writeonly"
+	newTestDatabase(t, c, dbID)
+	var req models.CreateSchemaRequest
+	body := `{"fields": {"name": "string", "email": {"type": "string", "visibility": "write_only"}, "age": {"type": "number", "visibility": "read"}}}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("Unmarshal(CreateSchemaRequest) error = %v", err)
+	}
+	if req.Fields["email"] != models.FieldTypeString || req.Fields["age"] != models.FieldTypeNumber {
+		t.Errorf("Fields = %v, want the types of every field", req.Fields)
+	}
+	writeOnly, err := WriteOnlyFields(req.FieldVisibility)
+	if err != nil || !slices.Equal(writeOnly, []string{"email"}) {
+		t.Fatalf("WriteOnlyFields() = %v, %v, want [email]", writeOnly, err)
+	}
+	if _, err := WriteOnlyFields(map[string]models.FieldVisibility{"email": "hidden"}); err == nil || !strings.HasPrefix(err.Error(), "invalid") {
+		t.Errorf("WriteOnlyFields(hidden) error = %v, want invalid", err)
+	}
+
+	// Write-only fields are masked as the schema is created
+	if _, err := c.CreateSchema(dbID, "people", req.Fields, []string{"email", "phone"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("CreateSchema(unknown masked field) error = %v, want not found", err)
+	}
+	if schema, err := c.GetSchema(dbID, "people"); err != nil || schema != nil {
+		t.Fatalf("GetSchema() after a failed CreateSchema = %v, %v, want no schema", schema, err)
+	}
+	schema, err := c.CreateSchema(dbID, "people", req.Fields, writeOnly)
+	if err != nil || !slices.Equal(schema.Masked, []string{"email"}) {
+		t.Fatalf("CreateSchema() = %v, %v, want [email] masked", schema, err)
+	}
+	if schema, err = c.GetSchema(dbID, "people"); err != nil || !slices.Equal(schema.Masked, []string{"email"}) {
+		t.Fatalf("GetSchema() masked = %v, %v, want [email]", schema, err)
+	}
+
+	// Fields added write-only are masked with the update
+	var update models.UpdateSchemaRequest
+	body = `{"add_fields": {"phone": {"type": "string", "visibility": "write_only"}, "city": "string"}}`
+	if err := json.Unmarshal([]byte(body), &update); err != nil {
+		t.Fatalf("Unmarshal(UpdateSchemaRequest) error = %v", err)
+	}
+	if schema, err = c.UpdateSchema(dbID, "people", &update); err != nil || !slices.Equal(schema.Masked, []string{"email", "phone"}) {
+		t.Errorf("UpdateSchema(add phone) masked = %v, %v, want [email phone]", schema, err)
+	}
+	update = models.UpdateSchemaRequest{
+		AddFields:       map[string]models.FieldType{"zip": models.FieldTypeString},
+		FieldVisibility: map[string]models.FieldVisibility{"zip": "hidden"},
+	}
+	if _, err := c.UpdateSchema(dbID, "people", &update); err == nil || !strings.HasPrefix(err.Error(), "invalid") {
+		t.Errorf("UpdateSchema(hidden zip) error = %v, want invalid", err)
+	}
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// MirrorFields returns the schema of a mirror's target collection: the mirrored fields of
+// the source schema
+func MirrorFields(fields []string, schemaFields map[string]models.FieldType) (map[string]models.FieldType, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid mirror: list the fields to copy")
+	}
+	subset := make(map[string]models.FieldType, len(fields))
+	for _, field := range fields {
+		fieldType, ok := schemaFields[field]
+		if !ok {
+			return nil, fmt.Errorf("invalid mirror: unknown field %s", field)
+		}
+		if _, dup := subset[field]; dup {
+			return nil, fmt.Errorf("invalid mirror: field %s is listed twice", field)
+		}
+		subset[field] = fieldType
+	}
+	return subset, nil
+}
+
+// CreateMirror records a mirror and creates its target collection, which must not exist yet
+// Mirrors don't chain: a mirror's source can't be another mirror's target, and a new target
+// can't have mirrors of its own
+func (c *CatalogDB) CreateMirror(mirror *models.Mirror) error {
+	schema, err := c.GetSchema(mirror.DatabaseID, mirror.Collection)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		return fmt.Errorf("schema not found")
+	}
+	fields, err := MirrorFields(mirror.Fields, schema.Fields)
+	if err != nil {
+		return err
+	}
+
+	target, err := c.GetSchema(mirror.TargetDatabaseID, mirror.TargetCollection)
+	if err != nil {
+		return err
+	}
+	if target != nil {
+		return fmt.Errorf("target collection already exists: %s", mirror.TargetCollection)
+	}
+
+	if into, err := c.GetMirrorInto(mirror.DatabaseID, mirror.Collection); err != nil {
+		return err
+	} else if into != nil {
+		return fmt.Errorf("invalid mirror: %s is itself a mirror", mirror.Collection)
+	}
+
+	id, err := generateRandomString(16)
+	if err != nil {
+		return fmt.Errorf("failed to generate mirror ID: %w", err)
+	}
+	mirror.ID = "mir_" + id
+	mirror.CreatedAt = time.Unix(time.Now().Unix(), 0)
+
+	fieldsJSON, err := json.Marshal(mirror.Fields)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mirror fields: %w", err)
+	}
+
+	if _, err := c.CreateSchema(mirror.TargetDatabaseID, mirror.TargetCollection, fields, nil); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO collection_mirrors (id, database_id, collection, target_database_id, target_collection, fields, filter, visibility, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	if _, err := c.db.Exec(query, mirror.ID, mirror.DatabaseID, mirror.Collection, mirror.TargetDatabaseID,
+		mirror.TargetCollection, string(fieldsJSON), mirror.Filter, mirror.Visibility, mirror.CreatedAt.Unix()); err != nil {
+		// Rollback: remove the target collection
+		c.DeleteSchema(mirror.TargetDatabaseID, mirror.TargetCollection)
+		return fmt.Errorf("failed to create mirror entry: %w", err)
+	}
+
+	return nil
+}
+
+// mirrorColumns are the catalog columns scanned by scanMirror
+const mirrorColumns = `id, database_id, collection, target_database_id, target_collection, fields, filter, visibility, created_at`
+
+// ListMirrors returns the mirrors copying a collection elsewhere, oldest first
+func (c *CatalogDB) ListMirrors(dbID string, collection string) ([]*models.Mirror, error) {
+	query := `SELECT ` + mirrorColumns + ` FROM collection_mirrors WHERE database_id = ? AND collection = ? ORDER BY created_at, id`
+	return c.queryMirrors(query, dbID, collection)
+}
+
+// GetMirrorInto returns the mirror writing into a collection, or nil if it isn't a mirror target
+func (c *CatalogDB) GetMirrorInto(dbID string, collection string) (*models.Mirror, error) {
+	query := `SELECT ` + mirrorColumns + ` FROM collection_mirrors WHERE target_database_id = ? AND target_collection = ?`
+	mirrors, err := c.queryMirrors(query, dbID, collection)
+	if err != nil || len(mirrors) == 0 {
+		return nil, err
+	}
+	return mirrors[0], nil
+}
+
+// DeleteMirror stops a collection's mirror; the target collection stays, and becomes writable
+func (c *CatalogDB) DeleteMirror(dbID string, collection string, id string) error {
+	result, err := c.db.Exec(`DELETE FROM collection_mirrors WHERE id = ? AND database_id = ? AND collection = ?`,
+		id, dbID, collection)
+	if err != nil {
+		return fmt.Errorf("failed to delete mirror entry: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("mirror not found")
+	}
+	return nil
+}
+
+// RollBackMirror undoes CreateMirror when its target couldn't be filled: it drops the
+// target collection with the mirror, unless the collection is no longer this mirror's
+// target, having been released or replaced since
+func (c *CatalogDB) RollBackMirror(mirror *models.Mirror) error {
+	into, err := c.GetMirrorInto(mirror.TargetDatabaseID, mirror.TargetCollection)
+	if err != nil {
+		return err
+	}
+	if into == nil || into.ID != mirror.ID {
+		return nil
+	}
+	return c.DeleteSchema(mirror.TargetDatabaseID, mirror.TargetCollection)
+}
+
+// deleteCollectionMirrors removes the mirrors from and into a collection
+func (c *CatalogDB) deleteCollectionMirrors(dbID string, collection string) error {
+	query := `
+		DELETE FROM collection_mirrors
+		WHERE (database_id = ? AND collection = ?) OR (target_database_id = ? AND target_collection = ?)
+	`
+	if _, err := c.db.Exec(query, dbID, collection, dbID, collection); err != nil {
+		return fmt.Errorf("failed to delete mirrors from catalog: %w", err)
+	}
+	return nil
+}
+
+// queryMirrors runs a query selecting mirrorColumns
+func (c *CatalogDB) queryMirrors(query string, args ...interface{}) ([]*models.Mirror, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mirrors: %w", err)
+	}
+	defer rows.Close()
+
+	mirrors := []*models.Mirror{}
+	for rows.Next() {
+		mirror, err := scanMirror(rows)
+		if err != nil {
+			return nil, err
+		}
+		mirrors = append(mirrors, mirror)
+	}
+	return mirrors, rows.Err()
+}
+
+// scanMirror scans a row of mirrorColumns
+func scanMirror(rows *sql.Rows) (*models.Mirror, error) {
+	var mirror models.Mirror
+	var fieldsJSON string
+	var createdAt int64
+	if err := rows.Scan(&mirror.ID, &mirror.DatabaseID, &mirror.Collection, &mirror.TargetDatabaseID,
+		&mirror.TargetCollection, &fieldsJSON, &mirror.Filter, &mirror.Visibility, &createdAt); err != nil {
+		return nil, fmt.Errorf("failed to scan mirror: %w", err)
+	}
+	if err := json.Unmarshal([]byte(fieldsJSON), &mirror.Fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mirror fields: %w", err)
+	}
+	mirror.CreatedAt = time.Unix(createdAt, 0)
+	return &mirror, nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestMirrorFields(t *testing.T) {
+	fields := map[string]models.FieldType{"title": models.FieldTypeString, "views": models.FieldTypeNumber, "email": models.FieldTypeString}
+
+	got, err := MirrorFields([]string{"title", "views"}, fields)
+	if err != nil {
+		t.Fatalf("MirrorFields() error = %v, want nil", err)
+	}
+	want := map[string]models.FieldType{"title": models.FieldTypeString, "views": models.FieldTypeNumber}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MirrorFields() = %v, want %v", got, want)
+	}
+
+	invalid := [][]string{
+		nil,
+		{"title", "missing"},
+		{"title", "title"},
+	}
+	for _, names := range invalid {
+		if _, err := MirrorFields(names, fields); err == nil {
+			t.Errorf("MirrorFields(%v) error = nil, want an error", names)
+		}
+	}
+}
+
+func TestRollBackMirror(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_rollback"
+	newTestDatabase(t, c, dbID)
+	if _, err := c.CreateSchema(dbID, "posts", map[string]models.FieldType{"title": models.FieldTypeString}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	mirror := func(target string) *models.Mirror {
+		m := &models.Mirror{DatabaseID: dbID, Collection: "posts", TargetDatabaseID: dbID, TargetCollection: target,
+			Fields: []string{"title"}, Visibility: models.VisibilityPublic}
+		if err := c.CreateMirror(m); err != nil {
+			t.Fatalf("CreateMirror(%s) error = %v", target, err)
+		}
+		return m
+	}
+
+	// A mirror's own target is dropped with it
+	m := mirror("titles")
+	if err := c.RollBackMirror(m); err != nil {
+		t.Fatalf("RollBackMirror() error = %v", err)
+	}
+	if schema, _ := c.GetSchema(dbID, "titles"); schema != nil {
+		t.Error("RollBackMirror() kept the target collection")
+	}
+
+	// A target released since keeps its collection
+	m = mirror("titles")
+	if err := c.DeleteMirror(dbID, "posts", m.ID); err != nil {
+		t.Fatalf("DeleteMirror() error = %v", err)
+	}
+	if err := c.RollBackMirror(m); err != nil {
+		t.Fatalf("RollBackMirror() after DeleteMirror error = %v", err)
+	}
+	if schema, _ := c.GetSchema(dbID, "titles"); schema == nil {
+		t.Error("RollBackMirror() dropped a collection that is no longer its target")
+	}
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// maxAllowedNetworks caps the networks of a database's allowlist
+const maxAllowedNetworks = 100
+
+// NormalizeNetworks validates an IP allowlist of CIDR ranges and single addresses,
+// returning the ranges in canonical form, sorted and without duplicates
+func NormalizeNetworks(networks []string) ([]string, error) {
+	if len(networks) > maxAllowedNetworks {
+		return nil, fmt.Errorf("network limit exceeded: allowlists can have at most %d networks", maxAllowedNetworks)
+	}
+	normalized := make([]string, 0, len(networks))
+	for _, network := range networks {
+		network = strings.TrimSpace(network)
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(network)
+			if addrErr != nil || addr.Zone() != "" {
+				return nil, fmt.Errorf("invalid network %q: expected a CIDR range or an IP address", network)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		// Clients reach IPv4 listeners as IPv4 addresses, so mapped ranges are kept as IPv4
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		normalized = append(normalized, prefix.Masked().String())
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
+
+// NetworksAllow reports whether an allowlist admits an address; an empty allowlist admits
+// every address
+func NetworksAllow(networks []string, addr netip.Addr) bool {
+	if len(networks) == 0 {
+		return true
+	}
+	addr = addr.Unmap()
+	for _, network := range networks {
+		if prefix, err := netip.ParsePrefix(network); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinList and splitList store an allowlist of networks or origins in a text column
+func joinList(values []string) string {
+	return strings.Join(values, ",")
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestNormalizeNetworks(t *testing.T) {
+	got, err := NormalizeNetworks([]string{"203.0.113.7", " 10.1.2.3/8", "2001:db8::1/32", "10.0.0.0/8", "::ffff:192.0.2.0/120"})
+	if err != nil {
+		t.Fatalf("NormalizeNetworks() error = %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.0/24", "2001:db8::/32", "203.0.113.7/32"}
+	if !slices.Equal(got, want) {
+		t.Errorf("NormalizeNetworks() = %v, want %v", got, want)
+	}
+
+	for _, network := range []string{"example.com", "10.0.0.0/33", "fe80::1%eth0", ""} {
+		if _, err := NormalizeNetworks([]string{network}); err == nil {
+			t.Errorf("NormalizeNetworks(%q) error = nil, want an error", network)
+		}
+	}
+	if _, err := NormalizeNetworks(make([]string, maxAllowedNetworks+1)); err == nil {
+		t.Errorf("NormalizeNetworks(%d networks) error = nil, want limit exceeded", maxAllowedNetworks+1)
+	}
+}
+
+func TestNetworksAllow(t *testing.T) {
+	networks := []string{"10.0.0.0/8", "2001:db8::/32"}
+	tests := []struct {
+		networks []string
+		addr     string
+		want     bool
+	}{
+		{networks, "10.20.30.40", true},
+		{networks, "::ffff:10.20.30.40", true},
+		{networks, "2001:db8::99", true},
+		{networks, "192.0.2.1", false},
+		{networks, "2001:db9::1", false},
+		{nil, "192.0.2.1", true},
+	}
+	for _, tt := range tests {
+		if got := NetworksAllow(tt.networks, netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("NetworksAllow(%v, %s) = %v, want %v", tt.networks, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestSetAllowedNetworks(t *testing.T) {
+	c := newTestCatalog(t)
+
+	created, err := c.CreateDatabase()
+	if err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	networks := []string{"10.0.0.0/8", "203.0.113.7/32"}
+	if err := c.SetAllowedNetworks(created.DatabaseID, networks); err != nil {
+		t.Fatalf("SetAllowedNetworks() error = %v", err)
+	}
+	db, err := c.GetDatabaseByWriteKey(created.WriteKey)
+	if err != nil || db == nil {
+		t.Fatalf("GetDatabaseByWriteKey() = %v, %v", db, err)
+	}
+	if !slices.Equal(db.AllowedNetworks, networks) {
+		t.Errorf("AllowedNetworks = %v, want %v", db.AllowedNetworks, networks)
+	}
+
+	if err := c.SetAllowedNetworks(created.DatabaseID, nil); err != nil {
+		t.Fatalf("SetAllowedNetworks(nil) error = %v", err)
+	}
+	if db, _ := c.GetDatabaseByID(created.DatabaseID); db.AllowedNetworks != nil {
+		t.Errorf("AllowedNetworks after clearing = %v, want nil", db.AllowedNetworks)
+	}
+	if err := c.SetAllowedNetworks("db_missing", networks); err == nil {
+		t.Error("SetAllowedNetworks(missing database) error = nil, want not found")
+	}
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// maxCORSOrigins caps the origins a database allows
+const maxCORSOrigins = 100
+
+// NormalizeOrigins validates a database's CORS origins, returning them as browsers send
+// them in the Origin header: lowercase, without a default port, sorted and without
+// duplicates. "*" allows any origin and replaces the rest of the list
+func NormalizeOrigins(origins []string) ([]string, error) {
+	if len(origins) > maxCORSOrigins {
+		return nil, fmt.Errorf("origin limit exceeded: databases can allow at most %d origins", maxCORSOrigins)
+	}
+	normalized := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		origin = strings.TrimSpace(origin)
+		if origin == "*" {
+			return []string{"*"}, nil
+		}
+		u, err := url.Parse(strings.ToLower(origin))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || strings.Contains(u.Host, "*") {
+			return nil, fmt.Errorf("invalid origin %q: expected scheme://host[:port], such as https://app.example.com", origin)
+		}
+		host := u.Host
+		if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+			host = strings.TrimSuffix(host, ":"+port)
+		}
+		normalized = append(normalized, u.Scheme+"://"+host)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
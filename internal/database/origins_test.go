@@ -0,0 +1,64 @@
+package database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeOrigins(t *testing.T) {
+	got, err := NormalizeOrigins([]string{"https://App.Example.com:443", " http://localhost:3000", "https://app.example.com/", "http://intranet:8080"})
+	if err != nil {
+		t.Fatalf("NormalizeOrigins() error = %v", err)
+	}
+	want := []string{"http://intranet:8080", "http://localhost:3000", "https://app.example.com"}
+	if !slices.Equal(got, want) {
+		t.Errorf("NormalizeOrigins() = %v, want %v", got, want)
+	}
+
+	if got, err := NormalizeOrigins([]string{"https://app.example.com", "*"}); err != nil || !slices.Equal(got, []string{"*"}) {
+		t.Errorf("NormalizeOrigins(with *) = %v, %v, want [*]", got, err)
+	}
+	for _, origin := range []string{"app.example.com", "ftp://app.example.com", "https://app.example.com/path", "https://user@app.example.com",
+		"https://*.example.com", "https://app.example.com?x=1", ""} {
+		if _, err := NormalizeOrigins([]string{origin}); err == nil {
+			t.Errorf("NormalizeOrigins(%q) error = nil, want an error", origin)
+		}
+	}
+	if _, err := NormalizeOrigins(make([]string, maxCORSOrigins+1)); err == nil {
+		t.Errorf("NormalizeOrigins(%d origins) error = nil, want limit exceeded", maxCORSOrigins+1)
+	}
+}
+
+func TestSetCORSOrigins(t *testing.T) {
+	c := newTestCatalog(t)
+
+	created, err := c.CreateDatabase()
+	if err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	origins := []string{"http://localhost:3000", "https://app.example.com"}
+	if err := c.SetCORSOrigins(created.DatabaseID, origins); err != nil {
+		t.Fatalf("SetCORSOrigins() error = %v", err)
+	}
+	db, err := c.GetDatabaseByID(created.DatabaseID)
+	if err != nil || db == nil {
+		t.Fatalf("GetDatabaseByID() = %v, %v", db, err)
+	}
+	if !slices.Equal(db.CORSOrigins, origins) {
+		t.Errorf("CORSOrigins = %v, want %v", db.CORSOrigins, origins)
+	}
+	databases, err := c.ListDatabases()
+	if err != nil || len(databases) != 1 || !slices.Equal(databases[0].CORSOrigins, origins) {
+		t.Errorf("ListDatabases() = %v, %v, want the CORS origins", databases, err)
+	}
+
+	if err := c.SetCORSOrigins(created.DatabaseID, nil); err != nil {
+		t.Fatalf("SetCORSOrigins(nil) error = %v", err)
+	}
+	if db, _ := c.GetDatabaseByID(created.DatabaseID); db.CORSOrigins != nil {
+		t.Errorf("CORSOrigins after clearing = %v, want nil", db.CORSOrigins)
+	}
+	if err := c.SetCORSOrigins("db_missing", origins); err == nil {
+		t.Error("SetCORSOrigins(missing database) error = nil, want not found")
+	}
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// Web console users own the databases they claimed with the write key, recorded by the
+// identity provider's subject of each user
+
+// maxOwnedDatabases caps the databases one console user owns
+const maxOwnedDatabases = 1000
+
+// AddOwner records a console user as an owner of a database; owning it already is not
+// an error
+func (c *CatalogDB) AddOwner(dbID string, subject string) error {
+	var count int
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM database_owners WHERE subject = ?`, subject).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count owned databases: %w", err)
+	}
+	if count >= maxOwnedDatabases {
+		return fmt.Errorf("owned database limit exceeded: users can own at most %d databases", maxOwnedDatabases)
+	}
+	query := `INSERT OR IGNORE INTO database_owners (database_id, subject, created_at) VALUES (?, ?, ?)`
+	if _, err := c.db.Exec(query, dbID, subject, time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to add owner: %w", err)
+	}
+	return nil
+}
+
+// RemoveOwner forgets a console user's ownership of a database
+func (c *CatalogDB) RemoveOwner(dbID string, subject string) error {
+	result, err := c.db.Exec(`DELETE FROM database_owners WHERE database_id = ? AND subject = ?`, dbID, subject)
+	if err != nil {
+		return fmt.Errorf("failed to remove owner: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("owner not found")
+	}
+	return nil
+}
+
+// IsOwner reports whether a console user owns a database
+func (c *CatalogDB) IsOwner(dbID string, subject string) (bool, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM database_owners WHERE database_id = ? AND subject = ?`
+	if err := c.db.QueryRow(query, dbID, subject).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check owner: %w", err)
+	}
+	return count > 0, nil
+}
+
+// OwnedDatabases returns the databases a console user owns, ordered by ID
+func (c *CatalogDB) OwnedDatabases(subject string) ([]*models.Database, error) {
+	rows, err := c.db.Query(`SELECT database_id FROM database_owners WHERE subject = ? ORDER BY database_id`, subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list owned databases: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan owned database: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list owned databases: %w", err)
+	}
+
+	databases := []*models.Database{}
+	for _, id := range ids {
+		db, err := c.GetDatabaseByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if db != nil {
+			databases = append(databases, db)
+		}
+	}
+	return databases, nil
+}
@@ -0,0 +1,51 @@
+package database
+
+import "testing"
+
+func TestOwners(t *testing.T) {
+	c := newTestCatalog(t)
+
+	for _, id := range []string{"db_owned_b", "db_owned_a", "db_other"} {
+		newTestDatabase(t, c, id)
+	}
+	for _, id := range []string{"db_owned_b", "db_owned_a", "db_owned_a"} {
+		if err := c.AddOwner(id, "user-1"); err != nil {
+			t.Fatalf("AddOwner(%s) error = %v", id, err)
+		}
+	}
+	if err := c.AddOwner("db_other", "user-2"); err != nil {
+		t.Fatalf("AddOwner(db_other) error = %v", err)
+	}
+
+	owned, err := c.OwnedDatabases("user-1")
+	if err != nil {
+		t.Fatalf("OwnedDatabases() error = %v", err)
+	}
+	if len(owned) != 2 || owned[0].ID != "db_owned_a" || owned[1].ID != "db_owned_b" {
+		t.Errorf("OwnedDatabases(user-1) = %v, want db_owned_a and db_owned_b", owned)
+	}
+	if ok, err := c.IsOwner("db_other", "user-1"); err != nil || ok {
+		t.Errorf("IsOwner(db_other, user-1) = %v, %v, want false", ok, err)
+	}
+	if ok, err := c.IsOwner("db_owned_a", "user-1"); err != nil || !ok {
+		t.Errorf("IsOwner(db_owned_a, user-1) = %v, %v, want true", ok, err)
+	}
+
+	if err := c.RemoveOwner("db_owned_b", "user-1"); err != nil {
+		t.Fatalf("RemoveOwner() error = %v", err)
+	}
+	if err := c.RemoveOwner("db_owned_b", "user-1"); err == nil {
+		t.Error("RemoveOwner() twice error = nil, want not found")
+	}
+
+	// Deleting a database forgets its owners
+	if err := c.DeleteDatabase("db_owned_a"); err != nil {
+		t.Fatalf("DeleteDatabase() error = %v", err)
+	}
+	if ok, _ := c.IsOwner("db_owned_a", "user-1"); ok {
+		t.Error("IsOwner() after deletion = true, want false")
+	}
+	if owned, err := c.OwnedDatabases("user-1"); err != nil || len(owned) != 0 {
+		t.Errorf("OwnedDatabases(user-1) = %v, %v, want none", owned, err)
+	}
+}
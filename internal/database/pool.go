@@ -0,0 +1,229 @@
+package database
+
+import (
+	"container/list"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PoolConfig bounds how many database files are kept open between operations
+type PoolConfig struct {
+	MaxOpen     int           // Database files kept open; 0 opens and closes the file for every operation
+	IdleTimeout time.Duration // Close files unused for this long; 0 keeps them until evicted
+}
+
+// dbPool keeps a *sql.DB handle open per database file, so operations don't pay for
+// opening the file and preparing its schema each time. Beyond MaxOpen the least recently
+// used handles are closed, as are handles left idle past IdleTimeout. Handles are
+// reference counted and never closed while an operation is using them, so the pool can
+// briefly hold more than MaxOpen when every handle is busy
+type dbPool struct {
+	config  PoolConfig
+	mu      sync.Mutex
+	entries map[string]*list.Element // Path -> element holding a *poolEntry
+	lru     *list.List               // Most recently used first
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// poolEntry is an open database file
+type poolEntry struct {
+	path     string
+	db       *sql.DB
+	refs     int // Operations using the handle
+	lastUsed time.Time
+	removed  bool // No longer in the pool; closed once the last operation releases it
+}
+
+// newDBPool creates a pool, starting idle eviction if an idle timeout is set
+func newDBPool(config PoolConfig) *dbPool {
+	p := &dbPool{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	if config.MaxOpen > 0 && config.IdleTimeout > 0 {
+		go p.evictIdleRoutine()
+	} else {
+		close(p.done)
+	}
+
+	return p
+}
+
+// acquire returns the handle for a database file and a function to release it
+// The release function must be called exactly once, when the operation is done
+func (p *dbPool) acquire(path string) (*sql.DB, func(), error) {
+	if p.config.MaxOpen <= 0 {
+		db, err := sql.Open(sqlDriverName(), dataSourceName(path))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		return db, func() { db.Close() }, nil
+	}
+
+	p.mu.Lock()
+	if elem, exists := p.entries[path]; exists {
+		entry := elem.Value.(*poolEntry)
+		entry.refs++
+		p.lru.MoveToFront(elem)
+		p.mu.Unlock()
+		return entry.db, func() { p.release(entry) }, nil
+	}
+
+	db, err := sql.Open(sqlDriverName(), dataSourceName(path))
+	if err != nil {
+		p.mu.Unlock()
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	entry := &poolEntry{path: path, db: db, refs: 1, lastUsed: time.Now()}
+	p.entries[path] = p.lru.PushFront(entry)
+	evicted := p.evictLocked()
+	p.mu.Unlock()
+
+	closeAll(evicted)
+	return db, func() { p.release(entry) }, nil
+}
+
+// dataSourceName returns the DSN for a database file
+// Transactions take the write lock when they begin, so ones that read before writing wait
+// for each other instead of failing with "database is locked" when both try to write
+func dataSourceName(path string) string {
+	return withDriverParams(path, "_txlock=immediate")
+}
+
+// release marks an operation on a handle as done
+func (p *dbPool) release(entry *poolEntry) {
+	p.mu.Lock()
+	entry.refs--
+	entry.lastUsed = time.Now()
+
+	var evicted []*sql.DB
+	if entry.removed {
+		if entry.refs == 0 {
+			evicted = append(evicted, entry.db)
+		}
+	} else {
+		evicted = p.evictLocked()
+	}
+	p.mu.Unlock()
+
+	closeAll(evicted)
+}
+
+// evictLocked removes unused handles, least recently used first, until the pool is within MaxOpen
+// Returns the handles to close once the lock is released
+func (p *dbPool) evictLocked() []*sql.DB {
+	var evicted []*sql.DB
+	for elem := p.lru.Back(); elem != nil && len(p.entries) > p.config.MaxOpen; {
+		prev := elem.Prev()
+		if entry := elem.Value.(*poolEntry); entry.refs == 0 {
+			p.removeLocked(elem)
+			evicted = append(evicted, entry.db)
+		}
+		elem = prev
+	}
+	return evicted
+}
+
+// removeLocked takes an entry out of the pool
+func (p *dbPool) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*poolEntry)
+	entry.removed = true
+	p.lru.Remove(elem)
+	delete(p.entries, entry.path)
+}
+
+// remove closes the handle for a file that is about to be deleted
+// A handle still in use is closed when its last operation releases it
+func (p *dbPool) remove(path string) {
+	p.mu.Lock()
+	elem, exists := p.entries[path]
+	if !exists {
+		p.mu.Unlock()
+		return
+	}
+	entry := elem.Value.(*poolEntry)
+	p.removeLocked(elem)
+	inUse := entry.refs > 0
+	p.mu.Unlock()
+
+	if !inUse {
+		entry.db.Close()
+	}
+}
+
+// closeIdle closes unused handles that haven't been used since before cutoff
+func (p *dbPool) closeIdle(cutoff time.Time) {
+	p.mu.Lock()
+	var idle []*sql.DB
+	for elem := p.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if entry := elem.Value.(*poolEntry); entry.refs == 0 && entry.lastUsed.Before(cutoff) {
+			p.removeLocked(elem)
+			idle = append(idle, entry.db)
+		}
+		elem = prev
+	}
+	p.mu.Unlock()
+
+	closeAll(idle)
+}
+
+// evictIdleRoutine periodically closes idle handles until the pool is closed
+func (p *dbPool) evictIdleRoutine() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.closeIdle(time.Now().Add(-p.config.IdleTimeout))
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// openCount returns the number of handles in the pool
+func (p *dbPool) openCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// Close stops idle eviction and closes every handle
+// Operations still running keep their handles until they release them
+func (p *dbPool) Close() {
+	close(p.stop)
+	<-p.done
+
+	p.mu.Lock()
+	var open []*sql.DB
+	for elem := p.lru.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*poolEntry)
+		p.removeLocked(elem)
+		if entry.refs == 0 {
+			open = append(open, entry.db)
+		}
+		elem = next
+	}
+	p.mu.Unlock()
+
+	closeAll(open)
+}
+
+// closeAll closes database handles
+func closeAll(dbs []*sql.DB) {
+	for _, db := range dbs {
+		db.Close()
+	}
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// isClosed reports whether a handle has been closed
+func isClosed(db *sql.DB) bool {
+	return db.Ping() != nil
+}
+
+func TestDBPool_ReusesHandle(t *testing.T) {
+	dir := t.TempDir()
+	p := newDBPool(PoolConfig{MaxOpen: 2})
+	defer p.Close()
+
+	first, release, err := p.acquire(filepath.Join(dir, "a.db"))
+	if err != nil {
+		t.Fatalf("acquire() error = %v, want nil", err)
+	}
+	release()
+
+	second, release, err := p.acquire(filepath.Join(dir, "a.db"))
+	if err != nil {
+		t.Fatalf("acquire() error = %v, want nil", err)
+	}
+	release()
+
+	if first != second {
+		t.Error("acquire() opened a second handle for the same file")
+	}
+	if isClosed(first) {
+		t.Error("pooled handle was closed on release")
+	}
+}
+
+func TestDBPool_EvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	p := newDBPool(PoolConfig{MaxOpen: 2})
+	defer p.Close()
+
+	handles := map[string]*sql.DB{}
+	for _, name := range []string{"a", "b", "a", "c"} {
+		db, release, err := p.acquire(filepath.Join(dir, name+".db"))
+		if err != nil {
+			t.Fatalf("acquire(%s) error = %v, want nil", name, err)
+		}
+		handles[name] = db
+		release()
+	}
+
+	if got := p.openCount(); got != 2 {
+		t.Errorf("openCount() = %d, want 2", got)
+	}
+	if !isClosed(handles["b"]) {
+		t.Error("least recently used handle b was not closed")
+	}
+	if isClosed(handles["a"]) || isClosed(handles["c"]) {
+		t.Error("recently used handles a and c should stay open")
+	}
+}
+
+func TestDBPool_KeepsHandlesInUse(t *testing.T) {
+	dir := t.TempDir()
+	p := newDBPool(PoolConfig{MaxOpen: 1})
+	defer p.Close()
+
+	busy, releaseBusy, err := p.acquire(filepath.Join(dir, "a.db"))
+	if err != nil {
+		t.Fatalf("acquire() error = %v, want nil", err)
+	}
+	_, release, err := p.acquire(filepath.Join(dir, "b.db"))
+	if err != nil {
+		t.Fatalf("acquire() error = %v, want nil", err)
+	}
+	release()
+
+	if isClosed(busy) {
+		t.Fatal("handle in use was closed")
+	}
+
+	releaseBusy()
+	if got := p.openCount(); got != 1 {
+		t.Errorf("openCount() = %d, want 1 once handles are released", got)
+	}
+}
+
+func TestDBPool_CloseIdle(t *testing.T) {
+	dir := t.TempDir()
+	p := newDBPool(PoolConfig{MaxOpen: 4})
+	defer p.Close()
+
+	db, release, err := p.acquire(filepath.Join(dir, "a.db"))
+	if err != nil {
+		t.Fatalf("acquire() error = %v, want nil", err)
+	}
+	release()
+
+	p.closeIdle(time.Now().Add(-time.Minute))
+	if isClosed(db) {
+		t.Fatal("closeIdle() closed a recently used handle")
+	}
+
+	p.closeIdle(time.Now().Add(time.Minute))
+	if !isClosed(db) {
+		t.Error("closeIdle() left an idle handle open")
+	}
+	if got := p.openCount(); got != 0 {
+		t.Errorf("openCount() = %d, want 0", got)
+	}
+}
+
+func TestDBPool_Remove(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.db")
+	p := newDBPool(PoolConfig{MaxOpen: 4})
+	defer p.Close()
+
+	db, release, err := p.acquire(path)
+	if err != nil {
+		t.Fatalf("acquire() error = %v, want nil", err)
+	}
+
+	p.remove(path)
+	if isClosed(db) {
+		t.Fatal("remove() closed a handle in use")
+	}
+
+	release()
+	if !isClosed(db) {
+		t.Error("removed handle was not closed on release")
+	}
+}
+
+func TestDBPool_Disabled(t *testing.T) {
+	dir := t.TempDir()
+	p := newDBPool(PoolConfig{})
+	defer p.Close()
+
+	db, release, err := p.acquire(filepath.Join(dir, "a.db"))
+	if err != nil {
+		t.Fatalf("acquire() error = %v, want nil", err)
+	}
+	release()
+
+	if !isClosed(db) {
+		t.Error("handle should be closed on release when pooling is disabled")
+	}
+}
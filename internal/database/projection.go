@@ -0,0 +1,76 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"jsondrop/internal/models"
+)
+
+// Projection selects which metadata columns and data fields a query returns
+type Projection struct {
+	metadata map[string]bool
+	data     []string
+}
+
+// ParseProjection parses a comma-separated fields parameter such as "id,updated_at,name"
+// Returns nil when the parameter is empty, meaning whole documents are returned
+func ParseProjection(value string, fields map[string]models.FieldType) (*Projection, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	p := &Projection{metadata: make(map[string]bool)}
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("invalid fields: empty field name")
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		switch {
+		case metadataColumns[name]:
+			p.metadata[name] = true
+		case fields[name] != "":
+			p.data = append(p.data, name)
+		default:
+			return nil, fmt.Errorf("invalid fields: unknown field %s", name)
+		}
+	}
+	return p, nil
+}
+
+// Apply returns the projected form of a document
+// Data fields are nested under "data" as in a full document; absent fields are omitted
+func (p *Projection) Apply(doc *models.Document) map[string]interface{} {
+	out := make(map[string]interface{})
+	if p.metadata["id"] {
+		out["id"] = doc.ID
+	}
+	if p.metadata["created_at"] {
+		out["created_at"] = doc.CreatedAt
+	}
+	if p.metadata["updated_at"] {
+		out["updated_at"] = doc.UpdatedAt
+	}
+
+	if len(p.data) > 0 {
+		data := make(map[string]interface{}, len(p.data))
+		for _, name := range p.data {
+			if value, exists := doc.Data[name]; exists {
+				data[name] = value
+			}
+		}
+		out["data"] = data
+	}
+
+	// Joined documents are requested separately, so projection leaves them whole
+	if doc.Joined != nil {
+		out["joined"] = doc.Joined
+	}
+	return out
+}
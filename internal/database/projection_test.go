@@ -0,0 +1,57 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestProjection(t *testing.T) {
+	doc := &models.Document{
+		ID:         "doc_1",
+		Collection: "users",
+		Data:       map[string]interface{}{"name": "Alice", "age": float64(30)},
+		Visibility: models.VisibilityReadKey,
+		CreatedAt:  time.Unix(100, 0),
+		UpdatedAt:  time.Unix(200, 0),
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  map[string]interface{}
+	}{
+		{
+			name:  "metadata and data fields",
+			value: "id,updated_at,name",
+			want: map[string]interface{}{
+				"id":         "doc_1",
+				"updated_at": time.Unix(200, 0),
+				"data":       map[string]interface{}{"name": "Alice"},
+			},
+		},
+		{name: "metadata only", value: "created_at", want: map[string]interface{}{"created_at": time.Unix(100, 0)}},
+		{name: "repeated field", value: "age, age", want: map[string]interface{}{"data": map[string]interface{}{"age": float64(30)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseProjection(tt.value, sortTestFields)
+			if err != nil {
+				t.Fatalf("ParseProjection(%q) error = %v", tt.value, err)
+			}
+			if got := p.Apply(doc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if p, err := ParseProjection("", sortTestFields); p != nil || err != nil {
+		t.Errorf("ParseProjection(\"\") = %v, %v; want nil, nil", p, err)
+	}
+	if _, err := ParseProjection("id,email", sortTestFields); err == nil {
+		t.Error("ParseProjection with an unknown field should fail")
+	}
+}
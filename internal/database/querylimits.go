@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// QueryLimitError reports a document query stopped by the query time or row limit
+type QueryLimitError struct {
+	Timeout time.Duration // Set when the query ran out of time
+	Rows    int           // Set when the query read too many rows
+}
+
+func (e *QueryLimitError) Error() string {
+	if e.Timeout > 0 {
+		return fmt.Sprintf("query timeout: query ran longer than %s", e.Timeout)
+	}
+	return fmt.Sprintf("scan limit exceeded: query read more than %d rows", e.Rows)
+}
+
+// QueryBudget bounds one document query by the QueryTimeout and MaxRowsScanned limits
+// The deadline interrupts the query in the database; rows are counted as they are read,
+// including rows a read policy then filters out
+type QueryBudget struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	limits  Limits
+	scanned int
+}
+
+// NewQueryBudget starts the clock on a query; Done must be called when it finishes
+func (l Limits) NewQueryBudget() *QueryBudget {
+	ctx, cancel := context.WithCancel(context.Background())
+	if l.QueryTimeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), l.QueryTimeout)
+	}
+	return &QueryBudget{ctx: ctx, cancel: cancel, limits: l}
+}
+
+// Context returns the context to run the query with
+func (b *QueryBudget) Context() context.Context {
+	return b.ctx
+}
+
+// Done releases the query's deadline
+func (b *QueryBudget) Done() {
+	b.cancel()
+}
+
+// Scan counts one row read, failing once the query has read too many rows or run too long
+func (b *QueryBudget) Scan() error {
+	b.scanned++
+	if b.limits.MaxRowsScanned > 0 && b.scanned > b.limits.MaxRowsScanned {
+		return &QueryLimitError{Rows: b.limits.MaxRowsScanned}
+	}
+	return b.Err(b.ctx.Err())
+}
+
+// Err returns err, or a QueryLimitError if err came from the query running out of time
+func (b *QueryBudget) Err(err error) error {
+	if err != nil && errors.Is(b.ctx.Err(), context.DeadlineExceeded) {
+		return &QueryLimitError{Timeout: b.limits.QueryTimeout}
+	}
+	return err
+}
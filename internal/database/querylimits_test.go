@@ -0,0 +1,54 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestQueryLimits(t *testing.T) {
+	c := newTestCatalogWith(t, Limits{MaxRowsScanned: 3}, nil)
+
+	const dbID = "db_limits"
+	newTestDatabase(t, c, dbID)
+	if _, err := c.CreateSchema(dbID, "notes", map[string]models.FieldType{"n": models.FieldTypeNumber}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := c.InsertDocument(dbID, "notes", map[string]interface{}{"n": float64(i)}, ""); err != nil {
+			t.Fatalf("InsertDocument() error = %v", err)
+		}
+	}
+
+	if docs, err := c.QueryDocuments(dbID, "notes", 3, 0, nil, nil, nil, nil); err != nil || len(docs) != 3 {
+		t.Errorf("QueryDocuments(limit 3) = %d documents, %v, want 3 within the row limit", len(docs), err)
+	}
+
+	var limitErr *QueryLimitError
+	_, err := c.QueryDocuments(dbID, "notes", 0, 0, nil, nil, nil, nil)
+	if !errors.As(err, &limitErr) || limitErr.Rows != 3 {
+		t.Errorf("QueryDocuments(no limit) error = %v, want the row limit", err)
+	}
+	_, err = c.AggregateDocuments(dbID, "notes", nil, "n", nil, nil, nil)
+	if !errors.As(err, &limitErr) || limitErr.Rows != 3 {
+		t.Errorf("AggregateDocuments() error = %v, want the row limit", err)
+	}
+
+	// A deadline that has already passed stops the query before it reads a row
+	c.limits = Limits{QueryTimeout: time.Nanosecond}
+	_, err = c.QueryDocuments(dbID, "notes", 0, 0, nil, nil, nil, nil)
+	if !errors.As(err, &limitErr) || limitErr.Timeout != time.Nanosecond {
+		t.Errorf("QueryDocuments() error = %v, want the query timeout", err)
+	}
+	if want := fmt.Sprintf("query timeout: query ran longer than %s", time.Nanosecond); err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+
+	c.limits = Limits{}
+	if docs, err := c.QueryDocuments(dbID, "notes", 0, 0, nil, nil, nil, nil); err != nil || len(docs) != 5 {
+		t.Errorf("QueryDocuments(unlimited) = %d documents, %v, want 5", len(docs), err)
+	}
+}
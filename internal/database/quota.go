@@ -0,0 +1,155 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"jsondrop/internal/models"
+)
+
+// Writes reserve quota before storing anything and release it if the write fails or
+// removes data. A reservation checks the limit and adds to quota_used in one statement, so
+// concurrent writes can't all pass the check and together exceed the limit.
+//
+// Some paths ignore failures to release quota, so the tracked usage can drift from what is
+// stored. Recalculation recomputes it from the documents themselves, counting their stored
+// size as writes do.
+
+// QuotaSummary reports a recalculation run over every database
+type QuotaSummary struct {
+	Checked   int // Databases recalculated
+	Corrected int // Databases whose tracked usage was wrong
+	Failed    int // Databases that couldn't be recalculated
+}
+
+// reserveQuota adds size bytes to a database's quota usage, failing if that would exceed its limit
+func (c *CatalogDB) reserveQuota(dbID string, size int64) error {
+	query := `UPDATE databases SET quota_used = quota_used + ? WHERE id = ? AND quota_used + ? <= quota_limit`
+	result, err := c.db.Exec(query, size, dbID, size)
+	if err != nil {
+		return fmt.Errorf("failed to update quota: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+		return nil
+	}
+
+	// Nothing was reserved; find out why
+	var quotaUsed, quotaLimit int64
+	err = c.db.QueryRow(`SELECT quota_used, quota_limit FROM databases WHERE id = ?`, dbID).Scan(&quotaUsed, &quotaLimit)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("database not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get quota: %w", err)
+	}
+	return fmt.Errorf("quota exceeded: current %d bytes, limit %d bytes, attempted to add %d bytes",
+		quotaUsed, quotaLimit, size)
+}
+
+// releaseQuota subtracts size bytes from a database's quota usage, never going below zero
+func (c *CatalogDB) releaseQuota(dbID string, size int64) {
+	if size <= 0 {
+		return
+	}
+	query := `UPDATE databases SET quota_used = MAX(quota_used - ?, 0) WHERE id = ?`
+	if _, err := c.db.Exec(query, size, dbID); err != nil {
+		// Log but don't fail; recalculation corrects the usage
+	}
+}
+
+// SetQuotaLimit changes a database's quota limit in bytes
+// Lowering the limit below current usage keeps the data; later writes fail until usage drops
+func (c *CatalogDB) SetQuotaLimit(dbID string, limit int64) error {
+	result, err := c.db.Exec(`UPDATE databases SET quota_limit = ? WHERE id = ?`, limit, dbID)
+	if err != nil {
+		return fmt.Errorf("failed to update quota_limit: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("database not found")
+	}
+	return nil
+}
+
+// RecalculateQuota recomputes a database's quota usage from its stored documents
+// Soft-deleted documents count, as they do until purged. A write that lands while the
+// documents are being summed can leave usage off by that write until the next recalculation
+func (c *CatalogDB) RecalculateQuota(dbID string) (*models.QuotaRecalculationResponse, error) {
+	info, err := c.GetDatabaseByID(dbID)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, fmt.Errorf("database not found")
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	used, err := storedSize(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.UpdateQuotaUsed(dbID, used); err != nil {
+		return nil, err
+	}
+
+	return &models.QuotaRecalculationResponse{
+		DatabaseID:        dbID,
+		PreviousQuotaUsed: info.QuotaUsed,
+		QuotaUsed:         used,
+		QuotaLimit:        info.QuotaLimit,
+	}, nil
+}
+
+// RecalculateQuotas recomputes the quota usage of every database
+// Failures don't stop the run; they are counted and returned together
+func (c *CatalogDB) RecalculateQuotas() (QuotaSummary, error) {
+	var summary QuotaSummary
+
+	ids, err := c.listDatabaseIDs()
+	if err != nil {
+		return summary, err
+	}
+
+	var errs []error
+	for _, dbID := range ids {
+		result, err := c.RecalculateQuota(dbID)
+		if err != nil {
+			summary.Failed++
+			errs = append(errs, fmt.Errorf("%s: %w", dbID, err))
+			continue
+		}
+		summary.Checked++
+		if result.QuotaUsed != result.PreviousQuotaUsed {
+			summary.Corrected++
+		}
+	}
+
+	return summary, errors.Join(errs...)
+}
+
+// storedSize sums the stored size in bytes of every document in a database file
+// Compressed documents count at their compressed size, as they are charged
+func storedSize(db *sql.DB) (int64, error) {
+	collections, err := collectionNames(db)
+	if err != nil {
+		return 0, err
+	}
+
+	// LENGTH counts characters in text, so the data is measured as a blob
+	var total int64
+	for _, collection := range collections {
+		var size int64
+		query := fmt.Sprintf(`SELECT COALESCE(SUM(LENGTH(CAST(data AS BLOB))), 0) FROM %s`, QuoteIdentifier(collection))
+		if err := db.QueryRow(query).Scan(&size); err != nil {
+			return 0, fmt.Errorf("failed to size collection %s: %w", collection, err)
+		}
+		total += size
+	}
+
+	return total, nil
+}
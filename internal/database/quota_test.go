@@ -0,0 +1,139 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestStoredSize(t *testing.T) {
+	db, err := sql.Open(sqlDriverName(), withDriverParams(filepath.Join(t.TempDir(), "a.db")))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	statements := []string{
+		`CREATE TABLE _collections (name TEXT PRIMARY KEY, created_at INTEGER NOT NULL)`,
+		`INSERT INTO _collections VALUES ('posts', 0), ('odd"name', 0), ('empty', 0)`,
+		`CREATE TABLE posts (id TEXT PRIMARY KEY, data TEXT, deleted_at INTEGER)`,
+		`CREATE TABLE "odd""name" (id TEXT PRIMARY KEY, data TEXT, deleted_at INTEGER)`,
+		`CREATE TABLE empty (id TEXT PRIMARY KEY, data TEXT, deleted_at INTEGER)`,
+		// Multi-byte text counts in bytes, and soft-deleted documents still count
+		`INSERT INTO posts VALUES ('a', '{"t":"é"}', NULL), ('b', '{}', 1700000000)`,
+		`INSERT INTO "odd""name" VALUES ('c', X'1f8b0800', NULL)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	got, err := storedSize(db)
+	if err != nil {
+		t.Fatalf("storedSize() error = %v, want nil", err)
+	}
+	if want := int64(len(`{"t":"é"}`) + len(`{}`) + 4); got != want {
+		t.Errorf("storedSize() = %d, want %d", got, want)
+	}
+}
+
+func TestReserveQuota_Concurrent(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_quota"
+	if _, err := c.CreateDatabaseWithKeys(dbID, "wk_quota", "rk_quota", 1000); err != nil {
+		t.Fatalf("CreateDatabaseWithKeys() error = %v", err)
+	}
+
+	// Twice as many reservations as fit; exactly the ones that fit should succeed
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.reserveQuota(dbID, 100)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				reserved++
+			} else if !strings.HasPrefix(err.Error(), "quota exceeded") {
+				t.Errorf("reserveQuota() error = %v, want quota exceeded", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	info, err := c.GetDatabaseByID(dbID)
+	if err != nil {
+		t.Fatalf("GetDatabaseByID() error = %v", err)
+	}
+	if reserved != 10 || info.QuotaUsed != 1000 {
+		t.Errorf("reserved %d times for %d bytes, want 10 times for 1000 bytes", reserved, info.QuotaUsed)
+	}
+
+	c.releaseQuota(dbID, 1500)
+	if info, _ := c.GetDatabaseByID(dbID); info.QuotaUsed != 0 {
+		t.Errorf("quota_used after releasing more than reserved = %d, want 0", info.QuotaUsed)
+	}
+
+	if err := c.reserveQuota("db_missing", 1); err == nil || err.Error() != "database not found" {
+		t.Errorf("reserveQuota() for unknown database: error = %v, want database not found", err)
+	}
+}
+
+func TestUpdateDocument_ConcurrentQuota(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_updates"
+	newTestDatabase(t, c, dbID)
+	if _, err := c.CreateSchema(dbID, "notes", map[string]models.FieldType{"text": models.FieldTypeString}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	doc, err := c.InsertDocument(dbID, "notes", map[string]interface{}{"text": "x"}, "")
+	if err != nil {
+		t.Fatalf("InsertDocument() error = %v", err)
+	}
+
+	// Updates of different sizes race on one document; every size change must be charged once
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := c.UpdateDocument(dbID, "notes", doc.ID, map[string]interface{}{"text": strings.Repeat("y", i*10)}, "", 0)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("UpdateDocument() error = %v", err)
+		}
+	}
+
+	final, err := c.GetDocument(dbID, "notes", doc.ID, nil)
+	if err != nil {
+		t.Fatalf("GetDocument() error = %v", err)
+	}
+	if final.Revision != writers+1 {
+		t.Errorf("revision = %d, want %d", final.Revision, writers+1)
+	}
+	info, _ := c.GetDatabaseByID(dbID)
+	result, err := c.RecalculateQuota(dbID)
+	if err != nil {
+		t.Fatalf("RecalculateQuota() error = %v", err)
+	}
+	if info.QuotaUsed != result.QuotaUsed {
+		t.Errorf("quota_used after concurrent updates = %d, want the stored size %d", info.QuotaUsed, result.QuotaUsed)
+	}
+}
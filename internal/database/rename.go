@@ -0,0 +1,176 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// RenameSchema renames a collection, keeping its documents, read filter and search index
+// The table rename runs in a transaction that commits only once the catalog is updated,
+// and the catalog entry is restored if that commit fails
+func (c *CatalogDB) RenameSchema(dbID string, name string, newName string) (*models.Schema, error) {
+	if err := ValidateSchemaName(newName); err != nil {
+		return nil, err
+	}
+
+	schema, err := c.GetSchema(dbID, name)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("schema not found")
+	}
+	if newName == name {
+		return schema, nil
+	}
+
+	existing, err := c.GetSchema(dbID, newName)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("schema already exists: %s", newName)
+	}
+
+	indexes, err := c.ListIndexes(dbID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := renameCollectionTables(tx, name, newName, schema.Fields, indexes); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE schemas SET name = ? WHERE database_id = ? AND name = ?`
+	if _, err := c.db.Exec(query, newName, dbID, name); err != nil {
+		return nil, fmt.Errorf("failed to rename schema: %w", err)
+	}
+	defer c.schemas.invalidate(dbID, newName)
+	defer c.schemas.invalidate(dbID, name)
+	indexQuery := `UPDATE collection_indexes SET collection = ? WHERE database_id = ? AND collection = ?`
+	if _, err := c.db.Exec(indexQuery, newName, dbID, name); err != nil {
+		c.db.Exec(query, name, dbID, newName)
+		return nil, fmt.Errorf("failed to rename indexes: %w", err)
+	}
+	mirrorQuery := `UPDATE collection_mirrors SET collection = ? WHERE database_id = ? AND collection = ?`
+	if _, err := c.db.Exec(mirrorQuery, newName, dbID, name); err != nil {
+		c.db.Exec(query, name, dbID, newName)
+		c.db.Exec(indexQuery, name, dbID, newName)
+		return nil, fmt.Errorf("failed to rename mirrors: %w", err)
+	}
+	webhookQuery := `UPDATE webhooks SET collection = ? WHERE database_id = ? AND collection = ?`
+	if _, err := c.db.Exec(webhookQuery, newName, dbID, name); err != nil {
+		c.db.Exec(query, name, dbID, newName)
+		c.db.Exec(indexQuery, name, dbID, newName)
+		c.db.Exec(mirrorQuery, name, dbID, newName)
+		return nil, fmt.Errorf("failed to rename webhooks: %w", err)
+	}
+	scopeQuery := `UPDATE scoped_key_collections SET collection = ? WHERE database_id = ? AND collection = ?`
+	if _, err := c.db.Exec(scopeQuery, newName, dbID, name); err != nil {
+		c.db.Exec(query, name, dbID, newName)
+		c.db.Exec(indexQuery, name, dbID, newName)
+		c.db.Exec(mirrorQuery, name, dbID, newName)
+		c.db.Exec(webhookQuery, name, dbID, newName)
+		return nil, fmt.Errorf("failed to rename scoped keys: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		// Rollback: restore the catalog entries
+		c.db.Exec(query, name, dbID, newName)
+		c.db.Exec(indexQuery, name, dbID, newName)
+		c.db.Exec(mirrorQuery, name, dbID, newName)
+		c.db.Exec(webhookQuery, name, dbID, newName)
+		c.db.Exec(scopeQuery, name, dbID, newName)
+		return nil, fmt.Errorf("failed to commit rename: %w", err)
+	}
+
+	schema.Name = newName
+
+	// Broadcast rename event on the old collection, where its subscribers are listening
+	if c.broadcaster != nil {
+		event := models.ChangeEvent{
+			EventType:  "schema_renamed",
+			DatabaseID: dbID,
+			Collection: name,
+			DocumentID: "",
+			Data: map[string]interface{}{
+				"schema_name": name,
+				"new_name":    newName,
+			},
+			Timestamp: time.Now(),
+		}
+		c.broadcaster.Broadcast(dbID, event)
+	}
+
+	return schema, nil
+}
+
+// renameCollectionTables renames a collection's table, indexes, search index and registry entry
+func renameCollectionTables(tx *sql.Tx, name string, newName string, fields map[string]models.FieldType, indexes []*models.Index) error {
+	renameSQL := fmt.Sprintf("ALTER TABLE %s RENAME TO %s", QuoteIdentifier(name), QuoteIdentifier(newName))
+	if _, err := tx.Exec(renameSQL); err != nil {
+		return fmt.Errorf("failed to rename table: %w", err)
+	}
+
+	// SQLite has no index rename; index names embed the collection name, so recreate them
+	for _, column := range timestampColumns {
+		dropSQL := fmt.Sprintf("DROP INDEX IF EXISTS %s", QuoteIdentifier(timestampIndexName(name, column)))
+		if _, err := tx.Exec(dropSQL); err != nil {
+			return fmt.Errorf("failed to drop %s index: %w", column, err)
+		}
+	}
+	if err := ensureTimestampIndexes(tx, newName); err != nil {
+		return err
+	}
+	for _, field := range sortedFields(fields) {
+		if err := dropFieldIndex(tx, name, field); err != nil {
+			return err
+		}
+		if err := createFieldIndex(tx, newName, field); err != nil {
+			return err
+		}
+	}
+	for _, index := range indexes {
+		if err := dropUserIndex(tx, name, index.Name); err != nil {
+			return err
+		}
+		if err := createUserIndex(tx, newName, index.Name, indexKeys(index)); err != nil {
+			return err
+		}
+	}
+
+	// The full-text index exists only when FTS5 is available and the collection has string fields
+	var searchTable string
+	err := tx.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?`, searchTableName(name)).Scan(&searchTable)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to check search index: %w", err)
+	}
+	if err == nil {
+		renameSQL := fmt.Sprintf("ALTER TABLE %s RENAME TO %s",
+			QuoteIdentifier(searchTableName(name)), QuoteIdentifier(searchTableName(newName)))
+		if _, err := tx.Exec(renameSQL); err != nil {
+			return fmt.Errorf("failed to rename search index: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE _collections SET name = ? WHERE name = ?`, newName, name); err != nil {
+		return fmt.Errorf("failed to rename collection: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// MaxRetentionDays bounds a collection's retention period, about a century
+const MaxRetentionDays = 36500
+
+// RetentionSummary reports a retention run over every collection with a retention period
+type RetentionSummary struct {
+	Collections    int   // Collections with a retention period
+	Deleted        int   // Documents deleted for being older than their collection's period
+	Failed         int   // Collections that couldn't be cleaned up
+	ReclaimedBytes int64 // Stored document data removed, as charged to quotas
+}
+
+// SetRetention sets how many days a collection keeps documents; 0 keeps them forever
+func (c *CatalogDB) SetRetention(dbID string, name string, days int) (*models.Schema, error) {
+	if days < 0 || days > MaxRetentionDays {
+		return nil, fmt.Errorf("invalid retention_days: must be between 0 and %d", MaxRetentionDays)
+	}
+
+	query := `UPDATE schemas SET retention_days = ? WHERE database_id = ? AND name = ?`
+	result, err := c.db.Exec(query, days, dbID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update retention: %w", err)
+	}
+	c.schemas.invalidate(dbID, name)
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("schema not found")
+	}
+
+	return c.GetSchema(dbID, name)
+}
+
+// ApplyRetention deletes documents created before their collection's retention period,
+// batchSize at a time so writers aren't held up by a large backlog
+// Soft-deleted documents are removed too. Failures don't stop the run; they are counted
+// and returned together
+func (c *CatalogDB) ApplyRetention(now time.Time, batchSize int) (RetentionSummary, error) {
+	var summary RetentionSummary
+
+	schemas, err := c.querySchemas(`WHERE retention_days > 0`)
+	if err != nil {
+		return summary, err
+	}
+
+	var errs []error
+	for _, schema := range schemas {
+		summary.Collections++
+		cutoff := now.AddDate(0, 0, -schema.RetentionDays)
+		deleted, reclaimed, err := c.expireDocuments(schema.DatabaseID, schema.Name, cutoff, batchSize)
+		summary.Deleted += deleted
+		summary.ReclaimedBytes += reclaimed
+		if err != nil {
+			summary.Failed++
+			errs = append(errs, fmt.Errorf("%s/%s: %w", schema.DatabaseID, schema.Name, err))
+		}
+	}
+
+	return summary, errors.Join(errs...)
+}
+
+// expireDocuments deletes a collection's documents created before cutoff, in batches
+// Returns the documents deleted and their stored size
+func (c *CatalogDB) expireDocuments(dbID string, collection string, cutoff time.Time, batchSize int) (int, int64, error) {
+	var deleted int
+	var reclaimed int64
+	for {
+		batch, err := c.expireBatch(dbID, collection, cutoff, batchSize)
+		for _, doc := range batch {
+			deleted++
+			reclaimed += doc.size
+		}
+		if err != nil || len(batch) < batchSize {
+			return deleted, reclaimed, err
+		}
+	}
+}
+
+// expireBatch deletes up to batchSize documents created before cutoff
+// The database file is released between batches
+func (c *CatalogDB) expireBatch(dbID string, collection string, cutoff time.Time, batchSize int) ([]removedDocument, error) {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return c.removeDocuments(db, dbID, collection, "created_at < ?", []interface{}{cutoff.Unix()}, batchSize,
+		map[string]interface{}{"retention": true})
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// eventRecorder collects the events a catalog broadcasts
+type eventRecorder struct {
+	events []models.ChangeEvent
+}
+
+func (r *eventRecorder) Broadcast(dbID string, event models.ChangeEvent) {
+	r.events = append(r.events, event)
+}
+
+func TestApplyRetention(t *testing.T) {
+	recorder := &eventRecorder{}
+	c := newTestCatalogWith(t, Limits{}, recorder)
+
+	const dbID = "db_retention"
+	newTestDatabase(t, c, dbID)
+	fields := map[string]models.FieldType{"n": models.FieldTypeNumber}
+	for _, name := range []string{"logs", "keep"} {
+		if _, err := c.CreateSchema(dbID, name, fields, nil); err != nil {
+			t.Fatalf("CreateSchema(%s) error = %v", name, err)
+		}
+		for i := 0; i < 5; i++ {
+			if _, err := c.InsertDocument(dbID, name, map[string]interface{}{"n": i}, ""); err != nil {
+				t.Fatalf("InsertDocument() error = %v", err)
+			}
+		}
+	}
+	if _, err := c.SetRetention(dbID, "logs", 30); err != nil {
+		t.Fatalf("SetRetention() error = %v", err)
+	}
+	if _, err := c.SetRetention(dbID, "logs", -1); err == nil {
+		t.Error("SetRetention(-1) error = nil, want an error")
+	}
+
+	// Backdate three of the five documents past the retention period
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	old := time.Now().AddDate(0, 0, -31).Unix()
+	_, err = db.Exec(`UPDATE logs SET created_at = ? WHERE id IN (SELECT id FROM logs LIMIT 3)`, old)
+	release()
+	if err != nil {
+		t.Fatalf("backdate error = %v", err)
+	}
+	before, _ := c.GetDatabaseByID(dbID)
+	recorder.events = nil
+
+	summary, err := c.ApplyRetention(time.Now(), 2)
+	if err != nil {
+		t.Fatalf("ApplyRetention() error = %v", err)
+	}
+	if summary.Collections != 1 || summary.Deleted != 3 || summary.Failed != 0 || summary.ReclaimedBytes <= 0 {
+		t.Errorf("ApplyRetention() = %+v, want 3 documents deleted from 1 collection", summary)
+	}
+
+	if len(recorder.events) != 3 || recorder.events[0].EventType != "delete" || recorder.events[0].Data["retention"] != true {
+		t.Errorf("ApplyRetention() broadcast %+v, want 3 retention deletes", recorder.events)
+	}
+
+	after, _ := c.GetDatabaseByID(dbID)
+	if after.QuotaUsed != before.QuotaUsed-summary.ReclaimedBytes {
+		t.Errorf("quota used = %d, want %d released from %d", after.QuotaUsed, summary.ReclaimedBytes, before.QuotaUsed)
+	}
+	for name, want := range map[string]int{"logs": 2, "keep": 5} {
+		docs, err := c.QueryDocuments(dbID, name, 100, 0, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("QueryDocuments(%s) error = %v", name, err)
+		}
+		if len(docs) != want {
+			t.Errorf("%s has %d documents, want %d", name, len(docs), want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"sync"
+
+	"jsondrop/internal/models"
+)
+
+// schemaCache keeps schemas read from the catalog in memory, so document requests don't
+// query the catalog for them each time. Every change to a schema in the catalog drops its
+// entry. Callers get copies, which they may modify
+type schemaCache struct {
+	mu         sync.RWMutex
+	schemas    map[schemaKey]*models.Schema
+	generation uint64 // Incremented on every invalidation
+}
+
+// schemaKey identifies a collection's schema
+type schemaKey struct {
+	dbID string
+	name string
+}
+
+// newSchemaCache creates an empty cache
+func newSchemaCache() *schemaCache {
+	return &schemaCache{schemas: make(map[schemaKey]*models.Schema)}
+}
+
+// get returns a copy of a cached schema
+// On a miss it returns the generation to pass to put with the schema loaded from the catalog
+func (sc *schemaCache) get(dbID string, name string) (*models.Schema, uint64) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	if schema, exists := sc.schemas[schemaKey{dbID, name}]; exists {
+		return copySchema(schema), sc.generation
+	}
+	return nil, sc.generation
+}
+
+// put caches a schema loaded from the catalog
+// It is dropped if anything was invalidated since the generation was read, as the catalog
+// may have changed between loading the schema and caching it
+func (sc *schemaCache) put(schema *models.Schema, generation uint64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if generation != sc.generation {
+		return
+	}
+	sc.schemas[schemaKey{schema.DatabaseID, schema.Name}] = copySchema(schema)
+}
+
+// invalidate drops a schema after it changed in the catalog
+func (sc *schemaCache) invalidate(dbID string, name string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	delete(sc.schemas, schemaKey{dbID, name})
+	sc.generation++
+}
+
+// invalidateDatabase drops every schema of a database
+func (sc *schemaCache) invalidateDatabase(dbID string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	for key := range sc.schemas {
+		if key.dbID == dbID {
+			delete(sc.schemas, key)
+		}
+	}
+	sc.generation++
+}
+
+// copySchema returns a copy of a schema that shares nothing with it
+func copySchema(schema *models.Schema) *models.Schema {
+	clone := *schema
+	clone.Fields = make(map[string]models.FieldType, len(schema.Fields))
+	for name, fieldType := range schema.Fields {
+		clone.Fields[name] = fieldType
+	}
+	return &clone
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func testSchema(dbID string, name string) *models.Schema {
+	return &models.Schema{
+		DatabaseID: dbID,
+		Name:       name,
+		Fields:     map[string]models.FieldType{"title": models.FieldTypeString},
+	}
+}
+
+func TestSchemaCache_ReturnsCopies(t *testing.T) {
+	sc := newSchemaCache()
+	_, generation := sc.get("db_a", "posts")
+	sc.put(testSchema("db_a", "posts"), generation)
+
+	first, _ := sc.get("db_a", "posts")
+	if first == nil {
+		t.Fatal("get() after put() = nil, want schema")
+	}
+	first.Name = "renamed"
+	first.Fields["extra"] = models.FieldTypeNumber
+
+	second, _ := sc.get("db_a", "posts")
+	if second.Name != "posts" || len(second.Fields) != 1 {
+		t.Errorf("get() = %+v, want the cached schema unaffected by changes to an earlier copy", second)
+	}
+}
+
+func TestSchemaCache_Invalidate(t *testing.T) {
+	sc := newSchemaCache()
+	_, generation := sc.get("db_a", "posts")
+	sc.put(testSchema("db_a", "posts"), generation)
+	sc.put(testSchema("db_a", "tags"), generation)
+
+	sc.invalidate("db_a", "posts")
+	if schema, _ := sc.get("db_a", "posts"); schema != nil {
+		t.Error("get() after invalidate() returned a schema, want nil")
+	}
+	if schema, _ := sc.get("db_a", "tags"); schema == nil {
+		t.Error("invalidate() dropped another collection's schema")
+	}
+}
+
+func TestSchemaCache_InvalidateDatabase(t *testing.T) {
+	sc := newSchemaCache()
+	_, generation := sc.get("db_a", "posts")
+	sc.put(testSchema("db_a", "posts"), generation)
+	sc.put(testSchema("db_a", "tags"), generation)
+	sc.put(testSchema("db_b", "posts"), generation)
+
+	sc.invalidateDatabase("db_a")
+	if schema, _ := sc.get("db_a", "posts"); schema != nil {
+		t.Error("get() after invalidateDatabase() returned a schema, want nil")
+	}
+	if schema, _ := sc.get("db_a", "tags"); schema != nil {
+		t.Error("get() after invalidateDatabase() returned a schema, want nil")
+	}
+	if schema, _ := sc.get("db_b", "posts"); schema == nil {
+		t.Error("invalidateDatabase() dropped another database's schema")
+	}
+}
+
+func TestSchemaCache_StalePut(t *testing.T) {
+	sc := newSchemaCache()
+	_, generation := sc.get("db_a", "posts")
+
+	// The schema changes after it was loaded but before it is cached
+	sc.invalidate("db_a", "posts")
+	sc.put(testSchema("db_a", "posts"), generation)
+
+	if schema, _ := sc.get("db_a", "posts"); schema != nil {
+		t.Error("put() cached a schema loaded before an invalidation")
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"fmt"
+
+	"jsondrop/internal/models"
+)
+
+// ReadScope restricts which documents a read may return
+type ReadScope struct {
+	Visible []models.Visibility         // Readable visibility levels; nil means all
+	Allow   func(*models.Document) bool // Per-document read policy; nil means no policy
+	Deleted bool                        // Read soft-deleted documents instead of live ones
+	Masked  []string                    // Fields the caller can't read, which full-text search skips
+}
+
+// ReadsDeleted reports whether the scope selects soft-deleted documents instead of live ones
+func (s *ReadScope) ReadsDeleted() bool {
+	return s != nil && s.Deleted
+}
+
+// deletedFilter builds the SQL condition selecting live or soft-deleted documents
+// Reads see live documents unless the scope asks for deleted ones
+func (s *ReadScope) deletedFilter(prefix string) string {
+	if s.ReadsDeleted() {
+		return fmt.Sprintf(" AND %sdeleted_at IS NOT NULL", prefix)
+	}
+	return fmt.Sprintf(" AND %sdeleted_at IS NULL", prefix)
+}
+
+// VisibleLevels returns the readable visibility levels, or nil for no restriction
+func (s *ReadScope) VisibleLevels() []models.Visibility {
+	if s == nil {
+		return nil
+	}
+	return s.Visible
+}
+
+// MaskedFields returns the fields the caller can't read
+func (s *ReadScope) MaskedFields() []string {
+	if s == nil {
+		return nil
+	}
+	return s.Masked
+}
+
+// HasPolicy reports whether documents must pass a per-document check
+func (s *ReadScope) HasPolicy() bool {
+	return s != nil && s.Allow != nil
+}
+
+// Allows reports whether the scope's policy permits reading a document
+func (s *ReadScope) Allows(doc *models.Document) bool {
+	if !s.HasPolicy() {
+		return true
+	}
+	return s.Allow(doc)
+}
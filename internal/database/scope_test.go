@@ -0,0 +1,24 @@
+package database
+
+import "testing"
+
+func TestDeletedFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		scope  *ReadScope
+		prefix string
+		want   string
+	}{
+		{name: "nil scope reads live documents", scope: nil, want: " AND deleted_at IS NULL"},
+		{name: "live documents", scope: &ReadScope{}, prefix: "d.", want: " AND d.deleted_at IS NULL"},
+		{name: "deleted documents", scope: &ReadScope{Deleted: true}, want: " AND deleted_at IS NOT NULL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.deletedFilter(tt.prefix); got != tt.want {
+				t.Errorf("deletedFilter(%q) = %q, want %q", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
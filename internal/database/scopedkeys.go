@@ -0,0 +1,195 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// maxScopedKeys caps the scoped keys of a database
+const maxScopedKeys = 100
+
+// CreateScopedKey records a key restricted to some collections, assigning its ID and the
+// key itself, a write or read key as key.Access says
+// A key with ExpiresAt stops working then, and is removed by DeleteExpiredScopedKeys
+func (c *CatalogDB) CreateScopedKey(key *models.ScopedKey) error {
+	if len(key.Collections) == 0 {
+		return fmt.Errorf("invalid scoped key: at least one collection is required")
+	}
+	var expiresAt int64
+	if key.ExpiresAt != nil {
+		if !key.ExpiresAt.After(time.Now()) {
+			return fmt.Errorf("invalid expires_at: must be in the future")
+		}
+		expires := time.Unix(key.ExpiresAt.Unix(), 0)
+		key.ExpiresAt = &expires
+		expiresAt = expires.Unix()
+	}
+	collections := slices.Clone(key.Collections)
+	slices.Sort(collections)
+	collections = slices.Compact(collections)
+	for _, collection := range collections {
+		schema, err := c.GetSchema(key.DatabaseID, collection)
+		if err != nil {
+			return err
+		}
+		if schema == nil {
+			return fmt.Errorf("schema not found: %s", collection)
+		}
+	}
+
+	var count int
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM scoped_keys WHERE database_id = ?`, key.DatabaseID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count scoped keys: %w", err)
+	}
+	if count >= maxScopedKeys {
+		return fmt.Errorf("scoped key limit exceeded: databases can have at most %d scoped keys", maxScopedKeys)
+	}
+
+	var secret string
+	var err error
+	switch key.Access {
+	case models.KeyAccessWrite:
+		secret, _, err = RotatedKeys(c.keys, true, false)
+	case models.KeyAccessRead:
+		_, secret, err = RotatedKeys(c.keys, false, true)
+	default:
+		return fmt.Errorf("invalid access %q: use write or read", key.Access)
+	}
+	if err != nil {
+		return err
+	}
+	id, err := generateRandomString(16)
+	if err != nil {
+		return fmt.Errorf("failed to generate scoped key ID: %w", err)
+	}
+	key.ID = "key_" + id
+	key.Key = secret
+	key.Collections = collections
+	key.CreatedAt = time.Unix(time.Now().Unix(), 0)
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO scoped_keys (id, database_id, key, name, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := tx.Exec(query, key.ID, key.DatabaseID, key.Key, key.Name, key.CreatedAt.Unix(), expiresAt); err != nil {
+		return fmt.Errorf("failed to create scoped key entry: %w", err)
+	}
+	for _, collection := range collections {
+		query := `INSERT INTO scoped_key_collections (key_id, database_id, collection) VALUES (?, ?, ?)`
+		if _, err := tx.Exec(query, key.ID, key.DatabaseID, collection); err != nil {
+			return fmt.Errorf("failed to create scoped key entry: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create scoped key entry: %w", err)
+	}
+	return nil
+}
+
+// GetScopedKey returns the scoped key with the given key, or nil if there is none
+// Keys past their expiry are returned until they are removed; callers check Expired
+func (c *CatalogDB) GetScopedKey(secret string) (*models.ScopedKey, error) {
+	keys, err := c.queryScopedKeys(`WHERE k.key = ?`, secret)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return keys[0], nil
+}
+
+// ListScopedKeys returns a database's scoped keys with their keys, oldest first
+func (c *CatalogDB) ListScopedKeys(dbID string) ([]*models.ScopedKey, error) {
+	return c.queryScopedKeys(`WHERE k.database_id = ?`, dbID)
+}
+
+// DeleteScopedKey revokes a scoped key
+func (c *CatalogDB) DeleteScopedKey(dbID string, id string) error {
+	result, err := c.db.Exec(`DELETE FROM scoped_keys WHERE id = ? AND database_id = ?`, id, dbID)
+	if err != nil {
+		return fmt.Errorf("failed to delete scoped key entry: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("scoped key not found")
+	}
+	if _, err := c.db.Exec(`DELETE FROM scoped_key_collections WHERE key_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete scoped key entry: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredScopedKeys removes the scoped keys that expired at or before now, returning
+// how many were removed
+func (c *CatalogDB) DeleteExpiredScopedKeys(now time.Time) (int, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	expired := `SELECT id FROM scoped_keys WHERE expires_at > 0 AND expires_at <= ?`
+	if _, err := tx.Exec(`DELETE FROM scoped_key_collections WHERE key_id IN (`+expired+`)`, now.Unix()); err != nil {
+		return 0, fmt.Errorf("failed to delete expired scoped keys: %w", err)
+	}
+	result, err := tx.Exec(`DELETE FROM scoped_keys WHERE expires_at > 0 AND expires_at <= ?`, now.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired scoped keys: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to delete expired scoped keys: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// queryScopedKeys returns the scoped keys matching a WHERE clause on scoped_keys k, in
+// the order they were created, with their collections in order
+func (c *CatalogDB) queryScopedKeys(where string, args ...interface{}) ([]*models.ScopedKey, error) {
+	query := `
+		SELECT k.id, k.database_id, k.key, k.name, k.created_at, k.expires_at, s.collection
+		FROM scoped_keys k JOIN scoped_key_collections s ON s.key_id = k.id
+		` + where + `
+		ORDER BY k.rowid, s.collection
+	`
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scoped keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*models.ScopedKey{}
+	var key *models.ScopedKey
+	for rows.Next() {
+		var row models.ScopedKey
+		var collection string
+		var createdAt, expiresAt int64
+		if err := rows.Scan(&row.ID, &row.DatabaseID, &row.Key, &row.Name, &createdAt, &expiresAt, &collection); err != nil {
+			return nil, fmt.Errorf("failed to scan scoped key: %w", err)
+		}
+		if key == nil || key.ID != row.ID {
+			key = &row
+			key.Access = scopedKeyAccess(key.Key)
+			key.CreatedAt = time.Unix(createdAt, 0)
+			if expiresAt > 0 {
+				expires := time.Unix(expiresAt, 0)
+				key.ExpiresAt = &expires
+			}
+			keys = append(keys, key)
+		}
+		key.Collections = append(key.Collections, collection)
+	}
+	return keys, rows.Err()
+}
+
+// scopedKeyAccess returns the access level a key's prefix grants
+func scopedKeyAccess(secret string) string {
+	if strings.HasPrefix(secret, "wk_") {
+		return models.KeyAccessWrite
+	}
+	return models.KeyAccessRead
+}
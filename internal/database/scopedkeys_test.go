@@ -0,0 +1,147 @@
+package database
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestScopedKeys(t *testing.T) {
+	c := newTestCatalog(t)
+
+	created, err := c.CreateDatabase()
+	if err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	dbID := created.DatabaseID
+	for _, name := range []string{"telemetry", "notes"} {
+		if _, err := c.CreateSchema(dbID, name, map[string]models.FieldType{"t": models.FieldTypeString}, nil); err != nil {
+			t.Fatalf("CreateSchema(%s) error = %v", name, err)
+		}
+	}
+
+	// Keys need an access level and existing collections
+	for _, key := range []models.ScopedKey{
+		{DatabaseID: dbID, Access: models.KeyAccessWrite},
+		{DatabaseID: dbID, Access: models.KeyAccessWrite, Collections: []string{"missing"}},
+		{DatabaseID: dbID, Access: "admin", Collections: []string{"notes"}},
+	} {
+		if err := c.CreateScopedKey(&key); err == nil {
+			t.Errorf("CreateScopedKey(%+v) error = nil, want an error", key)
+		}
+	}
+
+	write := &models.ScopedKey{DatabaseID: dbID, Name: "device", Access: models.KeyAccessWrite, Collections: []string{"telemetry", "telemetry"}}
+	if err := c.CreateScopedKey(write); err != nil {
+		t.Fatalf("CreateScopedKey(write) error = %v", err)
+	}
+	if !strings.HasPrefix(write.ID, "key_") || !strings.HasPrefix(write.Key, "wk_") || !slices.Equal(write.Collections, []string{"telemetry"}) {
+		t.Fatalf("CreateScopedKey(write) = %+v, want a wk_ key for telemetry", write)
+	}
+	read := &models.ScopedKey{DatabaseID: dbID, Access: models.KeyAccessRead, Collections: []string{"notes", "telemetry"}}
+	if err := c.CreateScopedKey(read); err != nil {
+		t.Fatalf("CreateScopedKey(read) error = %v", err)
+	}
+
+	got, err := c.GetScopedKey(read.Key)
+	if err != nil {
+		t.Fatalf("GetScopedKey() error = %v", err)
+	}
+	if got == nil || got.ID != read.ID || got.Access != models.KeyAccessRead || !slices.Equal(got.Collections, []string{"notes", "telemetry"}) {
+		t.Errorf("GetScopedKey(read) = %+v, want %+v", got, read)
+	}
+	if got, err := c.GetScopedKey("rk_unknown"); err != nil || got != nil {
+		t.Errorf("GetScopedKey(unknown) = %+v, %v, want nil, nil", got, err)
+	}
+	// Scoped keys don't open the database itself
+	if db, err := c.GetDatabaseByWriteKey(write.Key); err != nil || db != nil {
+		t.Errorf("GetDatabaseByWriteKey(scoped key) = %+v, %v, want nil, nil", db, err)
+	}
+
+	// Renaming a collection carries its scopes along
+	if _, err := c.RenameSchema(dbID, "telemetry", "metrics"); err != nil {
+		t.Fatalf("RenameSchema() error = %v", err)
+	}
+	keys, err := c.ListScopedKeys(dbID)
+	if err != nil {
+		t.Fatalf("ListScopedKeys() error = %v", err)
+	}
+	if len(keys) != 2 || !slices.Equal(keys[0].Collections, []string{"metrics"}) || !slices.Equal(keys[1].Collections, []string{"metrics", "notes"}) {
+		t.Errorf("ListScopedKeys() after rename = %+v, want metrics in place of telemetry", keys)
+	}
+
+	if err := c.DeleteScopedKey(dbID, write.ID); err != nil {
+		t.Fatalf("DeleteScopedKey() error = %v", err)
+	}
+	if err := c.DeleteScopedKey(dbID, write.ID); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("DeleteScopedKey(deleted) error = %v, want not found", err)
+	}
+	if got, _ := c.GetScopedKey(write.Key); got != nil {
+		t.Errorf("GetScopedKey(deleted) = %+v, want nil", got)
+	}
+
+	// Deleting the database revokes the rest
+	if err := c.DeleteDatabase(dbID); err != nil {
+		t.Fatalf("DeleteDatabase() error = %v", err)
+	}
+	if got, _ := c.GetScopedKey(read.Key); got != nil {
+		t.Errorf("GetScopedKey() after DeleteDatabase = %+v, want nil", got)
+	}
+}
+
+func TestScopedKeys_Expiry(t *testing.T) {
+	c := newTestCatalog(t)
+
+	created, err := c.CreateDatabase()
+	if err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	dbID := created.DatabaseID
+	if _, err := c.CreateSchema(dbID, "notes", map[string]models.FieldType{"t": models.FieldTypeString}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	if err := c.CreateScopedKey(&models.ScopedKey{DatabaseID: dbID, Access: models.KeyAccessRead, Collections: []string{"notes"}, ExpiresAt: &past}); err == nil {
+		t.Error("CreateScopedKey(expired) error = nil, want an error")
+	}
+
+	expires := time.Now().Add(time.Hour)
+	temporary := &models.ScopedKey{DatabaseID: dbID, Access: models.KeyAccessRead, Collections: []string{"notes"}, ExpiresAt: &expires}
+	if err := c.CreateScopedKey(temporary); err != nil {
+		t.Fatalf("CreateScopedKey(temporary) error = %v", err)
+	}
+	permanent := &models.ScopedKey{DatabaseID: dbID, Access: models.KeyAccessRead, Collections: []string{"notes"}}
+	if err := c.CreateScopedKey(permanent); err != nil {
+		t.Fatalf("CreateScopedKey(permanent) error = %v", err)
+	}
+
+	got, err := c.GetScopedKey(temporary.Key)
+	if err != nil || got == nil {
+		t.Fatalf("GetScopedKey() = %+v, %v", got, err)
+	}
+	if got.ExpiresAt == nil || got.ExpiresAt.Unix() != expires.Unix() {
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, expires)
+	}
+	if got.Expired(time.Now()) || !got.Expired(expires) {
+		t.Errorf("Expired() is wrong around %v", expires)
+	}
+
+	// Cleanup only removes keys whose time has come
+	if removed, err := c.DeleteExpiredScopedKeys(time.Now()); err != nil || removed != 0 {
+		t.Errorf("DeleteExpiredScopedKeys(now) = %d, %v, want 0", removed, err)
+	}
+	if removed, err := c.DeleteExpiredScopedKeys(expires.Add(time.Second)); err != nil || removed != 1 {
+		t.Errorf("DeleteExpiredScopedKeys(after expiry) = %d, %v, want 1", removed, err)
+	}
+	keys, err := c.ListScopedKeys(dbID)
+	if err != nil {
+		t.Fatalf("ListScopedKeys() error = %v", err)
+	}
+	if len(keys) != 1 || keys[0].ID != permanent.ID || keys[0].ExpiresAt != nil {
+		t.Errorf("ListScopedKeys() after cleanup = %+v, want only the permanent key", keys)
+	}
+}
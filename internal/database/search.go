@@ -0,0 +1,312 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// searchTablePrefix names the FTS5 table that indexes a collection's string fields
+const searchTablePrefix = "_fts_"
+
+// searchTableName returns the name of the full-text index table for a collection
+func searchTableName(collection string) string {
+	return searchTablePrefix + collection
+}
+
+// stringFields returns the sorted names of a schema's string fields
+func stringFields(fields map[string]models.FieldType) []string {
+	var names []string
+	for name, fieldType := range fields {
+		if fieldType == models.FieldTypeString {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ensureSearchIndex creates and backfills the full-text index for a collection
+// Collections without string fields get no index
+func ensureSearchIndex(db *sql.DB, collection string, fields map[string]models.FieldType) error {
+	columns := stringFields(fields)
+	if len(columns) == 0 {
+		return nil
+	}
+
+	tableName := searchTableName(collection)
+
+	var existing string
+	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?`, tableName).Scan(&existing)
+	if err == nil {
+		return nil // Already indexed
+	}
+	if err != sql.ErrNoRows {
+		return fmt.Errorf("failed to check search index: %w", err)
+	}
+
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = QuoteIdentifier(column)
+	}
+
+	createSQL := fmt.Sprintf(
+		"CREATE VIRTUAL TABLE %s USING fts5(doc_id UNINDEXED, %s)",
+		QuoteIdentifier(tableName),
+		strings.Join(quotedColumns, ", "),
+	)
+	if _, err := db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	// Backfill from existing documents
+	rows, err := db.Query(fmt.Sprintf(`SELECT id, data FROM %s`, QuoteIdentifier(collection)))
+	if err != nil {
+		return fmt.Errorf("failed to read documents for search index: %w", err)
+	}
+	defer rows.Close()
+
+	type indexedDoc struct {
+		id   string
+		data map[string]interface{}
+	}
+	var docs []indexedDoc
+	for rows.Next() {
+		var doc indexedDoc
+		var stored []byte
+		if err := rows.Scan(&doc.id, &stored); err != nil {
+			return fmt.Errorf("failed to scan document for search index: %w", err)
+		}
+		if err := decodeData(stored, &doc.data); err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read documents for search index: %w", err)
+	}
+	rows.Close()
+
+	for _, doc := range docs {
+		if err := indexDocument(db, collection, columns, doc.id, doc.data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// searchColumns returns the indexed columns of a collection, or nil if it has no index
+func searchColumns(db queryer, collection string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", QuoteIdentifier(searchTableName(collection))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect search index: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("failed to inspect search index: %w", err)
+		}
+		if name != "doc_id" {
+			columns = append(columns, name)
+		}
+	}
+
+	return columns, rows.Err()
+}
+
+// indexDocument writes a document's string fields into the full-text index
+func indexDocument(db execer, collection string, columns []string, docID string, data map[string]interface{}) error {
+	if len(columns) == 0 {
+		return nil
+	}
+
+	quotedColumns := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	args := []interface{}{docID}
+	for i, column := range columns {
+		quotedColumns[i] = QuoteIdentifier(column)
+		placeholders[i] = "?"
+		value, _ := data[column].(string)
+		args = append(args, value)
+	}
+
+	insertSQL := fmt.Sprintf(
+		"INSERT INTO %s (doc_id, %s) VALUES (?, %s)",
+		QuoteIdentifier(searchTableName(collection)),
+		strings.Join(quotedColumns, ", "),
+		strings.Join(placeholders, ", "),
+	)
+	if _, err := db.Exec(insertSQL, args...); err != nil {
+		return fmt.Errorf("failed to index document: %w", err)
+	}
+
+	return nil
+}
+
+// unindexDocument removes a document from the full-text index
+func unindexDocument(db execer, collection string, docID string) error {
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE doc_id = ?", QuoteIdentifier(searchTableName(collection)))
+	if _, err := db.Exec(deleteSQL, docID); err != nil {
+		return fmt.Errorf("failed to remove document from search index: %w", err)
+	}
+	return nil
+}
+
+// syncSearchIndex replaces a document's entry in the full-text index
+// It is a no-op when FTS5 is unavailable or the collection has no index. Given the
+// transaction that writes the document, the entry commits along with it
+func (c *CatalogDB) syncSearchIndex(db queryer, collection string, docID string, data map[string]interface{}) error {
+	if !c.capabilities.FTS5 {
+		return nil
+	}
+
+	columns, err := searchColumns(db, collection)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	if err := unindexDocument(db, collection, docID); err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+	return indexDocument(db, collection, columns, docID, data)
+}
+
+// buildMatchQuery turns free text into an FTS5 query matching all terms
+// Each term is quoted so user input can't inject FTS5 query syntax
+func buildMatchQuery(search string) string {
+	terms := strings.Fields(search)
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// matchColumns limits an FTS5 query to some columns
+func matchColumns(columns []string, match string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = `"` + strings.ReplaceAll(column, `"`, `""`) + `"`
+	}
+	return "{" + strings.Join(quoted, " ") + "} : (" + match + ")"
+}
+
+// SearchDocuments runs a full-text search over a collection's string fields
+// Results are ordered by relevance unless sort keys are given; filters and scope apply as in QueryDocuments
+func (c *CatalogDB) SearchDocuments(dbID string, collection string, search string, limit int, offset int, filters []Filter, dates *TimeRange, order []SortKey, scope *ReadScope) ([]*models.Document, error) {
+	if !c.capabilities.FTS5 {
+		return nil, fmt.Errorf("full-text search is not available: server built without FTS5 support")
+	}
+
+	match := buildMatchQuery(search)
+	if match == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	columns, err := searchColumns(db, collection)
+	if err != nil {
+		return nil, err
+	}
+	// Searching a masked field would tell the caller what it holds
+	readable := slices.DeleteFunc(slices.Clone(columns), func(column string) bool {
+		return slices.Contains(scope.MaskedFields(), column)
+	})
+	if len(readable) == 0 {
+		return nil, fmt.Errorf("collection has no searchable string fields")
+	}
+	if len(readable) < len(columns) {
+		match = matchColumns(readable, match)
+	}
+
+	quotedCollection := QuoteIdentifier(collection)
+	quotedSearch := QuoteIdentifier(searchTableName(collection))
+	visibilityClause, visibilityArgs := visibilityFilter("d.visibility", scope.VisibleLevels())
+	dateClause, dateArgs := timeRangeFilter("d.", dates)
+	fieldClause, fieldArgs := filterClause("d.", filters)
+	orderBy := quotedSearch + ".rank"
+	if len(order) > 0 {
+		orderBy = orderByClause("d.", order)
+	}
+	query := fmt.Sprintf(`
+		SELECT d.id, d.created_at, d.updated_at, d.data, d.visibility, d.revision
+		FROM %s
+		JOIN %s AS d ON d.id = %s.doc_id
+		WHERE %s MATCH ?%s%s%s%s
+		ORDER BY %s
+	`, quotedSearch, quotedCollection, quotedSearch, quotedSearch,
+		visibilityClause, dateClause, fieldClause, scope.deletedFilter("d."), orderBy)
+
+	args := append([]interface{}{match}, visibilityArgs...)
+	args = append(args, dateArgs...)
+	args = append(args, fieldArgs...)
+	budget := c.limits.NewQueryBudget()
+	defer budget.Done()
+
+	rows, err := db.QueryContext(budget.Context(), query, args...)
+	if err != nil {
+		return nil, budget.Err(fmt.Errorf("failed to search documents: %w", err))
+	}
+	defer rows.Close()
+
+	// Read policies are applied in memory, so pagination happens after filtering
+	var documents []*models.Document
+	skipped := 0
+	for rows.Next() {
+		if err := budget.Scan(); err != nil {
+			return nil, err
+		}
+
+		var doc models.Document
+		var createdAt, updatedAt int64
+		var stored []byte
+
+		if err := rows.Scan(&doc.ID, &createdAt, &updatedAt, &stored, &doc.Visibility, &doc.Revision); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+
+		if err := decodeData(stored, &doc.Data); err != nil {
+			return nil, err
+		}
+
+		doc.Collection = collection
+		doc.CreatedAt = time.Unix(createdAt, 0)
+		doc.UpdatedAt = time.Unix(updatedAt, 0)
+
+		if !scope.Allows(&doc) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		documents = append(documents, &doc)
+		if limit > 0 && len(documents) >= limit {
+			break
+		}
+	}
+
+	return documents, budget.Err(rows.Err())
+}
@@ -0,0 +1,26 @@
+package database
+
+import "testing"
+
+func TestBuildMatchQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		search string
+		want   string
+	}{
+		{name: "single term", search: "sqlite", want: `"sqlite"`},
+		{name: "multiple terms", search: "go  sqlite\ttips", want: `"go" "sqlite" "tips"`},
+		{name: "operators are quoted", search: "a OR b NOT c", want: `"a" "OR" "b" "NOT" "c"`},
+		{name: "embedded quotes", search: `say "hi"`, want: `"say" """hi"""`},
+		{name: "column filter syntax", search: "title:secret", want: `"title:secret"`},
+		{name: "whitespace only", search: "   ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildMatchQuery(tt.search); got != tt.want {
+				t.Errorf("buildMatchQuery(%q) = %s, want %s", tt.search, got, tt.want)
+			}
+		})
+	}
+}
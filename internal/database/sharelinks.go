@@ -0,0 +1,154 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// Share links are kept in the catalog by a hash of their token, so the catalog alone
+// doesn't open them. Expired links are removed by DeleteExpiredShareLinks
+
+// maxShareLinks caps the unexpired share links of a database
+const maxShareLinks = 1000
+
+// CreateShareLink records a link to link.DocumentID, or to the query link.Query when it
+// is empty, assigning its ID and token
+func (c *CatalogDB) CreateShareLink(link *models.ShareLink) error {
+	now := time.Now()
+	if !link.ExpiresAt.After(now) {
+		return fmt.Errorf("invalid expiry: must be in the future")
+	}
+	schema, err := c.GetSchema(link.DatabaseID, link.Collection)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		return fmt.Errorf("schema not found: %s", link.Collection)
+	}
+
+	var count int
+	query := `SELECT COUNT(*) FROM share_links WHERE database_id = ? AND expires_at > ?`
+	if err := c.db.QueryRow(query, link.DatabaseID, now.Unix()).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count share links: %w", err)
+	}
+	if count >= maxShareLinks {
+		return fmt.Errorf("share link limit exceeded: databases can have at most %d share links", maxShareLinks)
+	}
+
+	id, err := generateRandomString(16)
+	if err != nil {
+		return fmt.Errorf("failed to generate share link ID: %w", err)
+	}
+	secret, err := generateRandomString(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate share link token: %w", err)
+	}
+	link.ID = "shr_" + id
+	link.Token = "sh_" + secret
+	link.CreatedAt = time.Unix(now.Unix(), 0)
+	link.ExpiresAt = time.Unix(link.ExpiresAt.Unix(), 0)
+	link.UsedAt = nil
+
+	query = `
+		INSERT INTO share_links (id, database_id, token_hash, collection, document_id, query, single_use, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	if _, err := c.db.Exec(query, link.ID, link.DatabaseID, shareTokenHash(link.Token), link.Collection, link.DocumentID,
+		link.Query, link.SingleUse, link.CreatedAt.Unix(), link.ExpiresAt.Unix()); err != nil {
+		return fmt.Errorf("failed to create share link: %w", err)
+	}
+	return nil
+}
+
+// GetShareLink returns the share link with the given token, or nil if there is none
+// Links past their expiry or already used are returned until they are removed
+func (c *CatalogDB) GetShareLink(token string) (*models.ShareLink, error) {
+	links, err := c.queryShareLinks(`WHERE token_hash = ?`, shareTokenHash(token))
+	if err != nil || len(links) == 0 {
+		return nil, err
+	}
+	return links[0], nil
+}
+
+// ListShareLinks returns a database's share links without their tokens, oldest first
+func (c *CatalogDB) ListShareLinks(dbID string) ([]*models.ShareLink, error) {
+	return c.queryShareLinks(`WHERE database_id = ?`, dbID)
+}
+
+// UseShareLink marks a single-use link as opened at now; only the first call succeeds
+func (c *CatalogDB) UseShareLink(id string, now time.Time) error {
+	result, err := c.db.Exec(`UPDATE share_links SET used_at = ? WHERE id = ? AND used_at = 0`, now.Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to use share link: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("share link already used")
+	}
+	return nil
+}
+
+// DeleteShareLink revokes a share link
+func (c *CatalogDB) DeleteShareLink(dbID string, id string) error {
+	result, err := c.db.Exec(`DELETE FROM share_links WHERE id = ? AND database_id = ?`, id, dbID)
+	if err != nil {
+		return fmt.Errorf("failed to delete share link: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("share link not found")
+	}
+	return nil
+}
+
+// DeleteExpiredShareLinks removes the share links that expired at or before now,
+// returning how many were removed
+func (c *CatalogDB) DeleteExpiredShareLinks(now time.Time) (int, error) {
+	result, err := c.db.Exec(`DELETE FROM share_links WHERE expires_at <= ?`, now.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired share links: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// queryShareLinks returns the share links matching a WHERE clause on share_links
+func (c *CatalogDB) queryShareLinks(where string, args ...interface{}) ([]*models.ShareLink, error) {
+	query := `
+		SELECT id, database_id, collection, document_id, query, single_use, created_at, expires_at, used_at
+		FROM share_links
+		` + where + `
+		ORDER BY created_at, rowid
+	`
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list share links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []*models.ShareLink{}
+	for rows.Next() {
+		var link models.ShareLink
+		var createdAt, expiresAt, usedAt int64
+		if err := rows.Scan(&link.ID, &link.DatabaseID, &link.Collection, &link.DocumentID, &link.Query,
+			&link.SingleUse, &createdAt, &expiresAt, &usedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan share link: %w", err)
+		}
+		link.CreatedAt = time.Unix(createdAt, 0)
+		link.ExpiresAt = time.Unix(expiresAt, 0)
+		if usedAt > 0 {
+			used := time.Unix(usedAt, 0)
+			link.UsedAt = &used
+		}
+		links = append(links, &link)
+	}
+	return links, rows.Err()
+}
+
+// shareTokenHash is the form a share link's token is stored in
+func shareTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
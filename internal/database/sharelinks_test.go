@@ -0,0 +1,96 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestShareLinks(t *testing.T) {
+	c := newTestCatalog(t)
+
+	created, err := c.CreateDatabase()
+	if err != nil {
+		t.Fatalf("CreateDatabase() error = %v", err)
+	}
+	dbID := created.DatabaseID
+	if _, err := c.CreateSchema(dbID, "notes", map[string]models.FieldType{"t": models.FieldTypeString}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	for _, link := range []models.ShareLink{
+		{DatabaseID: dbID, Collection: "missing", DocumentID: "doc_1", ExpiresAt: expires},
+		{DatabaseID: dbID, Collection: "notes", DocumentID: "doc_1", ExpiresAt: time.Now().Add(-time.Second)},
+	} {
+		if err := c.CreateShareLink(&link); err == nil {
+			t.Errorf("CreateShareLink(%+v) error = nil, want an error", link)
+		}
+	}
+
+	doc := &models.ShareLink{DatabaseID: dbID, Collection: "notes", DocumentID: "doc_1", SingleUse: true, ExpiresAt: expires}
+	if err := c.CreateShareLink(doc); err != nil {
+		t.Fatalf("CreateShareLink(document) error = %v", err)
+	}
+	if !strings.HasPrefix(doc.ID, "shr_") || !strings.HasPrefix(doc.Token, "sh_") {
+		t.Fatalf("CreateShareLink(document) = %+v, want an shr_ ID and an sh_ token", doc)
+	}
+	query := &models.ShareLink{DatabaseID: dbID, Collection: "notes", Query: "t=hello&limit=5", ExpiresAt: expires}
+	if err := c.CreateShareLink(query); err != nil {
+		t.Fatalf("CreateShareLink(query) error = %v", err)
+	}
+
+	got, err := c.GetShareLink(query.Token)
+	if err != nil {
+		t.Fatalf("GetShareLink() error = %v", err)
+	}
+	if got == nil || got.ID != query.ID || got.Query != query.Query || got.SingleUse || got.Token != "" || !got.ExpiresAt.Equal(query.ExpiresAt) {
+		t.Errorf("GetShareLink(query) = %+v, want %+v without its token", got, query)
+	}
+	if got, err := c.GetShareLink("sh_unknown"); err != nil || got != nil {
+		t.Errorf("GetShareLink(unknown) = %+v, %v; want nil, nil", got, err)
+	}
+
+	// Single-use links open once
+	now := time.Now()
+	if err := c.UseShareLink(doc.ID, now); err != nil {
+		t.Fatalf("UseShareLink() error = %v", err)
+	}
+	if err := c.UseShareLink(doc.ID, now); err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Errorf("UseShareLink() again error = %v, want already used", err)
+	}
+	if got, _ := c.GetShareLink(doc.Token); got == nil || got.UsedAt == nil || got.UsedAt.Unix() != now.Unix() {
+		t.Errorf("GetShareLink(used) = %+v, want used at %v", got, now)
+	}
+
+	links, err := c.ListShareLinks(dbID)
+	if err != nil {
+		t.Fatalf("ListShareLinks() error = %v", err)
+	}
+	if len(links) != 2 || links[0].ID != doc.ID || links[1].ID != query.ID {
+		t.Fatalf("ListShareLinks() = %+v, want the document then the query link", links)
+	}
+
+	if err := c.DeleteShareLink(dbID, query.ID); err != nil {
+		t.Fatalf("DeleteShareLink() error = %v", err)
+	}
+	if err := c.DeleteShareLink(dbID, query.ID); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("DeleteShareLink() again error = %v, want not found", err)
+	}
+	if got, _ := c.GetShareLink(query.Token); got != nil {
+		t.Errorf("GetShareLink(revoked) = %+v, want nil", got)
+	}
+
+	removed, err := c.DeleteExpiredShareLinks(expires)
+	if err != nil {
+		t.Fatalf("DeleteExpiredShareLinks() error = %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("DeleteExpiredShareLinks() = %d, want 1", removed)
+	}
+	if links, _ := c.ListShareLinks(dbID); len(links) != 0 {
+		t.Errorf("ListShareLinks() after expiry = %+v, want none", links)
+	}
+}
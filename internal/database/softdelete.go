@@ -0,0 +1,223 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// SoftDeleteDocument marks a document deleted, hiding it from reads until it is restored or purged
+// Soft-deleted documents keep counting towards the quota. A nonzero revision must match
+// the document's current revision
+func (c *CatalogDB) SoftDeleteDocument(dbID string, collection string, docID string, revision int64) error {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	now := time.Now().Unix()
+	var stored []byte
+	var visibility models.Visibility
+	query := fmt.Sprintf(`UPDATE %s SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR revision = ?) RETURNING data, visibility`, QuoteIdentifier(collection))
+	err = db.QueryRow(query, now, docID, revision, revision).Scan(&stored, &visibility)
+	if err == sql.ErrNoRows {
+		return revisionMismatchOrNotFound(db, collection, docID, " AND deleted_at IS NULL")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+
+	// Broadcast delete event; subscribers see the document disappear as for a hard delete
+	if c.broadcaster != nil {
+		event := models.ChangeEvent{
+			EventType:  "delete",
+			DatabaseID: dbID,
+			Collection: collection,
+			DocumentID: docID,
+			Data:       map[string]interface{}{"soft": true},
+			Visibility: visibility,
+			Timestamp:  time.Unix(now, 0),
+		}
+		if err := decodeData(stored, &event.OldData); err != nil {
+			// Still broadcast the delete, without the previous data
+		}
+		c.broadcaster.Broadcast(dbID, event)
+	}
+
+	return nil
+}
+
+// RestoreDocument brings back a soft-deleted document
+func (c *CatalogDB) RestoreDocument(dbID string, collection string, docID string) (*models.Document, error) {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	query := fmt.Sprintf(`UPDATE %s SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, QuoteIdentifier(collection))
+	result, err := db.Exec(query, docID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore document: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("deleted document not found")
+	}
+
+	doc, err := c.GetDocument(dbID, collection, docID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("deleted document not found")
+	}
+
+	// Broadcast restore event
+	if c.broadcaster != nil {
+		event := models.ChangeEvent{
+			EventType:  "restore",
+			DatabaseID: dbID,
+			Collection: collection,
+			DocumentID: docID,
+			Data:       doc.Data,
+			Visibility: doc.Visibility,
+			Timestamp:  time.Now(),
+		}
+		c.broadcaster.Broadcast(dbID, event)
+	}
+
+	return doc, nil
+}
+
+// PurgeDocuments permanently removes soft-deleted documents and releases their quota
+// A non-zero before only purges documents deleted before that time
+func (c *CatalogDB) PurgeDocuments(dbID string, collection string, before time.Time) (int, error) {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	quotedCollection := QuoteIdentifier(collection)
+	where := "deleted_at IS NOT NULL"
+	var args []interface{}
+	if !before.IsZero() {
+		where += " AND deleted_at < ?"
+		args = append(args, before.Unix())
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The IDs are needed to clean up the search index once the rows are gone
+	rows, err := tx.Query(fmt.Sprintf(`SELECT id, LENGTH(data) FROM %s WHERE %s`, quotedCollection, where), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find deleted documents: %w", err)
+	}
+	var ids []string
+	var purgedSize int64
+	for rows.Next() {
+		var id string
+		var size int64
+		if err := rows.Scan(&id, &size); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan deleted document: %w", err)
+		}
+		ids = append(ids, id)
+		purgedSize += size
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find deleted documents: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s`, quotedCollection, where), args...); err != nil {
+		return 0, fmt.Errorf("failed to purge documents: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to purge documents: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := c.syncSearchIndex(db, collection, id, nil); err != nil {
+			// Log but don't fail; search results are joined against live documents
+		}
+	}
+	c.releaseQuota(dbID, purgedSize)
+
+	return len(ids), nil
+}
+
+// removedDocument is a document permanently deleted by retention or erasure
+type removedDocument struct {
+	id          string
+	size        int64
+	softDeleted bool
+	visibility  models.Visibility
+}
+
+// removeDocuments permanently deletes the documents of a collection matching a SQL condition,
+// live or soft-deleted, up to limit of them unless limit is 0. Their quota is released and a
+// delete event with eventData is sent for each live one
+func (c *CatalogDB) removeDocuments(db *sql.DB, dbID string, collection string, condition string, args []interface{}, limit int, eventData map[string]interface{}) ([]removedDocument, error) {
+	quotedCollection := QuoteIdentifier(collection)
+	where := condition
+	if limit > 0 {
+		where = fmt.Sprintf("id IN (SELECT id FROM %s WHERE %s LIMIT %d)", quotedCollection, condition, limit)
+	}
+	query := fmt.Sprintf(`DELETE FROM %s WHERE %s RETURNING id, LENGTH(CAST(data AS BLOB)), deleted_at IS NOT NULL, visibility`,
+		quotedCollection, where)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete documents: %w", err)
+	}
+	var removed []removedDocument
+	var size int64
+	for rows.Next() {
+		var doc removedDocument
+		if err := rows.Scan(&doc.id, &doc.size, &doc.softDeleted, &doc.visibility); err != nil {
+			rows.Close()
+			return removed, fmt.Errorf("failed to scan deleted document: %w", err)
+		}
+		removed = append(removed, doc)
+		size += doc.size
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return removed, fmt.Errorf("failed to delete documents: %w", err)
+	}
+
+	c.releaseQuota(dbID, size)
+	for _, doc := range removed {
+		if err := c.syncSearchIndex(db, collection, doc.id, nil); err != nil {
+			// Log but don't fail; search results are joined against live documents
+		}
+
+		// Soft-deleted documents already disappeared from subscribers' view
+		if c.broadcaster != nil && !doc.softDeleted {
+			event := models.ChangeEvent{
+				EventType:  "delete",
+				DatabaseID: dbID,
+				Collection: collection,
+				DocumentID: doc.id,
+				Data:       eventData,
+				Visibility: doc.visibility,
+				Timestamp:  time.Now(),
+			}
+			c.broadcaster.Broadcast(dbID, event)
+		}
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"jsondrop/internal/models"
+)
+
+// metadataColumns are the built-in document columns that can be sorted and projected like data fields
+// They take precedence over schema fields with the same names
+var metadataColumns = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// SortKey orders query results by a metadata column or data field
+type SortKey struct {
+	Field      string
+	Descending bool
+	Collation  string // Collation of a string field; empty for SQLite's byte order
+}
+
+// CollationUnicode orders strings by the Unicode collation algorithm, so case and accents
+// sort as readers expect; it needs SQLite built with ICU
+const CollationUnicode = "unicode"
+
+// ParseSort parses a comma-separated sort parameter such as "-updated_at,name"
+// A leading '-' sorts that key in descending order
+func ParseSort(value string, fields map[string]models.FieldType) ([]SortKey, error) {
+	if value == "" {
+		return nil, nil
+	}
+	return parseKeys("sort", strings.Split(value, ","), fields)
+}
+
+// ApplyCollation sets the collation of the sort keys on string fields
+// "binary" or empty keeps SQLite's byte order
+func ApplyCollation(keys []SortKey, fields map[string]models.FieldType, collation string) ([]SortKey, error) {
+	switch collation {
+	case "", "binary":
+		return keys, nil
+	case CollationUnicode:
+	default:
+		return nil, fmt.Errorf("invalid collate: unknown collation %s; use binary or %s", collation, CollationUnicode)
+	}
+	for i, key := range keys {
+		if !metadataColumns[key.Field] && fields[key.Field] == models.FieldTypeString {
+			keys[i].Collation = collation
+		}
+	}
+	return keys, nil
+}
+
+// parseKeys parses field names with an optional leading '-' for descending order
+// kind names what is being parsed in error messages
+func parseKeys(kind string, names []string, fields map[string]models.FieldType) ([]SortKey, error) {
+	var keys []SortKey
+	seen := make(map[string]bool)
+	for _, part := range names {
+		part = strings.TrimSpace(part)
+		key := SortKey{Field: strings.TrimPrefix(part, "-")}
+		key.Descending = key.Field != part
+
+		if key.Field == "" {
+			return nil, fmt.Errorf("invalid %s: empty field name", kind)
+		}
+		if _, isField := fields[key.Field]; !isField && !metadataColumns[key.Field] {
+			return nil, fmt.Errorf("invalid %s: unknown field %s", kind, key.Field)
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("invalid %s: field %s listed more than once", kind, key.Field)
+		}
+		seen[key.Field] = true
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// fieldExpr returns the SQL column for a metadata column or data field
+// Data fields are read from their generated columns so the field indexes apply
+func fieldExpr(prefix string, field string) string {
+	if metadataColumns[field] {
+		return prefix + field
+	}
+	// Field names come from schemas, where they are validated identifiers
+	return prefix + fieldColumnName(field)
+}
+
+// orderByClause builds an ORDER BY expression list for sort keys
+func orderByClause(prefix string, keys []SortKey) string {
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		expr := fieldExpr(prefix, key.Field)
+		if key.Collation != "" {
+			// ApplyCollation only sets CollationUnicode, which connections load by that name
+			expr += " COLLATE " + key.Collation
+		}
+		if key.Descending {
+			expr += " DESC"
+		}
+		terms[i] = expr
+	}
+	return strings.Join(terms, ", ")
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+var sortTestFields = map[string]models.FieldType{
+	"name": models.FieldTypeString,
+	"age":  models.FieldTypeNumber,
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []SortKey
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: nil},
+		{
+			name:  "metadata and data fields",
+			value: "-updated_at, name,id",
+			want: []SortKey{
+				{Field: "updated_at", Descending: true},
+				{Field: "name"},
+				{Field: "id"},
+			},
+		},
+		{name: "unknown field", value: "email", wantErr: true},
+		{name: "bare dash", value: "-", wantErr: true},
+		{name: "repeated field", value: "age,-age", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSort(tt.value, sortTestFields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSort(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSort(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderByClause(t *testing.T) {
+	keys := []SortKey{{Field: "updated_at", Descending: true}, {Field: "name"}}
+	want := "d.updated_at DESC, d.field_name"
+	if got := orderByClause("d.", keys); got != want {
+		t.Errorf("orderByClause() = %q, want %q", got, want)
+	}
+}
+
+func TestApplyCollation(t *testing.T) {
+	keys := []SortKey{{Field: "name", Descending: true}, {Field: "age"}, {Field: "id"}}
+
+	got, err := ApplyCollation(keys, sortTestFields, CollationUnicode)
+	if err != nil {
+		t.Fatalf("ApplyCollation() error = %v", err)
+	}
+	want := "field_name COLLATE unicode DESC, field_age, id"
+	if clause := orderByClause("", got); clause != want {
+		t.Errorf("orderByClause() = %q, want %q", clause, want)
+	}
+
+	if _, err := ApplyCollation(keys, sortTestFields, "nocase"); err == nil {
+		t.Error("ApplyCollation(\"nocase\") error = nil, want an error")
+	}
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// Stats returns aggregate catalog statistics
+func (c *CatalogDB) Stats() (*models.CatalogStats, error) {
+	stats := &models.CatalogStats{SearchEnabled: c.capabilities.FTS5, OpenDatabases: c.pool.openCount()}
+
+	var oldestCreated, latestAccessed int64
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(quota_used), 0), COALESCE(SUM(quota_limit), 0),
+			COALESCE(MIN(created_at), 0), COALESCE(MAX(last_accessed), 0)
+		FROM databases
+	`
+	err := c.db.QueryRow(query).Scan(&stats.Databases, &stats.QuotaUsed, &stats.QuotaLimit, &oldestCreated, &latestAccessed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database stats: %w", err)
+	}
+	if oldestCreated > 0 {
+		stats.OldestCreated = time.Unix(oldestCreated, 0)
+	}
+	if latestAccessed > 0 {
+		stats.LatestAccessed = time.Unix(latestAccessed, 0)
+	}
+
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM schemas`).Scan(&stats.Schemas); err != nil {
+		return nil, fmt.Errorf("failed to get schema stats: %w", err)
+	}
+
+	if err := c.db.QueryRow(`SELECT sqlite_version()`).Scan(&stats.SQLiteVersion); err != nil {
+		return nil, fmt.Errorf("failed to get sqlite version: %w", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(c.dbBaseDir, "*.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list database files: %w", err)
+	}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			stats.DiskBytes += info.Size()
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// Store is a storage backend for databases, their schemas and documents, and quota
+// CatalogDB keeps each database in its own SQLite file and also provides search,
+// aggregation, indexes, schema changes, soft deletes, imports and archives, which
+// other backends don't
+type Store interface {
+	// Databases
+	CreateDatabase() (*models.CreateDatabaseResponse, error)
+	GetDatabaseByID(dbID string) (*models.Database, error)
+	GetDatabaseByWriteKey(writeKey string) (*models.Database, error)
+	GetDatabaseByReadKey(readKey string) (*models.Database, error)
+	// RotateKeys replaces the chosen keys; the old ones keep working for the grace period
+	RotateKeys(dbID string, write bool, read bool, grace time.Duration) (*models.RotateKeysResponse, error)
+	// SetAllowedNetworks replaces the CIDR ranges the database's keys work from
+	SetAllowedNetworks(dbID string, networks []string) error
+	// SetCORSOrigins replaces the browser origins allowed besides CORS_ORIGINS
+	SetCORSOrigins(dbID string, origins []string) error
+	// SetProtected sets whether deleting the database or its schemas needs confirmation
+	SetProtected(dbID string, protected bool) error
+	UpdateLastAccessed(dbID string) error
+	DeleteDatabase(dbID string) error
+	CountDatabases() (int, error)
+
+	// Schemas
+	CreateSchema(dbID string, name string, fields map[string]models.FieldType, masked []string) (*models.Schema, error) // Only the catalog masks fields
+	GetSchema(dbID string, name string) (*models.Schema, error)
+	SetReadFilter(dbID string, name string, expression string) (*models.Schema, error)
+	DeleteSchema(dbID string, name string) error
+	Limits() Limits
+
+	// Documents
+	InsertDocument(dbID string, collection string, data map[string]interface{}, visibility models.Visibility) (*models.Document, error)
+	GetDocument(dbID string, collection string, docID string, scope *ReadScope) (*models.Document, error)
+	QueryDocuments(dbID string, collection string, limit int, offset int, filters []Filter, dates *TimeRange, order []SortKey, scope *ReadScope) ([]*models.Document, error)
+	EachDocument(dbID string, collection string, scope *ReadScope, fn func(*models.Document) error) error
+	// A nonzero revision must match the document's current revision, or the write fails
+	// with a "revision mismatch" error
+	UpdateDocument(dbID string, collection string, docID string, data map[string]interface{}, visibility models.Visibility, revision int64) (*models.Document, error)
+	DeleteDocument(dbID string, collection string, docID string, revision int64) error
+
+	// Quota
+	RecalculateQuota(dbID string) (*models.QuotaRecalculationResponse, error)
+
+	Close() error
+}
+
+var _ Store = (*CatalogDB)(nil)
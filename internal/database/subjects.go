@@ -0,0 +1,182 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// A data subject is identified by a value of a field, such as user_id, that several
+// collections may share. Their documents are exported or erased across every collection
+// whose schema has the field.
+
+// SubjectCollections returns the schemas of a database that have a field, ordered by name
+func (c *CatalogDB) SubjectCollections(dbID string, field string) ([]*models.Schema, error) {
+	schemas, err := c.ListSchemas(dbID)
+	if err != nil {
+		return nil, err
+	}
+	var matching []*models.Schema
+	for _, schema := range schemas {
+		if _, ok := schema.Fields[field]; ok {
+			matching = append(matching, schema)
+		}
+	}
+	return matching, nil
+}
+
+// subjectCondition returns the SQL condition selecting a subject's documents in a collection
+func subjectCondition(schema *models.Schema, field string, value string) (string, []interface{}) {
+	filter := Filter{Field: field, Type: schema.Fields[field], Values: []string{value}}
+	clause, args := filterClause("", []Filter{filter})
+	// filterClause joins conditions with AND onto a preceding WHERE
+	return "1 = 1" + clause, args
+}
+
+// EachSubjectDocument streams a subject's documents in a collection to fn, oldest first
+// Soft-deleted documents are included, since the server still holds them
+func (c *CatalogDB) EachSubjectDocument(dbID string, schema *models.Schema, field string, value string, fn func(*models.Document) error) error {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	condition, args := subjectCondition(schema, field, value)
+	query := fmt.Sprintf(`
+		SELECT id, created_at, updated_at, data, visibility, revision
+		FROM %s
+		WHERE %s
+		ORDER BY created_at ASC, id ASC
+	`, QuoteIdentifier(schema.Name), condition)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var doc models.Document
+		var createdAt, updatedAt int64
+		var stored []byte
+		if err := rows.Scan(&doc.ID, &createdAt, &updatedAt, &stored, &doc.Visibility, &doc.Revision); err != nil {
+			return fmt.Errorf("failed to scan document: %w", err)
+		}
+		if err := decodeData(stored, &doc.Data); err != nil {
+			return err
+		}
+		doc.Collection = schema.Name
+		doc.CreatedAt = time.Unix(createdAt, 0)
+		doc.UpdatedAt = time.Unix(updatedAt, 0)
+		if err := fn(&doc); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// EraseSubject permanently deletes a subject's documents, live and soft-deleted, from every
+// collection with the field, and records a receipt listing what was erased
+// The receipt keeps a SHA-256 hash of the value rather than the value itself, and the
+// erased documents' data is removed from the event log
+func (c *CatalogDB) EraseSubject(dbID string, field string, value string) (*models.ErasureReceipt, error) {
+	schemas, err := c.SubjectCollections(dbID, field)
+	if err != nil {
+		return nil, err
+	}
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("invalid subject: no collection has field %s", field)
+	}
+
+	id, err := generateRandomString(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate receipt ID: %w", err)
+	}
+	hash := sha256.Sum256([]byte(value))
+	receipt := &models.ErasureReceipt{
+		ID:          "era_" + id,
+		DatabaseID:  dbID,
+		Field:       field,
+		ValueSHA256: hex.EncodeToString(hash[:]),
+		Collections: []models.ErasedCollection{},
+	}
+
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// A failure leaves earlier collections erased; the receipt is still recorded for them
+	var eraseErr error
+	for _, schema := range schemas {
+		condition, args := subjectCondition(schema, field, value)
+		removed, err := c.removeDocuments(db, dbID, schema.Name, condition, args, 0, map[string]interface{}{"erasure": true})
+		if len(removed) > 0 {
+			erased := models.ErasedCollection{Name: schema.Name, DocumentIDs: make([]string, len(removed))}
+			for i, doc := range removed {
+				erased.DocumentIDs[i] = doc.id
+				receipt.Bytes += doc.size
+			}
+			receipt.Collections = append(receipt.Collections, erased)
+			receipt.Documents += len(removed)
+			if err == nil {
+				err = redactEventLog(db, schema.Name, erased.DocumentIDs)
+			}
+		}
+		if err != nil {
+			eraseErr = fmt.Errorf("failed to erase from %s: %w", schema.Name, err)
+			break
+		}
+	}
+	receipt.ErasedAt = time.Unix(time.Now().Unix(), 0)
+
+	collectionsJSON, err := json.Marshal(receipt.Collections)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal receipt: %w", err)
+	}
+	_, err = c.db.Exec(`
+		INSERT INTO erasure_receipts (id, database_id, field, value_sha256, collections, documents, bytes, erased_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, receipt.ID, dbID, field, receipt.ValueSHA256, string(collectionsJSON), receipt.Documents, receipt.Bytes, receipt.ErasedAt.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to record erasure receipt: %w", err)
+	}
+
+	return receipt, eraseErr
+}
+
+// ListErasureReceipts returns a database's erasure receipts, oldest first
+func (c *CatalogDB) ListErasureReceipts(dbID string) ([]*models.ErasureReceipt, error) {
+	rows, err := c.db.Query(`
+		SELECT id, database_id, field, value_sha256, collections, documents, bytes, erased_at
+		FROM erasure_receipts
+		WHERE database_id = ?
+		ORDER BY erased_at, id
+	`, dbID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list erasure receipts: %w", err)
+	}
+	defer rows.Close()
+
+	receipts := []*models.ErasureReceipt{}
+	for rows.Next() {
+		var receipt models.ErasureReceipt
+		var collectionsJSON string
+		var erasedAt int64
+		if err := rows.Scan(&receipt.ID, &receipt.DatabaseID, &receipt.Field, &receipt.ValueSHA256,
+			&collectionsJSON, &receipt.Documents, &receipt.Bytes, &erasedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan erasure receipt: %w", err)
+		}
+		if err := json.Unmarshal([]byte(collectionsJSON), &receipt.Collections); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal erasure receipt: %w", err)
+		}
+		receipt.ErasedAt = time.Unix(erasedAt, 0)
+		receipts = append(receipts, &receipt)
+	}
+	return receipts, rows.Err()
+}
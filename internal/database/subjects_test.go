@@ -0,0 +1,100 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestEraseSubject(t *testing.T) {
+	recorder := &eventRecorder{}
+	c := newTestCatalogWith(t, Limits{}, recorder)
+
+	const dbID = "db_subjects"
+	newTestDatabase(t, c, dbID)
+	withUser := map[string]models.FieldType{"user_id": models.FieldTypeString, "n": models.FieldTypeNumber}
+	for _, name := range []string{"orders", "profiles"} {
+		if _, err := c.CreateSchema(dbID, name, withUser, nil); err != nil {
+			t.Fatalf("CreateSchema(%s) error = %v", name, err)
+		}
+	}
+	if _, err := c.CreateSchema(dbID, "notes", map[string]models.FieldType{"n": models.FieldTypeNumber}, nil); err != nil {
+		t.Fatalf("CreateSchema(notes) error = %v", err)
+	}
+
+	insert := func(collection string, data map[string]interface{}) *models.Document {
+		doc, err := c.InsertDocument(dbID, collection, data, "")
+		if err != nil {
+			t.Fatalf("InsertDocument(%s) error = %v", collection, err)
+		}
+		return doc
+	}
+	for i := 0; i < 3; i++ {
+		insert("orders", map[string]interface{}{"user_id": "u1", "n": i})
+		insert("orders", map[string]interface{}{"user_id": "u2", "n": i})
+	}
+	deleted := insert("profiles", map[string]interface{}{"user_id": "u1", "n": 0})
+	if err := c.SoftDeleteDocument(dbID, "profiles", deleted.ID, 0); err != nil {
+		t.Fatalf("SoftDeleteDocument() error = %v", err)
+	}
+	insert("notes", map[string]interface{}{"n": 1})
+
+	var exported int
+	schemas, err := c.SubjectCollections(dbID, "user_id")
+	if err != nil || len(schemas) != 2 {
+		t.Fatalf("SubjectCollections() = %d schemas, %v, want 2", len(schemas), err)
+	}
+	for _, schema := range schemas {
+		err := c.EachSubjectDocument(dbID, schema, "user_id", "u1", func(doc *models.Document) error {
+			exported++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("EachSubjectDocument(%s) error = %v", schema.Name, err)
+		}
+	}
+	if exported != 4 {
+		t.Errorf("exported %d documents, want 4 including the soft-deleted one", exported)
+	}
+
+	before, _ := c.GetDatabaseByID(dbID)
+	recorder.events = nil
+	receipt, err := c.EraseSubject(dbID, "user_id", "u1")
+	if err != nil {
+		t.Fatalf("EraseSubject() error = %v", err)
+	}
+	hash := sha256.Sum256([]byte("u1"))
+	if receipt.ValueSHA256 != hex.EncodeToString(hash[:]) {
+		t.Errorf("receipt hash = %s, want the SHA-256 of the value", receipt.ValueSHA256)
+	}
+	if receipt.Documents != 4 || len(receipt.Collections) != 2 || receipt.Bytes <= 0 {
+		t.Errorf("receipt = %d documents in %d collections, %d bytes, want 4 in 2", receipt.Documents, len(receipt.Collections), receipt.Bytes)
+	}
+	if len(recorder.events) != 3 {
+		t.Errorf("broadcast %d events, want 3 for the live documents", len(recorder.events))
+	}
+	after, _ := c.GetDatabaseByID(dbID)
+	if after.QuotaUsed != before.QuotaUsed-receipt.Bytes {
+		t.Errorf("quota_used = %d, want %d", after.QuotaUsed, before.QuotaUsed-receipt.Bytes)
+	}
+
+	// The other subject and collections without the field are untouched
+	if docs, err := c.QueryDocuments(dbID, "orders", 100, 0, nil, nil, nil, nil); err != nil || len(docs) != 3 {
+		t.Errorf("orders left = %d, %v, want 3", len(docs), err)
+	}
+	if docs, err := c.QueryDocuments(dbID, "notes", 100, 0, nil, nil, nil, nil); err != nil || len(docs) != 1 {
+		t.Errorf("notes left = %d, %v, want 1", len(docs), err)
+	}
+
+	if _, err := c.EraseSubject(dbID, "email", "a@b.c"); err == nil || !strings.HasPrefix(err.Error(), "invalid subject") {
+		t.Errorf("EraseSubject() on a missing field error = %v, want invalid subject", err)
+	}
+
+	receipts, err := c.ListErasureReceipts(dbID)
+	if err != nil || len(receipts) != 1 || receipts[0].ID != receipt.ID || receipts[0].Documents != 4 {
+		t.Errorf("ListErasureReceipts() = %v, %v, want the one receipt", receipts, err)
+	}
+}
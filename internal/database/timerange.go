@@ -0,0 +1,139 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Query parameters that filter documents by their built-in timestamps
+const (
+	CreatedAfterParam  = "created_after"
+	CreatedBeforeParam = "created_before"
+	UpdatedAfterParam  = "updated_after"
+	UpdatedBeforeParam = "updated_before"
+)
+
+// TimeRange restricts documents by their created_at and updated_at timestamps
+// Zero bounds are ignored; all bounds are exclusive
+type TimeRange struct {
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+}
+
+// IsTimeRangeParam reports whether a query parameter is a timestamp filter
+func IsTimeRangeParam(key string) bool {
+	switch key {
+	case CreatedAfterParam, CreatedBeforeParam, UpdatedAfterParam, UpdatedBeforeParam:
+		return true
+	}
+	return false
+}
+
+// ParseTimeRange reads timestamp filters from query parameters
+// Returns nil when no timestamp filter is present
+func ParseTimeRange(query map[string][]string) (*TimeRange, error) {
+	var tr TimeRange
+	found := false
+
+	bounds := []struct {
+		param  string
+		target *time.Time
+	}{
+		{CreatedAfterParam, &tr.CreatedAfter},
+		{CreatedBeforeParam, &tr.CreatedBefore},
+		{UpdatedAfterParam, &tr.UpdatedAfter},
+		{UpdatedBeforeParam, &tr.UpdatedBefore},
+	}
+	for _, bound := range bounds {
+		values := query[bound.param]
+		if len(values) == 0 {
+			continue
+		}
+		if len(values) > 1 {
+			return nil, fmt.Errorf("invalid %s: parameter may only be given once", bound.param)
+		}
+		t, err := ParseTimestamp(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", bound.param, err)
+		}
+		*bound.target = t
+		found = true
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return &tr, nil
+}
+
+// ParseTimestamp accepts RFC 3339 timestamps, YYYY-MM-DD dates (UTC midnight) and Unix seconds
+func ParseTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("expected an RFC 3339 timestamp, a YYYY-MM-DD date or Unix seconds, got %q", value)
+}
+
+// timeRangeFilter builds SQL conditions for a time range on the created_at and updated_at columns
+// Columns hold Unix seconds, so a bound inside a second is compared at second granularity
+func timeRangeFilter(prefix string, tr *TimeRange) (string, []interface{}) {
+	if tr == nil {
+		return "", nil
+	}
+
+	clause := ""
+	var args []interface{}
+	add := func(column string, op string, t time.Time) {
+		if t.IsZero() {
+			return
+		}
+		clause += fmt.Sprintf(" AND %s%s %s ?", prefix, column, op)
+		args = append(args, t.Unix())
+	}
+	add("created_at", ">", tr.CreatedAfter)
+	add("created_at", "<", tr.CreatedBefore)
+	add("updated_at", ">", tr.UpdatedAfter)
+	add("updated_at", "<", tr.UpdatedBefore)
+	return clause, args
+}
+
+// timestampColumns are the indexed document timestamp columns
+var timestampColumns = []string{"created_at", "updated_at"}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// queryer is an execer that can also query, such as *sql.DB or *sql.Tx
+type queryer interface {
+	execer
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// timestampIndexName returns the name of a collection's index on a timestamp column
+func timestampIndexName(collection string, column string) string {
+	return "idx_" + collection + "_" + column
+}
+
+// ensureTimestampIndexes indexes a collection's created_at and updated_at columns
+func ensureTimestampIndexes(db execer, collection string) error {
+	for _, column := range timestampColumns {
+		index := QuoteIdentifier(timestampIndexName(collection, column))
+		stmt := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", index, QuoteIdentifier(collection), column)
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create %s index: %w", column, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   map[string][]string
+		want    *TimeRange
+		wantErr bool
+	}{
+		{name: "no parameters", query: map[string][]string{"limit": {"10"}}, want: nil},
+		{
+			name:  "rfc3339",
+			query: map[string][]string{"created_after": {"2024-03-01T10:00:00Z"}},
+			want:  &TimeRange{CreatedAfter: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "date and unix seconds",
+			query: map[string][]string{"updated_before": {"2024-03-01"}, "created_before": {"1700000000"}},
+			want: &TimeRange{
+				CreatedBefore: time.Unix(1700000000, 0),
+				UpdatedBefore: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{name: "invalid value", query: map[string][]string{"updated_after": {"yesterday"}}, wantErr: true},
+		{name: "repeated parameter", query: map[string][]string{"created_after": {"1", "2"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimeRange(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTimeRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTimeRange() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeRangeFilter(t *testing.T) {
+	tr := &TimeRange{
+		CreatedAfter:  time.Unix(100, 0),
+		UpdatedBefore: time.Unix(200, 0),
+	}
+
+	clause, args := timeRangeFilter("d.", tr)
+	if want := " AND d.created_at > ? AND d.updated_at < ?"; clause != want {
+		t.Errorf("clause = %q, want %q", clause, want)
+	}
+	if want := []interface{}{int64(100), int64(200)}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+
+	if clause, args := timeRangeFilter("", nil); clause != "" || args != nil {
+		t.Errorf("nil range = %q, %v; want no filter", clause, args)
+	}
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SQLite keeps the pages freed by deleted documents in the file for reuse, so files never
+// shrink on their own. The first compaction of a file rebuilds it with VACUUM and switches
+// it to incremental auto-vacuum; later compactions only need to truncate its free pages.
+
+// auto_vacuum modes reported by PRAGMA auto_vacuum
+const autoVacuumIncremental = 2
+
+// VacuumSummary reports a compaction run over every database file
+type VacuumSummary struct {
+	Checked        int   // Files examined
+	Vacuumed       int   // Files compacted
+	Failed         int   // Files that couldn't be examined or compacted
+	ReclaimedBytes int64 // Disk space given back
+}
+
+// VacuumDatabases compacts database files where at least minFreePercent of the pages are free
+// Failures don't stop the run; they are counted and returned together
+// Writes to a file wait on, or fail with "database is locked" during, its compaction
+func (c *CatalogDB) VacuumDatabases(minFreePercent int) (VacuumSummary, error) {
+	var summary VacuumSummary
+
+	ids, err := c.listDatabaseIDs()
+	if err != nil {
+		return summary, err
+	}
+
+	var errs []error
+	for _, dbID := range ids {
+		summary.Checked++
+		reclaimed, vacuumed, err := c.vacuumDatabase(dbID, minFreePercent)
+		if err != nil {
+			summary.Failed++
+			errs = append(errs, fmt.Errorf("%s: %w", dbID, err))
+			continue
+		}
+		if vacuumed {
+			summary.Vacuumed++
+			summary.ReclaimedBytes += reclaimed
+		}
+	}
+
+	return summary, errors.Join(errs...)
+}
+
+// vacuumDatabase compacts a database file if enough of it is free
+func (c *CatalogDB) vacuumDatabase(dbID string, minFreePercent int) (int64, bool, error) {
+	db, release, err := c.openDatabase(dbID)
+	if err != nil {
+		return 0, false, err
+	}
+	defer release()
+
+	return vacuumFile(db, minFreePercent)
+}
+
+// vacuumFile compacts an open database file if at least minFreePercent of its pages are free
+// Returns the bytes reclaimed and whether the file was compacted
+func vacuumFile(db *sql.DB, minFreePercent int) (int64, bool, error) {
+	// The auto_vacuum change only takes effect on the connection that runs VACUUM
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to open connection: %w", err)
+	}
+	defer conn.Close()
+
+	var pageSize, pageCount, freePages, autoVacuum int64
+	pragmas := []struct {
+		name string
+		dest *int64
+	}{
+		{"page_size", &pageSize},
+		{"page_count", &pageCount},
+		{"freelist_count", &freePages},
+		{"auto_vacuum", &autoVacuum},
+	}
+	for _, pragma := range pragmas {
+		if err := conn.QueryRowContext(context.Background(), "PRAGMA "+pragma.name).Scan(pragma.dest); err != nil {
+			return 0, false, fmt.Errorf("failed to read %s: %w", pragma.name, err)
+		}
+	}
+
+	if !needsVacuum(pageCount, freePages, minFreePercent) {
+		return 0, false, nil
+	}
+
+	if autoVacuum == autoVacuumIncremental {
+		if err := incrementalVacuum(conn); err != nil {
+			return 0, false, fmt.Errorf("failed to vacuum: %w", err)
+		}
+	} else {
+		if _, err := conn.ExecContext(context.Background(), "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			return 0, false, fmt.Errorf("failed to enable incremental vacuum: %w", err)
+		}
+		if _, err := conn.ExecContext(context.Background(), "VACUUM"); err != nil {
+			return 0, false, fmt.Errorf("failed to vacuum: %w", err)
+		}
+	}
+
+	var remaining int64
+	if err := conn.QueryRowContext(context.Background(), "PRAGMA page_count").Scan(&remaining); err != nil {
+		return 0, true, fmt.Errorf("failed to read page_count: %w", err)
+	}
+	return (pageCount - remaining) * pageSize, true, nil
+}
+
+// incrementalVacuum truncates every free page of a file in incremental auto-vacuum mode
+// The pragma frees one page per step, so it is read to completion like a query
+func incrementalVacuum(conn *sql.Conn) error {
+	rows, err := conn.QueryContext(context.Background(), "PRAGMA incremental_vacuum")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// needsVacuum reports whether free pages make up at least minFreePercent of a file
+func needsVacuum(pageCount int64, freePages int64, minFreePercent int) bool {
+	return freePages > 0 && freePages*100 >= pageCount*int64(minFreePercent)
+}
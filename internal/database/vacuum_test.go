@@ -0,0 +1,78 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNeedsVacuum(t *testing.T) {
+	tests := []struct {
+		pageCount, freePages int64
+		minFreePercent       int
+		want                 bool
+	}{
+		{100, 0, 0, false},
+		{100, 19, 20, false},
+		{100, 20, 20, true},
+		{100, 1, 0, true},
+		{4, 4, 100, true},
+	}
+	for _, tt := range tests {
+		if got := needsVacuum(tt.pageCount, tt.freePages, tt.minFreePercent); got != tt.want {
+			t.Errorf("needsVacuum(%d, %d, %d) = %v, want %v", tt.pageCount, tt.freePages, tt.minFreePercent, got, tt.want)
+		}
+	}
+}
+
+// fillAndDelete inserts rows spanning many pages and deletes them, leaving the pages free
+func fillAndDelete(t *testing.T, db *sql.DB) {
+	t.Helper()
+	body := strings.Repeat("x", 2000)
+	for i := 0; i < 200; i++ {
+		if _, err := db.Exec(`INSERT INTO docs (body) VALUES (?)`, body); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+	if _, err := db.Exec(`DELETE FROM docs`); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+}
+
+func TestVacuumFile(t *testing.T) {
+	db, err := sql.Open(sqlDriverName(), withDriverParams(filepath.Join(t.TempDir(), "a.db")))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE docs (id INTEGER PRIMARY KEY, body TEXT)`); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	if _, vacuumed, err := vacuumFile(db, 20); err != nil || vacuumed {
+		t.Fatalf("vacuumFile() on a file without free pages = %v, %v, want no compaction", vacuumed, err)
+	}
+
+	// The first compaction rebuilds the file and switches it to incremental auto-vacuum
+	fillAndDelete(t, db)
+	reclaimed, vacuumed, err := vacuumFile(db, 20)
+	if err != nil || !vacuumed || reclaimed <= 0 {
+		t.Fatalf("vacuumFile() = %d, %v, %v, want bytes reclaimed", reclaimed, vacuumed, err)
+	}
+	var autoVacuum int
+	if err := db.QueryRow(`PRAGMA auto_vacuum`).Scan(&autoVacuum); err != nil || autoVacuum != autoVacuumIncremental {
+		t.Fatalf("auto_vacuum = %d, %v, want incremental", autoVacuum, err)
+	}
+
+	// Later compactions truncate the free pages incrementally
+	fillAndDelete(t, db)
+	reclaimed, vacuumed, err = vacuumFile(db, 20)
+	if err != nil || !vacuumed || reclaimed <= 0 {
+		t.Fatalf("vacuumFile() after switching to incremental = %d, %v, %v, want bytes reclaimed", reclaimed, vacuumed, err)
+	}
+	var freePages int
+	if err := db.QueryRow(`PRAGMA freelist_count`).Scan(&freePages); err != nil || freePages != 0 {
+		t.Errorf("freelist_count after vacuumFile() = %d, %v, want 0", freePages, err)
+	}
+}
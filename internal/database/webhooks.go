@@ -0,0 +1,234 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"jsondrop/internal/models"
+	"jsondrop/internal/webhook"
+)
+
+// maxWebhooks caps the webhooks of a database, and maxWebhookDeadLetters the dead letters
+// kept for each webhook, the oldest going first
+const (
+	maxWebhooks           = 10
+	maxWebhookDeadLetters = 1000
+)
+
+// ValidateWebhookURL checks that a webhook URL is an absolute http or https URL whose host
+// isn't a non-public address. Host names are checked again as deliveries connect, since
+// what they resolve to can change
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid webhook URL: scheme must be http or https")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid webhook URL: host is required")
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("invalid webhook URL: host must be public")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !webhook.Public(addr) {
+		return fmt.Errorf("invalid webhook URL: host must be public")
+	}
+	return nil
+}
+
+// CreateWebhook records a webhook, assigning its ID and signing secret
+func (c *CatalogDB) CreateWebhook(hook *models.Webhook) error {
+	if err := ValidateWebhookURL(hook.URL); err != nil {
+		return err
+	}
+	if hook.Collection != "" {
+		schema, err := c.GetSchema(hook.DatabaseID, hook.Collection)
+		if err != nil {
+			return err
+		}
+		if schema == nil {
+			return fmt.Errorf("schema not found")
+		}
+	}
+
+	existing, err := c.ListWebhooks(hook.DatabaseID)
+	if err != nil {
+		return err
+	}
+	if len(existing) >= maxWebhooks {
+		return fmt.Errorf("webhook limit exceeded: databases can have at most %d webhooks", maxWebhooks)
+	}
+
+	id, err := generateRandomString(16)
+	if err != nil {
+		return fmt.Errorf("failed to generate webhook ID: %w", err)
+	}
+	secret, err := generateRandomString(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	hook.ID = "wh_" + id
+	hook.Secret = "whsec_" + secret
+	hook.CreatedAt = time.Unix(time.Now().Unix(), 0)
+
+	eventsJSON, err := json.Marshal(hook.Events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook events: %w", err)
+	}
+
+	query := `
+		INSERT INTO webhooks (id, database_id, url, collection, events, secret, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	if _, err := c.db.Exec(query, hook.ID, hook.DatabaseID, hook.URL, hook.Collection, string(eventsJSON),
+		hook.Secret, hook.CreatedAt.Unix()); err != nil {
+		return fmt.Errorf("failed to create webhook entry: %w", err)
+	}
+	return nil
+}
+
+// ListWebhooks returns a database's webhooks with their secrets, oldest first
+func (c *CatalogDB) ListWebhooks(dbID string) ([]*models.Webhook, error) {
+	query := `
+		SELECT id, database_id, url, collection, events, secret, created_at
+		FROM webhooks WHERE database_id = ? ORDER BY created_at, id
+	`
+	rows, err := c.db.Query(query, dbID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	hooks := []*models.Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// DeleteWebhook removes a webhook and its dead letters; deliveries still being retried
+// are dropped
+func (c *CatalogDB) DeleteWebhook(dbID string, id string) error {
+	result, err := c.db.Exec(`DELETE FROM webhooks WHERE id = ? AND database_id = ?`, id, dbID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook entry: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+	if _, err := c.db.Exec(`DELETE FROM webhook_dead_letters WHERE webhook_id = ? AND database_id = ?`, id, dbID); err != nil {
+		return fmt.Errorf("failed to delete webhook dead letters: %w", err)
+	}
+	return nil
+}
+
+// AddWebhookDeadLetter keeps a delivery that failed every attempt, replacing an earlier
+// dead letter of the same delivery. Nothing is kept for webhooks deleted since
+func (c *CatalogDB) AddWebhookDeadLetter(dead *models.WebhookDeadLetter) error {
+	query := `
+		INSERT OR REPLACE INTO webhook_dead_letters
+			(id, database_id, webhook_id, event_type, event, attempts, last_error, failed_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE EXISTS (SELECT 1 FROM webhooks WHERE id = ? AND database_id = ?)
+	`
+	if _, err := c.db.Exec(query, dead.ID, dead.DatabaseID, dead.WebhookID, dead.EventType, string(dead.Event),
+		dead.Attempts, dead.LastError, dead.FailedAt.Unix(), dead.WebhookID, dead.DatabaseID); err != nil {
+		return fmt.Errorf("failed to add webhook dead letter: %w", err)
+	}
+
+	trim := `
+		DELETE FROM webhook_dead_letters WHERE webhook_id = ? AND rowid NOT IN (
+			SELECT rowid FROM webhook_dead_letters WHERE webhook_id = ? ORDER BY rowid DESC LIMIT ?
+		)
+	`
+	if _, err := c.db.Exec(trim, dead.WebhookID, dead.WebhookID, maxWebhookDeadLetters); err != nil {
+		return fmt.Errorf("failed to trim webhook dead letters: %w", err)
+	}
+	return nil
+}
+
+// ListWebhookDeadLetters returns the dead letters of a webhook, oldest first
+func (c *CatalogDB) ListWebhookDeadLetters(dbID string, webhookID string) ([]*models.WebhookDeadLetter, error) {
+	query := `
+		SELECT id, database_id, webhook_id, event_type, event, attempts, last_error, failed_at
+		FROM webhook_dead_letters WHERE database_id = ? AND webhook_id = ? ORDER BY rowid
+	`
+	rows, err := c.db.Query(query, dbID, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	deadLetters := []*models.WebhookDeadLetter{}
+	for rows.Next() {
+		dead, err := scanWebhookDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, dead)
+	}
+	return deadLetters, rows.Err()
+}
+
+// TakeWebhookDeadLetter removes a dead letter and returns it, to replay or discard
+func (c *CatalogDB) TakeWebhookDeadLetter(dbID string, webhookID string, id string) (*models.WebhookDeadLetter, error) {
+	query := `
+		DELETE FROM webhook_dead_letters WHERE database_id = ? AND webhook_id = ? AND id = ?
+		RETURNING id, database_id, webhook_id, event_type, event, attempts, last_error, failed_at
+	`
+	rows, err := c.db.Query(query, dbID, webhookID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take webhook dead letter: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to take webhook dead letter: %w", err)
+		}
+		return nil, fmt.Errorf("dead letter not found")
+	}
+	return scanWebhookDeadLetter(rows)
+}
+
+// scanWebhook scans a webhooks row
+func scanWebhook(rows *sql.Rows) (*models.Webhook, error) {
+	var hook models.Webhook
+	var eventsJSON string
+	var createdAt int64
+	if err := rows.Scan(&hook.ID, &hook.DatabaseID, &hook.URL, &hook.Collection, &eventsJSON,
+		&hook.Secret, &createdAt); err != nil {
+		return nil, fmt.Errorf("failed to scan webhook: %w", err)
+	}
+	if err := json.Unmarshal([]byte(eventsJSON), &hook.Events); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook events: %w", err)
+	}
+	hook.CreatedAt = time.Unix(createdAt, 0)
+	return &hook, nil
+}
+
+// scanWebhookDeadLetter scans a webhook_dead_letters row
+func scanWebhookDeadLetter(rows *sql.Rows) (*models.WebhookDeadLetter, error) {
+	var dead models.WebhookDeadLetter
+	var event string
+	var failedAt int64
+	if err := rows.Scan(&dead.ID, &dead.DatabaseID, &dead.WebhookID, &dead.EventType, &event,
+		&dead.Attempts, &dead.LastError, &failedAt); err != nil {
+		return nil, fmt.Errorf("failed to scan webhook dead letter: %w", err)
+	}
+	dead.Event = json.RawMessage(event)
+	dead.FailedAt = time.Unix(failedAt, 0)
+	return &dead, nil
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestValidateWebhookURL(t *testing.T) {
+	for _, raw := range []string{"https://example.com/hooks", "http://93.184.216.34:9000", "https://[2606:2800:220:1::1]/hooks"} {
+		if err := ValidateWebhookURL(raw); err != nil {
+			t.Errorf("ValidateWebhookURL(%q) error = %v, want nil", raw, err)
+		}
+	}
+	for _, raw := range []string{"", "example.com/hooks", "ftp://example.com", "https://", "://bad",
+		"http://localhost:9000", "http://api.localhost", "http://127.0.0.1", "http://[::1]:8080", "http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.5/hook", "http://192.168.1.10"} {
+		if err := ValidateWebhookURL(raw); err == nil {
+			t.Errorf("ValidateWebhookURL(%q) error = nil, want an error", raw)
+		}
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_webhooks"
+	newTestDatabase(t, c, dbID)
+	if _, err := c.CreateSchema(dbID, "posts", map[string]models.FieldType{"title": models.FieldTypeString}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+
+	if err := c.CreateWebhook(&models.Webhook{DatabaseID: dbID, URL: "https://example.com", Collection: "missing"}); err == nil || !strings.Contains(err.Error(), "schema not found") {
+		t.Errorf("CreateWebhook(missing collection) error = %v, want schema not found", err)
+	}
+
+	hook := &models.Webhook{DatabaseID: dbID, URL: "https://example.com/hook", Collection: "posts", Events: []string{"insert"}}
+	if err := c.CreateWebhook(hook); err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	if !strings.HasPrefix(hook.ID, "wh_") || !strings.HasPrefix(hook.Secret, "whsec_") {
+		t.Errorf("CreateWebhook() assigned ID %q and secret %q, want wh_ and whsec_ prefixes", hook.ID, hook.Secret)
+	}
+
+	// Renaming the collection keeps the webhook on it
+	if _, err := c.RenameSchema(dbID, "posts", "articles"); err != nil {
+		t.Fatalf("RenameSchema() error = %v", err)
+	}
+	hooks, err := c.ListWebhooks(dbID)
+	if err != nil || len(hooks) != 1 {
+		t.Fatalf("ListWebhooks() = %v, %v, want the webhook", hooks, err)
+	}
+	if got := hooks[0]; got.Collection != "articles" || got.Secret != hook.Secret || len(got.Events) != 1 {
+		t.Errorf("ListWebhooks() = %+v, want it on articles with its secret and events", got)
+	}
+
+	for i := 1; i < maxWebhooks; i++ {
+		if err := c.CreateWebhook(&models.Webhook{DatabaseID: dbID, URL: "https://example.com"}); err != nil {
+			t.Fatalf("CreateWebhook(%d) error = %v", i, err)
+		}
+	}
+	if err := c.CreateWebhook(&models.Webhook{DatabaseID: dbID, URL: "https://example.com"}); err == nil || !strings.Contains(err.Error(), "limit exceeded") {
+		t.Errorf("CreateWebhook() over the limit error = %v, want limit exceeded", err)
+	}
+
+	if err := c.DeleteWebhook(dbID, hook.ID); err != nil {
+		t.Errorf("DeleteWebhook() error = %v", err)
+	}
+	if err := c.DeleteWebhook(dbID, hook.ID); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("DeleteWebhook() again error = %v, want not found", err)
+	}
+
+	if err := c.DeleteDatabase(dbID); err != nil {
+		t.Fatalf("DeleteDatabase() error = %v", err)
+	}
+	if hooks, _ := c.ListWebhooks(dbID); len(hooks) != 0 {
+		t.Errorf("ListWebhooks() after DeleteDatabase = %d webhooks, want none", len(hooks))
+	}
+}
+
+func TestWebhookDeadLetters(t *testing.T) {
+	c := newTestCatalog(t)
+
+	const dbID = "db_deadletters"
+	newTestDatabase(t, c, dbID)
+	hook := &models.Webhook{DatabaseID: dbID, URL: "https://example.com/hook"}
+	if err := c.CreateWebhook(hook); err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+
+	deadLetter := func(id string) *models.WebhookDeadLetter {
+		return &models.WebhookDeadLetter{ID: id, DatabaseID: dbID, WebhookID: hook.ID, EventType: "insert",
+			Event: json.RawMessage(`{"type":"insert"}`), Attempts: 6, LastError: "receiver responded 503", FailedAt: time.Now()}
+	}
+	for _, id := range []string{"dlv_b", "dlv_a", "dlv_b"} {
+		if err := c.AddWebhookDeadLetter(deadLetter(id)); err != nil {
+			t.Fatalf("AddWebhookDeadLetter(%s) error = %v", id, err)
+		}
+	}
+	// Nothing is kept for deleted webhooks
+	orphan := deadLetter("dlv_orphan")
+	orphan.WebhookID = "wh_deleted"
+	if err := c.AddWebhookDeadLetter(orphan); err != nil {
+		t.Fatalf("AddWebhookDeadLetter(deleted webhook) error = %v", err)
+	}
+
+	// A delivery failing again replaces its dead letter and moves it last
+	deadLetters, err := c.ListWebhookDeadLetters(dbID, hook.ID)
+	if err != nil || len(deadLetters) != 2 || deadLetters[0].ID != "dlv_a" || deadLetters[1].ID != "dlv_b" {
+		t.Fatalf("ListWebhookDeadLetters() = %v, %v, want dlv_a then dlv_b", deadLetters, err)
+	}
+	if got := deadLetters[0]; string(got.Event) != `{"type":"insert"}` || got.Attempts != 6 || got.LastError == "" {
+		t.Errorf("ListWebhookDeadLetters()[0] = %+v, want its event, attempts and error", got)
+	}
+	if deadLetters, _ := c.ListWebhookDeadLetters(dbID, "wh_deleted"); len(deadLetters) != 0 {
+		t.Errorf("ListWebhookDeadLetters(deleted webhook) = %d dead letters, want none", len(deadLetters))
+	}
+
+	taken, err := c.TakeWebhookDeadLetter(dbID, hook.ID, "dlv_a")
+	if err != nil || taken.ID != "dlv_a" || taken.EventType != "insert" {
+		t.Fatalf("TakeWebhookDeadLetter() = %+v, %v, want dlv_a", taken, err)
+	}
+	if _, err := c.TakeWebhookDeadLetter(dbID, hook.ID, "dlv_a"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("TakeWebhookDeadLetter() again error = %v, want not found", err)
+	}
+
+	if err := c.DeleteWebhook(dbID, hook.ID); err != nil {
+		t.Fatalf("DeleteWebhook() error = %v", err)
+	}
+	if deadLetters, _ := c.ListWebhookDeadLetters(dbID, hook.ID); len(deadLetters) != 0 {
+		t.Errorf("ListWebhookDeadLetters() after DeleteWebhook = %d dead letters, want none", len(deadLetters))
+	}
+}
@@ -0,0 +1,191 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+// versionInfo describes the running build
+type versionInfo struct {
+	GeneratedAt  time.Time         `json:"generated_at"`
+	GoVersion    string            `json:"go_version"`
+	Platform     string            `json:"platform"`
+	Module       string            `json:"module"`
+	Version      string            `json:"version"`
+	Build        map[string]string `json:"build,omitempty"` // VCS revision, build tags, etc.
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+}
+
+// configSnapshot is the server configuration with secrets removed
+type configSnapshot struct {
+	Port                string   `json:"port"`
+	DBBaseDir           string   `json:"db_base_dir"`
+	CatalogDBPath       string   `json:"catalog_db_path"`
+	CORSOrigins         []string `json:"cors_origins"`
+	DefaultQuotaMB      int64    `json:"default_quota_mb"`
+	ExpiryDays          int      `json:"expiry_days"`
+	ExpiryCheckInterval string   `json:"expiry_check_interval"`
+	FaultInjection      bool     `json:"fault_injection"`
+	FaultRoutes         int      `json:"fault_routes"`
+	FixturesDir         string   `json:"fixtures_dir,omitempty"`
+	AdminKeySet         bool     `json:"admin_key_set"`
+	DevMode             bool     `json:"dev_mode"`
+	EventSink           string   `json:"event_sink,omitempty"` // Kind only; server URLs may hold credentials
+}
+
+// schemaSnapshot is a schema definition with its database ID anonymized
+type schemaSnapshot struct {
+	Database      string                      `json:"database"`
+	Name          string                      `json:"name"`
+	Fields        map[string]models.FieldType `json:"fields"`
+	ReadFilter    string                      `json:"read_filter,omitempty"` // String literals redacted
+	RetentionDays int                         `json:"retention_days,omitempty"`
+	CreatedAt     time.Time                   `json:"created_at"`
+}
+
+// WriteBundle writes a gzipped tarball of sanitized diagnostics to w
+// The bundle holds build versions, configuration without secrets, catalog
+// statistics, schema definitions and recent error log lines, but no document data
+func WriteBundle(w io.Writer, catalog *database.CatalogDB, cfg *config.Config, errorLog *ErrorLog) error {
+	now := time.Now().UTC()
+
+	stats, err := catalog.Stats()
+	if err != nil {
+		return err
+	}
+
+	schemas, err := catalog.ListAllSchemas()
+	if err != nil {
+		return err
+	}
+	schemaSnapshots := make([]schemaSnapshot, 0, len(schemas))
+	for _, schema := range schemas {
+		schemaSnapshots = append(schemaSnapshots, schemaSnapshot{
+			Database:      AnonymizeID(schema.DatabaseID),
+			Name:          schema.Name,
+			Fields:        schema.Fields,
+			ReadFilter:    RedactLiterals(schema.ReadFilter),
+			RetentionDays: schema.RetentionDays,
+			CreatedAt:     schema.CreatedAt,
+		})
+	}
+
+	var logLines []string
+	if errorLog != nil {
+		logLines = errorLog.Lines()
+	}
+	logText := strings.Join(logLines, "\n")
+	if logText != "" {
+		logText += "\n"
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	dir := "jsondrop-diagnostics-" + now.Format("20060102T150405Z") + "/"
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"version.json", buildVersionInfo(now)},
+		{"config.json", snapshotConfig(cfg)},
+		{"catalog.json", stats},
+		{"schemas.json", schemaSnapshots},
+	}
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.content, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", file.name, err)
+		}
+		if err := writeTarFile(tw, dir+file.name, append(data, '\n'), now); err != nil {
+			return err
+		}
+	}
+	if err := writeTarFile(tw, dir+"errors.log", []byte(logText), now); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return nil
+}
+
+// writeTarFile adds a regular file to the archive
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// buildVersionInfo collects version information from the binary
+func buildVersionInfo(now time.Time) versionInfo {
+	info := versionInfo{
+		GeneratedAt: now,
+		GoVersion:   runtime.Version(),
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.Module = build.Main.Path
+	info.Version = build.Main.Version
+	info.Build = make(map[string]string)
+	for _, setting := range build.Settings {
+		if setting.Key == "-tags" || strings.HasPrefix(setting.Key, "vcs.") || setting.Key == "CGO_ENABLED" {
+			info.Build[setting.Key] = setting.Value
+		}
+	}
+	info.Dependencies = make(map[string]string)
+	for _, dep := range build.Deps {
+		info.Dependencies[dep.Path] = dep.Version
+	}
+
+	return info
+}
+
+// snapshotConfig copies the configuration without secrets
+func snapshotConfig(cfg *config.Config) configSnapshot {
+	return configSnapshot{
+		Port:                cfg.Port,
+		DBBaseDir:           cfg.DBBaseDir,
+		CatalogDBPath:       cfg.CatalogDBPath,
+		CORSOrigins:         cfg.CORSOrigins,
+		DefaultQuotaMB:      cfg.DefaultQuotaMB,
+		ExpiryDays:          cfg.ExpiryDays,
+		ExpiryCheckInterval: cfg.ExpiryCheckInterval.String(),
+		FaultInjection:      cfg.Faults.Enabled,
+		FaultRoutes:         len(cfg.Faults.Routes),
+		FixturesDir:         cfg.FixturesDir,
+		AdminKeySet:         cfg.AdminKey != "",
+		DevMode:             cfg.DevMode,
+		EventSink:           cfg.EventSink.Kind,
+	}
+}
@@ -0,0 +1,88 @@
+package diagnostics
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// DefaultErrorLogLines is how many error lines an ErrorLog keeps by default
+const DefaultErrorLogLines = 500
+
+// ErrorLog is an io.Writer that keeps the most recent error lines written to it
+// Lines are sanitized as they are captured, so secrets never reach the buffer
+type ErrorLog struct {
+	mu      sync.Mutex
+	lines   []string
+	next    int  // Index of the slot to overwrite once full
+	full    bool // All slots have been written at least once
+	partial string
+}
+
+// NewErrorLog creates an ErrorLog holding up to size lines
+func NewErrorLog(size int) *ErrorLog {
+	if size <= 0 {
+		size = DefaultErrorLogLines
+	}
+	return &ErrorLog{lines: make([]string, size)}
+}
+
+// Write implements io.Writer, buffering incomplete lines until their newline arrives
+func (l *ErrorLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	text := l.partial + string(p)
+	parts := strings.Split(text, "\n")
+	l.partial = parts[len(parts)-1]
+
+	for _, line := range parts[:len(parts)-1] {
+		if IsErrorLine(line) {
+			l.add(SanitizeLogLine(line))
+		}
+	}
+
+	return len(p), nil
+}
+
+// add appends a line, overwriting the oldest when full
+func (l *ErrorLog) add(line string) {
+	l.lines[l.next] = line
+	l.next = (l.next + 1) % len(l.lines)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Lines returns the buffered lines, oldest first
+func (l *ErrorLog) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.full {
+		return append([]string(nil), l.lines[:l.next]...)
+	}
+	return append(append([]string(nil), l.lines[l.next:]...), l.lines[:l.next]...)
+}
+
+var (
+	ansiPattern        = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	serverErrorPattern = regexp.MustCompile(` - 5\d\d `) // chi request log status
+	errorWords         = []string{"error", "fail", "panic", "fatal", "warning"}
+)
+
+// IsErrorLine reports whether a log line records an error or a 5xx response
+func IsErrorLine(line string) bool {
+	line = ansiPattern.ReplaceAllString(line, "")
+	if serverErrorPattern.MatchString(line) {
+		return true
+	}
+
+	lower := strings.ToLower(line)
+	for _, word := range errorWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
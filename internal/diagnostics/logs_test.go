@@ -0,0 +1,46 @@
+package diagnostics
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestErrorLog_KeepsOnlyErrors(t *testing.T) {
+	l := NewErrorLog(10)
+	fmt.Fprintln(l, `"GET http://localhost/api/databases HTTP/1.1" from 127.0.0.1:1 - 200 10B in 1ms`)
+	fmt.Fprintln(l, `"GET http://localhost/api/databases HTTP/1.1" from 127.0.0.1:1 - 503 10B in 1ms`)
+	fmt.Fprintln(l, "Server listening on :8080")
+	fmt.Fprintln(l, "Failed to delete expired database: disk I/O error")
+
+	got := l.Lines()
+	if len(got) != 2 {
+		t.Fatalf("Lines() = %q, want 2 error lines", got)
+	}
+}
+
+func TestErrorLog_PartialWrites(t *testing.T) {
+	l := NewErrorLog(10)
+	l.Write([]byte("first err"))
+	if len(l.Lines()) != 0 {
+		t.Fatal("Lines() returned an incomplete line")
+	}
+	l.Write([]byte("or\nsecond error\n"))
+
+	want := []string{"first error", "second error"}
+	if got := l.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+}
+
+func TestErrorLog_Wraps(t *testing.T) {
+	l := NewErrorLog(3)
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(l, "error %d\n", i)
+	}
+
+	want := []string{"error 3", "error 4", "error 5"}
+	if got := l.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+}
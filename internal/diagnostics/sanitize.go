@@ -0,0 +1,45 @@
+package diagnostics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+)
+
+var (
+	keyPattern         = regexp.MustCompile(`\b(wk|rk)_[A-Za-z0-9]+`)
+	idPattern          = regexp.MustCompile(`\b(db|doc)_[A-Za-z0-9]+`)
+	bearerPattern      = regexp.MustCompile(`(?i)bearer\s+\S+`)
+	queryStringPattern = regexp.MustCompile(`\?[^\s"]*`)
+	stringLiteral      = regexp.MustCompile(`'(?:\\.|[^'\\])*'|"(?:\\.|[^"\\])*"`)
+)
+
+// SanitizeLogLine removes secrets and user data from a log line
+// Keys and bearer tokens are redacted, database and document IDs are replaced by
+// a stable hash so lines can still be correlated, and query strings (which carry
+// filter values) are dropped
+func SanitizeLogLine(line string) string {
+	line = ansiPattern.ReplaceAllString(line, "")
+	line = bearerPattern.ReplaceAllString(line, "Bearer [redacted]")
+	line = keyPattern.ReplaceAllString(line, "${1}_[redacted]")
+	line = idPattern.ReplaceAllStringFunc(line, AnonymizeID)
+	line = queryStringPattern.ReplaceAllString(line, "?[redacted]")
+	return line
+}
+
+// AnonymizeID replaces an ID with a short stable hash, keeping its prefix
+// e.g. db_abc123 becomes db:1a2b3c4d5e6f
+func AnonymizeID(id string) string {
+	prefix := ""
+	if match := idPattern.FindStringSubmatch(id); match != nil && match[0] == id {
+		prefix = match[1]
+	}
+	sum := sha256.Sum256([]byte(id))
+	return prefix + ":" + hex.EncodeToString(sum[:6])
+}
+
+// RedactLiterals replaces string literals in a read filter expression,
+// which may name users or other values taken from document data
+func RedactLiterals(expression string) string {
+	return stringLiteral.ReplaceAllString(expression, `"[redacted]"`)
+}
@@ -0,0 +1,43 @@
+package diagnostics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeLogLine(t *testing.T) {
+	line := "\x1b[32m\"GET http://localhost/api/databases/db_abc123/users/doc_xyz789?owner=alice HTTP/1.1\" Authorization: Bearer wk_secret1 rk_secret2\x1b[0m"
+	got := SanitizeLogLine(line)
+
+	for _, leaked := range []string{"db_abc123", "doc_xyz789", "alice", "wk_secret1", "rk_secret2", "\x1b["} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("SanitizeLogLine() = %q, still contains %q", got, leaked)
+		}
+	}
+	for _, kept := range []string{AnonymizeID("db_abc123"), AnonymizeID("doc_xyz789"), "/users/", "?[redacted]"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("SanitizeLogLine() = %q, want it to contain %q", got, kept)
+		}
+	}
+}
+
+func TestAnonymizeID(t *testing.T) {
+	a := AnonymizeID("db_abc123")
+	if a != AnonymizeID("db_abc123") {
+		t.Error("AnonymizeID() is not stable")
+	}
+	if a == AnonymizeID("db_abc124") {
+		t.Error("AnonymizeID() returned the same hash for different IDs")
+	}
+	if !strings.HasPrefix(a, "db:") {
+		t.Errorf("AnonymizeID() = %q, want db: prefix", a)
+	}
+}
+
+func TestRedactLiterals(t *testing.T) {
+	got := RedactLiterals(`data.owner == 'alice' || data.team == "red \"x\"" || data.n > 3`)
+	want := `data.owner == "[redacted]" || data.team == "[redacted]" || data.n > 3`
+	if got != want {
+		t.Errorf("RedactLiterals() = %q, want %q", got, want)
+	}
+}
@@ -1,9 +1,16 @@
 package events
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"jsondrop/internal/models"
@@ -14,22 +21,240 @@ type Broadcaster struct {
 	mu                  sync.RWMutex
 	databaseListeners   map[string]map[*Listener]bool            // dbID -> listeners
 	collectionListeners map[string]map[string]map[*Listener]bool // dbID -> collection -> listeners
+	firehose            map[*Listener]bool                       // Listeners to every database's events
+	limiter             *rateLimiter
+	observers           []func(models.ChangeEvent) // See every event, ahead of rate limiting
+	replays             map[string]*replayLog      // dbID -> recent events
+	firstID             uint64                     // Event IDs start after this, which grows with start time
+	lastID              uint64                     // ID of the last event broadcast
+	bufferSize          int                        // Events queued per listener
+
+	// Traffic since start, for Stats
+	broadcast   atomic.Uint64 // Events sent to listeners, bulk_change included
+	rateLimited atomic.Uint64 // Events held back by rate limiting, dropped or coalesced
+	dropped     atomic.Uint64 // Deliveries dropped because a listener fell behind
+	throttled   atomic.Uint64 // Deliveries skipped because a listener was over its rate
+	relayed     atomic.Uint64 // Events relayed from other server instances
 }
 
+// DefaultBufferSize is the number of events queued per listener when none is configured
+const DefaultBufferSize = 10
+
 // Listener represents a single SSE connection
 type Listener struct {
-	ID       string
-	Events   chan models.ChangeEvent
-	Done     chan bool
-	LastPing time.Time
+	ID          string
+	Events      chan models.ChangeEvent
+	Done        chan bool
+	ConnectedAt time.Time
+	LastPing    time.Time       // Guarded by the broadcaster's lock
+	AckRequired bool            // The client acknowledges it is alive and is evicted when acks stop
+	LastAck     time.Time       // Guarded by the broadcaster's lock
+	types       map[string]bool // Event types delivered; nil delivers all
+	collections map[string]bool // Collections a database listener delivers; nil delivers all
+	closeOnce   sync.Once
+
+	// match narrows events carrying a document, as SubscribeOptions.Match
+	match func(data map[string]interface{}) bool
+	// redact removes what the subscriber can't read from events, as SubscribeOptions.Redact
+	redact func(event models.ChangeEvent) (models.ChangeEvent, bool)
+
+	dropped    atomic.Uint64 // Events dropped because Events was full
+	unreported atomic.Uint64 // Of those, the ones not yet taken by TakeDropped
+
+	bucket              *tokenBucket  // Paces deliveries; nil when listeners aren't paced
+	throttled           atomic.Uint64 // Events skipped because the listener was over its rate
+	unreportedThrottled atomic.Uint64 // Of those, the ones not yet taken by TakeThrottled
+}
+
+// Dropped returns the number of events the listener missed because it fell behind
+func (l *Listener) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+// TakeDropped returns the number of events dropped since it was last called, so the
+// stream can tell its client to resync
+func (l *Listener) TakeDropped() uint64 {
+	return l.unreported.Swap(0)
+}
+
+// Throttled returns the number of events the listener skipped for being over its rate
+func (l *Listener) Throttled() uint64 {
+	return l.throttled.Load()
 }
 
-// NewBroadcaster creates a new event broadcaster
-func NewBroadcaster() *Broadcaster {
+// TakeThrottled returns the number of events skipped for being over the listener's rate
+// since it was last called, so the stream can recommend its client resync
+func (l *Listener) TakeThrottled() uint64 {
+	return l.unreportedThrottled.Swap(0)
+}
+
+// SubscribeOptions narrow and resume a subscription
+type SubscribeOptions struct {
+	LastID uint64   // Replay the events after this one; 0 starts afresh
+	Types  []string // Event types to deliver, from ParseTypes; empty delivers all
+	// Collections narrows a database subscription to the events of these collections;
+	// empty delivers all. Events without a collection always pass
+	Collections []string
+	// Match narrows document events to those whose data it accepts, before or after the
+	// change; nil delivers all. Other events carry no document and always pass
+	Match func(data map[string]interface{}) bool
+	// Redact returns an event as the subscriber may read it, called as each is
+	// delivered or replayed, or reports false to drop it; nil delivers events whole.
+	// Match sees the whole document
+	Redact func(event models.ChangeEvent) (models.ChangeEvent, bool)
+}
+
+// wants reports whether a listener delivers an event
+// bulk_change events always pass, since they may summarize events of any type
+func (l *Listener) wants(event models.ChangeEvent) bool {
+	if l.types != nil && !l.types[event.EventType] && event.EventType != EventTypeBulkChange {
+		return false
+	}
+	if l.collections != nil && !l.inCollections(event) {
+		return false
+	}
+	return l.match == nil || l.matches(event)
+}
+
+// inCollections reports whether an event concerns one of the listener's collections;
+// bulk_change events do when they count changes to any of them
+func (l *Listener) inCollections(event models.ChangeEvent) bool {
+	if event.EventType == EventTypeBulkChange {
+		for collection := range event.Counts {
+			if l.collections[collection] {
+				return true
+			}
+		}
+		return false
+	}
+	return event.Collection == "" || l.collections[event.Collection]
+}
+
+// narrow trims a bulk_change event's counts to the listener's collections
+func (l *Listener) narrow(event models.ChangeEvent) models.ChangeEvent {
+	if l.collections == nil || event.EventType != EventTypeBulkChange {
+		return event
+	}
+	counts := make(map[string]int)
+	for collection, count := range event.Counts {
+		if l.collections[collection] {
+			counts[collection] = count
+		}
+	}
+	event.Counts = counts
+	return event
+}
+
+// strip removes what the listener can't read from an event, reporting false when it
+// can't read the event at all
+func (l *Listener) strip(event models.ChangeEvent) (models.ChangeEvent, bool) {
+	if l.redact == nil {
+		return event, true
+	}
+	return l.redact(event)
+}
+
+// Mask removes the fields mask names from the documents of a document event, or the
+// documents whole when mask reports false, for a SubscribeOptions.Redact. The event's
+// data may be shared, so it is copied rather than changed
+func Mask(event models.ChangeEvent, mask func(collection string) ([]string, bool)) models.ChangeEvent {
+	switch event.EventType {
+	case "insert", "update", "delete", "restore":
+	default:
+		return event
+	}
+	fields, ok := mask(event.Collection)
+	if !ok {
+		event.Data, event.OldData = nil, nil
+		return event
+	}
+	if len(fields) == 0 {
+		return event
+	}
+	event.Data = withoutFields(event.Data, fields)
+	event.OldData = withoutFields(event.OldData, fields)
+	return event
+}
+
+// stripAll strips replayed events, dropping those the listener can't read, outside the
+// broadcaster's lock since redacting may read schemas
+func (l *Listener) stripAll(events []models.ChangeEvent) []models.ChangeEvent {
+	readable := events[:0]
+	for _, event := range events {
+		if event, ok := l.strip(event); ok {
+			readable = append(readable, event)
+		}
+	}
+	return readable
+}
+
+// withoutFields returns a copy of data without some fields
+func withoutFields(data map[string]interface{}, fields []string) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+	data = maps.Clone(data)
+	for _, field := range fields {
+		delete(data, field)
+	}
+	return data
+}
+
+// matches reports whether an event concerns a document the listener's match accepts
+// Updates pass when the document matched before or after, so listeners see documents
+// leave their view; events without a document always pass
+func (l *Listener) matches(event models.ChangeEvent) bool {
+	switch event.EventType {
+	case "insert", "restore":
+		return l.match(event.Data)
+	case "update":
+		return l.match(event.Data) || (event.OldData != nil && l.match(event.OldData))
+	case "delete":
+		return event.OldData == nil || l.match(event.OldData)
+	default:
+		return true
+	}
+}
+
+// Listeners are evicted when the server hasn't written a ping to them for staleAfter,
+// or, if they acknowledge, when the client hasn't acknowledged for AckTimeout
+const (
+	staleAfter = 2 * time.Minute
+	AckTimeout = time.Minute
+)
+
+// stale reports whether a listener should be evicted
+func (l *Listener) stale(now time.Time) bool {
+	if now.Sub(l.LastPing) > staleAfter {
+		return true
+	}
+	return l.AckRequired && now.Sub(l.LastAck) > AckTimeout
+}
+
+// close closes the listener's Done channel; later calls do nothing
+// Both cleanup and the handler's unsubscribe close listeners, in either order
+func (l *Listener) close() {
+	l.closeOnce.Do(func() { close(l.Done) })
+}
+
+// NewBroadcaster creates a new event broadcaster limiting each database's events and
+// queueing up to bufferSize events per listener; 0 uses DefaultBufferSize
+func NewBroadcaster(limit RateLimit, bufferSize int) *Broadcaster {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
 	b := &Broadcaster{
 		databaseListeners:   make(map[string]map[*Listener]bool),
 		collectionListeners: make(map[string]map[string]map[*Listener]bool),
+		firehose:            make(map[*Listener]bool),
+		limiter:             newRateLimiter(limit),
+		replays:             make(map[string]*replayLog),
+		bufferSize:          bufferSize,
 	}
+	// Seeding IDs with the start time keeps them increasing across restarts, so a client
+	// resuming from a previous run is recognized
+	b.firstID = uint64(time.Now().UnixMicro())
+	b.lastID = b.firstID
 
 	// Start cleanup goroutine for dead connections
 	go b.cleanupRoutine()
@@ -39,20 +264,56 @@ func NewBroadcaster() *Broadcaster {
 
 // Subscribe adds a listener for database-level events
 func (b *Broadcaster) Subscribe(dbID string) *Listener {
-	listener := &Listener{
-		ID:       generateListenerID(),
-		Events:   make(chan models.ChangeEvent, 10),
-		Done:     make(chan bool),
-		LastPing: time.Now(),
-	}
+	listener, _, _ := b.SubscribeWith(dbID, SubscribeOptions{})
+	return listener
+}
+
+// SubscribeWith adds a listener for database-level events of some types, resuming after
+// the event with opts.LastID: the events since are returned, with whether none were lost.
+// The listener gets every later event, and none of those returned
+func (b *Broadcaster) SubscribeWith(dbID string, opts SubscribeOptions) (*Listener, []models.ChangeEvent, bool) {
+	listener := b.newListener(opts)
 
 	b.mu.Lock()
 	if b.databaseListeners[dbID] == nil {
 		b.databaseListeners[dbID] = make(map[*Listener]bool)
 	}
 	b.databaseListeners[dbID][listener] = true
+
+	if opts.LastID == 0 {
+		b.mu.Unlock()
+		return listener, nil, true
+	}
+	missed, complete := b.replay(listener, dbID, "", opts.LastID)
 	b.mu.Unlock()
+	return listener, listener.stripAll(missed), complete
+}
 
+// newListener creates a listener with a fresh ID, delivering the events opts select
+func (b *Broadcaster) newListener(opts SubscribeOptions) *Listener {
+	now := time.Now()
+	listener := &Listener{
+		ID:          generateListenerID(),
+		Events:      make(chan models.ChangeEvent, b.bufferSize),
+		Done:        make(chan bool),
+		ConnectedAt: now,
+		LastPing:    now,
+		match:       opts.Match,
+		redact:      opts.Redact,
+		bucket:      newTokenBucket(b.limiter.limit.ListenerEventsPerSecond, b.limiter.limit.ListenerBurst),
+	}
+	if len(opts.Types) > 0 {
+		listener.types = make(map[string]bool, len(opts.Types))
+		for _, eventType := range opts.Types {
+			listener.types[eventType] = true
+		}
+	}
+	if len(opts.Collections) > 0 {
+		listener.collections = make(map[string]bool, len(opts.Collections))
+		for _, collection := range opts.Collections {
+			listener.collections[collection] = true
+		}
+	}
 	return listener
 }
 
@@ -68,17 +329,19 @@ func (b *Broadcaster) Unsubscribe(dbID string, listener *Listener) {
 		}
 	}
 
-	close(listener.Done)
+	listener.close()
 }
 
 // SubscribeCollection adds a listener for collection-specific events
 func (b *Broadcaster) SubscribeCollection(dbID string, collection string) *Listener {
-	listener := &Listener{
-		ID:       generateListenerID(),
-		Events:   make(chan models.ChangeEvent, 10),
-		Done:     make(chan bool),
-		LastPing: time.Now(),
-	}
+	listener, _, _ := b.SubscribeCollectionWith(dbID, collection, SubscribeOptions{})
+	return listener
+}
+
+// SubscribeCollectionWith adds a listener for collection-specific events of some types,
+// resuming after the event with opts.LastID as SubscribeWith does
+func (b *Broadcaster) SubscribeCollectionWith(dbID string, collection string, opts SubscribeOptions) (*Listener, []models.ChangeEvent, bool) {
+	listener := b.newListener(opts)
 
 	b.mu.Lock()
 	if b.collectionListeners[dbID] == nil {
@@ -88,9 +351,14 @@ func (b *Broadcaster) SubscribeCollection(dbID string, collection string) *Liste
 		b.collectionListeners[dbID][collection] = make(map[*Listener]bool)
 	}
 	b.collectionListeners[dbID][collection][listener] = true
-	b.mu.Unlock()
 
-	return listener
+	if opts.LastID == 0 {
+		b.mu.Unlock()
+		return listener, nil, true
+	}
+	missed, complete := b.replay(listener, dbID, collection, opts.LastID)
+	b.mu.Unlock()
+	return listener, listener.stripAll(missed), complete
 }
 
 // UnsubscribeCollection removes a collection listener
@@ -110,40 +378,216 @@ func (b *Broadcaster) UnsubscribeCollection(dbID string, collection string, list
 		}
 	}
 
-	close(listener.Done)
+	listener.close()
+}
+
+// Observe registers a function called with every broadcast event, before rate limiting,
+// so it sees each change even when listeners get a bulk_change instead
+// Observers run on the writer's goroutine and must not block
+func (b *Broadcaster) Observe(fn func(models.ChangeEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.observers = append(b.observers, fn)
 }
 
 // Broadcast sends an event to all listeners for a database and specific collection
+// Events over the database's rate limit are dropped or coalesced into a bulk_change event
 func (b *Broadcaster) Broadcast(dbID string, event models.ChangeEvent) {
 	b.mu.RLock()
-	databaseListeners := b.databaseListeners[dbID]
-	var collectionListeners map[*Listener]bool
-	if collections, exists := b.collectionListeners[dbID]; exists {
-		collectionListeners = collections[event.Collection]
-	}
+	observers := b.observers
 	b.mu.RUnlock()
+	for _, observe := range observers {
+		observe(event)
+	}
+	b.deliver(dbID, event)
+}
+
+// BroadcastRelayed sends an event relayed from another server instance to this one's
+// listeners; observers saw it on the instance it happened on, so they are skipped
+func (b *Broadcaster) BroadcastRelayed(dbID string, event models.ChangeEvent) {
+	b.relayed.Add(1)
+	b.deliver(dbID, event)
+}
+
+// deliver sends an event to the listeners for its database and collection, subject to
+// the database's rate limit
+func (b *Broadcaster) deliver(dbID string, event models.ChangeEvent) {
+	admitted, flushIn := b.limiter.admit(dbID, event, time.Now())
+	if flushIn > 0 {
+		time.AfterFunc(flushIn, func() { b.broadcastBulk(dbID) })
+	}
+	if !admitted {
+		b.rateLimited.Add(1)
+		return
+	}
+
+	// The event is logged and the listeners picked together, so a subscriber either
+	// replays it or receives it, never both
+	b.mu.Lock()
+	event = b.record(dbID, event)
+	databaseListeners := listenersOf(b.databaseListeners[dbID])
+	collectionListeners := listenersOf(b.collectionListeners[dbID][event.Collection])
+	firehose := listenersOf(b.firehose)
+	b.mu.Unlock()
+
+	b.broadcast.Add(1)
+	b.send(databaseListeners, event)
+	b.send(collectionListeners, event)
+	b.send(firehose, event)
+}
+
+// broadcastBulk sends the events coalesced for a database as bulk_change events
+// Database-level and firehose listeners get the counts for every collection, collection
+// listeners only their collection's
+func (b *Broadcaster) broadcastBulk(dbID string) {
+	counts := b.limiter.flush(dbID)
+	if len(counts) == 0 {
+		return
+	}
+
+	// Collection listeners get variants of the logged event, under the same ID
+	b.mu.Lock()
+	event := b.record(dbID, models.ChangeEvent{
+		EventType:  EventTypeBulkChange,
+		DatabaseID: dbID,
+		Counts:     counts,
+		Timestamp:  time.Now(),
+	})
+	databaseListeners := listenersOf(b.databaseListeners[dbID])
+	collectionListeners := make(map[string][]*Listener, len(counts))
+	for collection := range counts {
+		collectionListeners[collection] = listenersOf(b.collectionListeners[dbID][collection])
+	}
+	firehose := listenersOf(b.firehose)
+	b.mu.Unlock()
+
+	b.broadcast.Add(1)
+	b.send(databaseListeners, event)
+	b.send(firehose, event)
+	for collection, listeners := range collectionListeners {
+		variant := event
+		variant.Collection = collection
+		variant.Counts = map[string]int{collection: counts[collection]}
+		b.send(listeners, variant)
+	}
+}
 
-	// Send to database-level listeners
-	for listener := range databaseListeners {
+// listenersOf copies a set of listeners, so they can be sent to after the lock is released
+func listenersOf(set map[*Listener]bool) []*Listener {
+	listeners := make([]*Listener, 0, len(set))
+	for listener := range set {
+		listeners = append(listeners, listener)
+	}
+	return listeners
+}
+
+// send delivers an event to the listeners wanting it, without blocking
+// Listeners too far behind to take it have it counted as dropped instead, and those over
+// their rate as throttled. bulk_change events already summarize many and aren't paced
+func (b *Broadcaster) send(listeners []*Listener, event models.ChangeEvent) {
+	now := time.Now()
+	for _, listener := range listeners {
+		if !listener.wants(event) {
+			continue
+		}
+		delivered, ok := listener.strip(listener.narrow(event))
+		if !ok {
+			continue
+		}
+		if event.EventType != EventTypeBulkChange && !listener.bucket.take(now) {
+			// The stream recommends its client resync
+			listener.throttled.Add(1)
+			listener.unreportedThrottled.Add(1)
+			b.throttled.Add(1)
+			continue
+		}
 		select {
-		case listener.Events <- event:
+		case listener.Events <- delivered:
 			// Event sent successfully
 		default:
-			// Channel full, skip this listener
-			// TODO: Add logging
+			// Channel full; the stream tells its client to resync
+			listener.dropped.Add(1)
+			listener.unreported.Add(1)
+			b.dropped.Add(1)
 		}
 	}
+}
 
-	// Send to collection-specific listeners
-	for listener := range collectionListeners {
-		select {
-		case listener.Events <- event:
-			// Event sent successfully
-		default:
-			// Channel full, skip this listener
-			// TODO: Add logging
+// Stats summarizes the broadcaster's listeners and traffic for operators
+type Stats struct {
+	DatabaseListeners   int             `json:"database_listeners"`
+	CollectionListeners int             `json:"collection_listeners"`
+	FirehoseListeners   int             `json:"firehose_listeners"`  // Following every database
+	EventsBroadcast     uint64          `json:"events_broadcast"`    // Sent to listeners since start, bulk_change included
+	EventsRateLimited   uint64          `json:"events_rate_limited"` // Held back by rate limiting, dropped or coalesced
+	EventsDropped       uint64          `json:"events_dropped"`      // Deliveries dropped because a listener fell behind
+	EventsThrottled     uint64          `json:"events_throttled"`    // Deliveries skipped because a listener was over its rate
+	EventsRelayed       uint64          `json:"events_relayed"`      // Received from other server instances
+	Databases           []DatabaseStats `json:"databases"`           // Databases with listeners, by ID
+}
+
+// DatabaseStats counts the listeners of one database
+type DatabaseStats struct {
+	DatabaseID          string         `json:"database_id"`
+	DatabaseListeners   int            `json:"database_listeners"`
+	CollectionListeners map[string]int `json:"collection_listeners"` // Per collection
+	Queued              int            `json:"queued"`               // Events waiting in its listeners' buffers
+	Dropped             uint64         `json:"dropped"`              // Events its current listeners missed
+	Throttled           uint64         `json:"throttled"`            // Events its current listeners skipped over their rate
+}
+
+// Stats returns counts of active listeners and of events since the server started
+func (b *Broadcaster) Stats() Stats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := Stats{
+		EventsBroadcast:   b.broadcast.Load(),
+		EventsRateLimited: b.rateLimited.Load(),
+		EventsDropped:     b.dropped.Load(),
+		EventsThrottled:   b.throttled.Load(),
+		EventsRelayed:     b.relayed.Load(),
+		FirehoseListeners: len(b.firehose),
+		Databases:         []DatabaseStats{},
+	}
+	databases := make(map[string]*DatabaseStats)
+	database := func(dbID string) *DatabaseStats {
+		if databases[dbID] == nil {
+			databases[dbID] = &DatabaseStats{DatabaseID: dbID, CollectionListeners: map[string]int{}}
 		}
+		return databases[dbID]
 	}
+	count := func(db *DatabaseStats, listener *Listener) {
+		db.Queued += len(listener.Events)
+		db.Dropped += listener.Dropped()
+		db.Throttled += listener.Throttled()
+	}
+
+	for dbID, listeners := range b.databaseListeners {
+		db := database(dbID)
+		for listener := range listeners {
+			db.DatabaseListeners++
+			count(db, listener)
+		}
+		stats.DatabaseListeners += len(listeners)
+	}
+	for dbID, collections := range b.collectionListeners {
+		db := database(dbID)
+		for collection, listeners := range collections {
+			for listener := range listeners {
+				db.CollectionListeners[collection]++
+				count(db, listener)
+			}
+			stats.CollectionListeners += len(listeners)
+		}
+	}
+
+	for _, db := range databases {
+		stats.Databases = append(stats.Databases, *db)
+	}
+	slices.SortFunc(stats.Databases, func(x, y DatabaseStats) int { return strings.Compare(x.DatabaseID, y.DatabaseID) })
+	return stats
 }
 
 // GetListenerCount returns the number of active listeners for a database
@@ -164,14 +608,14 @@ func (b *Broadcaster) cleanupRoutine() {
 
 	for range ticker.C {
 		b.mu.Lock()
+		now := time.Now()
 
 		// Cleanup database-level listeners
 		for dbID, listeners := range b.databaseListeners {
 			for listener := range listeners {
-				// Remove listeners that haven't been pinged in 2 minutes
-				if time.Since(listener.LastPing) > 2*time.Minute {
+				if listener.stale(now) {
 					delete(listeners, listener)
-					close(listener.Done)
+					listener.close()
 				}
 			}
 			// Clean up empty database entries
@@ -184,10 +628,9 @@ func (b *Broadcaster) cleanupRoutine() {
 		for dbID, collections := range b.collectionListeners {
 			for collection, listeners := range collections {
 				for listener := range listeners {
-					// Remove listeners that haven't been pinged in 2 minutes
-					if time.Since(listener.LastPing) > 2*time.Minute {
+					if listener.stale(now) {
 						delete(listeners, listener)
-						close(listener.Done)
+						listener.close()
 					}
 				}
 				// Clean up empty collection entries
@@ -201,19 +644,244 @@ func (b *Broadcaster) cleanupRoutine() {
 			}
 		}
 
+		for listener := range b.firehose {
+			if listener.stale(now) {
+				delete(b.firehose, listener)
+				listener.close()
+			}
+		}
+
 		b.mu.Unlock()
+
+		b.limiter.expire(now)
+		b.expireReplays(now)
+	}
+}
+
+// RequireAck makes a listener's client responsible for acknowledging it is alive
+// The listener is evicted if the client then goes AckTimeout without acknowledging
+func (b *Broadcaster) RequireAck(listener *Listener) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	listener.AckRequired = true
+	listener.LastAck = time.Now()
+}
+
+// Ack records that the client of a database's listener is alive
+// Returns false if the database has no such listener, as after eviction
+func (b *Broadcaster) Ack(dbID string, listenerID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	listener := b.findListener(dbID, listenerID)
+	if listener == nil {
+		return false
 	}
+	listener.LastAck = time.Now()
+	return true
+}
+
+// findListener returns a database's database-level or collection listener by ID
+func (b *Broadcaster) findListener(dbID string, listenerID string) *Listener {
+	for listener := range b.databaseListeners[dbID] {
+		if listener.ID == listenerID {
+			return listener
+		}
+	}
+	for _, listeners := range b.collectionListeners[dbID] {
+		for listener := range listeners {
+			if listener.ID == listenerID {
+				return listener
+			}
+		}
+	}
+	return nil
 }
 
 // UpdatePing updates the last ping time for a listener
 func (b *Broadcaster) UpdatePing(listener *Listener) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	listener.LastPing = time.Now()
 }
 
-// FormatSSE formats an event as Server-Sent Events format
-func FormatSSE(event models.ChangeEvent) string {
-	data, _ := json.Marshal(event)
-	return fmt.Sprintf("event: change\ndata: %s\n\n", string(data))
+// Event format versions, negotiated with ?v= when subscribing
+// Version 0 sends the bare ChangeEvent, as before versioning, and stays the default so
+// existing consumers keep working. Later versions wrap it in an EventEnvelope
+const (
+	VersionUnversioned = 0
+	VersionLatest      = 1
+)
+
+// Versions lists the event format versions the server can send
+func Versions() []int {
+	versions := make([]int, 0, VersionLatest+1)
+	for v := VersionUnversioned; v <= VersionLatest; v++ {
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+// EventTypes lists the types of event listeners receive
+var EventTypes = []string{
+	"insert", "update", "delete", "restore",
+	"schema_created", "schema_updated", "schema_renamed", "schema_deleted",
+	EventTypeBulkChange,
+}
+
+// ParseTypes parses the ?events= subscription parameter, a comma-separated list of
+// event types; empty selects every type
+func ParseTypes(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var types []string
+	for _, eventType := range strings.Split(value, ",") {
+		eventType = strings.TrimSpace(eventType)
+		if !slices.Contains(EventTypes, eventType) {
+			return nil, fmt.Errorf("unknown event type %q: supported types are %s", eventType, strings.Join(EventTypes, ", "))
+		}
+		types = append(types, eventType)
+	}
+	return types, nil
+}
+
+// ParseVersion parses the ?v= subscription parameter; empty selects version 0
+func ParseVersion(value string) (int, error) {
+	if value == "" {
+		return VersionUnversioned, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < VersionUnversioned || v > VersionLatest {
+		return 0, fmt.Errorf("unsupported event version %q: supported versions are %d to %d", value, VersionUnversioned, VersionLatest)
+	}
+	return v, nil
+}
+
+// Batching, with ?batch=, holds events for a window of MinBatchWindow to MaxBatchWindow
+// and sends them together, up to MaxBatchEvents at a time
+const (
+	MinBatchWindow = 10 * time.Millisecond
+	MaxBatchWindow = 10 * time.Second
+	MaxBatchEvents = 500
+)
+
+// ParseBatch parses the ?batch= subscription parameter, a duration such as 250ms; empty
+// selects no batching
+func ParseBatch(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window < MinBatchWindow || window > MaxBatchWindow {
+		return 0, fmt.Errorf("invalid batch window %q: must be a duration from %v to %v", value, MinBatchWindow, MaxBatchWindow)
+	}
+	return window, nil
+}
+
+// Streams send a heartbeat every DefaultHeartbeat, or as often as ?heartbeat= asks from
+// MinHeartbeat to MaxHeartbeat. The bound keeps WebSocket pongs, which acknowledge, well
+// within AckTimeout
+const (
+	DefaultHeartbeat = 15 * time.Second
+	MinHeartbeat     = time.Second
+	MaxHeartbeat     = 30 * time.Second
+)
+
+// ParseHeartbeat parses the ?heartbeat= subscription parameter, a duration such as 5s;
+// empty selects DefaultHeartbeat
+func ParseHeartbeat(value string) (time.Duration, error) {
+	if value == "" {
+		return DefaultHeartbeat, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < MinHeartbeat || interval > MaxHeartbeat {
+		return 0, fmt.Errorf("invalid heartbeat interval %q: must be a duration from %v to %v", value, MinHeartbeat, MaxHeartbeat)
+	}
+	return interval, nil
+}
+
+// FormatSSE formats an event as Server-Sent Events format in an event format version
+// Broadcast events carry their ID, which the client sends back as Last-Event-ID
+func FormatSSE(event models.ChangeEvent, version int) string {
+	frame := fmt.Sprintf("event: change\ndata: %s\n\n", string(eventData(event, version)))
+	if event.ID != 0 {
+		frame = fmt.Sprintf("id: %d\n", event.ID) + frame
+	}
+	return frame
+}
+
+// FormatSSEBatch formats events as one batch frame, whose data is an array of the events
+// in an event format version. The frame carries the ID of the last event with one
+func FormatSSEBatch(batch []models.ChangeEvent, version int) string {
+	frame := fmt.Sprintf("event: batch\ndata: %s\n\n", string(batchData(batch, version)))
+	if id := lastID(batch); id != 0 {
+		frame = fmt.Sprintf("id: %d\n", id) + frame
+	}
+	return frame
+}
+
+// Message is a WebSocket event message: the name and data of the SSE frame it stands for
+type Message struct {
+	Event string          `json:"event"`
+	ID    string          `json:"id,omitempty"` // Resume after it with ?last_event_id=
+	Data  json.RawMessage `json:"data"`
+}
+
+// FormatMessage formats an event as a WebSocket message in an event format version
+func FormatMessage(event models.ChangeEvent, version int) []byte {
+	message := Message{Event: "change", Data: eventData(event, version)}
+	if event.ID != 0 {
+		message.ID = strconv.FormatUint(event.ID, 10)
+	}
+	data, _ := json.Marshal(message)
+	return data
+}
+
+// FormatMessageBatch formats events as one WebSocket batch message, with the same data as
+// FormatSSEBatch
+func FormatMessageBatch(batch []models.ChangeEvent, version int) []byte {
+	message := Message{Event: "batch", Data: batchData(batch, version)}
+	if id := lastID(batch); id != 0 {
+		message.ID = strconv.FormatUint(id, 10)
+	}
+	data, _ := json.Marshal(message)
+	return data
+}
+
+// batchData marshals events as a JSON array in an event format version
+func batchData(batch []models.ChangeEvent, version int) []byte {
+	data := []byte{'['}
+	for i, event := range batch {
+		if i > 0 {
+			data = append(data, ',')
+		}
+		data = append(data, eventData(event, version)...)
+	}
+	return append(data, ']')
+}
+
+// lastID returns the ID of the last event in a batch that has one, or 0
+func lastID(batch []models.ChangeEvent) uint64 {
+	for i := len(batch) - 1; i >= 0; i-- {
+		if batch[i].ID != 0 {
+			return batch[i].ID
+		}
+	}
+	return 0
+}
+
+// eventData marshals an event in an event format version
+func eventData(event models.ChangeEvent, version int) []byte {
+	var data []byte
+	if version == VersionUnversioned {
+		data, _ = json.Marshal(event)
+	} else {
+		data, _ = json.Marshal(models.EventEnvelope{V: version, Event: event})
+	}
+	return data
 }
 
 // FormatPing formats a ping/heartbeat message
@@ -222,6 +890,9 @@ func FormatPing() string {
 }
 
 // generateListenerID generates a unique listener ID
+// IDs are random so one client can't guess, and acknowledge for, another's listener
 func generateListenerID() string {
-	return fmt.Sprintf("listener_%d", time.Now().UnixNano())
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "listener_" + hex.EncodeToString(b)
 }
@@ -0,0 +1,468 @@
+package events
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"", VersionUnversioned, false},
+		{"0", 0, false},
+		{"1", 1, false},
+		{"2", 0, true},
+		{"-1", 0, true},
+		{"latest", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseVersion(%q) = %d, %v, want %d, wantErr %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFormatSSE(t *testing.T) {
+	event := models.ChangeEvent{
+		EventType:  "insert",
+		DatabaseID: "db_abc",
+		Collection: "posts",
+		DocumentID: "doc_1",
+		Timestamp:  time.Unix(1700000000, 0).UTC(),
+	}
+
+	// Unversioned subscribers get the bare event, as before versioning
+	frame := FormatSSE(event, VersionUnversioned)
+	var bare map[string]interface{}
+	if err := json.Unmarshal([]byte(sseData(t, frame)), &bare); err != nil {
+		t.Fatalf("version 0 data is not JSON: %v", err)
+	}
+	if bare["event_type"] != "insert" || bare["v"] != nil {
+		t.Errorf("version 0 data = %v, want the bare event", bare)
+	}
+
+	frame = FormatSSE(event, 1)
+	var envelope models.EventEnvelope
+	if err := json.Unmarshal([]byte(sseData(t, frame)), &envelope); err != nil {
+		t.Fatalf("version 1 data is not JSON: %v", err)
+	}
+	if envelope.V != 1 || envelope.Event.DocumentID != "doc_1" || envelope.Event.EventType != "insert" {
+		t.Errorf("version 1 data = %+v, want the event in a v1 envelope", envelope)
+	}
+	// Broadcast events carry their ID for Last-Event-ID
+	event.ID = 42
+	if frame := FormatSSE(event, 1); !strings.HasPrefix(frame, "id: 42\nevent: change\n") {
+		t.Errorf("frame = %q, want it to start with the event ID", frame)
+	}
+}
+
+func TestFormatMessage(t *testing.T) {
+	event := models.ChangeEvent{EventType: "update", DatabaseID: "db_abc", Collection: "posts", DocumentID: "doc_1"}
+
+	// Messages carry the same data as the SSE frame of the event
+	var message Message
+	if err := json.Unmarshal(FormatMessage(event, 1), &message); err != nil {
+		t.Fatalf("message is not JSON: %v", err)
+	}
+	if message.Event != "change" || string(message.Data) != sseData(t, FormatSSE(event, 1)) {
+		t.Errorf("message = %s %s, want the change event's SSE data", message.Event, message.Data)
+	}
+}
+
+func TestFormatBatch(t *testing.T) {
+	batch := []models.ChangeEvent{
+		{ID: 41, EventType: "insert", DatabaseID: "db_abc", Collection: "posts", DocumentID: "doc_1"},
+		{ID: 42, EventType: "update", DatabaseID: "db_abc", Collection: "posts", DocumentID: "doc_1"},
+		{EventType: "schema_updated", DatabaseID: "db_abc", Collection: "posts"},
+	}
+
+	// The frame carries the last ID, and each event's data as its own frame would
+	frame := FormatSSEBatch(batch, 1)
+	prefix := "id: 42\nevent: batch\ndata: "
+	if !strings.HasPrefix(frame, prefix) || !strings.HasSuffix(frame, "\n\n") {
+		t.Fatalf("frame = %q, want a batch frame with ID 42", frame)
+	}
+	var data []json.RawMessage
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(frame, prefix), "\n\n")), &data); err != nil {
+		t.Fatalf("batch data is not a JSON array: %v", err)
+	}
+	if len(data) != 3 || string(data[1]) != sseData(t, strings.TrimPrefix(FormatSSE(batch[1], 1), "id: 42\n")) {
+		t.Errorf("batch data = %s, want the three events' data", data)
+	}
+
+	var message Message
+	if err := json.Unmarshal(FormatMessageBatch(batch, 1), &message); err != nil {
+		t.Fatalf("message is not JSON: %v", err)
+	}
+	if message.Event != "batch" || message.ID != "42" || !strings.Contains(frame, string(message.Data)) {
+		t.Errorf("message = %s %s %s, want the batch frame's ID and data", message.Event, message.ID, message.Data)
+	}
+}
+
+func TestParseBatch(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": 0, "250ms": 250 * time.Millisecond, "10s": 10 * time.Second} {
+		if got, err := ParseBatch(value); err != nil || got != want {
+			t.Errorf("ParseBatch(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"250", "1ms", "1m", "-5s"} {
+		if _, err := ParseBatch(value); err == nil {
+			t.Errorf("ParseBatch(%q) error = nil, want an error", value)
+		}
+	}
+}
+
+func TestParseHeartbeat(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": DefaultHeartbeat, "5s": 5 * time.Second, "1s": time.Second, "30s": 30 * time.Second} {
+		if got, err := ParseHeartbeat(value); err != nil || got != want {
+			t.Errorf("ParseHeartbeat(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"5", "500ms", "1m", "-5s"} {
+		if _, err := ParseHeartbeat(value); err == nil {
+			t.Errorf("ParseHeartbeat(%q) error = nil, want an error", value)
+		}
+	}
+}
+
+// sseData returns the data line of a change event frame
+func sseData(t *testing.T, frame string) string {
+	t.Helper()
+	if !strings.HasPrefix(frame, "event: change\ndata: ") || !strings.HasSuffix(frame, "\n\n") {
+		t.Fatalf("malformed frame %q", frame)
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(frame, "event: change\ndata: "), "\n\n")
+}
+
+func TestListenerStale(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		listener *Listener
+		want     bool
+	}{
+		{"pinged", &Listener{LastPing: now}, false},
+		{"not pinged", &Listener{LastPing: now.Add(-staleAfter - time.Second)}, true},
+		{"idle but acknowledging", &Listener{LastPing: now, AckRequired: true, LastAck: now.Add(-AckTimeout / 2)}, false},
+		{"pinged but not acknowledging", &Listener{LastPing: now, AckRequired: true, LastAck: now.Add(-AckTimeout - time.Second)}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.listener.stale(now); got != tt.want {
+			t.Errorf("%s: stale() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBroadcaster_Ack(t *testing.T) {
+	b := NewBroadcaster(RateLimit{}, 0)
+	dbListener := b.Subscribe("db_a")
+	collectionListener := b.SubscribeCollection("db_a", "posts")
+	b.RequireAck(collectionListener)
+	acked := collectionListener.LastAck
+
+	time.Sleep(time.Millisecond)
+	if !b.Ack("db_a", collectionListener.ID) || !collectionListener.LastAck.After(acked) {
+		t.Error("Ack() did not record the acknowledgment of a collection listener")
+	}
+	if !b.Ack("db_a", dbListener.ID) {
+		t.Error("Ack() = false for a database listener, want true")
+	}
+	if b.Ack("db_b", dbListener.ID) {
+		t.Error("Ack() = true for another database's listener, want false")
+	}
+
+	b.Unsubscribe("db_a", dbListener)
+	if b.Ack("db_a", dbListener.ID) {
+		t.Error("Ack() = true after Unsubscribe(), want false")
+	}
+}
+
+func TestListener_CloseTwice(t *testing.T) {
+	b := NewBroadcaster(RateLimit{}, 0)
+	listener := b.Subscribe("db_a")
+
+	// Cleanup evicts the listener, then the handler unsubscribes it
+	listener.close()
+	b.Unsubscribe("db_a", listener)
+
+	select {
+	case <-listener.Done:
+	default:
+		t.Error("Done is open after the listener was closed")
+	}
+}
+
+func TestBroadcaster_ObserveSkipsRateLimit(t *testing.T) {
+	b := NewBroadcaster(RateLimit{EventsPerSecond: 1}, 0)
+	var observed []string
+	b.Observe(func(event models.ChangeEvent) {
+		observed = append(observed, event.DocumentID)
+	})
+
+	for _, docID := range []string{"doc_1", "doc_2", "doc_3"} {
+		b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", DatabaseID: "db_a", Collection: "posts", DocumentID: docID})
+	}
+
+	if strings.Join(observed, ",") != "doc_1,doc_2,doc_3" {
+		t.Errorf("observed %v, want every event despite the rate limit", observed)
+	}
+}
+
+func TestBroadcaster_EventTypes(t *testing.T) {
+	b := NewBroadcaster(RateLimit{}, 0)
+	listener, _, _ := b.SubscribeWith("db_a", SubscribeOptions{Types: []string{"delete", "schema_created"}})
+	defer b.Unsubscribe("db_a", listener)
+
+	for _, eventType := range []string{"insert", "delete", "update", "schema_created"} {
+		b.Broadcast("db_a", models.ChangeEvent{EventType: eventType, Collection: "posts", Timestamp: time.Now()})
+	}
+	for _, want := range []string{"delete", "schema_created"} {
+		if event := <-listener.Events; event.EventType != want {
+			t.Errorf("listener got %s, want %s", event.EventType, want)
+		}
+	}
+	select {
+	case event := <-listener.Events:
+		t.Errorf("listener got %s, want nothing more", event.EventType)
+	default:
+	}
+
+	// Replays are filtered the same way
+	_, missed, _ := b.SubscribeCollectionWith("db_a", "posts", SubscribeOptions{LastID: b.firstID, Types: []string{"update"}})
+	if len(missed) != 1 || missed[0].EventType != "update" {
+		t.Errorf("replayed %v, want only the update", missed)
+	}
+}
+
+func TestBroadcaster_Collections(t *testing.T) {
+	b := NewBroadcaster(RateLimit{EventsPerSecond: 4, Coalesce: true}, 0)
+	listener, _, _ := b.SubscribeWith("db_a", SubscribeOptions{Collections: []string{"users", "orders"}})
+	defer b.Unsubscribe("db_a", listener)
+
+	for _, collection := range []string{"users", "posts", "orders", ""} {
+		b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", Collection: collection, Timestamp: time.Now()})
+	}
+	for _, want := range []string{"users", "orders", ""} {
+		if event := <-listener.Events; event.Collection != want {
+			t.Errorf("listener got an event of %q, want %q", event.Collection, want)
+		}
+	}
+
+	// bulk_change events only count the listener's collections
+	for _, collection := range []string{"posts", "orders", "posts"} {
+		b.Broadcast("db_a", models.ChangeEvent{EventType: "update", Collection: collection, Timestamp: time.Now()})
+	}
+	select {
+	case event := <-listener.Events:
+		if event.EventType != EventTypeBulkChange || len(event.Counts) != 1 || event.Counts["orders"] != 1 {
+			t.Errorf("listener got %s with counts %v, want bulk_change counting orders alone", event.EventType, event.Counts)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("listener got no bulk_change event")
+	}
+
+	// Replays are narrowed the same way
+	_, missed, _ := b.SubscribeWith("db_a", SubscribeOptions{LastID: b.firstID, Collections: []string{"posts"}})
+	if len(missed) != 3 || missed[0].Collection != "posts" || missed[1].Collection != "" || len(missed[2].Counts) != 1 || missed[2].Counts["posts"] != 2 {
+		t.Errorf("replayed %v, want the posts insert, the event without a collection and the posts count", missed)
+	}
+}
+
+func TestBroadcaster_Match(t *testing.T) {
+	b := NewBroadcaster(RateLimit{}, 0)
+	open := func(data map[string]interface{}) bool { return data["status"] == "open" }
+	listener, _, _ := b.SubscribeCollectionWith("db_a", "tasks", SubscribeOptions{Match: open})
+	defer b.UnsubscribeCollection("db_a", "tasks", listener)
+
+	changes := []models.ChangeEvent{
+		{EventType: "insert", DocumentID: "a", Data: map[string]interface{}{"status": "open"}},
+		{EventType: "insert", DocumentID: "b", Data: map[string]interface{}{"status": "done"}},
+		{EventType: "update", DocumentID: "b", Data: map[string]interface{}{"status": "open"}},
+		{EventType: "update", DocumentID: "b", Data: map[string]interface{}{"status": "done"}, OldData: map[string]interface{}{"status": "open"}},
+		{EventType: "update", DocumentID: "b", Data: map[string]interface{}{"status": "done", "n": 1.0}, OldData: map[string]interface{}{"status": "done"}},
+		{EventType: "delete", DocumentID: "b", OldData: map[string]interface{}{"status": "done"}},
+		{EventType: "delete", DocumentID: "a"},
+		{EventType: "schema_updated", Data: map[string]interface{}{"schema_name": "tasks"}},
+	}
+	for _, event := range changes {
+		event.Collection = "tasks"
+		event.Timestamp = time.Now()
+		b.Broadcast("db_a", event)
+	}
+
+	// Changes to done documents are skipped, except the update that made one done;
+	// events without a document always pass
+	for _, want := range []string{"insert a", "update b", "update b", "delete a", "schema_updated "} {
+		if event := <-listener.Events; event.EventType+" "+event.DocumentID != want {
+			t.Errorf("listener got %s %s, want %s", event.EventType, event.DocumentID, want)
+		}
+	}
+	select {
+	case event := <-listener.Events:
+		t.Errorf("listener got %s, want nothing more", event.EventType)
+	default:
+	}
+
+	_, missed, _ := b.SubscribeCollectionWith("db_a", "tasks", SubscribeOptions{LastID: b.firstID, Match: open})
+	if len(missed) != 5 {
+		t.Errorf("replayed %d events, want 5", len(missed))
+	}
+}
+
+func TestBroadcaster_Mask(t *testing.T) {
+	b := NewBroadcaster(RateLimit{}, 0)
+	mask := func(collection string) ([]string, bool) {
+		switch collection {
+		case "users":
+			return []string{"ssn", "salary"}, true
+		case "broken":
+			return nil, false
+		}
+		return nil, true
+	}
+	redact := func(event models.ChangeEvent) (models.ChangeEvent, bool) {
+		return Mask(event, mask), true
+	}
+	masked, _, _ := b.SubscribeWith("db_a", SubscribeOptions{Redact: redact})
+	defer b.Unsubscribe("db_a", masked)
+	whole := b.Subscribe("db_a")
+	defer b.Unsubscribe("db_a", whole)
+
+	update := models.ChangeEvent{
+		EventType:  "update",
+		Collection: "users",
+		Data:       map[string]interface{}{"name": "Ann", "ssn": "123-45-6789"},
+		OldData:    map[string]interface{}{"name": "Ann", "salary": 100.0},
+		Timestamp:  time.Now(),
+	}
+	b.Broadcast("db_a", update)
+	b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", Collection: "broken", Data: map[string]interface{}{"a": 1.0}, Timestamp: time.Now()})
+	b.Broadcast("db_a", models.ChangeEvent{EventType: "schema_updated", Collection: "users", Data: map[string]interface{}{"ssn": "kept"}, Timestamp: time.Now()})
+
+	event := <-masked.Events
+	if _, ok := event.Data["ssn"]; ok || event.Data["name"] != "Ann" || len(event.OldData) != 1 {
+		t.Errorf("masked listener got data %v and old data %v, want ssn and salary stripped", event.Data, event.OldData)
+	}
+	if event := <-whole.Events; event.Data["ssn"] == nil || event.OldData["salary"] == nil {
+		t.Errorf("listener without a mask got data %v and old data %v, want them whole", event.Data, event.OldData)
+	}
+	if event := <-masked.Events; event.Data != nil {
+		t.Errorf("masked listener got data %v for a collection whose mask is unknown, want none", event.Data)
+	}
+	if event := <-masked.Events; event.Data["ssn"] != "kept" {
+		t.Errorf("masked listener got schema event data %v, want it untouched", event.Data)
+	}
+
+	// Replays are masked too
+	_, missed, _ := b.SubscribeWith("db_a", SubscribeOptions{LastID: b.firstID - 1, Redact: redact})
+	if len(missed) != 3 || missed[0].Data["ssn"] != nil || missed[1].Data != nil {
+		t.Errorf("replayed %v, want the documents masked", missed)
+	}
+}
+
+func TestBroadcaster_Redact(t *testing.T) {
+	b := NewBroadcaster(RateLimit{}, 0)
+	// Like a read key, which can't read write-key-only documents
+	redact := func(event models.ChangeEvent) (models.ChangeEvent, bool) {
+		return event, event.Visibility != models.VisibilityWriteKeyOnly
+	}
+	listener, _, _ := b.SubscribeWith("db_a", SubscribeOptions{Redact: redact})
+	defer b.Unsubscribe("db_a", listener)
+
+	b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", Collection: "users", DocumentID: "doc_hidden",
+		Visibility: models.VisibilityWriteKeyOnly, Timestamp: time.Now()})
+	b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", Collection: "users", DocumentID: "doc_shown",
+		Visibility: models.VisibilityReadKey, Timestamp: time.Now()})
+	if event := <-listener.Events; event.DocumentID != "doc_shown" {
+		t.Errorf("listener got %s, want the write-key-only document dropped", event.DocumentID)
+	}
+	if len(listener.Events) != 0 || listener.Dropped() != 0 {
+		t.Errorf("listener has %d more events and %d dropped, want none", len(listener.Events), listener.Dropped())
+	}
+
+	// Replays drop them too
+	_, missed, _ := b.SubscribeWith("db_a", SubscribeOptions{LastID: b.firstID - 1, Redact: redact})
+	if len(missed) != 1 || missed[0].DocumentID != "doc_shown" {
+		t.Errorf("replayed %v, want only doc_shown", missed)
+	}
+}
+
+func TestBroadcaster_Dropped(t *testing.T) {
+	b := NewBroadcaster(RateLimit{}, 2)
+	listener := b.Subscribe("db_a")
+	defer b.Unsubscribe("db_a", listener)
+
+	for range 5 {
+		b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", Collection: "posts", Timestamp: time.Now()})
+	}
+	if got := listener.Dropped(); got != 3 {
+		t.Errorf("Dropped() = %d, want the 3 events past the buffer", got)
+	}
+	if got := listener.TakeDropped(); got != 3 {
+		t.Errorf("TakeDropped() = %d, want 3", got)
+	}
+	if got := listener.TakeDropped(); got != 0 {
+		t.Errorf("TakeDropped() again = %d, want 0", got)
+	}
+
+	<-listener.Events
+	b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", Collection: "posts", Timestamp: time.Now()})
+	b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", Collection: "posts", Timestamp: time.Now()})
+	if dropped, total := listener.TakeDropped(), listener.Dropped(); dropped != 1 || total != 4 {
+		t.Errorf("TakeDropped() = %d with %d in total, want 1 of 4", dropped, total)
+	}
+}
+
+func TestBroadcaster_Stats(t *testing.T) {
+	b := NewBroadcaster(RateLimit{EventsPerSecond: 3}, 1)
+	dbListener := b.Subscribe("db_b")
+	defer b.Unsubscribe("db_b", dbListener)
+	postsListener := b.SubscribeCollection("db_a", "posts")
+	defer b.UnsubscribeCollection("db_a", "posts", postsListener)
+	b.Unsubscribe("db_c", b.Subscribe("db_c"))
+
+	for range 2 {
+		b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", Collection: "posts", Timestamp: time.Now()})
+	}
+	for range 4 {
+		b.Broadcast("db_b", models.ChangeEvent{EventType: "insert", Collection: "posts", Timestamp: time.Now()})
+	}
+
+	stats := b.Stats()
+	if stats.DatabaseListeners != 1 || stats.CollectionListeners != 1 {
+		t.Errorf("Stats() listeners = %d database, %d collection, want 1 and 1", stats.DatabaseListeners, stats.CollectionListeners)
+	}
+	// db_b's fourth event is over the rate limit; each listener holds one of the rest
+	if stats.EventsBroadcast != 5 || stats.EventsRateLimited != 1 || stats.EventsDropped != 3 {
+		t.Errorf("Stats() events = %d broadcast, %d rate limited, %d dropped, want 5, 1 and 3", stats.EventsBroadcast, stats.EventsRateLimited, stats.EventsDropped)
+	}
+	if len(stats.Databases) != 2 {
+		t.Fatalf("Stats() databases = %+v, want db_a and db_b", stats.Databases)
+	}
+	if a := stats.Databases[0]; a.DatabaseID != "db_a" || a.CollectionListeners["posts"] != 1 || a.Queued != 1 || a.Dropped != 1 {
+		t.Errorf("Stats() db_a = %+v, want a posts listener with 1 queued and 1 dropped", a)
+	}
+	if bStats := stats.Databases[1]; bStats.DatabaseID != "db_b" || bStats.DatabaseListeners != 1 || bStats.Queued != 1 || bStats.Dropped != 2 {
+		t.Errorf("Stats() db_b = %+v, want a database listener with 1 queued and 2 dropped", bStats)
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	if types, err := ParseTypes("insert, bulk_change"); err != nil || len(types) != 2 {
+		t.Errorf("ParseTypes() = %v, %v, want insert and bulk_change", types, err)
+	}
+	if types, err := ParseTypes(""); err != nil || types != nil {
+		t.Errorf("ParseTypes(\"\") = %v, %v, want every type", types, err)
+	}
+	if _, err := ParseTypes("insert,created"); err == nil {
+		t.Error("ParseTypes accepted an unknown event type")
+	}
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"cmp"
+	"slices"
+
+	"jsondrop/internal/models"
+)
+
+// SubscribeFirehose adds a listener for the events of every database, each carrying its
+// database_id, resuming after the event with opts.LastID as SubscribeWith does
+// Bulk changes arrive with the counts of every collection, as on database streams
+func (b *Broadcaster) SubscribeFirehose(opts SubscribeOptions) (*Listener, []models.ChangeEvent, bool) {
+	listener := b.newListener(opts)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.firehose[listener] = true
+	if opts.LastID == 0 {
+		return listener, nil, true
+	}
+	missed, complete := b.replayAll(listener, opts.LastID)
+	return listener, missed, complete
+}
+
+// UnsubscribeFirehose removes a firehose listener
+func (b *Broadcaster) UnsubscribeFirehose(listener *Listener) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.firehose, listener)
+	listener.close()
+}
+
+// replayAll returns the events after lastID a listener wants across every database, in
+// the order they were broadcast, and whether they are all the events since lastID
+// The caller holds the lock
+func (b *Broadcaster) replayAll(listener *Listener, lastID uint64) ([]models.ChangeEvent, bool) {
+	complete := lastID >= b.firstID && lastID <= b.lastID
+	var missed []models.ChangeEvent
+	for dbID := range b.replays {
+		events, logComplete := b.replay(listener, dbID, "", lastID)
+		missed = append(missed, events...)
+		complete = complete && logComplete
+	}
+	slices.SortFunc(missed, func(x, y models.ChangeEvent) int { return cmp.Compare(x.ID, y.ID) })
+	return missed, complete
+}
@@ -0,0 +1,53 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestBroadcaster_Firehose(t *testing.T) {
+	b := NewBroadcaster(RateLimit{}, 0)
+	listener, _, _ := b.SubscribeFirehose(SubscribeOptions{Types: []string{"insert"}})
+
+	broadcast := func(dbID string, eventType string, documentID string) {
+		b.Broadcast(dbID, models.ChangeEvent{EventType: eventType, DatabaseID: dbID, Collection: "tasks", DocumentID: documentID, Timestamp: time.Now()})
+	}
+	broadcast("db_a", "insert", "a1")
+	broadcast("db_b", "insert", "b1")
+	broadcast("db_b", "delete", "b1")
+	broadcast("db_a", "insert", "a2")
+
+	for _, want := range []string{"db_a a1", "db_b b1", "db_a a2"} {
+		if event := <-listener.Events; event.DatabaseID+" "+event.DocumentID != want {
+			t.Errorf("firehose got %s %s, want %s", event.DatabaseID, event.DocumentID, want)
+		}
+	}
+	if stats := b.Stats(); stats.FirehoseListeners != 1 {
+		t.Errorf("Stats().FirehoseListeners = %d, want 1", stats.FirehoseListeners)
+	}
+
+	// Replays merge every database's events in the order they were broadcast
+	resumed, missed, complete := b.SubscribeFirehose(SubscribeOptions{LastID: b.firstID + 1})
+	defer b.UnsubscribeFirehose(resumed)
+	if !complete || len(missed) != 3 {
+		t.Fatalf("replayed %d events, complete %v, want 3 complete", len(missed), complete)
+	}
+	for i, want := range []string{"db_b b1", "db_b b1", "db_a a2"} {
+		if event := missed[i]; event.DatabaseID+" "+event.DocumentID != want || (i > 0 && event.ID <= missed[i-1].ID) {
+			t.Errorf("replayed event %d = %s %s with ID %d, want %s in ID order", i, event.DatabaseID, event.DocumentID, event.ID, want)
+		}
+	}
+
+	b.UnsubscribeFirehose(listener)
+	select {
+	case <-listener.Done:
+	default:
+		t.Error("listener not closed after UnsubscribeFirehose")
+	}
+	broadcast("db_a", "insert", "a3")
+	if len(listener.Events) != 0 {
+		t.Error("unsubscribed firehose listener still got events")
+	}
+}
@@ -0,0 +1,100 @@
+package events
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Subscription types of a ListenerInfo
+const (
+	SubscriptionDatabase   = "database"
+	SubscriptionCollection = "collection"
+)
+
+// ListenerInfo describes an open stream of a database, for developers debugging why
+// their clients don't receive events
+type ListenerInfo struct {
+	ID           string     `json:"listener_id"`
+	Subscription string     `json:"subscription"`          // SubscriptionDatabase or SubscriptionCollection
+	Collection   string     `json:"collection,omitempty"`  // Of a collection subscription
+	Types        []string   `json:"events,omitempty"`      // Event types delivered; empty delivers all
+	Collections  []string   `json:"collections,omitempty"` // Collections a database subscription follows; empty follows all
+	Filtered     bool       `json:"filtered"`              // Documents are narrowed by field filters; read policies narrow all of a read key's streams
+	ConnectedAt  time.Time  `json:"connected_at"`
+	LastPing     time.Time  `json:"last_ping"`
+	LastAck      *time.Time `json:"last_ack,omitempty"` // For streams acknowledging they are alive
+	Queued       int        `json:"queued"`             // Events waiting in its buffer
+	Dropped      uint64     `json:"dropped"`            // Events missed by falling behind
+	Throttled    uint64     `json:"throttled"`          // Events skipped for being over its rate
+}
+
+// Listeners describes a database's open database and collection streams, oldest first
+func (b *Broadcaster) Listeners(dbID string) []ListenerInfo {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	infos := []ListenerInfo{}
+	for listener := range b.databaseListeners[dbID] {
+		infos = append(infos, listener.info(SubscriptionDatabase, ""))
+	}
+	for collection, listeners := range b.collectionListeners[dbID] {
+		for listener := range listeners {
+			infos = append(infos, listener.info(SubscriptionCollection, collection))
+		}
+	}
+	slices.SortFunc(infos, func(x, y ListenerInfo) int {
+		return cmp.Or(x.ConnectedAt.Compare(y.ConnectedAt), cmp.Compare(x.ID, y.ID))
+	})
+	return infos
+}
+
+// info describes the listener; the caller holds the broadcaster's lock
+func (l *Listener) info(subscription string, collection string) ListenerInfo {
+	info := ListenerInfo{
+		ID:           l.ID,
+		Subscription: subscription,
+		Collection:   collection,
+		Filtered:     l.match != nil,
+		ConnectedAt:  l.ConnectedAt,
+		LastPing:     l.LastPing,
+		Queued:       len(l.Events),
+		Dropped:      l.Dropped(),
+		Throttled:    l.Throttled(),
+	}
+	if l.types != nil {
+		info.Types = slices.Sorted(maps.Keys(l.types))
+	}
+	if l.collections != nil {
+		info.Collections = slices.Sorted(maps.Keys(l.collections))
+	}
+	if l.AckRequired {
+		lastAck := l.LastAck
+		info.LastAck = &lastAck
+	}
+	return info
+}
+
+// Disconnect closes a database's open database and collection streams, as when the keys
+// they authenticated with are revoked, and returns how many it closed
+// Clients holding a valid key can reconnect and resume
+func (b *Broadcaster) Disconnect(dbID string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	closed := 0
+	for listener := range b.databaseListeners[dbID] {
+		listener.close()
+		closed++
+	}
+	for _, listeners := range b.collectionListeners[dbID] {
+		for listener := range listeners {
+			listener.close()
+			closed++
+		}
+	}
+	delete(b.databaseListeners, dbID)
+	delete(b.collectionListeners, dbID)
+	return closed
+}
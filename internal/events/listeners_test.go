@@ -0,0 +1,72 @@
+package events
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBroadcaster_Listeners(t *testing.T) {
+	b := NewBroadcaster(RateLimit{}, 0)
+	if infos := b.Listeners("db_a"); infos == nil || len(infos) != 0 {
+		t.Errorf("Listeners() = %v with no streams, want an empty list", infos)
+	}
+
+	first, _, _ := b.SubscribeWith("db_a", SubscribeOptions{Types: []string{"update", "insert"}, Collections: []string{"users"}})
+	defer b.Unsubscribe("db_a", first)
+	second, _, _ := b.SubscribeCollectionWith("db_a", "posts", SubscribeOptions{Match: func(map[string]interface{}) bool { return true }})
+	defer b.UnsubscribeCollection("db_a", "posts", second)
+	b.RequireAck(second)
+	other := b.Subscribe("db_b")
+	defer b.Unsubscribe("db_b", other)
+
+	infos := b.Listeners("db_a")
+	if len(infos) != 2 {
+		t.Fatalf("Listeners() = %d listeners, want 2", len(infos))
+	}
+	if infos[0].ConnectedAt.After(infos[1].ConnectedAt) {
+		t.Error("Listeners() not ordered oldest first")
+	}
+	byID := map[string]ListenerInfo{infos[0].ID: infos[0], infos[1].ID: infos[1]}
+
+	info := byID[first.ID]
+	if info.Subscription != SubscriptionDatabase || info.Collection != "" || info.Filtered || info.LastAck != nil {
+		t.Errorf("database listener = %+v, want an unfiltered database subscription", info)
+	}
+	if !slices.Equal(info.Types, []string{"insert", "update"}) || !slices.Equal(info.Collections, []string{"users"}) {
+		t.Errorf("database listener types %v, collections %v, want [insert update] and [users]", info.Types, info.Collections)
+	}
+
+	info = byID[second.ID]
+	if info.Subscription != SubscriptionCollection || info.Collection != "posts" || !info.Filtered || info.LastAck == nil {
+		t.Errorf("collection listener = %+v, want a filtered, acknowledging subscription to posts", info)
+	}
+	if info.Types != nil || info.LastPing.IsZero() || info.ConnectedAt.IsZero() {
+		t.Errorf("collection listener = %+v, want every type and connection times", info)
+	}
+}
+
+func TestBroadcaster_Disconnect(t *testing.T) {
+	b := NewBroadcaster(RateLimit{}, 0)
+	database := b.Subscribe("db_a")
+	collection := b.SubscribeCollection("db_a", "posts")
+	other := b.Subscribe("db_b")
+	defer b.Unsubscribe("db_b", other)
+
+	if closed := b.Disconnect("db_a"); closed != 2 {
+		t.Errorf("Disconnect() = %d, want 2", closed)
+	}
+	for _, listener := range []*Listener{database, collection} {
+		select {
+		case <-listener.Done:
+		default:
+			t.Errorf("listener %s not closed after Disconnect", listener.ID)
+		}
+	}
+	if len(b.Listeners("db_a")) != 0 || len(b.Listeners("db_b")) != 1 {
+		t.Error("Disconnect() left db_a listeners or removed db_b's")
+	}
+
+	// Streams unsubscribe when they end, after being closed
+	b.Unsubscribe("db_a", database)
+	b.UnsubscribeCollection("db_a", "posts", collection)
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"jsondrop/internal/models"
+)
+
+// Streams can ask with ?patch= for update events to carry an RFC 6902 JSON Patch from
+// old_data to data, so clients holding the document can apply the change instead of
+// replacing it
+
+// PatchMode selects how update events carry their change
+type PatchMode string
+
+// Patch modes of the ?patch= subscription parameter
+const (
+	PatchNone    PatchMode = ""     // Full data and old_data only
+	PatchInclude PatchMode = "true" // A patch as well as the full data
+	PatchOnly    PatchMode = "only" // A patch instead of the full data
+)
+
+// ParsePatch parses the ?patch= subscription parameter; empty and "false" send no patches
+func ParsePatch(value string) (PatchMode, error) {
+	switch value {
+	case "", "false":
+		return PatchNone, nil
+	case string(PatchInclude), string(PatchOnly):
+		return PatchMode(value), nil
+	}
+	return PatchNone, fmt.Errorf("invalid patch parameter %q: expected true, only or false", value)
+}
+
+// Apply adds a patch to an update event that has the document from before the change,
+// dropping the full data in PatchOnly mode; other events are returned unchanged
+func (m PatchMode) Apply(event models.ChangeEvent) models.ChangeEvent {
+	if m == PatchNone || event.EventType != "update" || event.OldData == nil {
+		return event
+	}
+	event.Patch = Diff(event.OldData, event.Data)
+	if m == PatchOnly {
+		event.Data, event.OldData = nil, nil
+	}
+	return event
+}
+
+// Diff returns a JSON Patch turning from into to
+// Objects are compared member by member, in sorted order; any other changed value,
+// arrays included, is replaced whole
+func Diff(from, to map[string]interface{}) []models.PatchOperation {
+	ops := []models.PatchOperation{}
+	diffObjects(&ops, "", from, to)
+	return ops
+}
+
+// diffObjects appends the operations turning the object at path from one value to another
+func diffObjects(ops *[]models.PatchOperation, path string, from, to map[string]interface{}) {
+	keys := make([]string, 0, len(from)+len(to))
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		memberPath := path + "/" + escapePointer(key)
+		before, inFrom := from[key]
+		after, inTo := to[key]
+		switch {
+		case !inTo:
+			*ops = append(*ops, models.PatchOperation{Op: "remove", Path: memberPath})
+		case !inFrom:
+			*ops = append(*ops, patchValue("add", memberPath, after))
+		default:
+			beforeObject, ok1 := before.(map[string]interface{})
+			afterObject, ok2 := after.(map[string]interface{})
+			if ok1 && ok2 {
+				diffObjects(ops, memberPath, beforeObject, afterObject)
+			} else if !reflect.DeepEqual(before, after) {
+				*ops = append(*ops, patchValue("replace", memberPath, after))
+			}
+		}
+	}
+}
+
+// patchValue builds an operation carrying a value
+func patchValue(op string, path string, value interface{}) models.PatchOperation {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data = []byte("null")
+	}
+	return models.PatchOperation{Op: op, Path: path, Value: data}
+}
+
+// escapePointer escapes a member name for a JSON Pointer, as RFC 6901 requires
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
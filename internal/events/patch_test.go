@@ -0,0 +1,122 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+// applyPatch applies the object operations Diff produces, to check patches round-trip
+func applyPatch(t *testing.T, doc map[string]interface{}, ops []models.PatchOperation) map[string]interface{} {
+	t.Helper()
+	var copied map[string]interface{}
+	data, _ := json.Marshal(doc)
+	json.Unmarshal(data, &copied)
+
+	for _, op := range ops {
+		tokens := strings.Split(strings.TrimPrefix(op.Path, "/"), "/")
+		parent := copied
+		for _, token := range tokens[:len(tokens)-1] {
+			parent = parent[unescapePointer(token)].(map[string]interface{})
+		}
+		key := unescapePointer(tokens[len(tokens)-1])
+		switch op.Op {
+		case "remove":
+			delete(parent, key)
+		case "add", "replace":
+			var value interface{}
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				t.Fatalf("operation %+v value error = %v", op, err)
+			}
+			parent[key] = value
+		default:
+			t.Fatalf("unexpected operation %s", op.Op)
+		}
+	}
+	return copied
+}
+
+func unescapePointer(token string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+}
+
+func TestDiff(t *testing.T) {
+	from := map[string]interface{}{
+		"name":    "Alice",
+		"age":     30.0,
+		"tags":    []interface{}{"a", "b"},
+		"address": map[string]interface{}{"city": "Paris", "zip": "75001"},
+		"a/b~c":   true,
+		"gone":    "soon",
+	}
+	to := map[string]interface{}{
+		"name":    "Alice",
+		"age":     31.0,
+		"tags":    []interface{}{"a", "b", "c"},
+		"address": map[string]interface{}{"city": "Lyon", "zip": "75001", "country": "FR"},
+		"a/b~c":   nil,
+		"nick":    "Al",
+	}
+
+	ops := Diff(from, to)
+	got, _ := json.Marshal(ops)
+	want := `[{"op":"replace","path":"/a~1b~0c","value":null},` +
+		`{"op":"replace","path":"/address/city","value":"Lyon"},` +
+		`{"op":"add","path":"/address/country","value":"FR"},` +
+		`{"op":"replace","path":"/age","value":31},` +
+		`{"op":"remove","path":"/gone"},` +
+		`{"op":"add","path":"/nick","value":"Al"},` +
+		`{"op":"replace","path":"/tags","value":["a","b","c"]}]`
+	if string(got) != want {
+		t.Errorf("Diff() = %s\nwant %s", got, want)
+	}
+	if patched := applyPatch(t, from, ops); !reflect.DeepEqual(patched, to) {
+		t.Errorf("applying Diff() gives %v, want %v", patched, to)
+	}
+
+	if ops := Diff(to, to); len(ops) != 0 {
+		t.Errorf("Diff() of equal documents = %v, want no operations", ops)
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	for value, want := range map[string]PatchMode{"": PatchNone, "false": PatchNone, "true": PatchInclude, "only": PatchOnly} {
+		if got, err := ParsePatch(value); err != nil || got != want {
+			t.Errorf("ParsePatch(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParsePatch("yes"); err == nil {
+		t.Error("ParsePatch(yes) error = nil, want an error")
+	}
+}
+
+func TestPatchMode_Apply(t *testing.T) {
+	update := models.ChangeEvent{
+		EventType: "update",
+		Data:      map[string]interface{}{"title": "final"},
+		OldData:   map[string]interface{}{"title": "draft"},
+	}
+
+	if event := PatchNone.Apply(update); event.Patch != nil {
+		t.Errorf("PatchNone.Apply() patch = %v, want none", event.Patch)
+	}
+	if event := PatchInclude.Apply(update); len(event.Patch) != 1 || event.Data == nil || event.OldData == nil {
+		t.Errorf("PatchInclude.Apply() = %+v, want a patch and the full data", event)
+	}
+	if event := PatchOnly.Apply(update); len(event.Patch) != 1 || event.Data != nil || event.OldData != nil {
+		t.Errorf("PatchOnly.Apply() = %+v, want a patch alone", event)
+	}
+
+	// Other events, and updates without the previous document, keep their data
+	insert := models.ChangeEvent{EventType: "insert", Data: update.Data}
+	if event := PatchOnly.Apply(insert); event.Patch != nil || event.Data == nil {
+		t.Errorf("PatchOnly.Apply(insert) = %+v, want it unchanged", event)
+	}
+	bare := models.ChangeEvent{EventType: "update", Data: update.Data}
+	if event := PatchOnly.Apply(bare); event.Patch != nil || event.Data == nil {
+		t.Errorf("PatchOnly.Apply(update without old_data) = %+v, want it unchanged", event)
+	}
+}
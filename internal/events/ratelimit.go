@@ -0,0 +1,149 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// Bulk writes such as imports can change thousands of documents a second, far more than
+// listeners can use. Each database may deliver RateLimit.EventsPerSecond events per
+// one-second window; later events in the window are dropped or, when coalescing, counted
+// per collection and delivered as one bulk_change event when the window ends.
+//
+// A database's limit still lets one writer flood every stream following it. Each listener
+// may also be paced by a token bucket refilling RateLimit.ListenerEventsPerSecond tokens a
+// second; events finding it empty are skipped for that listener and counted, so its stream
+// can recommend a resync instead.
+
+// EventTypeBulkChange summarizes events coalesced by rate limiting
+const EventTypeBulkChange = "bulk_change"
+
+// RateLimit bounds the events delivered per database
+type RateLimit struct {
+	EventsPerSecond int  // Events delivered per database each second; 0 disables limiting
+	Coalesce        bool // Summarize events over the limit in bulk_change events instead of dropping them
+
+	ListenerEventsPerSecond int // Events delivered per listener each second; 0 disables pacing
+	ListenerBurst           int // Events a listener may take at once; 0 uses ListenerEventsPerSecond
+}
+
+// rateWindow counts a database's events in the current one-second window
+type rateWindow struct {
+	start  time.Time
+	sent   int
+	counts map[string]int // Collection -> events coalesced; nil when none are pending
+}
+
+// rateLimiter applies a RateLimit to every database
+type rateLimiter struct {
+	limit   RateLimit
+	mu      sync.Mutex
+	windows map[string]*rateWindow // dbID -> window
+}
+
+// newRateLimiter creates a limiter with no windows open
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{limit: limit, windows: make(map[string]*rateWindow)}
+}
+
+// admit reports whether an event may be delivered now
+// When an event is coalesced into a window that had none pending, it also returns the
+// time until the window ends, when flush must be called for the database
+func (r *rateLimiter) admit(dbID string, event models.ChangeEvent, now time.Time) (bool, time.Duration) {
+	if r.limit.EventsPerSecond <= 0 {
+		return true, 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	window := r.windows[dbID]
+	if window == nil || (now.Sub(window.start) >= time.Second && window.counts == nil) {
+		window = &rateWindow{start: now}
+		r.windows[dbID] = window
+	}
+	if window.counts == nil && window.sent < r.limit.EventsPerSecond {
+		window.sent++
+		return true, 0
+	}
+	if !r.limit.Coalesce {
+		return false, 0
+	}
+
+	// Once events are pending, later ones are coalesced too so they aren't delivered out of order
+	if window.counts == nil {
+		window.counts = make(map[string]int)
+		window.counts[event.Collection]++
+		return false, window.start.Add(time.Second).Sub(now)
+	}
+	window.counts[event.Collection]++
+	return false, 0
+}
+
+// flush ends a database's window, returning the events coalesced in it per collection
+func (r *rateLimiter) flush(dbID string) map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	window := r.windows[dbID]
+	if window == nil {
+		return nil
+	}
+	delete(r.windows, dbID)
+	return window.counts
+}
+
+// expire drops windows that ended with nothing pending
+func (r *rateLimiter) expire(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for dbID, window := range r.windows {
+		if window.counts == nil && now.Sub(window.start) >= time.Second {
+			delete(r.windows, dbID)
+		}
+	}
+}
+
+// tokenBucket paces one listener's events, letting bursts of up to burst through
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens added each second
+	burst  float64
+	tokens float64
+	last   time.Time // When tokens was last refilled
+}
+
+// newTokenBucket creates a full bucket, or returns nil when rate doesn't limit anything
+func newTokenBucket(rate, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+	return &tokenBucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// take reports whether an event may be delivered now, spending a token for it
+// A nil bucket lets everything through
+func (t *tokenBucket) take(now time.Time) bool {
+	if t == nil {
+		return true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elapsed := now.Sub(t.last).Seconds(); elapsed > 0 {
+		t.tokens = min(t.burst, t.tokens+elapsed*t.rate)
+		t.last = now
+	}
+	if t.tokens < 1 {
+		return false
+	}
+	t.tokens--
+	return true
+}
@@ -0,0 +1,161 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestRateLimiter_Admit(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	event := models.ChangeEvent{Collection: "posts"}
+
+	r := newRateLimiter(RateLimit{EventsPerSecond: 2})
+	for i, want := range []bool{true, true, false} {
+		if got, _ := r.admit("db_a", event, start); got != want {
+			t.Errorf("event %d: admit() = %v, want %v", i, got, want)
+		}
+	}
+	if got, _ := r.admit("db_b", event, start); !got {
+		t.Error("admit() = false for another database, want true")
+	}
+	if got, _ := r.admit("db_a", event, start.Add(time.Second)); !got {
+		t.Error("admit() = false in the next window, want true")
+	}
+
+	unlimited := newRateLimiter(RateLimit{})
+	for i := 0; i < 100; i++ {
+		if got, _ := unlimited.admit("db_a", event, start); !got {
+			t.Fatal("admit() = false with no limit, want true")
+		}
+	}
+}
+
+func TestRateLimiter_Coalesce(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	r := newRateLimiter(RateLimit{EventsPerSecond: 1, Coalesce: true})
+
+	r.admit("db_a", models.ChangeEvent{Collection: "posts"}, start)
+	_, flushIn := r.admit("db_a", models.ChangeEvent{Collection: "posts"}, start.Add(400*time.Millisecond))
+	if flushIn != 600*time.Millisecond {
+		t.Errorf("admit() flush in %v, want the 600ms left in the window", flushIn)
+	}
+	_, flushIn = r.admit("db_a", models.ChangeEvent{Collection: "users"}, start.Add(500*time.Millisecond))
+	if flushIn != 0 {
+		t.Errorf("admit() flush in %v with a flush already due, want 0", flushIn)
+	}
+
+	// Events arriving before the flush are coalesced even though the window has ended
+	if got, _ := r.admit("db_a", models.ChangeEvent{Collection: "posts"}, start.Add(time.Second)); got {
+		t.Error("admit() = true with coalesced events pending, want false")
+	}
+
+	counts := r.flush("db_a")
+	if len(counts) != 2 || counts["posts"] != 2 || counts["users"] != 1 {
+		t.Errorf("flush() = %v, want posts: 2, users: 1", counts)
+	}
+	if got, _ := r.admit("db_a", models.ChangeEvent{Collection: "posts"}, start.Add(time.Second)); !got {
+		t.Error("admit() = false after flush(), want true")
+	}
+}
+
+func TestBroadcaster_BulkChange(t *testing.T) {
+	b := NewBroadcaster(RateLimit{EventsPerSecond: 2, Coalesce: true}, 0)
+	dbListener := b.Subscribe("db_a")
+	usersListener := b.SubscribeCollection("db_a", "users")
+
+	for _, collection := range []string{"posts", "posts", "posts", "users", "posts"} {
+		b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", DatabaseID: "db_a", Collection: collection})
+	}
+
+	for i := 0; i < 2; i++ {
+		if event := <-dbListener.Events; event.EventType != "insert" {
+			t.Errorf("event %d: EventType = %s, want insert", i, event.EventType)
+		}
+	}
+
+	timeout := time.After(2 * time.Second)
+	select {
+	case event := <-dbListener.Events:
+		if event.EventType != EventTypeBulkChange || event.Counts["posts"] != 2 || event.Counts["users"] != 1 {
+			t.Errorf("database listener got %s with counts %v, want bulk_change with posts: 2, users: 1", event.EventType, event.Counts)
+		}
+	case <-timeout:
+		t.Fatal("database listener got no bulk_change event")
+	}
+	select {
+	case event := <-usersListener.Events:
+		if event.EventType != EventTypeBulkChange || event.Collection != "users" || len(event.Counts) != 1 || event.Counts["users"] != 1 {
+			t.Errorf("collection listener got %s for %q with counts %v, want bulk_change for users only", event.EventType, event.Collection, event.Counts)
+		}
+	case <-timeout:
+		t.Fatal("collection listener got no bulk_change event")
+	}
+}
+
+func TestTokenBucket_Take(t *testing.T) {
+	start := time.Now()
+	bucket := newTokenBucket(2, 3)
+	bucket.last = start
+
+	for i, want := range []bool{true, true, true, false} {
+		if got := bucket.take(start); got != want {
+			t.Errorf("take %d: got %v, want %v", i, got, want)
+		}
+	}
+	if !bucket.take(start.Add(500 * time.Millisecond)) {
+		t.Error("take() = false after a token refilled, want true")
+	}
+	if bucket.take(start.Add(500 * time.Millisecond)) {
+		t.Error("take() = true with the refilled token spent, want false")
+	}
+
+	// Refills stop at the burst
+	for i, want := range []bool{true, true, true, false} {
+		if got := bucket.take(start.Add(time.Hour)); got != want {
+			t.Errorf("take %d after an hour: got %v, want %v", i, got, want)
+		}
+	}
+
+	if newTokenBucket(0, 10) != nil {
+		t.Error("newTokenBucket(0, 10) != nil, want no pacing")
+	}
+	var unpaced *tokenBucket
+	if !unpaced.take(start) {
+		t.Error("take() = false on a nil bucket, want true")
+	}
+}
+
+func TestBroadcaster_Throttled(t *testing.T) {
+	b := NewBroadcaster(RateLimit{ListenerEventsPerSecond: 2}, 0)
+	listener := b.Subscribe("db_a")
+	defer b.Unsubscribe("db_a", listener)
+
+	for range 5 {
+		b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", DatabaseID: "db_a", Collection: "posts"})
+	}
+	// Summaries of many events aren't paced
+	b.Broadcast("db_a", models.ChangeEvent{EventType: EventTypeBulkChange, DatabaseID: "db_a", Counts: map[string]int{"posts": 10}})
+
+	if len(listener.Events) != 3 {
+		t.Errorf("listener queued %d events, want 2 inserts and the bulk_change", len(listener.Events))
+	}
+	if got := listener.TakeThrottled(); got != 3 {
+		t.Errorf("TakeThrottled() = %d, want 3", got)
+	}
+	if got := listener.TakeThrottled(); got != 0 {
+		t.Errorf("TakeThrottled() again = %d, want 0", got)
+	}
+	if stats := b.Stats(); stats.EventsThrottled != 3 || stats.Databases[0].Throttled != 3 {
+		t.Errorf("Stats() throttled %d, database %d, want 3", stats.EventsThrottled, stats.Databases[0].Throttled)
+	}
+
+	// Each listener has its own bucket
+	other := b.Subscribe("db_a")
+	defer b.Unsubscribe("db_a", other)
+	b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", DatabaseID: "db_a", Collection: "posts"})
+	if len(other.Events) != 1 || listener.Throttled() != 4 {
+		t.Errorf("new listener queued %d events, throttled listener %d total, want 1 and 4", len(other.Events), listener.Throttled())
+	}
+}
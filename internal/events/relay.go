@@ -0,0 +1,256 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Events wait in a queue of up to relayQueue while Redis is unreachable, later ones
+// being dropped, and are published up to relayBatch at a time. Failed connections are
+// retried after relayFirstBackoff, doubling up to relayMaxBackoff
+const (
+	relayQueue        = 10000
+	relayBatch        = 500
+	relayFirstBackoff = time.Second
+	relayMaxBackoff   = 30 * time.Second
+	relayDialTimeout  = 10 * time.Second
+	relayIOTimeout    = 10 * time.Second
+)
+
+// relayMessage is what instances publish: an event and the instance it happened on
+type relayMessage struct {
+	Origin string             `json:"origin"`
+	Event  models.ChangeEvent `json:"event"`
+}
+
+// Relay carries change events between server instances over Redis pub/sub, so the
+// listeners of every instance see changes made on any of them
+// Each instance publishes the events it broadcasts on the channel and subscribes to it,
+// skipping its own. Pub/sub doesn't keep messages, so events published while an
+// instance's subscription is down never reach its listeners
+type Relay struct {
+	cfg         config.EventRelayConfig
+	origin      string
+	broadcaster *Broadcaster
+	client      *redis.Client
+	pubsub      *redis.PubSub
+	queue       chan models.ChangeEvent
+	dropped     atomic.Uint64 // Events dropped from a full queue since last logged
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+	wg          sync.WaitGroup
+}
+
+// NewRelay connects a broadcaster to the other instances sharing a Redis channel: it
+// observes the broadcaster's events to publish them, and broadcasts theirs
+// Redis is first contacted in the background, so an unreachable server doesn't hold up startup
+func NewRelay(b *Broadcaster, cfg config.EventRelayConfig) (*Relay, error) {
+	tlsConfig, err := relayTLS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	origin := make([]byte, 8)
+	rand.Read(origin)
+	client := redis.NewClient(&redis.Options{
+		Addr:         cfg.Addr,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		TLSConfig:    tlsConfig,
+		DialTimeout:  relayDialTimeout,
+		ReadTimeout:  relayIOTimeout,
+		WriteTimeout: relayIOTimeout,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Relay{
+		cfg:         cfg,
+		origin:      hex.EncodeToString(origin),
+		broadcaster: b,
+		client:      client,
+		pubsub:      client.Subscribe(ctx), // Without channels, this doesn't connect yet
+		queue:       make(chan models.ChangeEvent, relayQueue),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	r.wg.Add(2)
+	go r.publish()
+	go r.subscribe()
+	b.Observe(r.observe)
+	return r, nil
+}
+
+// relayTLS returns the TLS configuration to connect to Redis with, or nil without TLS
+func relayTLS(cfg config.EventRelayConfig) (*tls.Config, error) {
+	if !cfg.TLS {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read EVENT_RELAY_TLS_CA_FILE: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid EVENT_RELAY_TLS_CA_FILE: no PEM certificates in %s", cfg.CAFile)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// observe queues an event for publishing, dropping it when the queue is full
+func (r *Relay) observe(event models.ChangeEvent) {
+	select {
+	case r.queue <- event:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Close stops relaying and drops the connections; queued events are discarded
+func (r *Relay) Close() {
+	r.closeOnce.Do(func() {
+		r.cancel()
+		r.pubsub.Close()
+		r.client.Close()
+	})
+	r.wg.Wait()
+}
+
+// publish sends queued events with PUBLISH until the relay is closed, pipelining each
+// batch and retrying failed ones
+func (r *Relay) publish() {
+	defer r.wg.Done()
+
+	var backoff time.Duration
+	var batch []models.ChangeEvent
+	for {
+		if len(batch) == 0 {
+			select {
+			case event := <-r.queue:
+				batch = append(batch, event)
+			case <-r.ctx.Done():
+				return
+			}
+		fill:
+			for len(batch) < relayBatch {
+				select {
+				case event := <-r.queue:
+					batch = append(batch, event)
+				default:
+					break fill
+				}
+			}
+			if n := r.dropped.Swap(0); n > 0 {
+				log.Printf("relay: queue full, dropped %d events", n)
+			}
+		}
+
+		err := r.publishBatch(batch)
+		if err == nil {
+			batch, backoff = batch[:0], 0
+			continue
+		}
+		if r.ctx.Err() != nil {
+			return
+		}
+		backoff = min(max(backoff*2, relayFirstBackoff), relayMaxBackoff)
+		log.Printf("relay: failed to publish %d events, retrying in %v: %v", len(batch), backoff, err)
+		if !r.sleep(backoff) {
+			return
+		}
+	}
+}
+
+// publishBatch publishes events in one pipeline
+func (r *Relay) publishBatch(batch []models.ChangeEvent) error {
+	pipe := r.client.Pipeline()
+	for _, event := range batch {
+		payload, err := json.Marshal(relayMessage{Origin: r.origin, Event: event})
+		if err != nil {
+			log.Printf("relay: skipped %s event of %s: %v", event.EventType, event.DatabaseID, err)
+			continue
+		}
+		pipe.Publish(r.ctx, r.cfg.Channel, payload)
+	}
+	if pipe.Len() == 0 {
+		return nil
+	}
+	_, err := pipe.Exec(r.ctx)
+	return err
+}
+
+// subscribe broadcasts the events other instances publish until the relay is closed
+// The pub/sub connection resubscribes by itself after reconnecting, so failures only
+// need a pause before receiving again
+func (r *Relay) subscribe() {
+	defer r.wg.Done()
+
+	var backoff time.Duration
+	// The channel is kept for resubscribing even when this first attempt fails
+	err := r.pubsub.Subscribe(r.ctx, r.cfg.Channel)
+	for {
+		var reply interface{}
+		if err == nil {
+			// Subscriptions stay quiet while nothing changes; TCP keepalives find dead connections
+			reply, err = r.pubsub.Receive(r.ctx)
+		}
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return
+			}
+			backoff = min(max(backoff*2, relayFirstBackoff), relayMaxBackoff)
+			log.Printf("relay: failed to subscribe to %s, retrying in %v: %v", r.cfg.Channel, backoff, err)
+			if !r.sleep(backoff) {
+				return
+			}
+			err = nil
+			continue
+		}
+
+		switch reply := reply.(type) {
+		case *redis.Subscription:
+			backoff = 0
+		case *redis.Message:
+			r.receive(reply.Payload)
+		}
+	}
+}
+
+// receive broadcasts an event published by another instance
+func (r *Relay) receive(payload string) {
+	var msg relayMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		log.Printf("relay: skipped malformed message: %v", err)
+		return
+	}
+	if msg.Origin == r.origin {
+		return // Already broadcast here
+	}
+	r.broadcaster.BroadcastRelayed(msg.Event.DatabaseID, msg.Event)
+}
+
+// sleep waits for d, returning false if the relay is closed first
+func (r *Relay) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-r.ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,186 @@
+package events
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// relayInstance is a broadcaster relaying through Redis, with a listener and an observer
+type relayInstance struct {
+	broadcaster *Broadcaster
+	listener    *Listener
+	observed    chan models.ChangeEvent
+}
+
+// newRelayInstances starts n instances relaying on the same channel
+func newRelayInstances(t *testing.T, cfg config.EventRelayConfig, n int) []relayInstance {
+	t.Helper()
+	instances := make([]relayInstance, n)
+	for i := range instances {
+		b := NewBroadcaster(RateLimit{}, 10)
+		observed := make(chan models.ChangeEvent, 10)
+		b.Observe(func(event models.ChangeEvent) {
+			select {
+			case observed <- event:
+			default:
+			}
+		})
+		relay, err := NewRelay(b, cfg)
+		if err != nil {
+			t.Fatalf("NewRelay() error = %v", err)
+		}
+		t.Cleanup(relay.Close)
+		instances[i] = relayInstance{broadcaster: b, listener: b.Subscribe("db_relay"), observed: observed}
+	}
+	return instances
+}
+
+// awaitRelayed broadcasts on one instance until the other hears, returning what it heard
+// Subscriptions come up in the background, so early events may be missed
+func awaitRelayed(t *testing.T, from, to relayInstance) models.ChangeEvent {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		from.broadcaster.Broadcast("db_relay", models.ChangeEvent{EventType: "insert", DatabaseID: "db_relay", Collection: "tasks", DocumentID: "doc_1", Seq: 7})
+		select {
+		case received := <-to.listener.Events:
+			return received
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("event never relayed to the other instance")
+		}
+	}
+}
+
+func TestRelay(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireUserAuth("relay", "s3cret")
+	instances := newRelayInstances(t, config.EventRelayConfig{Addr: server.Addr(), Username: "relay", Password: "s3cret", Channel: "test-events"}, 2)
+
+	received := awaitRelayed(t, instances[0], instances[1])
+	if received.DocumentID != "doc_1" || received.Seq != 7 || received.ID == 0 {
+		t.Errorf("relayed event = %+v, want doc_1 with seq 7 and a local ID", received)
+	}
+
+	// The receiving instance's observers already ran on the sending instance
+	select {
+	case event := <-instances[1].observed:
+		t.Errorf("observer saw relayed event %+v", event)
+	default:
+	}
+	if stats := instances[1].broadcaster.Stats(); stats.EventsRelayed == 0 {
+		t.Errorf("Stats().EventsRelayed = 0, want the relayed events counted")
+	}
+
+	// The sending instance doesn't get its own events back
+	time.Sleep(100 * time.Millisecond)
+	for range len(instances[0].listener.Events) {
+		<-instances[0].listener.Events
+	}
+	instances[0].broadcaster.Broadcast("db_relay", models.ChangeEvent{EventType: "delete", DatabaseID: "db_relay", Collection: "tasks", DocumentID: "doc_1"})
+	<-instances[0].listener.Events
+	select {
+	case <-instances[1].listener.Events:
+	case <-time.After(5 * time.Second):
+	}
+	select {
+	case event := <-instances[0].listener.Events:
+		t.Errorf("sending instance got its own event back: %+v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestRelay_Reconnects(t *testing.T) {
+	server := miniredis.RunT(t)
+	instances := newRelayInstances(t, config.EventRelayConfig{Addr: server.Addr(), Channel: "test-events"}, 2)
+	awaitRelayed(t, instances[0], instances[1])
+
+	// A restarted server has forgotten the subscriptions, which are made again
+	addr := server.Addr()
+	server.Close()
+	if err := server.StartAddr(addr); err != nil {
+		t.Fatalf("StartAddr() error = %v", err)
+	}
+	awaitRelayed(t, instances[0], instances[1])
+}
+
+func TestRelay_TLS(t *testing.T) {
+	cert, caFile := selfSignedCert(t)
+	server, err := miniredis.RunTLS(&tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("RunTLS() error = %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	instances := newRelayInstances(t, config.EventRelayConfig{Addr: server.Addr(), Channel: "test-events", TLS: true, CAFile: caFile}, 2)
+	awaitRelayed(t, instances[0], instances[1])
+}
+
+func TestRelay_WrongPassword(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("s3cret")
+	relay, err := NewRelay(NewBroadcaster(RateLimit{}, 10), config.EventRelayConfig{Addr: server.Addr(), Password: "wrong", Channel: "test-events"})
+	if err != nil {
+		t.Fatalf("NewRelay() error = %v", err)
+	}
+	defer relay.Close()
+	if err := relay.publishBatch([]models.ChangeEvent{{EventType: "insert", DatabaseID: "db_relay"}}); err == nil {
+		t.Error("publishBatch() with the wrong password error = nil, want an error")
+	}
+}
+
+func TestNewRelay_InvalidCAFile(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := NewRelay(NewBroadcaster(RateLimit{}, 10), config.EventRelayConfig{Addr: "127.0.0.1:6379", TLS: true, CAFile: caFile}); err == nil {
+		t.Error("NewRelay() error = nil, want an error for a CA file without certificates")
+	}
+}
+
+// selfSignedCert creates a certificate for 127.0.0.1 and writes it to a PEM file to
+// verify it with
+func selfSignedCert(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "jsondrop test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
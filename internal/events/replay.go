@@ -0,0 +1,102 @@
+package events
+
+import (
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// Recent events are kept per database so clients reconnecting with Last-Event-ID get the
+// events they missed. A database keeps at most replayEvents, none older than replayWindow
+const (
+	replayEvents = 1000
+	replayWindow = 5 * time.Minute
+)
+
+// replayLog is a database's recent events, oldest first
+type replayLog struct {
+	events  []models.ChangeEvent
+	trimmed uint64 // ID of the newest event dropped from the log
+}
+
+// add appends an event, dropping the oldest beyond replayEvents
+func (l *replayLog) add(event models.ChangeEvent) {
+	if len(l.events) >= replayEvents {
+		l.trimmed = l.events[0].ID
+		l.events = l.events[1:]
+	}
+	l.events = append(l.events, event)
+}
+
+// expire drops events broadcast before cutoff
+func (l *replayLog) expire(cutoff time.Time) {
+	n := 0
+	for n < len(l.events) && l.events[n].Timestamp.Before(cutoff) {
+		n++
+	}
+	if n > 0 {
+		l.trimmed = l.events[n-1].ID
+		l.events = append([]models.ChangeEvent(nil), l.events[n:]...)
+	}
+}
+
+// record assigns an event the next ID and logs it for replay
+// The caller holds the write lock, so IDs are handed out in the order listeners get events
+func (b *Broadcaster) record(dbID string, event models.ChangeEvent) models.ChangeEvent {
+	b.lastID++
+	event.ID = b.lastID
+	log := b.replays[dbID]
+	if log == nil {
+		log = &replayLog{}
+		b.replays[dbID] = log
+	}
+	log.add(event)
+	return event
+}
+
+// replay returns the events after lastID a listener wants, of a database or only of a
+// collection if collection isn't empty, and whether they are all the events since lastID
+// Events from before the server started, or dropped from the log, can't be replayed
+// The caller holds the lock
+func (b *Broadcaster) replay(listener *Listener, dbID string, collection string, lastID uint64) ([]models.ChangeEvent, bool) {
+	complete := lastID >= b.firstID && lastID <= b.lastID
+	log := b.replays[dbID]
+	if log == nil {
+		return nil, complete
+	}
+	if lastID < log.trimmed {
+		complete = false
+	}
+
+	var missed []models.ChangeEvent
+	for _, event := range log.events {
+		if event.ID <= lastID || !listener.wants(event) {
+			continue
+		}
+		if collection == "" {
+			missed = append(missed, listener.narrow(event))
+			continue
+		}
+		switch {
+		case event.EventType == EventTypeBulkChange && event.Counts[collection] > 0:
+			// Collection listeners get their own collection's count, as when broadcast
+			event.Collection = collection
+			event.Counts = map[string]int{collection: event.Counts[collection]}
+			missed = append(missed, event)
+		case event.Collection == collection:
+			missed = append(missed, event)
+		}
+	}
+	return missed, complete
+}
+
+// expireReplays drops events older than replayWindow from every database's log
+// Logs are kept when empty, so a resume from before the dropped events is still detected
+func (b *Broadcaster) expireReplays(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, log := range b.replays {
+		log.expire(now.Add(-replayWindow))
+	}
+}
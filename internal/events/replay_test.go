@@ -0,0 +1,67 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+func TestBroadcaster_Resume(t *testing.T) {
+	b := NewBroadcaster(RateLimit{}, 0)
+	listener := b.Subscribe("db_a")
+	defer b.Unsubscribe("db_a", listener)
+
+	for _, collection := range []string{"posts", "users", "posts"} {
+		b.Broadcast("db_a", models.ChangeEvent{EventType: "insert", Collection: collection, Timestamp: time.Now()})
+	}
+	b.Broadcast("db_b", models.ChangeEvent{EventType: "insert", Collection: "posts", Timestamp: time.Now()})
+
+	var ids []uint64
+	for range 3 {
+		event := <-listener.Events
+		if len(ids) > 0 && event.ID <= ids[len(ids)-1] {
+			t.Fatalf("event IDs %v then %d, want increasing", ids, event.ID)
+		}
+		ids = append(ids, event.ID)
+	}
+
+	// Resuming after the first event replays the rest, and later events arrive live
+	resumed, missed, complete := b.SubscribeWith("db_a", SubscribeOptions{LastID: ids[0]})
+	defer b.Unsubscribe("db_a", resumed)
+	if !complete || len(missed) != 2 || missed[0].ID != ids[1] || missed[1].ID != ids[2] {
+		t.Errorf("SubscribeWith() = %v, %t, want events %v", missed, complete, ids[1:])
+	}
+	b.Broadcast("db_a", models.ChangeEvent{EventType: "update", Collection: "posts", Timestamp: time.Now()})
+	if event := <-resumed.Events; event.EventType != "update" {
+		t.Errorf("live event = %s, want the update", event.EventType)
+	}
+
+	// Collection listeners only replay their collection
+	posts, missed, complete := b.SubscribeCollectionWith("db_a", "posts", SubscribeOptions{LastID: ids[0]})
+	defer b.UnsubscribeCollection("db_a", "posts", posts)
+	if !complete || len(missed) != 2 || missed[0].ID != ids[2] || missed[1].EventType != "update" {
+		t.Errorf("SubscribeCollectionWith() = %v, %t, want the third insert and the update", missed, complete)
+	}
+
+	// IDs from before the server started can't be resumed
+	if _, missed, complete := b.SubscribeWith("db_a", SubscribeOptions{LastID: b.firstID - 1}); complete || len(missed) != 4 {
+		t.Errorf("SubscribeWith(previous run) = %d events, %t, want 4 and incomplete", len(missed), complete)
+	}
+}
+
+func TestReplayLog_Trim(t *testing.T) {
+	log := &replayLog{}
+	start := time.Now()
+	for i := 1; i <= replayEvents+2; i++ {
+		log.add(models.ChangeEvent{ID: uint64(i), Timestamp: start.Add(time.Duration(i) * time.Second)})
+	}
+	if len(log.events) != replayEvents || log.trimmed != 2 {
+		t.Errorf("log has %d events, trimmed through %d, want %d and 2", len(log.events), log.trimmed, replayEvents)
+	}
+
+	log.expire(start.Add(10*time.Second + time.Millisecond))
+	if log.events[0].ID != 11 || log.trimmed != 10 {
+		t.Errorf("after expiry log starts at %d, trimmed through %d, want 11 and 10", log.events[0].ID, log.trimmed)
+	}
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// Writer writes SSE frames to a single connection
+// Frames are serialized by a mutex and buffered so each one reaches the client
+// whole, even when events, heartbeats and backfill write from different goroutines
+type Writer struct {
+	mu         sync.Mutex
+	buf        *bufio.Writer
+	controller *http.ResponseController
+	timeout    time.Duration
+	err        error // First write error; the connection is unusable after it
+}
+
+// NewWriter creates a Writer for w
+// Each frame must be written within timeout, so a stalled client can't block the stream forever
+func NewWriter(w http.ResponseWriter, timeout time.Duration) *Writer {
+	return &Writer{
+		buf:        bufio.NewWriter(w),
+		controller: http.NewResponseController(w),
+		timeout:    timeout,
+	}
+}
+
+// WriteFrame writes a complete SSE frame and flushes it to the client
+func (sw *Writer) WriteFrame(frame string) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if sw.err != nil {
+		return sw.err
+	}
+
+	if sw.timeout > 0 {
+		if err := sw.controller.SetWriteDeadline(time.Now().Add(sw.timeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			sw.err = fmt.Errorf("failed to set write deadline: %w", err)
+			return sw.err
+		}
+	}
+
+	if _, err := sw.buf.WriteString(frame); err != nil {
+		sw.err = err
+		return err
+	}
+	if err := sw.buf.Flush(); err != nil {
+		sw.err = err
+		return err
+	}
+	if err := sw.controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		sw.err = err
+		return err
+	}
+
+	// Clear the deadline so an idle stream isn't closed between frames
+	if sw.timeout > 0 {
+		if err := sw.controller.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			sw.err = fmt.Errorf("failed to clear write deadline: %w", err)
+			return sw.err
+		}
+	}
+
+	return nil
+}
+
+// WriteEvent writes a change event frame in an event format version
+func (sw *Writer) WriteEvent(event models.ChangeEvent, version int) error {
+	return sw.WriteFrame(FormatSSE(event, version))
+}
+
+// WriteBatch writes events as one batch frame in an event format version
+func (sw *Writer) WriteBatch(batch []models.ChangeEvent, version int) error {
+	return sw.WriteFrame(FormatSSEBatch(batch, version))
+}
+
+// WriteNotice writes a frame of a named event other than a change, such as dropped
+func (sw *Writer) WriteNotice(event string, data []byte) error {
+	return sw.WriteFrame(fmt.Sprintf("event: %s\ndata: %s\n\n", event, data))
+}
+
+// WritePing writes a heartbeat comment frame
+func (sw *Writer) WritePing() error {
+	return sw.WriteFrame(FormatPing())
+}
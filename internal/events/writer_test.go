@@ -0,0 +1,80 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriter_ConcurrentFramesStayWhole(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := NewWriter(rec, time.Second)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				frame := fmt.Sprintf("event: change\ndata: {\"g\":%d,\"i\":%d,\"pad\":\"%s\"}\n\n", g, i, strings.Repeat("x", 4096))
+				if err := sw.WriteFrame(frame); err != nil {
+					t.Errorf("WriteFrame() error = %v", err)
+					return
+				}
+				if i%10 == 0 {
+					sw.WritePing()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	frames := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n\n"), "\n\n")
+	events := 0
+	for _, frame := range frames {
+		switch {
+		case frame == ": ping":
+		case strings.HasPrefix(frame, "event: change\ndata: {") && strings.HasSuffix(frame, "\"}") && strings.Count(frame, "\n") == 1:
+			events++
+		default:
+			t.Fatalf("corrupted frame: %.80q", frame)
+		}
+	}
+	if events != 8*50 {
+		t.Errorf("got %d event frames, want %d", events, 8*50)
+	}
+	if !rec.Flushed {
+		t.Error("WriteFrame() did not flush the response")
+	}
+}
+
+// failingWriter rejects every write
+type failingWriter struct {
+	http.ResponseWriter
+	writes int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	f.writes++
+	return 0, errors.New("broken pipe")
+}
+
+func TestWriter_ErrorIsSticky(t *testing.T) {
+	fw := &failingWriter{ResponseWriter: httptest.NewRecorder()}
+	sw := NewWriter(fw, time.Second)
+
+	if err := sw.WritePing(); err == nil {
+		t.Fatal("WritePing() error = nil, want write error")
+	}
+	if err := sw.WritePing(); err == nil {
+		t.Fatal("second WritePing() error = nil, want the first error")
+	}
+	if fw.writes != 1 {
+		t.Errorf("underlying writes = %d, want 1 after a failed write", fw.writes)
+	}
+}
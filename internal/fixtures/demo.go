@@ -0,0 +1,68 @@
+package fixtures
+
+import (
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+// DemoDatabaseID is the database DEV_MODE creates, so frontends can hard-code it
+const DemoDatabaseID = "db_demo"
+
+// demoSchemas are the example collections of the demo database
+var demoSchemas = map[string]Schema{
+	"todos": {Fields: map[string]models.FieldType{
+		"title":    models.FieldTypeString,
+		"done":     models.FieldTypeBool,
+		"priority": models.FieldTypeNumber,
+	}},
+	"notes": {Fields: map[string]models.FieldType{
+		"title": models.FieldTypeString,
+		"body":  models.FieldTypeString,
+	}},
+}
+
+// demoDocuments are the example documents of the demo database
+var demoDocuments = map[string][]Document{
+	"todos": {
+		{Data: map[string]interface{}{"title": "Try the API", "done": true, "priority": 1}},
+		{Data: map[string]interface{}{"title": "Build a frontend", "done": false, "priority": 2}},
+	},
+	"notes": {
+		{Data: map[string]interface{}{"title": "Welcome", "body": "This database was created by DEV_MODE."}, Visibility: models.VisibilityPublic},
+	},
+}
+
+// LoadDemo returns the demo database, creating it with example schemas and documents
+// and keys drawn from keys when it doesn't exist yet. It is kept across restarts, so
+// its keys stay the same and data written during development survives
+// Returns whether the database was created
+func LoadDemo(catalog *database.CatalogDB, keys database.KeyGenerator) (*models.Database, bool, error) {
+	existing, err := catalog.GetDatabaseByID(DemoDatabaseID)
+	if err != nil || existing != nil {
+		return existing, false, err
+	}
+
+	writeKey, err := keys.WriteKey()
+	if err != nil {
+		return nil, false, err
+	}
+	readKey, err := keys.ReadKey()
+	if err != nil {
+		return nil, false, err
+	}
+	demo := Database{
+		ID:        DemoDatabaseID,
+		WriteKey:  writeKey,
+		ReadKey:   readKey,
+		Schemas:   demoSchemas,
+		Documents: demoDocuments,
+	}
+	if err := loadDatabase(catalog, demo, &Summary{}); err != nil {
+		// Leave no half-seeded database to be found on the next start
+		catalog.DeleteDatabase(DemoDatabaseID)
+		return nil, false, err
+	}
+
+	db, err := catalog.GetDatabaseByID(DemoDatabaseID)
+	return db, true, err
+}
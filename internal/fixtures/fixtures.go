@@ -0,0 +1,175 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+	"jsondrop/internal/policy"
+)
+
+// File is the top-level structure of a fixture file
+type File struct {
+	Databases []Database `json:"databases"`
+}
+
+// Database describes a database to create, with fixed ID and keys
+type Database struct {
+	ID        string                `json:"id"`
+	WriteKey  string                `json:"write_key"`
+	ReadKey   string                `json:"read_key"`
+	QuotaMB   int64                 `json:"quota_mb,omitempty"` // Defaults to the server default
+	Schemas   map[string]Schema     `json:"schemas"`
+	Documents map[string][]Document `json:"documents"` // Collection name -> documents
+}
+
+// Schema describes a collection schema
+type Schema struct {
+	Fields        map[string]models.FieldType `json:"fields"`
+	ReadFilter    string                      `json:"read_filter,omitempty"`
+	RetentionDays int                         `json:"retention_days,omitempty"`
+}
+
+// Document describes a document to insert
+type Document struct {
+	ID         string                 `json:"id,omitempty"` // Generated if empty
+	Data       map[string]interface{} `json:"data"`
+	Visibility models.Visibility      `json:"visibility,omitempty"`
+}
+
+// Summary reports what a load created
+type Summary struct {
+	Files     int `json:"files"`
+	Databases int `json:"databases"`
+	Schemas   int `json:"schemas"`
+	Documents int `json:"documents"`
+}
+
+// LoadDir loads every *.json fixture file in dir, in filename order
+// Databases named in fixtures are deleted and recreated, so each load
+// produces the same state regardless of what happened since the last one
+func LoadDir(catalog *database.CatalogDB, dir string) (*Summary, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	sort.Strings(paths)
+
+	summary := &Summary{}
+	for _, path := range paths {
+		if err := loadFile(catalog, path, summary); err != nil {
+			return summary, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		summary.Files++
+	}
+
+	return summary, nil
+}
+
+// loadFile parses and applies a single fixture file
+func loadFile(catalog *database.CatalogDB, path string, summary *Summary) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read fixture: %w", err)
+	}
+	defer f.Close()
+
+	var file File
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return fmt.Errorf("failed to parse fixture: %w", err)
+	}
+
+	for _, db := range file.Databases {
+		if err := loadDatabase(catalog, db, summary); err != nil {
+			return fmt.Errorf("database %s: %w", db.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// loadDatabase recreates a database and fills it with the fixture's schemas and documents
+func loadDatabase(catalog *database.CatalogDB, fixture Database, summary *Summary) error {
+	existing, err := catalog.GetDatabaseByID(fixture.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if err := catalog.DeleteDatabase(existing.ID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := catalog.CreateDatabaseWithKeys(fixture.ID, fixture.WriteKey, fixture.ReadKey, fixture.QuotaMB*1024*1024); err != nil {
+		return err
+	}
+	summary.Databases++
+
+	// Create schemas in name order so generated events are reproducible
+	names := make([]string, 0, len(fixture.Schemas))
+	for name := range fixture.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	schemas := make(map[string]*models.Schema)
+	for _, name := range names {
+		def := fixture.Schemas[name]
+		schema, err := catalog.CreateSchema(fixture.ID, name, def.Fields, nil)
+		if err != nil {
+			return fmt.Errorf("schema %s: %w", name, err)
+		}
+		if def.ReadFilter != "" {
+			if _, err := policy.Parse(def.ReadFilter); err != nil {
+				return fmt.Errorf("schema %s: invalid read filter: %w", name, err)
+			}
+			if schema, err = catalog.SetReadFilter(fixture.ID, name, def.ReadFilter); err != nil {
+				return fmt.Errorf("schema %s: %w", name, err)
+			}
+		}
+		if def.RetentionDays != 0 {
+			if schema, err = catalog.SetRetention(fixture.ID, name, def.RetentionDays); err != nil {
+				return fmt.Errorf("schema %s: %w", name, err)
+			}
+		}
+		schemas[name] = schema
+		summary.Schemas++
+	}
+
+	collections := make([]string, 0, len(fixture.Documents))
+	for collection := range fixture.Documents {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+
+	for _, collection := range collections {
+		schema, exists := schemas[collection]
+		if !exists {
+			return fmt.Errorf("documents for undefined collection %s", collection)
+		}
+
+		for i, doc := range fixture.Documents[collection] {
+			if err := models.ValidateDocument(doc.Data, schema); err != nil {
+				return fmt.Errorf("%s[%d]: %w", collection, i, err)
+			}
+
+			if doc.ID == "" {
+				_, err = catalog.InsertDocument(fixture.ID, collection, doc.Data, doc.Visibility)
+			} else {
+				_, err = catalog.InsertDocumentWithID(fixture.ID, collection, doc.ID, doc.Data, doc.Visibility)
+			}
+			if err != nil {
+				return fmt.Errorf("%s[%d]: %w", collection, i, err)
+			}
+			summary.Documents++
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"jsondrop/internal/database"
+)
+
+// testFixture mixes documents with fixed and generated IDs, and schema settings
+const testFixture = `{"databases": [{
+	"id": "db_fixture",
+	"write_key": "wk_fixture",
+	"read_key": "rk_fixture",
+	"schemas": {
+		"tasks": {"fields": {"title": "string", "done": "bool"}, "read_filter": "data.done == false", "retention_days": 30},
+		"notes": {"fields": {"body": "string"}}
+	},
+	"documents": {
+		"tasks": [
+			{"id": "task_1", "data": {"title": "Write docs", "done": false}},
+			{"id": "task_2", "data": {"title": "Ship", "done": true}, "visibility": "public"}
+		],
+		"notes": [
+			{"data": {"body": "first"}},
+			{"data": {"body": "second"}}
+		]
+	}
+}]}`
+
+// writeFixtures writes the test fixture to a directory for LoadDir
+func writeFixtures(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fixture.json"), []byte(testFixture), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return dir
+}
+
+// contents describes a database's schemas and documents, leaving out generated IDs
+// and timestamps, so loads can be compared
+func contents(t *testing.T, c *database.CatalogDB, dbID string) []string {
+	t.Helper()
+	schemas, err := c.ListSchemas(dbID)
+	if err != nil {
+		t.Fatalf("ListSchemas() error = %v", err)
+	}
+	var lines []string
+	for _, schema := range schemas {
+		fields, _ := json.Marshal(schema.Fields)
+		lines = append(lines, fmt.Sprintf("%s %s %q %d", schema.Name, fields, schema.ReadFilter, schema.RetentionDays))
+		docs, err := c.QueryDocuments(dbID, schema.Name, 0, 0, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("QueryDocuments(%s) error = %v", schema.Name, err)
+		}
+		for _, doc := range docs {
+			data, _ := json.Marshal(doc.Data)
+			id := doc.ID
+			if strings.HasPrefix(id, "doc_") {
+				id = "generated"
+			}
+			lines = append(lines, schema.Name+"/"+id+" "+string(doc.Visibility)+" "+string(data))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func TestLoadDir_Idempotent(t *testing.T) {
+	c := newTestCatalog(t)
+	dir := writeFixtures(t)
+
+	first, err := LoadDir(c, dir)
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	want := &Summary{Files: 1, Databases: 1, Schemas: 2, Documents: 4}
+	if !reflect.DeepEqual(first, want) {
+		t.Errorf("LoadDir() = %+v, want %+v", first, want)
+	}
+	loaded := contents(t, c, "db_fixture")
+
+	// Changes made since are undone rather than added to
+	if _, err := c.InsertDocument("db_fixture", "notes", map[string]interface{}{"body": "extra"}, ""); err != nil {
+		t.Fatalf("InsertDocument() error = %v", err)
+	}
+	if err := c.DeleteDocument("db_fixture", "tasks", "task_1", 0); err != nil {
+		t.Fatalf("DeleteDocument() error = %v", err)
+	}
+
+	second, err := LoadDir(c, dir)
+	if err != nil {
+		t.Fatalf("second LoadDir() error = %v", err)
+	}
+	if !reflect.DeepEqual(second, first) {
+		t.Errorf("second LoadDir() = %+v, want %+v", second, first)
+	}
+	if got := contents(t, c, "db_fixture"); !reflect.DeepEqual(got, loaded) {
+		t.Errorf("after reloading:\n%v\nwant\n%v", got, loaded)
+	}
+	if db, err := c.GetDatabaseByWriteKey("wk_fixture"); err != nil || db == nil || db.ID != "db_fixture" {
+		t.Errorf("GetDatabaseByWriteKey() = %v, %v, want db_fixture", db, err)
+	}
+}
+
+func TestLoadDir_Deterministic(t *testing.T) {
+	dir := writeFixtures(t)
+	var loads [][]string
+	for range 2 {
+		c := newTestCatalog(t)
+		if _, err := LoadDir(c, dir); err != nil {
+			t.Fatalf("LoadDir() error = %v", err)
+		}
+		loads = append(loads, contents(t, c, "db_fixture"))
+	}
+	if !reflect.DeepEqual(loads[0], loads[1]) {
+		t.Errorf("loads differ:\n%v\n%v", loads[0], loads[1])
+	}
+}
+
+func TestLoadDemo_KeepsExisting(t *testing.T) {
+	c := newTestCatalog(t)
+	demo, created, err := LoadDemo(c, database.RandomKeys{})
+	if err != nil || !created {
+		t.Fatalf("LoadDemo() = %v, %v, want a created database", created, err)
+	}
+	loaded := contents(t, c, DemoDatabaseID)
+
+	again, created, err := LoadDemo(c, database.RandomKeys{})
+	if err != nil || created {
+		t.Fatalf("second LoadDemo() = %v, %v, want the existing database", created, err)
+	}
+	if again.WriteKey != demo.WriteKey || again.ReadKey != demo.ReadKey {
+		t.Error("second LoadDemo() changed the demo database's keys")
+	}
+	if got := contents(t, c, DemoDatabaseID); !reflect.DeepEqual(got, loaded) {
+		t.Errorf("after a second load:\n%v\nwant\n%v", got, loaded)
+	}
+}
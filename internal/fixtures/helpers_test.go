@@ -0,0 +1,21 @@
+package fixtures
+
+import (
+	"path/filepath"
+	"testing"
+
+	"jsondrop/internal/database"
+)
+
+// newTestCatalog opens an empty catalog in a temporary directory, closed when the
+// test ends
+func newTestCatalog(t *testing.T) *database.CatalogDB {
+	t.Helper()
+	dir := t.TempDir()
+	c, err := database.NewCatalogDB(filepath.Join(dir, "catalog.db"), dir, 1, database.Limits{}, database.PoolConfig{}, database.Compression{}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewCatalogDB() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
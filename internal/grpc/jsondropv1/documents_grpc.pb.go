@@ -0,0 +1,297 @@
+// The gRPC API, served on GRPC_PORT over HTTP/2 without TLS.
+//
+// Calls authenticate with the database's keys in the "authorization" metadata, as
+// "Bearer <key>": reads and Watch accept the read or write key, writes need the write
+// key. Document data travels as JSON, since collections define their own fields.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: jsondrop/v1/documents.proto
+
+package jsondropv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Documents_GetDocument_FullMethodName    = "/jsondrop.v1.Documents/GetDocument"
+	Documents_InsertDocument_FullMethodName = "/jsondrop.v1.Documents/InsertDocument"
+	Documents_UpdateDocument_FullMethodName = "/jsondrop.v1.Documents/UpdateDocument"
+	Documents_DeleteDocument_FullMethodName = "/jsondrop.v1.Documents/DeleteDocument"
+	Documents_Watch_FullMethodName          = "/jsondrop.v1.Documents/Watch"
+)
+
+// DocumentsClient is the client API for Documents service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DocumentsClient interface {
+	// Returns a document; documents the key may not see are NOT_FOUND
+	GetDocument(ctx context.Context, in *GetDocumentRequest, opts ...grpc.CallOption) (*Document, error)
+	// Inserts a document, validated against the collection's schema
+	InsertDocument(ctx context.Context, in *InsertDocumentRequest, opts ...grpc.CallOption) (*Document, error)
+	// Replaces a document's data
+	UpdateDocument(ctx context.Context, in *UpdateDocumentRequest, opts ...grpc.CallOption) (*Document, error)
+	// Deletes a document
+	DeleteDocument(ctx context.Context, in *DeleteDocumentRequest, opts ...grpc.CallOption) (*DeleteDocumentResponse, error)
+	// Streams a database's change events, or a collection's, until the call is canceled.
+	// The first event is "connected" and "dropped" notices may follow; both carry the
+	// JSON the event streams send in data_json
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error)
+}
+
+type documentsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDocumentsClient(cc grpc.ClientConnInterface) DocumentsClient {
+	return &documentsClient{cc}
+}
+
+func (c *documentsClient) GetDocument(ctx context.Context, in *GetDocumentRequest, opts ...grpc.CallOption) (*Document, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Document)
+	err := c.cc.Invoke(ctx, Documents_GetDocument_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentsClient) InsertDocument(ctx context.Context, in *InsertDocumentRequest, opts ...grpc.CallOption) (*Document, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Document)
+	err := c.cc.Invoke(ctx, Documents_InsertDocument_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentsClient) UpdateDocument(ctx context.Context, in *UpdateDocumentRequest, opts ...grpc.CallOption) (*Document, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Document)
+	err := c.cc.Invoke(ctx, Documents_UpdateDocument_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentsClient) DeleteDocument(ctx context.Context, in *DeleteDocumentRequest, opts ...grpc.CallOption) (*DeleteDocumentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteDocumentResponse)
+	err := c.cc.Invoke(ctx, Documents_DeleteDocument_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *documentsClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Documents_ServiceDesc.Streams[0], Documents_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, ChangeEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Documents_WatchClient = grpc.ServerStreamingClient[ChangeEvent]
+
+// DocumentsServer is the server API for Documents service.
+// All implementations must embed UnimplementedDocumentsServer
+// for forward compatibility.
+type DocumentsServer interface {
+	// Returns a document; documents the key may not see are NOT_FOUND
+	GetDocument(context.Context, *GetDocumentRequest) (*Document, error)
+	// Inserts a document, validated against the collection's schema
+	InsertDocument(context.Context, *InsertDocumentRequest) (*Document, error)
+	// Replaces a document's data
+	UpdateDocument(context.Context, *UpdateDocumentRequest) (*Document, error)
+	// Deletes a document
+	DeleteDocument(context.Context, *DeleteDocumentRequest) (*DeleteDocumentResponse, error)
+	// Streams a database's change events, or a collection's, until the call is canceled.
+	// The first event is "connected" and "dropped" notices may follow; both carry the
+	// JSON the event streams send in data_json
+	Watch(*WatchRequest, grpc.ServerStreamingServer[ChangeEvent]) error
+	mustEmbedUnimplementedDocumentsServer()
+}
+
+// UnimplementedDocumentsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDocumentsServer struct{}
+
+func (UnimplementedDocumentsServer) GetDocument(context.Context, *GetDocumentRequest) (*Document, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDocument not implemented")
+}
+func (UnimplementedDocumentsServer) InsertDocument(context.Context, *InsertDocumentRequest) (*Document, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InsertDocument not implemented")
+}
+func (UnimplementedDocumentsServer) UpdateDocument(context.Context, *UpdateDocumentRequest) (*Document, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateDocument not implemented")
+}
+func (UnimplementedDocumentsServer) DeleteDocument(context.Context, *DeleteDocumentRequest) (*DeleteDocumentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteDocument not implemented")
+}
+func (UnimplementedDocumentsServer) Watch(*WatchRequest, grpc.ServerStreamingServer[ChangeEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedDocumentsServer) mustEmbedUnimplementedDocumentsServer() {}
+func (UnimplementedDocumentsServer) testEmbeddedByValue()                   {}
+
+// UnsafeDocumentsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DocumentsServer will
+// result in compilation errors.
+type UnsafeDocumentsServer interface {
+	mustEmbedUnimplementedDocumentsServer()
+}
+
+func RegisterDocumentsServer(s grpc.ServiceRegistrar, srv DocumentsServer) {
+	// If the following call pancis, it indicates UnimplementedDocumentsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Documents_ServiceDesc, srv)
+}
+
+func _Documents_GetDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentsServer).GetDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Documents_GetDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentsServer).GetDocument(ctx, req.(*GetDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Documents_InsertDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InsertDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentsServer).InsertDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Documents_InsertDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentsServer).InsertDocument(ctx, req.(*InsertDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Documents_UpdateDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentsServer).UpdateDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Documents_UpdateDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentsServer).UpdateDocument(ctx, req.(*UpdateDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Documents_DeleteDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DocumentsServer).DeleteDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Documents_DeleteDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DocumentsServer).DeleteDocument(ctx, req.(*DeleteDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Documents_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DocumentsServer).Watch(m, &grpc.GenericServerStream[WatchRequest, ChangeEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Documents_WatchServer = grpc.ServerStreamingServer[ChangeEvent]
+
+// Documents_ServiceDesc is the grpc.ServiceDesc for Documents service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Documents_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "jsondrop.v1.Documents",
+	HandlerType: (*DocumentsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetDocument",
+			Handler:    _Documents_GetDocument_Handler,
+		},
+		{
+			MethodName: "InsertDocument",
+			Handler:    _Documents_InsertDocument_Handler,
+		},
+		{
+			MethodName: "UpdateDocument",
+			Handler:    _Documents_UpdateDocument_Handler,
+		},
+		{
+			MethodName: "DeleteDocument",
+			Handler:    _Documents_DeleteDocument_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Documents_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "jsondrop/v1/documents.proto",
+}
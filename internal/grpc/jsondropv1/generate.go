@@ -0,0 +1,5 @@
+// Package jsondropv1 holds the messages and service stubs generated from
+// proto/jsondrop/v1/documents.proto
+package jsondropv1
+
+//go:generate protoc -I ../../../proto --go_out=../../.. --go_opt=module=jsondrop --go-grpc_out=../../.. --go-grpc_opt=module=jsondrop jsondrop/v1/documents.proto
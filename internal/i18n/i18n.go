@@ -0,0 +1,122 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+
+	"jsondrop/internal/models"
+)
+
+// DefaultLanguage is used when a request accepts none of the supported languages
+const DefaultLanguage = "en"
+
+// Languages lists the supported languages, default first
+var Languages = []string{"en", "es", "de"}
+
+// catalog holds a language's messages
+type catalog struct {
+	validationFailed string                      // Prefix for document validation messages
+	validation       map[string]string           // Message templates by validation error code
+	types            map[models.FieldType]string // Field type names as used in messages
+}
+
+// Templates use {field}, {expected} and {got} for the error's details
+var catalogs = map[string]catalog{
+	"en": {
+		validationFailed: "Validation failed",
+		validation: map[string]string{
+			models.ValidationUnknownField:     "field '{field}' is not defined in schema",
+			models.ValidationMissingField:     "required field '{field}' is missing",
+			models.ValidationInvalidType:      "field '{field}' must be {expected}, got {got}",
+			models.ValidationUnknownFieldType: "unknown field type: {expected}",
+			models.ValidationRetiredField:     "field '{field}' is deprecated and can no longer be written",
+		},
+		types: map[models.FieldType]string{
+			models.FieldTypeString: "a string",
+			models.FieldTypeNumber: "a number",
+			models.FieldTypeBool:   "a boolean",
+		},
+	},
+	"es": {
+		validationFailed: "La validación falló",
+		validation: map[string]string{
+			models.ValidationUnknownField:     "el campo '{field}' no está definido en el esquema",
+			models.ValidationMissingField:     "falta el campo obligatorio '{field}'",
+			models.ValidationInvalidType:      "el campo '{field}' debe ser {expected}, se recibió {got}",
+			models.ValidationUnknownFieldType: "tipo de campo desconocido: {expected}",
+			models.ValidationRetiredField:     "el campo '{field}' está obsoleto y ya no se puede escribir",
+		},
+		types: map[models.FieldType]string{
+			models.FieldTypeString: "una cadena de texto",
+			models.FieldTypeNumber: "un número",
+			models.FieldTypeBool:   "un booleano",
+		},
+	},
+	"de": {
+		validationFailed: "Validierung fehlgeschlagen",
+		validation: map[string]string{
+			models.ValidationUnknownField:     "Feld '{field}' ist im Schema nicht definiert",
+			models.ValidationMissingField:     "Pflichtfeld '{field}' fehlt",
+			models.ValidationInvalidType:      "Feld '{field}' muss {expected} sein, erhalten: {got}",
+			models.ValidationUnknownFieldType: "Unbekannter Feldtyp: {expected}",
+			models.ValidationRetiredField:     "Feld '{field}' ist veraltet und kann nicht mehr geschrieben werden",
+		},
+		types: map[models.FieldType]string{
+			models.FieldTypeString: "eine Zeichenkette",
+			models.FieldTypeNumber: "eine Zahl",
+			models.FieldTypeBool:   "ein boolescher Wert",
+		},
+	},
+}
+
+// Negotiate picks the supported language a client prefers from an Accept-Language header
+// Regional variants match their base language ("es-MX" selects "es"); "*" selects the default
+func Negotiate(acceptLanguage string) string {
+	best := DefaultLanguage
+	bestQ := 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if base == "*" {
+			base = DefaultLanguage
+		}
+		// Earlier entries win ties, as clients list preferences in order
+		if _, ok := catalogs[base]; ok && q > bestQ {
+			best, bestQ = base, q
+		}
+	}
+	return best
+}
+
+// ValidationMessage returns a document validation error's message in a language,
+// including the "Validation failed" prefix. Unknown languages fall back to the default
+func ValidationMessage(lang string, err *models.ValidationError) string {
+	c, ok := catalogs[lang]
+	if !ok {
+		c = catalogs[DefaultLanguage]
+	}
+	template, ok := c.validation[err.Code]
+	if !ok {
+		return c.validationFailed + ": " + err.Error()
+	}
+
+	expected, ok := c.types[err.Expected]
+	if !ok {
+		expected = string(err.Expected)
+	}
+	message := strings.NewReplacer(
+		"{field}", err.Field,
+		"{expected}", expected,
+		"{got}", err.Got,
+	).Replace(template)
+	return c.validationFailed + ": " + message
+}
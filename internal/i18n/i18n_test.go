@@ -0,0 +1,78 @@
+package i18n
+
+import (
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"es", "es"},
+		{"de-DE,de;q=0.9,en;q=0.8", "de"},
+		{"fr-FR, es-MX;q=0.7, en;q=0.5", "es"},
+		{"en;q=0.4, de;q=0.6", "de"},
+		{"es;q=0, fr", "en"},
+		{"fr, *;q=0.1", "en"},
+		{"de;q=bad, es", "es"},
+		{"ES-es", "es"},
+	}
+
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogsComplete(t *testing.T) {
+	en := catalogs[DefaultLanguage]
+	for _, lang := range Languages {
+		c, ok := catalogs[lang]
+		if !ok {
+			t.Fatalf("no catalog for %s", lang)
+		}
+		for code := range en.validation {
+			if c.validation[code] == "" {
+				t.Errorf("%s: no message for %s", lang, code)
+			}
+		}
+		for fieldType := range en.types {
+			if c.types[fieldType] == "" {
+				t.Errorf("%s: no name for type %s", lang, fieldType)
+			}
+		}
+	}
+}
+
+func TestValidationMessage(t *testing.T) {
+	errs := []*models.ValidationError{
+		{Code: models.ValidationUnknownField, Field: "x"},
+		{Code: models.ValidationMissingField, Field: "title"},
+		{Code: models.ValidationInvalidType, Field: "age", Expected: models.FieldTypeNumber, Got: "string"},
+		{Code: models.ValidationUnknownFieldType, Field: "age", Expected: "date"},
+		{Code: models.ValidationRetiredField, Field: "nick"},
+	}
+
+	// English messages match the errors' own text
+	for _, err := range errs {
+		if got, want := ValidationMessage("en", err), "Validation failed: "+err.Error(); got != want {
+			t.Errorf("ValidationMessage(en) = %q, want %q", got, want)
+		}
+	}
+
+	invalid := errs[2]
+	if got, want := ValidationMessage("es", invalid), "La validación falló: el campo 'age' debe ser un número, se recibió string"; got != want {
+		t.Errorf("ValidationMessage(es) = %q, want %q", got, want)
+	}
+	if got, want := ValidationMessage("de", invalid), "Validierung fehlgeschlagen: Feld 'age' muss eine Zahl sein, erhalten: string"; got != want {
+		t.Errorf("ValidationMessage(de) = %q, want %q", got, want)
+	}
+	if got := ValidationMessage("fr", invalid); got != ValidationMessage("en", invalid) {
+		t.Errorf("ValidationMessage(fr) = %q, want the English message", got)
+	}
+}
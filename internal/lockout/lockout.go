@@ -0,0 +1,103 @@
+// Package lockout throttles clients that keep failing to authenticate, so keys can't be
+// guessed online: past a number of failures, each further failure locks the client out
+// for twice as long as the last, up to a maximum
+package lockout
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"jsondrop/internal/clientaddr"
+)
+
+// maxClients bounds the clients tracked at once; failures of further clients aren't
+// counted until quiet ones are forgotten
+const maxClients = 100000
+
+// Guard counts failed attempts by client address
+type Guard struct {
+	threshold int
+	maxDelay  time.Duration
+
+	mu      sync.Mutex
+	clients map[netip.Prefix]*client
+	pruned  time.Time // When forgotten clients were last dropped
+}
+
+// client is the record of one address's failures
+type client struct {
+	failures int
+	last     time.Time // Last failure
+	until    time.Time // End of the current lockout
+}
+
+// New creates a guard allowing threshold failures before locking clients out for up to
+// maxDelay. A client is forgotten once it has made no attempt for maxDelay after its
+// last failure or lockout
+func New(threshold int, maxDelay time.Duration) *Guard {
+	return &Guard{threshold: threshold, maxDelay: maxDelay, clients: make(map[netip.Prefix]*client)}
+}
+
+// Wait returns how long a client is still locked out; 0 lets it try. A nil guard never
+// locks clients out
+func (g *Guard) Wait(addr netip.Addr, now time.Time) time.Duration {
+	if g == nil || !addr.IsValid() {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c := g.clients[clientaddr.Key(addr)]; c != nil && now.Before(c.until) {
+		return c.until.Sub(now)
+	}
+	return 0
+}
+
+// Fail records a failed attempt, returning how long the client is now locked out
+func (g *Guard) Fail(addr netip.Addr, now time.Time) time.Duration {
+	if g == nil || !addr.IsValid() {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.pruned) >= time.Minute {
+		for key, c := range g.clients {
+			if g.forgotten(c, now) {
+				delete(g.clients, key)
+			}
+		}
+		g.pruned = now
+	}
+
+	key := clientaddr.Key(addr)
+	c := g.clients[key]
+	if c != nil && g.forgotten(c, now) {
+		c = nil
+	}
+	if c == nil {
+		if len(g.clients) >= maxClients {
+			return 0
+		}
+		c = &client{}
+		g.clients[key] = c
+	}
+	c.failures++
+	c.last = now
+	if c.failures <= g.threshold {
+		return 0
+	}
+
+	delay := g.maxDelay
+	if doublings := c.failures - g.threshold - 1; doublings < 32 {
+		delay = min(time.Second<<doublings, g.maxDelay)
+	}
+	c.until = now.Add(delay)
+	return delay
+}
+
+// forgotten reports whether a client has been quiet long enough to start over
+func (g *Guard) forgotten(c *client, now time.Time) bool {
+	return now.Sub(c.last.Add(g.maxDelay)) >= 0 && now.Sub(c.until.Add(g.maxDelay)) >= 0
+}
@@ -0,0 +1,68 @@
+package lockout
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestGuard(t *testing.T) {
+	g := New(3, 8*time.Second)
+	addr := netip.MustParseAddr("203.0.113.7")
+	now := time.Unix(1700000000, 0)
+
+	for i := 0; i < 3; i++ {
+		if delay := g.Fail(addr, now); delay != 0 {
+			t.Fatalf("Fail(%d) = %v, want no lockout within the threshold", i+1, delay)
+		}
+	}
+	if wait := g.Wait(addr, now); wait != 0 {
+		t.Errorf("Wait() within the threshold = %v, want 0", wait)
+	}
+
+	// Each failure past the threshold doubles the lockout, up to the maximum
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second} {
+		if delay := g.Fail(addr, now); delay != want {
+			t.Fatalf("Fail() = %v, want %v", delay, want)
+		}
+		if wait := g.Wait(addr, now.Add(time.Second/2)); wait != want-time.Second/2 {
+			t.Errorf("Wait() half a second later = %v, want %v", wait, want-time.Second/2)
+		}
+		now = now.Add(want)
+		if wait := g.Wait(addr, now); wait != 0 {
+			t.Errorf("Wait() once the lockout ended = %v, want 0", wait)
+		}
+	}
+
+	// Other clients aren't affected, except the same IPv6 /64
+	if wait := g.Wait(netip.MustParseAddr("203.0.113.8"), now.Add(-time.Second)); wait != 0 {
+		t.Errorf("Wait(other client) = %v, want 0", wait)
+	}
+	v6 := netip.MustParseAddr("2001:db8:1:2::1")
+	for i := 0; i < 4; i++ {
+		g.Fail(v6, now)
+	}
+	if wait := g.Wait(netip.MustParseAddr("2001:db8:1:2:ffff::9"), now); wait != time.Second {
+		t.Errorf("Wait(same /64) = %v, want 1s", wait)
+	}
+	if wait := g.Wait(netip.MustParseAddr("2001:db8:1:3::1"), now); wait != 0 {
+		t.Errorf("Wait(other /64) = %v, want 0", wait)
+	}
+
+	// A client quiet for the maximum after its lockout starts over
+	now = now.Add(8 * time.Second)
+	if delay := g.Fail(addr, now); delay != 0 {
+		t.Errorf("Fail() after a quiet period = %v, want the threshold to apply again", delay)
+	}
+}
+
+func TestGuard_Nil(t *testing.T) {
+	var g *Guard
+	addr := netip.MustParseAddr("203.0.113.7")
+	if delay := g.Fail(addr, time.Now()); delay != 0 {
+		t.Errorf("nil Fail() = %v, want 0", delay)
+	}
+	if wait := g.Wait(addr, time.Now()); wait != 0 {
+		t.Errorf("nil Wait() = %v, want 0", wait)
+	}
+}
@@ -0,0 +1,233 @@
+// Package mirror keeps read-only copies of collections in other databases up to date
+package mirror
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+	"jsondrop/internal/policy"
+)
+
+// Replicator applies change events to the mirrors of the changed collections
+// Events are queued and applied in order on one goroutine, so writers never wait on a
+// mirror and no change is lost to event rate limiting
+type Replicator struct {
+	catalog *database.CatalogDB
+	mu      sync.Mutex
+	queue   []models.ChangeEvent
+	wake    chan struct{}
+	applyMu sync.Mutex // Serializes applying events with full syncs
+}
+
+// NewReplicator creates a replicator and starts applying the events it observes
+func NewReplicator(catalog *database.CatalogDB) *Replicator {
+	r := &Replicator{
+		catalog: catalog,
+		wake:    make(chan struct{}, 1),
+	}
+	go r.run()
+	return r
+}
+
+// Observe queues a change event for the mirrors of its collection
+// It is registered with the broadcaster, and never blocks
+func (r *Replicator) Observe(event models.ChangeEvent) {
+	switch event.EventType {
+	case "insert", "update", "delete", "restore", "schema_updated":
+	default:
+		return
+	}
+
+	r.mu.Lock()
+	r.queue = append(r.queue, event)
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+		// Already woken; the queue is drained in full
+	}
+}
+
+// run applies queued events until the process exits
+func (r *Replicator) run() {
+	for range r.wake {
+		for {
+			r.mu.Lock()
+			batch := r.queue
+			r.queue = nil
+			r.mu.Unlock()
+			if len(batch) == 0 {
+				break
+			}
+			for _, event := range batch {
+				r.apply(event)
+			}
+		}
+	}
+}
+
+// apply updates the mirrors of an event's collection
+// A schema update can rewrite every document without per-document events, so it resyncs
+func (r *Replicator) apply(event models.ChangeEvent) {
+	mirrors, err := r.catalog.ListMirrors(event.DatabaseID, event.Collection)
+	if err != nil {
+		log.Printf("mirror: failed to list mirrors of %s/%s: %v", event.DatabaseID, event.Collection, err)
+		return
+	}
+
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	for _, mirror := range mirrors {
+		if event.EventType == "schema_updated" {
+			_, err = r.sync(mirror)
+		} else {
+			_, err = r.syncDocument(mirror, event.DocumentID)
+		}
+		if err != nil {
+			log.Printf("mirror %s: %v", mirror.ID, err)
+		}
+	}
+}
+
+// Sync brings a mirror's target collection in line with its source, returning the
+// number of documents copied
+func (r *Replicator) Sync(mirror *models.Mirror) (int, error) {
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	return r.sync(mirror)
+}
+
+// sync copies every source document and removes target documents with no source
+// Document IDs are collected first, so no read is open while writing
+func (r *Replicator) sync(mirror *models.Mirror) (int, error) {
+	sourceIDs, err := r.documentIDs(mirror.DatabaseID, mirror.Collection)
+	if err != nil {
+		return 0, err
+	}
+	copied := 0
+	sources := make(map[string]bool, len(sourceIDs))
+	for _, docID := range sourceIDs {
+		sources[docID] = true
+		ok, err := r.syncDocument(mirror, docID)
+		if err != nil {
+			return copied, err
+		}
+		if ok {
+			copied++
+		}
+	}
+
+	targetIDs, err := r.documentIDs(mirror.TargetDatabaseID, mirror.TargetCollection)
+	if err != nil {
+		return copied, err
+	}
+	for _, docID := range targetIDs {
+		if !sources[docID] {
+			if err := r.removeCopy(mirror, docID); err != nil {
+				return copied, err
+			}
+		}
+	}
+
+	return copied, nil
+}
+
+// syncDocument copies one document to a mirror's target, or removes its copy when the
+// document is gone, no longer passes the filter or is hidden from the copies' readers.
+// Returns whether a copy exists
+func (r *Replicator) syncDocument(mirror *models.Mirror, docID string) (bool, error) {
+	filter, err := ParseFilter(mirror.Filter)
+	if err != nil {
+		return false, err
+	}
+	doc, err := r.catalog.GetDocument(mirror.DatabaseID, mirror.Collection, docID, Scope(mirror.Visibility))
+	if err != nil {
+		return false, err
+	}
+
+	data, ok := Copy(mirror.Fields, filter, doc)
+	if !ok {
+		return false, r.removeCopy(mirror, docID)
+	}
+
+	existing, err := r.catalog.GetDocument(mirror.TargetDatabaseID, mirror.TargetCollection, docID, nil)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case existing == nil:
+		_, err = r.catalog.InsertDocumentWithID(mirror.TargetDatabaseID, mirror.TargetCollection, docID, data, mirror.Visibility)
+	case !reflect.DeepEqual(existing.Data, data) || existing.Visibility != mirror.Visibility:
+		_, err = r.catalog.UpdateDocument(mirror.TargetDatabaseID, mirror.TargetCollection, docID, data, mirror.Visibility, 0)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to copy %s: %w", docID, err)
+	}
+	return true, nil
+}
+
+// removeCopy deletes a document's copy from a mirror's target, if there is one
+func (r *Replicator) removeCopy(mirror *models.Mirror, docID string) error {
+	err := r.catalog.DeleteDocument(mirror.TargetDatabaseID, mirror.TargetCollection, docID, 0)
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return fmt.Errorf("failed to remove copy of %s: %w", docID, err)
+	}
+	return nil
+}
+
+// documentIDs returns the IDs of a collection's live documents
+func (r *Replicator) documentIDs(dbID string, collection string) ([]string, error) {
+	var ids []string
+	err := r.catalog.EachDocument(dbID, collection, nil, func(doc *models.Document) error {
+		ids = append(ids, doc.ID)
+		return nil
+	})
+	return ids, err
+}
+
+// Scope returns the read scope of the source documents mirrored at a visibility: those the
+// copies' readers could read in the source, so a public mirror never copies documents
+// needing a key
+func Scope(visibility models.Visibility) *database.ReadScope {
+	authenticated := visibility != models.VisibilityPublic
+	return &database.ReadScope{Visible: models.VisibleLevels(authenticated, visibility == models.VisibilityWriteKeyOnly)}
+}
+
+// ParseFilter parses a mirror's filter expression; an empty filter passes every document
+func ParseFilter(source string) (*policy.Expression, error) {
+	if source == "" {
+		return nil, nil
+	}
+	filter, err := policy.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mirror filter: %w", err)
+	}
+	return filter, nil
+}
+
+// Copy returns the mirrored fields of a document, and false if the document is nil or
+// doesn't pass the filter. The filter sees the whole document, as a public reader
+func Copy(fields []string, filter *policy.Expression, doc *models.Document) (map[string]interface{}, bool) {
+	if doc == nil {
+		return nil, false
+	}
+	if filter != nil && !filter.Eval(policy.Env{Data: doc.Data, KeyType: policy.KeyTypePublic}) {
+		return nil, false
+	}
+
+	data := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if value, ok := doc.Data[field]; ok {
+			data[field] = value
+		}
+	}
+	return data, true
+}
@@ -0,0 +1,93 @@
+package mirror
+
+import (
+	"reflect"
+	"testing"
+
+	"jsondrop/internal/models"
+)
+
+func TestCopy(t *testing.T) {
+	filter, err := ParseFilter(`data.published == true`)
+	if err != nil {
+		t.Fatalf("ParseFilter: %v", err)
+	}
+	fields := []string{"title", "summary"}
+
+	tests := []struct {
+		name   string
+		doc    *models.Document
+		want   map[string]interface{}
+		wantOK bool
+	}{
+		{"missing document", nil, nil, false},
+		{
+			"filtered out",
+			&models.Document{Data: map[string]interface{}{"title": "Draft", "published": false}},
+			nil, false,
+		},
+		{
+			"unlisted fields left out",
+			&models.Document{Data: map[string]interface{}{"title": "Hello", "summary": "Hi", "email": "a@b.c", "published": true}},
+			map[string]interface{}{"title": "Hello", "summary": "Hi"}, true,
+		},
+		{
+			"absent fields stay absent",
+			&models.Document{Data: map[string]interface{}{"title": "Hello", "published": true}},
+			map[string]interface{}{"title": "Hello"}, true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Copy(fields, filter, tt.doc)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Copy() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	// Without a filter every document is copied
+	doc := &models.Document{Data: map[string]interface{}{"title": "Draft", "published": false}}
+	if got, ok := Copy(fields, nil, doc); !ok || got["title"] != "Draft" {
+		t.Errorf("Copy() without filter = %v, %v, want the title", got, ok)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	if filter, err := ParseFilter(""); filter != nil || err != nil {
+		t.Errorf("ParseFilter(\"\") = %v, %v, want no filter", filter, err)
+	}
+	if _, err := ParseFilter("data.published =="); err == nil {
+		t.Error("ParseFilter accepted an incomplete expression")
+	}
+}
+
+func TestSync_SkipsHiddenDocuments(t *testing.T) {
+	c := newTestCatalog(t)
+
+	for _, dbID := range []string{"db_source", "db_target"} {
+		newTestDatabase(t, c, dbID)
+	}
+	if _, err := c.CreateSchema("db_source", "posts", map[string]models.FieldType{"title": models.FieldTypeString}, nil); err != nil {
+		t.Fatalf("CreateSchema() error = %v", err)
+	}
+	visibilities := []models.Visibility{models.VisibilityPublic, models.VisibilityReadKey, models.VisibilityWriteKeyOnly}
+	for _, visibility := range visibilities {
+		if _, err := c.InsertDocumentWithID("db_source", "posts", string(visibility), map[string]interface{}{"title": "x"}, visibility); err != nil {
+			t.Fatalf("InsertDocumentWithID(%s) error = %v", visibility, err)
+		}
+	}
+
+	r := NewReplicator(c)
+	for i, visibility := range visibilities {
+		m := &models.Mirror{DatabaseID: "db_source", Collection: "posts", TargetDatabaseID: "db_target",
+			TargetCollection: "posts_" + string(visibility), Fields: []string{"title"}, Visibility: visibility}
+		if err := c.CreateMirror(m); err != nil {
+			t.Fatalf("CreateMirror(%s) error = %v", visibility, err)
+		}
+		// Each level copies the documents its readers could read in the source
+		if copied, err := r.Sync(m); err != nil || copied != i+1 {
+			t.Errorf("Sync(%s) = %d, %v, want %d copies", visibility, copied, err, i+1)
+		}
+	}
+}
@@ -1,24 +1,49 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Database represents a user-created database in the catalog
 type Database struct {
-	ID           string    `json:"id"`
-	WriteKey     string    `json:"-"` // Never expose in JSON responses
-	ReadKey      string    `json:"-"` // Never expose in JSON responses
-	CreatedAt    time.Time `json:"created_at"`
-	LastAccessed time.Time `json:"last_accessed"`
-	QuotaUsed    int64     `json:"quota_used"`    // bytes
-	QuotaLimit   int64     `json:"quota_limit"`   // bytes
+	ID              string    `json:"id"`
+	WriteKey        string    `json:"-"` // Never expose in JSON responses
+	ReadKey         string    `json:"-"` // Never expose in JSON responses
+	CreatedAt       time.Time `json:"created_at"`
+	LastAccessed    time.Time `json:"last_accessed"`
+	QuotaUsed       int64     `json:"quota_used"`                 // bytes
+	QuotaLimit      int64     `json:"quota_limit"`                // bytes
+	AllowedNetworks []string  `json:"allowed_networks,omitempty"` // CIDR ranges keys and tokens work from; empty allows all
+	CORSOrigins     []string  `json:"cors_origins,omitempty"`     // Browser origins allowed besides CORS_ORIGINS
+	Protected       bool      `json:"protected,omitempty"`        // Deleting the database or its schemas needs confirmation
+	KeyGeneration   int64     `json:"-"`                          // Counts key rotations; access tokens minted before the last one are revoked
 }
 
 // Schema represents a collection schema definition
 type Schema struct {
-	DatabaseID string                 `json:"database_id"`
-	Name       string                 `json:"name"`
-	Fields     map[string]FieldType   `json:"fields"`
-	CreatedAt  time.Time              `json:"created_at"`
+	DatabaseID    string                       `json:"database_id"`
+	Name          string                       `json:"name"`
+	Fields        map[string]FieldType         `json:"fields"`
+	ReadFilter    string                       `json:"read_filter,omitempty"`       // Row-level read policy expression
+	RetentionDays int                          `json:"retention_days,omitempty"`    // Documents older than this many days are deleted; 0 keeps them
+	Deprecated    map[string]*FieldDeprecation `json:"deprecated_fields,omitempty"` // Fields being phased out, by name
+	Masked        []string                     `json:"masked_fields,omitempty"`     // Fields only write key holders read, sorted
+	CreatedAt     time.Time                    `json:"created_at"`
+}
+
+// FieldDeprecation marks a schema field for removal after a grace period
+// During the period writes may omit the field, and writes including it are warned; after
+// it, writes including the field fail and the field can be removed
+type FieldDeprecation struct {
+	DeprecatedAt time.Time `json:"deprecated_at"`
+	Until        time.Time `json:"until"`          // End of the grace period
+	Note         string    `json:"note,omitempty"` // Shown in warnings, such as the field to use instead
+}
+
+// Retired reports whether a deprecated field's grace period is over at now
+func (d *FieldDeprecation) Retired(now time.Time) bool {
+	return !now.Before(d.Until)
 }
 
 // FieldType represents the type of a field in a schema
@@ -40,13 +65,84 @@ func (ft FieldType) IsValid() bool {
 	}
 }
 
+// Visibility controls which credentials can read a document
+type Visibility string
+
+const (
+	VisibilityPublic       Visibility = "public"         // Readable without any key
+	VisibilityReadKey      Visibility = "read_key"       // Readable with read or write key
+	VisibilityWriteKeyOnly Visibility = "write_key_only" // Readable with write key only
+)
+
+// DefaultVisibility is applied to documents that don't specify a visibility
+const DefaultVisibility = VisibilityReadKey
+
+// IsValid checks if a visibility level is valid
+func (v Visibility) IsValid() bool {
+	switch v {
+	case VisibilityPublic, VisibilityReadKey, VisibilityWriteKeyOnly:
+		return true
+	default:
+		return false
+	}
+}
+
+// VisibleLevels returns the visibility levels readable with the given access
+func VisibleLevels(authenticated bool, isWrite bool) []Visibility {
+	switch {
+	case isWrite:
+		return []Visibility{VisibilityPublic, VisibilityReadKey, VisibilityWriteKeyOnly}
+	case authenticated:
+		return []Visibility{VisibilityPublic, VisibilityReadKey}
+	default:
+		return []Visibility{VisibilityPublic}
+	}
+}
+
+// CollectionInfo describes a collection and its contents
+type CollectionInfo struct {
+	Name          string    `json:"name"`
+	DocumentCount int64     `json:"document_count"`
+	SizeBytes     int64     `json:"size_bytes"` // Approximate: total size of document JSON
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CollectionStats describes what a collection's documents and indexes take up
+// Byte counts include soft-deleted documents, which count against the quota until purged
+type CollectionStats struct {
+	Name                string           `json:"name"`
+	DocumentCount       int64            `json:"document_count"`
+	DeletedCount        int64            `json:"deleted_count"`
+	TotalBytes          int64            `json:"total_bytes"` // Stored document data, as charged to the quota
+	AverageBytes        float64          `json:"average_bytes"`
+	LargestDocument     *LargestDocument `json:"largest_document,omitempty"`
+	Indexes             []IndexStats     `json:"indexes"`
+	IndexBytes          int64            `json:"index_bytes"`
+	IndexSizesEstimated bool             `json:"index_sizes_estimated"` // Estimated from key lengths, without SQLite's dbstat table
+}
+
+// LargestDocument identifies the largest document in a collection
+type LargestDocument struct {
+	ID    string `json:"id"`
+	Bytes int64  `json:"bytes"`
+}
+
+// IndexStats is the size of one SQLite index of a collection
+type IndexStats struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+}
+
 // Document represents a JSON document in a collection
 type Document struct {
 	ID         string                 `json:"id"`
 	Collection string                 `json:"collection"`
 	Data       map[string]interface{} `json:"data"`
+	Visibility Visibility             `json:"visibility"`
 	CreatedAt  time.Time              `json:"created_at"`
 	UpdatedAt  time.Time              `json:"updated_at"`
+	Revision   int64                  `json:"revision"`         // Starts at 1 and increases with every update; sent as the ETag
+	Joined     map[string][]*Document `json:"joined,omitempty"` // Matching documents from a joined collection
 }
 
 // CreateDatabaseResponse is the response when creating a new database
@@ -56,19 +152,446 @@ type CreateDatabaseResponse struct {
 	ReadKey    string `json:"read_key"`
 }
 
+// RotateKeysRequest chooses the keys to replace and how long the old ones keep working
+type RotateKeysRequest struct {
+	Write        bool `json:"write"`
+	Read         bool `json:"read"`
+	GraceSeconds int  `json:"grace_seconds,omitempty"` // 0 revokes the old keys at once
+}
+
+// RotateKeysResponse carries the new keys, which are shown only this once
+type RotateKeysResponse struct {
+	DatabaseID    string     `json:"database_id"`
+	WriteKey      string     `json:"write_key,omitempty"`          // Only if rotated
+	ReadKey       string     `json:"read_key,omitempty"`           // Only if rotated
+	OldKeysExpire *time.Time `json:"old_keys_expire_at,omitempty"` // With a grace period
+	StreamsClosed int        `json:"streams_closed"`               // Event streams closed because their keys were revoked
+}
+
+// Access levels of scoped keys
+const (
+	KeyAccessWrite = "write"
+	KeyAccessRead  = "read"
+)
+
+// ScopedKey is an extra key of a database restricted to some of its collections, for
+// embedding in semi-trusted clients
+type ScopedKey struct {
+	ID          string     `json:"id"`
+	DatabaseID  string     `json:"database_id"`
+	Name        string     `json:"name,omitempty"`
+	Access      string     `json:"access"` // KeyAccessWrite or KeyAccessRead, as the key's prefix says
+	Collections []string   `json:"collections"`
+	Key         string     `json:"key,omitempty"` // Only returned when the key is created
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // When the key stops working; nil never
+}
+
+// Expired reports whether the key has stopped working at now
+func (k *ScopedKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// CreateScopedKeyRequest is the request to create a scoped key
+type CreateScopedKeyRequest struct {
+	Name        string     `json:"name,omitempty"`
+	Access      string     `json:"access"`
+	Collections []string   `json:"collections"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // RFC 3339; the key never expires without it
+}
+
+// CreateTokenRequest is the request to mint a short-lived access token
+type CreateTokenRequest struct {
+	Access      string   `json:"access,omitempty"`      // KeyAccessWrite or KeyAccessRead; defaults to read
+	Collections []string `json:"collections,omitempty"` // Restricts the token to these collections
+	TTLSeconds  int      `json:"ttl_seconds,omitempty"` // Defaults to 15 minutes; at most TOKEN_MAX_TTL
+	Caller      string   `json:"caller,omitempty"`      // Who the token is for, such as a user ID; read filters see it as token.sub
+}
+
+// TokenResponse carries a minted access token
+type TokenResponse struct {
+	Token       string    `json:"token"`
+	TokenType   string    `json:"token_type"`
+	DatabaseID  string    `json:"database_id"`
+	Access      string    `json:"access"`
+	Collections []string  `json:"collections,omitempty"`
+	Caller      string    `json:"caller,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// CreateSignedURLRequest is the request to sign a URL reading a document or an export
+type CreateSignedURLRequest struct {
+	Collection string `json:"collection,omitempty"`  // Empty for an export of the whole database
+	DocumentID string `json:"document_id,omitempty"` // Empty for an export of the collection
+	Query      string `json:"query,omitempty"`       // Parameters of an export, as format=csv
+	Access     string `json:"access,omitempty"`      // KeyAccessWrite or KeyAccessRead; defaults to read
+	TTLSeconds int    `json:"ttl_seconds,omitempty"` // Defaults to 1 hour; at most 7 days
+}
+
+// SignedURL is a URL reading a document or an export without a key until it expires
+type SignedURL struct {
+	URL       string    `json:"url"` // Path and query, to append to the server's address
+	Method    string    `json:"method"`
+	Access    string    `json:"access"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Allowlist lists the networks a database's keys and tokens work from
+type Allowlist struct {
+	DatabaseID string   `json:"database_id"`
+	Networks   []string `json:"networks"` // CIDR ranges; empty allows every address
+}
+
+// SetAllowlistRequest is the request to replace a database's allowlist
+type SetAllowlistRequest struct {
+	Networks []string `json:"networks"` // CIDR ranges or single IP addresses
+}
+
+// CORSOrigins lists the browser origins a database allows besides the server's
+type CORSOrigins struct {
+	DatabaseID string   `json:"database_id"`
+	Origins    []string `json:"origins"` // scheme://host[:port], or "*" for any origin
+}
+
+// SetCORSOriginsRequest is the request to replace a database's CORS origins
+type SetCORSOriginsRequest struct {
+	Origins []string `json:"origins"`
+}
+
+// Protection tells whether deleting a database or its schemas needs confirmation
+type Protection struct {
+	DatabaseID string `json:"database_id"`
+	Protected  bool   `json:"protected"`
+}
+
+// SetProtectionRequest is the request to protect a database or lift its protection
+type SetProtectionRequest struct {
+	Protected *bool `json:"protected"`
+}
+
+// ShareLink grants read access to one document, or to the results of one saved query,
+// to whoever holds its token, until it expires or, for single-use links, is opened
+type ShareLink struct {
+	ID         string     `json:"id"`
+	DatabaseID string     `json:"database_id"`
+	Collection string     `json:"collection"`
+	DocumentID string     `json:"document_id,omitempty"` // The shared document; empty when sharing a query
+	Query      string     `json:"query,omitempty"`       // The shared query string, as GET /:collection takes it
+	SingleUse  bool       `json:"single_use"`
+	Token      string     `json:"token,omitempty"` // Only returned when the link is created
+	URL        string     `json:"url,omitempty"`   // Path that opens the link; only returned when it is created
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty"` // When a single-use link was opened
+}
+
+// Expired reports whether the link has stopped working at now
+func (l *ShareLink) Expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
+
+// CreateShareLinkRequest is the request to share a document or a query
+type CreateShareLinkRequest struct {
+	Query      string `json:"query,omitempty"` // Only when sharing a query
+	SingleUse  bool   `json:"single_use,omitempty"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"` // 0 keeps the link for a day
+}
+
 // CreateSchemaRequest is the request to define a schema
+// Fields are given by type, or as objects with their type and visibility
 type CreateSchemaRequest struct {
-	Fields map[string]FieldType `json:"fields"`
+	Fields          map[string]FieldType       `json:"fields"`
+	ReadFilter      string                     `json:"read_filter,omitempty"`
+	FieldVisibility map[string]FieldVisibility `json:"-"` // Of the fields given as objects with a visibility
+}
+
+// UnmarshalJSON accepts fields given by type or as objects
+func (r *CreateSchemaRequest) UnmarshalJSON(data []byte) error {
+	type request CreateSchemaRequest
+	var raw struct {
+		request
+		Fields map[string]FieldDefinition `json:"fields"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = CreateSchemaRequest(raw.request)
+	r.Fields, r.FieldVisibility = splitFieldDefinitions(raw.Fields)
+	return nil
+}
+
+// UpdateSchemaRequest is the request to add or remove fields of an existing schema
+// Added fields are given by type, or as objects with their type and visibility
+type UpdateSchemaRequest struct {
+	AddFields       map[string]FieldType       `json:"add_fields,omitempty"`
+	RemoveFields    []string                   `json:"remove_fields,omitempty"`
+	Backfill        map[string]interface{}     `json:"backfill,omitempty"` // Values written into existing documents for added fields
+	FieldVisibility map[string]FieldVisibility `json:"-"`                  // Of the added fields given as objects with a visibility
+}
+
+// UnmarshalJSON accepts added fields given by type or as objects
+func (r *UpdateSchemaRequest) UnmarshalJSON(data []byte) error {
+	type request UpdateSchemaRequest
+	var raw struct {
+		request
+		AddFields map[string]FieldDefinition `json:"add_fields,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = UpdateSchemaRequest(raw.request)
+	r.AddFields, r.FieldVisibility = splitFieldDefinitions(raw.AddFields)
+	return nil
+}
+
+// FieldVisibility controls which keys read a schema field of the documents they read
+type FieldVisibility string
+
+const (
+	FieldVisibilityRead      FieldVisibility = "read"       // Read by every key reading the document
+	FieldVisibilityWriteOnly FieldVisibility = "write_only" // Read with the write key only, like a masked field
+)
+
+// IsValid checks if a field visibility is valid
+func (v FieldVisibility) IsValid() bool {
+	return v == FieldVisibilityRead || v == FieldVisibilityWriteOnly
+}
+
+// FieldDefinition is a schema field in a request, given by its type alone, as "string",
+// or as an object, as {"type": "string", "visibility": "write_only"}
+type FieldDefinition struct {
+	Type       FieldType       `json:"type"`
+	Visibility FieldVisibility `json:"visibility,omitempty"`
+}
+
+// UnmarshalJSON accepts a field's type alone or an object
+func (d *FieldDefinition) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*d = FieldDefinition{}
+		return json.Unmarshal(data, &d.Type)
+	}
+	type definition FieldDefinition
+	return json.Unmarshal(data, (*definition)(d))
+}
+
+// splitFieldDefinitions separates the types of fields from the visibilities given for
+// them; either is nil when there are none
+func splitFieldDefinitions(definitions map[string]FieldDefinition) (map[string]FieldType, map[string]FieldVisibility) {
+	if definitions == nil {
+		return nil, nil
+	}
+	fields := make(map[string]FieldType, len(definitions))
+	var visibility map[string]FieldVisibility
+	for name, definition := range definitions {
+		fields[name] = definition.Type
+		if definition.Visibility != "" {
+			if visibility == nil {
+				visibility = make(map[string]FieldVisibility)
+			}
+			visibility[name] = definition.Visibility
+		}
+	}
+	return fields, visibility
+}
+
+// SetReadFilterRequest is the request to set a collection's row-level read policy
+type SetReadFilterRequest struct {
+	ReadFilter string `json:"read_filter"` // Empty removes the policy
+}
+
+// DeprecateFieldRequest is the request to deprecate a schema field
+type DeprecateFieldRequest struct {
+	GraceDays int    `json:"grace_days"` // Days writes may still include the field; 0 ends the grace period at once
+	Note      string `json:"note,omitempty"`
+}
+
+// SetRetentionRequest is the request to set how long a collection keeps documents
+type SetRetentionRequest struct {
+	RetentionDays int `json:"retention_days"` // 0 keeps documents forever
+}
+
+// EraseSubjectRequest is the request to erase a data subject's documents
+type EraseSubjectRequest struct {
+	Field string      `json:"field"`
+	Value interface{} `json:"value"` // String, number or bool
+}
+
+// ErasureReceipt records the documents erased for a data subject
+type ErasureReceipt struct {
+	ID          string             `json:"id"`
+	DatabaseID  string             `json:"database_id"`
+	Field       string             `json:"field"`
+	ValueSHA256 string             `json:"value_sha256"` // The erased value itself isn't kept
+	Collections []ErasedCollection `json:"collections"`
+	Documents   int                `json:"documents"`
+	Bytes       int64              `json:"bytes"` // Stored size released from the quota
+	ErasedAt    time.Time          `json:"erased_at"`
+}
+
+// ErasedCollection lists the documents erased from one collection
+type ErasedCollection struct {
+	Name        string   `json:"name"`
+	DocumentIDs []string `json:"document_ids"`
+}
+
+// RenameSchemaRequest is the request to rename a collection
+type RenameSchemaRequest struct {
+	NewName string `json:"new_name"`
+}
+
+// Index is a user-declared index over several fields of a collection
+// Fields starting with '-' are indexed in descending order
+type Index struct {
+	Name       string    `json:"name"`
+	Collection string    `json:"collection"`
+	Fields     []string  `json:"fields"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateIndexRequest is the request to declare an index
+type CreateIndexRequest struct {
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+}
+
+// Mirror continuously copies a subset of a collection's documents into a collection of
+// another database. The target collection is read-only while the mirror exists
+type Mirror struct {
+	ID               string     `json:"id"`
+	DatabaseID       string     `json:"database_id"`
+	Collection       string     `json:"collection"`
+	TargetDatabaseID string     `json:"target_database_id"`
+	TargetCollection string     `json:"target_collection"`
+	Fields           []string   `json:"fields"`           // Fields copied; the rest are left out
+	Filter           string     `json:"filter,omitempty"` // Read filter expression a document must pass to be copied
+	Visibility       Visibility `json:"visibility"`       // Visibility of the copies
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// CreateMirrorRequest is the request to mirror a collection into another database
+// The target database's write key shows the caller may write there
+type CreateMirrorRequest struct {
+	TargetWriteKey   string     `json:"target_write_key"`
+	TargetCollection string     `json:"target_collection"` // Defaults to the source collection's name
+	Fields           []string   `json:"fields"`
+	Filter           string     `json:"filter,omitempty"`
+	Visibility       Visibility `json:"visibility,omitempty"` // Defaults to public
+}
+
+// CreateMirrorResponse is the created mirror with the number of documents initially copied
+type CreateMirrorResponse struct {
+	Mirror
+	Copied int `json:"copied"`
+}
+
+// Webhook POSTs a database's change events to a URL, signed with the webhook's secret
+type Webhook struct {
+	ID         string    `json:"id"`
+	DatabaseID string    `json:"database_id"`
+	URL        string    `json:"url"`
+	Collection string    `json:"collection,omitempty"` // Only this collection's events; empty for every collection
+	Events     []string  `json:"events,omitempty"`     // Event types delivered; empty for every type
+	Secret     string    `json:"secret,omitempty"`     // HMAC signing key, only returned when the webhook is created
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookDeadLetter is a delivery a webhook's receiver still refused after every attempt,
+// kept to be inspected and replayed
+type WebhookDeadLetter struct {
+	ID         string          `json:"id"` // Delivery ID, sent again when replayed
+	DatabaseID string          `json:"database_id"`
+	WebhookID  string          `json:"webhook_id"`
+	EventType  string          `json:"event_type"`
+	Event      json.RawMessage `json:"event"`
+	Attempts   int             `json:"attempts"`
+	LastError  string          `json:"last_error"`
+	FailedAt   time.Time       `json:"failed_at"`
+}
+
+// CreateWebhookRequest is the request to register a webhook
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	Collection string   `json:"collection,omitempty"`
+	Events     []string `json:"events,omitempty"`
+}
+
+// Batch operations
+const (
+	BatchInsert = "insert"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// BatchOperation is one write in a batch
+type BatchOperation struct {
+	Op         string                 `json:"op"`                   // insert, update or delete
+	Collection string                 `json:"collection"`
+	ID         string                 `json:"id,omitempty"`         // Document to update or delete
+	Data       map[string]interface{} `json:"data,omitempty"`       // Document data to insert or update
+	Visibility Visibility             `json:"visibility,omitempty"` // As for single inserts and updates
+	Revision   int64                  `json:"revision,omitempty"`   // Nonzero must match the document's revision, as with If-Match
+}
+
+// BatchRequest is a list of writes applied in order, each independently of the others
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// Batch operation outcomes
+const (
+	BatchCreated = "created"
+	BatchUpdated = "updated"
+	BatchDeleted = "deleted"
+	BatchFailed  = "failed"
+)
+
+// BatchResult is the outcome of one batch operation, at the operation's position
+type BatchResult struct {
+	Status   string `json:"status"`
+	ID       string `json:"id,omitempty"`
+	Revision int64  `json:"revision,omitempty"` // Revision after an insert or update
+	Code     string `json:"code,omitempty"`     // Why the operation failed, e.g. not_found or a validation code
+	Field    string `json:"field,omitempty"`    // Field a validation failure is about
+	Error    string `json:"error,omitempty"`
+}
+
+// BatchResponse reports the outcome of every operation in a batch
+type BatchResponse struct {
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
 }
 
 // InsertDocumentRequest is the request to insert a document
 type InsertDocumentRequest struct {
-	Data map[string]interface{} `json:"data"`
+	Data       map[string]interface{} `json:"data"`
+	Visibility Visibility             `json:"visibility,omitempty"` // Defaults to read_key
 }
 
 // UpdateDocumentRequest is the request to update a document
 type UpdateDocumentRequest struct {
-	Data map[string]interface{} `json:"data"`
+	Data       map[string]interface{} `json:"data"`
+	Visibility Visibility             `json:"visibility,omitempty"` // Keeps current visibility if empty
+}
+
+// ImportLineError reports why a line of an import was rejected
+type ImportLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportResponse summarizes an NDJSON import
+type ImportResponse struct {
+	Imported  int               `json:"imported"`
+	Failed    int               `json:"failed"`
+	Errors    []ImportLineError `json:"errors,omitempty"`     // Capped; Failed has the full count
+	Error     string            `json:"error,omitempty"`      // Set when the import stopped early
+	StoppedAt int               `json:"stopped_at,omitempty"` // Line where the import stopped
+}
+
+// PurgeResponse reports how many soft-deleted documents were removed
+type PurgeResponse struct {
+	Purged int `json:"purged"`
 }
 
 // DatabaseInfoResponse returns quota and usage information
@@ -81,10 +604,182 @@ type DatabaseInfoResponse struct {
 	LastAccessed time.Time `json:"last_accessed"`
 }
 
+// QuotaRecalculationResponse reports a database's quota usage recomputed from its stored documents
+type QuotaRecalculationResponse struct {
+	DatabaseID        string `json:"database_id"`
+	PreviousQuotaUsed int64  `json:"previous_quota_used"` // As tracked before recalculation
+	QuotaUsed         int64  `json:"quota_used"`
+	QuotaLimit        int64  `json:"quota_limit"`
+}
+
+// KeyUsage counts a key's activity against a database
+type KeyUsage struct {
+	Requests       int64 `json:"requests"`
+	BytesWritten   int64 `json:"bytes_written"`   // Request bodies of successful writes
+	EventsConsumed int64 `json:"events_consumed"` // Events delivered to the key's event streams
+}
+
+// UsageResponse breaks down a database's activity by key label since Since
+type UsageResponse struct {
+	DatabaseID string              `json:"database_id"`
+	Since      time.Time           `json:"since"` // Server start; counts aren't persisted
+	Keys       map[string]KeyUsage `json:"keys"`
+}
+
+// AggregateResponse is a histogram and/or summary statistics of a collection's documents
+type AggregateResponse struct {
+	Field      string       `json:"field,omitempty"`       // Bucketed field; empty when not bucketing
+	Interval   string       `json:"interval,omitempty"`    // Set for date buckets
+	BucketSize float64      `json:"bucket_size,omitempty"` // Set for numeric buckets
+	Buckets    []Bucket     `json:"buckets"`
+	Missing    int          `json:"missing"`               // Matching documents without a value for the bucketed field
+	StatsField string       `json:"stats_field,omitempty"` // Number field summarized in Stats
+	Stats      *NumberStats `json:"stats,omitempty"`       // Over all matching documents
+}
+
+// AnalyticsRequest is an analytical SQL query over a collection
+type AnalyticsRequest struct {
+	Query string `json:"query"`
+}
+
+// AnalyticsResponse holds the rows of an analytical query
+type AnalyticsResponse struct {
+	Columns   []string        `json:"columns"`
+	Rows      [][]interface{} `json:"rows"`
+	Truncated bool            `json:"truncated"` // More rows matched than were returned
+}
+
+// Bucket counts documents whose value falls in [From, To)
+// Bounds are timestamps for date buckets and numbers for numeric buckets
+type Bucket struct {
+	From  interface{}  `json:"from"`
+	To    interface{}  `json:"to"`
+	Count int          `json:"count"`
+	Stats *NumberStats `json:"stats,omitempty"` // Over the bucket's documents, when stats are requested
+}
+
+// NumberStats summarizes the values of a number field
+// Percentiles interpolate linearly between the nearest values; StdDev is the population standard deviation
+type NumberStats struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	P50    float64 `json:"p50"`
+	P90    float64 `json:"p90"`
+	P99    float64 `json:"p99"`
+}
+
+// RestoreResponse summarizes restoring a database archive
+type RestoreResponse struct {
+	SchemasCreated  int                        `json:"schemas_created"`
+	SchemasExisting int                        `json:"schemas_existing"` // Already present with the same fields
+	Collections     map[string]*ImportResponse `json:"collections"`
+	Verified        bool                       `json:"verified"`           // Checksums were verified; archives before version 2 have none
+	Problems        []string                   `json:"problems,omitempty"` // Corruption or truncation found; nothing was restored
+	Error           string                     `json:"error,omitempty"`    // Set when the restore stopped early
+}
+
+// MetaResponse describes server limits and capabilities so clients can adapt to them
+type MetaResponse struct {
+	Limits   MetaLimits   `json:"limits"`
+	Features MetaFeatures `json:"features"`
+}
+
+// MetaLimits lists the limits applied to every database
+type MetaLimits struct {
+	MaxCollections     int   `json:"max_collections"`   // 0 means unlimited
+	MaxSchemaFields    int   `json:"max_schema_fields"` // 0 means unlimited
+	DefaultQuotaMB     int64 `json:"default_quota_mb"`
+	ExpiryDays         int   `json:"expiry_days"`
+	MaxBatchOperations int   `json:"max_batch_operations"`
+	MaxDocumentBytes   int64 `json:"max_document_bytes"` // 0 means unlimited
+	MaxBodyBytes       int64 `json:"max_body_bytes"`     // 0 means unlimited; imports are exempt
+	QueryTimeoutMS     int64 `json:"query_timeout_ms"`   // 0 means unlimited
+	MaxRowsScanned     int   `json:"max_rows_scanned"`   // 0 means unlimited
+	MaxDatabases       int   `json:"max_databases"`      // 0 means unlimited
+	CreateRateLimit    int   `json:"create_rate_limit"`  // Databases one address may create per create_rate_window_seconds; 0 means unlimited
+	CreateRateWindow   int64 `json:"create_rate_window_seconds"`
+}
+
+// MetaFeatures lists optional features and supported formats
+type MetaFeatures struct {
+	FieldTypes     []FieldType  `json:"field_types"`
+	Visibilities   []Visibility `json:"visibilities"`
+	ExportFormats  []string     `json:"export_formats"`
+	FullTextSearch bool         `json:"full_text_search"`
+	Analytics      bool         `json:"analytics"` // Built with DuckDB (duckdb build tag)
+	EventVersions  []int        `json:"event_versions"` // Accepted by ?v= on event streams
+	EventTypes     []string     `json:"event_types"`    // Accepted by ?events= on event streams
+	StorageBackend string       `json:"storage_backend"` // "sqlite", "postgres" or "bolt"
+	SQLiteDriver   string       `json:"sqlite_driver,omitempty"` // "mattn" or "modernc" with the sqlite backend
+	SQLite         *SQLiteCapabilities `json:"sqlite,omitempty"` // Features of the SQLite library, with the sqlite backend
+	Languages      []string     `json:"languages"`       // Accept-Language values with translated messages
+	DevMode        bool         `json:"dev_mode"`        // Server errors carry stack traces
+	CreateChallenge string      `json:"create_challenge"` // Challenge passed to create a database: "none", "pow" or "captcha"
+}
+
+// SQLiteCapabilities are the optional SQLite features compiled into the driver in use
+type SQLiteCapabilities struct {
+	FTS5   bool `json:"fts5"`   // Full-text search
+	JSON1  bool `json:"json1"`  // JSON functions, required for collection tables
+	ICU    bool `json:"icu"`    // Unicode collation for sorts (collate=unicode)
+	DBStat bool `json:"dbstat"` // On-disk index sizes in collection stats
+}
+
+// CatalogStats summarizes the catalog for operators, without per-database details
+type CatalogStats struct {
+	Databases      int       `json:"databases"`
+	Schemas        int       `json:"schemas"`
+	QuotaUsed      int64     `json:"quota_used"`  // Sum across databases
+	QuotaLimit     int64     `json:"quota_limit"` // Sum across databases
+	DiskBytes      int64     `json:"disk_bytes"`  // Size of *.db files in the database directory
+	OldestCreated  time.Time `json:"oldest_created"`
+	LatestAccessed time.Time `json:"latest_accessed"`
+	SQLiteVersion  string    `json:"sqlite_version"`
+	SearchEnabled  bool      `json:"search_enabled"`
+	OpenDatabases  int       `json:"open_databases"` // Database files held open by the connection pool
+}
+
+// ServerStats reports the running server's resource use for operators
+type ServerStats struct {
+	StartedAt     time.Time     `json:"started_at"`
+	UptimeSeconds int64         `json:"uptime_seconds"`
+	Goroutines    int           `json:"goroutines"`
+	HeapBytes     uint64        `json:"heap_bytes"`
+	Catalog       *CatalogStats `json:"catalog"`
+}
+
+// SetQuotaLimitRequest is the request to change a database's quota limit
+type SetQuotaLimitRequest struct {
+	QuotaLimit int64 `json:"quota_limit"` // bytes
+}
+
+// FsckProblem is an inconsistency found while checking a database
+type FsckProblem struct {
+	DatabaseID string `json:"database_id"`
+	Problem    string `json:"problem"`
+}
+
+// FsckResponse reports a consistency check of the catalog and every database file
+type FsckResponse struct {
+	Checked  int           `json:"checked"`
+	Problems []FsckProblem `json:"problems"`
+}
+
 // ErrorResponse represents an API error
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message,omitempty"`
+	Code    string `json:"code,omitempty"`  // Stable machine-readable reason, such as a validation error code
+	Field   string `json:"field,omitempty"` // Document field the error is about
+	Limit   int64  `json:"limit,omitempty"` // Limit exceeded: bytes for body_too_large and document_too_large, rows for scan_limit_exceeded, milliseconds for query_timeout
+	Stack   string `json:"stack,omitempty"` // Where a server error was raised, in DEV_MODE only
+
+	Confirmation string `json:"confirmation,omitempty"` // Token to repeat the request with, for confirmation_required
+	Challenge    string `json:"challenge,omitempty"`    // Proof-of-work challenge to solve, for challenge_required
+	Difficulty   int    `json:"difficulty,omitempty"`   // Leading zero bits the challenge's solution must hash to
 }
 
 // ChangeEvent represents a change notification for SSE
@@ -94,5 +789,85 @@ type ChangeEvent struct {
 	Collection string                 `json:"collection"`
 	DocumentID string                 `json:"document_id"`
 	Data       map[string]interface{} `json:"data,omitempty"`
+	OldData    map[string]interface{} `json:"old_data,omitempty"` // The document before an update or delete
+	Visibility    Visibility `json:"visibility,omitempty"`     // Of the document; empty for events logged before it was recorded
+	OldVisibility Visibility `json:"old_visibility,omitempty"` // Of the document before an update
+	Counts     map[string]int         `json:"counts,omitempty"` // Collection -> changes summarized by a bulk_change event
 	Timestamp  time.Time              `json:"timestamp"`
+	ID         uint64                 `json:"-"` // Assigned when broadcast; the SSE event ID clients resume from
+	Seq        uint64                 `json:"seq,omitempty"` // Position in the database's event log, for paging its history
+	Patch      []PatchOperation       `json:"patch,omitempty"` // RFC 6902 JSON Patch from old_data to data, for streams asking with ?patch=
+}
+
+// PatchOperation is one operation of an RFC 6902 JSON Patch
+type PatchOperation struct {
+	Op    string          `json:"op"` // "add", "remove" or "replace"
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"` // Absent for remove; JSON null is kept
+}
+
+// EventHistoryResponse is a page of a database's event log, oldest first
+type EventHistoryResponse struct {
+	Events   []ChangeEvent `json:"events"`
+	Next     uint64        `json:"next"`     // The since to request the following page with
+	HasMore  bool          `json:"has_more"` // More events were logged after this page
+	Complete bool          `json:"complete"` // False when events after since were already pruned
+}
+
+// Credentials an audited request can authenticate with
+const (
+	CredentialWriteKey  = "write_key"
+	CredentialReadKey   = "read_key"
+	CredentialScopedKey = "scoped_key"
+	CredentialToken     = "token"
+)
+
+// AuditEntry records an authenticated request that changes a database, or tried to
+type AuditEntry struct {
+	Seq        uint64    `json:"seq"`
+	Credential string    `json:"credential"` // CredentialWriteKey, CredentialReadKey, CredentialScopedKey or CredentialToken
+	KeyID      string    `json:"key_id"`     // Scoped key or token ID, or a fingerprint of the database key
+	ClientAddr string    `json:"client_addr,omitempty"`
+	Method     string    `json:"method"` // HTTP method, or GRPC for gRPC calls
+	Path       string    `json:"path"`
+	Collection string    `json:"collection,omitempty"`
+	DocumentID string    `json:"document_id,omitempty"`
+	Status     int       `json:"status"` // HTTP status of the response; gRPC codes are mapped to their HTTP equivalents
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// AuditLogResponse is a page of a database's audit log, oldest first
+type AuditLogResponse struct {
+	Entries []AuditEntry `json:"entries"`
+	Next    uint64       `json:"next"`     // The since to request the following page with
+	HasMore bool         `json:"has_more"` // More entries were recorded after this page
+}
+
+// DocumentChange is a document in a collection's change feed, in the state it has now
+type DocumentChange struct {
+	ID        string    `json:"id"`
+	Deleted   bool      `json:"deleted,omitempty"`  // Deleted, or no longer readable with the key
+	Document  *Document `json:"document,omitempty"` // Unless deleted
+	Seq       uint64    `json:"seq"`                // The document's last change in the event log
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// ChangesResponse is a page of a collection's change feed, in the order the documents last changed
+type ChangesResponse struct {
+	Changes  []DocumentChange `json:"changes"`
+	Next     uint64           `json:"next"`     // The since to poll with next
+	HasMore  bool             `json:"has_more"` // More documents changed after this page
+	Complete bool             `json:"complete"` // False when changes after since were already pruned
+}
+
+// AckEventsRequest acknowledges that the client of an event stream is alive
+type AckEventsRequest struct {
+	ListenerID string `json:"listener_id"` // From the stream's connected event
+}
+
+// EventEnvelope wraps a ChangeEvent with the event format version the client subscribed to
+// Within a version fields and event types are only ever added; anything else bumps V
+type EventEnvelope struct {
+	V     int         `json:"v"`
+	Event ChangeEvent `json:"event"`
 }
@@ -1,16 +1,62 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
 )
 
+// Validation error codes are stable, so clients can translate or handle them without parsing messages
+const (
+	ValidationUnknownField     = "unknown_field"      // The field isn't in the schema
+	ValidationMissingField     = "missing_field"      // A schema field is absent
+	ValidationInvalidType      = "invalid_type"       // The value isn't of the field's type
+	ValidationUnknownFieldType = "unknown_field_type" // The schema names a type that doesn't exist
+	ValidationRetiredField     = "retired_field"      // The field is deprecated and its grace period is over
+)
+
+// ValidationError describes why a document doesn't match its schema
+type ValidationError struct {
+	Code     string
+	Field    string
+	Expected FieldType // Field type, for invalid_type and unknown_field_type
+	Got      string    // Go type of the value, for invalid_type
+}
+
+// Error returns the English message for the error
+func (e *ValidationError) Error() string {
+	switch e.Code {
+	case ValidationUnknownField:
+		return fmt.Sprintf("field '%s' is not defined in schema", e.Field)
+	case ValidationMissingField:
+		return fmt.Sprintf("required field '%s' is missing", e.Field)
+	case ValidationInvalidType:
+		return fmt.Sprintf("field '%s' must be %s, got %s", e.Field, typeArticle[e.Expected], e.Got)
+	case ValidationRetiredField:
+		return fmt.Sprintf("field '%s' is deprecated and can no longer be written", e.Field)
+	default:
+		return fmt.Sprintf("unknown field type: %s", e.Expected)
+	}
+}
+
+// typeArticle names each field type in English messages
+var typeArticle = map[FieldType]string{
+	FieldTypeString: "a string",
+	FieldTypeNumber: "a number",
+	FieldTypeBool:   "a boolean",
+}
+
 // ValidateDocument validates a document's data against a schema
+// Errors are *ValidationError
 func ValidateDocument(data map[string]interface{}, schema *Schema) error {
 	// Check that all fields in data match the schema
 	for fieldName, value := range data {
 		fieldType, exists := schema.Fields[fieldName]
 		if !exists {
-			return fmt.Errorf("field '%s' is not defined in schema", fieldName)
+			return &ValidationError{Code: ValidationUnknownField, Field: fieldName}
+		}
+		if deprecation := schema.Deprecated[fieldName]; deprecation != nil && deprecation.Retired(time.Now()) {
+			return &ValidationError{Code: ValidationRetiredField, Field: fieldName}
 		}
 
 		if err := validateFieldValue(fieldName, value, fieldType); err != nil {
@@ -18,10 +64,10 @@ func ValidateDocument(data map[string]interface{}, schema *Schema) error {
 		}
 	}
 
-	// All fields must be present (no optional fields for now)
+	// All fields must be present, except deprecated ones
 	for fieldName := range schema.Fields {
-		if _, exists := data[fieldName]; !exists {
-			return fmt.Errorf("required field '%s' is missing", fieldName)
+		if _, exists := data[fieldName]; !exists && schema.Deprecated[fieldName] == nil {
+			return &ValidationError{Code: ValidationMissingField, Field: fieldName}
 		}
 	}
 
@@ -30,26 +76,51 @@ func ValidateDocument(data map[string]interface{}, schema *Schema) error {
 
 // validateFieldValue validates a single field value against its type
 func validateFieldValue(fieldName string, value interface{}, expectedType FieldType) error {
+	valid := false
 	switch expectedType {
 	case FieldTypeString:
-		if _, ok := value.(string); !ok {
-			return fmt.Errorf("field '%s' must be a string, got %T", fieldName, value)
-		}
+		_, valid = value.(string)
 	case FieldTypeNumber:
 		// JSON numbers can be float64 or int
 		switch value.(type) {
 		case float64, int, int64, float32:
-			return nil
-		default:
-			return fmt.Errorf("field '%s' must be a number, got %T", fieldName, value)
+			valid = true
 		}
 	case FieldTypeBool:
-		if _, ok := value.(bool); !ok {
-			return fmt.Errorf("field '%s' must be a boolean, got %T", fieldName, value)
-		}
+		_, valid = value.(bool)
 	default:
-		return fmt.Errorf("unknown field type: %s", expectedType)
+		return &ValidationError{Code: ValidationUnknownFieldType, Field: fieldName, Expected: expectedType}
+	}
+
+	if !valid {
+		return &ValidationError{Code: ValidationInvalidType, Field: fieldName, Expected: expectedType, Got: fmt.Sprintf("%T", value)}
 	}
+	return nil
+}
+
+// DocumentTooLargeError reports a document whose JSON is over the size limit
+type DocumentTooLargeError struct {
+	Size  int64
+	Limit int64
+}
+
+// Error returns the English message for the error
+func (e *DocumentTooLargeError) Error() string {
+	return fmt.Sprintf("document too large: %d bytes exceeds the limit of %d", e.Size, e.Limit)
+}
 
+// CheckDocumentSize fails with a *DocumentTooLargeError when a document's data, as JSON,
+// is larger than limit bytes; a limit of 0 allows any size
+func CheckDocumentSize(data map[string]interface{}, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document data: %w", err)
+	}
+	if size := int64(len(dataJSON)); size > limit {
+		return &DocumentTooLargeError{Size: size, Limit: limit}
+	}
 	return nil
 }
@@ -0,0 +1,421 @@
+// Package oidc signs people in with an OpenID Connect provider, using the authorization
+// code flow with PKCE, and verifies the ID tokens the provider returns
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxResponseBytes caps the provider responses read
+const maxResponseBytes = 1 << 20
+
+// keyRefreshInterval is how often the provider's keys may be fetched again when a token
+// is signed with a key not seen yet
+const keyRefreshInterval = time.Minute
+
+// clockSkew is how far the provider's clock may be ahead of or behind this server's
+const clockSkew = time.Minute
+
+// Identity is who signed in
+type Identity struct {
+	Subject string // Provider's stable identifier of the person
+	Email   string // Empty unless the provider verified it
+	Name    string
+}
+
+// Provider signs people in with one OpenID Connect provider as one client
+// Its configuration and keys are fetched when first needed, so the server starts even
+// while the provider is unreachable
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	client       *http.Client
+
+	mu          sync.Mutex
+	metadata    *metadata
+	keys        map[string]crypto.PublicKey // By key ID
+	keysFetched time.Time
+}
+
+// metadata is the part of the provider's discovery document used
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewProvider creates a provider for issuer, signing in as clientID with redirectURL as
+// the callback; clientSecret is empty for public clients
+func NewProvider(issuer string, clientID string, clientSecret string, redirectURL string) *Provider {
+	return &Provider{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Challenge returns the PKCE code challenge of a verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthURL returns where to send a browser to sign in. The provider sends it back to the
+// redirect URL with state and a code; nonce is expected in the ID token, and verifier is
+// the PKCE secret the code is exchanged with
+func (p *Provider) AuthURL(ctx context.Context, state string, nonce string, verifier string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientID},
+		"redirect_uri":          {p.redirectURL},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return md.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems the code a browser came back with and verifies the ID token it is
+// exchanged for, returning who signed in
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string, nonce string) (*Identity, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.clientSecret == "" {
+		form.Set("client_id", p.clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	var response struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.do(req, &response); err != nil && response.Error == "" {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("failed to exchange code: %s %s", response.Error, response.ErrorDescription)
+	}
+	if response.IDToken == "" {
+		return nil, fmt.Errorf("failed to exchange code: no ID token in the response")
+	}
+	return p.Verify(ctx, response.IDToken, nonce, time.Now())
+}
+
+// Verify checks an ID token's signature, issuer, audience, expiry and nonce and returns
+// who it identifies. Errors for tokens failing verification start with "invalid"
+func (p *Provider) Verify(ctx context.Context, idToken string, nonce string, now time.Time) (*Identity, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid ID token: malformed")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid ID token: malformed header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: malformed signature")
+	}
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := p.key(ctx, md, header.Kid, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		Issuer        string          `json:"iss"`
+		Subject       string          `json:"sub"`
+		Audience      audience        `json:"aud"`
+		AuthorizedBy  string          `json:"azp"`
+		ExpiresAt     int64           `json:"exp"`
+		Nonce         string          `json:"nonce"`
+		Email         string          `json:"email"`
+		EmailVerified json.RawMessage `json:"email_verified"`
+		Name          string          `json:"name"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid ID token: malformed claims")
+	}
+	switch {
+	case claims.Issuer != md.Issuer:
+		return nil, fmt.Errorf("invalid ID token: issued by %q", claims.Issuer)
+	case !slices.Contains(claims.Audience, p.clientID) || (len(claims.Audience) > 1 && claims.AuthorizedBy != p.clientID):
+		return nil, fmt.Errorf("invalid ID token: issued to another client")
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)):
+		return nil, fmt.Errorf("invalid ID token: expired")
+	case claims.Nonce != nonce:
+		return nil, fmt.Errorf("invalid ID token: nonce mismatch")
+	case claims.Subject == "":
+		return nil, fmt.Errorf("invalid ID token: no subject")
+	}
+
+	identity := &Identity{Subject: claims.Subject, Name: claims.Name}
+	// Some providers send email_verified as a string
+	if verified := string(claims.EmailVerified); verified == "true" || verified == `"true"` {
+		identity.Email = claims.Email
+	}
+	return identity, nil
+}
+
+// audience is the aud claim, a single client or a list of them
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// verifySignature checks a token's signature with the key it names, accepting the RSA
+// and ECDSA algorithms providers sign with
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("invalid ID token: unsupported algorithm %q", alg)
+	}
+	var digest []byte
+	switch hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256([]byte(signed))
+		digest = sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384([]byte(signed))
+		digest = sum[:]
+	default:
+		sum := sha512.Sum512([]byte(signed))
+		digest = sum[:]
+	}
+
+	valid := false
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		valid = strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if strings.HasPrefix(alg, "ES") && len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(key, digest, r, s)
+		}
+	}
+	if !valid {
+		return fmt.Errorf("invalid ID token: bad signature")
+	}
+	return nil
+}
+
+// discover fetches the provider's configuration, once it has been fetched successfully
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var md metadata
+	if err := p.get(ctx, p.issuer+"/.well-known/openid-configuration", &md); err != nil {
+		return nil, fmt.Errorf("failed to discover OpenID provider: %w", err)
+	}
+	if strings.TrimSuffix(md.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("failed to discover OpenID provider: it names itself %q", md.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, fmt.Errorf("failed to discover OpenID provider: endpoints missing from its configuration")
+	}
+	p.metadata = &md
+	return p.metadata, nil
+}
+
+// key returns the provider key with an ID, fetching the keys again when it isn't known
+// and they weren't fetched recently
+func (p *Provider) key(ctx context.Context, md *metadata, kid string, now time.Time) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if now.Sub(p.keysFetched) < keyRefreshInterval {
+		return nil, fmt.Errorf("invalid ID token: unknown signing key %q", kid)
+	}
+	keys, err := p.fetchKeys(ctx, md.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys, p.keysFetched = keys, now
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// Providers with a single key may leave its ID out of tokens
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid ID token: unknown signing key %q", kid)
+}
+
+// fetchKeys reads the provider's signing keys from its JWK set
+func (p *Provider) fetchKeys(ctx context.Context, jwksURI string) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := p.get(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch OpenID provider keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch jwk.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch jwk.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return keys, nil
+}
+
+// get fetches a JSON document from the provider
+func (p *Provider) get(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	return p.do(req, v)
+}
+
+// do sends a request to the provider and decodes its JSON response, which is decoded
+// even when the status is an error, for the error's details
+func (p *Provider) do(req *http.Request, v any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decodeErr := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(v)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded %s", req.URL.Redacted(), resp.Status)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("malformed response from %s: %w", req.URL.Redacted(), decodeErr)
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,197 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProvider is an OpenID provider signing ID tokens with an RSA and an ECDSA key
+type testProvider struct {
+	*httptest.Server
+	rsaKey   *rsa.PrivateKey
+	ecKey    *ecdsa.PrivateKey
+	idToken  string // Returned by the token endpoint
+	lastForm url.Values
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+	p := &testProvider{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa1", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": encode(ecKey.X.FillBytes(make([]byte, 32))), "y": encode(ecKey.Y.FillBytes(make([]byte, 32)))},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		p.lastForm = r.PostForm
+		if id, secret, _ := r.BasicAuth(); id != "console" || secret != "s3cret" || r.PostForm.Get("code") != "good" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": p.idToken})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// sign mints an ID token with the given algorithm ("RS256" or "ES256"), key ID and claims
+func (p *testProvider) sign(t *testing.T, alg string, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	var err error
+	if alg == "ES256" {
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, p.ecKey, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, p.rsaKey, crypto.SHA256, digest[:])
+	}
+	if err != nil {
+		t.Fatalf("signing error = %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// claims returns valid claims for the console client, with overrides
+func (p *testProvider) claims(now time.Time, overrides map[string]any) map[string]any {
+	claims := map[string]any{
+		"iss":            p.URL,
+		"sub":            "user-1",
+		"aud":            "console",
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          "n0nce",
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"name":           "Ada",
+	}
+	for key, value := range overrides {
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+	}
+	return claims
+}
+
+func TestProvider_AuthURLExchange(t *testing.T) {
+	idp := newTestProvider(t)
+	p := NewProvider(idp.URL+"/", "console", "s3cret", "https://api.example.com/console/callback")
+	ctx := context.Background()
+
+	authURL, err := p.AuthURL(ctx, "st4te", "n0nce", "verifier")
+	if err != nil {
+		t.Fatalf("AuthURL() error = %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	query := u.Query()
+	if !strings.HasPrefix(authURL, idp.URL+"/authorize?") || query.Get("client_id") != "console" || query.Get("state") != "st4te" ||
+		query.Get("nonce") != "n0nce" || query.Get("code_challenge") != Challenge("verifier") || query.Get("code_challenge_method") != "S256" ||
+		query.Get("redirect_uri") != "https://api.example.com/console/callback" || !strings.Contains(query.Get("scope"), "openid") {
+		t.Errorf("AuthURL() = %s, want an authorization request for the console", authURL)
+	}
+
+	idp.idToken = idp.sign(t, "RS256", "rsa1", idp.claims(time.Now(), nil))
+	identity, err := p.Exchange(ctx, "good", "verifier", "n0nce")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if *identity != (Identity{Subject: "user-1", Email: "ada@example.com", Name: "Ada"}) {
+		t.Errorf("Exchange() = %+v, want Ada", identity)
+	}
+	if idp.lastForm.Get("code_verifier") != "verifier" || idp.lastForm.Get("grant_type") != "authorization_code" {
+		t.Errorf("token request = %v, want the code and its verifier", idp.lastForm)
+	}
+
+	if _, err := p.Exchange(ctx, "bad", "verifier", "n0nce"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Exchange(bad code) error = %v, want invalid_grant", err)
+	}
+}
+
+func TestProvider_Verify(t *testing.T) {
+	idp := newTestProvider(t)
+	p := NewProvider(idp.URL, "console", "", "https://api.example.com/console/callback")
+	ctx := context.Background()
+	now := time.Now()
+
+	identity, err := p.Verify(ctx, idp.sign(t, "ES256", "ec1", idp.claims(now, map[string]any{"email_verified": "false"})), "n0nce", now)
+	if err != nil {
+		t.Fatalf("Verify(ES256) error = %v", err)
+	}
+	if identity.Subject != "user-1" || identity.Email != "" {
+		t.Errorf("Verify() = %+v, want user-1 without the unverified email", identity)
+	}
+	if _, err := p.Verify(ctx, idp.sign(t, "RS256", "rsa1", idp.claims(now, map[string]any{"aud": []string{"console", "other"}, "azp": "console"})), "n0nce", now); err != nil {
+		t.Errorf("Verify(several audiences) error = %v", err)
+	}
+
+	other := newTestProvider(t)
+	signed := idp.sign(t, "RS256", "rsa1", idp.claims(now, nil))
+	unsigned := signed[:strings.LastIndex(signed, ".")+1]
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong nonce", idp.sign(t, "RS256", "rsa1", idp.claims(now, map[string]any{"nonce": "other"}))},
+		{"other audience", idp.sign(t, "RS256", "rsa1", idp.claims(now, map[string]any{"aud": "other"}))},
+		{"authorized for another", idp.sign(t, "RS256", "rsa1", idp.claims(now, map[string]any{"aud": []string{"console", "other"}, "azp": "other"}))},
+		{"other issuer", idp.sign(t, "RS256", "rsa1", idp.claims(now, map[string]any{"iss": other.URL}))},
+		{"expired", idp.sign(t, "RS256", "rsa1", idp.claims(now, map[string]any{"exp": now.Add(-time.Hour).Unix()}))},
+		{"no subject", idp.sign(t, "RS256", "rsa1", idp.claims(now, map[string]any{"sub": nil}))},
+		{"other key", other.sign(t, "RS256", "rsa1", idp.claims(now, nil))},
+		{"algorithm of another key", idp.sign(t, "ES256", "rsa1", idp.claims(now, nil))},
+		{"unknown key", idp.sign(t, "RS256", "rsa2", idp.claims(now, nil))},
+		{"unsigned", unsigned},
+		{"malformed", "not.a-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.Verify(ctx, tt.token, "n0nce", now); err == nil || !strings.HasPrefix(err.Error(), "invalid") {
+				t.Errorf("Verify() error = %v, want an invalid ID token", err)
+			}
+		})
+	}
+}
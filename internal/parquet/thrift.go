@@ -0,0 +1,138 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Parquet metadata is serialized with Thrift's compact protocol; only the parts
+// needed to write file metadata and page headers are implemented here
+
+// Compact protocol type codes
+const (
+	thriftBoolTrue  byte = 1
+	thriftBoolFalse byte = 2
+	thriftI32       byte = 5
+	thriftI64       byte = 6
+	thriftBinary    byte = 8
+	thriftList      byte = 9
+	thriftStruct    byte = 12
+)
+
+// thriftWriter encodes Thrift structs with the compact protocol
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID []int16 // Last field ID written, per open struct
+}
+
+// newThriftWriter starts encoding a top-level struct
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastID: []int16{0}}
+}
+
+// Bytes returns the encoded struct, closing it
+func (t *thriftWriter) Bytes() []byte {
+	t.buf.WriteByte(0) // Stop field
+	return t.buf.Bytes()
+}
+
+// fieldHeader writes a field's ID, as a delta from the previous field when it fits, and type
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &t.lastID[len(t.lastID)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+// varint writes an unsigned LEB128 integer
+func (t *thriftWriter) varint(v uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], v)
+	t.buf.Write(scratch[:n])
+}
+
+// zigzag maps signed integers to unsigned ones so small magnitudes stay short
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// bool writes a boolean field; the value is carried in the type code
+func (t *thriftWriter) bool(id int16, v bool) {
+	if v {
+		t.fieldHeader(id, thriftBoolTrue)
+	} else {
+		t.fieldHeader(id, thriftBoolFalse)
+	}
+}
+
+// i32 writes a 32-bit integer field
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+// i64 writes a 64-bit integer field
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+// string writes a string field
+func (t *thriftWriter) string(id int16, v string) {
+	t.fieldHeader(id, thriftBinary)
+	t.stringValue(v)
+}
+
+// stringValue writes a string without a field header, as in list elements
+func (t *thriftWriter) stringValue(v string) {
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+// listHeader writes a list field's header; the caller then writes size elements
+func (t *thriftWriter) listHeader(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xf0 | elemType)
+		t.varint(uint64(size))
+	}
+}
+
+// i32List writes a list of 32-bit integers
+func (t *thriftWriter) i32List(id int16, values []int32) {
+	t.listHeader(id, thriftI32, len(values))
+	for _, v := range values {
+		t.varint(zigzag(int64(v)))
+	}
+}
+
+// stringList writes a list of strings
+func (t *thriftWriter) stringList(id int16, values []string) {
+	t.listHeader(id, thriftBinary, len(values))
+	for _, v := range values {
+		t.stringValue(v)
+	}
+}
+
+// beginStruct opens a struct field; fields written until endStruct belong to it
+func (t *thriftWriter) beginStruct(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.beginElement()
+}
+
+// beginElement opens a struct written as a list element, which has no field header
+func (t *thriftWriter) beginElement() {
+	t.lastID = append(t.lastID, 0)
+}
+
+// endStruct closes the innermost open struct
+func (t *thriftWriter) endStruct() {
+	t.buf.WriteByte(0) // Stop field
+	t.lastID = t.lastID[:len(t.lastID)-1]
+}
@@ -0,0 +1,80 @@
+package parquet
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestZigzag(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want uint64
+	}{
+		{0, 0},
+		{-1, 1},
+		{1, 2},
+		{-2, 3},
+		{2147483647, 4294967294},
+		{-2147483648, 4294967295},
+	}
+	for _, tt := range tests {
+		if got := zigzag(tt.in); got != tt.want {
+			t.Errorf("zigzag(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestThriftWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(*thriftWriter)
+		want  []byte
+	}{
+		{
+			name:  "short field delta",
+			write: func(w *thriftWriter) { w.i32(1, 3) },
+			want:  []byte{0x15, 0x06, 0x00},
+		},
+		{
+			name:  "long field delta",
+			write: func(w *thriftWriter) { w.i64(20, 1) },
+			want:  []byte{0x06, 0x28, 0x02, 0x00},
+		},
+		{
+			name:  "bool in type",
+			write: func(w *thriftWriter) { w.bool(1, true); w.bool(2, false) },
+			want:  []byte{0x11, 0x12, 0x00},
+		},
+		{
+			name:  "string",
+			write: func(w *thriftWriter) { w.string(4, "id") },
+			want:  []byte{0x48, 0x02, 'i', 'd', 0x00},
+		},
+		{
+			name:  "list",
+			write: func(w *thriftWriter) { w.i32List(2, []int32{0, 3}) },
+			want:  []byte{0x29, 0x25, 0x00, 0x06, 0x00},
+		},
+		{
+			name: "nested struct restarts field ids",
+			write: func(w *thriftWriter) {
+				w.i32(1, 0)
+				w.beginStruct(5)
+				w.i32(1, 1)
+				w.endStruct()
+				w.i32(6, 0)
+			},
+			want: []byte{0x15, 0x00, 0x4c, 0x15, 0x02, 0x00, 0x15, 0x00, 0x00},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newThriftWriter()
+			tt.write(w)
+			if got := w.Bytes(); !bytes.Equal(got, tt.want) {
+				t.Errorf("encoded % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Type is the type of a column's values
+type Type int
+
+const (
+	String    Type = iota // UTF-8 text; values are string
+	Double                // 64-bit floats; values are float64
+	Bool                  // Booleans; values are bool
+	Timestamp             // Milliseconds since the epoch in UTC; values are time.Time
+)
+
+// Column describes a column of a flat table
+// Optional columns store nil, and values of the wrong type, as null
+type Column struct {
+	Name     string
+	Type     Type
+	Optional bool
+}
+
+// rowGroupSize is the number of rows buffered before they are written as a row group
+const rowGroupSize = 10000
+
+// Parquet format enum values
+const (
+	typeBoolean   int32 = 0
+	typeInt64     int32 = 2
+	typeDouble    int32 = 5
+	typeByteArray int32 = 6
+
+	repetitionRequired int32 = 0
+	repetitionOptional int32 = 1
+
+	convertedUTF8            int32 = 0
+	convertedTimestampMillis int32 = 9
+
+	encodingPlain int32 = 0
+	encodingRLE   int32 = 3
+
+	codecGzip int32 = 2
+
+	pageTypeData int32 = 0
+)
+
+// magic starts and ends every Parquet file
+var magic = []byte("PAR1")
+
+// createdBy identifies the writer in file metadata
+const createdBy = "jsondrop"
+
+// Writer streams rows into a Parquet file
+// Each row group is one gzip-compressed, PLAIN-encoded data page per column. The footer
+// locating the row groups is written by Close; the file can't be read before that
+type Writer struct {
+	out       io.Writer
+	offset    int64
+	columns   []Column
+	buffers   []columnBuffer
+	rows      int // Rows buffered for the current row group
+	numRows   int64
+	rowGroups []rowGroup
+}
+
+// columnBuffer holds a column's values for the current row group
+type columnBuffer struct {
+	values  bytes.Buffer // PLAIN-encoded values, except booleans
+	bools   []bool       // Boolean values, bit-packed when the page is written
+	defined []bool       // Whether each row has a value; optional columns only
+}
+
+// rowGroup records where a written row group's column chunks are
+type rowGroup struct {
+	chunks   []columnChunk
+	numRows  int64
+	byteSize int64
+}
+
+// columnChunk records a written column chunk
+type columnChunk struct {
+	offset           int64
+	numValues        int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+// NewWriter starts a Parquet file with the given columns
+func NewWriter(out io.Writer, columns []Column) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("parquet: no columns")
+	}
+
+	w := &Writer{
+		out:     out,
+		columns: columns,
+		buffers: make([]columnBuffer, len(columns)),
+	}
+	if err := w.write(magic); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// write writes to the output, tracking the file offset
+func (w *Writer) write(p []byte) error {
+	n, err := w.out.Write(p)
+	w.offset += int64(n)
+	return err
+}
+
+// Write adds a row with one value per column
+func (w *Writer) Write(row []interface{}) error {
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("parquet: row has %d values, want %d", len(row), len(w.columns))
+	}
+
+	for i, column := range w.columns {
+		if !column.Optional && !matches(column.Type, row[i]) {
+			return fmt.Errorf("parquet: column %s requires a value, got %T", column.Name, row[i])
+		}
+	}
+
+	for i, column := range w.columns {
+		buf := &w.buffers[i]
+		defined := matches(column.Type, row[i])
+		if column.Optional {
+			buf.defined = append(buf.defined, defined)
+		}
+		if defined {
+			buf.append(row[i])
+		}
+	}
+
+	w.rows++
+	if w.rows >= rowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// matches reports whether a value is of a column type; nil never is
+func matches(typ Type, value interface{}) bool {
+	switch value.(type) {
+	case string:
+		return typ == String
+	case float64:
+		return typ == Double
+	case bool:
+		return typ == Bool
+	case time.Time:
+		return typ == Timestamp
+	default:
+		return false
+	}
+}
+
+// append encodes a value that matches the column's type
+func (buf *columnBuffer) append(value interface{}) {
+	var scratch [8]byte
+	switch v := value.(type) {
+	case string:
+		binary.LittleEndian.PutUint32(scratch[:4], uint32(len(v)))
+		buf.values.Write(scratch[:4])
+		buf.values.WriteString(v)
+	case float64:
+		binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(v))
+		buf.values.Write(scratch[:])
+	case bool:
+		buf.bools = append(buf.bools, v)
+	case time.Time:
+		binary.LittleEndian.PutUint64(scratch[:], uint64(v.UnixMilli()))
+		buf.values.Write(scratch[:])
+	}
+}
+
+// flush writes the buffered rows as a row group
+func (w *Writer) flush() error {
+	group := rowGroup{numRows: int64(w.rows)}
+
+	for i, column := range w.columns {
+		buf := &w.buffers[i]
+
+		var page bytes.Buffer
+		if column.Optional {
+			page.Write(encodeLevels(buf.defined))
+		}
+		if column.Type == Bool {
+			page.Write(packBits(buf.bools))
+		} else {
+			page.Write(buf.values.Bytes())
+		}
+
+		chunk, err := w.writePage(page.Bytes(), w.rows)
+		if err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.byteSize += chunk.uncompressedSize
+
+		buf.values.Reset()
+		buf.bools = buf.bools[:0]
+		buf.defined = buf.defined[:0]
+	}
+
+	w.rowGroups = append(w.rowGroups, group)
+	w.numRows += int64(w.rows)
+	w.rows = 0
+	return nil
+}
+
+// writePage compresses and writes a data page with its header, as a column chunk
+func (w *Writer) writePage(page []byte, numValues int) (columnChunk, error) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(page); err != nil {
+		return columnChunk{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return columnChunk{}, err
+	}
+
+	t := newThriftWriter()
+	t.i32(1, pageTypeData)
+	t.i32(2, int32(len(page)))
+	t.i32(3, int32(compressed.Len()))
+	t.beginStruct(5)
+	t.i32(1, int32(numValues))
+	t.i32(2, encodingPlain)
+	t.i32(3, encodingRLE)
+	t.i32(4, encodingRLE)
+	t.endStruct()
+	header := t.Bytes()
+
+	chunk := columnChunk{
+		offset:           w.offset,
+		numValues:        int64(numValues),
+		uncompressedSize: int64(len(header) + len(page)),
+		compressedSize:   int64(len(header) + compressed.Len()),
+	}
+	if err := w.write(header); err != nil {
+		return columnChunk{}, err
+	}
+	if err := w.write(compressed.Bytes()); err != nil {
+		return columnChunk{}, err
+	}
+	return chunk, nil
+}
+
+// encodeLevels encodes definition levels as a length-prefixed, bit-packed RLE hybrid run
+func encodeLevels(defined []bool) []byte {
+	var run bytes.Buffer
+	var scratch [binary.MaxVarintLen64]byte
+	groups := (len(defined) + 7) / 8
+	n := binary.PutUvarint(scratch[:], uint64(groups)<<1|1)
+	run.Write(scratch[:n])
+	run.Write(packBits(defined))
+
+	out := make([]byte, 4, 4+run.Len())
+	binary.LittleEndian.PutUint32(out, uint32(run.Len()))
+	return append(out, run.Bytes()...)
+}
+
+// packBits packs booleans one bit each, least significant bit first
+func packBits(values []bool) []byte {
+	packed := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return packed
+}
+
+// Close writes any buffered rows and the file footer
+// It does not close the underlying writer
+func (w *Writer) Close() error {
+	if w.rows > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+
+	footer := w.fileMetadata()
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	if err := w.write(length[:]); err != nil {
+		return err
+	}
+	return w.write(magic)
+}
+
+// fileMetadata encodes the FileMetaData struct describing the schema and row groups
+func (w *Writer) fileMetadata() []byte {
+	t := newThriftWriter()
+	t.i32(1, 1) // Format version
+
+	t.listHeader(2, thriftStruct, len(w.columns)+1)
+	t.beginElement()
+	t.string(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.endStruct()
+	for _, column := range w.columns {
+		t.beginElement()
+		t.i32(1, physicalType(column.Type))
+		if column.Optional {
+			t.i32(3, repetitionOptional)
+		} else {
+			t.i32(3, repetitionRequired)
+		}
+		t.string(4, column.Name)
+		if converted, ok := convertedType(column.Type); ok {
+			t.i32(6, converted)
+		}
+		t.endStruct()
+	}
+
+	t.i64(3, w.numRows)
+
+	t.listHeader(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		t.beginElement()
+		t.listHeader(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			column := w.columns[i]
+			encodings := []int32{encodingPlain}
+			if column.Optional {
+				encodings = append(encodings, encodingRLE)
+			}
+
+			t.beginElement()
+			t.i64(2, chunk.offset)
+			t.beginStruct(3)
+			t.i32(1, physicalType(column.Type))
+			t.i32List(2, encodings)
+			t.stringList(3, []string{column.Name})
+			t.i32(4, codecGzip)
+			t.i64(5, chunk.numValues)
+			t.i64(6, chunk.uncompressedSize)
+			t.i64(7, chunk.compressedSize)
+			t.i64(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, group.byteSize)
+		t.i64(3, group.numRows)
+		t.endStruct()
+	}
+
+	t.string(6, createdBy)
+	return t.Bytes()
+}
+
+// physicalType returns the Parquet storage type for a column type
+func physicalType(typ Type) int32 {
+	switch typ {
+	case Double:
+		return typeDouble
+	case Bool:
+		return typeBoolean
+	case Timestamp:
+		return typeInt64
+	default:
+		return typeByteArray
+	}
+}
+
+// convertedType returns the annotation telling readers how to interpret a column type
+func convertedType(typ Type) (int32, bool) {
+	switch typ {
+	case String:
+		return convertedUTF8, true
+	case Timestamp:
+		return convertedTimestampMillis, true
+	default:
+		return 0, false
+	}
+}
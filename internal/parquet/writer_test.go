@@ -0,0 +1,219 @@
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+func TestPackBits(t *testing.T) {
+	got := packBits([]bool{true, false, true, true, false, false, false, false, true})
+	want := []byte{0x0d, 0x01}
+	if !bytes.Equal(got, want) {
+		t.Errorf("packBits() = % x, want % x", got, want)
+	}
+}
+
+func TestEncodeLevels(t *testing.T) {
+	got := encodeLevels([]bool{true, false, true})
+	// 4-byte length, run header for one bit-packed group, then the packed levels
+	want := []byte{0x02, 0x00, 0x00, 0x00, 0x03, 0x05}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeLevels() = % x, want % x", got, want)
+	}
+}
+
+func TestWriter_FileLayout(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, []Column{
+		{Name: "id", Type: String},
+		{Name: "created_at", Type: Timestamp},
+		{Name: "score", Type: Double, Optional: true},
+		{Name: "done", Type: Bool, Optional: true},
+	})
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	rows := [][]interface{}{
+		{"doc_1", time.Unix(1700000000, 0), 1.5, true},
+		{"doc_2", time.Unix(1700000001, 0), nil, "not a bool"},
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	file := out.Bytes()
+	if !bytes.HasPrefix(file, magic) || !bytes.HasSuffix(file, magic) {
+		t.Fatal("file does not start and end with PAR1")
+	}
+
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8 : len(file)-4]))
+	footer := file[len(file)-8-footerLen : len(file)-8]
+	if !bytes.Equal(footer, w.fileMetadata()) {
+		t.Error("footer length does not locate the file metadata")
+	}
+
+	if w.numRows != 2 || len(w.rowGroups) != 1 {
+		t.Fatalf("wrote %d rows in %d row groups, want 2 in 1", w.numRows, len(w.rowGroups))
+	}
+	chunks := w.rowGroups[0].chunks
+	if chunks[0].offset != int64(len(magic)) {
+		t.Errorf("first column chunk at %d, want %d", chunks[0].offset, len(magic))
+	}
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].offset != chunks[i-1].offset+chunks[i-1].compressedSize {
+			t.Errorf("column chunk %d at %d, want it right after chunk %d", i, chunks[i].offset, i-1)
+		}
+	}
+}
+
+func TestWriter_RowGroups(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, []Column{{Name: "id", Type: String}})
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	for i := 0; i < rowGroupSize+1; i++ {
+		if err := w.Write([]interface{}{"doc"}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if len(w.rowGroups) != 2 || w.rowGroups[1].numRows != 1 {
+		t.Errorf("wrote %d row groups, want a full one and one with the remaining row", len(w.rowGroups))
+	}
+}
+
+// TestWriter_ReadBack reads a file back with the Apache Arrow Parquet reader, an
+// implementation independent of this one
+func TestWriter_ReadBack(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, []Column{
+		{Name: "id", Type: String},
+		{Name: "created_at", Type: Timestamp},
+		{Name: "score", Type: Double, Optional: true},
+		{Name: "done", Type: Bool, Optional: true},
+	})
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+
+	// Enough rows for two row groups, with nulls and a wrong type in the optional columns
+	base := time.UnixMilli(1700000000123).UTC()
+	rows := make([][]interface{}, rowGroupSize+3)
+	for i := range rows {
+		var score, done interface{} = float64(i) / 4, i%2 == 0
+		if i%3 == 0 {
+			score = nil
+		}
+		if i%5 == 0 {
+			done = "not a bool"
+		}
+		rows[i] = []interface{}{fmt.Sprintf("doc_%d_é", i), base.Add(time.Duration(i) * time.Millisecond), score, done}
+		if err := w.Write(rows[i]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	table, err := pqarrow.ReadTable(context.Background(), bytes.NewReader(out.Bytes()), nil, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		t.Fatalf("ReadTable() error = %v", err)
+	}
+	defer table.Release()
+
+	schema := table.Schema()
+	wantFields := []arrow.Field{
+		{Name: "id", Type: arrow.BinaryTypes.String},
+		{Name: "created_at", Type: &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}},
+		{Name: "score", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+		{Name: "done", Type: arrow.FixedWidthTypes.Boolean, Nullable: true},
+	}
+	if len(schema.Fields()) != len(wantFields) {
+		t.Fatalf("read %d columns, want %d", len(schema.Fields()), len(wantFields))
+	}
+	for i, want := range wantFields {
+		got := schema.Field(i)
+		if got.Name != want.Name || !arrow.TypeEqual(got.Type, want.Type) || got.Nullable != want.Nullable {
+			t.Errorf("column %d = %s %s nullable %v, want %s %s nullable %v", i, got.Name, got.Type, got.Nullable, want.Name, want.Type, want.Nullable)
+		}
+	}
+	if table.NumRows() != int64(len(rows)) {
+		t.Fatalf("read %d rows, want %d", table.NumRows(), len(rows))
+	}
+
+	// Values are compared row by row across the chunks of each column
+	for col := 0; col < int(table.NumCols()); col++ {
+		row := 0
+		for _, chunk := range table.Column(col).Data().Chunks() {
+			for i := 0; i < chunk.Len(); i, row = i+1, row+1 {
+				var got interface{}
+				if chunk.IsValid(i) {
+					switch values := chunk.(type) {
+					case *array.String:
+						got = values.Value(i)
+					case *array.Timestamp:
+						got = values.Value(i).ToTime(arrow.Millisecond)
+					case *array.Float64:
+						got = values.Value(i)
+					case *array.Boolean:
+						got = values.Value(i)
+					default:
+						t.Fatalf("column %d read as %T", col, chunk)
+					}
+				}
+				want := rows[row][col]
+				if _, wrongType := want.(string); wrongType && col == 3 {
+					want = nil
+				}
+				if wantTime, ok := want.(time.Time); ok {
+					if gotTime, _ := got.(time.Time); !gotTime.Equal(wantTime) {
+						t.Fatalf("row %d column %d = %v, want %v", row, col, got, want)
+					}
+				} else if got != want {
+					t.Fatalf("row %d column %d = %v, want %v", row, col, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestWriter_Errors(t *testing.T) {
+	var out bytes.Buffer
+	if _, err := NewWriter(&out, nil); err == nil {
+		t.Error("NewWriter() with no columns: error = nil, want error")
+	}
+
+	w, err := NewWriter(&out, []Column{{Name: "id", Type: String}})
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	if err := w.Write([]interface{}{"a", "b"}); err == nil {
+		t.Error("Write() with too many values: error = nil, want error")
+	}
+	if err := w.Write([]interface{}{nil}); err == nil {
+		t.Error("Write() with null required value: error = nil, want error")
+	}
+	if w.rows != 0 || w.buffers[0].values.Len() != 0 {
+		t.Error("Write() buffered part of a rejected row")
+	}
+}
@@ -0,0 +1,451 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Key types exposed to expressions as key.type
+const (
+	KeyTypeWrite  = "write"
+	KeyTypeRead   = "read"
+	KeyTypePublic = "public" // No key presented
+)
+
+// Env holds the values an expression can reference
+type Env struct {
+	Data    map[string]interface{} // Document data, referenced as data.<field>
+	Token   map[string]interface{} // Token claims, referenced as token.<claim>
+	KeyType string                 // Referenced as key.type
+}
+
+// Expression is a parsed read filter expression
+//
+// Grammar:
+//
+//	expr       = or
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | primary
+//	primary    = "(" expr ")" | comparison
+//	comparison = operand [ ("==" | "!=" | "<" | "<=" | ">" | ">=") operand ]
+//	operand    = path | string | number | "true" | "false" | "null"
+//	path       = ("data" | "token" | "key") "." ident { "." ident }
+type Expression struct {
+	source string
+	root   node
+}
+
+// String returns the expression source
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression, returning true if the document is readable
+func (e *Expression) Eval(env Env) bool {
+	return truthy(e.root.eval(env))
+}
+
+// Parse compiles an expression
+func Parse(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("expression cannot be empty")
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+// node is an evaluable expression tree node
+type node interface {
+	eval(env Env) interface{}
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) eval(env Env) interface{} {
+	return n.value
+}
+
+type pathNode struct {
+	root string
+	path []string
+}
+
+func (n pathNode) eval(env Env) interface{} {
+	var current interface{}
+	switch n.root {
+	case "data":
+		current = env.Data
+	case "token":
+		current = env.Token
+	case "key":
+		current = map[string]interface{}{"type": env.KeyType}
+	}
+
+	for _, segment := range n.path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return missing{}
+		}
+		value, exists := m[segment]
+		if !exists {
+			return missing{}
+		}
+		current = value
+	}
+	return normalize(current)
+}
+
+// missing marks a path that doesn't resolve to a value
+// Two missing values are never equal, so an absent claim can't match an absent field
+type missing struct{}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) eval(env Env) interface{} {
+	return !truthy(n.operand.eval(env))
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n logicalNode) eval(env Env) interface{} {
+	left := truthy(n.left.eval(env))
+	if n.op == "&&" {
+		return left && truthy(n.right.eval(env))
+	}
+	return left || truthy(n.right.eval(env))
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) eval(env Env) interface{} {
+	left := n.left.eval(env)
+	right := n.right.eval(env)
+
+	_, leftMissing := left.(missing)
+	_, rightMissing := right.(missing)
+	if leftMissing && rightMissing {
+		return n.op == "!="
+	}
+	if leftMissing {
+		left = nil
+	}
+	if rightMissing {
+		right = nil
+	}
+
+	switch n.op {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	}
+
+	// Ordering is only defined between two numbers or two strings
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false
+		}
+		return compareOrdered(n.op, l < r, l == r)
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false
+		}
+		return compareOrdered(n.op, l < r, l == r)
+	}
+	return false
+}
+
+// compareOrdered applies an ordering operator given less-than and equality results
+func compareOrdered(op string, less bool, equal bool) bool {
+	switch op {
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	case ">=":
+		return !less
+	}
+	return false
+}
+
+// normalize converts numeric values to float64 so comparisons behave consistently
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case map[string]interface{}, []interface{}:
+		return nil // Composite values are not comparable
+	}
+	return value
+}
+
+// truthy reports whether a value counts as true; only boolean true does
+func truthy(value interface{}) bool {
+	b, ok := value.(bool)
+	return ok && b
+}
+
+// token is a lexical token
+type token struct {
+	kind string // "op", "ident", "string", "number"
+	text string
+	pos  int
+}
+
+// tokenize splits an expression into tokens
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(source) {
+		ch := source[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+
+		case strings.HasPrefix(source[i:], "==") || strings.HasPrefix(source[i:], "!=") ||
+			strings.HasPrefix(source[i:], "<=") || strings.HasPrefix(source[i:], ">=") ||
+			strings.HasPrefix(source[i:], "&&") || strings.HasPrefix(source[i:], "||"):
+			tokens = append(tokens, token{kind: "op", text: source[i : i+2], pos: i})
+			i += 2
+
+		case strings.ContainsRune("()!<>.", rune(ch)):
+			tokens = append(tokens, token{kind: "op", text: string(ch), pos: i})
+			i++
+
+		case ch == '"' || ch == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(source) {
+				if source[i] == '\\' && i+1 < len(source) {
+					sb.WriteByte(source[i+1])
+					i += 2
+					continue
+				}
+				if source[i] == ch {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(source[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			tokens = append(tokens, token{kind: "string", text: sb.String(), pos: start})
+
+		case ch == '-' || (ch >= '0' && ch <= '9'):
+			start := i
+			i++
+			for i < len(source) && (source[i] == '.' || (source[i] >= '0' && source[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, token{kind: "number", text: source[start:i], pos: start})
+
+		case ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z'):
+			start := i
+			for i < len(source) && (source[i] == '_' || (source[i] >= 'a' && source[i] <= 'z') ||
+				(source[i] >= 'A' && source[i] <= 'Z') || (source[i] >= '0' && source[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, token{kind: "ident", text: source[start:i], pos: start})
+
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", ch, i)
+		}
+	}
+	return tokens, nil
+}
+
+// parser is a recursive descent parser over tokens
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: "eof", text: "end of expression", pos: -1}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) acceptOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != "op" {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp("||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "||", left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp("&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.acceptOp("!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if _, ok := p.acceptOp("("); ok {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.acceptOp(")"); !ok {
+			return nil, fmt.Errorf("expected ) at position %d", p.peek().pos)
+		}
+		return inner, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if op, ok := p.acceptOp("==", "!=", "<", "<=", ">", ">="); ok {
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.peek()
+	switch t.kind {
+	case "string":
+		p.pos++
+		return literalNode{value: t.text}, nil
+
+	case "number":
+		p.pos++
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.text, t.pos)
+		}
+		return literalNode{value: value}, nil
+
+	case "ident":
+		p.pos++
+		switch t.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		case "data", "token", "key":
+			return p.parsePath(t)
+		}
+		return nil, fmt.Errorf("unknown identifier %q at position %d (expected data, token or key)", t.text, t.pos)
+	}
+
+	return nil, fmt.Errorf("expected value at position %d, got %q", t.pos, t.text)
+}
+
+func (p *parser) parsePath(root token) (node, error) {
+	n := pathNode{root: root.text}
+	for {
+		if _, ok := p.acceptOp("."); !ok {
+			break
+		}
+		t := p.peek()
+		if t.kind != "ident" {
+			return nil, fmt.Errorf("expected field name at position %d", t.pos)
+		}
+		p.pos++
+		n.path = append(n.path, t.text)
+	}
+	if len(n.path) == 0 {
+		return nil, fmt.Errorf("%s must be followed by a field name at position %d", root.text, root.pos)
+	}
+	if root.text == "key" && (len(n.path) != 1 || n.path[0] != "type") {
+		return nil, fmt.Errorf("only key.type is supported at position %d", root.pos)
+	}
+	return n, nil
+}
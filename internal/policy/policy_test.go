@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		errorMsg string
+	}{
+		{name: "empty", expr: "  ", errorMsg: "cannot be empty"},
+		{name: "unknown root", expr: "doc.owner == 'a'", errorMsg: "unknown identifier"},
+		{name: "bare root", expr: "data == 'a'", errorMsg: "must be followed by a field name"},
+		{name: "unsupported key field", expr: "key.value == 'a'", errorMsg: "only key.type"},
+		{name: "unterminated string", expr: "data.a == 'abc", errorMsg: "unterminated string"},
+		{name: "missing paren", expr: "(data.a == 1", errorMsg: "expected )"},
+		{name: "trailing tokens", expr: "data.a == 1 data.b", errorMsg: "unexpected"},
+		{name: "bad character", expr: "data.a = 1", errorMsg: "unexpected character"},
+		{name: "missing operand", expr: "data.a ==", errorMsg: "expected value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if err == nil {
+				t.Fatalf("Parse(%q) error = nil, want error containing %q", tt.expr, tt.errorMsg)
+			}
+			if !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("Parse(%q) error = %v, want error containing %q", tt.expr, err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestExpression_Eval(t *testing.T) {
+	env := Env{
+		Data: map[string]interface{}{
+			"owner_id": "user_1",
+			"score":    float64(42),
+			"public":   true,
+			"meta":     map[string]interface{}{"team": "red"},
+		},
+		Token:   map[string]interface{}{"sub": "user_1", "role": "member"},
+		KeyType: KeyTypeRead,
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{name: "owner matches token", expr: "data.owner_id == token.sub", want: true},
+		{name: "owner mismatch", expr: "data.owner_id != token.sub", want: false},
+		{name: "key type", expr: `key.type == "read"`, want: true},
+		{name: "bool field", expr: "data.public", want: true},
+		{name: "negation", expr: "!data.public", want: false},
+		{name: "numeric ordering", expr: "data.score >= 42 && data.score < 100", want: true},
+		{name: "string ordering", expr: "data.owner_id > 'user_0'", want: true},
+		{name: "mixed type ordering", expr: "data.score > 'a'", want: false},
+		{name: "or short circuit", expr: "key.type == 'write' || data.owner_id == 'user_1'", want: true},
+		{name: "parentheses", expr: "!(data.public && data.score == 1)", want: true},
+		{name: "nested path", expr: "data.meta.team == 'red'", want: true},
+		{name: "missing field is null", expr: "data.missing == null", want: true},
+		{name: "missing claim", expr: "data.owner_id == token.missing", want: false},
+		{name: "non-bool value is false", expr: "data.score", want: false},
+		{name: "escaped quote", expr: `data.owner_id != 'it\'s'`, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.expr, err)
+			}
+			if got := expr.Eval(env); got != tt.want {
+				t.Errorf("Eval(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpression_EvalWithoutToken(t *testing.T) {
+	expr, err := Parse("data.owner_id == token.sub")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	env := Env{Data: map[string]interface{}{"owner_id": "user_1"}, KeyType: KeyTypePublic}
+	if expr.Eval(env) {
+		t.Error("Eval() = true with no token claims, want false")
+	}
+
+	// An absent field must not match an absent claim
+	env.Data = map[string]interface{}{}
+	if expr.Eval(env) {
+		t.Error("Eval() = true with missing field and claim, want false")
+	}
+}
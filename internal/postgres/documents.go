@@ -0,0 +1,385 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+// Documents are charged for the size of their JSON as written. Soft deletes aren't
+// supported, so reads of soft-deleted documents find none.
+
+// documentColumns are the columns scanDocument reads, in order
+const documentColumns = `id, created_at, updated_at, data, visibility, revision`
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanDocument reads a document selected with documentColumns
+func scanDocument(row scanner, collection string) (*models.Document, error) {
+	var doc models.Document
+	var createdAt, updatedAt int64
+	var data []byte
+	if err := row.Scan(&doc.ID, &createdAt, &updatedAt, &data, &doc.Visibility, &doc.Revision); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &doc.Data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal document data: %w", err)
+	}
+
+	doc.Collection = collection
+	doc.CreatedAt = time.Unix(createdAt, 0)
+	doc.UpdatedAt = time.Unix(updatedAt, 0)
+	return &doc, nil
+}
+
+// InsertDocument inserts a new document into a collection
+func (s *Store) InsertDocument(dbID string, collection string, data map[string]interface{}, visibility models.Visibility) (*models.Document, error) {
+	if visibility == "" {
+		visibility = models.DefaultVisibility
+	}
+	if !visibility.IsValid() {
+		return nil, fmt.Errorf("invalid visibility: %s", visibility)
+	}
+
+	docID, err := s.keys.DocumentID()
+	if err != nil {
+		return nil, err
+	}
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document data: %w", err)
+	}
+	size := int64(len(dataJSON))
+	now := time.Now().Unix()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := reserveQuota(tx, dbID, size); err != nil {
+		return nil, err
+	}
+	query := `
+		INSERT INTO documents (database_id, collection, id, data, size, visibility, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`
+	if _, err := tx.Exec(query, dbID, collection, docID, string(dataJSON), size, string(visibility), now); err != nil {
+		return nil, fmt.Errorf("failed to insert document: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to insert document: %w", err)
+	}
+
+	if s.broadcaster != nil {
+		s.broadcaster.Broadcast(dbID, models.ChangeEvent{
+			EventType:  "insert",
+			DatabaseID: dbID,
+			Collection: collection,
+			DocumentID: docID,
+			Data:       data,
+			Visibility: visibility,
+			Timestamp:  time.Unix(now, 0),
+		})
+	}
+
+	return &models.Document{
+		ID:         docID,
+		Collection: collection,
+		Data:       data,
+		Visibility: visibility,
+		CreatedAt:  time.Unix(now, 0),
+		UpdatedAt:  time.Unix(now, 0),
+		Revision:   1,
+	}, nil
+}
+
+// GetDocument retrieves a single document by ID
+// Returns nil if the document doesn't exist or the scope doesn't allow reading it
+func (s *Store) GetDocument(dbID string, collection string, docID string, scope *database.ReadScope) (*models.Document, error) {
+	if scope.ReadsDeleted() {
+		return nil, nil
+	}
+
+	args := queryArgs{dbID, collection, docID}
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM documents
+		WHERE database_id = $1 AND collection = $2 AND id = $3%s
+	`, documentColumns, visibilityClause(&args, scope.VisibleLevels()))
+
+	doc, err := scanDocument(s.db.QueryRow(query, args...), collection)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	if !scope.Allows(doc) {
+		return nil, nil
+	}
+
+	return doc, nil
+}
+
+// QueryDocuments retrieves documents from a collection with pagination and filtering
+// Results are newest first unless sort keys are given
+// Nil dates or scope mean no timestamp or visibility restriction
+func (s *Store) QueryDocuments(dbID string, collection string, limit int, offset int, filters []database.Filter, dates *database.TimeRange, order []database.SortKey, scope *database.ReadScope) ([]*models.Document, error) {
+	if scope.ReadsDeleted() {
+		return nil, nil
+	}
+
+	args := queryArgs{dbID, collection}
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM documents
+		WHERE database_id = $1 AND collection = $2%s%s%s
+		ORDER BY %s
+	`, documentColumns, visibilityClause(&args, scope.VisibleLevels()), timeRangeClause(&args, dates),
+		filterClause(&args, filters), orderByClause(order))
+
+	// Paginate in SQL unless rows are filtered by a read policy afterwards
+	inMemory := scope.HasPolicy()
+	if !inMemory {
+		if limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", limit)
+		}
+		if offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", offset)
+		}
+	}
+
+	budget := s.limits.NewQueryBudget()
+	defer budget.Done()
+
+	rows, err := s.db.QueryContext(budget.Context(), query, args...)
+	if err != nil {
+		return nil, budget.Err(fmt.Errorf("failed to query documents: %w", err))
+	}
+	defer rows.Close()
+
+	var documents []*models.Document
+	skipped := 0
+	for rows.Next() {
+		if err := budget.Scan(); err != nil {
+			return nil, err
+		}
+		doc, err := scanDocument(rows, collection)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+
+		if !inMemory {
+			documents = append(documents, doc)
+			continue
+		}
+
+		// Apply the read policy, then paginate
+		if !scope.Allows(doc) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		documents = append(documents, doc)
+		if limit > 0 && len(documents) >= limit {
+			break
+		}
+	}
+
+	return documents, budget.Err(rows.Err())
+}
+
+// EachDocument streams every document in a collection to fn, oldest first
+func (s *Store) EachDocument(dbID string, collection string, scope *database.ReadScope, fn func(*models.Document) error) error {
+	if scope.ReadsDeleted() {
+		return nil
+	}
+
+	args := queryArgs{dbID, collection}
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM documents
+		WHERE database_id = $1 AND collection = $2%s
+		ORDER BY created_at ASC, id ASC
+	`, documentColumns, visibilityClause(&args, scope.VisibleLevels()))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		doc, err := scanDocument(rows, collection)
+		if err != nil {
+			return fmt.Errorf("failed to scan document: %w", err)
+		}
+		if !scope.Allows(doc) {
+			continue
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// UpdateDocument updates an existing document by ID
+// An empty visibility keeps the document's current visibility, and a nonzero revision
+// must match the document's current revision
+func (s *Store) UpdateDocument(dbID string, collection string, docID string, data map[string]interface{}, visibility models.Visibility, revision int64) (*models.Document, error) {
+	if visibility != "" && !visibility.IsValid() {
+		return nil, fmt.Errorf("invalid visibility: %s", visibility)
+	}
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document data: %w", err)
+	}
+	newSize := int64(len(dataJSON))
+	now := time.Now().Unix()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the document keeps concurrent updates from charging the same size change twice
+	var oldSize, createdAt, oldRevision int64
+	var oldVisibility models.Visibility
+	var oldJSON []byte
+	query := `
+		SELECT data, size, visibility, created_at, revision
+		FROM documents
+		WHERE database_id = $1 AND collection = $2 AND id = $3
+		FOR UPDATE
+	`
+	err = tx.QueryRow(query, dbID, collection, docID).Scan(&oldJSON, &oldSize, &oldVisibility, &createdAt, &oldRevision)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("document not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	if err := database.CheckRevision(revision, oldRevision); err != nil {
+		return nil, err
+	}
+	if visibility == "" {
+		visibility = oldVisibility
+	}
+
+	if sizeDelta := newSize - oldSize; sizeDelta > 0 {
+		err = reserveQuota(tx, dbID, sizeDelta)
+	} else {
+		err = releaseQuota(tx, dbID, -sizeDelta)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	updateQuery := `
+		UPDATE documents
+		SET data = $1, size = $2, visibility = $3, updated_at = $4, revision = revision + 1
+		WHERE database_id = $5 AND collection = $6 AND id = $7
+	`
+	if _, err := tx.Exec(updateQuery, string(dataJSON), newSize, string(visibility), now, dbID, collection, docID); err != nil {
+		return nil, fmt.Errorf("failed to update document: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update document: %w", err)
+	}
+
+	if s.broadcaster != nil {
+		event := models.ChangeEvent{
+			EventType:     "update",
+			DatabaseID:    dbID,
+			Collection:    collection,
+			DocumentID:    docID,
+			Data:          data,
+			Visibility:    visibility,
+			OldVisibility: oldVisibility,
+			Timestamp:     time.Unix(now, 0),
+		}
+		json.Unmarshal(oldJSON, &event.OldData)
+		s.broadcaster.Broadcast(dbID, event)
+	}
+
+	return &models.Document{
+		ID:         docID,
+		Collection: collection,
+		Data:       data,
+		Visibility: visibility,
+		CreatedAt:  time.Unix(createdAt, 0),
+		UpdatedAt:  time.Unix(now, 0),
+		Revision:   oldRevision + 1,
+	}, nil
+}
+
+// DeleteDocument deletes a single document by ID
+// A nonzero revision must match the document's current revision
+func (s *Store) DeleteDocument(dbID string, collection string, docID string, revision int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var size, current int64
+	var visibility models.Visibility
+	var oldJSON []byte
+	query := `
+		SELECT data, size, visibility, revision
+		FROM documents
+		WHERE database_id = $1 AND collection = $2 AND id = $3
+		FOR UPDATE
+	`
+	err = tx.QueryRow(query, dbID, collection, docID).Scan(&oldJSON, &size, &visibility, &current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("document not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get document: %w", err)
+	}
+	if err := database.CheckRevision(revision, current); err != nil {
+		return err
+	}
+
+	deleteQuery := `DELETE FROM documents WHERE database_id = $1 AND collection = $2 AND id = $3`
+	if _, err := tx.Exec(deleteQuery, dbID, collection, docID); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	if err := releaseQuota(tx, dbID, size); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+
+	if s.broadcaster != nil {
+		event := models.ChangeEvent{
+			EventType:  "delete",
+			DatabaseID: dbID,
+			Collection: collection,
+			DocumentID: docID,
+			Visibility: visibility,
+			Timestamp:  time.Now(),
+		}
+		json.Unmarshal(oldJSON, &event.OldData)
+		s.broadcaster.Broadcast(dbID, event)
+	}
+
+	return nil
+}
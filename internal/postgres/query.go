@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+// queryArgs collects query arguments, numbering their placeholders
+type queryArgs []interface{}
+
+// add appends an argument and returns its placeholder
+func (a *queryArgs) add(value interface{}) string {
+	*a = append(*a, value)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// metadataColumns are the built-in document columns that can be sorted like data fields
+var metadataColumns = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// fieldExpr returns the SQL expression for a metadata column or data field
+// Field names come from schemas, where they are validated identifiers
+func fieldExpr(field string) string {
+	if metadataColumns[field] {
+		return field
+	}
+	return "data->'" + field + "'"
+}
+
+// visibilityClause restricts documents to the given visibility levels; nil means no restriction
+func visibilityClause(args *queryArgs, visible []models.Visibility) string {
+	if visible == nil {
+		return ""
+	}
+	if len(visible) == 0 {
+		return " AND FALSE"
+	}
+
+	placeholders := make([]string, len(visible))
+	for i, v := range visible {
+		placeholders[i] = args.add(string(v))
+	}
+	return fmt.Sprintf(" AND visibility IN (%s)", strings.Join(placeholders, ", "))
+}
+
+// timeRangeClause restricts documents by their created_at and updated_at timestamps
+func timeRangeClause(args *queryArgs, tr *database.TimeRange) string {
+	if tr == nil {
+		return ""
+	}
+
+	var clause strings.Builder
+	bounds := []struct {
+		column string
+		op     string
+		unix   int64
+		set    bool
+	}{
+		{"created_at", ">", tr.CreatedAfter.Unix(), !tr.CreatedAfter.IsZero()},
+		{"created_at", "<", tr.CreatedBefore.Unix(), !tr.CreatedBefore.IsZero()},
+		{"updated_at", ">", tr.UpdatedAfter.Unix(), !tr.UpdatedAfter.IsZero()},
+		{"updated_at", "<", tr.UpdatedBefore.Unix(), !tr.UpdatedBefore.IsZero()},
+	}
+	for _, bound := range bounds {
+		if bound.set {
+			fmt.Fprintf(&clause, " AND %s %s %s", bound.column, bound.op, args.add(bound.unix))
+		}
+	}
+	return clause.String()
+}
+
+// filterClause restricts documents to field values, comparing them as JSONB
+// Values that don't parse as the field's type never match, as with SQLite
+func filterClause(args *queryArgs, filters []database.Filter) string {
+	var clause strings.Builder
+	for _, filter := range filters {
+		switch filter.Op {
+		case database.FilterEquals:
+		case database.FilterExists:
+			clause.WriteString(" AND " + existsCondition(filter))
+			continue
+		default:
+			clause.WriteString(" AND " + stringFilterCondition(args, filter))
+			continue
+		}
+
+		var conditions []string
+		if values := filterValues(filter); len(values) > 0 {
+			placeholders := make([]string, len(values))
+			for i, value := range values {
+				placeholders[i] = args.add(value) + "::jsonb"
+			}
+			conditions = append(conditions, fmt.Sprintf("%s IN (%s)", fieldExpr(filter.Field), strings.Join(placeholders, ", ")))
+		}
+		if filter.MatchNull {
+			conditions = append(conditions, fmt.Sprintf("(%s IS NULL OR %s = 'null'::jsonb)", fieldExpr(filter.Field), fieldExpr(filter.Field)))
+		}
+		switch len(conditions) {
+		case 0:
+			clause.WriteString(" AND FALSE")
+		case 1:
+			clause.WriteString(" AND " + conditions[0])
+		default:
+			clause.WriteString(" AND (" + strings.Join(conditions, " OR ") + ")")
+		}
+	}
+	return clause.String()
+}
+
+// existsCondition builds the condition for an exists filter; a JSON null field exists
+func existsCondition(filter database.Filter) string {
+	present, missing := filter.Existence()
+	switch {
+	case present && missing:
+		return "TRUE"
+	case present:
+		return "data ? '" + filter.Field + "'"
+	case missing:
+		return "NOT data ? '" + filter.Field + "'"
+	default:
+		return "FALSE"
+	}
+}
+
+// stringFilterCondition builds the condition for a string filter operator, comparing the
+// field as text; unlike SQLite, ILIKE and lower() ignore the case of all letters
+func stringFilterCondition(args *queryArgs, filter database.Filter) string {
+	if len(filter.Values) == 0 {
+		return "FALSE"
+	}
+	column := "data->>'" + filter.Field + "'"
+	conditions := make([]string, len(filter.Values))
+	for i, value := range filter.Values {
+		if filter.Op == database.FilterIEquals {
+			conditions[i] = fmt.Sprintf("lower(%s) = lower(%s)", column, args.add(value))
+			continue
+		}
+		conditions[i] = fmt.Sprintf(`%s ILIKE %s ESCAPE '\'`, column, args.add(database.LikePattern(filter.Op, value)))
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")"
+}
+
+// filterValues encodes filter values as JSON of the field's type
+func filterValues(filter database.Filter) []string {
+	var values []string
+	for _, value := range filter.Values {
+		var encoded []byte
+		switch filter.Type {
+		case models.FieldTypeNumber:
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			encoded, _ = json.Marshal(n)
+		case models.FieldTypeBool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				continue
+			}
+			encoded, _ = json.Marshal(b)
+		default:
+			encoded, _ = json.Marshal(value)
+		}
+		values = append(values, string(encoded))
+	}
+	return values
+}
+
+// orderByClause builds an ORDER BY expression list for sort keys
+// Missing fields sort first, as SQLite sorts nulls
+func orderByClause(keys []database.SortKey) string {
+	if len(keys) == 0 {
+		return "created_at DESC"
+	}
+
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		if key.Descending {
+			terms[i] = fieldExpr(key.Field) + " DESC NULLS LAST"
+		} else {
+			terms[i] = fieldExpr(key.Field) + " ASC NULLS FIRST"
+		}
+	}
+	return strings.Join(terms, ", ")
+}
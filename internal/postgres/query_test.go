@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+func TestVisibilityClause(t *testing.T) {
+	var args queryArgs
+	if got := visibilityClause(&args, nil); got != "" || len(args) != 0 {
+		t.Errorf("nil levels = %q with %d args, want no clause", got, len(args))
+	}
+	if got := visibilityClause(&args, []models.Visibility{}); got != " AND FALSE" {
+		t.Errorf("empty levels = %q, want AND FALSE", got)
+	}
+
+	args = queryArgs{"db_1"}
+	got := visibilityClause(&args, []models.Visibility{models.VisibilityPublic, models.VisibilityReadKey})
+	if want := " AND visibility IN ($2, $3)"; got != want {
+		t.Errorf("visibilityClause() = %q, want %q", got, want)
+	}
+	if want := (queryArgs{"db_1", "public", "read_key"}); !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
+
+func TestTimeRangeClause(t *testing.T) {
+	after := time.Unix(1000, 0)
+	before := time.Unix(2000, 0)
+
+	var args queryArgs
+	got := timeRangeClause(&args, &database.TimeRange{CreatedAfter: after, UpdatedBefore: before})
+	if want := " AND created_at > $1 AND updated_at < $2"; got != want {
+		t.Errorf("timeRangeClause() = %q, want %q", got, want)
+	}
+	if want := (queryArgs{int64(1000), int64(2000)}); !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+
+	if got := timeRangeClause(&args, nil); got != "" {
+		t.Errorf("nil range = %q, want no clause", got)
+	}
+}
+
+func TestFilterClause(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  []database.Filter
+		want     string
+		wantArgs queryArgs
+	}{
+		{name: "none", want: ""},
+		{
+			name:     "string values",
+			filters:  []database.Filter{{Field: "status", Type: models.FieldTypeString, Values: []string{"open", "closed"}}},
+			want:     " AND data->'status' IN ($1::jsonb, $2::jsonb)",
+			wantArgs: queryArgs{`"open"`, `"closed"`},
+		},
+		{
+			name: "number and bool",
+			filters: []database.Filter{
+				{Field: "age", Type: models.FieldTypeNumber, Values: []string{"30"}},
+				{Field: "active", Type: models.FieldTypeBool, Values: []string{"true"}},
+			},
+			want:     " AND data->'age' IN ($1::jsonb) AND data->'active' IN ($2::jsonb)",
+			wantArgs: queryArgs{"30", "true"},
+		},
+		{
+			name: "null and exists",
+			filters: []database.Filter{
+				{Field: "age", Type: models.FieldTypeNumber, Values: []string{"30"}, MatchNull: true},
+				{Field: "email", Type: models.FieldTypeString, Op: database.FilterExists, Values: []string{"false"}},
+			},
+			want:     " AND (data->'age' IN ($1::jsonb) OR (data->'age' IS NULL OR data->'age' = 'null'::jsonb)) AND NOT data ? 'email'",
+			wantArgs: queryArgs{"30"},
+		},
+		{
+			name:     "string operators",
+			filters:  []database.Filter{{Field: "name", Type: models.FieldTypeString, Op: database.FilterPrefix, Values: []string{"a_"}}},
+			want:     ` AND (data->>'name' ILIKE $1 ESCAPE '\')`,
+			wantArgs: queryArgs{`a\_%`},
+		},
+		{
+			name:     "unparseable values never match",
+			filters:  []database.Filter{{Field: "age", Type: models.FieldTypeNumber, Values: []string{"old"}}},
+			want:     " AND FALSE",
+			wantArgs: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args queryArgs
+			got := filterClause(&args, tt.filters)
+			if got != tt.want {
+				t.Errorf("filterClause() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestOrderByClause(t *testing.T) {
+	if got := orderByClause(nil); got != "created_at DESC" {
+		t.Errorf("default order = %q, want created_at DESC", got)
+	}
+
+	got := orderByClause([]database.SortKey{{Field: "name"}, {Field: "updated_at", Descending: true}})
+	if want := "data->'name' ASC NULLS FIRST, updated_at DESC NULLS LAST"; got != want {
+		t.Errorf("orderByClause() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+)
+
+// CreateSchema creates a new schema for a collection; masking fields needs the catalog
+func (s *Store) CreateSchema(dbID string, name string, fields map[string]models.FieldType, masked []string) (*models.Schema, error) {
+	if err := database.ValidateSchema(name, fields); err != nil {
+		return nil, err
+	}
+	if len(masked) > 0 {
+		return nil, fmt.Errorf("masked fields require the sqlite storage backend")
+	}
+	if err := s.limits.CheckFields(len(fields)); err != nil {
+		return nil, err
+	}
+
+	fieldsJSON, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fields: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the database serializes schema creation, so the collection limit holds
+	if err := lockDatabase(tx, dbID); err != nil {
+		return nil, err
+	}
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM schemas WHERE database_id = $1`, dbID).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count collections: %w", err)
+	}
+	if err := s.limits.CheckCollections(count); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	query := `INSERT INTO schemas (database_id, name, fields, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(query, dbID, name, string(fieldsJSON), now); err != nil {
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	if s.broadcaster != nil {
+		s.broadcaster.Broadcast(dbID, models.ChangeEvent{
+			EventType:  "schema_created",
+			DatabaseID: dbID,
+			Collection: name,
+			Data: map[string]interface{}{
+				"schema_name": name,
+				"fields":      fields,
+			},
+			Timestamp: time.Unix(now, 0),
+		})
+	}
+
+	return &models.Schema{
+		DatabaseID: dbID,
+		Name:       name,
+		Fields:     fields,
+		CreatedAt:  time.Unix(now, 0),
+	}, nil
+}
+
+// GetSchema retrieves a schema by database ID and name
+func (s *Store) GetSchema(dbID string, name string) (*models.Schema, error) {
+	query := `
+		SELECT database_id, name, fields, read_filter, created_at
+		FROM schemas
+		WHERE database_id = $1 AND name = $2
+	`
+
+	var schema models.Schema
+	var fieldsJSON []byte
+	var createdAt int64
+	err := s.db.QueryRow(query, dbID, name).Scan(
+		&schema.DatabaseID,
+		&schema.Name,
+		&fieldsJSON,
+		&schema.ReadFilter,
+		&createdAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema: %w", err)
+	}
+
+	if err := json.Unmarshal(fieldsJSON, &schema.Fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal fields: %w", err)
+	}
+	schema.CreatedAt = time.Unix(createdAt, 0)
+
+	return &schema, nil
+}
+
+// SetReadFilter sets the row-level read filter expression for a collection
+// An empty expression removes the filter
+func (s *Store) SetReadFilter(dbID string, name string, expression string) (*models.Schema, error) {
+	result, err := s.db.Exec(`UPDATE schemas SET read_filter = $1 WHERE database_id = $2 AND name = $3`, expression, dbID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update read filter: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return nil, fmt.Errorf("schema not found")
+	}
+
+	return s.GetSchema(dbID, name)
+}
+
+// DeleteSchema deletes a schema and its documents, giving back their quota
+func (s *Store) DeleteSchema(dbID string, name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the database holds off writes, so every deleted document's quota is released
+	if err := lockDatabase(tx, dbID); err != nil {
+		return err
+	}
+	var size int64
+	err = tx.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM documents WHERE database_id = $1 AND collection = $2`, dbID, name).Scan(&size)
+	if err != nil {
+		return fmt.Errorf("failed to size documents: %w", err)
+	}
+
+	// Documents are deleted with the schema
+	result, err := tx.Exec(`DELETE FROM schemas WHERE database_id = $1 AND name = $2`, dbID, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete schema: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("schema not found")
+	}
+	if err := releaseQuota(tx, dbID, size); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete schema: %w", err)
+	}
+
+	if s.broadcaster != nil {
+		s.broadcaster.Broadcast(dbID, models.ChangeEvent{
+			EventType:  "schema_deleted",
+			DatabaseID: dbID,
+			Collection: name,
+			Data: map[string]interface{}{
+				"schema_name": name,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,404 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"jsondrop/internal/database"
+	"jsondrop/internal/models"
+
+	_ "github.com/lib/pq"
+)
+
+// All databases share one PostgreSQL database: the databases and schemas tables mirror the
+// SQLite catalog, and every document lives in the documents table with its data as JSONB.
+// Quota is reserved in the same transaction that writes the document, so it never drifts
+// from what is stored.
+
+const schemaSQL = `
+	CREATE TABLE IF NOT EXISTS databases (
+		id TEXT PRIMARY KEY,
+		write_key TEXT UNIQUE NOT NULL,
+		read_key TEXT UNIQUE NOT NULL,
+		created_at BIGINT NOT NULL,
+		last_accessed BIGINT NOT NULL,
+		quota_used BIGINT NOT NULL DEFAULT 0,
+		quota_limit BIGINT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS schemas (
+		database_id TEXT NOT NULL REFERENCES databases (id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		fields JSONB NOT NULL,
+		read_filter TEXT NOT NULL DEFAULT '',
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (database_id, name)
+	);
+
+	CREATE TABLE IF NOT EXISTS documents (
+		database_id TEXT NOT NULL,
+		collection TEXT NOT NULL,
+		id TEXT NOT NULL,
+		data JSONB NOT NULL,
+		size BIGINT NOT NULL,
+		visibility TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		revision BIGINT NOT NULL DEFAULT 1,
+		PRIMARY KEY (database_id, collection, id),
+		FOREIGN KEY (database_id, collection) REFERENCES schemas (database_id, name) ON DELETE CASCADE
+	);
+
+	ALTER TABLE documents ADD COLUMN IF NOT EXISTS revision BIGINT NOT NULL DEFAULT 1;
+	ALTER TABLE databases ADD COLUMN IF NOT EXISTS previous_write_key TEXT;
+	ALTER TABLE databases ADD COLUMN IF NOT EXISTS previous_write_key_expires BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE databases ADD COLUMN IF NOT EXISTS previous_read_key TEXT;
+	ALTER TABLE databases ADD COLUMN IF NOT EXISTS previous_read_key_expires BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE databases ADD COLUMN IF NOT EXISTS allowed_networks TEXT NOT NULL DEFAULT '';
+	ALTER TABLE databases ADD COLUMN IF NOT EXISTS cors_origins TEXT NOT NULL DEFAULT '';
+	ALTER TABLE databases ADD COLUMN IF NOT EXISTS protected BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE databases ADD COLUMN IF NOT EXISTS key_generation BIGINT NOT NULL DEFAULT 0;
+
+	CREATE INDEX IF NOT EXISTS idx_databases_previous_write_key ON databases (previous_write_key);
+	CREATE INDEX IF NOT EXISTS idx_databases_previous_read_key ON databases (previous_read_key);
+
+	CREATE INDEX IF NOT EXISTS idx_documents_created_at ON documents (database_id, collection, created_at);
+	CREATE INDEX IF NOT EXISTS idx_documents_updated_at ON documents (database_id, collection, updated_at);
+	CREATE INDEX IF NOT EXISTS idx_documents_data ON documents USING GIN (data jsonb_path_ops);
+`
+
+// Store keeps databases in PostgreSQL
+type Store struct {
+	db           *sql.DB
+	defaultQuota int64 // bytes
+	limits       database.Limits
+	broadcaster  database.EventBroadcaster
+	keys         database.KeyGenerator
+}
+
+var _ database.Store = (*Store)(nil)
+
+// Open connects to PostgreSQL and creates the tables if they don't exist
+func Open(url string, defaultQuotaMB int64, limits database.Limits, broadcaster database.EventBroadcaster, keys database.KeyGenerator) (*Store, error) {
+	if keys == nil {
+		keys = database.RandomKeys{}
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	if _, err := db.Exec(schemaSQL); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize postgres schema: %w", err)
+	}
+
+	return &Store{
+		db:           db,
+		defaultQuota: defaultQuotaMB * 1024 * 1024,
+		limits:       limits,
+		broadcaster:  broadcaster,
+		keys:         keys,
+	}, nil
+}
+
+// Close closes the connection pool
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Limits returns the configured schema limits
+func (s *Store) Limits() database.Limits {
+	return s.limits
+}
+
+// CreateDatabase creates a database with generated identifiers
+func (s *Store) CreateDatabase() (*models.CreateDatabaseResponse, error) {
+	dbID, err := s.keys.DatabaseID()
+	if err != nil {
+		return nil, err
+	}
+	writeKey, err := s.keys.WriteKey()
+	if err != nil {
+		return nil, err
+	}
+	readKey, err := s.keys.ReadKey()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(dbID, "db_") || !strings.HasPrefix(writeKey, "wk_") || !strings.HasPrefix(readKey, "rk_") {
+		return nil, fmt.Errorf("invalid database identifiers: expected db_, wk_ and rk_ prefixes")
+	}
+
+	now := time.Now().Unix()
+	query := `
+		INSERT INTO databases (id, write_key, read_key, created_at, last_accessed, quota_used, quota_limit)
+		VALUES ($1, $2, $3, $4, $4, 0, $5)
+	`
+	if _, err := s.db.Exec(query, dbID, writeKey, readKey, now, s.defaultQuota); err != nil {
+		return nil, fmt.Errorf("failed to create database entry: %w", err)
+	}
+
+	return &models.CreateDatabaseResponse{
+		DatabaseID: dbID,
+		WriteKey:   writeKey,
+		ReadKey:    readKey,
+	}, nil
+}
+
+// GetDatabaseByID retrieves a database by its ID
+func (s *Store) GetDatabaseByID(dbID string) (*models.Database, error) {
+	return s.getDatabaseByKey("id", dbID)
+}
+
+// GetDatabaseByWriteKey retrieves a database by its write key
+func (s *Store) GetDatabaseByWriteKey(writeKey string) (*models.Database, error) {
+	return s.getDatabaseByKey("write_key", writeKey)
+}
+
+// GetDatabaseByReadKey retrieves a database by its read key
+func (s *Store) GetDatabaseByReadKey(readKey string) (*models.Database, error) {
+	return s.getDatabaseByKey("read_key", readKey)
+}
+
+// getDatabaseByKey retrieves a database by any key column
+// Replaced write and read keys match until their grace period ends
+func (s *Store) getDatabaseByKey(keyField string, keyValue string) (*models.Database, error) {
+	where := keyField + " = $1"
+	args := []interface{}{keyValue}
+	if keyField != "id" {
+		where = fmt.Sprintf("%s OR (previous_%s = $1 AND previous_%s_expires > $2)", where, keyField, keyField)
+		args = append(args, time.Now().Unix())
+	}
+	query := fmt.Sprintf(`
+		SELECT id, write_key, read_key, created_at, last_accessed, quota_used, quota_limit, allowed_networks, cors_origins, protected, key_generation
+		FROM databases
+		WHERE %s
+	`, where)
+
+	var db models.Database
+	var createdAt, lastAccessed int64
+	var networks, origins string
+	err := s.db.QueryRow(query, args...).Scan(
+		&db.ID,
+		&db.WriteKey,
+		&db.ReadKey,
+		&createdAt,
+		&lastAccessed,
+		&db.QuotaUsed,
+		&db.QuotaLimit,
+		&networks,
+		&origins,
+		&db.Protected,
+		&db.KeyGeneration,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database: %w", err)
+	}
+
+	db.CreatedAt = time.Unix(createdAt, 0)
+	db.LastAccessed = time.Unix(lastAccessed, 0)
+	if networks != "" {
+		db.AllowedNetworks = strings.Split(networks, ",")
+	}
+	if origins != "" {
+		db.CORSOrigins = strings.Split(origins, ",")
+	}
+	return &db, nil
+}
+
+// RotateKeys replaces a database's chosen keys with new ones
+// With a grace period the old keys keep working until it ends; keys replaced by an
+// earlier rotation stop working at once
+func (s *Store) RotateKeys(dbID string, write bool, read bool, grace time.Duration) (*models.RotateKeysResponse, error) {
+	writeKey, readKey, err := database.RotatedKeys(s.keys, write, read)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.RotateKeysResponse{DatabaseID: dbID, WriteKey: writeKey, ReadKey: readKey}
+	var expires int64
+	if grace > 0 {
+		expiresAt := time.Now().Add(grace)
+		resp.OldKeysExpire = &expiresAt
+		expires = expiresAt.Unix()
+	}
+
+	// Columns are only replaced when given a key; the right-hand sides see the old row
+	query := `
+		UPDATE databases SET
+			previous_write_key = CASE WHEN $2::text = '' THEN previous_write_key WHEN $4::bigint > 0 THEN write_key END,
+			previous_write_key_expires = CASE WHEN $2::text = '' THEN previous_write_key_expires ELSE $4::bigint END,
+			write_key = CASE WHEN $2::text = '' THEN write_key ELSE $2 END,
+			previous_read_key = CASE WHEN $3::text = '' THEN previous_read_key WHEN $4::bigint > 0 THEN read_key END,
+			previous_read_key_expires = CASE WHEN $3::text = '' THEN previous_read_key_expires ELSE $4::bigint END,
+			read_key = CASE WHEN $3::text = '' THEN read_key ELSE $3 END,
+			key_generation = key_generation + 1
+		WHERE id = $1
+	`
+	result, err := s.db.Exec(query, dbID, writeKey, readKey, expires)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate keys: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("database not found")
+	}
+	return resp, nil
+}
+
+// SetAllowedNetworks replaces the IP allowlist of a database; an empty list allows every address
+func (s *Store) SetAllowedNetworks(dbID string, networks []string) error {
+	result, err := s.db.Exec(`UPDATE databases SET allowed_networks = $1 WHERE id = $2`, strings.Join(networks, ","), dbID)
+	if err != nil {
+		return fmt.Errorf("failed to set allowed networks: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("database not found")
+	}
+	return nil
+}
+
+// SetCORSOrigins replaces the browser origins allowed besides CORS_ORIGINS
+func (s *Store) SetCORSOrigins(dbID string, origins []string) error {
+	result, err := s.db.Exec(`UPDATE databases SET cors_origins = $1 WHERE id = $2`, strings.Join(origins, ","), dbID)
+	if err != nil {
+		return fmt.Errorf("failed to set CORS origins: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("database not found")
+	}
+	return nil
+}
+
+// SetProtected sets whether deleting a database or its schemas needs confirmation
+func (s *Store) SetProtected(dbID string, protected bool) error {
+	result, err := s.db.Exec(`UPDATE databases SET protected = $1 WHERE id = $2`, protected, dbID)
+	if err != nil {
+		return fmt.Errorf("failed to set protection: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("database not found")
+	}
+	return nil
+}
+
+// CountDatabases returns how many databases the store holds
+func (s *Store) CountDatabases() (int, error) {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM databases`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count databases: %w", err)
+	}
+	return count, nil
+}
+
+// UpdateLastAccessed updates the last_accessed timestamp for a database
+func (s *Store) UpdateLastAccessed(dbID string) error {
+	if _, err := s.db.Exec(`UPDATE databases SET last_accessed = $1 WHERE id = $2`, time.Now().Unix(), dbID); err != nil {
+		return fmt.Errorf("failed to update last_accessed: %w", err)
+	}
+	return nil
+}
+
+// DeleteDatabase removes a database; its schemas and documents are deleted with it
+func (s *Store) DeleteDatabase(dbID string) error {
+	if _, err := s.db.Exec(`DELETE FROM databases WHERE id = $1`, dbID); err != nil {
+		return fmt.Errorf("failed to delete database: %w", err)
+	}
+	return nil
+}
+
+// RecalculateQuota recomputes a database's quota usage from its stored documents
+// Usage is kept in step with writes, so this only corrects changes made outside the server
+func (s *Store) RecalculateQuota(dbID string) (*models.QuotaRecalculationResponse, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the database row holds off writes until the new usage is committed
+	var previous, limit int64
+	err = tx.QueryRow(`SELECT quota_used, quota_limit FROM databases WHERE id = $1 FOR UPDATE`, dbID).Scan(&previous, &limit)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("database not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota: %w", err)
+	}
+
+	var used int64
+	if err := tx.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM documents WHERE database_id = $1`, dbID).Scan(&used); err != nil {
+		return nil, fmt.Errorf("failed to size documents: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE databases SET quota_used = $1 WHERE id = $2`, used, dbID); err != nil {
+		return nil, fmt.Errorf("failed to update quota_used: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update quota_used: %w", err)
+	}
+
+	return &models.QuotaRecalculationResponse{
+		DatabaseID:        dbID,
+		PreviousQuotaUsed: previous,
+		QuotaUsed:         used,
+		QuotaLimit:        limit,
+	}, nil
+}
+
+// lockDatabase locks a database's row until a transaction ends
+// Document writes reserve quota by updating the row, so they wait for the lock
+func lockDatabase(tx *sql.Tx, dbID string) error {
+	var id string
+	err := tx.QueryRow(`SELECT id FROM databases WHERE id = $1 FOR UPDATE`, dbID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("database not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock database: %w", err)
+	}
+	return nil
+}
+
+// reserveQuota adds size bytes to a database's quota usage within a transaction, failing
+// if that would exceed its limit. The database row stays locked until the transaction ends
+func reserveQuota(tx *sql.Tx, dbID string, size int64) error {
+	query := `UPDATE databases SET quota_used = quota_used + $1 WHERE id = $2 AND quota_used + $1 <= quota_limit`
+	result, err := tx.Exec(query, size, dbID)
+	if err != nil {
+		return fmt.Errorf("failed to update quota: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+		return nil
+	}
+
+	var quotaUsed, quotaLimit int64
+	err = tx.QueryRow(`SELECT quota_used, quota_limit FROM databases WHERE id = $1`, dbID).Scan(&quotaUsed, &quotaLimit)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("database not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get quota: %w", err)
+	}
+	return fmt.Errorf("quota exceeded: current %d bytes, limit %d bytes, attempted to add %d bytes",
+		quotaUsed, quotaLimit, size)
+}
+
+// releaseQuota subtracts size bytes from a database's quota usage within a transaction
+func releaseQuota(tx *sql.Tx, dbID string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	if _, err := tx.Exec(`UPDATE databases SET quota_used = GREATEST(quota_used - $1, 0) WHERE id = $2`, size, dbID); err != nil {
+		return fmt.Errorf("failed to update quota: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Package ratelimit limits how often each client may do something, such as creating
+// databases: a client may act a number of times per window, counted from its first act
+package ratelimit
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"jsondrop/internal/clientaddr"
+)
+
+// maxClients bounds the clients tracked at once; acts of further clients aren't limited
+// until windows of tracked ones end
+const maxClients = 100000
+
+// Limiter counts acts by client address
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	clients map[netip.Prefix]*client
+	pruned  time.Time // When ended windows were last dropped
+}
+
+// client is the record of one address's acts in its current window
+type client struct {
+	count int
+	start time.Time
+}
+
+// New creates a limiter allowing each client limit acts per window
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{limit: limit, window: window, clients: make(map[netip.Prefix]*client)}
+}
+
+// Reserve counts an act of a client and returns 0 when the client may act now;
+// otherwise it counts nothing and returns how long until the client may. Checking and
+// counting together keeps concurrent acts from all passing the check. A nil limiter
+// never makes clients wait
+func (l *Limiter) Reserve(addr netip.Addr, now time.Time) time.Duration {
+	if l == nil || !addr.IsValid() {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.pruned) >= time.Minute {
+		for key, c := range l.clients {
+			if l.ended(c, now) {
+				delete(l.clients, key)
+			}
+		}
+		l.pruned = now
+	}
+
+	key := clientaddr.Key(addr)
+	c := l.clients[key]
+	if c == nil || l.ended(c, now) {
+		if c == nil && len(l.clients) >= maxClients {
+			return 0
+		}
+		c = &client{start: now}
+		l.clients[key] = c
+	}
+	if c.count >= l.limit {
+		return c.start.Add(l.window).Sub(now)
+	}
+	c.count++
+	return 0
+}
+
+// Release gives back an act reserved at reserved that didn't happen after all, unless
+// the window it was counted in has ended
+func (l *Limiter) Release(addr netip.Addr, reserved time.Time) {
+	if l == nil || !addr.IsValid() {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.clients[clientaddr.Key(addr)]
+	if c == nil || reserved.Before(c.start) || l.ended(c, reserved) || c.count == 0 {
+		return
+	}
+	c.count--
+}
+
+// ended reports whether a client's window is over
+func (l *Limiter) ended(c *client, now time.Time) bool {
+	return now.Sub(c.start) >= l.window
+}
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := New(2, time.Hour)
+	addr := netip.MustParseAddr("203.0.113.7")
+	now := time.Unix(1700000000, 0)
+
+	for i := 0; i < 2; i++ {
+		if wait := l.Reserve(addr, now.Add(time.Duration(i)*time.Minute)); wait != 0 {
+			t.Fatalf("Reserve() for act %d = %v, want 0", i+1, wait)
+		}
+	}
+
+	// The window runs from the client's first act
+	if wait := l.Reserve(addr, now.Add(10*time.Minute)); wait != 50*time.Minute {
+		t.Errorf("Reserve() past the limit = %v, want 50m", wait)
+	}
+	if wait := l.Reserve(netip.MustParseAddr("203.0.113.8"), now); wait != 0 {
+		t.Errorf("Reserve(other client) = %v, want 0", wait)
+	}
+	if wait := l.Reserve(addr, now.Add(time.Hour)); wait != 0 {
+		t.Errorf("Reserve() once the window ended = %v, want 0", wait)
+	}
+	if wait := l.Reserve(addr, now.Add(time.Hour)); wait != 0 {
+		t.Errorf("Reserve() after one act in a new window = %v, want 0", wait)
+	}
+}
+
+func TestLimiter_Release(t *testing.T) {
+	l := New(1, time.Hour)
+	addr := netip.MustParseAddr("203.0.113.7")
+	now := time.Unix(1700000000, 0)
+
+	if wait := l.Reserve(addr, now); wait != 0 {
+		t.Fatalf("Reserve() = %v, want 0", wait)
+	}
+	l.Release(addr, now)
+	if wait := l.Reserve(addr, now.Add(time.Minute)); wait != 0 {
+		t.Errorf("Reserve() after a release = %v, want 0", wait)
+	}
+
+	// A release from an ended window gives nothing back in the new one
+	if wait := l.Reserve(addr, now.Add(time.Hour)); wait != 0 {
+		t.Fatalf("Reserve() in a new window = %v, want 0", wait)
+	}
+	l.Release(addr, now.Add(time.Minute))
+	if wait := l.Reserve(addr, now.Add(time.Hour)); wait == 0 {
+		t.Error("Reserve() after releasing an act of the ended window = 0, want a wait")
+	}
+}
+
+func TestLimiter_ConcurrentReserves(t *testing.T) {
+	l := New(3, time.Hour)
+	addr := netip.MustParseAddr("203.0.113.7")
+	now := time.Now()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l.Reserve(addr, now) == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 3 {
+		t.Errorf("%d concurrent reserves allowed, want 3", allowed)
+	}
+}
+
+func TestLimiter_IPv6Networks(t *testing.T) {
+	l := New(1, time.Minute)
+	now := time.Unix(1700000000, 0)
+
+	l.Reserve(netip.MustParseAddr("2001:db8:1:2::1"), now)
+	if wait := l.Reserve(netip.MustParseAddr("2001:db8:1:2:ffff::9"), now); wait != time.Minute {
+		t.Errorf("Reserve(same /64) = %v, want 1m", wait)
+	}
+	if wait := l.Reserve(netip.MustParseAddr("2001:db8:1:3::1"), now); wait != 0 {
+		t.Errorf("Reserve(other /64) = %v, want 0", wait)
+	}
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	var l *Limiter
+	addr := netip.MustParseAddr("203.0.113.7")
+	l.Release(addr, time.Now())
+	if wait := l.Reserve(addr, time.Now()); wait != 0 {
+		t.Errorf("nil Reserve() = %v, want 0", wait)
+	}
+}
@@ -0,0 +1,104 @@
+// Package signing verifies requests signed with a database key instead of carrying it,
+// so the key never passes through proxies and logs in a form that can be replayed
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request headers of a signed request
+const (
+	HeaderCredential = "X-JSONDrop-Credential" // Database ID and key type, as db_abc123/write or db_abc123/read
+	HeaderTimestamp  = "X-JSONDrop-Timestamp"  // Unix seconds the request was signed at
+	HeaderNonce      = "X-JSONDrop-Nonce"      // Random value, unique per request
+	HeaderSignature  = "X-JSONDrop-Signature"  // sha256=hex HMAC of StringToSign with the key
+)
+
+// Window is how far a request's timestamp may be from the server's clock
+const Window = 5 * time.Minute
+
+// Nonces must be this long, so they can't be guessed and are unlikely to repeat
+const (
+	minNonceLength = 8
+	maxNonceLength = 128
+)
+
+// StringToSign returns what a request's signature covers: its method, path with query,
+// timestamp, nonce and the SHA-256 of its body, on separate lines
+func StringToSign(method string, uri string, timestamp string, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{method, uri, timestamp, nonce, hex.EncodeToString(sum[:])}, "\n")
+}
+
+// Sign returns the signature header value of a string to sign with key
+func Sign(key string, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(stringToSign))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ParseCredential splits a credential into its database ID and whether it names the
+// write key
+func ParseCredential(credential string) (string, bool, error) {
+	dbID, keyType, ok := strings.Cut(credential, "/")
+	if !ok || dbID == "" || (keyType != "write" && keyType != "read") {
+		return "", false, fmt.Errorf("invalid credential: expected database ID/write or database ID/read")
+	}
+	return dbID, keyType == "write", nil
+}
+
+// Verifier checks signatures and remembers the nonces it accepted until their requests'
+// timestamps leave the window, rejecting replays on this server
+type Verifier struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time // By credential and nonce, when they can be forgotten
+	pruned time.Time            // When forgettable nonces were last dropped
+}
+
+// NewVerifier creates a verifier
+func NewVerifier() *Verifier {
+	return &Verifier{nonces: make(map[string]time.Time)}
+}
+
+// Verify checks a request's signature with key and records its nonce
+func (v *Verifier) Verify(key string, credential string, method string, uri string, timestamp string, nonce string, body []byte, signature string, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: expected Unix seconds")
+	}
+	signedAt := time.Unix(seconds, 0)
+	if signedAt.Before(now.Add(-Window)) || signedAt.After(now.Add(Window)) {
+		return fmt.Errorf("invalid timestamp: more than %s from the server's clock", Window)
+	}
+	if len(nonce) < minNonceLength || len(nonce) > maxNonceLength {
+		return fmt.Errorf("invalid nonce: expected %d to %d characters", minNonceLength, maxNonceLength)
+	}
+	want := Sign(key, StringToSign(method, uri, timestamp, nonce, body))
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if now.Sub(v.pruned) >= time.Minute {
+		for seen, expires := range v.nonces {
+			if now.After(expires) {
+				delete(v.nonces, seen)
+			}
+		}
+		v.pruned = now
+	}
+	seen := credential + "\n" + nonce
+	if expires, ok := v.nonces[seen]; ok && !now.After(expires) {
+		return fmt.Errorf("invalid nonce: already used")
+	}
+	v.nonces[seen] = signedAt.Add(Window)
+	return nil
+}
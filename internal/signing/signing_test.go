@@ -0,0 +1,72 @@
+package signing
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseCredential(t *testing.T) {
+	if dbID, write, err := ParseCredential("db_abc/write"); err != nil || dbID != "db_abc" || !write {
+		t.Errorf("ParseCredential(db_abc/write) = %q, %v, %v", dbID, write, err)
+	}
+	if dbID, write, err := ParseCredential("db_abc/read"); err != nil || dbID != "db_abc" || write {
+		t.Errorf("ParseCredential(db_abc/read) = %q, %v, %v", dbID, write, err)
+	}
+	for _, credential := range []string{"db_abc", "db_abc/admin", "/write", "wk_secret"} {
+		if _, _, err := ParseCredential(credential); err == nil {
+			t.Errorf("ParseCredential(%q) error = nil, want an error", credential)
+		}
+	}
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	const key = "wk_secretwritekey123"
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"data":{"title":"hello"}}`)
+	sign := func(method, uri, timestamp, nonce string, body []byte) string {
+		return Sign(key, StringToSign(method, uri, timestamp, nonce, body))
+	}
+
+	v := NewVerifier()
+	signature := sign("POST", "/api/databases/db_abc/posts", timestamp, "nonce-0001", body)
+	if err := v.Verify(key, "db_abc/write", "POST", "/api/databases/db_abc/posts", timestamp, "nonce-0001", body, signature, now); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if err := v.Verify(key, "db_abc/write", "POST", "/api/databases/db_abc/posts", timestamp, "nonce-0001", body, signature, now); err == nil {
+		t.Error("Verify(replayed request) error = nil, want an error")
+	}
+
+	stale := strconv.FormatInt(now.Add(-Window-time.Second).Unix(), 10)
+	tests := []struct {
+		name      string
+		method    string
+		timestamp string
+		nonce     string
+		body      string
+		signature string
+	}{
+		{"other key", "POST", timestamp, "nonce-0002", string(body), Sign("wk_other", StringToSign("POST", "/api/databases/db_abc/posts", timestamp, "nonce-0002", body))},
+		{"changed body", "POST", timestamp, "nonce-0003", `{"data":{"title":"evil"}}`, sign("POST", "/api/databases/db_abc/posts", timestamp, "nonce-0003", body)},
+		{"changed method", "DELETE", timestamp, "nonce-0004", string(body), sign("POST", "/api/databases/db_abc/posts", timestamp, "nonce-0004", body)},
+		{"stale", "POST", stale, "nonce-0005", string(body), sign("POST", "/api/databases/db_abc/posts", stale, "nonce-0005", body)},
+		{"short nonce", "POST", timestamp, "n1", string(body), sign("POST", "/api/databases/db_abc/posts", timestamp, "n1", body)},
+		{"bad timestamp", "POST", "soon", "nonce-0006", string(body), sign("POST", "/api/databases/db_abc/posts", "soon", "nonce-0006", body)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.Verify(key, "db_abc/write", tt.method, "/api/databases/db_abc/posts", tt.timestamp, tt.nonce, []byte(tt.body), tt.signature, now); err == nil {
+				t.Error("Verify() error = nil, want an error")
+			}
+		})
+	}
+
+	// Nonces can be reused once their requests are outside the window
+	later := now.Add(2*Window + time.Second)
+	laterStamp := strconv.FormatInt(later.Unix(), 10)
+	signature = sign("POST", "/api/databases/db_abc/posts", laterStamp, "nonce-0001", body)
+	if err := v.Verify(key, "db_abc/write", "POST", "/api/databases/db_abc/posts", laterStamp, "nonce-0001", body, signature, later); err != nil {
+		t.Errorf("Verify(nonce reused after the window) error = %v", err)
+	}
+}
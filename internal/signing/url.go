@@ -0,0 +1,71 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query parameters of a signed URL, which carries its signature in place of a key
+const (
+	ParamAccess    = "X-JSONDrop-Access"    // Key type the URL reads as, write or read
+	ParamExpires   = "X-JSONDrop-Expires"   // Unix seconds the URL stops working at
+	ParamSignature = "X-JSONDrop-Signature" // sha256=hex HMAC of URLStringToSign with the write key
+)
+
+// urlScheme starts what signed URLs sign, so their signatures can't pass for a request's
+const urlScheme = "JSONDROP-URL"
+
+// URLStringToSign returns what a signed URL's signature covers: its path, its other
+// query parameters sorted by name, the key type it reads as and its expiry, on separate
+// lines. Signed URLs are only good for GET
+func URLStringToSign(path string, query url.Values, access string, expires string) string {
+	return strings.Join([]string{urlScheme, "GET", path, unsignedQuery(query).Encode(), access, expires}, "\n")
+}
+
+// SignURL returns query with the parameters making path?query readable as access until
+// expires, signed with the database's write key
+func SignURL(key string, path string, query url.Values, access string, expires time.Time) url.Values {
+	signed := unsignedQuery(query)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	signed.Set(ParamAccess, access)
+	signed.Set(ParamExpires, exp)
+	signed.Set(ParamSignature, Sign(key, URLStringToSign(path, query, access, exp)))
+	return signed
+}
+
+// VerifyURL checks a signed URL's signature with the database's write key and returns
+// the key type it reads as
+func VerifyURL(key string, path string, query url.Values, now time.Time) (string, error) {
+	access := query.Get(ParamAccess)
+	if access != "write" && access != "read" {
+		return "", fmt.Errorf("invalid signed URL: %s must be write or read", ParamAccess)
+	}
+	exp := query.Get(ParamExpires)
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid signed URL: %s must be Unix seconds", ParamExpires)
+	}
+	want := Sign(key, URLStringToSign(path, query, access, exp))
+	if !hmac.Equal([]byte(query.Get(ParamSignature)), []byte(want)) {
+		return "", fmt.Errorf("invalid signed URL: signature mismatch")
+	}
+	if now.Unix() >= expires {
+		return "", fmt.Errorf("invalid signed URL: expired")
+	}
+	return access, nil
+}
+
+// unsignedQuery returns a copy of query without the parameters SignURL adds
+func unsignedQuery(query url.Values) url.Values {
+	unsigned := make(url.Values, len(query))
+	for name, values := range query {
+		if name != ParamAccess && name != ParamExpires && name != ParamSignature {
+			unsigned[name] = values
+		}
+	}
+	return unsigned
+}
@@ -0,0 +1,63 @@
+package signing
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVerifyURL(t *testing.T) {
+	const key = "wk_secretwritekey123"
+	const path = "/api/databases/db_abc/users/export"
+	now := time.Unix(1700000000, 0)
+
+	query := SignURL(key, path, url.Values{"format": {"csv"}}, "read", now.Add(time.Hour))
+	if query.Get("format") != "csv" || query.Get(ParamSignature) == "" {
+		t.Fatalf("SignURL() = %v, want format kept and a signature", query)
+	}
+	if access, err := VerifyURL(key, path, query, now); err != nil || access != "read" {
+		t.Fatalf("VerifyURL() = %q, %v, want read", access, err)
+	}
+	// The parameters can be given in any order
+	reordered, err := url.ParseQuery("format=csv&" + ParamSignature + "=" + url.QueryEscape(query.Get(ParamSignature)) +
+		"&" + ParamExpires + "=" + query.Get(ParamExpires) + "&" + ParamAccess + "=read")
+	if err != nil {
+		t.Fatalf("ParseQuery() error = %v", err)
+	}
+	if _, err := VerifyURL(key, path, reordered, now); err != nil {
+		t.Errorf("VerifyURL(reordered) error = %v", err)
+	}
+
+	tamper := func(change func(url.Values)) url.Values {
+		tampered := url.Values{}
+		for name, values := range query {
+			tampered[name] = values
+		}
+		change(tampered)
+		return tampered
+	}
+	tests := []struct {
+		name  string
+		key   string
+		path  string
+		query url.Values
+		now   time.Time
+	}{
+		{"other key", "wk_other", path, query, now},
+		{"other path", key, "/api/databases/db_abc/admins/export", query, now},
+		{"changed parameter", key, path, tamper(func(q url.Values) { q.Set("format", "ndjson") }), now},
+		{"added parameter", key, path, tamper(func(q url.Values) { q.Set("limit", "1") }), now},
+		{"write access", key, path, tamper(func(q url.Values) { q.Set(ParamAccess, "write") }), now},
+		{"later expiry", key, path, tamper(func(q url.Values) { q.Set(ParamExpires, "1800000000") }), now},
+		{"bad expiry", key, path, tamper(func(q url.Values) { q.Set(ParamExpires, "soon") }), now},
+		{"no signature", key, path, tamper(func(q url.Values) { q.Del(ParamSignature) }), now},
+		{"expired", key, path, query, now.Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyURL(tt.key, tt.path, tt.query, tt.now); err == nil {
+				t.Error("VerifyURL() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/models"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// kafkaPort is the Kafka port, used for servers given without one
+const kafkaPort = "9092"
+
+// kafkaTransport produces each event as a record of the topic, keyed by database ID
+// Records of a database all go to one partition, chosen from the key the way Kafka's Java
+// client does, so they are consumed in order; the event type is in the event_type header
+type kafkaTransport struct {
+	client  *kgo.Client
+	timeout time.Duration // For a batch to be acknowledged
+}
+
+// newKafka creates a Kafka transport for bootstrap servers given as host[:port]
+func newKafka(cfg config.EventSinkConfig, tlsConfig *tls.Config) (*kafkaTransport, error) {
+	var seeds []string
+	for _, server := range cfg.Servers {
+		if strings.Contains(server, "://") {
+			return nil, fmt.Errorf("invalid Kafka server %q: expected host[:port]", server)
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, kafkaPort)
+		}
+		seeds = append(seeds, server)
+	}
+
+	options := []kgo.Opt{
+		kgo.SeedBrokers(seeds...),
+		kgo.ClientID("jsondrop"),
+		kgo.DefaultProduceTopic(cfg.Topic),
+		kgo.AllowAutoTopicCreation(),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		kgo.RecordPartitioner(kgo.StickyKeyPartitioner(nil)),
+		kgo.DialTimeout(dialTimeout),
+	}
+	if tlsConfig != nil {
+		options = append(options, kgo.DialTLSConfig(tlsConfig))
+	}
+	if mechanism := saslMechanism(cfg); mechanism != nil {
+		options = append(options, kgo.SASL(mechanism))
+	}
+	client, err := kgo.NewClient(options...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kafka configuration: %w", err)
+	}
+	return &kafkaTransport{client: client, timeout: publishTimeout}, nil
+}
+
+// saslMechanism returns the SASL mechanism to authenticate with, or nil for none
+func saslMechanism(cfg config.EventSinkConfig) sasl.Mechanism {
+	switch cfg.SASLMechanism {
+	case config.SASLPlain:
+		return plain.Auth{User: cfg.Username, Pass: cfg.Password}.AsMechanism()
+	case config.SASLScramSHA256:
+		return scram.Auth{User: cfg.Username, Pass: cfg.Password}.AsSha256Mechanism()
+	case config.SASLScramSHA512:
+		return scram.Auth{User: cfg.Username, Pass: cfg.Password}.AsSha512Mechanism()
+	default:
+		return nil
+	}
+}
+
+// publish produces the events and waits for every record to be acknowledged
+func (t *kafkaTransport) publish(events []models.ChangeEvent) error {
+	records := make([]*kgo.Record, 0, len(events))
+	for _, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("sink: skipped %s event of %s/%s: %v", event.EventType, event.DatabaseID, event.Collection, err)
+			continue
+		}
+		records = append(records, &kgo.Record{
+			Key:       []byte(event.DatabaseID),
+			Value:     value,
+			Headers:   []kgo.RecordHeader{{Key: "event_type", Value: []byte(event.EventType)}},
+			Timestamp: event.Timestamp,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+	if err := t.client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		return fmt.Errorf("failed to produce to Kafka: %w", err)
+	}
+	return nil
+}
+
+// close closes the client
+func (t *kafkaTransport) close() {
+	t.client.Close()
+}
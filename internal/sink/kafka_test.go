@@ -0,0 +1,121 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/models"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// consume reads n records of a topic from the start
+func consume(t *testing.T, seeds []string, topic string, n int) []*kgo.Record {
+	t.Helper()
+	client, err := kgo.NewClient(kgo.SeedBrokers(seeds...), kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var records []*kgo.Record
+	for len(records) < n {
+		fetches := client.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("consumed %d records, want %d", len(records), n)
+		}
+		records = append(records, fetches.Records()...)
+	}
+	return records
+}
+
+func TestKafka_Publish(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(3), kfake.SeedTopics(3, "changes"))
+	if err != nil {
+		t.Fatalf("NewCluster() error = %v", err)
+	}
+	defer cluster.Close()
+
+	transport, err := newKafka(config.EventSinkConfig{Servers: cluster.ListenAddrs(), Topic: "changes"}, nil)
+	if err != nil {
+		t.Fatalf("newKafka() error = %v", err)
+	}
+	defer transport.close()
+
+	now := time.Now()
+	events := []models.ChangeEvent{
+		{EventType: "insert", DatabaseID: "db_a", Collection: "posts", DocumentID: "doc_1", Timestamp: now},
+		{EventType: "insert", DatabaseID: "db_b", Collection: "posts", DocumentID: "doc_2", Timestamp: now},
+		{EventType: "delete", DatabaseID: "db_a", Collection: "posts", DocumentID: "doc_1", Timestamp: now.Add(time.Millisecond)},
+	}
+	if err := transport.publish(events); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+
+	// Each database's records arrive in order, on one partition
+	got := map[string][]*kgo.Record{}
+	for _, record := range consume(t, cluster.ListenAddrs(), "changes", len(events)) {
+		got[string(record.Key)] = append(got[string(record.Key)], record)
+	}
+	for _, event := range events {
+		record := got[event.DatabaseID][0]
+		got[event.DatabaseID] = got[event.DatabaseID][1:]
+		var decoded models.ChangeEvent
+		json.Unmarshal(record.Value, &decoded)
+		eventType := ""
+		if len(record.Headers) == 1 && record.Headers[0].Key == "event_type" {
+			eventType = string(record.Headers[0].Value)
+		}
+		if eventType != event.EventType || decoded.DocumentID != event.DocumentID || decoded.EventType != event.EventType {
+			t.Errorf("record %s with headers %v, want the %s of %s", record.Value, record.Headers, event.EventType, event.DocumentID)
+		}
+	}
+	if first, second := got["db_a"], got["db_b"]; len(first) != 0 || len(second) != 0 {
+		t.Errorf("records left over: %v %v", first, second)
+	}
+}
+
+func TestKafka_SASL(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, "changes"),
+		kfake.EnableSASL(), kfake.Superuser("SCRAM-SHA-256", "jsondrop", "s3cret"))
+	if err != nil {
+		t.Fatalf("NewCluster() error = %v", err)
+	}
+	defer cluster.Close()
+
+	event := models.ChangeEvent{EventType: "insert", DatabaseID: "db_a", DocumentID: "doc_1", Timestamp: time.Now()}
+	cfg := config.EventSinkConfig{Servers: cluster.ListenAddrs(), Topic: "changes", Username: "jsondrop",
+		Password: "s3cret", SASLMechanism: config.SASLScramSHA256}
+	transport, err := newKafka(cfg, nil)
+	if err != nil {
+		t.Fatalf("newKafka() error = %v", err)
+	}
+	defer transport.close()
+	if err := transport.publish([]models.ChangeEvent{event}); err != nil {
+		t.Errorf("publish() error = %v", err)
+	}
+
+	cfg.Password = "wrong"
+	wrong, _ := newKafka(cfg, nil)
+	defer wrong.close()
+	wrong.timeout = time.Second
+	if err := wrong.publish([]models.ChangeEvent{event}); err == nil {
+		t.Error("publish() with the wrong password error = nil, want an error")
+	}
+}
+
+func TestKafka_UnreachableBootstrap(t *testing.T) {
+	transport, _ := newKafka(config.EventSinkConfig{Servers: []string{"127.0.0.1:1"}, Topic: "changes"}, nil)
+	defer transport.close()
+	transport.timeout = 500 * time.Millisecond
+	if err := transport.publish([]models.ChangeEvent{{EventType: "insert", DatabaseID: "db_a"}}); err == nil {
+		t.Error("publish() error = nil, want the connection failure")
+	}
+}
@@ -0,0 +1,123 @@
+package sink
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/models"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsTransport publishes events on the subject <topic>.<database>.<collection>.<event
+// type>, so subscribers can pick them with wildcards; events without a collection use "_"
+type natsTransport struct {
+	servers string // Comma-separated server URLs, tried in turn
+	topic   string
+	options []nats.Option
+	conn    *nats.Conn
+}
+
+// newNATS creates a NATS transport for servers given as host[:port], or as nats:// or
+// tls:// URLs that may carry a user and password, or a token
+func newNATS(cfg config.EventSinkConfig, tlsConfig *tls.Config) (*natsTransport, error) {
+	var servers []string
+	for _, raw := range cfg.Servers {
+		server, err := natsURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, server)
+	}
+
+	options := []nats.Option{
+		nats.Name("jsondrop"),
+		nats.Timeout(dialTimeout),
+		nats.DontRandomize(),
+		nats.MaxReconnects(-1),
+		// Publishes fail while disconnected instead of being buffered, so the publisher
+		// retries them in order
+		nats.ReconnectBufSize(-1),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			log.Printf("sink: NATS error: %v", err)
+		}),
+	}
+	if tlsConfig != nil {
+		options = append(options, nats.Secure(tlsConfig))
+	}
+	if cfg.Username != "" {
+		options = append(options, nats.UserInfo(cfg.Username, cfg.Password))
+	}
+	if cfg.NATSCreds != "" {
+		options = append(options, nats.UserCredentials(cfg.NATSCreds))
+	}
+	return &natsTransport{servers: strings.Join(servers, ","), topic: cfg.Topic, options: options}, nil
+}
+
+// natsURL checks a server address, returning it as a URL
+func natsURL(raw string) (string, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "nats://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid NATS server %q: expected host[:port], nats://host[:port] or tls://host[:port]", raw)
+	}
+	return u.String(), nil
+}
+
+// Subject returns the NATS subject an event is published on
+func Subject(topic string, event models.ChangeEvent) string {
+	collection := event.Collection
+	if collection == "" {
+		collection = "_"
+	}
+	return topic + "." + event.DatabaseID + "." + collection + "." + event.EventType
+}
+
+// publish sends each event, then flushes, which waits for the server to have processed
+// everything sent before it
+func (t *natsTransport) publish(events []models.ChangeEvent) error {
+	if t.conn == nil || t.conn.IsClosed() {
+		conn, err := nats.Connect(t.servers, t.options...)
+		if err != nil {
+			return fmt.Errorf("failed to connect to NATS: %w", err)
+		}
+		t.conn = conn
+	}
+
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("sink: skipped %s event of %s/%s: %v", event.EventType, event.DatabaseID, event.Collection, err)
+			continue
+		}
+		err = t.conn.Publish(Subject(t.topic, event), payload)
+		if errors.Is(err, nats.ErrMaxPayload) {
+			log.Printf("sink: skipped %s event of %s/%s: %d bytes is over the server's max_payload of %d",
+				event.EventType, event.DatabaseID, event.Collection, len(payload), t.conn.MaxPayload())
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to publish to NATS: %w", err)
+		}
+	}
+	if err := t.conn.FlushTimeout(publishTimeout); err != nil {
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+	return nil
+}
+
+// close closes the connection
+func (t *natsTransport) close() {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
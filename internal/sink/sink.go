@@ -0,0 +1,203 @@
+// Package sink publishes every change event to a message broker, NATS or Kafka, so
+// pipelines can consume changes without holding event streams open
+// Brokers are spoken to with their maintained Go clients, nats.go and franz-go, which
+// handle TLS, authentication, reconnects and Kafka partition leadership
+package sink
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"jsondrop/internal/config"
+	"jsondrop/internal/models"
+)
+
+// Events wait in a queue of up to maxQueue while the broker is unreachable, the oldest
+// being dropped beyond it, and are published up to maxBatch at a time. Failed batches
+// are retried after firstBackoff, doubling up to maxBackoff
+const (
+	maxQueue       = 10000
+	maxBatch       = 500
+	firstBackoff   = time.Second
+	maxBackoff     = 30 * time.Second
+	dialTimeout    = 10 * time.Second
+	publishTimeout = 10 * time.Second // For a batch to be acknowledged
+)
+
+// transport publishes batches of events to a broker, connecting as needed
+// A batch is published in full or fails; the client reconnects on its own
+type transport interface {
+	publish(events []models.ChangeEvent) error
+	close()
+}
+
+// Publisher publishes the change events it observes to a broker
+// Events are queued and published in order on one goroutine, so writers never wait on
+// the broker. Events are delivered at least once while the server runs: a batch that
+// fails is published again in full, and queued events are lost on restart
+type Publisher struct {
+	transport    transport
+	mu           sync.Mutex
+	queue        []models.ChangeEvent
+	dropped      int // Events dropped from a full queue since last logged
+	inflight     int // Events in the batch being published
+	wake         chan struct{}
+	idle         chan struct{} // Signalled when the queue drains
+	firstBackoff time.Duration
+}
+
+// New creates a publisher for an EVENT_SINK configuration and starts publishing the
+// events it observes; the broker is first contacted with the first event
+func New(cfg config.EventSinkConfig) (*Publisher, error) {
+	tlsConfig, err := clientTLS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var t transport
+	switch cfg.Kind {
+	case config.EventSinkNATS:
+		t, err = newNATS(cfg, tlsConfig)
+	case config.EventSinkKafka:
+		t, err = newKafka(cfg, tlsConfig)
+	default:
+		return nil, fmt.Errorf("unknown event sink %q", cfg.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newPublisher(t), nil
+}
+
+// clientTLS returns the TLS configuration to connect to brokers with, or nil without TLS
+func clientTLS(cfg config.EventSinkConfig) (*tls.Config, error) {
+	if !cfg.TLS {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read EVENT_SINK_TLS_CA_FILE: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid EVENT_SINK_TLS_CA_FILE: no PEM certificates in %s", cfg.CAFile)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// newPublisher creates a publisher over a transport and starts it
+func newPublisher(t transport) *Publisher {
+	p := &Publisher{
+		transport:    t,
+		wake:         make(chan struct{}, 1),
+		idle:         make(chan struct{}, 1),
+		firstBackoff: firstBackoff,
+	}
+	go p.run()
+	return p
+}
+
+// Observe queues a change event for publishing
+// It is registered with the broadcaster, and never blocks
+func (p *Publisher) Observe(event models.ChangeEvent) {
+	p.mu.Lock()
+	p.queue = append(p.queue, event)
+	p.trim()
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+		// Already woken; the queue is drained in full
+	}
+}
+
+// trim drops the oldest events beyond maxQueue; the caller holds mu
+func (p *Publisher) trim() {
+	if over := len(p.queue) - maxQueue; over > 0 {
+		p.queue = p.queue[over:]
+		p.dropped += over
+	}
+}
+
+// run publishes queued events until the process exits
+func (p *Publisher) run() {
+	var backoff time.Duration // Before the next retry; 0 after a success
+	for range p.wake {
+		for {
+			batch := p.take()
+			if len(batch) == 0 {
+				break
+			}
+			if err := p.transport.publish(batch); err != nil {
+				p.requeue(batch)
+				backoff = min(max(backoff*2, p.firstBackoff), maxBackoff)
+				log.Printf("sink: failed to publish %d events, retrying in %v: %v", len(batch), backoff, err)
+				time.Sleep(backoff)
+				continue
+			}
+			backoff = 0
+		}
+	}
+}
+
+// take removes the next batch from the queue, reporting events dropped before it
+func (p *Publisher) take() []models.ChangeEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.dropped > 0 {
+		log.Printf("sink: queue full, dropped %d events", p.dropped)
+		p.dropped = 0
+	}
+	n := min(len(p.queue), maxBatch)
+	batch := p.queue[:n:n]
+	p.queue = p.queue[n:]
+	p.inflight = n
+	if n == 0 {
+		select {
+		case p.idle <- struct{}{}:
+		default:
+		}
+	}
+	return batch
+}
+
+// requeue puts a failed batch back at the front of the queue
+func (p *Publisher) requeue(batch []models.ChangeEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queue = append(batch, p.queue...)
+	p.inflight = 0
+	p.trim()
+}
+
+// Flush waits up to timeout for the queued events to be published, then disconnects
+// Returns the number of events left unpublished
+func (p *Publisher) Flush(timeout time.Duration) int {
+	deadline := time.After(timeout)
+	for {
+		p.mu.Lock()
+		pending := len(p.queue) + p.inflight
+		p.mu.Unlock()
+		if pending == 0 {
+			break
+		}
+		select {
+		case <-p.idle:
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			return pending
+		}
+	}
+	p.transport.close()
+	return 0
+}
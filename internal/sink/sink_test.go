@@ -0,0 +1,74 @@
+package sink
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// flakyTransport fails its first publishes, then records what it publishes
+type flakyTransport struct {
+	mu        sync.Mutex
+	failures  int
+	published []string
+	closed    bool
+}
+
+func (f *flakyTransport) publish(events []models.ChangeEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("broker unavailable")
+	}
+	for _, event := range events {
+		f.published = append(f.published, event.DocumentID)
+	}
+	return nil
+}
+
+func (f *flakyTransport) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+}
+
+func TestPublisher_RetriesInOrder(t *testing.T) {
+	transport := &flakyTransport{failures: 2}
+	p := newPublisher(transport)
+	p.firstBackoff = 10 * time.Millisecond
+
+	for _, docID := range []string{"doc_1", "doc_2", "doc_3"} {
+		p.Observe(models.ChangeEvent{EventType: "insert", DatabaseID: "db_a", DocumentID: docID})
+	}
+	if left := p.Flush(2 * time.Second); left != 0 {
+		t.Fatalf("Flush() left %d events, want none", left)
+	}
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	var got string
+	for _, docID := range transport.published {
+		got += docID + " "
+	}
+	if got != "doc_1 doc_2 doc_3 " {
+		t.Errorf("published %s, want every event once, in order", got)
+	}
+	if !transport.closed {
+		t.Error("Flush() didn't close the transport")
+	}
+}
+
+func TestPublisher_FlushTimesOut(t *testing.T) {
+	transport := &flakyTransport{failures: 1000}
+	p := newPublisher(transport)
+	p.firstBackoff = 10 * time.Millisecond
+
+	p.Observe(models.ChangeEvent{EventType: "insert", DatabaseID: "db_a", DocumentID: "doc_1"})
+	if left := p.Flush(50 * time.Millisecond); left != 1 {
+		t.Errorf("Flush() left %d events, want 1", left)
+	}
+}
@@ -0,0 +1,63 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// challengePrefix starts proof-of-work challenges
+const challengePrefix = "ch_"
+
+// Challenge mints a proof-of-work challenge valid until expires. Its random part keeps
+// solutions from being worked out before it is issued
+func (i *Issuer) Challenge(expires time.Time) (string, error) {
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	body := challengePrefix + strconv.FormatInt(expires.Unix(), 10) + "." + base64.RawURLEncoding.EncodeToString(random)
+	return body + "." + i.sign(body), nil
+}
+
+// VerifyProof checks that a challenge was issued by i and hasn't expired, and that the
+// SHA-256 of the challenge, a colon and the solution starts with difficulty zero bits.
+// It returns when the challenge expires, until which callers keep it from being reused
+func (i *Issuer) VerifyProof(challenge string, solution string, difficulty int, now time.Time) (time.Time, error) {
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], challengePrefix) {
+		return time.Time{}, fmt.Errorf("invalid challenge: malformed")
+	}
+	expires, err := strconv.ParseInt(strings.TrimPrefix(parts[0], challengePrefix), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid challenge: malformed")
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(i.sign(parts[0]+"."+parts[1]))) {
+		return time.Time{}, fmt.Errorf("invalid challenge: not issued by this server")
+	}
+	if now.Unix() >= expires {
+		return time.Time{}, fmt.Errorf("invalid challenge: expired")
+	}
+	if leadingZeros(sha256.Sum256([]byte(challenge+":"+solution))) < difficulty {
+		return time.Time{}, fmt.Errorf("invalid solution: the hash needs %d leading zero bits", difficulty)
+	}
+	return time.Unix(expires, 0), nil
+}
+
+// leadingZeros counts the zero bits a hash starts with
+func leadingZeros(sum [sha256.Size]byte) int {
+	zeros := 0
+	for _, b := range sum {
+		zeros += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return zeros
+}
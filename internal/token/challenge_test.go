@@ -0,0 +1,83 @@
+package token
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// solve finds a solution to a challenge by brute force
+func solve(t *testing.T, i *Issuer, challenge string, difficulty int, now time.Time) string {
+	t.Helper()
+	for n := 0; n < 1<<20; n++ {
+		solution := strconv.Itoa(n)
+		if _, err := i.VerifyProof(challenge, solution, difficulty, now); err == nil {
+			return solution
+		}
+	}
+	t.Fatalf("no solution to %q found", challenge)
+	return ""
+}
+
+func TestIssuer_Challenge(t *testing.T) {
+	issuer, err := NewIssuer([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewIssuer() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	expires := now.Add(5 * time.Minute)
+
+	challenge, err := issuer.Challenge(expires)
+	if err != nil {
+		t.Fatalf("Challenge() error = %v", err)
+	}
+	if other, _ := issuer.Challenge(expires); other == challenge {
+		t.Errorf("Challenge() twice = %q, want challenges to differ", challenge)
+	}
+
+	const difficulty = 8
+	solution := solve(t, issuer, challenge, difficulty, now)
+	got, err := issuer.VerifyProof(challenge, solution, difficulty, now)
+	if err != nil || !got.Equal(expires) {
+		t.Fatalf("VerifyProof() = %v, %v; want %v, nil", got, err, expires)
+	}
+
+	other, _ := NewIssuer(nil)
+	tests := []struct {
+		name      string
+		issuer    *Issuer
+		challenge string
+		solution  string
+		now       time.Time
+	}{
+		{"expired", issuer, challenge, solution, expires},
+		{"other secret", other, challenge, solution, now},
+		{"extended", issuer, "ch_9999999999" + challenge[len("ch_1700000300"):], solution, now},
+		{"malformed", issuer, "ch_soon.abc", solution, now},
+		{"empty", issuer, "", solution, now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.issuer.VerifyProof(tt.challenge, tt.solution, difficulty, tt.now); err == nil || !strings.HasPrefix(err.Error(), "invalid challenge") {
+				t.Errorf("VerifyProof() error = %v, want an invalid challenge", err)
+			}
+		})
+	}
+
+	// Almost every other solution falls short of a hard enough difficulty
+	if _, err := issuer.VerifyProof(challenge, solution+"x", 24, now); err == nil || !strings.HasPrefix(err.Error(), "invalid solution") {
+		t.Errorf("VerifyProof(wrong solution) error = %v, want an invalid solution", err)
+	}
+}
+
+func TestLeadingZeros(t *testing.T) {
+	var sum [32]byte
+	if got := leadingZeros(sum); got != 256 {
+		t.Errorf("leadingZeros(zero) = %d, want 256", got)
+	}
+	sum[1] = 0x10
+	if got := leadingZeros(sum); got != 11 {
+		t.Errorf("leadingZeros(00 10 ...) = %d, want 11", got)
+	}
+}
@@ -0,0 +1,40 @@
+package token
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// confirmPrefix starts confirmation tokens, which can't be mistaken for access tokens
+const confirmPrefix = "cf_"
+
+// Confirmation mints a token confirming action on a database until expires, for
+// destructive requests that must be repeated with it to take effect
+func (i *Issuer) Confirmation(dbID string, action string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return confirmPrefix + exp + "." + i.sign(confirmationInput(dbID, action, exp))
+}
+
+// VerifyConfirmation checks that a token confirms action on a database and hasn't expired
+func (i *Issuer) VerifyConfirmation(token string, dbID string, action string, now time.Time) error {
+	exp, signature, ok := strings.Cut(strings.TrimPrefix(token, confirmPrefix), ".")
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if !strings.HasPrefix(token, confirmPrefix) || !ok || err != nil {
+		return fmt.Errorf("invalid confirmation: malformed")
+	}
+	if !hmac.Equal([]byte(signature), []byte(i.sign(confirmationInput(dbID, action, exp)))) {
+		return fmt.Errorf("invalid confirmation: not issued for this request")
+	}
+	if now.Unix() >= expires {
+		return fmt.Errorf("invalid confirmation: expired")
+	}
+	return nil
+}
+
+// confirmationInput is what a confirmation token signs
+func confirmationInput(dbID string, action string, exp string) string {
+	return confirmPrefix + dbID + "\x00" + action + "\x00" + exp
+}
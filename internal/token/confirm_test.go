@@ -0,0 +1,47 @@
+package token
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIssuer_Confirmation(t *testing.T) {
+	issuer, err := NewIssuer([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewIssuer() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	confirmation := issuer.Confirmation("db_abc", "delete_database", now.Add(time.Minute))
+	if Looks(confirmation) {
+		t.Errorf("Looks(%q) = true, want confirmations told from access tokens", confirmation)
+	}
+	if err := issuer.VerifyConfirmation(confirmation, "db_abc", "delete_database", now.Add(59*time.Second)); err != nil {
+		t.Fatalf("VerifyConfirmation() error = %v", err)
+	}
+
+	other, _ := NewIssuer(nil)
+	tests := []struct {
+		name   string
+		issuer *Issuer
+		token  string
+		dbID   string
+		action string
+		now    time.Time
+	}{
+		{"expired", issuer, confirmation, "db_abc", "delete_database", now.Add(time.Minute)},
+		{"other database", issuer, confirmation, "db_other", "delete_database", now},
+		{"other action", issuer, confirmation, "db_abc", "delete_schema:users", now},
+		{"other secret", other, confirmation, "db_abc", "delete_database", now},
+		{"extended", issuer, "cf_9999999999" + confirmation[len("cf_1700000060"):], "db_abc", "delete_database", now},
+		{"malformed", issuer, "cf_soon.abc", "db_abc", "delete_database", now},
+		{"empty", issuer, "", "db_abc", "delete_database", now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.issuer.VerifyConfirmation(tt.token, tt.dbID, tt.action, tt.now); err == nil {
+				t.Error("VerifyConfirmation() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package token
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sessionPrefix starts web console sessions, which can't be mistaken for access tokens
+const sessionPrefix = "se_"
+
+// Session is a person signed in to the web console, kept in a cookie
+type Session struct {
+	Subject   string `json:"sub"`             // Identity provider's ID of the person
+	Email     string `json:"email,omitempty"` // Verified email, if the provider shared it
+	Name      string `json:"name,omitempty"`
+	CSRF      string `json:"csrf"` // Random value the console's forms must send back
+	ExpiresAt int64  `json:"exp"`
+}
+
+// SignSession encodes a session and signs it
+func (i *Issuer) SignSession(session Session) (string, error) {
+	payload, err := json.Marshal(session)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session: %w", err)
+	}
+	signed := sessionPrefix + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + i.sign(signed), nil
+}
+
+// VerifySession checks a session's signature and expiry and returns it
+func (i *Issuer) VerifySession(value string, now time.Time) (*Session, error) {
+	signed, signature, ok := strings.Cut(value, ".")
+	if !ok || !strings.HasPrefix(signed, sessionPrefix) {
+		return nil, fmt.Errorf("invalid session: malformed")
+	}
+	if !hmac.Equal([]byte(signature), []byte(i.sign(signed))) {
+		return nil, fmt.Errorf("invalid session: bad signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(signed, sessionPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid session: malformed")
+	}
+	var session Session
+	if err := json.Unmarshal(payload, &session); err != nil || session.Subject == "" {
+		return nil, fmt.Errorf("invalid session: malformed")
+	}
+	if now.Unix() >= session.ExpiresAt {
+		return nil, fmt.Errorf("invalid session: expired")
+	}
+	return &session, nil
+}
@@ -0,0 +1,52 @@
+package token
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIssuer_Session(t *testing.T) {
+	issuer, err := NewIssuer([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewIssuer() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	want := Session{Subject: "user-1", Email: "ada@example.com", Name: "Ada", CSRF: "c5rf", ExpiresAt: now.Add(time.Hour).Unix()}
+	value, err := issuer.SignSession(want)
+	if err != nil {
+		t.Fatalf("SignSession() error = %v", err)
+	}
+	if Looks(value) {
+		t.Errorf("Looks(%q) = true, want sessions told from access tokens", value)
+	}
+	got, err := issuer.VerifySession(value, now)
+	if err != nil {
+		t.Fatalf("VerifySession() error = %v", err)
+	}
+	if *got != want {
+		t.Errorf("VerifySession() = %+v, want %+v", got, want)
+	}
+
+	other, _ := NewIssuer(nil)
+	confirmation := issuer.Confirmation("db_abc", "delete_database", now.Add(time.Hour))
+	tests := []struct {
+		name   string
+		issuer *Issuer
+		value  string
+		now    time.Time
+	}{
+		{"expired", issuer, value, now.Add(time.Hour)},
+		{"other secret", other, value, now},
+		{"tampered", issuer, value[:len(value)-1] + "x", now},
+		{"confirmation", issuer, confirmation, now},
+		{"empty", issuer, "", now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.issuer.VerifySession(tt.value, tt.now); err == nil {
+				t.Error("VerifySession() error = nil, want an error")
+			}
+		})
+	}
+}
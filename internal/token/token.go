@@ -0,0 +1,130 @@
+// Package token mints and verifies the short-lived access tokens databases hand to
+// browsers in place of their long-lived keys: JSON Web Tokens signed with HMAC-SHA256
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// issuer names this server in the tokens it mints
+const issuer = "jsondrop"
+
+// header is the encoded JOSE header of every token; tokens with any other header are
+// rejected, so the algorithm can't be downgraded
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims are the contents of a token
+type Claims struct {
+	Issuer      string   `json:"iss"`
+	DatabaseID  string   `json:"sub"`
+	ID          string   `json:"jti"`
+	IssuedAt    int64    `json:"iat"`
+	ExpiresAt   int64    `json:"exp"`
+	Access      string   `json:"access"`                // "write" or "read"
+	Collections []string `json:"collections,omitempty"` // Collections it is restricted to; empty allows all
+	Generation  int64    `json:"gen"`                   // The database's key generation when minted; rotating the keys revokes the token
+	Caller      string   `json:"caller,omitempty"`      // Who the token was minted for, such as a user ID; "sub" names the database
+}
+
+// PolicyClaims returns the claims read filters see as token.<claim>
+// token.sub is the caller rather than the database, and is missing without one, so
+// filters such as data.owner_id == token.sub only match the caller's documents
+func (c *Claims) PolicyClaims() map[string]interface{} {
+	claims := map[string]interface{}{
+		"database_id": c.DatabaseID,
+		"access":      c.Access,
+		"iat":         float64(c.IssuedAt),
+		"exp":         float64(c.ExpiresAt),
+	}
+	if c.Caller != "" {
+		claims["sub"] = c.Caller
+	}
+	return claims
+}
+
+// Issuer signs and verifies tokens with one secret
+type Issuer struct {
+	secret []byte
+}
+
+// NewIssuer creates an issuer signing with secret, or with a random secret when it is
+// empty, in which case tokens stop working when the server restarts
+func NewIssuer(secret []byte) (*Issuer, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate token secret: %w", err)
+		}
+	}
+	return &Issuer{secret: secret}, nil
+}
+
+// Mint signs a token for claims lasting ttl from now, filling in its issuer, ID and times
+func (i *Issuer) Mint(claims Claims, ttl time.Duration, now time.Time) (string, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+	claims.Issuer = issuer
+	claims.ID = base64.RawURLEncoding.EncodeToString(id)
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode token: %w", err)
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + i.sign(signed), nil
+}
+
+// Verify checks a token's signature and expiry and returns its claims
+func (i *Issuer) Verify(token string, now time.Time) (*Claims, error) {
+	signed, signature, ok := strings.Cut(token, ".")
+	if ok {
+		var payload string
+		payload, signature, ok = strings.Cut(signature, ".")
+		signed += "." + payload
+	}
+	if !ok || !strings.HasPrefix(signed, header+".") {
+		return nil, fmt.Errorf("invalid token: malformed")
+	}
+	if !hmac.Equal([]byte(signature), []byte(i.sign(signed))) {
+		return nil, fmt.Errorf("invalid token: bad signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(signed, header+"."))
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: malformed payload")
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("invalid token: malformed payload")
+	}
+	if claims.Issuer != issuer || claims.DatabaseID == "" {
+		return nil, fmt.Errorf("invalid token: not issued by this server")
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("invalid token: expired")
+	}
+	return &claims, nil
+}
+
+// sign returns the encoded signature of a token's header and payload
+func (i *Issuer) sign(signed string) string {
+	mac := hmac.New(sha256.New, i.secret)
+	mac.Write([]byte(signed))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Looks reports whether a credential is shaped like a token rather than an API key
+func Looks(credential string) bool {
+	return strings.Count(credential, ".") == 2
+}
@@ -0,0 +1,72 @@
+package token
+
+import (
+	"encoding/base64"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssuer_MintVerify(t *testing.T) {
+	issuer, err := NewIssuer([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewIssuer() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	token, err := issuer.Mint(Claims{DatabaseID: "db_abc", Access: "write", Collections: []string{"telemetry"}, Generation: 3, Caller: "user_42"}, time.Minute, now)
+	if err != nil {
+		t.Fatalf("Mint() error = %v", err)
+	}
+	if !Looks(token) || Looks("wk_abc") {
+		t.Errorf("Looks() doesn't tell tokens from keys")
+	}
+
+	claims, err := issuer.Verify(token, now.Add(59*time.Second))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.DatabaseID != "db_abc" || claims.Access != "write" || !slices.Equal(claims.Collections, []string{"telemetry"}) || claims.Generation != 3 || claims.Caller != "user_42" ||
+		claims.IssuedAt != now.Unix() || claims.ExpiresAt != now.Add(time.Minute).Unix() || claims.ID == "" {
+		t.Errorf("Verify() = %+v, want the minted claims", claims)
+	}
+
+	other, _ := NewIssuer(nil)
+	parts := strings.Split(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"jsondrop","sub":"db_other","exp":9999999999,"access":"write"}`))
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	tests := []struct {
+		name   string
+		issuer *Issuer
+		token  string
+		now    time.Time
+	}{
+		{"expired", issuer, token, now.Add(time.Minute)},
+		{"other secret", other, token, now},
+		{"changed payload", issuer, parts[0] + "." + forged + "." + parts[2], now},
+		{"unsigned", issuer, none + "." + parts[1] + ".", now},
+		{"malformed", issuer, "a.b", now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if claims, err := tt.issuer.Verify(tt.token, tt.now); err == nil {
+				t.Errorf("Verify() = %+v, want an error", claims)
+			}
+		})
+	}
+}
+
+func TestClaims_PolicyClaims(t *testing.T) {
+	claims := Claims{DatabaseID: "db_abc", Access: "read", Caller: "user_42", IssuedAt: 1700000000, ExpiresAt: 1700000060}
+	got := claims.PolicyClaims()
+	if got["sub"] != "user_42" || got["database_id"] != "db_abc" || got["access"] != "read" || got["exp"] != float64(1700000060) {
+		t.Errorf("PolicyClaims() = %v, want the caller as sub", got)
+	}
+
+	// Without a caller, token.sub is missing rather than empty, so it matches no document
+	claims.Caller = ""
+	if _, ok := claims.PolicyClaims()["sub"]; ok {
+		t.Errorf("PolicyClaims() without a caller = %v, want no sub", claims.PolicyClaims())
+	}
+}
@@ -0,0 +1,80 @@
+package usage
+
+import (
+	"sync"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// Tracker counts each database's requests, bytes written and events consumed per key label
+// Counts are kept in memory and start over when the server restarts
+type Tracker struct {
+	mu        sync.Mutex
+	since     time.Time
+	databases map[string]map[string]*models.KeyUsage // Database ID -> key label -> counts
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{
+		since:     time.Now(),
+		databases: make(map[string]map[string]*models.KeyUsage),
+	}
+}
+
+// AddRequest counts a request made with a key
+func (t *Tracker) AddRequest(dbID string, label string) {
+	t.add(dbID, label, func(u *models.KeyUsage) { u.Requests++ })
+}
+
+// AddBytesWritten counts bytes a key wrote
+func (t *Tracker) AddBytesWritten(dbID string, label string, n int64) {
+	t.add(dbID, label, func(u *models.KeyUsage) { u.BytesWritten += n })
+}
+
+// AddEvent counts an event delivered to a stream opened with a key
+func (t *Tracker) AddEvent(dbID string, label string) {
+	t.add(dbID, label, func(u *models.KeyUsage) { u.EventsConsumed++ })
+}
+
+// add applies fn to a key's counts, creating them if needed
+func (t *Tracker) add(dbID string, label string, fn func(*models.KeyUsage)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keys := t.databases[dbID]
+	if keys == nil {
+		keys = make(map[string]*models.KeyUsage)
+		t.databases[dbID] = keys
+	}
+	u := keys[label]
+	if u == nil {
+		u = &models.KeyUsage{}
+		keys[label] = u
+	}
+	fn(u)
+}
+
+// Usage returns a copy of a database's counts by key label
+func (t *Tracker) Usage(dbID string) *models.UsageResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	resp := &models.UsageResponse{
+		DatabaseID: dbID,
+		Since:      t.since,
+		Keys:       make(map[string]models.KeyUsage),
+	}
+	for label, u := range t.databases[dbID] {
+		resp.Keys[label] = *u
+	}
+	return resp
+}
+
+// Forget drops a database's counts, such as when it is deleted
+func (t *Tracker) Forget(dbID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.databases, dbID)
+}
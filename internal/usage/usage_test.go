@@ -0,0 +1,47 @@
+package usage
+
+import "testing"
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	tracker.AddRequest("db1", "write")
+	tracker.AddRequest("db1", "write")
+	tracker.AddBytesWritten("db1", "write", 120)
+	tracker.AddRequest("db1", "read")
+	tracker.AddEvent("db1", "read")
+	tracker.AddRequest("db2", "public")
+
+	resp := tracker.Usage("db1")
+	if resp.DatabaseID != "db1" {
+		t.Errorf("DatabaseID = %q, want db1", resp.DatabaseID)
+	}
+	if len(resp.Keys) != 2 {
+		t.Fatalf("len(Keys) = %d, want 2", len(resp.Keys))
+	}
+	if got := resp.Keys["write"]; got.Requests != 2 || got.BytesWritten != 120 || got.EventsConsumed != 0 {
+		t.Errorf("write usage = %+v", got)
+	}
+	if got := resp.Keys["read"]; got.Requests != 1 || got.BytesWritten != 0 || got.EventsConsumed != 1 {
+		t.Errorf("read usage = %+v", got)
+	}
+
+	// The response is a copy
+	resp.Keys["write"] = resp.Keys["read"]
+	if got := tracker.Usage("db1").Keys["write"]; got.Requests != 2 {
+		t.Errorf("write requests after modifying response = %d, want 2", got.Requests)
+	}
+}
+
+func TestTracker_Forget(t *testing.T) {
+	tracker := NewTracker()
+	tracker.AddRequest("db1", "write")
+	tracker.AddRequest("db2", "write")
+	tracker.Forget("db1")
+
+	if keys := tracker.Usage("db1").Keys; len(keys) != 0 {
+		t.Errorf("Keys after Forget = %v, want empty", keys)
+	}
+	if keys := tracker.Usage("db2").Keys; keys["write"].Requests != 1 {
+		t.Errorf("db2 Keys = %v, want one write request", keys)
+	}
+}
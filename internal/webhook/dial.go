@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// reserved are special-purpose ranges netip has no predicate for, which a receiver on the
+// internet never has
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which reaches IPv4 addresses
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // Local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+}
+
+// Public reports whether an address is a public unicast address, which webhooks may be
+// delivered to: not loopback, private (RFC 1918 or unique local), link-local such as the
+// 169.254.169.254 metadata service, multicast or otherwise reserved
+func Public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reserved {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialControl refuses connections to addresses that aren't public
+// It runs on the resolved address of every connection, redirects included, so a host
+// name resolving to an internal address, even only after it was validated, gets nowhere
+func dialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook: invalid address %s: %w", address, err)
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("webhook: invalid address %s: %w", address, err)
+	}
+	if !Public(addr) {
+		return fmt.Errorf("webhook: refusing to connect to non-public address %s", addr)
+	}
+	return nil
+}
+
+// newClient returns the HTTP client deliveries are made with, which only connects to
+// public addresses. Proxies from the environment aren't used, since the proxy would
+// make the connection to the receiver instead
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: attemptTimeout, Control: dialControl}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   attemptTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{Timeout: attemptTimeout, Transport: transport}
+}
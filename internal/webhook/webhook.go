@@ -0,0 +1,279 @@
+// Package webhook POSTs change events to the URLs databases register, for consumers that
+// can't hold an event stream open
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// Delivery headers
+const (
+	HeaderEvent     = "X-JSONDrop-Event"     // Event type
+	HeaderDelivery  = "X-JSONDrop-Delivery"  // Delivery ID, the same on every attempt
+	HeaderTimestamp = "X-JSONDrop-Timestamp" // Unix seconds the attempt was signed at
+	HeaderSignature = "X-JSONDrop-Signature" // sha256=HMAC of timestamp.body with the secret
+)
+
+// Deliveries are attempted up to maxAttempts times, waiting firstRetry before the second
+// attempt and twice as long before each one after
+const (
+	maxAttempts    = 6
+	firstRetry     = 10 * time.Second
+	attemptTimeout = 10 * time.Second
+	workers        = 4
+	queueSize      = 1000
+)
+
+// Source lists the webhooks of a database, and keeps the deliveries they never accepted
+type Source interface {
+	ListWebhooks(dbID string) ([]*models.Webhook, error)
+	AddWebhookDeadLetter(dead *models.WebhookDeadLetter) error
+}
+
+// delivery is an event on its way to one webhook
+type delivery struct {
+	id      string
+	hook    *models.Webhook
+	event   models.ChangeEvent
+	body    []byte
+	attempt int // Attempts made so far
+}
+
+// Dispatcher delivers change events to the webhooks wanting them
+// Events are queued and matched to webhooks on one goroutine, so writers never wait on a
+// webhook; deliveries are made by a few workers, only to public addresses, and retried
+// with exponential backoff. Deliveries that fail every attempt, or find the queue full,
+// are kept as dead letters to replay. Deliveries waiting for a retry are kept in memory
+// and lost on restart
+type Dispatcher struct {
+	source     Source
+	client     *http.Client
+	mu         sync.Mutex
+	queue      []models.ChangeEvent
+	wake       chan struct{}
+	deliveries chan *delivery
+	firstRetry time.Duration
+}
+
+// NewDispatcher creates a dispatcher and starts delivering the events it observes
+func NewDispatcher(source Source) *Dispatcher {
+	d := &Dispatcher{
+		source:     source,
+		client:     newClient(),
+		wake:       make(chan struct{}, 1),
+		deliveries: make(chan *delivery, queueSize),
+		firstRetry: firstRetry,
+	}
+	go d.run()
+	for range workers {
+		go d.work()
+	}
+	return d
+}
+
+// Observe queues a change event for the database's webhooks
+// It is registered with the broadcaster, and never blocks
+func (d *Dispatcher) Observe(event models.ChangeEvent) {
+	d.mu.Lock()
+	d.queue = append(d.queue, event)
+	d.mu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+		// Already woken; the queue is drained in full
+	}
+}
+
+// run matches queued events to webhooks until the process exits
+func (d *Dispatcher) run() {
+	for range d.wake {
+		for {
+			d.mu.Lock()
+			batch := d.queue
+			d.queue = nil
+			d.mu.Unlock()
+			if len(batch) == 0 {
+				break
+			}
+			for _, event := range batch {
+				d.dispatch(event)
+			}
+		}
+	}
+}
+
+// dispatch queues a delivery of an event to each webhook wanting it
+func (d *Dispatcher) dispatch(event models.ChangeEvent) {
+	hooks, err := d.source.ListWebhooks(event.DatabaseID)
+	if err != nil {
+		log.Printf("webhook: failed to list webhooks of %s: %v", event.DatabaseID, err)
+		return
+	}
+
+	var body []byte
+	for _, hook := range hooks {
+		if !Wants(hook, event) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(event); err != nil {
+				log.Printf("webhook: failed to marshal %s event: %v", event.EventType, err)
+				return
+			}
+		}
+		id, err := deliveryID()
+		if err != nil {
+			log.Printf("webhook %s: %v", hook.ID, err)
+			continue
+		}
+		d.enqueue(&delivery{id: id, hook: hook, event: event, body: body})
+	}
+}
+
+// Replay queues a dead letter for delivery to its webhook again, with a fresh set of
+// attempts, keeping it as a dead letter if the queue is full
+func (d *Dispatcher) Replay(hook *models.Webhook, dead *models.WebhookDeadLetter) error {
+	dl := &delivery{id: dead.ID, hook: hook, body: dead.Event}
+	if err := json.Unmarshal(dead.Event, &dl.event); err != nil {
+		return fmt.Errorf("failed to unmarshal dead letter %s: %w", dead.ID, err)
+	}
+	if !d.enqueue(dl) {
+		return fmt.Errorf("webhook queue full")
+	}
+	return nil
+}
+
+// enqueue hands a delivery to the workers, keeping it as a dead letter when they are too
+// far behind
+func (d *Dispatcher) enqueue(dl *delivery) bool {
+	select {
+	case d.deliveries <- dl:
+		return true
+	default:
+		log.Printf("webhook %s: queue full, dead-lettered %s delivery %s", dl.hook.ID, dl.event.EventType, dl.id)
+		d.deadLetter(dl, fmt.Errorf("queue full"))
+		return false
+	}
+}
+
+// work makes deliveries until the process exits
+func (d *Dispatcher) work() {
+	for dl := range d.deliveries {
+		d.attempt(dl)
+	}
+}
+
+// attempt makes one delivery attempt, scheduling a retry if it fails
+// Retries are dropped once the webhook is deleted
+func (d *Dispatcher) attempt(dl *delivery) {
+	if dl.attempt > 0 && !d.exists(dl.hook) {
+		return
+	}
+	dl.attempt++
+	err := d.post(dl)
+	if err == nil {
+		return
+	}
+	if dl.attempt >= maxAttempts {
+		log.Printf("webhook %s: giving up on delivery %s after %d attempts: %v", dl.hook.ID, dl.id, dl.attempt, err)
+		d.deadLetter(dl, err)
+		return
+	}
+	time.AfterFunc(d.firstRetry<<(dl.attempt-1), func() { d.enqueue(dl) })
+}
+
+// post sends a delivery, failing unless the receiver answers with a 2xx status
+func (d *Dispatcher) post(dl *delivery) error {
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, dl.hook.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "JSONDrop-Webhook")
+	req.Header.Set(HeaderEvent, dl.event.EventType)
+	req.Header.Set(HeaderDelivery, dl.id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(dl.hook.Secret, timestamp, dl.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return nil
+}
+
+// deadLetter keeps a delivery that won't be attempted again
+func (d *Dispatcher) deadLetter(dl *delivery, cause error) {
+	dead := &models.WebhookDeadLetter{
+		ID:         dl.id,
+		DatabaseID: dl.hook.DatabaseID,
+		WebhookID:  dl.hook.ID,
+		EventType:  dl.event.EventType,
+		Event:      dl.body,
+		Attempts:   dl.attempt,
+		LastError:  cause.Error(),
+		FailedAt:   time.Now(),
+	}
+	if err := d.source.AddWebhookDeadLetter(dead); err != nil {
+		log.Printf("webhook %s: failed to keep dead letter %s: %v", dl.hook.ID, dl.id, err)
+	}
+}
+
+// exists reports whether a webhook is still registered
+func (d *Dispatcher) exists(hook *models.Webhook) bool {
+	hooks, err := d.source.ListWebhooks(hook.DatabaseID)
+	if err != nil {
+		return true // Keep retrying; the catalog may recover
+	}
+	return slices.ContainsFunc(hooks, func(h *models.Webhook) bool { return h.ID == hook.ID })
+}
+
+// Wants reports whether a webhook delivers an event, by its collection and event types
+// bulk_change events never reach webhooks, which see every change before rate limiting
+func Wants(hook *models.Webhook, event models.ChangeEvent) bool {
+	if hook.Collection != "" && event.Collection != hook.Collection {
+		return false
+	}
+	return len(hook.Events) == 0 || slices.Contains(hook.Events, event.EventType)
+}
+
+// Sign returns the signature header value of a delivery body sent at timestamp
+// Receivers recompute it with their secret and compare in constant time, and reject
+// timestamps too far from their clock to stop replays
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliveryID generates a random delivery ID
+func deliveryID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate delivery ID: %w", err)
+	}
+	return "dlv_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,251 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"jsondrop/internal/models"
+)
+
+// staticSource serves a fixed list of webhooks and collects dead letters
+type staticSource struct {
+	mu          sync.Mutex
+	hooks       []*models.Webhook
+	deadLetters []*models.WebhookDeadLetter
+}
+
+func (s *staticSource) ListWebhooks(dbID string) ([]*models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hooks []*models.Webhook
+	for _, hook := range s.hooks {
+		if hook.DatabaseID == dbID {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
+func (s *staticSource) AddWebhookDeadLetter(dead *models.WebhookDeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadLetters = append(s.deadLetters, dead)
+	return nil
+}
+
+func TestWants(t *testing.T) {
+	tests := []struct {
+		name string
+		hook models.Webhook
+		want bool
+	}{
+		{"everything", models.Webhook{}, true},
+		{"collection", models.Webhook{Collection: "posts"}, true},
+		{"other collection", models.Webhook{Collection: "users"}, false},
+		{"event type", models.Webhook{Events: []string{"delete", "insert"}}, true},
+		{"other event type", models.Webhook{Events: []string{"delete"}}, false},
+	}
+	event := models.ChangeEvent{EventType: "insert", Collection: "posts"}
+	for _, tt := range tests {
+		if got := Wants(&tt.hook, event); got != tt.want {
+			t.Errorf("%s: Wants() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{}' | openssl dgst -sha256 -hmac whsec_test
+	want := "sha256=35495024f4ef3f94e5a93e22221544c4b75e9a42300cd965ab81cb85cd994e91"
+	if got := Sign("whsec_test", 1700000000, []byte("{}")); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestDispatcher_RetriesAndSigns(t *testing.T) {
+	type request struct {
+		delivery  string
+		event     string
+		signature string
+		timestamp string
+		body      []byte
+	}
+	requests := make(chan request, 10)
+	var mu sync.Mutex
+	failures := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Header.Get(HeaderDelivery), r.Header.Get(HeaderEvent), r.Header.Get(HeaderSignature), r.Header.Get(HeaderTimestamp), body}
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	source := &staticSource{hooks: []*models.Webhook{
+		{ID: "wh_a", DatabaseID: "db_a", URL: server.URL, Secret: "whsec_a", Events: []string{"insert"}},
+	}}
+	d := NewDispatcher(source)
+	d.client = server.Client() // The test server listens on loopback
+	d.firstRetry = 10 * time.Millisecond
+
+	d.Observe(models.ChangeEvent{EventType: "delete", DatabaseID: "db_a", Collection: "posts"})
+	d.Observe(models.ChangeEvent{EventType: "insert", DatabaseID: "db_a", Collection: "posts", DocumentID: "doc_1"})
+
+	var first request
+	for attempt := 1; attempt <= 3; attempt++ {
+		select {
+		case got := <-requests:
+			if got.event != "insert" {
+				t.Fatalf("attempt %d delivered %s, want the insert", attempt, got.event)
+			}
+			if attempt == 1 {
+				first = got
+			} else if got.delivery != first.delivery {
+				t.Errorf("attempt %d has delivery ID %s, want %s as on the first", attempt, got.delivery, first.delivery)
+			}
+			timestamp, _ := strconv.ParseInt(got.timestamp, 10, 64)
+			if got.signature != Sign("whsec_a", timestamp, got.body) {
+				t.Errorf("attempt %d signature %s doesn't match its body", attempt, got.signature)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("attempt %d never arrived", attempt)
+		}
+	}
+
+	select {
+	case got := <-requests:
+		t.Errorf("unexpected delivery of %s after success", got.event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDispatcher_DropsRetriesOfDeletedWebhooks(t *testing.T) {
+	attempts := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts <- struct{}{}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	source := &staticSource{hooks: []*models.Webhook{{ID: "wh_a", DatabaseID: "db_a", URL: server.URL}}}
+	d := NewDispatcher(source)
+	d.client = server.Client() // The test server listens on loopback
+	d.firstRetry = 50 * time.Millisecond
+
+	d.Observe(models.ChangeEvent{EventType: "insert", DatabaseID: "db_a"})
+	select {
+	case <-attempts:
+	case <-time.After(2 * time.Second):
+		t.Fatal("first attempt never arrived")
+	}
+	source.mu.Lock()
+	source.hooks = nil
+	source.mu.Unlock()
+
+	select {
+	case <-attempts:
+		t.Error("retried a delivery to a deleted webhook")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestDispatcher_DeadLettersAndReplays(t *testing.T) {
+	deliveries := make(chan string, 20)
+	var mu sync.Mutex
+	accept := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries <- r.Header.Get(HeaderDelivery)
+		mu.Lock()
+		defer mu.Unlock()
+		if !accept {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	hook := &models.Webhook{ID: "wh_a", DatabaseID: "db_a", URL: server.URL}
+	source := &staticSource{hooks: []*models.Webhook{hook}}
+	d := NewDispatcher(source)
+	d.client = server.Client() // The test server listens on loopback
+	d.firstRetry = time.Millisecond
+
+	d.Observe(models.ChangeEvent{EventType: "insert", DatabaseID: "db_a", DocumentID: "doc_1"})
+	var dead *models.WebhookDeadLetter
+	for deadline := time.Now().Add(2 * time.Second); dead == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("delivery was never dead-lettered")
+		}
+		source.mu.Lock()
+		if len(source.deadLetters) > 0 {
+			dead = source.deadLetters[0]
+		}
+		source.mu.Unlock()
+	}
+	if dead.WebhookID != "wh_a" || dead.EventType != "insert" || dead.Attempts != maxAttempts || dead.LastError == "" {
+		t.Errorf("dead letter = %+v, want the insert to wh_a after %d attempts with its error", dead, maxAttempts)
+	}
+	for range maxAttempts {
+		<-deliveries
+	}
+
+	mu.Lock()
+	accept = true
+	mu.Unlock()
+	if err := d.Replay(hook, dead); err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	select {
+	case id := <-deliveries:
+		if id != dead.ID {
+			t.Errorf("replay has delivery ID %s, want %s", id, dead.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("replay never arrived")
+	}
+}
+
+func TestPublic(t *testing.T) {
+	for _, raw := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946", "::ffff:93.184.216.34"} {
+		if !Public(netip.MustParseAddr(raw)) {
+			t.Errorf("Public(%s) = false, want true", raw)
+		}
+	}
+	for _, raw := range []string{
+		"127.0.0.1", "::1", "0.0.0.0", "::", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"fe80::1", "fd00::1", "100.64.0.1", "224.0.0.1", "255.255.255.255", "::ffff:127.0.0.1", "64:ff9b::a9fe:a9fe",
+	} {
+		if Public(netip.MustParseAddr(raw)) {
+			t.Errorf("Public(%s) = true, want false", raw)
+		}
+	}
+}
+
+func TestDispatcher_RefusesNonPublicAddresses(t *testing.T) {
+	requests := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- struct{}{}
+	}))
+	defer server.Close()
+
+	// A host name resolving to loopback passes validation but can't be connected to
+	hook := &models.Webhook{ID: "wh_a", DatabaseID: "db_a", URL: strings.Replace(server.URL, "127.0.0.1", "localhost", 1)}
+	d := NewDispatcher(&staticSource{hooks: []*models.Webhook{hook}})
+	if err := d.post(&delivery{id: "dlv_a", hook: hook, body: []byte("{}")}); err == nil || !strings.Contains(err.Error(), "non-public") {
+		t.Errorf("post() error = %v, want a refused non-public address", err)
+	}
+	select {
+	case <-requests:
+		t.Error("delivered to a loopback address")
+	default:
+	}
+}
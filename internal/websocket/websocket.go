@@ -0,0 +1,316 @@
+// Package websocket is a minimal RFC 6455 server for pushing events to clients
+// It covers what event streams need: text messages to the client, pings, and reading
+// the client's messages, pongs and close. Extensions and subprotocols aren't supported
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// acceptGUID is appended to the client's key to prove the server speaks WebSocket
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// MaxMessageBytes caps the messages read from clients
+const MaxMessageBytes = 64 * 1024
+
+// Message types returned by ReadMessage
+const (
+	TextMessage   = 0x1
+	BinaryMessage = 0x2
+	PongMessage   = 0xA
+)
+
+// Frame opcodes besides the message types
+const (
+	opContinuation = 0x0
+	opClose        = 0x8
+	opPing         = 0x9
+)
+
+// Close status codes
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseTooLarge      = 1009
+)
+
+// ErrClosed is returned by ReadMessage once the client has closed the connection
+var ErrClosed = errors.New("websocket: connection closed")
+
+// errTooLarge reports a message over MaxMessageBytes; the connection is closed with 1009
+var errTooLarge = errors.New("websocket: message too large")
+
+// errProtocol reports a frame breaking RFC 6455; the connection is closed with 1002
+type errProtocol string
+
+func (e errProtocol) Error() string { return "websocket: protocol error: " + string(e) }
+
+// IsUpgrade reports whether a request asks to switch to WebSocket
+func IsUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") && headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// AcceptKey returns the Sec-WebSocket-Accept value answering a Sec-WebSocket-Key
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Upgrade completes the opening handshake and takes over the connection
+// Invalid handshakes are reported before anything is written, so the caller can respond
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, fmt.Errorf("invalid websocket handshake: method must be GET")
+	}
+	if !IsUpgrade(r) {
+		return nil, fmt.Errorf("invalid websocket handshake: missing Upgrade: websocket")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, fmt.Errorf("invalid websocket handshake: Sec-WebSocket-Version must be 13")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if nonce, err := base64.StdEncoding.DecodeString(key); err != nil || len(nonce) != 16 {
+		return nil, fmt.Errorf("invalid websocket handshake: bad Sec-WebSocket-Key")
+	}
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take over connection: %w", err)
+	}
+	// The server's read and write timeouts don't apply to a long-lived stream
+	netConn.SetDeadline(time.Time{})
+
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n\r\n"
+	if _, err := rw.WriteString(handshake); err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("failed to complete handshake: %w", err)
+	}
+
+	return NewConn(netConn, rw.Reader), nil
+}
+
+// Conn is the server side of a WebSocket connection
+// Writes may come from several goroutines; reads must come from one
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	mu     sync.Mutex // Serializes frames written
+	closed bool       // A close frame was sent; nothing more may be
+}
+
+// NewConn wraps an established connection; br buffers reads from it, and may be nil
+func NewConn(conn net.Conn, br *bufio.Reader) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	return &Conn{conn: conn, br: br}
+}
+
+// WriteText sends a text message, which must be written within timeout
+func (c *Conn) WriteText(data []byte, timeout time.Duration) error {
+	return c.writeFrame(TextMessage, data, timeout)
+}
+
+// Ping sends a ping; the client answers with a pong, returned by ReadMessage
+func (c *Conn) Ping(timeout time.Duration) error {
+	return c.writeFrame(opPing, nil, timeout)
+}
+
+// Close sends a close frame with a status code and reason, then closes the connection
+func (c *Conn) Close(code int, reason string) error {
+	c.writeClose(code, reason)
+	return c.conn.Close()
+}
+
+// writeClose sends a close frame unless one was sent already
+func (c *Conn) writeClose(code int, reason string) {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > 125 {
+		payload = payload[:125]
+	}
+	c.writeFrame(opClose, payload, time.Second)
+}
+
+// writeFrame writes a single unmasked frame, as servers must
+func (c *Conn) writeFrame(opcode byte, payload []byte, timeout time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	if opcode == opClose {
+		c.closed = true
+	}
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode // FIN: every message is a single frame
+	switch {
+	case len(payload) <= 125:
+		header[1] = byte(len(payload))
+	case len(payload) <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+
+	if timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(timeout))
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReadMessage returns the next text or binary message, or a pong with its payload
+// Pings are answered and a close from the client is confirmed, returning ErrClosed.
+// A protocol violation or a message over MaxMessageBytes closes the connection
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var messageType int
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			var protocolErr errProtocol
+			switch {
+			case errors.As(err, &protocolErr):
+				c.Close(CloseProtocolError, string(protocolErr))
+			case errors.Is(err, errTooLarge):
+				c.Close(CloseTooLarge, "message too large")
+			}
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(PongMessage, payload, time.Second); err != nil {
+				return 0, nil, err
+			}
+		case PongMessage:
+			// A pong between the frames of a message is dropped; the next one will do
+			if messageType == 0 {
+				return PongMessage, payload, nil
+			}
+		case opClose:
+			code := CloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.writeClose(code, "")
+			c.conn.Close()
+			return 0, nil, ErrClosed
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				c.Close(CloseProtocolError, "expected continuation frame")
+				return 0, nil, errProtocol("expected continuation frame")
+			}
+			messageType = int(opcode)
+			message = payload
+		case opContinuation:
+			if messageType == 0 {
+				c.Close(CloseProtocolError, "unexpected continuation frame")
+				return 0, nil, errProtocol("unexpected continuation frame")
+			}
+			if len(message)+len(payload) > MaxMessageBytes {
+				c.Close(CloseTooLarge, "message too large")
+				return 0, nil, errTooLarge
+			}
+			message = append(message, payload...)
+		default:
+			c.Close(CloseProtocolError, "unknown opcode")
+			return 0, nil, errProtocol(fmt.Sprintf("unknown opcode %d", opcode))
+		}
+
+		if fin && opcode < opClose {
+			return messageType, message, nil
+		}
+	}
+}
+
+// readFrame reads and unmasks one frame from the client
+func (c *Conn) readFrame() (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := head[0]&0x80 != 0
+	opcode := head[0] & 0x0F
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, errProtocol("reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, errProtocol("client frames must be masked")
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, errProtocol("invalid control frame")
+	}
+	if length > MaxMessageBytes {
+		return false, 0, nil, errTooLarge
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// headerHasToken reports whether a comma-separated header lists a token, ignoring case
+func headerHasToken(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,365 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// The example handshake of RFC 6455 section 1.3
+	if got, want := AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("AcceptKey() = %s, want %s", got, want)
+	}
+}
+
+func TestUpgradeRejectsInvalidHandshakes(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"plain request", nil},
+		{"old version", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ=="}},
+		{"bad key", map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "short"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			if _, err := Upgrade(httptest.NewRecorder(), r); err == nil {
+				t.Error("Upgrade() error = nil, want an invalid handshake")
+			}
+		})
+	}
+}
+
+// clientFrame encodes a masked frame as a client sends it
+func clientFrame(fin bool, opcode byte, payload []byte) []byte {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	var frame []byte
+	switch {
+	case len(payload) <= 125:
+		frame = []byte{b0, 0x80 | byte(len(payload))}
+	case len(payload) <= 0xFFFF:
+		frame = binary.BigEndian.AppendUint16([]byte{b0, 0x80 | 126}, uint16(len(payload)))
+	default:
+		frame = binary.BigEndian.AppendUint64([]byte{b0, 0x80 | 127}, uint64(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, c := range payload {
+		frame = append(frame, c^mask[i%4])
+	}
+	return frame
+}
+
+// nextServerFrame decodes an unmasked frame with a payload under 64 KB as the server
+// sends it
+func nextServerFrame(r io.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	length := int(head[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return head[0] & 0x0F, payload, nil
+}
+
+// readServerFrame reads the next frame the server sends
+func readServerFrame(t *testing.T, r io.Reader) (byte, []byte) {
+	t.Helper()
+	opcode, payload, err := nextServerFrame(r)
+	if err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	return opcode, payload
+}
+
+func TestConn(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := NewConn(server, nil)
+
+	go conn.WriteText([]byte(`{"event":"change"}`), time.Second)
+	if opcode, payload := readServerFrame(t, client); opcode != TextMessage || string(payload) != `{"event":"change"}` {
+		t.Errorf("server sent %d %q, want the text message", opcode, payload)
+	}
+
+	long := bytes.Repeat([]byte("x"), 300)
+	go conn.WriteText(long, time.Second)
+	if _, payload := readServerFrame(t, client); !bytes.Equal(payload, long) {
+		t.Errorf("server sent %d bytes, want %d", len(payload), len(long))
+	}
+
+	// A fragmented message is reassembled, and a ping in between is answered
+	go func() {
+		client.Write(clientFrame(false, TextMessage, []byte("hel")))
+		client.Write(clientFrame(true, opPing, []byte("p")))
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if opcode, payload := readServerFrame(t, client); opcode != PongMessage || string(payload) != "p" {
+			t.Errorf("server sent %d %q, want a pong", opcode, payload)
+		}
+		client.Write(clientFrame(true, opContinuation, []byte("lo")))
+	}()
+	messageType, message, err := conn.ReadMessage()
+	if err != nil || messageType != TextMessage || string(message) != "hello" {
+		t.Errorf("ReadMessage() = %d, %q, %v, want the text hello", messageType, message, err)
+	}
+	<-done
+
+	go client.Write(clientFrame(true, PongMessage, nil))
+	if messageType, _, err := conn.ReadMessage(); err != nil || messageType != PongMessage {
+		t.Errorf("ReadMessage() = %d, %v, want a pong", messageType, err)
+	}
+
+	// A close is confirmed with the same code
+	closeFrame := make([]byte, 2)
+	binary.BigEndian.PutUint16(closeFrame, CloseGoingAway)
+	go client.Write(clientFrame(true, opClose, closeFrame))
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		if opcode, payload := readServerFrame(t, client); opcode != opClose || !bytes.Equal(payload, closeFrame) {
+			t.Errorf("server sent %d %v, want a close with code 1001", opcode, payload)
+		}
+	}()
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, ErrClosed) {
+		t.Errorf("ReadMessage() error = %v, want ErrClosed", err)
+	}
+	<-done
+	if err := conn.WriteText([]byte("late"), time.Second); !errors.Is(err, ErrClosed) {
+		t.Errorf("WriteText() after close = %v, want ErrClosed", err)
+	}
+}
+
+func TestConnRejectsUnmaskedFrames(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := NewConn(server, bufio.NewReader(server))
+
+	go client.Write([]byte{0x81, 0x01, 'x'})
+	go io.Copy(io.Discard, client)
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("ReadMessage() error = nil, want a protocol error")
+	}
+}
+
+// serverFrame is a frame the server sent, with the status code of a close
+type serverFrame struct {
+	opcode    byte
+	payload   string
+	closeCode int
+}
+
+// exchange sends frames from a client and reads a message from them, returning the
+// frames the server sent meanwhile
+func exchange(t *testing.T, frames ...[]byte) (int, []byte, []serverFrame, error) {
+	t.Helper()
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := NewConn(server, nil)
+
+	// Writes stop when the server stops reading and the pipe closes
+	go func() {
+		for _, frame := range frames {
+			if _, err := client.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+	sent := make(chan []serverFrame)
+	go func() {
+		var got []serverFrame
+		for {
+			opcode, payload, err := nextServerFrame(client)
+			if err != nil {
+				sent <- got
+				return
+			}
+			frame := serverFrame{opcode: opcode, payload: string(payload)}
+			if opcode == opClose && len(payload) >= 2 {
+				frame.closeCode = int(binary.BigEndian.Uint16(payload))
+				frame.payload = string(payload[2:])
+			}
+			got = append(got, frame)
+		}
+	}()
+
+	messageType, message, err := conn.ReadMessage()
+	server.Close()
+	return messageType, message, <-sent, err
+}
+
+func TestReadMessage_Fragmented(t *testing.T) {
+	tests := []struct {
+		name     string
+		frames   [][]byte
+		wantType int
+		want     string
+	}{
+		{"text in three fragments", [][]byte{
+			clientFrame(false, TextMessage, []byte("frag")),
+			clientFrame(false, opContinuation, []byte("men")),
+			clientFrame(true, opContinuation, []byte("ted")),
+		}, TextMessage, "fragmented"},
+		{"binary in two fragments", [][]byte{
+			clientFrame(false, BinaryMessage, []byte{0, 1}),
+			clientFrame(true, opContinuation, []byte{2}),
+		}, BinaryMessage, "\x00\x01\x02"},
+		{"empty final fragment", [][]byte{
+			clientFrame(false, TextMessage, []byte("done")),
+			clientFrame(true, opContinuation, nil),
+		}, TextMessage, "done"},
+		{"fragments with extended lengths", [][]byte{
+			clientFrame(false, TextMessage, bytes.Repeat([]byte("a"), 200)),
+			clientFrame(true, opContinuation, bytes.Repeat([]byte("b"), 300)),
+		}, TextMessage, strings.Repeat("a", 200) + strings.Repeat("b", 300)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageType, message, sent, err := exchange(t, tt.frames...)
+			if err != nil || messageType != tt.wantType || string(message) != tt.want {
+				t.Errorf("ReadMessage() = %d, %q, %v, want %d, %q", messageType, message, err, tt.wantType, tt.want)
+			}
+			if len(sent) != 0 {
+				t.Errorf("server sent %+v, want nothing", sent)
+			}
+		})
+	}
+}
+
+func TestReadMessage_Oversized(t *testing.T) {
+	half := bytes.Repeat([]byte("x"), MaxMessageBytes/2)
+	tests := []struct {
+		name    string
+		frames  [][]byte
+		wantErr bool
+	}{
+		{"frame at the limit", [][]byte{clientFrame(true, TextMessage, bytes.Repeat([]byte("x"), MaxMessageBytes))}, false},
+		{"frame over the limit", [][]byte{clientFrame(true, TextMessage, bytes.Repeat([]byte("x"), MaxMessageBytes+1))}, true},
+		{"fragments adding up to the limit", [][]byte{
+			clientFrame(false, TextMessage, half),
+			clientFrame(true, opContinuation, half),
+		}, false},
+		{"fragments adding up to more", [][]byte{
+			clientFrame(false, TextMessage, half),
+			clientFrame(false, opContinuation, half),
+			clientFrame(true, opContinuation, []byte("x")),
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, message, sent, err := exchange(t, tt.frames...)
+			if !tt.wantErr {
+				if err != nil || len(message) != MaxMessageBytes {
+					t.Errorf("ReadMessage() = %d bytes, %v, want %d bytes", len(message), err, MaxMessageBytes)
+				}
+				return
+			}
+			if !errors.Is(err, errTooLarge) {
+				t.Errorf("ReadMessage() error = %v, want errTooLarge", err)
+			}
+			if len(sent) != 1 || sent[0].opcode != opClose || sent[0].closeCode != CloseTooLarge {
+				t.Errorf("server sent %+v, want a close with code %d", sent, CloseTooLarge)
+			}
+		})
+	}
+}
+
+func TestReadMessage_ControlFramesBetweenFragments(t *testing.T) {
+	closePayload := binary.BigEndian.AppendUint16(nil, CloseGoingAway)
+	tests := []struct {
+		name      string
+		frames    [][]byte
+		want      string // The message read, when there is one
+		wantErr   error
+		wantClose int // Status code of the close the server sends; 0 if none
+		wantPong  string
+	}{
+		{"ping is answered", [][]byte{
+			clientFrame(false, TextMessage, []byte("hel")),
+			clientFrame(true, opPing, []byte("p1")),
+			clientFrame(false, opContinuation, []byte("l")),
+			clientFrame(true, opPing, []byte("p2")),
+			clientFrame(true, opContinuation, []byte("o")),
+		}, "hello", nil, 0, "p1p2"},
+		{"pong is dropped", [][]byte{
+			clientFrame(false, TextMessage, []byte("hel")),
+			clientFrame(true, PongMessage, []byte("late")),
+			clientFrame(true, opContinuation, []byte("lo")),
+		}, "hello", nil, 0, ""},
+		{"close ends the message", [][]byte{
+			clientFrame(false, TextMessage, []byte("hel")),
+			clientFrame(true, opClose, closePayload),
+			clientFrame(true, opContinuation, []byte("lo")),
+		}, "", ErrClosed, CloseGoingAway, ""},
+		{"fragmented ping", [][]byte{
+			clientFrame(false, TextMessage, []byte("hel")),
+			clientFrame(false, opPing, []byte("p")),
+		}, "", errProtocol("invalid control frame"), CloseProtocolError, ""},
+		{"ping over 125 bytes", [][]byte{
+			clientFrame(false, TextMessage, []byte("hel")),
+			clientFrame(true, opPing, bytes.Repeat([]byte("p"), 126)),
+		}, "", errProtocol("invalid control frame"), CloseProtocolError, ""},
+		{"new message before the last ends", [][]byte{
+			clientFrame(false, TextMessage, []byte("hel")),
+			clientFrame(true, TextMessage, []byte("lo")),
+		}, "", errProtocol("expected continuation frame"), CloseProtocolError, ""},
+		{"continuation without a message", [][]byte{
+			clientFrame(true, opContinuation, []byte("lo")),
+		}, "", errProtocol("unexpected continuation frame"), CloseProtocolError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, message, sent, err := exchange(t, tt.frames...)
+			if err != tt.wantErr || string(message) != tt.want {
+				t.Errorf("ReadMessage() = %q, %v, want %q, %v", message, err, tt.want, tt.wantErr)
+			}
+
+			var pongs string
+			closeCode := 0
+			for _, frame := range sent {
+				switch frame.opcode {
+				case PongMessage:
+					pongs += frame.payload
+				case opClose:
+					closeCode = frame.closeCode
+				default:
+					t.Errorf("server sent opcode %d", frame.opcode)
+				}
+			}
+			if pongs != tt.wantPong {
+				t.Errorf("server ponged %q, want %q", pongs, tt.wantPong)
+			}
+			if closeCode != tt.wantClose {
+				t.Errorf("server closed with %d, want %d", closeCode, tt.wantClose)
+			}
+		})
+	}
+}